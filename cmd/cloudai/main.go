@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/ddjura/cloudai/internal/cli"
@@ -9,7 +8,7 @@ import (
 
 func main() {
 	if err := cli.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		cli.ReportError(err)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -8,10 +8,149 @@ import * as tasks from 'aws-cdk-lib/aws-stepfunctions-tasks';
 import * as events from 'aws-cdk-lib/aws-events';
 import * as targets from 'aws-cdk-lib/aws-events-targets';
 import * as ec2 from 'aws-cdk-lib/aws-ec2';
+import * as s3assets from 'aws-cdk-lib/aws-s3-assets';
+import * as sagemaker from 'aws-cdk-lib/aws-sagemaker';
+import { execSync } from 'child_process';
+import * as path from 'path';
+
+/** A base model the LoRA recipe knows how to fine-tune */
+export interface BaseModel {
+  /** Hugging Face model ID */
+  modelId: string;
+  /** Parameter count in billions, which decides the training instance */
+  sizeBillions: number;
+  /** Whether the model is gated on Hugging Face and needs a token */
+  gated: boolean;
+}
+
+/** Base models for the LoRA fine-tune, by the name used in ArchTrainingStackProps.baseModel */
+export const BASE_MODELS: Record<string, BaseModel> = {
+  'llama-3.1-8b': { modelId: 'meta-llama/Meta-Llama-3.1-8B-Instruct', sizeBillions: 8, gated: true },
+  'llama-3.1-70b': { modelId: 'meta-llama/Meta-Llama-3.1-70B-Instruct', sizeBillions: 70, gated: true },
+  'llama-2-13b': { modelId: 'meta-llama/Llama-2-13b-chat-hf', sizeBillions: 13, gated: true },
+  'mistral-7b': { modelId: 'mistralai/Mistral-7B-Instruct-v0.3', sizeBillions: 7, gated: true },
+  'mistral-nemo-12b': { modelId: 'mistralai/Mistral-Nemo-Instruct-2407', sizeBillions: 12, gated: true },
+};
+
+/** Where a model of a given size is trained and served */
+export interface InstanceSelection {
+  trainingInstanceType: string;
+  /** EBS volume for the base weights, checkpoints and merged model */
+  volumeGiB: number;
+  /** GPUs of the inference instance, passed to TGI as SM_NUM_GPUS */
+  inferenceInstanceType: string;
+  inferenceGpus: number;
+  /** QLoRA (4-bit base weights) keeps the model on a single GPU's memory */
+  loadIn4Bit: boolean;
+  maxRuntime: cdk.Duration;
+}
+
+/**
+ * Picks instances for a LoRA fine-tune of a model with sizeBillions parameters.
+ * QLoRA needs roughly 0.7 GB of GPU memory per billion parameters plus
+ * activations, and serving the merged model in bf16 about 2 GB per billion:
+ *   ≤ 8B  → 1× A10G (24 GB), served on 1× A10G
+ *   ≤ 14B → 4× A10G (96 GB), served on 4× A10G
+ *   larger → 8× A100 (320 GB), served on 8× A10G
+ */
+export function selectInstances(sizeBillions: number): InstanceSelection {
+  if (sizeBillions <= 8) {
+    return {
+      trainingInstanceType: 'ml.g5.2xlarge',
+      volumeGiB: 100,
+      inferenceInstanceType: 'ml.g5.2xlarge',
+      inferenceGpus: 1,
+      loadIn4Bit: true,
+      maxRuntime: cdk.Duration.hours(6),
+    };
+  }
+  if (sizeBillions <= 14) {
+    return {
+      trainingInstanceType: 'ml.g5.12xlarge',
+      volumeGiB: 200,
+      inferenceInstanceType: 'ml.g5.12xlarge',
+      inferenceGpus: 4,
+      loadIn4Bit: true,
+      maxRuntime: cdk.Duration.hours(12),
+    };
+  }
+  return {
+    trainingInstanceType: 'ml.p4d.24xlarge',
+    volumeGiB: 500,
+    inferenceInstanceType: 'ml.g5.48xlarge',
+    inferenceGpus: 8,
+    loadIn4Bit: true,
+    maxRuntime: cdk.Duration.hours(24),
+  };
+}
 
 export interface ArchTrainingStackProps extends cdk.StackProps {
   /** The frequency in minutes to run the background training (default: 1440 – daily) */
   scheduleMinutes?: number;
+  /** A key of BASE_MODELS or a Hugging Face model ID (default: llama-3.1-8b) */
+  baseModel?: string;
+  /** Parameter count in billions when baseModel is not a key of BASE_MODELS */
+  baseModelSizeBillions?: number;
+  /** Secrets Manager secret holding a Hugging Face token, required for gated models */
+  hfTokenSecretName?: string;
+  /** Overrides the training instance selectInstances picks */
+  trainingInstanceType?: string;
+  /** Model package group the fine-tuned models are registered in (default: cloudai-arch-models) */
+  modelPackageGroupName?: string;
+  /** LoRA hyper-parameters overriding the recipe's defaults, e.g. { lora_r: '32' } */
+  hyperparameters?: Record<string, string>;
+  /** Train on managed spot capacity, resuming from S3 checkpoints after interruptions (default: true) */
+  spotTraining?: boolean;
+  /** How long a spot job may wait for capacity and run in total (default: twice the maximum runtime) */
+  spotMaxWait?: cdk.Duration;
+}
+
+/** Managed spot settings SageMakerCreateTrainingJob does not expose */
+export interface SpotTrainingConfig {
+  /** Total time the job may take, waiting for spot capacity included; at least the maximum runtime */
+  maxWait: cdk.Duration;
+  /** S3 URI /opt/ml/checkpoints is synced to, so an interrupted job resumes; may be a JsonPath */
+  checkpointS3Uri: string;
+}
+
+export interface SpotTrainingJobProps extends tasks.SageMakerCreateTrainingJobProps {
+  /** Runs on managed spot capacity when set */
+  spot?: SpotTrainingConfig;
+}
+
+/**
+ * SageMakerCreateTrainingJob with EnableManagedSpotTraining, CheckpointConfig
+ * and StoppingCondition.MaxWaitTimeInSeconds added to the request.
+ */
+export class SpotTrainingJob extends tasks.SageMakerCreateTrainingJob {
+  private readonly spot?: SpotTrainingConfig;
+
+  constructor(scope: Construct, id: string, props: SpotTrainingJobProps) {
+    super(scope, id, props);
+    this.spot = props.spot;
+    if (props.spot && props.stoppingCondition?.maxRuntime
+      && props.spot.maxWait.toSeconds() < props.stoppingCondition.maxRuntime.toSeconds()) {
+      throw new Error('spot maxWait must be at least the maximum runtime');
+    }
+  }
+
+  protected _renderTask(...args: any[]): any {
+    const task = (super._renderTask as (...a: any[]) => any)(...args);
+    if (!this.spot) {
+      return task;
+    }
+    const request = task.Parameters ?? task.Arguments;
+    request.EnableManagedSpotTraining = true;
+    request.CheckpointConfig = sfn.FieldUtils.renderObject({
+      S3Uri: this.spot.checkpointS3Uri,
+      LocalPath: '/opt/ml/checkpoints',
+    });
+    request.StoppingCondition = {
+      ...request.StoppingCondition,
+      MaxWaitTimeInSeconds: this.spot.maxWait.toSeconds(),
+    };
+    return task;
+  }
 }
 
 /**
@@ -20,8 +159,12 @@ export interface ArchTrainingStackProps extends cdk.StackProps {
  * 2. Lambda to collect AWS architecture metadata → S3 (raw/)
  * 3. Lambda to convert raw JSON → JSONL friendly for fine-tuning (train/)
  * 4. Step-Functions State Machine that orchestrates:
- *      Collect → Prep → SageMaker TrainingJob
+ *      Collect → Prep → SageMaker TrainingJob (LoRA) → Model Registry
  * 5. EventBridge rule that triggers the State Machine on a cron schedule
+ *
+ * The training job runs training/llm_finetune.py in the Hugging Face DLC and
+ * registers the merged model, with a TGI serving container, as a pending
+ * model package ready to be approved and deployed to an endpoint.
  */
 export class ArchTrainingStack extends cdk.Stack {
   constructor(scope: Construct, id: string, props: ArchTrainingStackProps = {}) {
@@ -131,6 +274,22 @@ def handler(event, context):
     //---------------------------------------------------------------------
     // 3. SageMaker training task definition
     //---------------------------------------------------------------------
+    const modelKey = props.baseModel ?? 'llama-3.1-8b';
+    const baseModel: BaseModel = BASE_MODELS[modelKey] ?? {
+      modelId: modelKey,
+      sizeBillions: props.baseModelSizeBillions ?? 8,
+      gated: false,
+    };
+    if (!BASE_MODELS[modelKey] && props.baseModelSizeBillions === undefined) {
+      cdk.Annotations.of(this).addWarning(`Size of ${modelKey} unknown; set baseModelSizeBillions to pick the right instance`);
+    }
+    if (baseModel.gated && !props.hfTokenSecretName) {
+      cdk.Annotations.of(this).addWarning(`${baseModel.modelId} is gated on Hugging Face; set hfTokenSecretName or training will fail`);
+    }
+    const instances = selectInstances(baseModel.sizeBillions);
+    const spotTraining = props.spotTraining ?? true;
+    const spotMaxWait = props.spotMaxWait ?? cdk.Duration.seconds(instances.maxRuntime.toSeconds() * 2);
+
     // IAM role that SageMaker uses inside the training container
     const sagemakerExecRole = new iam.Role(this, 'SageMakerExecRole', {
       assumedBy: new iam.ServicePrincipal('sagemaker.amazonaws.com'),
@@ -138,26 +297,68 @@ def handler(event, context):
     bucket.grantReadWrite(sagemakerExecRole);
     sagemakerExecRole.addManagedPolicy(iam.ManagedPolicy.fromAwsManagedPolicyName('AmazonSageMakerFullAccess'));
 
+    const environment: Record<string, string> = {};
+    if (props.hfTokenSecretName) {
+      environment.HF_TOKEN_SECRET = props.hfTokenSecretName;
+      sagemakerExecRole.addToPolicy(new iam.PolicyStatement({
+        actions: ['secretsmanager:GetSecretValue'],
+        resources: [this.formatArn({
+          service: 'secretsmanager',
+          resource: 'secret',
+          resourceName: `${props.hfTokenSecretName}-*`,
+          arnFormat: cdk.ArnFormat.COLON_RESOURCE_NAME,
+        })],
+      }));
+    }
+
+    // The DLC downloads sagemaker_submit_directory and expects a tar.gz of it
+    const trainingCode = new s3assets.Asset(this, 'TrainingCode', {
+      path: path.join(__dirname, '..', 'training'),
+      exclude: ['__pycache__', '*.pyc'],
+      bundling: {
+        image: cdk.DockerImage.fromRegistry('public.ecr.aws/docker/library/alpine:3'),
+        command: ['sh', '-c', 'tar -czf /asset-output/sourcedir.tar.gz -C /asset-input .'],
+        outputType: cdk.BundlingOutput.ARCHIVED,
+        local: {
+          tryBundle(outputDir: string) {
+            execSync(`tar -czf ${path.join(outputDir, 'sourcedir.tar.gz')} -C ${path.join(__dirname, '..', 'training')} --exclude=__pycache__ .`);
+            return true;
+          },
+        },
+      },
+    });
+    trainingCode.grantRead(sagemakerExecRole);
+
     const trainingImageUri = `763104351884.dkr.ecr.${this.region}.amazonaws.com/huggingface-pytorch-training:2.1.1-transformers4.39.1-gpu-py310-cu121-ubuntu20.04`;
+    const inferenceImageUri = `763104351884.dkr.ecr.${this.region}.amazonaws.com/huggingface-pytorch-tgi-inference:2.3.0-tgi2.2.0-gpu-py310-cu121-ubuntu22.04`;
 
-    const trainingJobTask = new tasks.SageMakerCreateTrainingJob(this, 'CreateTrainingJob', {
+    const trainingJobTask = new SpotTrainingJob(this, 'CreateTrainingJob', {
       trainingJobName: sfn.JsonPath.stringAt('$$.Execution.Name'),
       algorithmSpecification: {
         trainingImage: tasks.DockerImage.fromRegistry(trainingImageUri),
         trainingInputMode: tasks.InputMode.FILE,
       },
       hyperparameters: {
-        "model_name_or_path": "tiiuae/falcon-7b-instruct",
-        "do_train": "True",
-        "num_train_epochs": "1",
-        "per_device_train_batch_size": "2",
-        "learning_rate": "2e-5",
-        "output_dir": "/opt/ml/model",
-        "logging_steps": "10"
+        sagemaker_program: 'llm_finetune.py',
+        sagemaker_submit_directory: trainingCode.s3ObjectUrl,
+        model_id: baseModel.modelId,
+        num_train_epochs: '3',
+        learning_rate: '2e-4',
+        per_device_train_batch_size: '1',
+        gradient_accumulation_steps: '8',
+        max_seq_length: '2048',
+        lora_r: '16',
+        lora_alpha: '32',
+        lora_dropout: '0.05',
+        load_in_4bit: String(instances.loadIn4Bit),
+        merge_weights: 'true',
+        logging_steps: '10',
+        ...props.hyperparameters,
       },
+      environment,
       inputDataConfig: [
         {
-          channelName: 'training',
+          channelName: 'train',
           dataSource: {
             s3DataSource: {
               s3DataType: tasks.S3DataType.S3_PREFIX,
@@ -172,16 +373,67 @@ def handler(event, context):
       },
       resourceConfig: {
         instanceCount: 1,
-        instanceType: new ec2.InstanceType('ml.g4dn.xlarge'),
-        volumeSize: cdk.Size.gibibytes(30),
+        instanceType: new ec2.InstanceType((props.trainingInstanceType ?? instances.trainingInstanceType).replace(/^ml\./, '')),
+        volumeSize: cdk.Size.gibibytes(instances.volumeGiB),
       },
       role: sagemakerExecRole,
       stoppingCondition: {
-        maxRuntime: cdk.Duration.hours(2),
+        maxRuntime: instances.maxRuntime,
       },
+      // spot capacity is typically 60-70% cheaper; interrupted jobs resume
+      // from the checkpoints llm_finetune.py writes
+      spot: spotTraining ? {
+        maxWait: spotMaxWait,
+        // one prefix per execution, so a new run does not resume an old one
+        checkpointS3Uri: sfn.JsonPath.format(`s3://${bucket.bucketName}/checkpoints/{}`, sfn.JsonPath.stringAt('$$.Execution.Name')),
+      } : undefined,
       integrationPattern: sfn.IntegrationPattern.RUN_JOB,
     });
 
+    //---------------------------------------------------------------------
+    // 3b. Register the merged model for deployment to a TGI endpoint
+    //---------------------------------------------------------------------
+    const modelPackageGroupName = props.modelPackageGroupName ?? 'cloudai-arch-models';
+    const modelPackageGroup = new sagemaker.CfnModelPackageGroup(this, 'ArchModelPackageGroup', {
+      modelPackageGroupName,
+      modelPackageGroupDescription: 'LoRA fine-tunes of open LLMs on architecture Q&A, served by TGI',
+    });
+
+    const registerModel = new tasks.CallAwsService(this, 'RegisterModel', {
+      service: 'sagemaker',
+      action: 'createModelPackage',
+      parameters: {
+        ModelPackageGroupName: modelPackageGroupName,
+        ModelPackageDescription: `LoRA fine-tune of ${baseModel.modelId}`,
+        ModelApprovalStatus: 'PendingManualApproval',
+        InferenceSpecification: {
+          Containers: [{
+            Image: inferenceImageUri,
+            ModelDataUrl: sfn.JsonPath.stringAt('$.ModelArtifacts.S3ModelArtifacts'),
+            Environment: {
+              HF_MODEL_ID: '/opt/ml/model',
+              SM_NUM_GPUS: String(instances.inferenceGpus),
+              MAX_INPUT_LENGTH: '3072',
+              MAX_TOTAL_TOKENS: '4096',
+            },
+          }],
+          SupportedContentTypes: ['application/json'],
+          SupportedResponseMIMETypes: ['application/json'],
+          SupportedRealtimeInferenceInstanceTypes: [instances.inferenceInstanceType],
+        },
+        CustomerMetadataProperties: {
+          base_model: baseModel.modelId,
+          training_job: sfn.JsonPath.stringAt('$.TrainingJobName'),
+          cloudai_format: 'tgi',
+        },
+      },
+      iamResources: [
+        this.formatArn({ service: 'sagemaker', resource: 'model-package-group', resourceName: modelPackageGroupName }),
+        this.formatArn({ service: 'sagemaker', resource: 'model-package', resourceName: `${modelPackageGroupName}/*` }),
+      ],
+      resultPath: '$.ModelPackage',
+    });
+
     //---------------------------------------------------------------------
     // 4. Assemble Step Functions workflow
     //---------------------------------------------------------------------
@@ -198,12 +450,13 @@ def handler(event, context):
       outputPath: '$',
     });
 
-    const definition = invokeCollector.next(invokePrep).next(trainingJobTask);
+    const definition = invokeCollector.next(invokePrep).next(trainingJobTask).next(registerModel);
 
     const stateMachine = new sfn.StateMachine(this, 'ArchTrainingStateMachine', {
       definition,
-      timeout: cdk.Duration.hours(3),
+      timeout: (spotTraining ? spotMaxWait : instances.maxRuntime).plus(cdk.Duration.hours(1)),
     });
+    stateMachine.node.addDependency(modelPackageGroup);
 
     //---------------------------------------------------------------------
     // 5. EventBridge schedule
@@ -1,5 +1,5 @@
 #!/usr/bin/env python
-"""Fine-tune an open-source LLM on architecture Q&A pairs.
+"""LoRA fine-tune an open-source LLM on architecture Q&A pairs.
 
 This script is designed to run inside the official HuggingFace SageMaker DLC
 image (PyTorch / Transformers).  It expects the following environment
@@ -9,10 +9,22 @@ variables provided by SageMaker training jobs:
     line contains {"prompt": ..., "completion": ...}
   * SM_MODEL_DIR     – where to save the final model/artifacts
   * SM_NUM_GPUS      – number of available GPUs (string)
+  * HF_TOKEN_SECRET  – optional Secrets Manager secret holding a Hugging Face
+    token, needed for gated models such as Llama
+
+When the job has a checkpoint config (managed spot training), checkpoints are
+written to /opt/ml/checkpoints, which SageMaker syncs to S3, and a restarted
+job resumes from the latest one instead of starting over.
 
 The hyper-parameters are passed as command-line args from the SageMaker
-training job definition.  We support the most common ones (epochs, lr, batch)
-with sensible defaults.
+training job definition.  Defaults follow the usual LoRA recipe for 7-8B
+instruct models (r=16, alpha=32, lr=2e-4); with --load_in_4bit the base model
+is quantised (QLoRA) so an 8B model fits on a single 24 GB A10G.
+
+With --merge_weights (the default) the adapter is merged into the base model
+and the result saved in SM_MODEL_DIR, so the model.tar.gz SageMaker uploads
+can be served as-is by the TGI inference container.  Otherwise only the
+adapter is saved, under SM_MODEL_DIR/adapter.
 """
 
 import argparse
@@ -21,16 +33,58 @@ import os
 from pathlib import Path
 
 import torch
-from datasets import load_dataset, Dataset
-from transformers import AutoTokenizer, AutoModelForCausalLM, TrainingArguments, Trainer
-from peft import LoraConfig, get_peft_model
+from datasets import Dataset
+from transformers import (
+    AutoModelForCausalLM,
+    AutoTokenizer,
+    BitsAndBytesConfig,
+    DataCollatorForLanguageModeling,
+    Trainer,
+    TrainingArguments,
+)
+from peft import LoraConfig, get_peft_model, prepare_model_for_kbit_training
+
+
+HF_CACHE = os.environ.get("HF_HOME", "/tmp/hf-cache")
+
+# SageMaker syncs this directory with CheckpointConfig.S3Uri
+SM_CHECKPOINT_DIR = Path("/opt/ml/checkpoints")
+
+# Attention and MLP projections of Llama and Mistral style decoders.  Adapting
+# all of them trains noticeably better than q/v alone for little extra memory.
+DEFAULT_TARGET_MODULES = "q_proj,k_proj,v_proj,o_proj,gate_proj,up_proj,down_proj"
+
 
+def str2bool(value: str) -> bool:
+    """SageMaker passes every hyper-parameter as a string"""
+    return str(value).lower() in ("1", "true", "yes")
 
-HF_CACHE = os.environ.get("HF_HOME", "/opt/ml/processing/hf-cache")
 
+def hf_token():
+    """Reads the Hugging Face token from Secrets Manager, if one is configured"""
+    secret = os.environ.get("HF_TOKEN_SECRET")
+    if not secret:
+        return os.environ.get("HF_TOKEN")
+    import boto3
 
-def load_training_data(data_dir: Path) -> Dataset:
-    """Loads jsonl files under *data_dir* into a 🤗 Dataset"""
+    return boto3.client("secretsmanager").get_secret_value(SecretId=secret)["SecretString"].strip()
+
+
+def latest_checkpoint(checkpoint_dir: Path):
+    """Returns the newest checkpoint-<step> directory, if a previous attempt
+    of this job left one"""
+    checkpoints = [
+        d for d in checkpoint_dir.glob("checkpoint-*")
+        if d.is_dir() and d.name.split("-")[-1].isdigit()
+    ]
+    if not checkpoints:
+        return None
+    return str(max(checkpoints, key=lambda d: int(d.name.split("-")[-1])))
+
+
+def load_training_data(data_dir: Path, tokenizer) -> Dataset:
+    """Loads jsonl files under *data_dir* into a 🤗 Dataset, formatted with the
+    model's chat template so the fine-tune matches how it is prompted later"""
     files = list(data_dir.glob("*.jsonl"))
     if not files:
         raise FileNotFoundError(f"No .jsonl files found in {data_dir}")
@@ -39,15 +93,30 @@ def load_training_data(data_dir: Path) -> Dataset:
     for fp in files:
         with open(fp) as f:
             for line in f:
+                if not line.strip():
+                    continue
                 obj = json.loads(line)
                 prompt = obj.get("prompt")
                 completion = obj.get("completion")
-                if prompt and completion:
-                    rows.append({"text": f"{prompt}\n{completion}"})
+                if not (prompt and completion):
+                    continue
+                if tokenizer.chat_template:
+                    text = tokenizer.apply_chat_template(
+                        [
+                            {"role": "user", "content": prompt},
+                            {"role": "assistant", "content": completion},
+                        ],
+                        tokenize=False,
+                    )
+                else:
+                    text = f"{prompt}\n{completion}{tokenizer.eos_token}"
+                rows.append({"text": text})
+    if not rows:
+        raise ValueError(f"No prompt/completion pairs found in {data_dir}")
     return Dataset.from_list(rows)
 
 
-def tokenize_dataset(dataset: Dataset, tokenizer, block_size: int = 1024):
+def tokenize_dataset(dataset: Dataset, tokenizer, block_size: int):
     def _tokenize(example):
         return tokenizer(example["text"], truncation=True, max_length=block_size)
 
@@ -56,50 +125,91 @@ def tokenize_dataset(dataset: Dataset, tokenizer, block_size: int = 1024):
 
 def main():
     parser = argparse.ArgumentParser()
-    parser.add_argument("--model_name_or_path", default="tiiuae/falcon-7b-instruct")
-    parser.add_argument("--num_train_epochs", type=int, default=1)
-    parser.add_argument("--learning_rate", type=float, default=2e-5)
-    parser.add_argument("--per_device_train_batch_size", type=int, default=2)
+    parser.add_argument("--model_id", default="meta-llama/Meta-Llama-3.1-8B-Instruct")
+    parser.add_argument("--num_train_epochs", type=int, default=3)
+    parser.add_argument("--learning_rate", type=float, default=2e-4)
+    parser.add_argument("--per_device_train_batch_size", type=int, default=1)
+    parser.add_argument("--gradient_accumulation_steps", type=int, default=8)
+    parser.add_argument("--warmup_ratio", type=float, default=0.03)
     parser.add_argument("--logging_steps", type=int, default=10)
-    parser.add_argument("--max_seq_length", type=int, default=1024)
-    args = parser.parse_args()
+    parser.add_argument("--max_seq_length", type=int, default=2048)
+    parser.add_argument("--lora_r", type=int, default=16)
+    parser.add_argument("--lora_alpha", type=int, default=32)
+    parser.add_argument("--lora_dropout", type=float, default=0.05)
+    parser.add_argument("--target_modules", default=DEFAULT_TARGET_MODULES)
+    parser.add_argument("--load_in_4bit", type=str2bool, default=True)
+    parser.add_argument("--merge_weights", type=str2bool, default=True)
+    parser.add_argument("--save_steps", type=int, default=50)
+    # SageMaker adds its own arguments (sagemaker_program, ...); ignore them
+    args, _ = parser.parse_known_args()
 
-    train_dir = Path(os.environ["SM_CHANNEL_TRAIN"])
+    train_dir = Path(os.environ.get("SM_CHANNEL_TRAIN") or os.environ["SM_CHANNEL_TRAINING"])
     model_dir = Path(os.environ["SM_MODEL_DIR"])
+    token = hf_token()
+    bf16 = torch.cuda.is_available() and torch.cuda.is_bf16_supported()
+    dtype = torch.bfloat16 if bf16 else torch.float16
 
-    tokenizer = AutoTokenizer.from_pretrained(args.model_name_or_path, cache_dir=HF_CACHE)
-    tokenizer.pad_token = tokenizer.eos_token
+    tokenizer = AutoTokenizer.from_pretrained(args.model_id, cache_dir=HF_CACHE, token=token)
+    if tokenizer.pad_token is None:
+        tokenizer.pad_token = tokenizer.eos_token
 
+    quantization = None
+    if args.load_in_4bit:
+        quantization = BitsAndBytesConfig(
+            load_in_4bit=True,
+            bnb_4bit_quant_type="nf4",
+            bnb_4bit_use_double_quant=True,
+            bnb_4bit_compute_dtype=dtype,
+        )
     base_model = AutoModelForCausalLM.from_pretrained(
-        args.model_name_or_path,
+        args.model_id,
         cache_dir=HF_CACHE,
-        torch_dtype=torch.float16 if torch.cuda.is_available() else torch.float32,
+        token=token,
+        torch_dtype=dtype,
+        quantization_config=quantization,
+        device_map="auto",
     )
+    if args.load_in_4bit:
+        base_model = prepare_model_for_kbit_training(base_model, use_gradient_checkpointing=True)
+    else:
+        base_model.gradient_checkpointing_enable()
 
     # LoRA config (lightweight fine-tune)
     lora_cfg = LoraConfig(
-        r=8,
-        lora_alpha=32,
-        target_modules=["q_proj", "v_proj"],
-        lora_dropout=0.05,
+        r=args.lora_r,
+        lora_alpha=args.lora_alpha,
+        target_modules=[m.strip() for m in args.target_modules.split(",") if m.strip()],
+        lora_dropout=args.lora_dropout,
         bias="none",
         task_type="CAUSAL_LM",
     )
 
     model = get_peft_model(base_model, lora_cfg)
+    model.print_trainable_parameters()
 
-    raw_ds = load_training_data(train_dir)
+    raw_ds = load_training_data(train_dir, tokenizer)
     tokenized_ds = tokenize_dataset(raw_ds, tokenizer, args.max_seq_length)
 
+    # Spot jobs get a checkpoint directory; keep the latest two there so an
+    # interruption while one is being written still leaves a complete one
+    spot = SM_CHECKPOINT_DIR.is_dir()
+    checkpoint_dir = SM_CHECKPOINT_DIR if spot else Path("/tmp/checkpoints")
     training_args = TrainingArguments(
-        output_dir=str(model_dir),
+        output_dir=str(checkpoint_dir),
         per_device_train_batch_size=args.per_device_train_batch_size,
+        gradient_accumulation_steps=args.gradient_accumulation_steps,
         learning_rate=args.learning_rate,
+        lr_scheduler_type="cosine",
+        warmup_ratio=args.warmup_ratio,
         num_train_epochs=args.num_train_epochs,
         logging_steps=args.logging_steps,
-        save_total_limit=1,
-        fp16=torch.cuda.is_available(),
-        optim="adamw_torch",
+        save_strategy="steps" if spot else "no",
+        save_steps=args.save_steps,
+        save_total_limit=2,
+        bf16=bf16,
+        fp16=torch.cuda.is_available() and not bf16,
+        gradient_checkpointing=True,
+        optim="paged_adamw_8bit" if args.load_in_4bit else "adamw_torch",
         report_to=["tensorboard"],
     )
 
@@ -107,19 +217,50 @@ def main():
         model=model,
         args=training_args,
         train_dataset=tokenized_ds,
-        tokenizer=tokenizer,
+        data_collator=DataCollatorForLanguageModeling(tokenizer, mlm=False),
     )
 
-    trainer.train()
+    resume_from = latest_checkpoint(checkpoint_dir) if spot else None
+    if resume_from:
+        print(f"Resuming from {resume_from}")
+    trainer.train(resume_from_checkpoint=resume_from)
 
-    # Save adapter config + base model ref
-    trainer.save_model()
+    if args.merge_weights:
+        # Merging needs the base weights unquantised, so reload them on CPU
+        adapter_dir = Path("/tmp/adapter")
+        trainer.model.save_pretrained(adapter_dir)
+        del model, base_model, trainer
+        torch.cuda.empty_cache()
+
+        from peft import AutoPeftModelForCausalLM
+
+        merged = AutoPeftModelForCausalLM.from_pretrained(
+            adapter_dir, cache_dir=HF_CACHE, torch_dtype=dtype, low_cpu_mem_usage=True, token=token
+        ).merge_and_unload()
+        merged.save_pretrained(model_dir, safe_serialization=True, max_shard_size="4GB")
+    else:
+        trainer.model.save_pretrained(model_dir / "adapter")
     tokenizer.save_pretrained(model_dir)
 
+    # Record what was trained, for the model registry and later deployments
+    with open(model_dir / "cloudai-training.json", "w") as f:
+        json.dump(
+            {
+                "base_model": args.model_id,
+                "merged": args.merge_weights,
+                "lora_r": args.lora_r,
+                "lora_alpha": args.lora_alpha,
+                "epochs": args.num_train_epochs,
+                "examples": len(raw_ds),
+            },
+            f,
+            indent=2,
+        )
+
     # Write success marker for SageMaker
     with open(model_dir / "complete", "w") as f:
         f.write("success")
 
 
 if __name__ == "__main__":
-    main()
\ No newline at end of file
+    main()
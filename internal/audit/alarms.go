@@ -0,0 +1,213 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Gaps a CoverageFinding can report
+const (
+	MissingAlarm     = "alarm"
+	MissingDashboard = "dashboard"
+)
+
+// CoverageFinding is a critical resource that no CloudWatch alarm watches or
+// no dashboard shows.
+type CoverageFinding struct {
+	Resource string   `json:"resource"`
+	Type     string   `json:"type"`
+	Name     string   `json:"name,omitempty"`
+	Kind     string   `json:"kind"`
+	Missing  []string `json:"missing"`
+	// Suggestion names the metrics an alarm on this kind of resource
+	// usually watches, when the alarm is missing
+	Suggestion string `json:"suggestion"`
+}
+
+// criticalKinds describes the resource types whose failures users notice
+// first, with the metrics worth alarming on.
+var criticalKinds = map[string]struct {
+	kind    string
+	metrics string
+}{
+	"AWS::ApiGateway::RestApi":     {"public API", "5XXError and Latency"},
+	"AWS::ApiGatewayV2::Api":       {"public API", "5xx and Latency"},
+	"AWS::Serverless::Api":         {"public API", "5XXError and Latency"},
+	"AWS::Serverless::HttpApi":     {"public API", "5xx and Latency"},
+	"AWS::SQS::Queue":              {"queue", "ApproximateAgeOfOldestMessage"},
+	"AWS::DynamoDB::Table":         {"database", "ThrottledRequests and SystemErrors"},
+	"AWS::RDS::DBInstance":         {"database", "CPUUtilization, FreeStorageSpace and DatabaseConnections"},
+	"AWS::RDS::DBCluster":          {"database", "CPUUtilization, FreeableMemory and DatabaseConnections"},
+	"AWS::Serverless::SimpleTable": {"database", "ThrottledRequests and SystemErrors"},
+}
+
+// FindUnmonitored lists the public APIs, queues and databases in the
+// infrastructure state that no AWS::CloudWatch::Alarm references or no
+// AWS::CloudWatch::Dashboard mentions. Dead-letter queues are told apart
+// from other queues: messages there are failures nobody sees without an
+// alarm. Findings without an alarm come first.
+func FindUnmonitored(state map[string]interface{}) []CoverageFinding {
+	resources, _ := state["Resources"].(map[string]interface{})
+
+	var alarms, dashboards [][]string
+	deadLetterTargets := make(map[string]bool)
+	for _, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		switch resource["Type"] {
+		case "AWS::CloudWatch::Alarm":
+			alarms = append(alarms, collectStrings(props, nil))
+		case "AWS::CloudWatch::Dashboard":
+			dashboards = append(dashboards, collectStrings(props, nil))
+		case "AWS::SQS::Queue":
+			// short-form Fn::GetAtt reads "DeadLetterQueue.Arn"
+			for _, target := range collectStrings(props["RedrivePolicy"], nil) {
+				deadLetterTargets[target] = true
+				deadLetterTargets[strings.Split(target, ".")[0]] = true
+			}
+		}
+	}
+
+	var findings []CoverageFinding
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		critical, ok := criticalKinds[resourceType]
+		if !ok {
+			continue
+		}
+		props, _ := resource["Properties"].(map[string]interface{})
+		if critical.kind == "public API" && isPrivateAPI(props) {
+			continue
+		}
+		names := monitoredNames(id, props)
+
+		finding := CoverageFinding{
+			Resource:   id,
+			Type:       resourceType,
+			Name:       names[len(names)-1],
+			Kind:       critical.kind,
+			Suggestion: fmt.Sprintf("alarm on %s", critical.metrics),
+		}
+		if finding.Name == id {
+			finding.Name = ""
+		}
+		if resourceType == "AWS::SQS::Queue" && isDeadLetterQueue(names, deadLetterTargets) {
+			finding.Kind = "dead-letter queue"
+			finding.Suggestion = "alarm on ApproximateNumberOfMessagesVisible > 0"
+		}
+		if !referencedByAny(alarms, id, names, false) {
+			finding.Missing = append(finding.Missing, MissingAlarm)
+		}
+		if !referencedByAny(dashboards, id, names, true) {
+			finding.Missing = append(finding.Missing, MissingDashboard)
+		}
+		if len(finding.Missing) == 1 && finding.Missing[0] == MissingDashboard {
+			finding.Suggestion = "add its metrics to a dashboard"
+		}
+		if len(finding.Missing) > 0 {
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		iAlarm, jAlarm := findings[i].Missing[0] == MissingAlarm, findings[j].Missing[0] == MissingAlarm
+		if iAlarm != jAlarm {
+			return iAlarm
+		}
+		return findings[i].Resource < findings[j].Resource
+	})
+	return findings
+}
+
+// monitoredNames returns the names a resource is referenced by: its logical
+// ID, its ARN and its physical name, in that order.
+func monitoredNames(id string, props map[string]interface{}) []string {
+	names := []string{id}
+	if arn := stringProp(props, "Arn"); arn != "" {
+		names = append(names, arn)
+	}
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, suffix := range []string{"Name", "Identifier"} {
+		for _, key := range keys {
+			if name := stringProp(props, key); name != "" && strings.HasSuffix(key, suffix) {
+				return append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// isPrivateAPI reports whether an API is only reachable from a VPC.
+func isPrivateAPI(props map[string]interface{}) bool {
+	config, _ := props["EndpointConfiguration"].(map[string]interface{})
+	for _, endpointType := range collectStrings(config["Types"], nil) {
+		if strings.EqualFold(endpointType, "PRIVATE") {
+			return true
+		}
+	}
+	return strings.EqualFold(stringProp(config, "Type"), "PRIVATE")
+}
+
+// isDeadLetterQueue reports whether another queue's redrive policy targets
+// the queue, or its name says it is one.
+func isDeadLetterQueue(names []string, targets map[string]bool) bool {
+	for _, name := range names {
+		if targets[name] {
+			return true
+		}
+		lower := strings.ToLower(name)
+		if strings.Contains(lower, "dlq") || strings.Contains(lower, "deadletter") || strings.Contains(lower, "dead-letter") {
+			return true
+		}
+	}
+	return false
+}
+
+// referencedByAny reports whether any of the string sets references the
+// resource: exactly by a name, through ${ID} in Fn::Sub or, for dashboard
+// bodies, by mentioning its physical name anywhere.
+func referencedByAny(sets [][]string, id string, names []string, mentions bool) bool {
+	for _, strs := range sets {
+		for _, s := range strs {
+			if strings.Contains(s, "${"+id+"}") || strings.Contains(s, "${"+id+".") {
+				return true
+			}
+			for i, name := range names {
+				if s == name {
+					return true
+				}
+				// logical IDs are too short and generic to match inside text
+				if mentions && i > 0 && len(name) >= 4 && strings.Contains(s, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// collectStrings appends every string in a property value, including Ref
+// and Fn::GetAtt targets, to strs.
+func collectStrings(value interface{}, strs []string) []string {
+	switch v := value.(type) {
+	case string:
+		strs = append(strs, v)
+	case []string:
+		strs = append(strs, v...)
+	case []interface{}:
+		for _, item := range v {
+			strs = collectStrings(item, strs)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			strs = collectStrings(item, strs)
+		}
+	}
+	return strs
+}
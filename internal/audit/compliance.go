@@ -0,0 +1,260 @@
+package audit
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Frameworks that controls are mapped to
+const (
+	FrameworkCIS  = "cis"
+	FrameworkFSBP = "fsbp"
+)
+
+// Statuses of a ControlResult
+const (
+	StatusPass          = "PASS"
+	StatusFail          = "FAIL"
+	StatusNotApplicable = "NOT_APPLICABLE"
+)
+
+// Control is a check of the scanned resources and the IDs of the framework
+// controls it evidences.
+type Control struct {
+	Check string
+	Title string
+	// IDs maps a framework to its control ID: "RDS.3" in the AWS
+	// Foundational Security Best Practices, "2.3.1" in the CIS AWS
+	// Foundations Benchmark v3.0
+	IDs       map[string]string
+	Types     []string
+	Compliant func(resource map[string]interface{}, ctx checkContext) bool
+}
+
+// checkContext holds what checks need beyond the resource itself.
+type checkContext struct {
+	id  string
+	eol map[string]bool
+	// backedUp holds the data stores an AWS Backup plan selects
+	backedUp map[string]bool
+}
+
+// ControlResult is the outcome of one control over the resources it applies
+// to.
+type ControlResult struct {
+	Framework string   `json:"framework"`
+	ControlID string   `json:"control_id"`
+	Check     string   `json:"check"`
+	Title     string   `json:"title"`
+	Status    string   `json:"status"`
+	Checked   int      `json:"checked"`
+	Failing   []string `json:"failing,omitempty"`
+}
+
+// Controls are the framework controls the scanned data can decide
+// deterministically. Account-level controls (CloudTrail, root user, password
+// policy) need more than a resource inventory and are not covered.
+var Controls = []Control{
+	{
+		Check: "lambda-supported-runtime",
+		Title: "Lambda functions should use supported runtimes",
+		IDs:   map[string]string{FrameworkFSBP: "Lambda.2"},
+		Types: []string{"AWS::Lambda::Function", "AWS::Serverless::Function"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			return !ctx.eol[ctx.id]
+		},
+	},
+	{
+		Check: "eks-supported-version",
+		Title: "EKS clusters should run on a supported Kubernetes version",
+		IDs:   map[string]string{FrameworkFSBP: "EKS.2"},
+		Types: []string{"AWS::EKS::Cluster"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			return !ctx.eol[ctx.id]
+		},
+	},
+	{
+		Check: "rds-encrypted",
+		Title: "RDS databases should be encrypted at rest",
+		IDs:   map[string]string{FrameworkFSBP: "RDS.3", FrameworkCIS: "2.3.1"},
+		Types: []string{"AWS::RDS::DBInstance", "AWS::RDS::DBCluster"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			props, _ := resource["Properties"].(map[string]interface{})
+			// Aurora members are encrypted by their cluster
+			if props["DBClusterIdentifier"] != nil {
+				return true
+			}
+			keys := collectStrings(props["KmsKeyId"], nil)
+			return isTrue(props["StorageEncrypted"]) || len(keys) > 0 && keys[0] != ""
+		},
+	},
+	{
+		Check: "rds-automatic-backups",
+		Title: "RDS databases should have automatic backups enabled",
+		IDs:   map[string]string{FrameworkFSBP: "RDS.11"},
+		Types: []string{"AWS::RDS::DBInstance", "AWS::RDS::DBCluster"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			props, _ := resource["Properties"].(map[string]interface{})
+			raw, ok := props["BackupRetentionPeriod"]
+			return !ok || intValue(raw) > 0
+		},
+	},
+	{
+		Check: "rds-backup-plan",
+		Title: "RDS databases should be protected by a backup plan",
+		IDs:   map[string]string{FrameworkFSBP: "RDS.26"},
+		Types: []string{"AWS::RDS::DBInstance", "AWS::RDS::DBCluster"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			return ctx.backedUp[ctx.id]
+		},
+	},
+	{
+		Check: "dynamodb-pitr",
+		Title: "DynamoDB tables should have point-in-time recovery enabled",
+		IDs:   map[string]string{FrameworkFSBP: "DynamoDB.2"},
+		Types: []string{"AWS::DynamoDB::Table"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			props, _ := resource["Properties"].(map[string]interface{})
+			spec, _ := props["PointInTimeRecoverySpecification"].(map[string]interface{})
+			return isTrue(spec["PointInTimeRecoveryEnabled"])
+		},
+	},
+	{
+		Check: "dynamodb-backup-plan",
+		Title: "DynamoDB tables should be protected by a backup plan",
+		IDs:   map[string]string{FrameworkFSBP: "DynamoDB.4"},
+		Types: []string{"AWS::DynamoDB::Table"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			return ctx.backedUp[ctx.id]
+		},
+	},
+	{
+		Check: "sqs-encrypted",
+		Title: "SQS queues should be encrypted at rest",
+		IDs:   map[string]string{FrameworkFSBP: "SQS.1"},
+		Types: []string{"AWS::SQS::Queue"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			props, _ := resource["Properties"].(map[string]interface{})
+			if props["KmsMasterKeyId"] != nil {
+				return true
+			}
+			// SSE-SQS is on by default for queues created since 2022
+			managed, set := props["SqsManagedSseEnabled"]
+			return !set || isTrue(managed)
+		},
+	},
+	{
+		Check: "efs-encrypted",
+		Title: "EFS file systems should be encrypted at rest",
+		IDs:   map[string]string{FrameworkFSBP: "EFS.1", FrameworkCIS: "2.4.1"},
+		Types: []string{"AWS::EFS::FileSystem"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			props, _ := resource["Properties"].(map[string]interface{})
+			return isTrue(props["Encrypted"])
+		},
+	},
+	{
+		Check: "ebs-encrypted",
+		Title: "EBS volumes should be encrypted at rest",
+		IDs:   map[string]string{FrameworkFSBP: "EC2.3", FrameworkCIS: "2.2.1"},
+		Types: []string{"AWS::EC2::Volume"},
+		Compliant: func(resource map[string]interface{}, ctx checkContext) bool {
+			props, _ := resource["Properties"].(map[string]interface{})
+			return isTrue(props["Encrypted"])
+		},
+	},
+}
+
+// Frameworks lists the frameworks controls are mapped to.
+func Frameworks() []string {
+	return []string{FrameworkCIS, FrameworkFSBP}
+}
+
+// EvaluateFramework runs every control mapped to framework over the
+// infrastructure state, sorted by control ID. Resources whose properties
+// were not scanned in detail, like those listed only by tag, are skipped.
+func EvaluateFramework(state map[string]interface{}, framework string, now time.Time) ([]ControlResult, error) {
+	framework = strings.ToLower(framework)
+	known := false
+	for _, name := range Frameworks() {
+		known = known || name == framework
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown framework %q: use %s", framework, strings.Join(Frameworks(), " or "))
+	}
+
+	resources, _ := state["Resources"].(map[string]interface{})
+	ctx := checkContext{eol: make(map[string]bool), backedUp: make(map[string]bool)}
+	for _, finding := range FindEOL(state, now, 0) {
+		if finding.Status == StatusDeprecated {
+			ctx.eol[finding.Resource] = true
+		}
+	}
+	// backup plans protect a store wherever it lives, so every region counts
+	for _, finding := range AssessDR(state, "").Findings {
+		for _, backup := range finding.Backups {
+			if strings.HasPrefix(backup, "AWS Backup plan") {
+				ctx.backedUp[finding.Resource] = true
+			}
+		}
+	}
+
+	var results []ControlResult
+	for _, control := range Controls {
+		controlID, ok := control.IDs[framework]
+		if !ok {
+			continue
+		}
+		result := ControlResult{
+			Framework: framework,
+			ControlID: controlID,
+			Check:     control.Check,
+			Title:     control.Title,
+		}
+		for id, raw := range resources {
+			resource, _ := raw.(map[string]interface{})
+			resourceType, _ := resource["Type"].(string)
+			if resource["Shallow"] == true || !slices.Contains(control.Types, resourceType) {
+				continue
+			}
+			ctx.id = id
+			result.Checked++
+			if !control.Compliant(resource, ctx) {
+				result.Failing = append(result.Failing, id)
+			}
+		}
+		sort.Strings(result.Failing)
+		switch {
+		case result.Checked == 0:
+			result.Status = StatusNotApplicable
+		case len(result.Failing) > 0:
+			result.Status = StatusFail
+		default:
+			result.Status = StatusPass
+		}
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return controlLess(results[i].ControlID, results[j].ControlID) })
+	return results, nil
+}
+
+// controlLess orders control IDs by their numeric parts, so "2.10" follows
+// "2.9" and "RDS.26" follows "RDS.3".
+func controlLess(a, b string) bool {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+		aNum, bNum := intValue(aParts[i]), intValue(bParts[i])
+		if aNum != bNum && aNum > 0 && bNum > 0 {
+			return aNum < bNum
+		}
+		return aParts[i] < bParts[i]
+	}
+	return len(aParts) < len(bParts)
+}
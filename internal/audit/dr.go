@@ -0,0 +1,473 @@
+package audit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// What a DRFinding's resource loses when its region goes down
+const (
+	// LossNone: a live replica in another region keeps serving the data
+	LossNone = "none"
+	// LossSinceCopy: backups are copied to another region, so only the
+	// writes since the last copy are lost
+	LossSinceCopy = "since-last-copy"
+	// LossAll: no copy leaves the region
+	LossAll = "all"
+)
+
+// DRFinding is the backup and disaster recovery posture of a data store.
+type DRFinding struct {
+	Resource string `json:"resource"`
+	Type     string `json:"type"`
+	Name     string `json:"name,omitempty"`
+	Region   string `json:"region,omitempty"`
+	// Backups are the protections within the region: backup plans,
+	// point-in-time recovery, automated snapshots
+	Backups []string `json:"backups"`
+	// CrossRegion names the copies and replicas outside the region
+	CrossRegion []string `json:"cross_region"`
+	Loss        string   `json:"loss"`
+}
+
+// DRReport is what a region outage would cost, data store by data store.
+type DRReport struct {
+	Region   string      `json:"region"`
+	Findings []DRFinding `json:"findings"`
+	// counts by Loss
+	Safe         int `json:"safe"`
+	SinceCopy    int `json:"since_last_copy"`
+	Lost         int `json:"lost"`
+	Unprotected  int `json:"unprotected"`
+	OtherRegions int `json:"other_regions"`
+}
+
+// dataStoreTypes hold data that a region outage can take with it.
+var dataStoreTypes = map[string]bool{
+	"AWS::S3::Bucket":              true,
+	"AWS::DynamoDB::Table":         true,
+	"AWS::DynamoDB::GlobalTable":   true,
+	"AWS::Serverless::SimpleTable": true,
+	"AWS::RDS::DBInstance":         true,
+	"AWS::RDS::DBCluster":          true,
+	"AWS::EFS::FileSystem":         true,
+	"AWS::EC2::Volume":             true,
+}
+
+// backupRule is a rule of an AWS Backup plan, with the regions its copy
+// actions send recovery points to.
+type backupRule struct {
+	plan        string
+	name        string
+	copyRegions []string
+	continuous  bool
+}
+
+// AssessDR answers "what would we lose if region went down?" for every data
+// store in the infrastructure state. It reads AWS Backup plans and
+// selections, DLM snapshot policies, point-in-time recovery, RDS automated
+// backups and read replicas, DynamoDB global table replicas and S3 and EFS
+// replication. Resources whose region cannot be told, as in templates, are
+// taken to live in region; an empty region assesses every region.
+// Unprotected and lost stores come first.
+func AssessDR(state map[string]interface{}, region string) DRReport {
+	resources, _ := state["Resources"].(map[string]interface{})
+	report := DRReport{Region: region, Findings: []DRFinding{}}
+
+	plans := make(map[string][]backupRule)
+	var selections, lifecyclePolicies, globalClusters []map[string]interface{}
+	bucketRegions := make(map[string]string)
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		switch resource["Type"] {
+		case "AWS::Backup::BackupPlan":
+			rules := backupRules(id, props)
+			plans[id] = rules
+			if planID := stringProp(props, "BackupPlanId"); planID != "" {
+				plans[planID] = rules
+			}
+		case "AWS::Backup::BackupSelection":
+			selections = append(selections, props)
+		case "AWS::DLM::LifecyclePolicy":
+			lifecyclePolicies = append(lifecyclePolicies, props)
+		case "AWS::RDS::GlobalCluster":
+			globalClusters = append(globalClusters, props)
+		case "AWS::S3::Bucket":
+			if bucketRegion := stringProp(props, "Region"); bucketRegion != "" {
+				bucketRegions[stringProp(props, "BucketName")] = bucketRegion
+			}
+		}
+	}
+
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		if !dataStoreTypes[resourceType] {
+			continue
+		}
+		props, _ := resource["Properties"].(map[string]interface{})
+		names := monitoredNames(id, props)
+		finding := DRFinding{
+			Resource:    id,
+			Type:        resourceType,
+			Name:        names[len(names)-1],
+			Region:      resourceRegion(props),
+			Backups:     []string{},
+			CrossRegion: []string{},
+		}
+		if finding.Name == id {
+			finding.Name = ""
+		}
+		if region != "" && finding.Region != "" && finding.Region != region {
+			report.OtherRegions++
+			continue
+		}
+
+		for _, selection := range selections {
+			if !selects(selection, id, names, props) {
+				continue
+			}
+			planRef := collectStrings(selection["BackupPlanId"], nil)
+			for _, ref := range planRef {
+				for _, rule := range plans[ref] {
+					label := fmt.Sprintf("AWS Backup plan %s, rule %s", rule.plan, rule.name)
+					if rule.continuous {
+						label += " (continuous)"
+					}
+					finding.Backups = append(finding.Backups, label)
+					for _, copyRegion := range rule.copyRegions {
+						if copyRegion != region {
+							finding.CrossRegion = append(finding.CrossRegion, fmt.Sprintf("backup copies in %s (plan %s)", copyRegion, rule.plan))
+						}
+					}
+				}
+			}
+		}
+		for _, policy := range lifecyclePolicies {
+			backups, copies := lifecyclePolicyCoverage(policy, resourceType, props, region)
+			finding.Backups = append(finding.Backups, backups...)
+			finding.CrossRegion = append(finding.CrossRegion, copies...)
+		}
+		finding.Backups = append(finding.Backups, nativeBackups(resourceType, props)...)
+		finding.CrossRegion = append(finding.CrossRegion, replicas(resourceType, props, names, region, bucketRegions, globalClusters)...)
+
+		switch {
+		case hasReplica(finding.CrossRegion):
+			finding.Loss = LossNone
+			report.Safe++
+		case len(finding.CrossRegion) > 0:
+			finding.Loss = LossSinceCopy
+			report.SinceCopy++
+		default:
+			finding.Loss = LossAll
+			report.Lost++
+			if len(finding.Backups) == 0 {
+				report.Unprotected++
+			}
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+
+	rank := map[string]int{LossAll: 0, LossSinceCopy: 1, LossNone: 2}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if rank[a.Loss] != rank[b.Loss] {
+			return rank[a.Loss] < rank[b.Loss]
+		}
+		if (len(a.Backups) == 0) != (len(b.Backups) == 0) {
+			return len(a.Backups) == 0
+		}
+		return a.Resource < b.Resource
+	})
+	return report
+}
+
+// backupRules reads the rules of a backup plan and where they copy to.
+func backupRules(id string, props map[string]interface{}) []backupRule {
+	plan, _ := props["BackupPlan"].(map[string]interface{})
+	name := stringProp(plan, "BackupPlanName")
+	if name == "" {
+		name = id
+	}
+	rawRules, _ := plan["BackupPlanRule"].([]interface{})
+	var rules []backupRule
+	for _, raw := range rawRules {
+		rule, _ := raw.(map[string]interface{})
+		parsed := backupRule{plan: name, name: stringProp(rule, "RuleName"), continuous: isTrue(rule["EnableContinuousBackup"])}
+		copies, _ := rule["CopyActions"].([]interface{})
+		for _, rawCopy := range copies {
+			copyAction, _ := rawCopy.(map[string]interface{})
+			for _, s := range collectStrings(copyAction["DestinationBackupVaultArn"], nil) {
+				if copyRegion := arnRegion(s); copyRegion != "" {
+					parsed.copyRegions = append(parsed.copyRegions, copyRegion)
+				}
+			}
+		}
+		rules = append(rules, parsed)
+	}
+	return rules
+}
+
+// selects reports whether a backup selection covers a resource, by ARN
+// pattern, template reference or tag condition.
+func selects(selection map[string]interface{}, id string, names []string, props map[string]interface{}) bool {
+	body, _ := selection["BackupSelection"].(map[string]interface{})
+	for _, pattern := range collectStrings(body["NotResources"], nil) {
+		if matchesPattern(pattern, id, names) {
+			return false
+		}
+	}
+	for _, pattern := range collectStrings(body["Resources"], nil) {
+		if matchesPattern(pattern, id, names) {
+			return true
+		}
+	}
+	tags := resourceTags(props)
+	conditions, _ := body["ListOfTags"].([]interface{})
+	for _, raw := range conditions {
+		condition, _ := raw.(map[string]interface{})
+		if value, ok := tags[stringProp(condition, "ConditionKey")]; ok && value == stringProp(condition, "ConditionValue") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPattern matches an ARN pattern with * wildcards against a
+// resource's ARN, or a template reference against its logical ID.
+func matchesPattern(pattern, id string, names []string) bool {
+	if pattern == id || strings.Contains(pattern, "${"+id+"}") || strings.Contains(pattern, "${"+id+".") {
+		return true
+	}
+	if !strings.Contains(pattern, "*") {
+		return slices.Contains(names, pattern)
+	}
+	re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+	if err != nil {
+		return false
+	}
+	for _, name := range names[1:] {
+		if strings.HasPrefix(name, "arn:") && re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lifecyclePolicyCoverage returns what a DLM policy provides a volume it
+// targets by tag: snapshots, and copies to other regions.
+func lifecyclePolicyCoverage(policy map[string]interface{}, resourceType string, props map[string]interface{}, region string) ([]string, []string) {
+	if resourceType != "AWS::EC2::Volume" {
+		return nil, nil
+	}
+	details, _ := policy["PolicyDetails"].(map[string]interface{})
+	tags := resourceTags(props)
+	targeted := false
+	targetTags, _ := details["TargetTags"].([]interface{})
+	for _, raw := range targetTags {
+		tag, _ := raw.(map[string]interface{})
+		if value, ok := tags[stringProp(tag, "Key")]; ok && value == stringProp(tag, "Value") {
+			targeted = true
+		}
+	}
+	if !targeted {
+		return nil, nil
+	}
+
+	name := stringProp(policy, "Description")
+	backups := []string{"DLM snapshot policy " + name}
+	var copies []string
+	schedules, _ := details["Schedules"].([]interface{})
+	for _, raw := range schedules {
+		schedule, _ := raw.(map[string]interface{})
+		rules, _ := schedule["CrossRegionCopyRules"].([]interface{})
+		for _, rawRule := range rules {
+			rule, _ := rawRule.(map[string]interface{})
+			if target := stringProp(rule, "TargetRegion"); target != "" && target != region {
+				copies = append(copies, fmt.Sprintf("snapshot copies in %s (DLM policy %s)", target, name))
+			}
+		}
+	}
+	return backups, copies
+}
+
+// nativeBackups returns the backups a data store takes on its own.
+func nativeBackups(resourceType string, props map[string]interface{}) []string {
+	var backups []string
+	switch resourceType {
+	case "AWS::DynamoDB::Table", "AWS::DynamoDB::GlobalTable":
+		spec, _ := props["PointInTimeRecoverySpecification"].(map[string]interface{})
+		if isTrue(spec["PointInTimeRecoveryEnabled"]) {
+			backups = append(backups, "point-in-time recovery")
+		}
+	case "AWS::RDS::DBInstance", "AWS::RDS::DBCluster":
+		// CloudFormation keeps one day of automated backups by default
+		days := 1
+		if raw, ok := props["BackupRetentionPeriod"]; ok {
+			days = intValue(raw)
+		}
+		if days > 0 {
+			backups = append(backups, fmt.Sprintf("automated backups, %d day(s)", days))
+		}
+	case "AWS::EFS::FileSystem":
+		policy, _ := props["BackupPolicy"].(map[string]interface{})
+		if strings.EqualFold(stringProp(policy, "Status"), "ENABLED") {
+			backups = append(backups, "EFS automatic backups")
+		}
+	case "AWS::S3::Bucket":
+		versioning, _ := props["VersioningConfiguration"].(map[string]interface{})
+		if strings.EqualFold(stringProp(versioning, "Status"), "Enabled") {
+			backups = append(backups, "versioning")
+		}
+	}
+	return backups
+}
+
+// replicas returns the live copies of a data store outside region.
+// Descriptions of live replicas start with "replica" so hasReplica can tell
+// them from backup copies.
+func replicas(resourceType string, props map[string]interface{}, names []string, region string, bucketRegions map[string]string, globalClusters []map[string]interface{}) []string {
+	var out []string
+	switch resourceType {
+	case "AWS::DynamoDB::Table", "AWS::DynamoDB::GlobalTable":
+		list, _ := props["Replicas"].([]interface{})
+		for _, raw := range list {
+			replica, _ := raw.(map[string]interface{})
+			if replicaRegion := stringProp(replica, "Region"); replicaRegion != "" && replicaRegion != region {
+				out = append(out, "replica in "+replicaRegion+" (global table)")
+			}
+		}
+	case "AWS::RDS::DBInstance", "AWS::RDS::DBCluster":
+		for _, key := range []string{"ReadReplicaDBInstanceIdentifiers", "ReadReplicaIdentifiers"} {
+			for _, replica := range collectStrings(props[key], nil) {
+				if replicaRegion := arnRegion(replica); replicaRegion != "" && replicaRegion != region {
+					out = append(out, "replica in "+replicaRegion+" (read replica)")
+				}
+			}
+		}
+		if global := stringProp(props, "GlobalClusterIdentifier"); global != "" {
+			out = append(out, "replica in another region (global database "+global+")")
+		}
+		for _, globalCluster := range globalClusters {
+			for _, source := range collectStrings(globalCluster["SourceDBClusterIdentifier"], nil) {
+				if slices.Contains(names, source) {
+					out = append(out, "replica in another region (global database "+stringProp(globalCluster, "GlobalClusterIdentifier")+")")
+				}
+			}
+		}
+	case "AWS::S3::Bucket":
+		replication, _ := props["ReplicationConfiguration"].(map[string]interface{})
+		rules, _ := replication["Rules"].([]interface{})
+		for _, raw := range rules {
+			rule, _ := raw.(map[string]interface{})
+			if strings.EqualFold(stringProp(rule, "Status"), "Disabled") {
+				continue
+			}
+			destination, _ := rule["Destination"].(map[string]interface{})
+			for _, bucket := range collectStrings(destination["Bucket"], nil) {
+				bucketName := bucket[strings.LastIndex(bucket, ":")+1:]
+				destinationRegion := bucketRegions[bucketName]
+				switch {
+				case destinationRegion == region:
+					continue
+				case destinationRegion == "":
+					out = append(out, "replica in bucket "+bucketName+" (region not scanned)")
+				default:
+					out = append(out, "replica in "+destinationRegion+" (bucket "+bucketName+")")
+				}
+			}
+		}
+	case "AWS::EFS::FileSystem":
+		replication, _ := props["ReplicationConfiguration"].(map[string]interface{})
+		destinations, _ := replication["Destinations"].([]interface{})
+		for _, raw := range destinations {
+			destination, _ := raw.(map[string]interface{})
+			if destinationRegion := stringProp(destination, "Region"); destinationRegion != "" && destinationRegion != region {
+				out = append(out, "replica in "+destinationRegion+" (EFS replication)")
+			}
+		}
+	}
+	return out
+}
+
+func hasReplica(crossRegion []string) bool {
+	for _, copyLabel := range crossRegion {
+		if strings.HasPrefix(copyLabel, "replica") {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceRegion returns the region of a scanned resource, from its Region
+// property or ARN, or "" for template resources.
+func resourceRegion(props map[string]interface{}) string {
+	if region := stringProp(props, "Region"); region != "" {
+		return region
+	}
+	return arnRegion(stringProp(props, "Arn"))
+}
+
+// arnRegion returns the region field of an ARN, including ARNs built with
+// Fn::Sub.
+func arnRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" || strings.HasPrefix(parts[3], "${") {
+		return ""
+	}
+	return parts[3]
+}
+
+// resourceTags reads tags as a map from a template's Key/Value list or a
+// live scan's map.
+func resourceTags(props map[string]interface{}) map[string]string {
+	tags := make(map[string]string)
+	switch raw := props["Tags"].(type) {
+	case map[string]interface{}:
+		for key, value := range raw {
+			if s, ok := value.(string); ok {
+				tags[key] = s
+			}
+		}
+	case []interface{}:
+		for _, item := range raw {
+			tag, _ := item.(map[string]interface{})
+			tags[stringProp(tag, "Key")] = stringProp(tag, "Value")
+		}
+	}
+	return tags
+}
+
+// isTrue accepts the booleans of JSON templates and the strings of YAML
+// ones.
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+func intValue(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
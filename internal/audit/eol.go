@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EOLEntry is the end of standard support for one runtime or engine version.
+// The tables below follow the AWS deprecation schedules; extend them as AWS
+// announces new versions and dates.
+type EOLEntry struct {
+	Date    string // YYYY-MM-DD
+	Upgrade string // suggested target version
+}
+
+// lambdaRuntimeEOL lists Lambda runtime deprecation dates. After this date
+// AWS stops applying security patches; function updates are blocked later.
+var lambdaRuntimeEOL = map[string]EOLEntry{
+	"python2.7":     {"2021-07-15", "python3.13"},
+	"python3.6":     {"2022-07-18", "python3.13"},
+	"python3.7":     {"2023-12-04", "python3.13"},
+	"python3.8":     {"2024-10-14", "python3.13"},
+	"python3.9":     {"2025-12-15", "python3.13"},
+	"python3.10":    {"2026-06-30", "python3.13"},
+	"python3.11":    {"2026-06-30", "python3.13"},
+	"python3.12":    {"2028-10-31", "python3.13"},
+	"python3.13":    {"2029-06-30", ""},
+	"nodejs10.x":    {"2021-07-30", "nodejs22.x"},
+	"nodejs12.x":    {"2023-03-31", "nodejs22.x"},
+	"nodejs14.x":    {"2023-12-04", "nodejs22.x"},
+	"nodejs16.x":    {"2024-06-12", "nodejs22.x"},
+	"nodejs18.x":    {"2025-09-01", "nodejs22.x"},
+	"nodejs20.x":    {"2026-04-30", "nodejs22.x"},
+	"nodejs22.x":    {"2027-04-30", ""},
+	"java8":         {"2024-01-08", "java21"},
+	"java8.al2":     {"2026-06-30", "java21"},
+	"java11":        {"2026-06-30", "java21"},
+	"java17":        {"2026-06-30", "java21"},
+	"java21":        {"2029-06-30", ""},
+	"dotnetcore3.1": {"2023-04-03", "dotnet8"},
+	"dotnet6":       {"2024-12-20", "dotnet8"},
+	"dotnet8":       {"2026-11-10", ""},
+	"ruby2.7":       {"2023-12-07", "ruby3.3"},
+	"ruby3.2":       {"2026-03-31", "ruby3.3"},
+	"ruby3.3":       {"2027-03-31", ""},
+	"go1.x":         {"2024-01-08", "provided.al2023"},
+	"provided":      {"2024-01-08", "provided.al2023"},
+	"provided.al2":  {"2026-06-30", "provided.al2023"},
+}
+
+// rdsEngineEOL lists the end of RDS standard support per engine major
+// version, keyed by "<engine> <major>".
+var rdsEngineEOL = map[string]EOLEntry{
+	"mysql 5.7":            {"2024-02-29", "8.4"},
+	"mysql 8.0":            {"2026-07-31", "8.4"},
+	"mysql 8.4":            {"2029-07-31", ""},
+	"mariadb 10.4":         {"2024-06-18", "10.11"},
+	"mariadb 10.5":         {"2025-02-15", "10.11"},
+	"mariadb 10.6":         {"2026-08-31", "10.11"},
+	"mariadb 10.11":        {"2028-02-16", ""},
+	"postgres 11":          {"2024-02-29", "16"},
+	"postgres 12":          {"2025-02-28", "16"},
+	"postgres 13":          {"2026-02-28", "16"},
+	"postgres 14":          {"2027-02-28", "16"},
+	"postgres 15":          {"2028-02-29", "16"},
+	"postgres 16":          {"2029-02-28", ""},
+	"aurora-mysql 5.7":     {"2024-10-31", "3 (MySQL 8.0 compatible)"},
+	"aurora-postgresql 11": {"2024-02-29", "16"},
+	"aurora-postgresql 12": {"2025-02-28", "16"},
+	"aurora-postgresql 13": {"2026-02-28", "16"},
+	"aurora-postgresql 14": {"2027-02-28", "16"},
+	"aurora-postgresql 15": {"2028-02-29", "16"},
+	"aurora-postgresql 16": {"2029-02-28", ""},
+}
+
+// eksVersionEOL lists the end of EKS standard support per Kubernetes version.
+// Upgrades must go one minor version at a time.
+var eksVersionEOL = map[string]EOLEntry{
+	"1.23": {"2023-10-11", "1.24"},
+	"1.24": {"2024-01-31", "1.25"},
+	"1.25": {"2024-05-01", "1.26"},
+	"1.26": {"2024-06-11", "1.27"},
+	"1.27": {"2024-07-24", "1.28"},
+	"1.28": {"2024-11-26", "1.29"},
+	"1.29": {"2025-03-23", "1.30"},
+	"1.30": {"2025-07-23", "1.31"},
+	"1.31": {"2025-11-26", "1.32"},
+	"1.32": {"2026-03-23", "1.33"},
+	"1.33": {"2026-07-29", ""},
+}
+
+// EOL finding statuses
+const (
+	StatusDeprecated = "deprecated"
+	StatusEOLSoon    = "eol-soon"
+)
+
+// EOLFinding is a resource on a deprecated or soon-EOL version
+type EOLFinding struct {
+	Resource   string `json:"resource"`
+	Type       string `json:"type"`
+	Version    string `json:"version"`
+	EOLDate    string `json:"eol_date"`
+	Status     string `json:"status"`
+	Suggestion string `json:"suggestion"`
+}
+
+// FindEOL checks every Lambda function, RDS instance/cluster and EKS cluster
+// in the infrastructure state against the EOL tables. Resources whose version
+// is past its EOL date, or reaches it within the given window, are returned
+// sorted by EOL date.
+func FindEOL(state map[string]interface{}, now time.Time, within time.Duration) []EOLFinding {
+	resources, _ := state["Resources"].(map[string]interface{})
+
+	var findings []EOLFinding
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+
+		var version string
+		var entry EOLEntry
+		var known bool
+		switch resourceType {
+		case "AWS::Lambda::Function", "AWS::Serverless::Function":
+			version = stringProp(props, "Runtime")
+			entry, known = lambdaRuntimeEOL[version]
+		case "AWS::RDS::DBInstance", "AWS::RDS::DBCluster":
+			engine := stringProp(props, "Engine")
+			version = stringProp(props, "EngineVersion")
+			entry, known = rdsEngineEOL[engine+" "+majorVersion(engine, version)]
+			version = strings.TrimSpace(engine + " " + version)
+		case "AWS::EKS::Cluster":
+			version = stringProp(props, "Version")
+			entry, known = eksVersionEOL[version]
+		}
+		if !known {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", entry.Date)
+		if err != nil {
+			continue
+		}
+		status := ""
+		switch {
+		case !now.Before(date):
+			status = StatusDeprecated
+		case date.Sub(now) <= within:
+			status = StatusEOLSoon
+		default:
+			continue
+		}
+
+		findings = append(findings, EOLFinding{
+			Resource:   id,
+			Type:       resourceType,
+			Version:    version,
+			EOLDate:    entry.Date,
+			Status:     status,
+			Suggestion: suggestion(resourceType, entry, status),
+		})
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].EOLDate != findings[j].EOLDate {
+			return findings[i].EOLDate < findings[j].EOLDate
+		}
+		return findings[i].Resource < findings[j].Resource
+	})
+	return findings
+}
+
+// majorVersion reduces an engine version to the major version the EOL table
+// is keyed by: "13.7" -> "13" for PostgreSQL, "8.0.35" -> "8.0" for MySQL and
+// "5.7.mysql_aurora.2.11.2" -> "5.7" for Aurora MySQL.
+func majorVersion(engine, version string) string {
+	parts := strings.Split(version, ".")
+	if strings.Contains(engine, "postgres") || len(parts) < 2 {
+		return parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+func suggestion(resourceType string, entry EOLEntry, status string) string {
+	if entry.Upgrade == "" {
+		if status == StatusDeprecated {
+			return "upgrade to a currently supported version"
+		}
+		return "plan an upgrade before the EOL date"
+	}
+	switch resourceType {
+	case "AWS::EKS::Cluster":
+		return fmt.Sprintf("upgrade to %s (one minor version at a time)", entry.Upgrade)
+	case "AWS::RDS::DBInstance", "AWS::RDS::DBCluster":
+		return fmt.Sprintf("upgrade the engine to %s", entry.Upgrade)
+	default:
+		return fmt.Sprintf("move to the %s runtime", entry.Upgrade)
+	}
+}
+
+func stringProp(props map[string]interface{}, key string) string {
+	s, _ := props[key].(string)
+	return s
+}
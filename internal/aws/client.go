@@ -2,33 +2,212 @@ package aws
 
 import (
 	"context"
+	"time"
 
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/backup"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sagemaker"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 // Client wraps AWS service clients
 type Client struct {
-	APIGateway   *apigateway.Client
-	Lambda       *lambda.Client
-	S3           *s3.Client
-	CostExplorer *costexplorer.Client
+	APIGateway    *apigateway.Client
+	Lambda        *lambda.Client
+	S3            *s3.Client
+	CostExplorer  *costexplorer.Client
+	Organizations *organizations.Client
+	STS           *sts.Client
+	IAM           *iam.Client
+	KMS           *kms.Client
+	Route53       *route53.Client
+	CloudFront    *cloudfront.Client
+	ELBv2         *elasticloadbalancingv2.Client
+	RDS           *rds.Client
+	EKS           *eks.Client
+	Config        *configservice.Client
+	Tagging       *resourcegroupstaggingapi.Client
+	CloudWatch    *cloudwatch.Client
+	CloudTrail    *cloudtrail.Client
+	Backup        *backup.Client
+	DynamoDB      *dynamodb.Client
+	EC2           *ec2.Client
+	Logs          *cloudwatchlogs.Client
+	SageMaker     *sagemaker.Client
+	Athena        *athena.Client
+	GuardDuty     *guardduty.Client
+	SecurityHub   *securityhub.Client
+	ServiceQuotas *servicequotas.Client
+	SNS           *sns.Client
+	SES           *sesv2.Client
+	ElastiCache   *elasticache.Client
+	Kinesis       *kinesis.Client
+	Kafka         *kafka.Client
 }
 
-// NewClient creates a new AWS client with all required services
-func NewClient(ctx context.Context) (*Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+// Option configures NewClient
+type Option func(*options)
+
+type options struct {
+	allowWrites bool
+	profile     string
+	region      string
+	endpoint    string
+}
+
+// WithWrites disables the read-only guard. Only commands whose purpose is to
+// create resources, such as 'cloudai train bootstrap-role', use it; it
+// exists so the guard is an explicit opt-out rather than implicit.
+func WithWrites() Option {
+	return func(o *options) { o.allowWrites = true }
+}
+
+// WithProfile loads credentials and settings from a named shared config
+// profile instead of the default chain's choice.
+func WithProfile(profile string) Option {
+	return func(o *options) { o.profile = profile }
+}
+
+// WithRegion overrides the region from the environment and profile.
+func WithRegion(region string) Option {
+	return func(o *options) { o.region = region }
+}
+
+// WithEndpoint sends every request to url instead of the AWS endpoints, for
+// LocalStack or moto. S3 uses path-style addressing, and without credentials
+// in the environment the emulators' test credentials are used.
+func WithEndpoint(url string) Option {
+	return func(o *options) { o.endpoint = url }
+}
+
+// LoadConfig loads the SDK configuration with the profile, region and
+// endpoint options, for SDK clients this package does not create, such as
+// Bedrock's. WithWrites does not apply to them.
+func LoadConfig(ctx context.Context, opts ...Option) (awssdk.Config, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var loadOptions []func(*config.LoadOptions) error
+	if o.profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(o.profile))
+	}
+	if o.region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(o.region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return cfg, err
+	}
+	if o.endpoint != "" {
+		useEndpoint(ctx, &cfg, o.endpoint)
+	}
+	return cfg, nil
+}
+
+// NewClient creates a new AWS client with all required services. Clients are
+// read-only: any operation that is not a Get/List/Describe-style call fails
+// with a MutationBlockedError before it is sent, unless WithWrites is given.
+func NewClient(ctx context.Context, opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg, err := LoadConfig(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
+	var s3Options []func(*s3.Options)
+	if o.endpoint != "" {
+		s3Options = append(s3Options, func(so *s3.Options) { so.UsePathStyle = true })
+	}
+	cfg.APIOptions = append(cfg.APIOptions, addPermissionErrors)
+	if !o.allowWrites {
+		cfg.APIOptions = append(cfg.APIOptions, addReadOnlyGuard)
+	}
 
 	return &Client{
-		APIGateway:   apigateway.NewFromConfig(cfg),
-		Lambda:       lambda.NewFromConfig(cfg),
-		S3:           s3.NewFromConfig(cfg),
-		CostExplorer: costexplorer.NewFromConfig(cfg),
+		APIGateway:    apigateway.NewFromConfig(cfg),
+		Lambda:        lambda.NewFromConfig(cfg),
+		S3:            s3.NewFromConfig(cfg, s3Options...),
+		CostExplorer:  costexplorer.NewFromConfig(cfg),
+		Organizations: organizations.NewFromConfig(cfg),
+		STS:           sts.NewFromConfig(cfg),
+		IAM:           iam.NewFromConfig(cfg),
+		KMS:           kms.NewFromConfig(cfg),
+		Route53:       route53.NewFromConfig(cfg),
+		CloudFront:    cloudfront.NewFromConfig(cfg),
+		ELBv2:         elasticloadbalancingv2.NewFromConfig(cfg),
+		RDS:           rds.NewFromConfig(cfg),
+		EKS:           eks.NewFromConfig(cfg),
+		Config:        configservice.NewFromConfig(cfg),
+		Tagging:       resourcegroupstaggingapi.NewFromConfig(cfg),
+		CloudWatch:    cloudwatch.NewFromConfig(cfg),
+		CloudTrail:    cloudtrail.NewFromConfig(cfg),
+		Backup:        backup.NewFromConfig(cfg),
+		DynamoDB:      dynamodb.NewFromConfig(cfg),
+		EC2:           ec2.NewFromConfig(cfg),
+		Logs:          cloudwatchlogs.NewFromConfig(cfg),
+		SageMaker:     sagemaker.NewFromConfig(cfg),
+		Athena:        athena.NewFromConfig(cfg),
+		GuardDuty:     guardduty.NewFromConfig(cfg),
+		SecurityHub:   securityhub.NewFromConfig(cfg),
+		ServiceQuotas: servicequotas.NewFromConfig(cfg),
+		SNS:           sns.NewFromConfig(cfg),
+		SES:           sesv2.NewFromConfig(cfg),
+		ElastiCache:   elasticache.NewFromConfig(cfg),
+		Kinesis:       kinesis.NewFromConfig(cfg),
+		Kafka:         kafka.NewFromConfig(cfg),
 	}, nil
 }
+
+// useEndpoint points cfg at an emulator. Emulators accept any credentials,
+// so when none can be found the conventional test ones are used, and a
+// region is set if none is configured.
+func useEndpoint(ctx context.Context, cfg *awssdk.Config, endpoint string) {
+	cfg.BaseEndpoint = awssdk.String(endpoint)
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if cfg.Credentials != nil {
+		if _, err := cfg.Credentials.Retrieve(ctx); err == nil {
+			return
+		}
+	}
+	cfg.Credentials = credentials.NewStaticCredentialsProvider("test", "test", "")
+}
@@ -0,0 +1,208 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	cetypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// AccountCost is the unblended cost of a single linked account over two
+// consecutive months.
+type AccountCost struct {
+	AccountID    string  `json:"account_id"`
+	AccountName  string  `json:"account_name,omitempty"`
+	PreviousCost float64 `json:"previous_cost"`
+	CurrentCost  float64 `json:"current_cost"`
+	Change       float64 `json:"change"`
+	ChangePct    float64 `json:"change_pct"`
+}
+
+// AccountCostReport holds the per-account rollup for two calendar months.
+type AccountCostReport struct {
+	PreviousMonth     string        `json:"previous_month"`
+	CurrentMonth      string        `json:"current_month"`
+	Currency          string        `json:"currency"`
+	IsManagement      bool          `json:"is_management_account"`
+	CallerAccountID   string        `json:"caller_account_id"`
+	ManagementAccount string        `json:"management_account_id,omitempty"`
+	Accounts          []AccountCost `json:"accounts"`
+}
+
+// IsManagementAccount reports whether the current credentials belong to the
+// management (payer) account of an AWS Organization. The caller and management
+// account IDs are returned so callers can explain the result.
+func (c *Client) IsManagementAccount(ctx context.Context) (bool, string, string, error) {
+	identity, err := c.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	caller := awssdk.ToString(identity.Account)
+
+	org, err := c.Organizations.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		var notInOrg *orgtypes.AWSOrganizationsNotInUseException
+		if errors.As(err, &notInOrg) {
+			return false, caller, "", nil
+		}
+		return false, caller, "", fmt.Errorf("failed to describe organization: %w", err)
+	}
+
+	management := awssdk.ToString(org.Organization.MasterAccountId)
+	return caller == management, caller, management, nil
+}
+
+// CostByAccount returns unblended cost grouped by LINKED_ACCOUNT for the last
+// full calendar month and the month before it, sorted by absolute growth so
+// the account that grew the most comes first.
+func (c *Client) CostByAccount(ctx context.Context, now time.Time) (*AccountCostReport, error) {
+	currentStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	previousStart := currentStart.AddDate(0, -1, 0)
+	end := currentStart.AddDate(0, 1, 0)
+
+	input := &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &cetypes.DateInterval{
+			Start: awssdk.String(previousStart.Format("2006-01-02")),
+			End:   awssdk.String(end.Format("2006-01-02")),
+		},
+		Granularity: cetypes.GranularityMonthly,
+		Metrics:     []string{"UnblendedCost"},
+		GroupBy: []cetypes.GroupDefinition{
+			{Type: cetypes.GroupDefinitionTypeDimension, Key: awssdk.String("LINKED_ACCOUNT")},
+		},
+	}
+
+	// Large organizations have more accounts than fit in one page
+	names := make(map[string]string)
+	var periods []cetypes.ResultByTime
+	for {
+		resp, err := c.CostExplorer.GetCostAndUsage(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cost by linked account: %w", err)
+		}
+		for _, attr := range resp.DimensionValueAttributes {
+			names[awssdk.ToString(attr.Value)] = attr.Attributes["description"]
+		}
+		periods = append(periods, resp.ResultsByTime...)
+		if resp.NextPageToken == nil {
+			break
+		}
+		input.NextPageToken = resp.NextPageToken
+	}
+
+	report := &AccountCostReport{
+		PreviousMonth: previousStart.Format("2006-01"),
+		CurrentMonth:  currentStart.Format("2006-01"),
+	}
+
+	byAccount := make(map[string]*AccountCost)
+	for _, period := range periods {
+		if period.TimePeriod == nil {
+			continue
+		}
+		isCurrent := awssdk.ToString(period.TimePeriod.Start) == currentStart.Format("2006-01-02")
+		for _, group := range period.Groups {
+			if len(group.Keys) == 0 {
+				continue
+			}
+			accountID := group.Keys[0]
+			metric, ok := group.Metrics["UnblendedCost"]
+			if !ok {
+				continue
+			}
+			amount, _ := strconv.ParseFloat(awssdk.ToString(metric.Amount), 64)
+			if report.Currency == "" {
+				report.Currency = awssdk.ToString(metric.Unit)
+			}
+
+			entry, ok := byAccount[accountID]
+			if !ok {
+				entry = &AccountCost{AccountID: accountID, AccountName: names[accountID]}
+				byAccount[accountID] = entry
+			}
+			if isCurrent {
+				entry.CurrentCost += amount
+			} else {
+				entry.PreviousCost += amount
+			}
+		}
+	}
+
+	for _, entry := range byAccount {
+		entry.Change = entry.CurrentCost - entry.PreviousCost
+		if entry.PreviousCost > 0 {
+			entry.ChangePct = entry.Change / entry.PreviousCost * 100
+		}
+		report.Accounts = append(report.Accounts, *entry)
+	}
+
+	sort.Slice(report.Accounts, func(i, j int) bool {
+		return report.Accounts[i].Change > report.Accounts[j].Change
+	})
+
+	return report, nil
+}
+
+// ServiceCost is the unblended cost of a single AWS service.
+type ServiceCost struct {
+	Service string  `json:"service"`
+	Cost    float64 `json:"cost"`
+}
+
+// TopServices returns unblended cost grouped by SERVICE between start and end
+// (exclusive), most expensive first, together with the currency.
+func (c *Client) TopServices(ctx context.Context, start, end time.Time) ([]ServiceCost, string, error) {
+	input := &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &cetypes.DateInterval{
+			Start: awssdk.String(start.Format("2006-01-02")),
+			End:   awssdk.String(end.Format("2006-01-02")),
+		},
+		Granularity: cetypes.GranularityMonthly,
+		Metrics:     []string{"UnblendedCost"},
+		GroupBy: []cetypes.GroupDefinition{
+			{Type: cetypes.GroupDefinitionTypeDimension, Key: awssdk.String("SERVICE")},
+		},
+	}
+
+	var currency string
+	byService := make(map[string]float64)
+	for {
+		resp, err := c.CostExplorer.GetCostAndUsage(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get cost by service: %w", err)
+		}
+		for _, period := range resp.ResultsByTime {
+			for _, group := range period.Groups {
+				metric, ok := group.Metrics["UnblendedCost"]
+				if !ok || len(group.Keys) == 0 {
+					continue
+				}
+				amount, _ := strconv.ParseFloat(awssdk.ToString(metric.Amount), 64)
+				byService[group.Keys[0]] += amount
+				if currency == "" {
+					currency = awssdk.ToString(metric.Unit)
+				}
+			}
+		}
+		if resp.NextPageToken == nil {
+			break
+		}
+		input.NextPageToken = resp.NextPageToken
+	}
+
+	services := make([]ServiceCost, 0, len(byService))
+	for service, cost := range byService {
+		services = append(services, ServiceCost{Service: service, Cost: cost})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Cost > services[j].Cost })
+	return services, currency, nil
+}
@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ErrPermissionDenied matches, with errors.Is, every authorization failure
+// returned by the clients of NewClient, whichever code the service used.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// accessDeniedCodes are the error codes AWS services use for authorization
+// failures. Services are not consistent, so we match all known variants.
+var accessDeniedCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+	"UnauthorizedException": true,
+	"AuthorizationError":    true,
+	"Forbidden":             true,
+}
+
+// IsAccessDenied reports whether err is an AWS authorization failure.
+func IsAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return accessDeniedCodes[apiErr.ErrorCode()]
+	}
+	return false
+}
+
+// permissionError marks an authorization failure as ErrPermissionDenied
+// while keeping the service's error and message.
+type permissionError struct {
+	err error
+}
+
+func (e *permissionError) Error() string { return e.err.Error() }
+
+func (e *permissionError) Unwrap() []error { return []error{e.err, ErrPermissionDenied} }
+
+// addPermissionErrors registers middleware that marks authorization
+// failures as ErrPermissionDenied. It wraps the whole stack, so it sees the
+// error after retries.
+func addPermissionErrors(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CloudAIPermissionErrors",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if err != nil && IsAccessDenied(err) {
+				err = &permissionError{err: err}
+			}
+			return out, metadata, err
+		}), middleware.Before)
+}
+
+// HasErrorCode reports whether err is an AWS API error with one of codes.
+func HasErrorCode(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	guarddutytypes "github.com/aws/aws-sdk-go-v2/service/guardduty/types"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	securityhubtypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+)
+
+// Finding sources
+const (
+	SourceGuardDuty   = "GuardDuty"
+	SourceSecurityHub = "Security Hub"
+)
+
+// guardDutyBatch is the most findings GetFindings accepts at once
+const guardDutyBatch = 50
+
+// ErrNotEnabled is returned when a security service has not been enabled
+// in the account and region.
+var ErrNotEnabled = errors.New("not enabled in this account and region")
+
+// SecurityFinding is an active GuardDuty or Security Hub finding.
+type SecurityFinding struct {
+	Source      string `json:"source"`
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Severity is CRITICAL, HIGH, MEDIUM, LOW or INFORMATIONAL
+	Severity string `json:"severity"`
+	// Resources are the ARNs of the affected resources, or their IDs when
+	// the finding has no ARN, such as an instance ID or access key user
+	Resources    []string  `json:"resources"`
+	ResourceType string    `json:"resource_type,omitempty"`
+	Count        int       `json:"count"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	// Product is the integration that reported a Security Hub finding
+	Product           string `json:"product,omitempty"`
+	Recommendation    string `json:"recommendation,omitempty"`
+	RecommendationURL string `json:"recommendation_url,omitempty"`
+}
+
+// GuardDutyFindings returns up to limit unarchived findings of the region's
+// GuardDuty detectors, the most severe first. It returns ErrNotEnabled when
+// the region has no detector.
+func (c *Client) GuardDutyFindings(ctx context.Context, limit int) ([]SecurityFinding, error) {
+	detectors, err := c.GuardDuty.ListDetectors(ctx, &guardduty.ListDetectorsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list GuardDuty detectors: %w", err)
+	}
+	if len(detectors.DetectorIds) == 0 {
+		return nil, fmt.Errorf("GuardDuty is %w", ErrNotEnabled)
+	}
+
+	var findings []SecurityFinding
+	for _, detector := range detectors.DetectorIds {
+		var ids []string
+		paginator := guardduty.NewListFindingsPaginator(c.GuardDuty, &guardduty.ListFindingsInput{
+			DetectorId: awssdk.String(detector),
+			FindingCriteria: &guarddutytypes.FindingCriteria{Criterion: map[string]guarddutytypes.Condition{
+				"service.archived": {Equals: []string{"false"}},
+			}},
+			SortCriteria: &guarddutytypes.SortCriteria{AttributeName: awssdk.String("severity"), OrderBy: guarddutytypes.OrderByDesc},
+		})
+		for paginator.HasMorePages() && len(findings)+len(ids) < limit {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("could not list GuardDuty findings: %w", err)
+			}
+			ids = append(ids, page.FindingIds...)
+		}
+		if len(findings)+len(ids) > limit {
+			ids = ids[:limit-len(findings)]
+		}
+
+		for start := 0; start < len(ids); start += guardDutyBatch {
+			end := min(start+guardDutyBatch, len(ids))
+			resp, err := c.GuardDuty.GetFindings(ctx, &guardduty.GetFindingsInput{
+				DetectorId: awssdk.String(detector),
+				FindingIds: ids[start:end],
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not get GuardDuty findings: %w", err)
+			}
+			for _, finding := range resp.Findings {
+				findings = append(findings, guardDutyFinding(finding))
+			}
+		}
+	}
+	return findings, nil
+}
+
+func guardDutyFinding(finding guarddutytypes.Finding) SecurityFinding {
+	result := SecurityFinding{
+		Source:      SourceGuardDuty,
+		ID:          awssdk.ToString(finding.Id),
+		Type:        awssdk.ToString(finding.Type),
+		Title:       awssdk.ToString(finding.Title),
+		Description: awssdk.ToString(finding.Description),
+		Severity:    guardDutySeverity(awssdk.ToFloat64(finding.Severity)),
+		Count:       1,
+		FirstSeen:   parseFindingTime(awssdk.ToString(finding.CreatedAt)),
+		LastSeen:    parseFindingTime(awssdk.ToString(finding.UpdatedAt)),
+	}
+	if service := finding.Service; service != nil {
+		if count := awssdk.ToInt32(service.Count); count > 0 {
+			result.Count = int(count)
+		}
+		if first := parseFindingTime(awssdk.ToString(service.EventFirstSeen)); !first.IsZero() {
+			result.FirstSeen = first
+		}
+		if last := parseFindingTime(awssdk.ToString(service.EventLastSeen)); !last.IsZero() {
+			result.LastSeen = last
+		}
+	}
+
+	resource := finding.Resource
+	if resource == nil {
+		return result
+	}
+	result.ResourceType = awssdk.ToString(resource.ResourceType)
+	add := func(arn, id *string) {
+		if value := awssdk.ToString(arn); value != "" {
+			result.Resources = append(result.Resources, value)
+		} else if value := awssdk.ToString(id); value != "" {
+			result.Resources = append(result.Resources, value)
+		}
+	}
+	if resource.InstanceDetails != nil {
+		add(nil, resource.InstanceDetails.InstanceId)
+	}
+	for _, bucket := range resource.S3BucketDetails {
+		add(bucket.Arn, bucket.Name)
+	}
+	if resource.LambdaDetails != nil {
+		add(resource.LambdaDetails.FunctionArn, resource.LambdaDetails.FunctionName)
+	}
+	if resource.RdsDbInstanceDetails != nil {
+		add(resource.RdsDbInstanceDetails.DbInstanceArn, resource.RdsDbInstanceDetails.DbInstanceIdentifier)
+	}
+	if resource.EksClusterDetails != nil {
+		add(resource.EksClusterDetails.Arn, resource.EksClusterDetails.Name)
+	}
+	if resource.AccessKeyDetails != nil {
+		add(nil, resource.AccessKeyDetails.UserName)
+	}
+	return result
+}
+
+// guardDutySeverity maps GuardDuty's numeric severity to the labels Security
+// Hub uses.
+func guardDutySeverity(severity float64) string {
+	switch {
+	case severity >= 9:
+		return "CRITICAL"
+	case severity >= 7:
+		return "HIGH"
+	case severity >= 4:
+		return "MEDIUM"
+	case severity >= 1:
+		return "LOW"
+	}
+	return "INFORMATIONAL"
+}
+
+// SecurityHubFindings returns up to limit active, unresolved Security Hub
+// findings of at least LOW severity, the most severe first. Findings
+// Security Hub imported from GuardDuty are left out when skipGuardDuty is
+// set, since GuardDutyFindings reports them with more detail. It returns
+// ErrNotEnabled when the account is not subscribed to Security Hub.
+func (c *Client) SecurityHubFindings(ctx context.Context, limit int, skipGuardDuty bool) ([]SecurityFinding, error) {
+	equals := func(values ...string) []securityhubtypes.StringFilter {
+		var filters []securityhubtypes.StringFilter
+		for _, value := range values {
+			filters = append(filters, securityhubtypes.StringFilter{Comparison: securityhubtypes.StringFilterComparisonEquals, Value: awssdk.String(value)})
+		}
+		return filters
+	}
+	filters := &securityhubtypes.AwsSecurityFindingFilters{
+		RecordState:    equals("ACTIVE"),
+		WorkflowStatus: equals("NEW", "NOTIFIED"),
+		SeverityLabel:  equals("CRITICAL", "HIGH", "MEDIUM", "LOW"),
+	}
+	if skipGuardDuty {
+		filters.ProductName = []securityhubtypes.StringFilter{{Comparison: securityhubtypes.StringFilterComparisonNotEquals, Value: awssdk.String(SourceGuardDuty)}}
+	}
+
+	var findings []SecurityFinding
+	paginator := securityhub.NewGetFindingsPaginator(c.SecurityHub, &securityhub.GetFindingsInput{
+		Filters:      filters,
+		SortCriteria: []securityhubtypes.SortCriterion{{Field: awssdk.String("SeverityNormalized"), SortOrder: securityhubtypes.SortOrderDescending}},
+	})
+	for paginator.HasMorePages() && len(findings) < limit {
+		page, err := paginator.NextPage(ctx)
+		if HasErrorCode(err, "InvalidAccessException") {
+			return nil, fmt.Errorf("Security Hub is %w", ErrNotEnabled)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get Security Hub findings: %w", err)
+		}
+		for _, finding := range page.Findings {
+			findings = append(findings, securityHubFinding(finding))
+		}
+	}
+	if len(findings) > limit {
+		findings = findings[:limit]
+	}
+	return findings, nil
+}
+
+func securityHubFinding(finding securityhubtypes.AwsSecurityFinding) SecurityFinding {
+	result := SecurityFinding{
+		Source:      SourceSecurityHub,
+		ID:          awssdk.ToString(finding.Id),
+		Type:        awssdk.ToString(finding.GeneratorId),
+		Title:       awssdk.ToString(finding.Title),
+		Description: awssdk.ToString(finding.Description),
+		Count:       1,
+		FirstSeen:   parseFindingTime(awssdk.ToString(finding.FirstObservedAt)),
+		LastSeen:    parseFindingTime(awssdk.ToString(finding.LastObservedAt)),
+		Product:     awssdk.ToString(finding.ProductName),
+	}
+	if result.LastSeen.IsZero() {
+		result.LastSeen = parseFindingTime(awssdk.ToString(finding.UpdatedAt))
+	}
+	if finding.Severity != nil {
+		result.Severity = string(finding.Severity.Label)
+	}
+	for _, resource := range finding.Resources {
+		result.Resources = append(result.Resources, awssdk.ToString(resource.Id))
+		if result.ResourceType == "" {
+			result.ResourceType = awssdk.ToString(resource.Type)
+		}
+	}
+	if finding.Remediation != nil && finding.Remediation.Recommendation != nil {
+		result.Recommendation = awssdk.ToString(finding.Remediation.Recommendation.Text)
+		result.RecommendationURL = awssdk.ToString(finding.Remediation.Recommendation.Url)
+	}
+	return result
+}
+
+func parseFindingTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
@@ -0,0 +1,416 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// maxTrafficPeers caps the peer rows a flow log query returns
+const maxTrafficPeers = 100
+
+// TrafficTarget is a resource whose network traffic is looked up: the
+// network interfaces it receives traffic on and their addresses.
+type TrafficTarget struct {
+	Resource   string   `json:"resource"`
+	Type       string   `json:"type"`
+	VpcID      string   `json:"vpc_id"`
+	Interfaces []string `json:"interfaces"`
+	Addresses  []string `json:"addresses"`
+	// Shared is set when the interfaces were found by security group and
+	// may also belong to other resources in the same groups
+	Shared bool `json:"shared,omitempty"`
+}
+
+// FlowLog is a VPC flow log that records a target's traffic.
+type FlowLog struct {
+	ID string `json:"id"`
+	// Destination is "cloud-watch-logs", "s3" or "kinesis-data-firehose"
+	Destination string `json:"destination"`
+	LogGroup    string `json:"log_group,omitempty"`
+	// TrafficType is ACCEPT, REJECT or ALL
+	TrafficType string `json:"traffic_type"`
+	// CustomFormat is set when the log does not use the default fields
+	CustomFormat bool `json:"custom_format,omitempty"`
+}
+
+// FlowLogsTable is the Athena table of flow logs delivered to S3.
+type FlowLogsTable struct {
+	AthenaTarget
+	Table string `json:"table"`
+}
+
+// TrafficPeer is an address that sent traffic to a target, per port and
+// accept or reject decision.
+type TrafficPeer struct {
+	Address string `json:"address"`
+	// Owner describes the peer's network interface: an instance ID, or the
+	// interface description such as "AWS Lambda VPC ENI-orders-..."
+	Owner    string    `json:"owner"`
+	Port     int       `json:"port"`
+	Action   string    `json:"action"`
+	Bytes    int64     `json:"bytes"`
+	Packets  int64     `json:"packets"`
+	Flows    int64     `json:"flows"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+var (
+	interfaceIDPattern = regexp.MustCompile(`^eni-[0-9a-f]+$`)
+	// defaultFlowLogFormat is the LogFormat of flow logs with the default fields
+	defaultFlowLogFormat = "${version} ${account-id} ${interface-id} ${srcaddr} ${dstaddr} ${srcport} ${dstport} ${protocol} ${packets} ${bytes} ${start} ${end} ${action} ${log-status}"
+)
+
+// FindTrafficTarget resolves ref to the network interfaces traffic to it
+// arrives on. ref is an ENI ID, a private IP, an EC2 instance ID or Name
+// tag, or an RDS instance, Lambda function or load balancer name; RDS
+// instances and Lambda functions are also matched by part of their name,
+// e.g. "legacy" for legacy-orders-db, if that matches only one.
+func (c *Client) FindTrafficTarget(ctx context.Context, ref string) (*TrafficTarget, error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case interfaceIDPattern.MatchString(ref):
+		return c.interfaceTarget(ctx, ref, "AWS::EC2::NetworkInterface", &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{ref}})
+	case net.ParseIP(ref) != nil:
+		return c.interfaceTarget(ctx, ref, "IP address", interfaceFilter("addresses.private-ip-address", ref))
+	case strings.HasPrefix(ref, "i-"):
+		return c.interfaceTarget(ctx, ref, "AWS::EC2::Instance", interfaceFilter("attachment.instance-id", ref))
+	}
+
+	if target, err := c.rdsTrafficTarget(ctx, ref); target != nil || err != nil {
+		return target, err
+	}
+	if target, err := c.lambdaTrafficTarget(ctx, ref); target != nil || err != nil {
+		return target, err
+	}
+	for _, kind := range []string{"app", "net"} {
+		input := interfaceFilter("description", fmt.Sprintf("ELB %s/%s/*", kind, ref))
+		if target, err := c.interfaceTarget(ctx, ref, "AWS::ElasticLoadBalancingV2::LoadBalancer", input); err == nil {
+			return target, nil
+		}
+	}
+	if instance, err := c.FindInstance(ctx, ref); err == nil {
+		return c.interfaceTarget(ctx, ref, "AWS::EC2::Instance", interfaceFilter("attachment.instance-id", instance.ID))
+	}
+	return nil, fmt.Errorf("found no network interface of %s: name an RDS instance, Lambda function, load balancer, EC2 instance, ENI or private IP", ref)
+}
+
+func interfaceFilter(name string, values ...string) *ec2.DescribeNetworkInterfacesInput {
+	return &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{{Name: awssdk.String(name), Values: values}},
+	}
+}
+
+// interfaceTarget collects the interfaces input finds into a target.
+func (c *Client) interfaceTarget(ctx context.Context, resource, resourceType string, input *ec2.DescribeNetworkInterfacesInput) (*TrafficTarget, error) {
+	interfaces, err := c.describeInterfaces(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(interfaces) == 0 {
+		return nil, fmt.Errorf("found no network interface of %s", resource)
+	}
+	target := &TrafficTarget{Resource: resource, Type: resourceType}
+	for _, eni := range interfaces {
+		target.VpcID = awssdk.ToString(eni.VpcId)
+		target.Interfaces = append(target.Interfaces, awssdk.ToString(eni.NetworkInterfaceId))
+		for _, address := range eni.PrivateIpAddresses {
+			target.Addresses = append(target.Addresses, awssdk.ToString(address.PrivateIpAddress))
+		}
+	}
+	return target, nil
+}
+
+func (c *Client) describeInterfaces(ctx context.Context, input *ec2.DescribeNetworkInterfacesInput) ([]ec2types.NetworkInterface, error) {
+	var interfaces []ec2types.NetworkInterface
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(c.EC2, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not describe network interfaces: %w", err)
+		}
+		interfaces = append(interfaces, page.NetworkInterfaces...)
+	}
+	return interfaces, nil
+}
+
+// rdsTrafficTarget finds the interface of an RDS instance through the
+// address its endpoint resolves to, which for an instance that is not
+// publicly accessible is its private IP. When the name cannot be resolved,
+// the interfaces RDS created in the instance's security groups are used. It
+// returns nil, nil when no instance matches.
+func (c *Client) rdsTrafficTarget(ctx context.Context, ref string) (*TrafficTarget, error) {
+	var names []string
+	instances := map[string]rdstypes.DBInstance{}
+	paginator := rds.NewDescribeDBInstancesPaginator(c.RDS, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil
+		}
+		for _, instance := range page.DBInstances {
+			name := awssdk.ToString(instance.DBInstanceIdentifier)
+			names = append(names, name)
+			instances[name] = instance
+		}
+	}
+	name, err := matchResourceName(names, ref, "RDS instances")
+	if name == "" {
+		return nil, err
+	}
+	instance := instances[name]
+
+	if instance.Endpoint != nil {
+		if addresses, err := net.DefaultResolver.LookupHost(ctx, awssdk.ToString(instance.Endpoint.Address)); err == nil {
+			if target, err := c.interfaceTarget(ctx, name, "AWS::RDS::DBInstance", interfaceFilter("addresses.private-ip-address", addresses...)); err == nil {
+				return target, nil
+			}
+		}
+	}
+	input := interfaceFilter("description", "RDSNetworkInterface")
+	var groups []string
+	for _, group := range instance.VpcSecurityGroups {
+		groups = append(groups, awssdk.ToString(group.VpcSecurityGroupId))
+	}
+	input.Filters = append(input.Filters, ec2types.Filter{Name: awssdk.String("group-id"), Values: groups})
+	target, err := c.interfaceTarget(ctx, name, "AWS::RDS::DBInstance", input)
+	if err != nil {
+		return nil, err
+	}
+	target.Shared = true
+	return target, nil
+}
+
+// lambdaTrafficTarget finds the interfaces of a function attached to a VPC.
+// It returns nil, nil when no function matches.
+func (c *Client) lambdaTrafficTarget(ctx context.Context, ref string) (*TrafficTarget, error) {
+	var names []string
+	paginator := lambda.NewListFunctionsPaginator(c.Lambda, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil
+		}
+		for _, function := range page.Functions {
+			if function.VpcConfig != nil && awssdk.ToString(function.VpcConfig.VpcId) != "" {
+				names = append(names, awssdk.ToString(function.FunctionName))
+			}
+		}
+	}
+	name, err := matchResourceName(names, ref, "VPC Lambda functions")
+	if name == "" {
+		return nil, err
+	}
+	return c.interfaceTarget(ctx, name, "AWS::Lambda::Function", interfaceFilter("description", "AWS Lambda VPC ENI-"+name+"*"))
+}
+
+// matchResourceName picks the name ref refers to: the exact name, or the
+// only name that contains every word of ref. Several partial matches are an
+// error rather than a guess; no match returns "".
+func matchResourceName(names []string, ref, kind string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(ref), func(r rune) bool { return r == ' ' || r == '-' || r == '_' })
+	var matches []string
+	for _, name := range names {
+		if name == ref {
+			return name, nil
+		}
+		lower := strings.ToLower(name)
+		all := len(words) > 0
+		for _, word := range words {
+			all = all && strings.Contains(lower, word)
+		}
+		if all {
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%d %s match %q (%s): name one", len(matches), kind, ref, strings.Join(matches, ", "))
+}
+
+// FlowLogsFor returns the flow logs that record the target's traffic: those
+// of its interfaces, their subnets or its VPC.
+func (c *Client) FlowLogsFor(ctx context.Context, target *TrafficTarget) ([]FlowLog, error) {
+	resources := append([]string{target.VpcID}, target.Interfaces...)
+	interfaces, err := c.describeInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: target.Interfaces})
+	if err != nil {
+		return nil, err
+	}
+	for _, eni := range interfaces {
+		resources = append(resources, awssdk.ToString(eni.SubnetId))
+	}
+
+	var logs []FlowLog
+	paginator := ec2.NewDescribeFlowLogsPaginator(c.EC2, &ec2.DescribeFlowLogsInput{
+		Filter: []ec2types.Filter{{Name: awssdk.String("resource-id"), Values: resources}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not describe flow logs: %w", err)
+		}
+		for _, log := range page.FlowLogs {
+			if awssdk.ToString(log.FlowLogStatus) != "ACTIVE" {
+				continue
+			}
+			format := awssdk.ToString(log.LogFormat)
+			logs = append(logs, FlowLog{
+				ID:           awssdk.ToString(log.FlowLogId),
+				Destination:  string(log.LogDestinationType),
+				LogGroup:     awssdk.ToString(log.LogGroupName),
+				TrafficType:  string(log.TrafficType),
+				CustomFormat: format != "" && format != defaultFlowLogFormat,
+			})
+		}
+	}
+	return logs, nil
+}
+
+// ObservedTraffic queries a flow log for the traffic that reached the
+// target between since and until, grouped by source address, destination
+// port and action, the most bytes first. Flow logs in CloudWatch Logs are
+// read with Logs Insights, those in S3 with Athena through table, which is
+// required for them. Peers in the VPC are named after their interfaces.
+func (c *Client) ObservedTraffic(ctx context.Context, target *TrafficTarget, log FlowLog, table *FlowLogsTable, since, until time.Time) ([]TrafficPeer, error) {
+	for _, id := range target.Interfaces {
+		if !interfaceIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("unexpected interface ID %q", id)
+		}
+	}
+	for _, address := range target.Addresses {
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("unexpected address %q", address)
+		}
+	}
+
+	var result *QueryResult
+	var err error
+	switch log.Destination {
+	case "cloud-watch-logs":
+		query := fmt.Sprintf(`filter interfaceId in [%s] and dstAddr in [%s]
+| stats sum(bytes) as bytes, sum(packets) as packets, count(*) as flows, max(end) as last_seen by srcAddr, dstPort, action
+| sort bytes desc
+| limit %d`, quoteList(target.Interfaces, `"`), quoteList(target.Addresses, `"`), maxTrafficPeers)
+		result, err = c.RunLogsInsightsQuery(ctx, []string{log.LogGroup}, query, since, until)
+	case "s3":
+		if table == nil || table.Database == "" {
+			return nil, fmt.Errorf("flow log %s is delivered to S3: set athena.database and athena.flow_logs_table to query it", log.ID)
+		}
+		result, err = c.RunAthenaQuery(ctx, c.flowLogsSQL(ctx, target, table, since, until), table.AthenaTarget)
+	default:
+		return nil, fmt.Errorf("flow log %s is delivered to %s, which cannot be queried", log.ID, log.Destination)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var peers []TrafficPeer
+	for _, row := range result.Rows {
+		if len(row) < 7 {
+			continue
+		}
+		port, _ := strconv.Atoi(row[1])
+		bytes, _ := strconv.ParseInt(row[3], 10, 64)
+		packets, _ := strconv.ParseInt(row[4], 10, 64)
+		flows, _ := strconv.ParseInt(row[5], 10, 64)
+		lastSeen, _ := strconv.ParseInt(row[6], 10, 64)
+		peers = append(peers, TrafficPeer{
+			Address:  row[0],
+			Port:     port,
+			Action:   row[2],
+			Bytes:    bytes,
+			Packets:  packets,
+			Flows:    flows,
+			LastSeen: time.Unix(lastSeen, 0).UTC(),
+		})
+	}
+	c.namePeers(ctx, target.VpcID, peers)
+	return peers, nil
+}
+
+// flowLogsSQL is the Athena equivalent of the Logs Insights query. The date
+// partition is filtered when the table has one, so only the window's data is
+// scanned.
+func (c *Client) flowLogsSQL(ctx context.Context, target *TrafficTarget, table *FlowLogsTable, since, until time.Time) string {
+	partition := ""
+	if columns, err := c.AthenaTableColumns(ctx, table.AthenaTarget, table.Table); err == nil {
+		for _, column := range columns {
+			if strings.HasPrefix(column, "date ") {
+				partition = fmt.Sprintf(` AND "date" >= DATE '%s'`, since.UTC().Format("2006-01-02"))
+			}
+		}
+	}
+	return fmt.Sprintf(`SELECT srcaddr, dstport, action, sum(bytes), sum(packets), count(*), max("end")
+FROM "%s"
+WHERE interface_id IN (%s) AND dstaddr IN (%s) AND start >= %d AND start < %d%s
+GROUP BY srcaddr, dstport, action
+ORDER BY 4 DESC
+LIMIT %d`, strings.ReplaceAll(table.Table, `"`, ""), quoteList(target.Interfaces, "'"), quoteList(target.Addresses, "'"),
+		since.Unix(), until.Unix(), partition, maxTrafficPeers)
+}
+
+// quoteList joins values, which were checked to be IDs or addresses, quoted
+// for a query.
+func quoteList(values []string, quote string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote + value + quote
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// namePeers sets the owner of each peer: what its interface in the VPC is
+// attached to, or where the address lies.
+func (c *Client) namePeers(ctx context.Context, vpcID string, peers []TrafficPeer) {
+	var addresses []string
+	for _, peer := range peers {
+		if net.ParseIP(peer.Address) != nil {
+			addresses = append(addresses, peer.Address)
+		}
+	}
+	sort.Strings(addresses)
+	owners := map[string]string{}
+	if len(addresses) > 0 {
+		input := interfaceFilter("addresses.private-ip-address", addresses...)
+		input.Filters = append(input.Filters, ec2types.Filter{Name: awssdk.String("vpc-id"), Values: []string{vpcID}})
+		interfaces, _ := c.describeInterfaces(ctx, input)
+		for _, eni := range interfaces {
+			owner := awssdk.ToString(eni.Description)
+			if eni.Attachment != nil && awssdk.ToString(eni.Attachment.InstanceId) != "" {
+				owner = "instance " + awssdk.ToString(eni.Attachment.InstanceId)
+			}
+			if owner == "" {
+				owner = awssdk.ToString(eni.NetworkInterfaceId)
+			}
+			for _, address := range eni.PrivateIpAddresses {
+				owners[awssdk.ToString(address.PrivateIpAddress)] = owner
+			}
+		}
+	}
+	for i := range peers {
+		switch ip := net.ParseIP(peers[i].Address); {
+		case owners[peers[i].Address] != "":
+			peers[i].Owner = owners[peers[i].Address]
+		case ip != nil && ip.IsPrivate():
+			peers[i].Owner = "private address outside the VPC's interfaces"
+		default:
+			peers[i].Owner = "outside the VPC"
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Feature describes a CloudAI-CLI capability and the IAM actions it needs.
+type Feature struct {
+	Name     string   `json:"name"`
+	Commands []string `json:"commands"`
+	Actions  []string `json:"actions"`
+}
+
+// Features lists every capability that talks to AWS on the user's behalf.
+// Keep this in sync when adding commands that call new AWS APIs.
+var Features = []Feature{
+	{
+		Name:     "Credential check",
+		Commands: []string{"cloudai setup"},
+		Actions:  []string{"lambda:ListFunctions"},
+	},
+	{
+		Name:     "API Gateway to Lambda lookups",
+		Commands: []string{`cloudai "Which Lambda handles GET /users on prod-api?"`},
+		Actions:  []string{"apigateway:GET"},
+	},
+	{
+		Name:     "Edge routing lookups",
+		Commands: []string{`cloudai "What does api.example.com point to?"`},
+		Actions: []string{
+			"route53:ListHostedZones", "route53:ListResourceRecordSets", "cloudfront:ListDistributions",
+			"elasticloadbalancing:DescribeLoadBalancers", "elasticloadbalancing:DescribeListeners",
+			"elasticloadbalancing:DescribeRules", "elasticloadbalancing:DescribeTargetHealth", "apigateway:GET",
+		},
+	},
+	{
+		Name:     "S3 bucket configuration",
+		Commands: []string{`cloudai "Which buckets are public?"`, `cloudai "Which buckets lack versioning?"`},
+		Actions: []string{
+			"s3:ListAllMyBuckets", "s3:GetBucketPolicyStatus", "s3:GetBucketPublicAccessBlock",
+			"s3:GetEncryptionConfiguration", "s3:GetBucketVersioning",
+		},
+	},
+	{
+		Name:     "Lambda configuration",
+		Commands: []string{`cloudai "Which Lambdas still run python3.8?"`, `cloudai "Which functions lack reserved concurrency?"`},
+		Actions:  []string{"lambda:ListFunctions", "lambda:GetFunctionConcurrency"},
+	},
+	{
+		Name:     "Live scan",
+		Commands: []string{"cloudai scan --live", `cloudai "What is encrypted with the payments key?"`},
+		Actions: []string{
+			"tag:GetResources", "lambda:ListFunctions", "apigateway:GET", "s3:ListAllMyBuckets", "s3:GetEncryptionConfiguration",
+			"s3:GetReplicationConfiguration", "kms:ListKeys", "kms:DescribeKey", "kms:ListAliases", "kms:ListGrants",
+			"rds:DescribeDBInstances", "rds:DescribeDBClusters", "eks:ListClusters", "eks:DescribeCluster",
+			"dynamodb:ListTables", "dynamodb:DescribeTable", "dynamodb:DescribeContinuousBackups",
+			"backup:ListBackupPlans", "backup:GetBackupPlan", "backup:ListBackupSelections", "backup:GetBackupSelection",
+			"cloudwatch:DescribeAlarms", "cloudwatch:ListDashboards", "cloudwatch:GetDashboard",
+		},
+	},
+	{
+		Name:     "Discovered resource details",
+		Commands: []string{"cloudai scan --discover", `cloudai "What runtime does the orders function use?"`},
+		Actions: []string{
+			"lambda:GetFunction", "apigateway:GET", "s3:ListBucket", "kms:DescribeKey", "kms:ListAliases", "kms:ListGrants",
+			"rds:DescribeDBInstances", "rds:DescribeDBClusters", "eks:DescribeCluster",
+		},
+	},
+	{
+		Name:     "AWS Config scan",
+		Commands: []string{"cloudai scan --aws-config", "cloudai scan --aggregator <name>"},
+		Actions:  []string{"config:SelectResourceConfig", "config:SelectAggregateResourceConfig"},
+	},
+	{
+		Name:     "Top services by cost",
+		Commands: []string{`cloudai "Top 5 services by cost last 30 days"`},
+		Actions:  []string{"ce:GetCostAndUsage"},
+	},
+	{
+		Name:     "Cost by account",
+		Commands: []string{"cloudai cost --by-account"},
+		Actions:  []string{"ce:GetCostAndUsage", "organizations:DescribeOrganization"},
+	},
+	{
+		Name:     "Incident triage",
+		Commands: []string{`cloudai incident "checkout is failing"`},
+		Actions:  []string{"cloudwatch:DescribeAlarms", "cloudwatch:GetMetricData", "cloudtrail:LookupEvents"},
+	},
+	{
+		Name:     "SSM tunnel",
+		Commands: []string{"cloudai tunnel <instance>"},
+		Actions:  []string{"ec2:DescribeInstances", "ssm:StartSession", "ssm:TerminateSession"},
+	},
+	{
+		Name:     "Training bootstrap",
+		Commands: []string{"cloudai train bootstrap-role"},
+		Actions: []string{
+			"sts:GetCallerIdentity", "s3:ListBucket", "s3:CreateBucket",
+			"iam:GetRole", "iam:CreateRole", "iam:TagRole", "iam:PutRolePolicy",
+		},
+	},
+	{
+		Name:     "Training data upload",
+		Commands: []string{"cloudai train prepare <file> --upload"},
+		Actions:  []string{"s3:PutObject"},
+	},
+	{
+		Name:     "Training job logs",
+		Commands: []string{"cloudai train logs <job>"},
+		Actions:  []string{"sagemaker:DescribeTrainingJob", "logs:FilterLogEvents"},
+	},
+	{
+		Name:     "Model registry",
+		Commands: []string{"cloudai train register <job>", "cloudai sagemaker versions"},
+		Actions: []string{
+			"sagemaker:CreateModelPackage", "sagemaker:CreateModelPackageGroup",
+			"sagemaker:ListModelPackages", "sagemaker:DescribeModelPackage",
+		},
+	},
+	{
+		Name:     "Model deployment",
+		Commands: []string{"cloudai sagemaker deploy"},
+		Actions: []string{
+			"sagemaker:CreateModel", "sagemaker:CreateEndpointConfig", "sagemaker:CreateEndpoint",
+			"sagemaker:UpdateEndpoint", "sagemaker:UpdateModelPackage", "iam:PassRole",
+		},
+	},
+	{
+		Name:     "Log queries",
+		Commands: []string{"cloudai nl2query <request>"},
+		Actions:  []string{"logs:DescribeLogGroups", "logs:StartQuery", "logs:GetQueryResults", "logs:StopQuery"},
+	},
+	{
+		Name:     "Athena queries",
+		Commands: []string{"cloudai nl2query <request> --source cur|flowlogs"},
+		Actions: []string{
+			"athena:GetTableMetadata", "athena:StartQueryExecution", "athena:GetQueryExecution",
+			"athena:GetQueryResults", "athena:StopQueryExecution", "glue:GetTable",
+		},
+	},
+	{
+		Name:     "Flow log traffic",
+		Commands: []string{"cloudai \"is anything talking to <resource>?\""},
+		Actions: []string{
+			"ec2:DescribeNetworkInterfaces", "ec2:DescribeFlowLogs", "rds:DescribeDBInstances",
+			"lambda:ListFunctions", "logs:StartQuery", "logs:GetQueryResults",
+		},
+	},
+	{
+		Name:     "Security findings",
+		Commands: []string{"cloudai findings"},
+		Actions: []string{
+			"guardduty:ListDetectors", "guardduty:ListFindings", "guardduty:GetFindings",
+			"securityhub:GetFindings",
+		},
+	},
+	{
+		Name:     "Service quotas",
+		Commands: []string{"cloudai quotas"},
+		Actions: []string{
+			"servicequotas:GetServiceQuota", "servicequotas:GetAWSDefaultServiceQuota",
+			"cloudwatch:GetMetricStatistics", "ec2:DescribeAddresses", "ec2:DescribeNetworkInterfaces",
+		},
+	},
+	{
+		Name:     "Report delivery",
+		Commands: []string{"cloudai report --deliver"},
+		Actions:  []string{"ce:GetCostAndUsage", "sns:Publish", "ses:SendEmail"},
+	},
+	{
+		Name:     "Bedrock models",
+		Commands: []string{"cloudai <question>", "cloudai bedrock-setup", "cloudai auto-setup", "cloudai list-models"},
+		Actions:  []string{"bedrock:InvokeModel", "bedrock:ListFoundationModels"},
+	},
+	{
+		Name:     "SageMaker models",
+		Commands: []string{"cloudai <question> (model.type sagemaker, arch.endpoint or CLOUDAI_ARCH_ENDPOINT)"},
+		Actions:  []string{"sagemaker:InvokeEndpoint"},
+	},
+}
+
+// Identity describes the principal behind the current credentials.
+type Identity struct {
+	Account string `json:"account"`
+	ARN     string `json:"arn"`
+	UserID  string `json:"user_id"`
+	// PrincipalARN is the IAM user or role ARN that policies are attached to.
+	// For assumed-role sessions this is the underlying role, not the session.
+	PrincipalARN string `json:"principal_arn,omitempty"`
+	// PrincipalType is one of "user", "role", "root" or "federated".
+	PrincipalType string `json:"principal_type"`
+}
+
+// WhoAmI calls STS GetCallerIdentity and resolves the IAM principal that can
+// be used for policy simulation.
+func (c *Client) WhoAmI(ctx context.Context) (*Identity, error) {
+	resp, err := c.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+
+	id := &Identity{
+		Account: awssdk.ToString(resp.Account),
+		ARN:     awssdk.ToString(resp.Arn),
+		UserID:  awssdk.ToString(resp.UserId),
+	}
+
+	// arn:aws:iam::123456789012:user/path/name
+	// arn:aws:sts::123456789012:assumed-role/RoleName/session
+	// arn:aws:iam::123456789012:root
+	parts := strings.SplitN(id.ARN, ":", 6)
+	if len(parts) < 6 {
+		return id, nil
+	}
+	resource := parts[5]
+
+	switch {
+	case resource == "root":
+		id.PrincipalType = "root"
+	case strings.HasPrefix(resource, "user/"):
+		id.PrincipalType = "user"
+		id.PrincipalARN = id.ARN
+	case strings.HasPrefix(resource, "assumed-role/"):
+		id.PrincipalType = "role"
+		roleName := strings.SplitN(strings.TrimPrefix(resource, "assumed-role/"), "/", 2)[0]
+		// Roles can live under a path that the session ARN does not carry,
+		// so ask IAM for the canonical ARN and fall back to the path-less one.
+		id.PrincipalARN = fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], id.Account, roleName)
+		if role, err := c.IAM.GetRole(ctx, &iam.GetRoleInput{RoleName: awssdk.String(roleName)}); err == nil && role.Role != nil {
+			id.PrincipalARN = awssdk.ToString(role.Role.Arn)
+		}
+	default:
+		id.PrincipalType = "federated"
+	}
+
+	return id, nil
+}
+
+// SimulateActions evaluates the given actions against the principal's IAM
+// policies and returns the decision ("allowed", "implicitDeny" or
+// "explicitDeny") for each action.
+func (c *Client) SimulateActions(ctx context.Context, principalARN string, actions []string) (map[string]string, error) {
+	decisions := make(map[string]string, len(actions))
+
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(c.IAM, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: awssdk.String(principalARN),
+		ActionNames:     actions,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to simulate policies for %s: %w", principalARN, err)
+		}
+		for _, result := range page.EvaluationResults {
+			decisions[awssdk.ToString(result.EvalActionName)] = string(result.EvalDecision)
+		}
+	}
+
+	return decisions, nil
+}
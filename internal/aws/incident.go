@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// maxMetricQueries is the most queries GetMetricData accepts in one call.
+const maxMetricQueries = 500
+
+// maxTrailPages caps LookupEvents, which AWS throttles to two calls a
+// second; 20 pages are the latest 1000 changes.
+const maxTrailPages = 20
+
+// Alarm is a CloudWatch metric alarm and the metric it watches.
+type Alarm struct {
+	Name       string            `json:"name"`
+	ARN        string            `json:"arn,omitempty"`
+	State      string            `json:"state"`
+	Reason     string            `json:"reason,omitempty"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Metric     string            `json:"metric,omitempty"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// MetricQuery selects the sum of one CloudWatch metric.
+type MetricQuery struct {
+	// ID must be unique within a call, start with a lowercase letter and
+	// contain only letters, digits and underscores
+	ID         string
+	Namespace  string
+	Metric     string
+	Dimensions map[string]string
+}
+
+// TrailEvent is a write call recorded by CloudTrail.
+type TrailEvent struct {
+	Time   time.Time `json:"time"`
+	Name   string    `json:"name"`
+	Source string    `json:"source"`
+	User   string    `json:"user,omitempty"`
+	// Resources are the names and ARNs the call touched, from the event's
+	// resource list and its request parameters
+	Resources []string `json:"resources,omitempty"`
+}
+
+// Alarms returns every CloudWatch metric alarm in the region.
+func (c *Client) Alarms(ctx context.Context) ([]Alarm, error) {
+	var alarms []Alarm
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(c.CloudWatch, &cloudwatch.DescribeAlarmsInput{
+		AlarmTypes: []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe alarms: %w", err)
+		}
+		for _, alarm := range page.MetricAlarms {
+			dimensions := make(map[string]string, len(alarm.Dimensions))
+			for _, dimension := range alarm.Dimensions {
+				dimensions[awssdk.ToString(dimension.Name)] = awssdk.ToString(dimension.Value)
+			}
+			alarms = append(alarms, Alarm{
+				Name:       awssdk.ToString(alarm.AlarmName),
+				ARN:        awssdk.ToString(alarm.AlarmArn),
+				State:      string(alarm.StateValue),
+				Reason:     awssdk.ToString(alarm.StateReason),
+				Namespace:  awssdk.ToString(alarm.Namespace),
+				Metric:     awssdk.ToString(alarm.MetricName),
+				Dimensions: dimensions,
+				UpdatedAt:  awssdk.ToTime(alarm.StateUpdatedTimestamp),
+			})
+		}
+	}
+	return alarms, nil
+}
+
+// MetricSums returns the sum of each queried metric between start and end,
+// by query ID. Metrics without datapoints are left out.
+func (c *Client) MetricSums(ctx context.Context, queries []MetricQuery, start, end time.Time) (map[string]float64, error) {
+	sums := make(map[string]float64)
+	period := int32(max(end.Sub(start)/time.Second, 60))
+	period -= period % 60
+
+	for len(queries) > 0 {
+		batch := queries[:min(len(queries), maxMetricQueries)]
+		queries = queries[len(batch):]
+
+		dataQueries := make([]cwtypes.MetricDataQuery, 0, len(batch))
+		for _, query := range batch {
+			var dimensions []cwtypes.Dimension
+			for name, value := range query.Dimensions {
+				dimensions = append(dimensions, cwtypes.Dimension{Name: awssdk.String(name), Value: awssdk.String(value)})
+			}
+			dataQueries = append(dataQueries, cwtypes.MetricDataQuery{
+				Id: awssdk.String(query.ID),
+				MetricStat: &cwtypes.MetricStat{
+					Metric: &cwtypes.Metric{
+						Namespace:  awssdk.String(query.Namespace),
+						MetricName: awssdk.String(query.Metric),
+						Dimensions: dimensions,
+					},
+					Period: awssdk.Int32(period),
+					Stat:   awssdk.String("Sum"),
+				},
+			})
+		}
+
+		paginator := cloudwatch.NewGetMetricDataPaginator(c.CloudWatch, &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: dataQueries,
+			StartTime:         awssdk.Time(start),
+			EndTime:           awssdk.Time(end),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get metric data: %w", err)
+			}
+			for _, result := range page.MetricDataResults {
+				if len(result.Values) == 0 {
+					continue
+				}
+				for _, value := range result.Values {
+					sums[awssdk.ToString(result.Id)] += value
+				}
+			}
+		}
+	}
+	return sums, nil
+}
+
+// RecentChanges returns the write calls CloudTrail recorded since the given
+// time, newest first, up to the latest 1000.
+func (c *Client) RecentChanges(ctx context.Context, since time.Time) ([]TrailEvent, error) {
+	var events []TrailEvent
+	paginator := cloudtrail.NewLookupEventsPaginator(c.CloudTrail, &cloudtrail.LookupEventsInput{
+		LookupAttributes: []cttypes.LookupAttribute{{
+			AttributeKey:   cttypes.LookupAttributeKeyReadOnly,
+			AttributeValue: awssdk.String("false"),
+		}},
+		StartTime:  awssdk.Time(since),
+		MaxResults: awssdk.Int32(50),
+	})
+	for pages := 0; paginator.HasMorePages() && pages < maxTrailPages; pages++ {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up CloudTrail events: %w", err)
+		}
+		for _, event := range page.Events {
+			events = append(events, trailEvent(event))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	return events, nil
+}
+
+func trailEvent(event cttypes.Event) TrailEvent {
+	seen := make(map[string]bool)
+	var resources []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			resources = append(resources, name)
+		}
+	}
+	for _, resource := range event.Resources {
+		add(awssdk.ToString(resource.ResourceName))
+	}
+	// not every service fills in the resource list, but the request
+	// parameters name what was changed
+	var record struct {
+		RequestParameters map[string]interface{} `json:"requestParameters"`
+	}
+	if json.Unmarshal([]byte(awssdk.ToString(event.CloudTrailEvent)), &record) == nil {
+		keys := make([]string, 0, len(record.RequestParameters))
+		for key := range record.RequestParameters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if value, ok := record.RequestParameters[key].(string); ok {
+				add(value)
+			}
+		}
+	}
+	return TrailEvent{
+		Time:      awssdk.ToTime(event.EventTime),
+		Name:      awssdk.ToString(event.EventName),
+		Source:    awssdk.ToString(event.EventSource),
+		User:      awssdk.ToString(event.Username),
+		Resources: resources,
+	}
+}
@@ -0,0 +1,68 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Instance is an EC2 instance a tunnel can be opened to.
+type Instance struct {
+	ID   string
+	Name string
+}
+
+// FindInstance looks up a running instance by ID or by its Name tag. A name
+// shared by several running instances is an error rather than a guess.
+func (c *Client) FindInstance(ctx context.Context, target string) (*Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{{Name: awssdk.String("instance-state-name"), Values: []string{"running"}}},
+	}
+	if strings.HasPrefix(target, "i-") {
+		input.InstanceIds = []string{target}
+	} else {
+		input.Filters = append(input.Filters, ec2types.Filter{Name: awssdk.String("tag:Name"), Values: []string{target}})
+	}
+
+	var found []Instance
+	paginator := ec2.NewDescribeInstancesPaginator(c.EC2, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				found = append(found, Instance{
+					ID:   awssdk.ToString(instance.InstanceId),
+					Name: instanceName(instance.Tags),
+				})
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no running instance %s", target)
+	case 1:
+		return &found[0], nil
+	}
+	ids := make([]string, len(found))
+	for i, instance := range found {
+		ids[i] = instance.ID
+	}
+	return nil, fmt.Errorf("%d running instances are named %s (%s): pass an instance ID", len(found), target, strings.Join(ids, ", "))
+}
+
+func instanceName(tags []ec2types.Tag) string {
+	for _, tag := range tags {
+		if awssdk.ToString(tag.Key) == "Name" {
+			return awssdk.ToString(tag.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,423 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sagemaker"
+	sagemakertypes "github.com/aws/aws-sdk-go-v2/service/sagemaker/types"
+)
+
+// DefaultModelPackageGroup is the model registry group fine-tuned
+// architecture models are registered in, as the training stack names it.
+const DefaultModelPackageGroup = "cloudai-arch-models"
+
+// DefaultInferenceInstanceType serves an 8B model on one A10G.
+const DefaultInferenceInstanceType = "ml.g5.2xlarge"
+
+// tgiInferenceImage is the Hugging Face TGI container the training stack
+// registers models with; %s is the region.
+const tgiInferenceImage = "763104351884.dkr.ecr.%s.amazonaws.com/huggingface-pytorch-tgi-inference:2.3.0-tgi2.2.0-gpu-py310-cu121-ubuntu22.04"
+
+// Metadata keys of registered models. Eval scores are stored as eval_<name>.
+const (
+	metadataTrainingJob = "training_job"
+	metadataBaseModel   = "base_model"
+	metadataDataset     = "dataset"
+	metadataDatasetHash = "dataset_sha256"
+	metadataFormat      = "cloudai_format"
+	metadataEvalPrefix  = "eval_"
+)
+
+// inferenceGPUs is the GPU count TGI shards the model across, per
+// instance type; unknown types get one.
+var inferenceGPUs = map[string]int{
+	"ml.g5.12xlarge":  4,
+	"ml.g5.48xlarge":  8,
+	"ml.p4d.24xlarge": 8,
+}
+
+// ModelVersion is a registered version of the architecture model.
+type ModelVersion struct {
+	Version      int32              `json:"version"`
+	ARN          string             `json:"arn"`
+	Approval     string             `json:"approval"`
+	Created      time.Time          `json:"created"`
+	TrainingJob  string             `json:"training_job,omitempty"`
+	BaseModel    string             `json:"base_model,omitempty"`
+	Dataset      string             `json:"dataset,omitempty"`
+	DatasetHash  string             `json:"dataset_sha256,omitempty"`
+	EvalScores   map[string]float64 `json:"eval_scores,omitempty"`
+	ModelData    string             `json:"model_data,omitempty"`
+	InstanceType string             `json:"instance_type,omitempty"`
+	Deployed     bool               `json:"deployed,omitempty"`
+}
+
+// Deployment is the endpoint a model version was deployed to.
+type Deployment struct {
+	Endpoint       string `json:"endpoint"`
+	EndpointConfig string `json:"endpoint_config"`
+	Model          string `json:"model"`
+	Version        int32  `json:"version"`
+	InstanceType   string `json:"instance_type"`
+	Created        bool   `json:"created"`
+	Region         string `json:"region"`
+}
+
+// RegisterTrainedModel registers the artifacts of a completed training job
+// as a new, approved version in the model registry, recording the job, its
+// base model, a hash of its training data and the eval scores: the job's
+// final metrics, overridden by those given. The group is created if it
+// does not exist. The client must be created WithWrites.
+func (c *Client) RegisterTrainedModel(ctx context.Context, group, jobName, instanceType string, evalScores map[string]float64) (*ModelVersion, error) {
+	job, err := c.SageMaker.DescribeTrainingJob(ctx, &sagemaker.DescribeTrainingJobInput{TrainingJobName: awssdk.String(jobName)})
+	if err != nil {
+		return nil, fmt.Errorf("could not describe training job %s: %w", jobName, err)
+	}
+	if job.TrainingJobStatus != sagemakertypes.TrainingJobStatusCompleted || job.ModelArtifacts == nil {
+		return nil, fmt.Errorf("training job %s is %s; only completed jobs can be registered", jobName, strings.ToLower(string(job.TrainingJobStatus)))
+	}
+
+	metadata := map[string]string{
+		metadataTrainingJob: jobName,
+		metadataFormat:      "tgi",
+	}
+	if baseModel := job.HyperParameters["model_id"]; baseModel != "" {
+		metadata[metadataBaseModel] = strings.Trim(baseModel, `"`)
+	}
+	if dataset := trainingDataURI(job.InputDataConfig); dataset != "" {
+		hash, err := c.datasetHash(ctx, dataset)
+		if err != nil {
+			return nil, err
+		}
+		metadata[metadataDataset] = dataset
+		metadata[metadataDatasetHash] = hash
+	}
+	scores := map[string]float64{}
+	for _, metric := range job.FinalMetricDataList {
+		scores[awssdk.ToString(metric.MetricName)] = float64(awssdk.ToFloat32(metric.Value))
+	}
+	for name, value := range evalScores {
+		scores[name] = value
+	}
+	for name, value := range scores {
+		metadata[metadataEvalPrefix+name] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	if err := c.ensureModelPackageGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	if instanceType == "" {
+		instanceType = DefaultInferenceInstanceType
+	}
+	gpus := inferenceGPUs[instanceType]
+	if gpus == 0 {
+		gpus = 1
+	}
+	resp, err := c.SageMaker.CreateModelPackage(ctx, &sagemaker.CreateModelPackageInput{
+		ModelPackageGroupName:   awssdk.String(group),
+		ModelPackageDescription: awssdk.String("Fine-tuned by training job " + jobName),
+		ModelApprovalStatus:     sagemakertypes.ModelApprovalStatusApproved,
+		InferenceSpecification: &sagemakertypes.InferenceSpecification{
+			Containers: []sagemakertypes.ModelPackageContainerDefinition{{
+				Image:        awssdk.String(fmt.Sprintf(tgiInferenceImage, c.SageMaker.Options().Region)),
+				ModelDataUrl: job.ModelArtifacts.S3ModelArtifacts,
+				Environment: map[string]string{
+					"HF_MODEL_ID":      "/opt/ml/model",
+					"SM_NUM_GPUS":      strconv.Itoa(gpus),
+					"MAX_INPUT_LENGTH": "3072",
+					"MAX_TOTAL_TOKENS": "4096",
+				},
+			}},
+			SupportedContentTypes:                   []string{"application/json"},
+			SupportedResponseMIMETypes:              []string{"application/json"},
+			SupportedRealtimeInferenceInstanceTypes: []sagemakertypes.ProductionVariantInstanceType{sagemakertypes.ProductionVariantInstanceType(instanceType)},
+		},
+		CustomerMetadataProperties: metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not register training job %s in %s: %w", jobName, group, err)
+	}
+	return c.describeModelVersion(ctx, awssdk.ToString(resp.ModelPackageArn))
+}
+
+// ListModelVersions returns the versions registered in a group, newest
+// first. Versions deployed to the endpoint, if one is given, are marked.
+func (c *Client) ListModelVersions(ctx context.Context, group, endpoint string) ([]ModelVersion, error) {
+	var deployed string
+	if endpoint != "" {
+		var err error
+		if deployed, err = c.deployedModelPackage(ctx, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	var versions []ModelVersion
+	paginator := sagemaker.NewListModelPackagesPaginator(c.SageMaker, &sagemaker.ListModelPackagesInput{
+		ModelPackageGroupName: awssdk.String(group),
+		ModelPackageType:      sagemakertypes.ModelPackageTypeVersioned,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not list the versions in %s: %w", group, err)
+		}
+		for _, summary := range page.ModelPackageSummaryList {
+			// the list has no metadata, so every version is described
+			version, err := c.describeModelVersion(ctx, awssdk.ToString(summary.ModelPackageArn))
+			if err != nil {
+				return nil, err
+			}
+			version.Deployed = version.ARN == deployed
+			versions = append(versions, *version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, nil
+}
+
+// DeployModelVersion serves a registered version on a real-time endpoint,
+// creating it or switching an existing one over, which SageMaker does
+// without downtime. Deploying an earlier version is how a rollback is done.
+// A version pending approval is approved, a rejected one refused. The model
+// runs with the execution role. The client must be created WithWrites.
+func (c *Client) DeployModelVersion(ctx context.Context, version *ModelVersion, endpoint, roleARN, instanceType string) (*Deployment, error) {
+	switch sagemakertypes.ModelApprovalStatus(version.Approval) {
+	case sagemakertypes.ModelApprovalStatusRejected:
+		return nil, fmt.Errorf("version %d is rejected and cannot be deployed", version.Version)
+	case sagemakertypes.ModelApprovalStatusPendingManualApproval:
+		if _, err := c.SageMaker.UpdateModelPackage(ctx, &sagemaker.UpdateModelPackageInput{
+			ModelPackageArn:     awssdk.String(version.ARN),
+			ModelApprovalStatus: sagemakertypes.ModelApprovalStatusApproved,
+			ApprovalDescription: awssdk.String("Approved by cloudai sagemaker deploy"),
+		}); err != nil {
+			return nil, fmt.Errorf("could not approve version %d: %w", version.Version, err)
+		}
+	}
+	if instanceType == "" {
+		instanceType = version.InstanceType
+	}
+	if instanceType == "" {
+		instanceType = DefaultInferenceInstanceType
+	}
+
+	// names must be unique, so redeploying a version gets new ones
+	suffix := fmt.Sprintf("v%d-%s", version.Version, time.Now().UTC().Format("20060102T150405"))
+	deployment := &Deployment{
+		Endpoint:       endpoint,
+		Model:          endpoint + "-" + suffix,
+		EndpointConfig: endpoint + "-" + suffix,
+		Version:        version.Version,
+		InstanceType:   instanceType,
+		Region:         c.SageMaker.Options().Region,
+	}
+	tags := []sagemakertypes.Tag{
+		{Key: awssdk.String("managed-by"), Value: awssdk.String("cloudai")},
+		{Key: awssdk.String("cloudai:model-version"), Value: awssdk.String(strconv.Itoa(int(version.Version)))},
+	}
+	if _, err := c.SageMaker.CreateModel(ctx, &sagemaker.CreateModelInput{
+		ModelName:        awssdk.String(deployment.Model),
+		ExecutionRoleArn: awssdk.String(roleARN),
+		PrimaryContainer: &sagemakertypes.ContainerDefinition{ModelPackageName: awssdk.String(version.ARN)},
+		Tags:             tags,
+	}); err != nil {
+		return nil, fmt.Errorf("could not create model %s: %w", deployment.Model, err)
+	}
+	if _, err := c.SageMaker.CreateEndpointConfig(ctx, &sagemaker.CreateEndpointConfigInput{
+		EndpointConfigName: awssdk.String(deployment.EndpointConfig),
+		ProductionVariants: []sagemakertypes.ProductionVariant{{
+			VariantName:          awssdk.String("AllTraffic"),
+			ModelName:            awssdk.String(deployment.Model),
+			InstanceType:         sagemakertypes.ProductionVariantInstanceType(instanceType),
+			InitialInstanceCount: awssdk.Int32(1),
+			// TGI downloads and loads the weights before it answers
+			ContainerStartupHealthCheckTimeoutInSeconds: awssdk.Int32(900),
+		}},
+		Tags: tags,
+	}); err != nil {
+		return nil, fmt.Errorf("could not create endpoint config %s: %w", deployment.EndpointConfig, err)
+	}
+
+	_, err := c.SageMaker.DescribeEndpoint(ctx, &sagemaker.DescribeEndpointInput{EndpointName: awssdk.String(endpoint)})
+	switch {
+	case err == nil:
+		_, err = c.SageMaker.UpdateEndpoint(ctx, &sagemaker.UpdateEndpointInput{
+			EndpointName:       awssdk.String(endpoint),
+			EndpointConfigName: awssdk.String(deployment.EndpointConfig),
+		})
+	case isSageMakerNotFound(err):
+		deployment.Created = true
+		_, err = c.SageMaker.CreateEndpoint(ctx, &sagemaker.CreateEndpointInput{
+			EndpointName:       awssdk.String(endpoint),
+			EndpointConfigName: awssdk.String(deployment.EndpointConfig),
+			Tags:               tags,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not deploy version %d to endpoint %s: %w", version.Version, endpoint, err)
+	}
+	return deployment, nil
+}
+
+// WaitForEndpoint waits until an endpoint being created or updated is in
+// service.
+func (c *Client) WaitForEndpoint(ctx context.Context, endpoint string, timeout time.Duration) error {
+	waiter := sagemaker.NewEndpointInServiceWaiter(c.SageMaker)
+	if err := waiter.Wait(ctx, &sagemaker.DescribeEndpointInput{EndpointName: awssdk.String(endpoint)}, timeout); err != nil {
+		return fmt.Errorf("endpoint %s did not come into service: %w", endpoint, err)
+	}
+	return nil
+}
+
+// describeModelVersion reads a registered version and its metadata.
+func (c *Client) describeModelVersion(ctx context.Context, arn string) (*ModelVersion, error) {
+	resp, err := c.SageMaker.DescribeModelPackage(ctx, &sagemaker.DescribeModelPackageInput{ModelPackageName: awssdk.String(arn)})
+	if err != nil {
+		return nil, fmt.Errorf("could not describe model package %s: %w", arn, err)
+	}
+	metadata := resp.CustomerMetadataProperties
+	version := &ModelVersion{
+		Version:     awssdk.ToInt32(resp.ModelPackageVersion),
+		ARN:         arn,
+		Approval:    string(resp.ModelApprovalStatus),
+		Created:     awssdk.ToTime(resp.CreationTime),
+		TrainingJob: metadata[metadataTrainingJob],
+		BaseModel:   metadata[metadataBaseModel],
+		Dataset:     metadata[metadataDataset],
+		DatasetHash: metadata[metadataDatasetHash],
+	}
+	for key, value := range metadata {
+		name, ok := strings.CutPrefix(key, metadataEvalPrefix)
+		if !ok {
+			continue
+		}
+		if score, err := strconv.ParseFloat(value, 64); err == nil {
+			if version.EvalScores == nil {
+				version.EvalScores = map[string]float64{}
+			}
+			version.EvalScores[name] = score
+		}
+	}
+	if spec := resp.InferenceSpecification; spec != nil {
+		if len(spec.Containers) > 0 {
+			version.ModelData = awssdk.ToString(spec.Containers[0].ModelDataUrl)
+		}
+		if len(spec.SupportedRealtimeInferenceInstanceTypes) > 0 {
+			version.InstanceType = string(spec.SupportedRealtimeInferenceInstanceTypes[0])
+		}
+	}
+	return version, nil
+}
+
+// deployedModelPackage returns the ARN of the model package an endpoint
+// serves, or "" if the endpoint does not exist or serves something else.
+func (c *Client) deployedModelPackage(ctx context.Context, endpoint string) (string, error) {
+	resp, err := c.SageMaker.DescribeEndpoint(ctx, &sagemaker.DescribeEndpointInput{EndpointName: awssdk.String(endpoint)})
+	if isSageMakerNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not describe endpoint %s: %w", endpoint, err)
+	}
+	config, err := c.SageMaker.DescribeEndpointConfig(ctx, &sagemaker.DescribeEndpointConfigInput{EndpointConfigName: resp.EndpointConfigName})
+	if err != nil {
+		return "", fmt.Errorf("could not describe the config of endpoint %s: %w", endpoint, err)
+	}
+	if len(config.ProductionVariants) == 0 {
+		return "", nil
+	}
+	model, err := c.SageMaker.DescribeModel(ctx, &sagemaker.DescribeModelInput{ModelName: config.ProductionVariants[0].ModelName})
+	if err != nil {
+		return "", fmt.Errorf("could not describe the model of endpoint %s: %w", endpoint, err)
+	}
+	if model.PrimaryContainer == nil {
+		return "", nil
+	}
+	return awssdk.ToString(model.PrimaryContainer.ModelPackageName), nil
+}
+
+// ensureModelPackageGroup creates the registry group unless it exists.
+func (c *Client) ensureModelPackageGroup(ctx context.Context, group string) error {
+	_, err := c.SageMaker.DescribeModelPackageGroup(ctx, &sagemaker.DescribeModelPackageGroupInput{ModelPackageGroupName: awssdk.String(group)})
+	if err == nil {
+		return nil
+	}
+	if !isSageMakerNotFound(err) {
+		return fmt.Errorf("could not look up model package group %s: %w", group, err)
+	}
+	if _, err := c.SageMaker.CreateModelPackageGroup(ctx, &sagemaker.CreateModelPackageGroupInput{
+		ModelPackageGroupName:        awssdk.String(group),
+		ModelPackageGroupDescription: awssdk.String("LoRA fine-tunes of open LLMs on architecture Q&A, served by TGI"),
+	}); err != nil {
+		return fmt.Errorf("could not create model package group %s: %w", group, err)
+	}
+	return nil
+}
+
+// datasetHash returns a SHA-256 over the objects under an S3 prefix, in key
+// order, identifying the exact data a model was trained on.
+func (c *Client) datasetHash(ctx context.Context, uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "s3" {
+		return "", fmt.Errorf("training data %s is not an S3 URI", uri)
+	}
+	bucket, prefix := parsed.Host, strings.TrimPrefix(parsed.Path, "/")
+
+	hash := sha256.New()
+	// ListObjectsV2 returns keys in order, so the hash is stable
+	paginator := s3.NewListObjectsV2Paginator(c.S3, &s3.ListObjectsV2Input{Bucket: awssdk.String(bucket), Prefix: awssdk.String(prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not list training data at %s: %w", uri, err)
+		}
+		for _, object := range page.Contents {
+			resp, err := c.S3.GetObject(ctx, &s3.GetObjectInput{Bucket: awssdk.String(bucket), Key: object.Key})
+			if err != nil {
+				return "", fmt.Errorf("could not read training data s3://%s/%s: %w", bucket, awssdk.ToString(object.Key), err)
+			}
+			_, err = io.Copy(hash, resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return "", fmt.Errorf("could not read training data s3://%s/%s: %w", bucket, awssdk.ToString(object.Key), err)
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// trainingDataURI returns the S3 URI of a job's train channel, or of its
+// only channel.
+func trainingDataURI(channels []sagemakertypes.Channel) string {
+	for _, channel := range channels {
+		if awssdk.ToString(channel.ChannelName) == "train" || len(channels) == 1 {
+			if channel.DataSource != nil && channel.DataSource.S3DataSource != nil {
+				return awssdk.ToString(channel.DataSource.S3DataSource.S3Uri)
+			}
+		}
+	}
+	return ""
+}
+
+// isSageMakerNotFound reports whether SageMaker said a resource does not
+// exist, which most of its APIs signal with a ValidationException.
+func isSageMakerNotFound(err error) bool {
+	if HasErrorCode(err, "ResourceNotFound") {
+		return true
+	}
+	if !HasErrorCode(err, "ValidationException") {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "could not find") || strings.Contains(message, "does not exist")
+}
@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+const (
+	// maxSNSSubject is the longest subject SNS accepts for email endpoints
+	maxSNSSubject = 100
+	// maxSNSMessage is the largest message SNS publishes, in bytes
+	maxSNSMessage = 256 * 1024
+)
+
+// PublishMessage publishes a message to an SNS topic. Subjects are cut to
+// what SNS accepts and messages that are too large are refused.
+func (c *Client) PublishMessage(ctx context.Context, topicARN, subject, message string) error {
+	if len(message) > maxSNSMessage {
+		return fmt.Errorf("message of %d bytes is larger than the %d bytes SNS publishes", len(message), maxSNSMessage)
+	}
+	if runes := []rune(subject); len(runes) > maxSNSSubject {
+		subject = string(runes[:maxSNSSubject])
+	}
+	_, err := c.SNS.Publish(ctx, &sns.PublishInput{
+		TopicArn: awssdk.String(topicARN),
+		Subject:  awssdk.String(subject),
+		Message:  awssdk.String(message),
+	})
+	if err != nil {
+		return fmt.Errorf("could not publish to %s: %w", topicARN, err)
+	}
+	return nil
+}
+
+// SendEmail sends a plain-text email through SES. The sender must be a
+// verified identity, and while the account is in the SES sandbox so must
+// the recipients.
+func (c *Client) SendEmail(ctx context.Context, from string, to []string, subject, body string) error {
+	_, err := c.SES.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: awssdk.String(from),
+		Destination:      &sestypes.Destination{ToAddresses: to},
+		Content: &sestypes.EmailContent{Simple: &sestypes.Message{
+			Subject: &sestypes.Content{Data: awssdk.String(subject), Charset: awssdk.String("UTF-8")},
+			Body:    &sestypes.Body{Text: &sestypes.Content{Data: awssdk.String(body), Charset: awssdk.String("UTF-8")}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not email %v: %w", to, err)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	athenatypes "github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// queryPollInterval is how often a running query's status is checked
+const queryPollInterval = time.Second
+
+// MaxQueryRows caps the rows fetched from a query; Logs Insights and
+// Athena both return at most 1000 per request
+const MaxQueryRows = 1000
+
+// QueryResult is what a Logs Insights or Athena query returned.
+type QueryResult struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+	// Truncated is set when the query had more rows than were fetched
+	Truncated    bool  `json:"truncated,omitempty"`
+	ScannedBytes int64 `json:"scanned_bytes"`
+}
+
+// AthenaTarget is where an Athena query runs and writes its results. The
+// output location may be empty when the workgroup sets one.
+type AthenaTarget struct {
+	Catalog        string `json:"catalog"`
+	Database       string `json:"database"`
+	WorkGroup      string `json:"workgroup"`
+	OutputLocation string `json:"output_location,omitempty"`
+}
+
+// ListLogGroups returns the names of up to limit log groups.
+func (c *Client) ListLogGroups(ctx context.Context, limit int) ([]string, error) {
+	var names []string
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(c.Logs, &cloudwatchlogs.DescribeLogGroupsInput{})
+	for paginator.HasMorePages() && len(names) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return names, fmt.Errorf("could not list log groups: %w", err)
+		}
+		for _, group := range page.LogGroups {
+			names = append(names, awssdk.ToString(group.LogGroupName))
+		}
+	}
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	return names, nil
+}
+
+// RunLogsInsightsQuery runs a Logs Insights query over the given log groups
+// and time range and waits for its results. The query is stopped if ctx ends
+// first.
+func (c *Client) RunLogsInsightsQuery(ctx context.Context, groups []string, query string, start, end time.Time) (*QueryResult, error) {
+	started, err := c.Logs.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
+		LogGroupNames: groups,
+		QueryString:   awssdk.String(query),
+		StartTime:     awssdk.Int64(start.Unix()),
+		EndTime:       awssdk.Int64(end.Unix()),
+		Limit:         awssdk.Int32(MaxQueryRows),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not start the Logs Insights query: %w", err)
+	}
+	id := started.QueryId
+
+	for {
+		resp, err := c.Logs.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{QueryId: id})
+		if err != nil {
+			c.stopLogsQuery(id)
+			return nil, fmt.Errorf("could not read the Logs Insights results: %w", err)
+		}
+		switch resp.Status {
+		case logstypes.QueryStatusComplete:
+			return logsQueryResult(resp), nil
+		case logstypes.QueryStatusFailed, logstypes.QueryStatusCancelled, logstypes.QueryStatusTimeout:
+			return nil, fmt.Errorf("the Logs Insights query ended with status %s", resp.Status)
+		}
+		select {
+		case <-ctx.Done():
+			c.stopLogsQuery(id)
+			return nil, ctx.Err()
+		case <-time.After(queryPollInterval):
+		}
+	}
+}
+
+// stopLogsQuery cancels a query that is no longer waited for, so it does not
+// keep scanning, and billing, in the background.
+func (c *Client) stopLogsQuery(id *string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.Logs.StopQuery(ctx, &cloudwatchlogs.StopQueryInput{QueryId: id})
+}
+
+// logsQueryResult turns Logs Insights rows, lists of field/value pairs, into
+// a table. Columns are in the order fields first appear; @ptr, which only
+// identifies the log event, is left out.
+func logsQueryResult(resp *cloudwatchlogs.GetQueryResultsOutput) *QueryResult {
+	result := &QueryResult{}
+	if resp.Statistics != nil {
+		result.ScannedBytes = int64(resp.Statistics.BytesScanned)
+		result.Truncated = resp.Statistics.RecordsMatched > float64(len(resp.Results)) && len(resp.Results) >= MaxQueryRows
+	}
+	index := map[string]int{}
+	for _, row := range resp.Results {
+		for _, field := range row {
+			name := awssdk.ToString(field.Field)
+			if _, ok := index[name]; !ok && name != "@ptr" {
+				index[name] = len(result.Columns)
+				result.Columns = append(result.Columns, name)
+			}
+		}
+	}
+	for _, row := range resp.Results {
+		values := make([]string, len(result.Columns))
+		for _, field := range row {
+			if i, ok := index[awssdk.ToString(field.Field)]; ok {
+				values[i] = awssdk.ToString(field.Value)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result
+}
+
+// AthenaTableColumns returns the columns of a table as "name type", the
+// partition keys last.
+func (c *Client) AthenaTableColumns(ctx context.Context, target AthenaTarget, table string) ([]string, error) {
+	resp, err := c.Athena.GetTableMetadata(ctx, &athena.GetTableMetadataInput{
+		CatalogName:  awssdk.String(target.Catalog),
+		DatabaseName: awssdk.String(target.Database),
+		TableName:    awssdk.String(table),
+		WorkGroup:    awssdk.String(target.WorkGroup),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not describe Athena table %s.%s: %w", target.Database, table, err)
+	}
+	var columns []string
+	for _, column := range append(resp.TableMetadata.Columns, resp.TableMetadata.PartitionKeys...) {
+		columns = append(columns, awssdk.ToString(column.Name)+" "+awssdk.ToString(column.Type))
+	}
+	return columns, nil
+}
+
+// RunAthenaQuery runs a read-only SQL query and waits for up to MaxQueryRows
+// of its results. Statements other than queries are refused before anything
+// is sent; the query is stopped if ctx ends first.
+func (c *Client) RunAthenaQuery(ctx context.Context, sql string, target AthenaTarget) (*QueryResult, error) {
+	if err := CheckReadOnlySQL(sql); err != nil {
+		return nil, err
+	}
+	input := &athena.StartQueryExecutionInput{
+		QueryString: awssdk.String(sql),
+		QueryExecutionContext: &athenatypes.QueryExecutionContext{
+			Catalog:  awssdk.String(target.Catalog),
+			Database: awssdk.String(target.Database),
+		},
+		WorkGroup: awssdk.String(target.WorkGroup),
+	}
+	if target.OutputLocation != "" {
+		input.ResultConfiguration = &athenatypes.ResultConfiguration{OutputLocation: awssdk.String(target.OutputLocation)}
+	}
+	started, err := c.Athena.StartQueryExecution(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("could not start the Athena query: %w", err)
+	}
+	id := started.QueryExecutionId
+
+	result := &QueryResult{}
+	for {
+		resp, err := c.Athena.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{QueryExecutionId: id})
+		if err != nil {
+			c.stopAthenaQuery(id)
+			return nil, fmt.Errorf("could not check the Athena query: %w", err)
+		}
+		execution := resp.QueryExecution
+		if execution.Statistics != nil {
+			result.ScannedBytes = awssdk.ToInt64(execution.Statistics.DataScannedInBytes)
+		}
+		state := athenatypes.QueryExecutionState("")
+		if execution.Status != nil {
+			state = execution.Status.State
+		}
+		if state == athenatypes.QueryExecutionStateSucceeded {
+			break
+		}
+		if state == athenatypes.QueryExecutionStateFailed || state == athenatypes.QueryExecutionStateCancelled {
+			return nil, fmt.Errorf("the Athena query ended with state %s: %s", state, awssdk.ToString(execution.Status.StateChangeReason))
+		}
+		select {
+		case <-ctx.Done():
+			c.stopAthenaQuery(id)
+			return nil, ctx.Err()
+		case <-time.After(queryPollInterval):
+		}
+	}
+
+	resp, err := c.Athena.GetQueryResults(ctx, &athena.GetQueryResultsInput{
+		QueryExecutionId: id,
+		MaxResults:       awssdk.Int32(MaxQueryRows),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read the Athena results: %w", err)
+	}
+	result.Truncated = resp.NextToken != nil
+	if resp.ResultSet == nil {
+		return result, nil
+	}
+	if resp.ResultSet.ResultSetMetadata != nil {
+		for _, column := range resp.ResultSet.ResultSetMetadata.ColumnInfo {
+			result.Columns = append(result.Columns, awssdk.ToString(column.Name))
+		}
+	}
+	for i, row := range resp.ResultSet.Rows {
+		values := make([]string, len(row.Data))
+		for j, datum := range row.Data {
+			values[j] = awssdk.ToString(datum.VarCharValue)
+		}
+		// the first row of a SELECT repeats the column names
+		if i == 0 && strings.Join(values, "\x00") == strings.Join(result.Columns, "\x00") {
+			continue
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, nil
+}
+
+// stopAthenaQuery cancels a query that is no longer waited for.
+func (c *Client) stopAthenaQuery(id *string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.Athena.StopQueryExecution(ctx, &athena.StopQueryExecutionInput{QueryExecutionId: id})
+}
+
+var (
+	// sqlComments are -- line and /* block */ comments
+	sqlComments = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+	// sqlLiterals are quoted strings and identifiers, whose contents are not
+	// keywords
+	sqlLiterals = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"`)
+	// sqlWrites are the keywords of statements that change data or metadata
+	sqlWrites = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|create|drop|alter|truncate|unload|msck|grant|revoke|vacuum|optimize|call|execute|prepare|deallocate|set|reset)\b`)
+)
+
+// CheckReadOnlySQL returns an error unless sql is a single SELECT, WITH,
+// SHOW, DESCRIBE or EXPLAIN statement with no keyword of a statement that
+// writes. It errs on the side of refusing: a query that only mentions such a
+// keyword as a bare column name is refused too.
+func CheckReadOnlySQL(sql string) error {
+	stripped := sqlLiterals.ReplaceAllString(sqlComments.ReplaceAllString(sql, " "), "''")
+	stripped = strings.TrimSpace(stripped)
+	stripped = strings.TrimSpace(strings.TrimSuffix(stripped, ";"))
+	if stripped == "" {
+		return fmt.Errorf("the query is empty")
+	}
+	if strings.Contains(stripped, ";") {
+		return fmt.Errorf("only a single statement can be run")
+	}
+	first := strings.ToUpper(strings.Fields(strings.TrimLeft(stripped, "( ") + " ")[0])
+	switch first {
+	case "SELECT", "WITH", "SHOW", "DESCRIBE", "EXPLAIN":
+	default:
+		return fmt.Errorf("only queries can be run, not %s statements", first)
+	}
+	if keyword := sqlWrites.FindString(stripped); keyword != "" {
+		return fmt.Errorf("the query contains %s, which could change data; only read-only queries are run", strings.ToUpper(keyword))
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+)
+
+// quotaUsageWindow is how far back a usage metric is read; the highest
+// value in it is taken as the current usage
+const quotaUsageWindow = time.Hour
+
+// QuotaRef names a Service Quotas limit by service and quota code.
+type QuotaRef struct {
+	Service string `json:"service"`
+	Code    string `json:"code"`
+}
+
+// Quotas checked by 'cloudai quotas' and the EC2 Ollama setup
+var (
+	LambdaConcurrency = QuotaRef{Service: "lambda", Code: "L-B99A9384"}
+	ElasticIPs        = QuotaRef{Service: "ec2", Code: "L-0263D0A3"}
+	NetworkInterfaces = QuotaRef{Service: "vpc", Code: "L-DF5E4CA3"}
+	StandardVCPUs     = QuotaRef{Service: "ec2", Code: "L-1216C47A"}
+	GPUVCPUs          = QuotaRef{Service: "ec2", Code: "L-DB2E81BA"}
+	GPUSpotVCPUs      = QuotaRef{Service: "ec2", Code: "L-3819A6DF"}
+)
+
+// KeyQuotas are the limits a growing account runs into first.
+var KeyQuotas = []QuotaRef{LambdaConcurrency, ElasticIPs, NetworkInterfaces, StandardVCPUs, GPUVCPUs}
+
+// ParseQuotaRef parses "service:code", such as "ec2:L-1216C47A".
+func ParseQuotaRef(value string) (QuotaRef, error) {
+	service, code, ok := strings.Cut(value, ":")
+	if !ok || service == "" || !strings.HasPrefix(code, "L-") {
+		return QuotaRef{}, fmt.Errorf("quota %q must be service:code, such as ec2:L-1216C47A", value)
+	}
+	return QuotaRef{Service: service, Code: code}, nil
+}
+
+// QuotaUsage is a quota's applied value and how much of it is in use.
+type QuotaUsage struct {
+	QuotaRef
+	Name       string  `json:"name"`
+	Limit      float64 `json:"limit"`
+	Unit       string  `json:"unit,omitempty"`
+	Adjustable bool    `json:"adjustable"`
+	// Usage is only set when UsageKnown: not every quota publishes a usage
+	// metric or has a resource count to stand in for one
+	Usage      float64 `json:"usage"`
+	UsageKnown bool    `json:"usage_known"`
+}
+
+// Utilization is the share of the limit in use, in percent, or -1 when the
+// usage is unknown.
+func (q *QuotaUsage) Utilization() float64 {
+	if !q.UsageKnown {
+		return -1
+	}
+	if q.Limit <= 0 {
+		if q.Usage > 0 {
+			return 100
+		}
+		return 0
+	}
+	return q.Usage / q.Limit * 100
+}
+
+// Available is how much of the limit is left, or the whole limit when the
+// usage is unknown.
+func (q *QuotaUsage) Available() float64 {
+	if q.Usage >= q.Limit {
+		return 0
+	}
+	return q.Limit - q.Usage
+}
+
+// QuotaUsage returns the applied value of a quota, falling back to the AWS
+// default for quotas that were never adjusted, and its current usage: the
+// highest value of the usage metric Service Quotas names for it over the
+// last hour, or a count of the resources for Elastic IPs and network
+// interfaces, which publish no metric.
+func (c *Client) QuotaUsage(ctx context.Context, ref QuotaRef) (*QuotaUsage, error) {
+	var quota *sqtypes.ServiceQuota
+	resp, err := c.ServiceQuotas.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: awssdk.String(ref.Service),
+		QuotaCode:   awssdk.String(ref.Code),
+	})
+	if HasErrorCode(err, "NoSuchResourceException") {
+		defaults, defaultErr := c.ServiceQuotas.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+			ServiceCode: awssdk.String(ref.Service),
+			QuotaCode:   awssdk.String(ref.Code),
+		})
+		if defaultErr != nil {
+			return nil, fmt.Errorf("could not get quota %s:%s: %w", ref.Service, ref.Code, defaultErr)
+		}
+		quota = defaults.Quota
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get quota %s:%s: %w", ref.Service, ref.Code, err)
+	} else {
+		quota = resp.Quota
+	}
+
+	usage := &QuotaUsage{
+		QuotaRef:   ref,
+		Name:       awssdk.ToString(quota.QuotaName),
+		Limit:      awssdk.ToFloat64(quota.Value),
+		Unit:       awssdk.ToString(quota.Unit),
+		Adjustable: quota.Adjustable,
+	}
+	switch ref {
+	case ElasticIPs:
+		usage.Usage, err = c.countAddresses(ctx)
+		usage.UsageKnown = err == nil
+	case NetworkInterfaces:
+		usage.Usage, err = c.countNetworkInterfaces(ctx)
+		usage.UsageKnown = err == nil
+	default:
+		if quota.UsageMetric != nil {
+			usage.Usage, usage.UsageKnown, err = c.quotaMetricUsage(ctx, quota.UsageMetric)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get the usage of %s: %w", usage.Name, err)
+	}
+	return usage, nil
+}
+
+func (c *Client) quotaMetricUsage(ctx context.Context, metric *sqtypes.MetricInfo) (float64, bool, error) {
+	statistic := cwtypes.Statistic(awssdk.ToString(metric.MetricStatisticRecommendation))
+	if statistic == "" {
+		statistic = cwtypes.StatisticMaximum
+	}
+	var dimensions []cwtypes.Dimension
+	for name, value := range metric.MetricDimensions {
+		dimensions = append(dimensions, cwtypes.Dimension{Name: awssdk.String(name), Value: awssdk.String(value)})
+	}
+	end := time.Now()
+	resp, err := c.CloudWatch.GetMetricStatistics(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  metric.MetricNamespace,
+		MetricName: metric.MetricName,
+		Dimensions: dimensions,
+		StartTime:  awssdk.Time(end.Add(-quotaUsageWindow)),
+		EndTime:    awssdk.Time(end),
+		Period:     awssdk.Int32(300),
+		Statistics: []cwtypes.Statistic{statistic},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if len(resp.Datapoints) == 0 {
+		// usage metrics are only published while something is in use
+		return 0, true, nil
+	}
+	highest := 0.0
+	for _, point := range resp.Datapoints {
+		var value *float64
+		switch statistic {
+		case cwtypes.StatisticSum:
+			value = point.Sum
+		case cwtypes.StatisticAverage:
+			value = point.Average
+		case cwtypes.StatisticSampleCount:
+			value = point.SampleCount
+		case cwtypes.StatisticMinimum:
+			value = point.Minimum
+		default:
+			value = point.Maximum
+		}
+		highest = max(highest, awssdk.ToFloat64(value))
+	}
+	return highest, true, nil
+}
+
+func (c *Client) countAddresses(ctx context.Context) (float64, error) {
+	resp, err := c.EC2.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []ec2types.Filter{{Name: awssdk.String("domain"), Values: []string{"vpc"}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return float64(len(resp.Addresses)), nil
+}
+
+func (c *Client) countNetworkInterfaces(ctx context.Context) (float64, error) {
+	count := 0
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(c.EC2, &ec2.DescribeNetworkInterfacesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		count += len(page.NetworkInterfaces)
+	}
+	return float64(count), nil
+}
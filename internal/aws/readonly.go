@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// readOnlyPrefixes are operation name prefixes that never modify resources.
+var readOnlyPrefixes = []string{"Get", "List", "Describe", "Head", "Lookup", "Search", "BatchGet"}
+
+// readOnlyOperations are read-only operations that don't follow the prefix
+// convention.
+var readOnlyOperations = map[string]bool{
+	"SimulatePrincipalPolicy": true, // IAM: evaluates policies, changes nothing
+	"SimulateCustomPolicy":    true,
+	"GenerateDataKey":         true, // KMS: cache encryption, no resource changes
+	"Decrypt":                 true,
+	// Config: SQL queries over the recorded resource inventory
+	"SelectResourceConfig":          true,
+	"SelectAggregateResourceConfig": true,
+	"FilterLogEvents":               true, // CloudWatch Logs: reads log events
+	// Logs Insights queries only read, and stopping one ends only a read
+	"StartQuery":         true,
+	"StopQuery":          true,
+	"StopQueryExecution": true, // Athena
+}
+
+// readOnlyRequests are operations that only read for some inputs: the check
+// is run on the operation's input.
+var readOnlyRequests = map[string]func(interface{}) bool{
+	// Athena runs any SQL; only queries are let through
+	"StartQueryExecution": func(input interface{}) bool {
+		in, ok := input.(*athena.StartQueryExecutionInput)
+		return ok && CheckReadOnlySQL(awssdk.ToString(in.QueryString)) == nil
+	},
+}
+
+// MutationBlockedError is returned when a read-only client is asked to call
+// an operation that could modify infrastructure.
+type MutationBlockedError struct {
+	Service   string
+	Operation string
+}
+
+func (e *MutationBlockedError) Error() string {
+	return fmt.Sprintf("blocked %s:%s: CloudAI-CLI runs in read-only mode and never modifies infrastructure", e.Service, e.Operation)
+}
+
+// IsReadOnlyOperation reports whether an AWS API operation only reads state.
+func IsReadOnlyOperation(operation string) bool {
+	if readOnlyOperations[operation] {
+		return true
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addReadOnlyGuard registers middleware that rejects every non-read operation
+// before it is signed or sent. It runs at the end of the initialize step, once
+// the operation name has been set.
+func addReadOnlyGuard(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CloudAIReadOnlyGuard",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			if check, ok := readOnlyRequests[operation]; ok && check(in.Parameters) {
+				return next.HandleInitialize(ctx, in)
+			}
+			if !IsReadOnlyOperation(operation) {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, &MutationBlockedError{
+					Service:   awsmiddleware.GetServiceID(ctx),
+					Operation: operation,
+				}
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.After)
+}
@@ -0,0 +1,209 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DefaultTrainingRoleName is the execution role 'cloudai train bootstrap-role'
+// creates unless told otherwise.
+const DefaultTrainingRoleName = "cloudai-sagemaker-training"
+
+// trainingPolicyName is the inline policy that scopes the role to its bucket
+const trainingPolicyName = "cloudai-training"
+
+// sageMakerTrustPolicy lets SageMaker training jobs assume the role
+const sageMakerTrustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Effect": "Allow",
+    "Principal": {"Service": "sagemaker.amazonaws.com"},
+    "Action": "sts:AssumeRole"
+  }]
+}`
+
+// TrainingResources are the execution role and bucket SageMaker training
+// jobs for the architecture model run with.
+type TrainingResources struct {
+	RoleARN       string `json:"role_arn"`
+	RoleCreated   bool   `json:"role_created"`
+	Bucket        string `json:"bucket"`
+	BucketCreated bool   `json:"bucket_created"`
+	Region        string `json:"region"`
+}
+
+// DefaultTrainingBucket names the training bucket of an account and region;
+// bucket names are global, so both are part of it.
+func DefaultTrainingBucket(account, region string) string {
+	return fmt.Sprintf("cloudai-training-%s-%s", account, region)
+}
+
+// TrainingRolePolicy is the inline policy of the training role: read and
+// write in its bucket, pull the AWS deep learning containers, write logs
+// and metrics, and read Hugging Face tokens stored as cloudai-* secrets.
+func TrainingRolePolicy(bucket string) string {
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":      "TrainingBucket",
+				"Effect":   "Allow",
+				"Action":   []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload"},
+				"Resource": "arn:aws:s3:::" + bucket + "/*",
+			},
+			{
+				"Sid":      "ListTrainingBucket",
+				"Effect":   "Allow",
+				"Action":   []string{"s3:ListBucket", "s3:GetBucketLocation"},
+				"Resource": "arn:aws:s3:::" + bucket,
+			},
+			{
+				"Sid":      "PullContainers",
+				"Effect":   "Allow",
+				"Action":   []string{"ecr:GetAuthorizationToken", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer", "ecr:BatchCheckLayerAvailability"},
+				"Resource": "*",
+			},
+			{
+				"Sid":      "Logs",
+				"Effect":   "Allow",
+				"Action":   []string{"logs:CreateLogGroup", "logs:CreateLogStream", "logs:PutLogEvents", "logs:DescribeLogStreams"},
+				"Resource": "arn:aws:logs:*:*:log-group:/aws/sagemaker/*",
+			},
+			{
+				"Sid":       "Metrics",
+				"Effect":    "Allow",
+				"Action":    "cloudwatch:PutMetricData",
+				"Resource":  "*",
+				"Condition": map[string]interface{}{"StringLike": map[string]string{"cloudwatch:namespace": "/aws/sagemaker/*"}},
+			},
+			{
+				"Sid":      "HuggingFaceToken",
+				"Effect":   "Allow",
+				"Action":   "secretsmanager:GetSecretValue",
+				"Resource": "arn:aws:secretsmanager:*:*:secret:cloudai-*",
+			},
+		},
+	}
+	b, _ := json.MarshalIndent(policy, "", "  ")
+	return string(b)
+}
+
+// BootstrapTraining locates or creates the training bucket and execution
+// role. An existing role keeps its trust policy but gets the inline policy
+// (re)applied, so running it again fixes a role scoped to another bucket.
+// An empty bucket name uses DefaultTrainingBucket. The client must be
+// created WithWrites.
+func (c *Client) BootstrapTraining(ctx context.Context, roleName, bucket string) (*TrainingResources, error) {
+	identity, err := c.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("could not identify the account: %w", err)
+	}
+	account := awssdk.ToString(identity.Account)
+	resources := &TrainingResources{Region: c.S3.Options().Region, Bucket: bucket}
+	if resources.Bucket == "" {
+		resources.Bucket = DefaultTrainingBucket(account, resources.Region)
+	}
+
+	if resources.BucketCreated, err = c.ensureTrainingBucket(ctx, resources.Bucket, account, resources.Region); err != nil {
+		return nil, err
+	}
+	if resources.RoleARN, resources.RoleCreated, err = c.ensureTrainingRole(ctx, roleName, resources.Bucket); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// ensureTrainingBucket creates the bucket unless the account already owns
+// it. New buckets block public access and are encrypted by default.
+func (c *Client) ensureTrainingBucket(ctx context.Context, bucket, account, region string) (bool, error) {
+	_, err := c.S3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: awssdk.String(bucket), ExpectedBucketOwner: awssdk.String(account)})
+	switch {
+	case err == nil:
+		return false, nil
+	case !HasErrorCode(err, "NotFound", "NoSuchBucket"):
+		return false, fmt.Errorf("bucket %s exists but is not usable by account %s (bucket names are global; pass another with --bucket): %w", bucket, account, err)
+	}
+
+	input := &s3.CreateBucketInput{Bucket: awssdk.String(bucket)}
+	// us-east-1 is the only region that rejects an explicit location
+	if region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{LocationConstraint: s3types.BucketLocationConstraint(region)}
+	}
+	if _, err := c.S3.CreateBucket(ctx, input); err != nil {
+		return false, fmt.Errorf("could not create bucket %s: %w", bucket, err)
+	}
+	return true, nil
+}
+
+// ensureTrainingRole returns the ARN of the role, creating it with a
+// SageMaker trust policy if it does not exist, and applies the inline policy.
+func (c *Client) ensureTrainingRole(ctx context.Context, roleName, bucket string) (string, bool, error) {
+	created := false
+	var role *iamtypes.Role
+	existing, err := c.IAM.GetRole(ctx, &iam.GetRoleInput{RoleName: awssdk.String(roleName)})
+	switch {
+	case err == nil:
+		role = existing.Role
+		if !trustsSageMaker(awssdk.ToString(role.AssumeRolePolicyDocument)) {
+			return "", false, fmt.Errorf("role %s exists but SageMaker cannot assume it; choose another with --role-name", roleName)
+		}
+	case HasErrorCode(err, "NoSuchEntity"):
+		resp, err := c.IAM.CreateRole(ctx, &iam.CreateRoleInput{
+			RoleName:                 awssdk.String(roleName),
+			AssumeRolePolicyDocument: awssdk.String(sageMakerTrustPolicy),
+			Description:              awssdk.String("SageMaker execution role for CloudAI-CLI architecture model training"),
+			Tags:                     []iamtypes.Tag{{Key: awssdk.String("managed-by"), Value: awssdk.String("cloudai")}},
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("could not create role %s: %w", roleName, err)
+		}
+		role, created = resp.Role, true
+	default:
+		return "", false, fmt.Errorf("could not look up role %s: %w", roleName, err)
+	}
+
+	if _, err := c.IAM.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       awssdk.String(roleName),
+		PolicyName:     awssdk.String(trainingPolicyName),
+		PolicyDocument: awssdk.String(TrainingRolePolicy(bucket)),
+	}); err != nil {
+		return "", created, fmt.Errorf("could not attach the training policy to role %s: %w", roleName, err)
+	}
+	return awssdk.ToString(role.Arn), created, nil
+}
+
+// trustsSageMaker reports whether a trust policy, URL-encoded as IAM
+// returns it, names the SageMaker service principal.
+func trustsSageMaker(document string) bool {
+	if decoded, err := url.QueryUnescape(document); err == nil {
+		document = decoded
+	}
+	return strings.Contains(document, "sagemaker.amazonaws.com")
+}
+
+// UploadTrainingData puts prepared JSONL under train/ in the training
+// bucket, where training jobs read it from, and returns its S3 URI. The
+// client must be created WithWrites.
+func (c *Client) UploadTrainingData(ctx context.Context, bucket, name string, data []byte) (string, error) {
+	key := "train/" + name
+	if _, err := c.S3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      awssdk.String(bucket),
+		Key:         awssdk.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: awssdk.String("application/jsonlines"),
+	}); err != nil {
+		return "", fmt.Errorf("could not upload training data to s3://%s/%s: %w", bucket, key, err)
+	}
+	return fmt.Sprintf("s3://%s/%s", bucket, key), nil
+}
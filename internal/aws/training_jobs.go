@@ -0,0 +1,143 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/sagemaker"
+	sagemakertypes "github.com/aws/aws-sdk-go-v2/service/sagemaker/types"
+)
+
+// trainingLogGroup is where SageMaker sends the output of training containers
+const trainingLogGroup = "/aws/sagemaker/TrainingJobs"
+
+// TrainingJob is the state of a SageMaker training job.
+type TrainingJob struct {
+	Name            string             `json:"name"`
+	Status          string             `json:"status"`
+	SecondaryStatus string             `json:"secondary_status"`
+	FailureReason   string             `json:"failure_reason,omitempty"`
+	BillableSeconds int32              `json:"billable_seconds,omitempty"`
+	Spot            bool               `json:"spot,omitempty"`
+	ModelArtifacts  string             `json:"model_artifacts,omitempty"`
+	FinalMetrics    map[string]float64 `json:"final_metrics,omitempty"`
+}
+
+// Done reports whether the job has stopped running.
+func (j *TrainingJob) Done() bool {
+	switch sagemakertypes.TrainingJobStatus(j.Status) {
+	case sagemakertypes.TrainingJobStatusCompleted, sagemakertypes.TrainingJobStatusFailed, sagemakertypes.TrainingJobStatusStopped:
+		return true
+	}
+	return false
+}
+
+// LogLine is one line a training container wrote. Stream tells the hosts
+// of a distributed job apart, e.g. "algo-1-1700000000".
+type LogLine struct {
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Message string    `json:"message"`
+}
+
+// DescribeTrainingJob returns the status of a training job.
+func (c *Client) DescribeTrainingJob(ctx context.Context, name string) (*TrainingJob, error) {
+	resp, err := c.SageMaker.DescribeTrainingJob(ctx, &sagemaker.DescribeTrainingJobInput{TrainingJobName: awssdk.String(name)})
+	if err != nil {
+		return nil, fmt.Errorf("could not describe training job %s: %w", name, err)
+	}
+	job := &TrainingJob{
+		Name:            name,
+		Status:          string(resp.TrainingJobStatus),
+		SecondaryStatus: string(resp.SecondaryStatus),
+		FailureReason:   awssdk.ToString(resp.FailureReason),
+		BillableSeconds: awssdk.ToInt32(resp.BillableTimeInSeconds),
+		Spot:            awssdk.ToBool(resp.EnableManagedSpotTraining),
+	}
+	if resp.ModelArtifacts != nil {
+		job.ModelArtifacts = awssdk.ToString(resp.ModelArtifacts.S3ModelArtifacts)
+	}
+	for _, metric := range resp.FinalMetricDataList {
+		if job.FinalMetrics == nil {
+			job.FinalMetrics = map[string]float64{}
+		}
+		job.FinalMetrics[awssdk.ToString(metric.MetricName)] = float64(awssdk.ToFloat32(metric.Value))
+	}
+	return job, nil
+}
+
+// FollowTrainingJob calls fn with every log line of a training job as it
+// is written, checking the job's status every interval until it is done,
+// and returns its final state. Status changes, such as "Downloading" or a
+// spot "Interrupted", are passed to onStatus.
+func (c *Client) FollowTrainingJob(ctx context.Context, name string, interval time.Duration, onStatus func(*TrainingJob), fn func(LogLine)) (*TrainingJob, error) {
+	var since int64
+	seen := map[string]bool{}
+	lastStatus := ""
+	for {
+		job, err := c.DescribeTrainingJob(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if status := job.Status + "/" + job.SecondaryStatus; status != lastStatus {
+			lastStatus = status
+			onStatus(job)
+		}
+
+		// logs written before the status check are complete once it says done
+		if since, err = c.trainingLogsSince(ctx, name, since, seen, fn); err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// trainingLogsSince passes the job's log events from since on to fn and
+// returns the timestamp to continue from. Events are filtered by ID, since
+// the next call starts at the last timestamp again to not miss events
+// written in the same millisecond.
+func (c *Client) trainingLogsSince(ctx context.Context, name string, since int64, seen map[string]bool, fn func(LogLine)) (int64, error) {
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(c.Logs, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        awssdk.String(trainingLogGroup),
+		LogStreamNamePrefix: awssdk.String(name + "/"),
+		StartTime:           awssdk.Int64(since),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if HasErrorCode(err, "ResourceNotFoundException") {
+			// the log group appears with the first job's first line
+			return since, nil
+		}
+		if err != nil {
+			return since, fmt.Errorf("could not read the logs of training job %s: %w", name, err)
+		}
+		for _, event := range page.Events {
+			id := awssdk.ToString(event.EventId)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			timestamp := awssdk.ToInt64(event.Timestamp)
+			if timestamp > since {
+				since = timestamp
+			}
+			fn(LogLine{
+				Time:    time.UnixMilli(timestamp),
+				Stream:  awssdk.ToString(event.LogStreamName)[len(name)+1:],
+				Message: awssdk.ToString(event.Message),
+			})
+		}
+	}
+	return since, nil
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/audit"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit [path]",
+	Short: "Audit the scanned infrastructure without calling the LLM",
+	Long: `Audits the infrastructure cache deterministically. With --framework, maps
+the checks to compliance framework controls and prints a pass/fail matrix:
+
+  cis   CIS AWS Foundations Benchmark v3.0
+  fsbp  AWS Foundational Security Best Practices
+
+  cloudai audit --framework cis
+  cloudai audit --framework fsbp --csv > fsbp.csv
+  cloudai audit --framework fsbp --json
+
+Only controls the scanned resources can decide are listed; account-level
+controls such as CloudTrail or the root user need AWS Security Hub.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuditFramework,
+}
+
+func runAuditFramework(cmd *cobra.Command, args []string) error {
+	if auditFramework == "" {
+		return cmd.Help()
+	}
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	results, err := audit.EvaluateFramework(infraState, auditFramework, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "audit --framework " + auditFramework,
+			Data:    results,
+			Success: true,
+		})
+	}
+	if auditCSV {
+		cw := csv.NewWriter(os.Stdout)
+		cw.Write([]string{"framework", "control_id", "check", "title", "status", "checked", "failing"})
+		for _, r := range results {
+			cw.Write([]string{r.Framework, r.ControlID, r.Check, r.Title, r.Status, strconv.Itoa(r.Checked), strings.Join(r.Failing, " ")})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	failed := 0
+	icons := map[string]string{audit.StatusPass: "✅ pass", audit.StatusFail: "❌ fail", audit.StatusNotApplicable: "➖ n/a"}
+	table := &output.Table{Headers: []string{"Control", "Title", "Status", "Checked", "Failing"}}
+	for _, r := range results {
+		if r.Status == audit.StatusFail {
+			failed++
+		}
+		table.Rows = append(table.Rows, []string{r.ControlID, r.Title, icons[r.Status], strconv.Itoa(r.Checked), strings.Join(r.Failing, ", ")})
+	}
+	table.Title = fmt.Sprintf("%s: %d of %d controls failing", strings.ToUpper(auditFramework), failed, len(results))
+	return output.NewFormatter(false).FormatResult(&output.Result{Query: "audit --framework " + auditFramework, Data: table, Success: true})
+}
+
+var auditEOLCmd = &cobra.Command{
+	Use:   "eol [path]",
+	Short: "List resources on deprecated or soon-EOL runtime and engine versions",
+	Long: `Checks Lambda runtimes, RDS engine versions and EKS Kubernetes versions in the
+infrastructure cache against their end-of-support dates and suggests an upgrade
+target for each affected resource.
+
+Run 'cloudai scan --live' first to include RDS and EKS from the account.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuditEOL,
+}
+
+func runAuditEOL(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if auditWithin < 0 {
+		return fmt.Errorf("--within must not be negative")
+	}
+
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	findings := audit.FindEOL(infraState, time.Now(), time.Duration(auditWithin)*24*time.Hour)
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "audit eol",
+			Data:    findings,
+			Success: true,
+		})
+	}
+
+	if len(findings) == 0 {
+		fmt.Printf("✅ No resources on deprecated versions or reaching EOL within %d days\n", auditWithin)
+		return nil
+	}
+
+	deprecated := 0
+	table := &output.Table{Headers: []string{"Resource", "Version", "EOL", "Status", "Suggestion"}}
+	for _, f := range findings {
+		status := "⚠️  EOL soon"
+		if f.Status == audit.StatusDeprecated {
+			status = "❌ deprecated"
+			deprecated++
+		}
+		table.Rows = append(table.Rows, []string{f.Resource, f.Version, f.EOLDate, status, f.Suggestion})
+	}
+	table.Title = fmt.Sprintf("%d deprecated, %d reaching EOL within %d days", deprecated, len(findings)-deprecated, auditWithin)
+	if err := output.NewFormatter(false).FormatResult(&output.Result{Query: "audit eol", Data: table, Success: true}); err != nil {
+		return err
+	}
+	if deprecated > 0 {
+		fmt.Fprintln(os.Stderr, "\n💡 Deprecated runtimes no longer receive security patches; upgrade these first.")
+	}
+	return nil
+}
+
+var auditAlarmsCmd = &cobra.Command{
+	Use:   "alarms [path]",
+	Short: "List public APIs, queues and databases without CloudWatch alarms or dashboards",
+	Long: `Checks the public APIs, SQS queues and databases in the infrastructure cache
+against the CloudWatch alarms and dashboards in it and lists the resources
+that no alarm watches or no dashboard shows. Dead-letter queues are called
+out: without an alarm, messages in them are failures nobody notices.
+
+Templates are checked against the alarms and dashboards they define; run
+'cloudai scan --live' to check the account's own.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuditAlarms,
+}
+
+func runAuditAlarms(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	findings := audit.FindUnmonitored(infraState)
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "audit alarms",
+			Data:    findings,
+			Success: true,
+		})
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("✅ Every public API, queue and database has an alarm and a dashboard")
+		return nil
+	}
+
+	unalarmed := 0
+	table := &output.Table{Headers: []string{"Resource", "Kind", "Missing", "Suggestion"}}
+	for _, f := range findings {
+		if f.Missing[0] == audit.MissingAlarm {
+			unalarmed++
+		}
+		table.Rows = append(table.Rows, []string{f.Resource, f.Kind, strings.Join(f.Missing, ", "), f.Suggestion})
+	}
+	table.Title = fmt.Sprintf("%d without an alarm, %d without a dashboard only", unalarmed, len(findings)-unalarmed)
+	if err := output.NewFormatter(false).FormatResult(&output.Result{Query: "audit alarms", Data: table, Success: true}); err != nil {
+		return err
+	}
+	for _, f := range findings {
+		if f.Kind == "dead-letter queue" && f.Missing[0] == audit.MissingAlarm {
+			fmt.Fprintln(os.Stderr, "\n💡 Dead-letter queues without an alarm fill up silently; alarm on them first.")
+			break
+		}
+	}
+	return nil
+}
+
+var auditSecretsCmd = &cobra.Command{
+	Use:   "secrets [path]",
+	Short: "List hard-coded secrets found in the scanned templates",
+	Long: `Lists the hard-coded secrets 'cloudai scan' found in Lambda environment
+variables, EC2 user data and template parameter defaults: known key formats
+such as AWS access keys and private keys, literal values of variables named
+like passwords or tokens, and high-entropy strings.
+
+The scan redacts the values from the infrastructure cache and keeps only a
+masked preview here. The findings are never sent to a model.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuditSecrets,
+}
+
+func runAuditSecrets(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	findings := state.SecretFindings(infraState)
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "audit secrets",
+			Data:    findings,
+			Success: true,
+		})
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("✅ No hard-coded secrets in the scanned templates")
+		return nil
+	}
+	table := &output.Table{
+		Title:   fmt.Sprintf("%d hard-coded secret(s), redacted from the cache", len(findings)),
+		Headers: []string{"Resource", "Location", "Kind", "Preview"},
+	}
+	for _, f := range findings {
+		table.Rows = append(table.Rows, []string{f.Resource, f.Location, f.Kind, f.Preview})
+	}
+	if err := output.NewFormatter(false).FormatResult(&output.Result{Query: "audit secrets", Data: table, Success: true}); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "\n💡 Move them to Secrets Manager or SSM Parameter Store and rotate them: they are in the template's history.")
+	return nil
+}
+
+func init() {
+	auditCmd.Flags().StringVar(&auditFramework, "framework", "", "print a pass/fail matrix for a compliance framework: cis or fsbp")
+	auditCmd.Flags().BoolVar(&auditCSV, "csv", false, "write the framework matrix as CSV")
+	auditEOLCmd.Flags().IntVar(&auditWithin, "within", 180, "also list versions reaching EOL within this many days")
+	auditCmd.AddCommand(auditEOLCmd)
+	auditCmd.AddCommand(auditAlarmsCmd)
+	auditCmd.AddCommand(auditSecretsCmd)
+	rootCmd.AddCommand(auditCmd)
+}
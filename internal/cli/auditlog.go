@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var auditLogCmd = &cobra.Command{
+	Use:   "audit-log",
+	Short: "Review the log of requests sent to language models",
+	Long: `Every request CloudAI-CLI sends to a model is recorded in an append-only audit log
+(~/.cloudai/audit.jsonl): when, to which provider and model, how many values of each
+category were redacted from the prompt, and the token counts. Prompts and answers are
+never recorded.
+
+With audit_log.hash_chain each entry carries the hash of the one before it, so that
+'cloudai audit-log verify' detects entries that were edited, removed or reordered.
+Configure in ~/.cloudai.yaml:
+
+  audit_log:
+    enabled: true
+    hash_chain: true
+    path: /var/log/cloudai/audit.jsonl`,
+}
+
+var auditLogShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "List the recorded model requests",
+	Args:  cobra.NoArgs,
+	RunE:  runAuditLogShow,
+}
+
+var auditLogVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the hash chain of the audit log",
+	Args:  cobra.NoArgs,
+	RunE:  runAuditLogVerify,
+}
+
+func runAuditLogShow(cmd *cobra.Command, args []string) error {
+	if auditLogDays < 0 {
+		return fmt.Errorf("--days must not be negative")
+	}
+	log, err := llm.NewAuditLogFromConfig()
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if auditLogDays > 0 {
+		since = time.Now().AddDate(0, 0, -auditLogDays)
+	}
+	entries, err := log.Load(since)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "audit-log show",
+			Data:    entries,
+			Success: true,
+		})
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No model requests recorded in %s\n", log.Path())
+		return nil
+	}
+
+	table := &output.Table{
+		Title:   fmt.Sprintf("Model requests (%s)", log.Path()),
+		Headers: []string{"Time", "Provider", "Model", "Operation", "Tokens in/out", "Redacted", "Status"},
+	}
+	estimated := false
+	for _, entry := range entries {
+		status := "ok"
+		if !entry.Success {
+			status = "failed"
+		}
+		tokens := fmt.Sprintf("%d/%d", entry.InputTokens, entry.OutputTokens)
+		if entry.TokensEstimated {
+			tokens = "~" + tokens
+			estimated = true
+		}
+		table.Rows = append(table.Rows, []string{
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Provider,
+			entry.Model,
+			entry.Operation,
+			tokens,
+			describeRedactions(entry.Redactions),
+			status,
+		})
+	}
+	table.Print()
+	if estimated {
+		fmt.Println("~ the backend did not report token counts; they are estimated at ~4 characters per token")
+	}
+	return nil
+}
+
+func runAuditLogVerify(cmd *cobra.Command, args []string) error {
+	log, err := llm.NewAuditLogFromConfig()
+	if err != nil {
+		return err
+	}
+	entries, err := log.Load(time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	result := usage.VerifyAudit(entries)
+
+	if jsonOutput {
+		if err := output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "audit-log verify",
+			Data:    result,
+			Success: result.BrokenAt == 0,
+		}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("🔏 %s: %d entries, %d hash-chained\n", log.Path(), result.Entries, result.Chained)
+		if result.Unchained > 0 {
+			fmt.Printf("   %d entries were written without audit_log.hash_chain and cannot be verified\n", result.Unchained)
+		}
+	}
+	if result.BrokenAt > 0 {
+		return fmt.Errorf("audit log hash chain is broken at entry %d: %s", result.BrokenAt, result.Problem)
+	}
+	if !jsonOutput && result.Chained > 0 {
+		fmt.Println("✅ Hash chain intact")
+	}
+	return nil
+}
+
+// describeRedactions summarises redaction counts, e.g. "ARN 3, IP 1".
+func describeRedactions(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	categories := sortedKeys(counts)
+	parts := make([]string, len(categories))
+	for i, category := range categories {
+		parts[i] = fmt.Sprintf("%s %d", category, counts[category])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	auditLogShowCmd.Flags().IntVar(&auditLogDays, "days", 7, "show requests from the last N days (0 for all)")
+	auditLogCmd.AddCommand(auditLogShowCmd, auditLogVerifyCmd)
+	rootCmd.AddCommand(auditLogCmd)
+}
@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and compact a project's infrastructure cache",
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info [path]",
+	Short: "Show the cache size, resources per type and scan times",
+	Long: `Shows how large a project's infrastructure cache is, how many resources of each
+type it holds and how much space they take, when each service was last scanned, what was
+imported, and how many tokens the cache adds to every question compared with the
+context window of the configured model.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCacheInfo,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune [path]",
+	Short: "Remove services, resource types or imports from the cache",
+	Long: `Removes resources from a project's infrastructure cache so questions carry a
+smaller context. Select what to remove by service (named after the CloudFormation type,
+lambda for AWS::Lambda::Function), by resource type, or by import as listed by
+'cloudai cache info' ("all" removes every import). The cache holds only the latest
+scan, so there are no older snapshots to remove; imported AWS Config snapshots are
+removed with --import.
+
+A cache that fails its integrity check is refused; run 'cloudai scan' to rebuild it.
+
+Examples:
+  cloudai cache prune --service logs,cloudwatch
+  cloudai cache prune --type AWS::CDK::Metadata
+  cloudai cache prune --import config-snapshot:snapshot.json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCachePrune,
+}
+
+func runCacheInfo(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	cachePath := state.NewCacheManager(dir).Path()
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		return fmt.Errorf("%w in this directory. Please run `cloudai scan` first", state.ErrNoCache)
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	resources, _ := infraState["Resources"].(map[string]interface{})
+
+	encrypted := ""
+	if viper.GetBool("cache.encrypt") {
+		encrypted = " (encrypted)"
+	}
+	fmt.Printf("🗂️  Infrastructure cache: %s\n", cachePath)
+	fmt.Printf("   Size: %s%s, saved %s\n", formatBytes(info.Size()), encrypted, info.ModTime().Format("2006-01-02 15:04"))
+	if source, ok := infraState["Source"].(string); ok {
+		fmt.Printf("   Source: %s\n", source)
+	}
+	fmt.Printf("   Resources: %d\n", len(resources))
+
+	if times := state.ScanTimes(infraState); len(times) > 0 {
+		services := sortedKeys(times)
+		parts := make([]string, len(services))
+		for i, service := range services {
+			parts[i] = service + " " + formatScanTime(times[service])
+		}
+		fmt.Printf("   Last scanned: %s\n", strings.Join(parts, ", "))
+	}
+	fmt.Println()
+
+	type typeUsage struct {
+		count int
+		bytes int
+	}
+	byType := make(map[string]*typeUsage)
+	imports := make(map[string]int)
+	for _, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceType, _ := resource["Type"].(string)
+		usage := byType[resourceType]
+		if usage == nil {
+			usage = &typeUsage{}
+			byType[resourceType] = usage
+		}
+		encoded, _ := json.Marshal(resource)
+		usage.count++
+		usage.bytes += len(encoded)
+		if source := state.ImportSource(resource); source != "" {
+			imports[source]++
+		}
+	}
+
+	types := make([]string, 0, len(byType))
+	for resourceType := range byType {
+		types = append(types, resourceType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if byType[types[i]].bytes != byType[types[j]].bytes {
+			return byType[types[i]].bytes > byType[types[j]].bytes
+		}
+		return types[i] < types[j]
+	})
+	table := &output.Table{Title: "Resources by type", Headers: []string{"Type", "Resources", "Size"}}
+	for _, resourceType := range types {
+		table.Rows = append(table.Rows, []string{resourceType, fmt.Sprint(byType[resourceType].count), formatBytes(int64(byType[resourceType].bytes))})
+	}
+	table.Print()
+
+	if len(imports) > 0 {
+		fmt.Println()
+		table := &output.Table{Title: "Imports", Headers: []string{"Import", "Resources"}}
+		for _, source := range sortedKeys(imports) {
+			table.Rows = append(table.Rows, []string{source, fmt.Sprint(imports[source])})
+		}
+		table.Print()
+	}
+
+	prompt, err := promptContext(infraState)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n🧮 Prompt context: ~%d tokens per question\n", llm.EstimateTokens(prompt))
+	for _, warning := range contextBudgetWarnings(prompt) {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+	}
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if len(pruneServices) == 0 && len(pruneTypes) == 0 && len(pruneImports) == 0 {
+		return fmt.Errorf("nothing to prune: pass --service, --type or --import")
+	}
+	for i, service := range pruneServices {
+		pruneServices[i] = strings.ToLower(strings.TrimSpace(service))
+	}
+
+	// Saving re-signs the cache, so an edited one is refused rather than blessed.
+	infraState, err := loadCache(dir, true)
+	if err != nil {
+		return err
+	}
+	removed := state.RemoveResources(infraState, func(resource map[string]interface{}) bool {
+		resourceType, _ := resource["Type"].(string)
+		source := state.ImportSource(resource)
+		return slices.Contains(pruneServices, state.ResourceService(resource)) ||
+			slices.Contains(pruneTypes, resourceType) ||
+			(source != "" && (slices.Contains(pruneImports, "all") || slices.Contains(pruneImports, source)))
+	})
+	if removed == 0 {
+		fmt.Println("Nothing matched; the cache is unchanged")
+		return nil
+	}
+
+	cacheManager, err := newCacheManager(dir)
+	if err != nil {
+		return err
+	}
+	if err := cacheManager.Save(infraState); err != nil {
+		return fmt.Errorf("could not save cache: %w", err)
+	}
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	fmt.Printf("✅ Removed %d resources; the cache now holds %d resources\n", removed, len(resources))
+	return nil
+}
+
+// contextBudgetWarnings compares the prompt context with the context window
+// of each configured model and explains the ones it would not fit.
+func contextBudgetWarnings(prompt string) []string {
+	tokens := llm.EstimateTokens(prompt)
+	var warnings []string
+	for _, ref := range llm.ConfiguredModels() {
+		window := llm.ContextWindow(ref)
+		if window == 0 || tokens+llm.EstimatedOutputTokens <= window {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("The cache adds ~%d tokens to each question, more than the ~%d-token context window of %s (%s); "+
+			"trim it with 'cloudai cache prune' or rescan with --services", tokens, window, ref.Model, ref.Role))
+	}
+	return warnings
+}
+
+// formatScanTime shows an RFC 3339 scan time in local time.
+func formatScanTime(value string) string {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return at.Local().Format("2006-01-02 15:04")
+}
+
+// formatBytes formats a size in B, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func init() {
+	cachePruneCmd.Flags().StringSliceVar(&pruneServices, "service", nil, "remove the resources of these services, e.g. logs,iam")
+	cachePruneCmd.Flags().StringSliceVar(&pruneTypes, "type", nil, "remove resources of these types, e.g. AWS::CDK::Metadata")
+	cachePruneCmd.Flags().StringSliceVar(&pruneImports, "import", nil, `remove the resources of these imports, or "all"`)
+	cacheCmd.AddCommand(cacheInfoCmd, cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
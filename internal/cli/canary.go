@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+// maxCanaryDifferences caps the disagreements the text report lists
+const maxCanaryDifferences = 5
+
+var canaryCmd = &cobra.Command{
+	Use:   "canary",
+	Short: "Report whether the fine-tuned architecture model beats the general model",
+	Long: `Summarizes the canary comparisons: architecture questions that were answered
+by both the fine-tuned architecture model and the general model, with a judge
+model picking the better answer. Turn the canary on with a sample rate:
+
+  arch:
+    canary:
+      sample_rate: 0.1   # compare one in ten architecture questions
+
+The report shows how often each model won, their errors, refusals, latency
+and cost, the answers they disagreed on most, and a verdict once at least 20
+comparisons were judged. Comparisons are kept in ~/.cloudai/canary.jsonl,
+with sensitive values replaced by placeholders; nothing is sent anywhere.`,
+	Args: cobra.NoArgs,
+	RunE: runCanary,
+}
+
+func runCanary(cmd *cobra.Command, args []string) error {
+	if canaryDays < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	log, err := usage.NewCanaryLog()
+	if err != nil {
+		return err
+	}
+	entries, err := log.Load(time.Now().AddDate(0, 0, -canaryDays))
+	if err != nil {
+		return fmt.Errorf("failed to read the canary log: %w", err)
+	}
+	report := usage.SummarizeCanary(entries)
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "canary",
+			Data:    map[string]interface{}{"report": report, "comparisons": entries},
+			Success: true,
+		})
+	}
+
+	fmt.Printf("🐤 Canary – last %d days\n", canaryDays)
+	if report.Samples == 0 {
+		fmt.Println("\nNo comparisons recorded. Set arch.canary.sample_rate and ask architecture questions.")
+		return nil
+	}
+
+	table := &output.Table{
+		Title:   fmt.Sprintf("%d comparisons, %d judged, %d ties", report.Samples, report.Judged, report.Ties),
+		Headers: []string{"Model", "Role", "Wins", "Errors", "Refusals", "Avg latency", "Cost"},
+	}
+	for _, side := range []struct {
+		role string
+		side usage.CanarySide
+	}{{"architecture", report.Arch}, {"general", report.General}} {
+		table.Rows = append(table.Rows, []string{
+			side.side.Model, side.role, fmt.Sprint(side.side.Wins), fmt.Sprint(side.side.Errors),
+			fmt.Sprint(side.side.Refusals), fmt.Sprintf("%.1fs", float64(side.side.AvgLatencyMs)/1000),
+			fmt.Sprintf("$%.4f", side.side.Cost),
+		})
+	}
+	fmt.Println()
+	table.Print()
+	fmt.Printf("\n   Answers overlap %.0f%% on average\n", 100*report.AvgSimilarity)
+
+	// the judged pairs the general model won show where the fine-tune falls short
+	shown := 0
+	for i := len(entries) - 1; i >= 0 && shown < maxCanaryDifferences; i-- {
+		entry := entries[i]
+		if entry.Winner != usage.CanaryGeneral {
+			continue
+		}
+		if shown == 0 {
+			fmt.Println("\n🔍 Recent questions the general model answered better:")
+		}
+		shown++
+		fmt.Printf("   • %s\n", truncate(entry.Question, 100))
+		if entry.Reason != "" {
+			fmt.Printf("     %s\n", truncate(entry.Reason, 160))
+		}
+	}
+
+	fmt.Println()
+	switch report.Verdict {
+	case usage.CanaryArch:
+		fmt.Printf("✅ The fine-tuned model %s answers better; it is safe to route more questions to it.\n", report.Arch.Model)
+	case usage.CanaryGeneral:
+		fmt.Printf("❌ The general model %s answers better; consider rolling back with 'cloudai sagemaker deploy --version <n>' or retraining.\n", report.General.Model)
+	case usage.CanaryTie:
+		fmt.Println("⚖️  Neither model answers clearly better.")
+	default:
+		fmt.Printf("⏳ %d judged comparisons so far; a verdict needs 20.\n", report.Judged)
+	}
+	return nil
+}
+
+// truncate shortens s to n characters, on one line.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
+
+func init() {
+	canaryCmd.Flags().IntVar(&canaryDays, "days", 30, "number of days to report on")
+	rootCmd.AddCommand(canaryCmd)
+}
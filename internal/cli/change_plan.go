@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// planChange answers a request to change infrastructure with a script to
+// review, never by acting on it. The script is built from the cache like
+// --plan output: a cache that fails its integrity check is refused rather
+// than trusted. Without a cache the model plans from the request alone.
+func (e *queryEngine) planChange(ctx context.Context, dir, request string, models *tierModels) (queryResult, error) {
+	contextString := ""
+	infraState, err := loadCache(dir, true)
+	switch {
+	case errors.Is(err, state.ErrNoCache):
+	case err != nil:
+		return queryResult{}, err
+	default:
+		if contextString, err = promptContext(infraState); err != nil {
+			return queryResult{}, err
+		}
+	}
+
+	noteOperation("planning the change with %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	plan, err := models.router.PlanChange(ctx, request, contextString)
+	usage := models.router.LastUsage()
+	var mentioned []string
+	if infraState != nil {
+		mentioned = state.MentionedResources(infraState, request)
+	}
+	recordUsage(dir, usage, time.Since(start), err == nil, mentioned)
+	if err != nil {
+		return queryResult{Usage: usage}, err
+	}
+	return queryResult{Answer: formatChangePlan(request, plan), Usage: usage}, nil
+}
+
+// formatChangePlan renders a plan under llm.ChangeNotice, with a script
+// header saying it has not been run.
+func formatChangePlan(request string, plan *llm.ChangePlan) string {
+	var b strings.Builder
+	b.WriteString(llm.ChangeNotice + "\n")
+	if plan.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", plan.Summary)
+	}
+	if len(plan.Steps) > 0 {
+		b.WriteString("\nSteps:\n")
+		for i, step := range plan.Steps {
+			fmt.Fprintf(&b, "  %d. %s\n", i+1, step)
+		}
+	}
+	if script := strings.TrimSpace(plan.Script); script != "" {
+		b.WriteString("\n```bash\n#!/usr/bin/env bash\n")
+		fmt.Fprintf(&b, "# Plan for: %s\n", strings.Join(strings.Fields(request), " "))
+		b.WriteString("# Written by cloudai and NOT run. Review every command before running it.\n")
+		b.WriteString("set -euo pipefail\n\n")
+		b.WriteString(script + "\n```\n")
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(&b, "\n⚠️  %s", warning)
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/prcomment"
+	"github.com/spf13/cobra"
+)
+
+// addCommentFlags adds the flags that turn a command's result into a pull
+// request comment.
+func addCommentFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&commentOutput, "comment", false, "print the result as a markdown pull request comment")
+	cmd.Flags().BoolVar(&commentPost, "post-comment", false, "post the comment to the pull request (token from GITHUB_TOKEN or GITLAB_TOKEN)")
+	cmd.Flags().StringVar(&commentProvider, "provider", "", "where to post: github or gitlab (default: detected from the CI environment)")
+	cmd.Flags().StringVar(&commentRepo, "repo", "", "GitHub owner/name or GitLab project ID or path (default: from the CI or Atlantis environment)")
+	cmd.Flags().IntVar(&commentPR, "pr", 0, "pull or merge request number (default: from the CI or Atlantis environment)")
+}
+
+// wantsComment reports whether the result should be a pull request comment.
+func wantsComment() bool {
+	return commentOutput || commentPost
+}
+
+// emitComment prints the comment, or with --post-comment posts it, replacing
+// the comment an earlier run left on the pull request.
+func emitComment(ctx context.Context, comment *output.Comment) error {
+	body := comment.Markdown()
+	if !commentPost {
+		fmt.Print(body)
+		return nil
+	}
+	target, err := prcomment.TargetFromEnv(commentProvider, commentRepo, commentPR)
+	if err != nil {
+		return err
+	}
+	link, err := prcomment.Post(ctx, target, comment.Marker, body)
+	if err != nil {
+		return fmt.Errorf("could not post the comment: %w", err)
+	}
+	if link == "" {
+		link = fmt.Sprintf("%s #%d", target.Repo, target.Number)
+	}
+	fmt.Fprintf(os.Stderr, "💬 Commented on %s\n", link)
+	return nil
+}
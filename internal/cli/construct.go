@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var constructCmd = &cobra.Command{
+	Use:   "construct <construct-path> [path]",
+	Short: "Explain what a CDK construct creates and wires together",
+	Long: `Gathers every resource a CDK construct synthesized, read from the
+aws:cdk:path metadata of the scanned templates, and shows them by their path
+below the construct instead of by generated logical ID, with the references
+among them and to the rest of the stack:
+
+  cloudai construct ApiStack/OrdersService
+  cloudai construct OrdersService/Handler ./infra
+
+The leading stack and construct IDs may be left out as long as the path
+names one construct. Unless --no-summary is given, the model then explains
+what the construct creates and how it is wired together.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runConstruct,
+}
+
+func runConstruct(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	report, err := state.Construct(infraState, args[0])
+	if err != nil {
+		return err
+	}
+
+	summary := ""
+	if !constructListOnly {
+		summary, err = constructSummary(context.Background(), dir, report)
+		if err != nil {
+			// the listing stands on its own
+			fmt.Fprintf(os.Stderr, "⚠️  Could not explain the construct: %v\n", err)
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "construct " + report.Path,
+			Data: map[string]interface{}{
+				"construct": report,
+				"summary":   summary,
+			},
+			Success: true,
+		})
+	}
+
+	fmt.Printf("🧱 %s\n\n", report.Path)
+	table := output.Table{
+		Title:   fmt.Sprintf("%d resource(s) in the construct", len(report.Resources)),
+		Headers: []string{"Path", "Type", "Name", "Defined in"},
+	}
+	for _, resource := range report.Resources {
+		table.Rows = append(table.Rows, []string{truncate(resource.Path, 50), resource.Type, truncate(resource.Name, 40), resource.DefinedIn})
+	}
+	table.Print()
+
+	printConstructLinks("\n🔗 Wired together", report.Internal)
+	printConstructLinks("\n➡️  Uses", report.Outgoing)
+	printConstructLinks("\n⬅️  Used by", report.Incoming)
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+func printConstructLinks(title string, links []state.ConstructLink) {
+	if len(links) == 0 {
+		return
+	}
+	fmt.Println(title)
+	for _, link := range links {
+		fmt.Printf("   %s → %s via %s\n", link.From, link.To, link.Relation)
+	}
+}
+
+// constructSummary asks the model what the construct creates and how its
+// resources work together.
+func constructSummary(ctx context.Context, dir string, report *state.ConstructReport) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": "construct",
+		"result": report,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	question := fmt.Sprintf("Explain what the CDK construct %s creates and how its resources are wired together, naming them by path, and what it uses or is used by outside itself.", report.Path)
+	ids := make([]string, len(report.Resources))
+	for i, resource := range report.Resources {
+		ids[i] = resource.ID
+	}
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, question, string(result))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, ids)
+	return text, err
+}
+
+func init() {
+	constructCmd.Flags().BoolVar(&constructListOnly, "no-summary", false, "list the resources without asking the model to explain them")
+	rootCmd.AddCommand(constructCmd)
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxContextFileBytes bounds each --context-file, so a stray log or dump
+// does not blow the model's context window and the budget
+const maxContextFileBytes = 256 << 10
+
+// fileSecretPatterns match credentials that runbooks and config samples
+// tend to carry. The router's scrubbing covers AWS identifiers, not these.
+var fileSecretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|access[_-]?key)[A-Za-z_\-]*["']?\s*[:=]\s*)["']?[^\s"',;]+`),
+}
+
+// withContextFiles appends the --context-file documents to the prompt
+// context, each marked with its name and with credentials redacted.
+func withContextFiles(contextString string) (string, error) {
+	if len(contextFiles) == 0 {
+		return contextString, nil
+	}
+	var b strings.Builder
+	b.WriteString(contextString)
+	for _, path := range contextFiles {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read context file: %w", err)
+		}
+		if info.Size() > maxContextFileBytes {
+			return "", fmt.Errorf("context file %s is %d KiB; the limit is %d KiB", path, info.Size()>>10, maxContextFileBytes>>10)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read context file: %w", err)
+		}
+		fmt.Fprintf(&b, "\n\n--- FILE %s ---\n%s\n--- END FILE ---", filepath.Base(path), redactFileSecrets(string(data)))
+	}
+	return b.String(), nil
+}
+
+// redactFileSecrets masks private keys, access key IDs and the values of
+// password-like assignments.
+func redactFileSecrets(text string) string {
+	for _, re := range fileSecretPatterns {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			if sub := re.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + "****"
+			}
+			return "****"
+		})
+	}
+	return text
+}
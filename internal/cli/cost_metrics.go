@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/usage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// writeCostMetrics writes today's model usage and budget, and this month's
+// spend per project, in the Prometheus text format read by node_exporter's
+// textfile collector. All metrics are gauges: each run is a snapshot of the
+// cost file and usage ledger, which reset daily and grow monthly.
+func writeCostMetrics(w io.Writer, costs *llm.CostManager) error {
+	registry := prometheus.NewRegistry()
+	gauge := func(name, help string) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		registry.MustRegister(g)
+		return g
+	}
+	gaugeVec := func(name, help string, labels ...string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+		registry.MustRegister(g)
+		return g
+	}
+
+	today := costs.GetUsageStats()
+	gauge("cloudai_llm_cost_today_dollars", "Estimated model spend in USD today, across all backends.").Set(today.TotalCost)
+	gauge("cloudai_llm_requests_today", "Model requests made today.").Set(float64(today.RequestCount))
+	gauge("cloudai_llm_budget_dollars", "Daily model budget in USD (cost.daily_limit).").Set(costs.DailyLimit)
+	if costs.DailyLimit > 0 {
+		gauge("cloudai_llm_budget_used_ratio", "Share of the daily model budget spent today.").Set(today.TotalCost / costs.DailyLimit)
+	}
+
+	backendCost := gaugeVec("cloudai_llm_backend_cost_today_dollars", "Estimated model spend in USD today, by backend and model.", "backend", "model")
+	backendRequests := gaugeVec("cloudai_llm_backend_requests_today", "Model requests made today, by backend and model.", "backend", "model")
+	tokens := gaugeVec("cloudai_llm_tokens_today", "Estimated tokens sent to and received from model backends today.", "backend", "model", "direction")
+	for _, b := range today.Backends {
+		backendCost.WithLabelValues(b.Backend, b.Model).Set(b.Cost)
+		backendRequests.WithLabelValues(b.Backend, b.Model).Set(float64(b.Requests))
+		tokens.WithLabelValues(b.Backend, b.Model, "input").Set(float64(b.InputTokens))
+		tokens.WithLabelValues(b.Backend, b.Model, "output").Set(float64(b.OutputTokens))
+	}
+
+	ledger, err := usage.NewLedger()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entries, err := ledger.Load(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()))
+	if err != nil {
+		return fmt.Errorf("could not read the usage ledger: %w", err)
+	}
+	projectCost := gaugeVec("cloudai_llm_project_cost_month_dollars", "Estimated model spend in USD this month, by project cache path.", "project")
+	projectQueries := gaugeVec("cloudai_llm_project_queries_month", "Questions asked this month, by project cache path.", "project")
+	for _, p := range usage.ByProject(entries, now) {
+		projectCost.WithLabelValues(p.Project).Set(p.Cost)
+		projectQueries.WithLabelValues(p.Project).Set(float64(p.Queries))
+	}
+	gauge("cloudai_cost_report_timestamp_seconds", "When these metrics were written, to alert on a stale textfile.").Set(float64(now.Unix()))
+
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printCostMetrics writes the cost metrics to stdout for `cloudai cost
+// --prometheus`.
+func printCostMetrics() error {
+	costs := llm.NewCostManager(llm.DailyLimit())
+	if err := costs.LoadUsage(); err != nil {
+		return err
+	}
+	return writeCostMetrics(os.Stdout, costs)
+}
@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// daemonDialTimeout bounds how long the CLI waits to find a running daemon
+// before answering the question itself.
+const daemonDialTimeout = 200 * time.Millisecond
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep models and caches warm and answer CLI questions over a local socket",
+	Long: `Runs in the foreground and answers questions for the CLI over a unix socket
+(~/.cloudai/daemon.sock, config: daemon.socket). The model clients stay initialized and
+each project's cache stays loaded and summarized in memory until 'cloudai scan'
+rewrites it, so questions skip seconds of startup.
+
+While the daemon runs, 'cloudai "<question>"' detects it and proxies the question
+automatically; pass --no-daemon to answer in-process instead. Restart the daemon
+after changing the model configuration.
+
+With refresh.every (or per-service refresh.services intervals) configured, the daemon
+also rescans the live account in the background for every project it has answered
+questions about whose cache is a live scan, so answers reflect recent state:
+
+  refresh:
+    every: 1h            # a duration, "@every 6h", "@hourly", "@daily" or "@weekly"
+    services:
+      lambda: 15m
+      kms: off
+
+With report.schedule and report.dir configured, the daemon also sends the report
+of 'cloudai report' on that schedule (see 'cloudai report --help').`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+// daemonRequest is a question proxied from the CLI
+type daemonRequest struct {
+	Query   string `json:"query"`
+	Dir     string `json:"dir"`
+	Tier    string `json:"tier,omitempty"`
+	Suggest bool   `json:"suggest,omitempty"`
+}
+
+// daemonResponse is the daemon's answer to a daemonRequest
+type daemonResponse struct {
+	Answer    string    `json:"answer"`
+	FollowUps []string  `json:"follow_ups,omitempty"`
+	Usage     llm.Usage `json:"usage"`
+	Error     string    `json:"error,omitempty"`
+	Code      string    `json:"code,omitempty"`
+}
+
+// daemonSocketPath returns the unix socket the daemon listens on.
+func daemonSocketPath() string {
+	if configured := getConfigString("daemon.socket"); configured != "" {
+		return configured
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "cloudai-daemon.sock")
+	}
+	return filepath.Join(home, ".cloudai", "daemon.sock")
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	// the daemon serves every project with its own credentials; questions
+	// in environments with a profile or region are answered in-process
+	activeEnvironment = state.Environment{}
+
+	socketPath := daemonSocketPath()
+	if conn, err := net.DialTimeout("unix", socketPath, daemonDialTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", socketPath)
+	}
+	// nothing answered, so any socket file left behind is stale
+	os.Remove(socketPath)
+
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+	// Only the owner may ask questions with these credentials
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	engine, err := newQueryEngine(true, "")
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	schedule, err := refreshSchedule()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	reportEvery, reportOpts, err := scheduledReport()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	reportDir := getConfigString("report.dir")
+	if reportEvery > 0 {
+		if reportDir == "" {
+			listener.Close()
+			return fmt.Errorf("report.schedule is set but report.dir, the project to report on, is not")
+		}
+		if reportDir, err = filepath.Abs(reportDir); err != nil {
+			listener.Close()
+			return fmt.Errorf("invalid report.dir: %w", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		var req daemonRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" || req.Dir == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(daemonResponse{Error: "request must include query and dir"})
+			return
+		}
+		result, err := engine.answer(r.Context(), req.Dir, req.Query, req.Tier, req.Suggest)
+		resp := daemonResponse{Answer: result.Answer, FollowUps: result.FollowUps, Usage: result.Usage}
+		if err != nil {
+			resp.Error, resp.Code = err.Error(), classify(err).code
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+	fmt.Printf("🔥 CloudAI-CLI daemon listening on %s (Ctrl-C to stop)\n", socketPath)
+	if len(schedule) > 0 {
+		go runRefresher(ctx, schedule, engine.projects, &engine.mu)
+		fmt.Printf("🔄 Refreshing live scans of the projects asked about: %s\n", describeSchedule(schedule))
+	}
+	if reportEvery > 0 {
+		go runReporter(ctx, reportEvery, reportDir, reportOpts)
+		fmt.Printf("📬 Reporting on %s every %s to %s\n", reportDir, reportEvery, strings.Join(reportOpts.Deliver, ", "))
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("daemon failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	fmt.Println("\nShutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down daemon: %w", err)
+	}
+	return nil
+}
+
+// askDaemon proxies a question to a running daemon. ok is false when no
+// daemon is listening, in which case the caller answers the question itself.
+func askDaemon(ctx context.Context, dir, question, tier string, suggest bool) (result queryResult, ok bool, err error) {
+	socketPath := daemonSocketPath()
+	if _, err := os.Stat(socketPath); err != nil {
+		return queryResult{}, false, nil
+	}
+	conn, err := net.DialTimeout("unix", socketPath, daemonDialTimeout)
+	if err != nil {
+		return queryResult{}, false, nil
+	}
+	conn.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: daemonDialTimeout}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	body, err := json.Marshal(daemonRequest{Query: question, Dir: dir, Tier: tier, Suggest: suggest})
+	if err != nil {
+		return queryResult{}, true, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://cloudai/query", bytes.NewReader(body))
+	if err != nil {
+		return queryResult{}, true, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return queryResult{}, true, fmt.Errorf("daemon request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var answer daemonResponse
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return queryResult{}, true, fmt.Errorf("failed to read daemon response: %w", err)
+	}
+	if answer.Error != "" {
+		return queryResult{}, true, errorFromCode(answer.Code, answer.Error)
+	}
+	return queryResult{Answer: answer.Answer, FollowUps: answer.FollowUps, Usage: answer.Usage}, true, nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxDiagnosticBundles is how many bundles are kept; older ones are removed
+// when a new one is written.
+const maxDiagnosticBundles = 20
+
+// secretConfigWords mark config keys whose values never go into a bundle,
+// e.g. api_key or secret_access_key
+var secretConfigWords = map[string]bool{"key": true, "secret": true, "token": true, "password": true, "credentials": true, "authorization": true, "cookie": true}
+
+// secretConfigSections mark config maps, such as model.headers and
+// model.auth, whose values are all masked; only their names are kept
+var secretConfigSections = map[string]bool{"headers": true, "auth": true}
+
+var (
+	operationMu   sync.Mutex
+	lastOperation string
+	// commandStarted is set once a command's flags and arguments were
+	// accepted, so usage errors do not produce bundles
+	commandStarted bool
+)
+
+// noteOperation records what the command is doing, for the diagnostic
+// bundle of a crash. It must not include the question or other user data.
+func noteOperation(format string, args ...interface{}) {
+	operationMu.Lock()
+	defer operationMu.Unlock()
+	lastOperation = fmt.Sprintf(format, args...)
+}
+
+// startCommand runs before every command.
+func startCommand(cmd *cobra.Command, args []string) {
+	commandStarted = true
+	noteOperation("running %s", cmd.CommandPath())
+	if cwd, err := os.Getwd(); err == nil {
+		llm.SetProject(projectCache(cwd))
+	}
+}
+
+// diagnosticBundle is written to .cloudai/diagnostics/ when a command panics
+// or fails unexpectedly. Nothing is sent anywhere.
+type diagnosticBundle struct {
+	Time          time.Time              `json:"time"`
+	Build         buildInfo              `json:"build"`
+	Command       string                 `json:"command"`
+	LastOperation string                 `json:"last_operation"`
+	Error         string                 `json:"error"`
+	Panic         bool                   `json:"panic"`
+	Stack         string                 `json:"stack,omitempty"`
+	Config        map[string]interface{} `json:"config"`
+	Modules       []string               `json:"modules"`
+}
+
+// reportCrash writes a diagnostic bundle for err, unless
+// diagnostics.enabled is false, and tells the user where it is. stack is nil
+// for errors that were returned rather than panicked.
+func reportCrash(err error, stack []byte) {
+	if viper.IsSet("diagnostics.enabled") && !viper.GetBool("diagnostics.enabled") {
+		return
+	}
+	path, writeErr := writeDiagnosticBundle(err, stack)
+	if writeErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not write a diagnostic report: %v\n", writeErr)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "🩺 Diagnostic report written to %s\n   Secrets and AWS identifiers are removed; please attach it to bug reports.\n", path)
+}
+
+func writeDiagnosticBundle(err error, stack []byte) (string, error) {
+	protector := llm.NewDataProtector()
+	operationMu.Lock()
+	operation := lastOperation
+	operationMu.Unlock()
+
+	bundle := diagnosticBundle{
+		Time:          time.Now().UTC(),
+		Build:         readBuildInfo(),
+		Command:       diagnosticCommand(),
+		LastOperation: protector.Scrub(operation),
+		Error:         protector.Scrub(err.Error()),
+		Panic:         stack != nil,
+		Stack:         string(stack),
+		Config:        redactConfig(protector, viper.AllSettings()),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			bundle.Modules = append(bundle.Modules, dep.Path+"@"+dep.Version)
+		}
+	}
+
+	dir := diagnosticsDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+bundle.Time.Format("20060102-150405")+".json")
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", err
+	}
+	pruneDiagnosticBundles(dir)
+	return path, nil
+}
+
+// diagnosticsDir is .cloudai/diagnostics of the project in the working
+// directory, or of the home directory outside projects.
+func diagnosticsDir() string {
+	if cwd, err := os.Getwd(); err == nil {
+		if info, err := os.Stat(filepath.Join(cwd, ".cloudai")); err == nil && info.IsDir() {
+			return filepath.Join(cwd, ".cloudai", "diagnostics")
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".cloudai", "diagnostics")
+	}
+	return filepath.Join(os.TempDir(), "cloudai-diagnostics")
+}
+
+// pruneDiagnosticBundles removes all but the newest bundles.
+func pruneDiagnosticBundles(dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if err != nil || len(paths) <= maxDiagnosticBundles {
+		return
+	}
+	// the timestamped names sort oldest first
+	sort.Strings(paths)
+	for _, path := range paths[:len(paths)-maxDiagnosticBundles] {
+		os.Remove(path)
+	}
+}
+
+// diagnosticCommand is the command line without arguments, which may hold
+// the question; only flag names are kept.
+func diagnosticCommand() string {
+	parts := []string{"cloudai"}
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil && cmd != rootCmd {
+		parts = strings.Fields(cmd.CommandPath())
+	}
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			name, _, _ := strings.Cut(arg, "=")
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// redactConfig copies settings with secret values masked and AWS
+// identifiers, IPs and emails replaced by placeholders.
+func redactConfig(protector *llm.DataProtector, settings map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if isSecretConfigKey(key) {
+			redacted[key] = "****"
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if secretConfigSections[strings.ToLower(key)] {
+				redacted[key] = maskConfigValues(v)
+				continue
+			}
+			redacted[key] = redactConfig(protector, v)
+		case string:
+			redacted[key] = protector.Scrub(v)
+		case []interface{}, []string:
+			redacted[key] = protector.Scrub(fmt.Sprint(v))
+		default:
+			redacted[key] = v
+		}
+	}
+	return redacted
+}
+
+// maskConfigValues copies settings with every value, nested ones included,
+// masked.
+func maskConfigValues(settings map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if v, ok := value.(map[string]interface{}); ok {
+			masked[key] = maskConfigValues(v)
+			continue
+		}
+		masked[key] = "****"
+	}
+	return masked
+}
+
+func isSecretConfigKey(key string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(key), func(r rune) bool { return r == '_' || r == '-' }) {
+		if secretConfigWords[word] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/audit"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var drCmd = &cobra.Command{
+	Use:   "dr [path]",
+	Short: "Show what data a region outage would lose",
+	Long: `Answers "what would we lose if us-east-1 went down?" from the infrastructure
+cache. For every bucket, table, database, file system and volume in the
+region it lists the backups kept there (AWS Backup plans, DLM snapshot
+policies, point-in-time recovery, automated snapshots) and the copies kept
+elsewhere (backup copy actions, read replicas, global tables, replication):
+
+  cloudai dr --region us-east-1
+  cloudai dr ./infra --json
+
+A store with a replica in another region loses nothing, one with backup
+copies in another region loses the writes since the last copy, and the
+rest are lost with the region. Run 'cloudai scan --live' to include the
+account's backup plans and replication. Unless --no-summary is given, the
+model then writes a short narrative of the exposure.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDR,
+}
+
+func runDR(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	region := drRegion
+	for _, fallback := range []string{activeEnvironment.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"} {
+		if region == "" {
+			region = fallback
+		}
+	}
+	report := audit.AssessDR(infraState, region)
+
+	summary := ""
+	if !drNoSummary && len(report.Findings) > 0 {
+		summary, err = drSummary(context.Background(), dir, report)
+		if err != nil {
+			// the inventory stands on its own
+			fmt.Fprintf(os.Stderr, "⚠️  Could not write the DR narrative: %v\n", err)
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "dr " + region,
+			Data: map[string]interface{}{
+				"report":  report,
+				"summary": summary,
+			},
+			Success: true,
+		})
+	}
+
+	if len(report.Findings) == 0 {
+		fmt.Printf("✅ No data stores in %s in the cache\n", region)
+		return nil
+	}
+	lossLabels := map[string]string{
+		audit.LossAll:       "❌ everything",
+		audit.LossSinceCopy: "⚠️  since last copy",
+		audit.LossNone:      "✅ nothing",
+	}
+	table := &output.Table{Headers: []string{"Resource", "Type", "Lost", "Backups", "Outside the region"}}
+	for _, f := range report.Findings {
+		backups := strings.Join(f.Backups, "; ")
+		if backups == "" {
+			backups = "none"
+		}
+		table.Rows = append(table.Rows, []string{f.Resource, f.Type, lossLabels[f.Loss], backups, strings.Join(f.CrossRegion, "; ")})
+	}
+	table.Title = fmt.Sprintf("If %s went down: %d data store(s) lost (%d without any backup), %d lose recent writes, %d safe",
+		region, report.Lost, report.Unprotected, report.SinceCopy, report.Safe)
+	if err := output.NewFormatter(false).FormatResult(&output.Result{Query: "dr " + region, Data: table, Success: true}); err != nil {
+		return err
+	}
+	if report.OtherRegions > 0 {
+		fmt.Fprintf(os.Stderr, "\nℹ️  %d data store(s) in other regions are not affected\n", report.OtherRegions)
+	}
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+// drSummary asks the model to explain the exposure of the report.
+func drSummary(ctx context.Context, dir string, report audit.DRReport) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": "disaster_recovery",
+		"result": report,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resources []string
+	for _, finding := range report.Findings {
+		resources = append(resources, finding.Resource)
+	}
+	question := fmt.Sprintf("What would we lose if %s went down? Summarize the data lost outright, the data that loses recent writes, and the most important gaps to close.", report.Region)
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, question, string(result))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, resources)
+	return text, err
+}
+
+func init() {
+	drCmd.Flags().StringVar(&drRegion, "region", "", "region to assume down (default: the environment's or AWS_REGION)")
+	drCmd.Flags().BoolVar(&drNoSummary, "no-summary", false, "list the inventory without asking the model for a narrative")
+	rootCmd.AddCommand(drCmd)
+}
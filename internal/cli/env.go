@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// envVar is an environment variable the tool reads
+type envVar struct {
+	Name    string
+	Purpose string
+	Secret  bool
+}
+
+// envVars lists every environment variable CloudAI-CLI honors, in the order
+// they are shown.
+var envVars = []envVar{
+	{Name: "AWS_MODEL_TYPE", Purpose: "general model on AWS: bedrock, sagemaker or openai (used when model.type is unset)"},
+	{Name: "AWS_MODEL_ID", Purpose: "Bedrock model ID for AWS_MODEL_TYPE"},
+	{Name: "AWS_ENDPOINT_NAME", Purpose: "SageMaker endpoint for AWS_MODEL_TYPE=sagemaker"},
+	{Name: "AWS_REGION", Purpose: "region for AWS_MODEL_TYPE models and AWS API calls"},
+	{Name: "OLLAMA_URL", Purpose: "Ollama server when no model is configured (default http://localhost:11434)"},
+	{Name: "OLLAMA_MODEL", Purpose: "Ollama model when no model is configured"},
+	{Name: "OPENAI_API_KEY", Purpose: "OpenAI key; used when nothing else is configured", Secret: true},
+	{Name: "HF_TOKEN", Purpose: "bearer token for a TGI server (model.type tgi)", Secret: true},
+	{Name: "CLOUDAI_ARCH_ENDPOINT", Purpose: "SageMaker endpoint of the architecture model; overrides arch.endpoint"},
+	{Name: "CLOUDAI_ARCH_MODEL_ID", Purpose: "name reported for the architecture model; overrides arch.model_id (default arch-bot)"},
+	{Name: "CLOUDAI_ARCH_REGION", Purpose: "region of the architecture endpoint; overrides arch.region"},
+	{Name: "AWS_PROFILE", Purpose: "AWS credentials profile"},
+	{Name: "AWS_ACCESS_KEY_ID", Purpose: "static AWS credentials", Secret: true},
+	{Name: "AWS_SECRET_ACCESS_KEY", Purpose: "static AWS credentials", Secret: true},
+	{Name: "AWS_SESSION_TOKEN", Purpose: "temporary AWS credentials", Secret: true},
+	{Name: "AWS_DEFAULT_REGION", Purpose: "fallback region for AWS API calls"},
+	{Name: "HTTPS_PROXY", Purpose: "proxy for OpenAI, Ollama and release checks"},
+	{Name: "NO_PROXY", Purpose: "hosts reached without the proxy, e.g. localhost"},
+	{Name: "CLOUDAI_CA_BUNDLE", Purpose: "extra CA certificates for a TLS-inspecting proxy (or http.ca_bundle)"},
+	{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Purpose: "enables tracing for 'cloudai serve'"},
+	{Name: "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", Purpose: "enables tracing for 'cloudai serve' (traces only)"},
+}
+
+// envIssue is a problem found in the combined env and config file settings
+type envIssue struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`
+}
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "List the environment variables CloudAI-CLI honors and check the resulting setup",
+	Long: `Lists every environment variable CloudAI-CLI reads with its current value
+(secrets masked), shows which models the combined environment and config file
+resolve to, and flags conflicts such as environment variables that the config
+file overrides or incomplete credentials.
+
+Exits non-zero when an error is found, so it can be used as a preflight check.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issues := checkEnv()
+		models := llm.ConfiguredModels()
+
+		if jsonOutput {
+			values := map[string]string{}
+			for _, v := range envVars {
+				if value, ok := os.LookupEnv(v.Name); ok {
+					values[v.Name] = displayEnvValue(v, value)
+				}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(map[string]interface{}{
+				"variables":   values,
+				"config_file": viper.ConfigFileUsed(),
+				"models":      models,
+				"issues":      issues,
+			}); err != nil {
+				return err
+			}
+		} else {
+			table := &output.Table{Title: "Environment", Headers: []string{"Variable", "Value", "Used for"}}
+			for _, v := range envVars {
+				value := "(unset)"
+				if raw, ok := os.LookupEnv(v.Name); ok {
+					value = displayEnvValue(v, raw)
+				}
+				table.Rows = append(table.Rows, []string{v.Name, value, v.Purpose})
+			}
+			table.Print()
+
+			configFile := viper.ConfigFileUsed()
+			if configFile == "" {
+				configFile = "(none)"
+			}
+			fmt.Printf("\n📄 Config file: %s\n", configFile)
+			fmt.Println("\n🤖 Resolved models:")
+			if len(models) == 0 {
+				fmt.Println("   (none)")
+			}
+			for _, m := range models {
+				fmt.Printf("   %s: %s (%s)\n", m.Role, m.Model, m.Backend)
+			}
+
+			fmt.Println()
+			if len(issues) == 0 {
+				fmt.Println("✅ Configuration is coherent")
+			}
+			for _, issue := range issues {
+				icon := "⚠️ "
+				if issue.Severity == "error" {
+					icon = "❌"
+				}
+				fmt.Printf("%s %s\n", icon, issue.Message)
+			}
+		}
+
+		errorCount := 0
+		for _, issue := range issues {
+			if issue.Severity == "error" {
+				errorCount++
+			}
+		}
+		if errorCount > 0 {
+			// the problems are already listed; usage help would only bury them
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d configuration error(s)", errorCount)
+		}
+		return nil
+	},
+}
+
+// checkEnv cross-checks environment variables against each other and the
+// config file, following the precedence NewClient uses.
+func checkEnv() []envIssue {
+	var issues []envIssue
+	add := func(severity, format string, args ...interface{}) {
+		issues = append(issues, envIssue{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+	set := func(name string) bool { return os.Getenv(name) != "" }
+
+	// The config file's model.type wins over every model env var
+	if modelType := getConfigString("model.type"); modelType != "" {
+		for _, name := range []string{"AWS_MODEL_TYPE", "OLLAMA_URL", "OLLAMA_MODEL"} {
+			if set(name) {
+				add("warning", "%s is ignored: model.type is set to %q in the config file", name, modelType)
+			}
+		}
+		if set("OPENAI_API_KEY") && modelType != "openai" {
+			add("warning", "OPENAI_API_KEY is ignored: model.type is set to %q in the config file", modelType)
+		}
+		if modelType == "openai" && !set("OPENAI_API_KEY") && getConfigString("openai.api_key") == "" && getConfigString("openai.base_url") == "" {
+			add("error", "model.type is openai but neither OPENAI_API_KEY, openai.api_key nor openai.base_url is set")
+		}
+	} else if awsType := os.Getenv("AWS_MODEL_TYPE"); awsType != "" {
+		switch llm.AWSModelType(awsType) {
+		case llm.AWSModelBedrock, llm.AWSModelOpenAI:
+			if !set("AWS_MODEL_ID") {
+				add("error", "AWS_MODEL_TYPE=%s requires AWS_MODEL_ID", awsType)
+			}
+		case llm.AWSModelSageMaker:
+			if !set("AWS_ENDPOINT_NAME") {
+				add("error", "AWS_MODEL_TYPE=sagemaker requires AWS_ENDPOINT_NAME")
+			}
+		default:
+			add("error", "AWS_MODEL_TYPE=%q is not one of bedrock, sagemaker or openai", awsType)
+		}
+		for _, name := range []string{"OLLAMA_URL", "OLLAMA_MODEL", "OPENAI_API_KEY"} {
+			if set(name) {
+				add("warning", "%s is ignored: AWS_MODEL_TYPE takes precedence", name)
+			}
+		}
+	} else {
+		for _, name := range []string{"AWS_MODEL_ID", "AWS_ENDPOINT_NAME"} {
+			if set(name) {
+				add("warning", "%s is ignored without AWS_MODEL_TYPE", name)
+			}
+		}
+	}
+
+	if llm.ArchEndpoint() == "" {
+		for _, name := range []string{"CLOUDAI_ARCH_MODEL_ID", "CLOUDAI_ARCH_REGION"} {
+			if set(name) {
+				add("warning", "%s is ignored without CLOUDAI_ARCH_ENDPOINT or arch.endpoint", name)
+			}
+		}
+	}
+
+	if set("AWS_ACCESS_KEY_ID") != set("AWS_SECRET_ACCESS_KEY") {
+		add("error", "AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+	}
+	if set("AWS_PROFILE") && set("AWS_ACCESS_KEY_ID") {
+		add("warning", "AWS_PROFILE is ignored: AWS_ACCESS_KEY_ID takes precedence in the AWS SDK")
+	}
+	if region, fallback := os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"); region != "" && fallback != "" && region != fallback {
+		add("warning", "AWS_REGION (%s) and AWS_DEFAULT_REGION (%s) differ; AWS_REGION is used", region, fallback)
+	}
+	if region, configured := os.Getenv("AWS_REGION"), getConfigString("model.region"); getConfigString("model.type") == "aws" && region != "" && configured != "" && region != configured {
+		add("warning", "the model runs in %s (model.region) but AWS APIs are called in %s (AWS_REGION)", configured, region)
+	}
+
+	if len(llm.ConfiguredModels()) == 0 {
+		add("error", "no model is configured; run 'cloudai setup-interactive' or set one of the model variables above")
+	}
+	return issues
+}
+
+// displayEnvValue masks secrets, keeping the last four characters.
+func displayEnvValue(v envVar, value string) string {
+	if !v.Secret {
+		return value
+	}
+	if len(value) > 4 {
+		return "****" + value[len(value)-4:]
+	}
+	return "****"
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+}
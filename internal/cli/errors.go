@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// Exit codes. Failures without a class of their own exit with 1; 2 is left
+// to shells and usage errors.
+const (
+	ExitFailure          = 1
+	ExitNoCache          = 3
+	ExitBudgetExceeded   = 4
+	ExitModelUnavailable = 5
+	ExitPermissionDenied = 6
+	ExitPolicyViolation  = 7
+)
+
+// errInternal marks a returned error as a bug rather than a problem with the
+// question, the configuration or the account; only these and panics leave a
+// diagnostic bundle.
+var errInternal = errors.New("internal error")
+
+// internalError wraps err as a bug, see errInternal.
+func internalError(err error) error {
+	return fmt.Errorf("%w: %w", errInternal, err)
+}
+
+// errorClass is a failure cause that scripts can branch on
+type errorClass struct {
+	err      error
+	code     string
+	exitCode int
+}
+
+// errorClasses are checked in order; the first one the error matches wins.
+var errorClasses = []errorClass{
+	{state.ErrNoCache, "no_cache", ExitNoCache},
+	{llm.ErrBudgetExceeded, "budget_exceeded", ExitBudgetExceeded},
+	{llm.ErrPolicyViolation, "policy_violation", ExitPolicyViolation},
+	{aws.ErrPermissionDenied, "permission_denied", ExitPermissionDenied},
+	{llm.ErrModelUnavailable, "model_unavailable", ExitModelUnavailable},
+}
+
+// classify returns the class of err, or a generic one.
+func classify(err error) errorClass {
+	for _, class := range errorClasses {
+		if errors.Is(err, class.err) {
+			return class
+		}
+	}
+	return errorClass{code: "error", exitCode: ExitFailure}
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return classify(err).exitCode
+}
+
+// errorEnvelope is the --json form of a failed command
+type errorEnvelope struct {
+	Error    string `json:"error"`
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+	Success  bool   `json:"success"`
+}
+
+// ReportError prints an error returned by Execute: on stderr, or with --json
+// as an envelope on stdout naming its cause.
+func ReportError(err error) {
+	if !jsonOutput {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	class := classify(err)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(errorEnvelope{Error: err.Error(), Code: class.code, ExitCode: class.exitCode})
+}
+
+// codedError restores the class of an error that crossed a process
+// boundary as a code and a message, e.g. from the daemon.
+type codedError struct {
+	message string
+	class   error
+}
+
+func (e *codedError) Error() string { return e.message }
+
+func (e *codedError) Unwrap() error { return e.class }
+
+// errorFromCode rebuilds an error reported with the code classify gave it.
+func errorFromCode(code, message string) error {
+	for _, class := range errorClasses {
+		if class.code == code {
+			return &codedError{message: message, class: class.err}
+		}
+	}
+	return errors.New(message)
+}
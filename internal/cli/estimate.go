@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+)
+
+// modelEstimate is the cost preview for a single model
+type modelEstimate struct {
+	llm.ModelRef
+	Cost       float64 `json:"cost"`
+	Known      bool    `json:"pricing_known"`
+	Configured bool    `json:"configured"`
+}
+
+type estimateReport struct {
+	InputTokens     int             `json:"input_tokens"`
+	OutputTokens    int             `json:"output_tokens"`
+	Models          []modelEstimate `json:"models"`
+	DailyLimit      float64         `json:"daily_limit"`
+	RemainingBudget float64         `json:"remaining_budget"`
+}
+
+// runEstimate builds the prompt a question would send and prints its estimated
+// cost per model, without creating any model client or calling any API.
+func runEstimate(dir, userQuery string) error {
+	contextString, _, err := loadQueryContext(dir, userQuery)
+	if err == nil {
+		contextString, err = withContextFiles(contextString)
+	}
+	if err != nil {
+		return err
+	}
+
+	report := &estimateReport{
+		InputTokens:  llm.EstimatePromptTokens(userQuery, contextString),
+		OutputTokens: llm.EstimatedOutputTokens,
+	}
+
+	configured := make(map[string]bool)
+	for _, ref := range llm.ConfiguredModels() {
+		cost, known := llm.EstimateCost(ref, report.InputTokens, report.OutputTokens)
+		report.Models = append(report.Models, modelEstimate{ModelRef: ref, Cost: cost, Known: known, Configured: true})
+		configured[ref.Model] = true
+	}
+	// Known Bedrock prices for comparison
+	for _, model := range llm.ModelCosts {
+		if configured[model.ModelID] {
+			continue
+		}
+		ref := llm.ModelRef{Backend: string(llm.AWSModelBedrock), Model: model.ModelID}
+		cost, _ := llm.EstimateCost(ref, report.InputTokens, report.OutputTokens)
+		report.Models = append(report.Models, modelEstimate{ModelRef: ref, Cost: cost, Known: true})
+	}
+
+	report.DailyLimit = llm.DailyLimit()
+	report.RemainingBudget = llm.NewCostManager(report.DailyLimit).GetRemainingBudget()
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   userQuery,
+			Data:    report,
+			Success: true,
+		})
+	}
+
+	fmt.Println("🧮 Cost estimate (nothing was sent)")
+	fmt.Printf("   Prompt: ~%d input tokens, ~%d output tokens assumed\n\n", report.InputTokens, report.OutputTokens)
+
+	if len(configured) == 0 {
+		fmt.Println("   No model configured – run 'cloudai setup-interactive' first.")
+	}
+	for _, m := range report.Models {
+		marker := "  "
+		if m.Configured {
+			marker = "👉"
+		}
+		cost := fmt.Sprintf("$%.4f", m.Cost)
+		switch {
+		case !m.Known:
+			cost = "pricing unknown"
+		case m.Backend == "ollama":
+			cost = "free (local)"
+		case m.Backend == "tgi":
+			cost = "free (self-hosted)"
+		}
+		fmt.Printf("   %s %-10s %-45s %s\n", marker, m.Backend, m.Model, cost)
+	}
+
+	fmt.Printf("\n💰 Daily budget: $%.2f (remaining: $%.2f)\n", report.DailyLimit, report.RemainingBudget)
+	return nil
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [path]",
+	Short: "Export the scanned resources as CSV, TSV or an edge list",
+	Long: `Exports the infrastructure cache in a form other tools can load without
+parsing cache.json:
+
+  csv    one row per resource: id, type, name, arn and the properties as JSON
+  tsv    the same columns, tab-separated with a UTF-8 byte order mark so
+         Excel opens it with the right encoding
+  edges  source,target,relation CSV of the references between resources,
+         ready for Neo4j LOAD CSV or Gephi
+  dot    the same graph in Graphviz format (dot -Tsvg graph.dot > graph.svg)
+
+Output goes to stdout unless --output is given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runExport,
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	var write func(io.Writer, map[string]interface{}) error
+	switch exportFormat {
+	case "csv":
+		write = writeCSV
+	case "tsv":
+		write = writeTSV
+	case "edges":
+		write = writeEdgeList
+	case "dot":
+		write = writeDOT
+	default:
+		return fmt.Errorf("unknown format %q: use csv, tsv, edges or dot", exportFormat)
+	}
+
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+
+	if exportOutput == "" {
+		return write(os.Stdout, infraState)
+	}
+	f, err := os.Create(exportOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", exportOutput, err)
+	}
+	if err := write(f, infraState); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Exported to %s\n", exportOutput)
+	return nil
+}
+
+func writeCSV(w io.Writer, infraState map[string]interface{}) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "type", "name", "arn", "properties"})
+	for _, row := range state.Inventory(infraState) {
+		cw.Write([]string{row.ID, row.Type, row.Name, row.ARN, row.Properties})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTSV writes the inventory as plain tab-separated lines. TSV has no
+// quoting, so tabs and line breaks inside fields become spaces.
+func writeTSV(w io.Writer, infraState map[string]interface{}) error {
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+	var b strings.Builder
+	b.WriteString("\ufeffid\ttype\tname\tarn\tproperties\n")
+	for _, row := range state.Inventory(infraState) {
+		fields := []string{row.ID, row.Type, row.Name, row.ARN, row.Properties}
+		for i, field := range fields {
+			fields[i] = clean.Replace(field)
+		}
+		b.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeEdgeList(w io.Writer, infraState map[string]interface{}) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source", "target", "relation"})
+	for _, edge := range state.Edges(infraState) {
+		cw.Write([]string{edge.From, edge.To, edge.Relation})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeDOT(w io.Writer, infraState map[string]interface{}) error {
+	var b strings.Builder
+	b.WriteString("digraph infrastructure {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, row := range state.Inventory(infraState) {
+		label := row.ID
+		if row.Name != "" && row.Name != row.ID {
+			label += "\\n" + row.Name
+		}
+		label += "\\n" + row.Type
+		fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(row.ID), dotQuote(label))
+	}
+	for _, edge := range state.Edges(infraState) {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Relation))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote quotes a Graphviz ID, keeping the \n line breaks in labels.
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "csv", "export format: csv, tsv, edges or dot")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write instead of stdout")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// maxFindingSteps is how many groups the next steps are printed for
+const maxFindingSteps = 5
+
+var findingsCmd = &cobra.Command{
+	Use:   "findings [path]",
+	Short: "Summarize active GuardDuty and Security Hub findings",
+	Long: `Pulls the active GuardDuty and Security Hub findings of the region, merges
+findings of the same kind across resources and sources, and matches the
+affected resources to the infrastructure cache.
+
+  cloudai findings
+  cloudai findings --severity high ./infra
+
+Groups are ranked by severity, how many cached resources depend on the
+affected ones, recent activity and whether GuardDuty reports an active
+threat, and each comes with suggested next steps. Without a cache the
+findings are still listed, only not matched to the scan. Unless
+--no-summary is given, the model then writes a short summary of what to
+handle first.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runFindings,
+}
+
+func runFindings(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if findingsLimit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+	minRank := 0
+	if findingsSeverity != "" {
+		minRank = state.SeverityRank(findingsSeverity)
+		if minRank == 0 {
+			return fmt.Errorf("--severity must be one of critical, high, medium or low")
+		}
+	}
+	infraState, err := loadCachedState(dir)
+	if errors.Is(err, state.ErrNoCache) {
+		fmt.Fprintln(os.Stderr, "⚠️  No infrastructure cache here; findings will not be matched to the scan. Run `cloudai scan` first to rank them by what depends on the affected resources.")
+		infraState = map[string]interface{}{}
+	} else if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	noteOperation("collecting active GuardDuty and Security Hub findings")
+	report, err := state.CollectFindings(ctx, client, infraState, findingsLimit, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, service := range report.Disabled {
+		fmt.Fprintf(os.Stderr, "⚠️  %s is not enabled in this region\n", service)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipped %s: grant %s\n", warning.Service, strings.Join(warning.Grant, ", "))
+	}
+	if minRank > 0 {
+		var groups []state.FindingGroup
+		for _, group := range report.Groups {
+			if state.SeverityRank(group.Severity) >= minRank {
+				groups = append(groups, group)
+			}
+		}
+		report.Groups = groups
+	}
+
+	summary := ""
+	if !findingsNoSummary && len(report.Groups) > 0 {
+		summary, err = findingsSummary(ctx, dir, report)
+		if err != nil {
+			// the ranked groups stand on their own
+			fmt.Fprintf(os.Stderr, "⚠️  Could not write the findings summary: %v\n", err)
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "findings",
+			Data: map[string]interface{}{
+				"findings": report,
+				"summary":  summary,
+			},
+			Success: true,
+		})
+	}
+
+	if len(report.Groups) == 0 {
+		fmt.Println("✅ No active findings")
+		return nil
+	}
+	table := output.Table{
+		Title:   fmt.Sprintf("%d finding(s) in %d group(s)", report.Total, len(report.Groups)),
+		Headers: []string{"#", "Severity", "Finding", "Source", "Resources", "In scan", "Dependents", "Last seen"},
+	}
+	for i, group := range report.Groups {
+		lastSeen := ""
+		if !group.LastSeen.IsZero() {
+			lastSeen = group.LastSeen.Format("2006-01-02 15:04")
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.Itoa(i + 1),
+			group.Severity,
+			truncate(group.Title, 60),
+			strings.Join(group.Sources, ", "),
+			strconv.Itoa(len(group.Resources)),
+			truncate(strings.Join(group.InScan, ", "), 40),
+			strconv.Itoa(group.Dependents),
+			lastSeen,
+		})
+	}
+	table.Print()
+
+	fmt.Println("\nNext steps:")
+	for i, group := range report.Groups {
+		if i == maxFindingSteps {
+			fmt.Printf("   ... and %d more group(s); use --json for all of them\n", len(report.Groups)-i)
+			break
+		}
+		fmt.Printf("%d. %s\n", i+1, group.Title)
+		for _, step := range group.NextSteps {
+			fmt.Printf("   - %s\n", step)
+		}
+	}
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+// findingsSummary asks the model what to handle first.
+func findingsSummary(ctx context.Context, dir string, report *state.FindingsReport) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	groups := report.Groups
+	if len(groups) > 20 {
+		groups = groups[:20]
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": "security_findings",
+		"result": groups,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resources []string
+	for _, group := range groups {
+		resources = append(resources, group.InScan...)
+	}
+	question := "Write a short summary of the account's active security findings: say which to handle first and why, and what to do about them."
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, question, string(result))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, resources)
+	return text, err
+}
+
+func init() {
+	findingsCmd.Flags().IntVar(&findingsLimit, "limit", 200, "most findings to pull from each service")
+	findingsCmd.Flags().StringVar(&findingsSeverity, "severity", "", "only show groups of at least this severity (critical, high, medium, low)")
+	findingsCmd.Flags().BoolVar(&findingsNoSummary, "no-summary", false, "rank the findings without asking the model for a summary")
+	rootCmd.AddCommand(findingsCmd)
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/mdp/qrterminal/v3"
+)
+
+// isHeadless reports whether there is no local browser to open: --no-browser
+// was passed, the session is over SSH, it runs in CI, or a Linux/BSD machine
+// has no display.
+func isHeadless() bool {
+	if noBrowser {
+		return true
+	}
+	for _, env := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY", "CI"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return false
+	default:
+		return os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+	}
+}
+
+// presentURL opens url in the local browser or, on a headless machine, prints
+// it with a QR code so it can be opened on a phone or another computer. It
+// reports whether a browser was opened.
+func presentURL(url string) bool {
+	if !isHeadless() {
+		fmt.Printf("📱 Opening AWS Console: %s\n", url)
+		if err := openBrowser(url); err == nil {
+			return true
+		}
+		fmt.Println("⚠️  Could not open a browser automatically")
+	} else {
+		fmt.Println("🖥️  No local browser (SSH session or headless machine)")
+	}
+
+	fmt.Println("📱 Open this URL on any device signed in to the AWS account:")
+	fmt.Printf("\n   %s\n\n", url)
+	qrterminal.GenerateHalfBlock(url, qrterminal.L, os.Stdout)
+	fmt.Println()
+	return false
+}
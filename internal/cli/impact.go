@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var impactCmd = &cobra.Command{
+	Use:   "impact <resource> [path]",
+	Short: "Show what breaks if a resource is deleted or throttled",
+	Long: `Walks the relationship graph of the infrastructure cache backwards from a
+resource to everything that references it, directly or through other
+resources, and prints that blast radius as a tree:
+
+  cloudai impact OrdersTable
+  cloudai impact orders-table-prod ./infra
+
+The resource can be given by logical ID, name or ARN. Each line names the
+property through which the resource depends on its parent. Unless
+--no-summary is given, the model then summarizes the impact on each
+dependent, most severe first.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runImpact,
+}
+
+func runImpact(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	id, err := state.ResolveResource(infraState, args[0])
+	if err != nil {
+		return err
+	}
+	impact := state.Impact(infraState, id)
+
+	summary := ""
+	if !impactNoSummary && impact.Count() > 0 {
+		summary, err = impactSummary(context.Background(), dir, impact)
+		if err != nil {
+			// the tree stands on its own
+			fmt.Fprintf(os.Stderr, "⚠️  Could not summarize the impact: %v\n", err)
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "impact " + id,
+			Data: map[string]interface{}{
+				"resource": impact,
+				"affected": impact.Count(),
+				"summary":  summary,
+			},
+			Success: true,
+		})
+	}
+
+	fmt.Printf("💥 Blast radius of %s\n\n", id)
+	fmt.Println(impactLabel(impact))
+	printImpactTree(impact.Dependents, "")
+	if impact.Count() == 0 {
+		fmt.Println("\n✅ Nothing in the cache references this resource")
+		return nil
+	}
+	fmt.Printf("\n%d resource(s) affected\n", impact.Count())
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+// impactSummary asks the model what the blast radius means in practice.
+func impactSummary(ctx context.Context, dir string, impact *state.ImpactNode) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": "impact",
+		"result": impact,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	question := fmt.Sprintf("What breaks if %s is deleted or throttled? Summarize the impact on each dependent resource, most severe first.", impact.ID)
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, question, string(result))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, []string{impact.ID})
+	return text, err
+}
+
+func impactLabel(node *state.ImpactNode) string {
+	label := node.ID + " (" + node.Type + ")"
+	if node.Name != "" && node.Name != node.ID {
+		label += " " + node.Name
+	}
+	if node.DefinedIn != "" {
+		label += " — " + node.DefinedIn
+	}
+	return label
+}
+
+// printImpactTree prints dependents below their parent, with the property
+// each one references the parent through.
+func printImpactTree(nodes []*state.ImpactNode, indent string) {
+	for i, node := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Printf("%s%s%s via %s\n", indent, branch, impactLabel(node), node.Relation)
+		printImpactTree(node.Dependents, indent+next)
+	}
+}
+
+func init() {
+	impactCmd.Flags().BoolVar(&impactNoSummary, "no-summary", false, "print the tree without asking the model for a summary")
+	rootCmd.AddCommand(impactCmd)
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file> [path]",
+	Short: "Merge an external inventory into the infrastructure cache",
+	Long: `Adds resources from another inventory to the project's infrastructure cache,
+so questions can cover resources the IaC files or live scan do not:
+
+  config-snapshot  an AWS Config snapshot (configurationItems) or the output of
+                   'aws configservice select-aggregate-resource-config'
+  steampipe        'steampipe query --output json' results; the type comes from
+                   each row's arn, or --type
+  csv              a CSV or TSV with a header row: id, type, name, arn and
+                   properties (JSON) are recognised, other columns become
+                   properties; 'cloudai export' output can be imported back
+
+The format is detected from the content unless --format is given. Importing
+the same file again replaces the resources it added before, and imported
+resources are kept when the project is rescanned.
+
+Examples:
+  cloudai import --format config-snapshot snapshot.json
+  steampipe query "select * from aws_s3_bucket" --output json > buckets.json
+  cloudai import buckets.json`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runImport,
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	file := args[0]
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	format := importFormat
+	if format == "" {
+		format = state.DetectImportFormat(data)
+	}
+	imported, err := state.Import(format, data, importType)
+	if err != nil {
+		return err
+	}
+	if len(imported) == 0 {
+		return fmt.Errorf("no resources found in %s (read as %s)", file, format)
+	}
+
+	cacheManager, err := newCacheManager(dir)
+	if err != nil {
+		return err
+	}
+	infraState := map[string]interface{}{"Source": "import"}
+	// Saving re-signs the cache, so an edited one is refused rather than blessed.
+	if cacheManager.Exists() {
+		if infraState, err = loadCache(dir, true); err != nil {
+			return err
+		}
+	}
+
+	source := format + ":" + filepath.Base(file)
+	added := state.MergeImport(infraState, imported, source)
+	if err := cacheManager.Save(infraState); err != nil {
+		return fmt.Errorf("could not save cache: %w", err)
+	}
+
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	fmt.Printf("✅ Imported %d resources from %s (%s); the cache now holds %d resources\n", added, file, format, len(resources))
+	return nil
+}
+
+// keepImports carries the imported resources of the existing cache over to
+// a fresh scan. Imports from a cache that fails its integrity check are
+// dropped, since the scan signs whatever it keeps.
+func keepImports(cacheManager *state.CacheManager, infraState map[string]interface{}) {
+	if !cacheManager.Exists() {
+		return
+	}
+	previous, err := cacheManager.Load()
+	if state.IsUntrusted(err) {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: imported resources were not kept: %v. Run `cloudai import` again to restore them.\n", err)
+		return
+	}
+	if err != nil {
+		return
+	}
+	state.KeepImports(previous, infraState)
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importFormat, "format", "", "inventory format: "+strings.Join(state.ImportFormats, ", ")+" (detected when omitted)")
+	importCmd.Flags().StringVar(&importType, "type", "", "resource type for rows without a type or arn, e.g. AWS::S3::Bucket")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var incidentCmd = &cobra.Command{
+	Use:   "incident <description> [path]",
+	Short: "Triage a failure from recent alarms, error metrics and changes",
+	Long: `Finds the resources on the failing path in the infrastructure cache, the
+ones the description names and everything they depend on, then pulls what
+happened to them recently:
+
+  - CloudWatch alarms currently in ALARM
+  - error and throttle metrics of functions, APIs and tables
+  - write calls recorded by CloudTrail
+
+  cloudai incident "checkout is failing"
+  cloudai incident "OrdersFunction times out" --since 6h ./infra
+
+Resources with a signal are ranked as likely causes. Unless --no-summary is
+given, the model then writes a triage summary from that evidence.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runIncident,
+}
+
+func runIncident(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	if incidentSince <= 0 {
+		return fmt.Errorf("--since must be positive")
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	now := time.Now()
+	noteOperation("collecting alarms, metrics and CloudTrail events of the last %s", incidentSince)
+	incident, err := state.Triage(ctx, client, infraState, args[0], now.Add(-incidentSince), now)
+	if err != nil {
+		return err
+	}
+	for _, warning := range incident.Warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipped %s: grant %s\n", warning.Service, strings.Join(warning.Grant, ", "))
+	}
+
+	summary := ""
+	if !incidentNoSummary && len(incident.Causes) > 0 {
+		summary, err = incidentSummary(ctx, dir, incident)
+		if err != nil {
+			// the ranked evidence stands on its own
+			fmt.Fprintf(os.Stderr, "⚠️  Could not write the triage summary: %v\n", err)
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "incident",
+			Data: map[string]interface{}{
+				"incident": incident,
+				"summary":  summary,
+			},
+			Success: true,
+		})
+	}
+
+	fmt.Printf("🚨 %s: %d resource(s) on the path, last %s\n\n", incident.Description, len(incident.Path), incidentSince)
+	if len(incident.Causes) == 0 {
+		fmt.Println("✅ No alarms, errors or changes on the path in that window")
+		return nil
+	}
+	for i, cause := range incident.Causes {
+		fmt.Printf("%d. %s (%s), score %d\n", i+1, cause.ID, cause.Type, cause.Score)
+		for _, evidence := range cause.Evidence {
+			fmt.Printf("   - %s\n", evidence)
+		}
+	}
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+// incidentSummary asks the model to rank the likely causes of the incident.
+func incidentSummary(ctx context.Context, dir string, incident *state.Incident) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": "incident_triage",
+		"result": incident,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resources []string
+	for _, cause := range incident.Causes {
+		resources = append(resources, cause.ID)
+	}
+	question := fmt.Sprintf("%q is happening. Write a short triage summary: rank the likely causes from the alarms, error metrics and recent changes, and say what to check first.", incident.Description)
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, question, string(result))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, resources)
+	return text, err
+}
+
+func init() {
+	incidentCmd.Flags().DurationVar(&incidentSince, "since", 3*time.Hour, "how far back to look for alarms, errors and changes")
+	incidentCmd.Flags().BoolVar(&incidentNoSummary, "no-summary", false, "rank the causes without asking the model for a summary")
+	rootCmd.AddCommand(incidentCmd)
+}
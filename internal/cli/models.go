@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Check and enable access to Bedrock foundation models",
+}
+
+var modelsStatusCmd = &cobra.Command{
+	Use:   "status [model-id...]",
+	Short: "Show whether Bedrock models are enabled in the current region",
+	Long: `Shows region, authorization, entitlement and agreement status for each model.
+Without arguments the models CloudAI-CLI knows the pricing of are listed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		client, region, err := newBedrockControlClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		table := &output.Table{
+			Title:   fmt.Sprintf("Bedrock model access in %s", region),
+			Headers: []string{"Model", "Region", "Authorized", "Entitled", "Agreement"},
+		}
+		for _, modelID := range modelIDsOrDefault(args) {
+			availability, err := client.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+				ModelId: awssdk.String(modelID),
+			})
+			if err != nil {
+				table.Rows = append(table.Rows, []string{modelID, "?", "?", "?", "❌ " + err.Error()})
+				continue
+			}
+			table.Rows = append(table.Rows, []string{
+				modelID,
+				string(availability.RegionAvailability),
+				string(availability.AuthorizationStatus),
+				string(availability.EntitlementAvailability),
+				agreementStatus(availability.AgreementAvailability),
+			})
+		}
+		return output.NewFormatter(false).FormatResult(&output.Result{Query: "models status", Data: table, Success: true})
+	},
+}
+
+var modelsEnableCmd = &cobra.Command{
+	Use:   "enable [model-id...]",
+	Short: "Enable Bedrock model access without the console",
+	Long: `Enables access to Bedrock foundation models through the Bedrock API by
+accepting each model's public Marketplace offer, the same agreement the
+console's 'Model access' page creates. Without arguments the models
+CloudAI-CLI knows the pricing of are enabled.
+
+Anthropic models also require a one-time use case form per account. If it
+has not been submitted, pass it as JSON with --use-case-file or submit it
+once in the console.
+
+Accepting an offer subscribes the account to the model; you will be asked
+to confirm unless --yes is given. Requires bedrock:GetFoundationModelAvailability,
+bedrock:ListFoundationModelAgreementOffers, bedrock:CreateFoundationModelAgreement,
+aws-marketplace:Subscribe and aws-marketplace:ViewSubscriptions (plus
+bedrock:GetUseCaseForModelAccess and bedrock:PutUseCaseForModelAccess for
+Anthropic models).`,
+	RunE: runModelsEnable,
+}
+
+func runModelsEnable(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, region, err := newBedrockControlClient(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔧 Enabling Bedrock model access in %s\n\n", region)
+
+	var pending []string
+	failed := 0
+	for _, modelID := range modelIDsOrDefault(args) {
+		enabled, err := enableBedrockModel(ctx, client, modelID)
+		switch {
+		case err != nil:
+			fmt.Printf("❌ %s: %v\n", modelID, err)
+			failed++
+		case enabled:
+			fmt.Printf("✅ %s: access enabled\n", modelID)
+		default:
+			pending = append(pending, modelID)
+		}
+	}
+
+	if len(pending) > 0 && modelsWait > 0 {
+		fmt.Printf("\n⏳ Waiting up to %s for %d agreement(s) to become active...\n", modelsWait, len(pending))
+		pending = waitForAgreements(ctx, client, pending, modelsWait)
+	}
+	for _, modelID := range pending {
+		fmt.Printf("⏳ %s: agreement still pending; check with 'cloudai models status'\n", modelID)
+	}
+
+	if failed > 0 {
+		consoleURL := fmt.Sprintf("https://%s.console.aws.amazon.com/bedrock/home?region=%s#/modelaccess", region, region)
+		fmt.Printf("\n💡 Models that cannot be enabled through the API can still be enabled at %s\n", consoleURL)
+		return fmt.Errorf("failed to enable %d model(s)", failed)
+	}
+	return nil
+}
+
+// enableBedrockModel accepts the model's public offer. It reports true when
+// access is already active and false when the agreement is still pending.
+func enableBedrockModel(ctx context.Context, client *bedrock.Client, modelID string) (bool, error) {
+	availability, err := client.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+		ModelId: awssdk.String(modelID),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check availability: %w", err)
+	}
+	if availability.RegionAvailability == bedrocktypes.RegionAvailabilityNotAvailable {
+		return false, fmt.Errorf("not offered in this region")
+	}
+	if availability.AuthorizationStatus == bedrocktypes.AuthorizationStatusNotAuthorized {
+		return false, fmt.Errorf("not authorized; an IAM or SCP policy denies access to this model")
+	}
+	if agreement := availability.AgreementAvailability; agreement != nil {
+		switch agreement.Status {
+		case bedrocktypes.AgreementStatusAvailable:
+			return true, nil
+		case bedrocktypes.AgreementStatusPending:
+			return false, nil
+		}
+	}
+
+	if strings.HasPrefix(modelID, "anthropic.") {
+		if err := ensureUseCaseSubmitted(ctx, client); err != nil {
+			return false, err
+		}
+	}
+
+	offers, err := client.ListFoundationModelAgreementOffers(ctx, &bedrock.ListFoundationModelAgreementOffersInput{
+		ModelId:   awssdk.String(modelID),
+		OfferType: bedrocktypes.OfferTypePublic,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list offers: %w", err)
+	}
+	if len(offers.Offers) == 0 || offers.Offers[0].OfferToken == nil {
+		return false, fmt.Errorf("no public offer available to accept")
+	}
+
+	if !modelsYes && !confirm(fmt.Sprintf("Accept the AWS Marketplace offer for %s? (y/N): ", modelID)) {
+		return false, fmt.Errorf("skipped")
+	}
+	if _, err := client.CreateFoundationModelAgreement(ctx, &bedrock.CreateFoundationModelAgreementInput{
+		ModelId:    awssdk.String(modelID),
+		OfferToken: offers.Offers[0].OfferToken,
+	}); err != nil {
+		return false, fmt.Errorf("failed to accept offer: %w", err)
+	}
+	return false, nil
+}
+
+// ensureUseCaseSubmitted submits the Anthropic use case form from
+// --use-case-file unless the account has already submitted one.
+func ensureUseCaseSubmitted(ctx context.Context, client *bedrock.Client) error {
+	if _, err := client.GetUseCaseForModelAccess(ctx, &bedrock.GetUseCaseForModelAccessInput{}); err == nil {
+		return nil
+	} else if !errors.As(err, new(*bedrocktypes.ResourceNotFoundException)) {
+		return fmt.Errorf("failed to check the Anthropic use case form: %w", err)
+	}
+
+	if modelsUseCaseFile == "" {
+		return fmt.Errorf("the Anthropic use case form has not been submitted for this account; pass it with --use-case-file")
+	}
+	formData, err := os.ReadFile(modelsUseCaseFile)
+	if err != nil {
+		return fmt.Errorf("failed to read use case file: %w", err)
+	}
+	if _, err := client.PutUseCaseForModelAccess(ctx, &bedrock.PutUseCaseForModelAccessInput{FormData: formData}); err != nil {
+		return fmt.Errorf("failed to submit the Anthropic use case form: %w", err)
+	}
+	fmt.Println("📝 Submitted the Anthropic use case form")
+	return nil
+}
+
+// waitForAgreements polls until the agreements are active or timeout passes,
+// returning the models that are still pending.
+func waitForAgreements(ctx context.Context, client *bedrock.Client, modelIDs []string, timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for len(modelIDs) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Second)
+
+		var stillPending []string
+		for _, modelID := range modelIDs {
+			availability, err := client.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+				ModelId: awssdk.String(modelID),
+			})
+			if err == nil && availability.AgreementAvailability != nil &&
+				availability.AgreementAvailability.Status == bedrocktypes.AgreementStatusAvailable {
+				fmt.Printf("✅ %s: access enabled\n", modelID)
+				continue
+			}
+			stillPending = append(stillPending, modelID)
+		}
+		modelIDs = stillPending
+	}
+	return modelIDs
+}
+
+// newBedrockControlClient creates a Bedrock control plane client for the
+// configured profile, region and endpoint.
+func newBedrockControlClient(ctx context.Context) (*bedrock.Client, string, error) {
+	cfg, err := aws.LoadConfig(ctx, awsClientOptions()...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return bedrock.NewFromConfig(cfg), cfg.Region, nil
+}
+
+// modelIDsOrDefault returns args, or the Bedrock models with known pricing.
+func modelIDsOrDefault(args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	modelIDs := make([]string, 0, len(llm.ModelCosts))
+	for _, model := range llm.ModelCosts {
+		modelIDs = append(modelIDs, model.ModelID)
+	}
+	return modelIDs
+}
+
+func agreementStatus(agreement *bedrocktypes.AgreementAvailability) string {
+	if agreement == nil {
+		return "-"
+	}
+	if agreement.Status == bedrocktypes.AgreementStatusError && agreement.ErrorMessage != nil {
+		return fmt.Sprintf("%s (%s)", agreement.Status, *agreement.ErrorMessage)
+	}
+	return string(agreement.Status)
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+}
+
+func init() {
+	modelsEnableCmd.Flags().BoolVarP(&modelsYes, "yes", "y", false, "accept the Marketplace offers without asking")
+	modelsEnableCmd.Flags().StringVar(&modelsUseCaseFile, "use-case-file", "", "JSON use case form to submit for Anthropic models")
+	modelsEnableCmd.Flags().DurationVar(&modelsWait, "wait", 2*time.Minute, "how long to wait for agreements to become active (0 to not wait)")
+	modelsCmd.AddCommand(modelsStatusCmd, modelsEnableCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
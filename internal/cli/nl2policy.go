@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// maxPolicyTargets caps the resources listed to the model; those the
+// request mentions come first
+const maxPolicyTargets = 300
+
+var nl2policyCmd = &cobra.Command{
+	Use:   "nl2policy <request> [path]",
+	Short: "Turn a plain-language request into a reviewed IAM policy (never applied)",
+	Long: `Drafts a least-privilege IAM policy for a request in plain language, using the
+ARNs of the resources in the infrastructure cache:
+
+  cloudai nl2policy "allow the reporting Lambda to read the orders table and write to the reports bucket"
+  cloudai nl2policy "let the ingest function publish to the alerts topic" ./infra > policy.json
+
+The draft is then reviewed locally: every resource must be in the scan, and
+wildcard, destructive and privilege-escalating actions are flagged. The
+policy JSON is printed to stdout and the review to stderr, so the policy can
+be redirected to a file.
+
+The command runs in plan mode: nothing is applied, and a cache that fails
+its integrity check is refused rather than trusted. ARNs the scan derived
+from templates contain <region> and <account> where the scan does not know
+them; fill those in before using the policy.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runNL2Policy,
+}
+
+func runNL2Policy(cmd *cobra.Command, args []string) error {
+	// the model's output is a security artifact, so the context it is built
+	// from must be trusted: plan mode refuses a tampered cache
+	planMode = true
+
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	targets := state.PolicyTargets(infraState)
+	if len(targets) == 0 {
+		return fmt.Errorf("the scan has no resources with an ARN or a name to build one from")
+	}
+
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	mentioned := state.MentionedResources(infraState, args[0])
+	start := time.Now()
+	draft, err := models.router.DraftPolicy(ctx, args[0], policyTargetList(targets, mentioned))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, mentioned)
+	if err != nil {
+		return err
+	}
+
+	policy := state.IAMPolicy{Version: "2012-10-17"}
+	reasons := map[string]string{}
+	for _, row := range draft.Statements {
+		policy.Statement = append(policy.Statement, state.IAMStatement{
+			Sid:      row.Sid,
+			Effect:   row.Effect,
+			Action:   row.Actions,
+			Resource: row.Resources,
+		})
+		reasons[row.Sid] = row.Reason
+	}
+	findings := state.ReviewPolicy(policy, targets)
+	attachTo := policyRole(infraState, targets, draft.Principal)
+	problems := 0
+	for _, finding := range findings {
+		if finding.Severity == "error" {
+			problems++
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "nl2policy " + args[0],
+			Data: map[string]interface{}{
+				"principal":   draft.Principal,
+				"attach_to":   attachTo,
+				"policy":      policy,
+				"reasons":     reasons,
+				"findings":    findings,
+				"assumptions": draft.Assumptions,
+				"applied":     false,
+			},
+			Success: problems == 0,
+		})
+	}
+
+	fmt.Fprintf(os.Stderr, "📝 Policy for %s", draft.Principal)
+	if attachTo != "" {
+		fmt.Fprintf(os.Stderr, ", to attach to %s", attachTo)
+	}
+	fmt.Fprintln(os.Stderr)
+	for _, statement := range policy.Statement {
+		if reason := reasons[statement.Sid]; reason != "" {
+			fmt.Fprintf(os.Stderr, "   • %s: %s\n", statement.Sid, reason)
+		}
+	}
+	if len(draft.Assumptions) > 0 {
+		fmt.Fprintln(os.Stderr, "\n💭 Assumptions:")
+		for _, assumption := range draft.Assumptions {
+			fmt.Fprintf(os.Stderr, "   • %s\n", assumption)
+		}
+	}
+	if len(findings) > 0 {
+		fmt.Fprintln(os.Stderr, "\n🔍 Review:")
+		for _, finding := range findings {
+			icon := "⚠️ "
+			if finding.Severity == "error" {
+				icon = "❌"
+			}
+			fmt.Fprintf(os.Stderr, "   %s %s: %s\n", icon, finding.Statement, finding.Message)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "\n✅ Review: every resource is in the scan and every action is specific")
+	}
+	fmt.Fprintln(os.Stderr, "\n📋 Plan only: the policy below was not applied.")
+
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	if problems > 0 {
+		return fmt.Errorf("the review found %d problem(s); fix them before using the policy", problems)
+	}
+	return nil
+}
+
+// policyTargetList renders the targets for the prompt, the mentioned ones
+// first, one per line.
+func policyTargetList(targets []state.PolicyTarget, mentioned []string) string {
+	first := map[string]bool{}
+	for _, id := range mentioned {
+		first[id] = true
+	}
+	var lines, rest []string
+	for _, target := range targets {
+		line := strings.Join([]string{target.ID, target.Type, target.Name, target.ARN}, " | ")
+		if first[target.ID] {
+			lines = append(lines, line)
+		} else {
+			rest = append(rest, line)
+		}
+	}
+	lines = append(lines, rest...)
+	if len(lines) > maxPolicyTargets {
+		lines = lines[:maxPolicyTargets]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// policyRole returns the role a policy for principal is attached to: the
+// execution role of a function or state machine, or the role itself.
+func policyRole(infraState map[string]interface{}, targets []state.PolicyTarget, principal string) string {
+	id, err := state.ResolveResource(infraState, principal)
+	if err != nil {
+		return ""
+	}
+	for _, target := range targets {
+		if target.ID != id {
+			continue
+		}
+		if target.Role != "" {
+			return target.Role
+		}
+		if target.Type == "AWS::IAM::Role" {
+			return target.ARN
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(nl2policyCmd)
+}
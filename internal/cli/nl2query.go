@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// maxQueryLogGroups caps the log groups listed to the model
+	maxQueryLogGroups = 500
+	// maxQueryRowsShown caps the result rows printed; --json has them all
+	maxQueryRowsShown = 50
+	// maxQueryRowsSummarized caps the result rows the model summarizes
+	maxQueryRowsSummarized = 200
+)
+
+// Query sources: what nl2query writes a query for
+const (
+	querySourceLogs     = "logs"
+	querySourceCUR      = "cur"
+	querySourceFlowLogs = "flowlogs"
+)
+
+// queryTables are the Athena tables queried per source, the config key that
+// names each and the columns of the standard layout, used when the table
+// cannot be described
+var queryTables = map[string]struct {
+	key, name string
+	columns   []string
+}{
+	querySourceCUR: {"athena.cur_table", "cur", []string{
+		"line_item_usage_account_id string", "line_item_usage_start_date timestamp", "line_item_product_code string",
+		"line_item_usage_type string", "line_item_operation string", "line_item_resource_id string",
+		"line_item_line_item_type string", "line_item_usage_amount double", "line_item_unblended_cost double",
+		"product_region string", "year string (partition)", "month string (partition)",
+	}},
+	querySourceFlowLogs: {"athena.flow_logs_table", "vpc_flow_logs", []string{
+		"version int", "account_id string", "interface_id string", "srcaddr string", "dstaddr string",
+		"srcport int", "dstport int", "protocol bigint", "packets bigint", "bytes bigint", "start bigint",
+		"end bigint", "action string", "log_status string", "date date (partition)",
+	}},
+}
+
+// querySourceWords pick the source of a request when --source is not given
+var querySourceWords = map[string][]string{
+	querySourceCUR:      {"cost", "spend", "spent", "bill", "charge", "price", "cur ", "usage type", "$"},
+	querySourceFlowLogs: {"flow log", "traffic", "rejected", "reject", "accepted", "ip address", "port ", "packets", "srcaddr", "dstaddr"},
+}
+
+var nl2queryCmd = &cobra.Command{
+	Use:   "nl2query <request>",
+	Short: "Turn a plain-language request into a Logs Insights or Athena query",
+	Long: `Writes a CloudWatch Logs Insights query, or Athena SQL over the Cost and Usage
+Report (CUR) or VPC flow logs, for a request in plain language:
+
+  cloudai nl2query "errors in the checkout functions grouped by message"
+  cloudai nl2query "top 10 services by cost last month" --source cur
+  cloudai nl2query "rejected connections to port 22" --source flowlogs --run
+
+Without --source, the source is guessed from the request: costs go to the
+CUR, traffic to the flow logs, anything else to Logs Insights. The model sees
+the names of the account's log groups, or the columns of the Athena table,
+not their contents.
+
+The query is printed to stdout; with --run it is also executed and the
+results are printed and summarized. Only queries run: SQL that could change
+data is refused before it is sent. Logs Insights and Athena bill for the data
+a query scans, which is shown after it runs.
+
+Athena is configured in ~/.cloudai.yaml:
+
+  athena:
+    database: cur_db                       # required to run
+    workgroup: primary                     # default
+    catalog: AwsDataCatalog                # default
+    output_location: s3://my-athena-results/   # unless the workgroup sets one
+    cur_table: cur                         # default
+    flow_logs_table: vpc_flow_logs         # default`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNL2Query,
+}
+
+func runNL2Query(cmd *cobra.Command, args []string) error {
+	request := args[0]
+	source := nl2querySource
+	if source == "" {
+		source = guessQuerySource(request)
+	}
+	if source != querySourceLogs && source != querySourceCUR && source != querySourceFlowLogs {
+		return fmt.Errorf("--source must be %s, %s or %s", querySourceLogs, querySourceCUR, querySourceFlowLogs)
+	}
+	if nl2querySince <= 0 {
+		return fmt.Errorf("--since must be positive")
+	}
+
+	ctx := context.Background()
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	language, schema, logGroups := llm.QueryLanguageAthena, "", nl2queryGroups
+	target := athenaTarget()
+	if source == querySourceLogs {
+		language = llm.QueryLanguageInsights
+		if len(logGroups) == 0 {
+			noteOperation("listing log groups")
+			if logGroups, err = client.ListLogGroups(ctx, maxQueryLogGroups); err != nil {
+				return err
+			}
+			if len(logGroups) == 0 {
+				return fmt.Errorf("the account has no log groups in this region")
+			}
+		}
+		schema = strings.Join(logGroups, "\n")
+	} else {
+		schema = athenaTableSchema(ctx, client, target, source)
+	}
+
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return err
+	}
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	draft, err := models.router.DraftQuery(ctx, request, language, schema)
+	recordUsage(".", models.router.LastUsage(), time.Since(start), err == nil, nil)
+	if err != nil {
+		return err
+	}
+
+	// the model may only pick from the groups it was shown, and only
+	// queries may run
+	if language == llm.QueryLanguageInsights {
+		known := map[string]bool{}
+		for _, group := range logGroups {
+			known[group] = true
+		}
+		var groups []string
+		for _, group := range draft.LogGroups {
+			if known[group] {
+				groups = append(groups, group)
+			} else {
+				fmt.Fprintf(os.Stderr, "⚠️  Dropped log group %s, which is not in the account\n", group)
+			}
+		}
+		if len(groups) == 0 {
+			return fmt.Errorf("the drafted query names no log group of the account; pass --log-group")
+		}
+		draft.LogGroups = groups
+	} else if err := aws.CheckReadOnlySQL(draft.Query); err != nil {
+		return fmt.Errorf("the drafted query was refused: %w", err)
+	}
+
+	var result *aws.QueryResult
+	summary := ""
+	if nl2queryRun {
+		if result, err = runDraftedQuery(ctx, client, source, draft, target); err != nil {
+			return err
+		}
+		if !nl2queryNoSummary && len(result.Rows) > 0 {
+			summary, err = queryResultSummary(ctx, request, draft, result)
+			if err != nil {
+				// the rows stand on their own
+				fmt.Fprintf(os.Stderr, "⚠️  Could not summarize the results: %v\n", err)
+			}
+		}
+	}
+
+	if jsonOutput {
+		data := map[string]interface{}{
+			"source":      source,
+			"language":    language,
+			"query":       draft.Query,
+			"explanation": draft.Explanation,
+			"assumptions": draft.Assumptions,
+		}
+		if language == llm.QueryLanguageInsights {
+			data["log_groups"] = draft.LogGroups
+			data["since"] = nl2querySince.String()
+		} else {
+			data["athena"] = target
+		}
+		if result != nil {
+			data["result"] = result
+			data["summary"] = summary
+		}
+		return output.NewFormatter(true).FormatResult(&output.Result{Query: "nl2query " + request, Data: data, Success: true})
+	}
+
+	fmt.Fprintf(os.Stderr, "🔎 %s\n", draft.Explanation)
+	if len(draft.LogGroups) > 0 {
+		fmt.Fprintf(os.Stderr, "   Log groups: %s\n", strings.Join(draft.LogGroups, ", "))
+	}
+	for _, assumption := range draft.Assumptions {
+		fmt.Fprintf(os.Stderr, "   💭 %s\n", assumption)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Println(draft.Query)
+	if result == nil {
+		fmt.Fprintln(os.Stderr, "\n💡 Run it read-only with --run")
+		return nil
+	}
+
+	fmt.Println()
+	printQueryResult(result)
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+// guessQuerySource picks the source a request is about from its words.
+func guessQuerySource(request string) string {
+	lower := strings.ToLower(request) + " "
+	for _, source := range []string{querySourceCUR, querySourceFlowLogs} {
+		for _, word := range querySourceWords[source] {
+			if strings.Contains(lower, word) {
+				return source
+			}
+		}
+	}
+	return querySourceLogs
+}
+
+// athenaTarget reads where Athena queries run from the config.
+func athenaTarget() aws.AthenaTarget {
+	target := aws.AthenaTarget{
+		Catalog:        getConfigString("athena.catalog"),
+		Database:       getConfigString("athena.database"),
+		WorkGroup:      getConfigString("athena.workgroup"),
+		OutputLocation: getConfigString("athena.output_location"),
+	}
+	if target.Catalog == "" {
+		target.Catalog = "AwsDataCatalog"
+	}
+	if target.WorkGroup == "" {
+		target.WorkGroup = "primary"
+	}
+	return target
+}
+
+// flowLogsTable is the Athena table of flow logs in S3, or nil when Athena
+// is not configured.
+func flowLogsTable() *aws.FlowLogsTable {
+	target := athenaTarget()
+	if target.Database == "" {
+		return nil
+	}
+	table := queryTables[querySourceFlowLogs]
+	name := getConfigString(table.key)
+	if name == "" {
+		name = table.name
+	}
+	return &aws.FlowLogsTable{AthenaTarget: target, Table: name}
+}
+
+// athenaTableSchema describes the source's table to the model: its columns
+// as Athena reports them or, when it cannot be described, those of the
+// standard layout.
+func athenaTableSchema(ctx context.Context, client *aws.Client, target aws.AthenaTarget, source string) string {
+	table := queryTables[source]
+	name := table.name
+	if configured := getConfigString(table.key); configured != "" {
+		name = configured
+	}
+	columns := table.columns
+	if target.Database == "" {
+		fmt.Fprintf(os.Stderr, "⚠️  athena.database is not set; assuming the standard layout of %s\n", name)
+	} else {
+		noteOperation("describing Athena table %s.%s", target.Database, name)
+		described, err := client.AthenaTableColumns(ctx, target, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v; assuming the standard layout\n", err)
+		} else {
+			columns = described
+		}
+	}
+	return fmt.Sprintf("table %s (columns: %s)", name, strings.Join(columns, ", "))
+}
+
+// runDraftedQuery executes the query, read-only, and reports how much data
+// it scanned.
+func runDraftedQuery(ctx context.Context, client *aws.Client, source string, draft *llm.QueryDraft, target aws.AthenaTarget) (*aws.QueryResult, error) {
+	var result *aws.QueryResult
+	var err error
+	if source == querySourceLogs {
+		noteOperation("running the Logs Insights query over %d log group(s)", len(draft.LogGroups))
+		end := time.Now()
+		result, err = client.RunLogsInsightsQuery(ctx, draft.LogGroups, draft.Query, end.Add(-nl2querySince), end)
+	} else {
+		if target.Database == "" {
+			return nil, fmt.Errorf("athena.database is not set in ~/.cloudai.yaml; see 'cloudai nl2query --help'")
+		}
+		noteOperation("running the Athena query in %s", target.Database)
+		result, err = client.RunAthenaQuery(ctx, draft.Query, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "📦 Scanned %s\n", formatBytes(result.ScannedBytes))
+	return result, nil
+}
+
+// printQueryResult prints up to maxQueryRowsShown rows as a table.
+func printQueryResult(result *aws.QueryResult) {
+	if len(result.Rows) == 0 {
+		fmt.Println("No rows")
+		return
+	}
+	rows := result.Rows
+	if len(rows) > maxQueryRowsShown {
+		rows = rows[:maxQueryRowsShown]
+	}
+	title := fmt.Sprintf("%d row(s)", len(result.Rows))
+	if result.Truncated {
+		title = fmt.Sprintf("first %d rows", len(result.Rows))
+	}
+	table := &output.Table{Title: title, Headers: result.Columns}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = truncate(cell, 80)
+		}
+		table.Rows = append(table.Rows, cells)
+	}
+	table.Print()
+	if len(result.Rows) > len(rows) {
+		fmt.Printf("… %d more, see --json\n", len(result.Rows)-len(rows))
+	}
+}
+
+// queryResultSummary asks the model to answer the request from the rows.
+func queryResultSummary(ctx context.Context, request string, draft *llm.QueryDraft, result *aws.QueryResult) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	rows := result.Rows
+	if len(rows) > maxQueryRowsSummarized {
+		rows = rows[:maxQueryRowsSummarized]
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"intent":    "query_results",
+		"query":     draft.Query,
+		"columns":   result.Columns,
+		"rows":      rows,
+		"row_count": len(result.Rows),
+		"truncated": result.Truncated || len(rows) < len(result.Rows),
+	})
+	if err != nil {
+		return "", err
+	}
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, request, string(data))
+	recordUsage(".", models.router.LastUsage(), time.Since(start), err == nil, nil)
+	return text, err
+}
+
+func init() {
+	nl2queryCmd.Flags().StringVar(&nl2querySource, "source", "", "what to query: logs, cur or flowlogs (default: guessed from the request)")
+	nl2queryCmd.Flags().BoolVar(&nl2queryRun, "run", false, "run the query, read-only, and summarize the results")
+	nl2queryCmd.Flags().DurationVar(&nl2querySince, "since", time.Hour, "time range of a Logs Insights query")
+	nl2queryCmd.Flags().StringSliceVar(&nl2queryGroups, "log-group", nil, "log groups to query, instead of letting the model pick (repeatable)")
+	nl2queryCmd.Flags().BoolVar(&nl2queryNoSummary, "no-summary", false, "print the results without asking the model for a summary")
+	rootCmd.AddCommand(nl2queryCmd)
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pluginPrefix is the executable name prefix for external command plugins,
+// e.g. "cloudai-drift" on PATH is invoked as "cloudai drift".
+const pluginPrefix = "cloudai-"
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Manage external command plugins",
+	Long: `CloudAI-CLI supports git/kubectl-style plugins: any executable on your PATH named
+cloudai-<name> can be run as "cloudai <name> [args...]".
+
+Plugins receive the context they need through environment variables:
+  CLOUDAI_PROJECT_DIR   – directory the command was run from
+  CLOUDAI_CACHE_PATH    – path to the infrastructure cache (encrypted if cache.encrypt is set)
+  CLOUDAI_CONFIG_FILE   – config file in use, if any
+  CLOUDAI_<KEY>         – a few non-secret settings: model.type as CLOUDAI_MODEL_TYPE,
+                          model.name, model.model_id, model.region, cache.encrypt
+                          and cache.key_source
+
+Credentials and other settings are not exported; plugins that need them read
+the config file.`,
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List external command plugins found on PATH",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins := findPlugins()
+		if len(plugins) == 0 {
+			fmt.Println("No plugins found. Add an executable named cloudai-<name> to your PATH.")
+			return nil
+		}
+
+		fmt.Println("🔌 Installed plugins:")
+		for _, name := range sortedKeys(plugins) {
+			fmt.Printf("   • %-20s %s\n", name, plugins[name])
+		}
+		return nil
+	},
+}
+
+// findPlugins returns plugin name -> executable path for every cloudai-*
+// executable on PATH. Earlier PATH entries win, like the shell.
+func findPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(name, pluginPrefix) {
+				continue
+			}
+			pluginName := strings.TrimSuffix(strings.TrimPrefix(name, pluginPrefix), filepath.Ext(name))
+			if _, seen := plugins[pluginName]; seen || pluginName == "" {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if _, err := exec.LookPath(path); err != nil {
+				continue // not executable
+			}
+			plugins[pluginName] = path
+		}
+	}
+	return plugins
+}
+
+// runPluginIfPresent executes an external plugin when the first argument names
+// one and is not a built-in command. It reports whether a plugin handled the
+// invocation.
+func runPluginIfPresent(args []string) (bool, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false, nil
+	}
+	name := args[0]
+	if cmd, _, err := rootCmd.Find([]string{name}); err == nil && cmd != rootCmd {
+		return false, nil
+	}
+
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return false, nil
+	}
+
+	// Plugins run before cobra, so load the config ourselves.
+	initConfig()
+
+	plugin := exec.Command(path, args[1:]...)
+	plugin.Stdin = os.Stdin
+	plugin.Stdout = os.Stdout
+	plugin.Stderr = os.Stderr
+	plugin.Env = append(os.Environ(), pluginEnv()...)
+
+	if err := plugin.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return true, fmt.Errorf("failed to run plugin %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// pluginConfigKeys are the config settings exported to plugins. Only
+// settings that can never hold a credential belong here; anything else
+// stays in the config file.
+var pluginConfigKeys = []string{"model.type", "model.name", "model.model_id", "model.region", "cache.encrypt", "cache.key_source"}
+
+// pluginEnv builds the environment variables passed to plugins.
+func pluginEnv() []string {
+	cwd, _ := os.Getwd()
+	env := []string{
+		"CLOUDAI_PROJECT_DIR=" + cwd,
+		"CLOUDAI_CACHE_PATH=" + state.NewCacheManager(cwd).Path(),
+		"CLOUDAI_CONFIG_FILE=" + viper.ConfigFileUsed(),
+	}
+	for _, key := range pluginConfigKeys {
+		if !viper.IsSet(key) {
+			continue
+		}
+		name := "CLOUDAI_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+		env = append(env, name+"="+viper.GetString(key))
+	}
+	return env
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	pluginCmd.AddCommand(pluginListCmd)
+	rootCmd.AddCommand(pluginCmd)
+}
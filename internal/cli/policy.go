@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Show the organisation policy for models and check the configuration against it",
+	Long: `Shows the policy in .cloudai/policy.yaml of the project, which restricts the
+providers and models CloudAI-CLI may use, whether prompts may leave this machine and
+how much it may spend, and checks every configured model against it. Questions are
+refused while the configuration violates the policy.
+
+Example .cloudai/policy.yaml:
+  providers: [bedrock, ollama]
+  models: ["anthropic.claude-3-haiku-*", "llama3*"]
+  allow_egress: true
+  max_daily_cost: 2.00
+  max_request_cost: 0.05`,
+	Args: cobra.NoArgs,
+	RunE: runPolicy,
+}
+
+func runPolicy(cmd *cobra.Command, args []string) error {
+	if policyErr != nil {
+		return policyErr
+	}
+	policy := llm.ActivePolicy()
+	if policy == nil {
+		fmt.Println("No policy: .cloudai/policy.yaml does not exist in this project, so every model is allowed")
+		return nil
+	}
+
+	fmt.Printf("📜 Policy: %s\n", policy.Path)
+	fmt.Printf("   Providers: %s\n", orAny(policy.Providers))
+	fmt.Printf("   Models: %s\n", orAny(policy.Models))
+	if policy.EgressAllowed() {
+		fmt.Println("   Data egress: allowed")
+	} else {
+		fmt.Println("   Data egress: not allowed, prompts stay on this machine")
+	}
+	fmt.Printf("   Daily budget: $%.2f\n", llm.DailyLimit())
+	if policy.MaxRequestCost > 0 {
+		fmt.Printf("   Per request: $%.4f\n", policy.MaxRequestCost)
+	}
+	fmt.Println()
+
+	violations := 0
+	for _, ref := range policyModels() {
+		if err := policy.CheckModel(ref); err != nil {
+			fmt.Printf("❌ %s (%s, %s): %v\n", ref.Model, ref.Backend, ref.Role, err)
+			violations++
+		} else {
+			fmt.Printf("✅ %s (%s, %s)\n", ref.Model, ref.Backend, ref.Role)
+		}
+	}
+	if violations > 0 {
+		return fmt.Errorf("%d configured models violate %s", violations, policy.Path)
+	}
+	return nil
+}
+
+// policyModels are the configured models, including the fast and smart
+// tiers.
+func policyModels() []llm.ModelRef {
+	refs := llm.ConfiguredModels()
+	for _, tier := range []string{llm.TierFast, llm.TierSmart} {
+		if name := viper.GetString("models." + tier); name != "" {
+			backend, model := llm.ResolveModelAlias(name)
+			refs = append(refs, llm.ModelRef{Backend: backend, Model: model, Role: tier})
+		}
+	}
+	return refs
+}
+
+// policyErr is why the working directory's policy could not be loaded.
+// Commands that use the policy return it; others, such as 'cloudai version',
+// still run so the project can be fixed.
+var policyErr error
+
+// selectPolicy makes the policy of the project in dir the one every model
+// request is checked against. A policy that cannot be read is an error
+// rather than no policy.
+func selectPolicy(dir string) error {
+	policy, err := llm.LoadPolicy(filepath.Join(dir, ".cloudai", "policy.yaml"))
+	if err != nil {
+		return err
+	}
+	llm.SetPolicy(policy)
+	return nil
+}
+
+// checkPolicy refuses clients the active policy does not allow, and every
+// client while the policy could not be loaded.
+func checkPolicy(clients ...*llm.Client) error {
+	if policyErr != nil {
+		return policyErr
+	}
+	for _, client := range clients {
+		if err := llm.ActivePolicy().CheckClient(client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func orAny(values []string) string {
+	if len(values) == 0 {
+		return "any"
+	}
+	return strings.Join(values, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// ollamaGPUVCPUs are the vCPUs of g4dn.xlarge, the default instance type
+	// of ec2-ollama-stack.yaml
+	ollamaGPUVCPUs = 4
+	// ollamaCPUVCPUs are the vCPUs of t3.medium, the CPU-only fallback
+	ollamaCPUVCPUs = 2
+)
+
+var quotasCmd = &cobra.Command{
+	Use:   "quotas",
+	Short: "Check how close key service quotas are to their limits",
+	Long: `Reads the applied Service Quotas values of the limits a growing account
+runs into first, and how much of each is in use:
+
+  - Lambda concurrent executions
+  - Elastic IP addresses
+  - network interfaces per region
+  - running On-Demand standard and GPU (G and VT) instance vCPUs
+
+  cloudai quotas
+  cloudai quotas --threshold 60 --quota ec2:L-34B43A08
+
+Usage comes from the metric Service Quotas names for each quota, or from
+counting the resources when there is none. Quotas at or above --threshold
+percent are flagged.`,
+	Args: cobra.NoArgs,
+	RunE: runQuotas,
+}
+
+func runQuotas(cmd *cobra.Command, args []string) error {
+	if quotasThreshold <= 0 || quotasThreshold > 100 {
+		return fmt.Errorf("--threshold must be a percentage between 0 and 100")
+	}
+	refs := append([]aws.QuotaRef{}, aws.KeyQuotas...)
+	for _, value := range quotasExtra {
+		ref, err := aws.ParseQuotaRef(value)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
+
+	ctx := context.Background()
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	noteOperation("reading %d service quotas and their usage", len(refs))
+	var quotas []*aws.QuotaUsage
+	var over []*aws.QuotaUsage
+	for _, ref := range refs {
+		quota, err := client.QuotaUsage(ctx, ref)
+		if aws.IsAccessDenied(err) {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipped %s:%s: grant servicequotas:GetServiceQuota, cloudwatch:GetMetricStatistics and ec2:Describe*\n", ref.Service, ref.Code)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		quotas = append(quotas, quota)
+		if quota.Utilization() >= quotasThreshold {
+			over = append(over, quota)
+		}
+	}
+
+	if jsonOutput {
+		type quotaResult struct {
+			*aws.QuotaUsage
+			Utilization float64 `json:"utilization"`
+			OverLimit   bool    `json:"over_threshold"`
+		}
+		results := make([]quotaResult, len(quotas))
+		for i, quota := range quotas {
+			results[i] = quotaResult{QuotaUsage: quota, Utilization: quota.Utilization(), OverLimit: quota.Utilization() >= quotasThreshold}
+		}
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "quotas",
+			Data: map[string]interface{}{
+				"threshold": quotasThreshold,
+				"quotas":    results,
+			},
+			Success: true,
+		})
+	}
+
+	table := output.Table{
+		Title:   "Service quotas",
+		Headers: []string{"", "Quota", "Code", "Usage", "Limit", "Used"},
+	}
+	for _, quota := range quotas {
+		status, usage, used := "🟢", "?", "unknown"
+		if utilization := quota.Utilization(); utilization >= 0 {
+			usage = formatQuotaValue(quota.Usage)
+			used = fmt.Sprintf("%.0f%%", utilization)
+			if utilization >= quotasThreshold {
+				status = "🔴"
+			}
+		} else {
+			status = "⚪"
+		}
+		table.Rows = append(table.Rows, []string{status, truncate(quota.Name, 50), quota.Service + ":" + quota.Code, usage, formatQuotaValue(quota.Limit), used})
+	}
+	table.Print()
+
+	if len(over) == 0 {
+		fmt.Printf("\n✅ All quotas with known usage are below %.0f%%\n", quotasThreshold)
+		return nil
+	}
+	fmt.Println()
+	for _, quota := range over {
+		fmt.Printf("⚠️  %s is at %.0f%% (%s of %s)", quota.Name, quota.Utilization(), formatQuotaValue(quota.Usage), formatQuotaValue(quota.Limit))
+		if quota.Adjustable {
+			fmt.Printf(": request an increase with\n   aws service-quotas request-service-quota-increase --service-code %s --quota-code %s --desired-value %s\n",
+				quota.Service, quota.Code, formatQuotaValue(quota.Limit*2))
+		} else {
+			fmt.Println("; it cannot be raised")
+		}
+	}
+	return nil
+}
+
+func formatQuotaValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// checkOllamaQuotas reports whether the region's vCPU quotas leave room for
+// the GPU instance the EC2 Ollama stack deploys, or for the CPU fallback,
+// and how to request more when they do not.
+func checkOllamaQuotas(ctx context.Context) error {
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return err
+	}
+	gpu, err := client.QuotaUsage(ctx, aws.GPUVCPUs)
+	if err != nil {
+		return err
+	}
+	if gpu.Available() >= ollamaGPUVCPUs {
+		fmt.Printf("✅ GPU quota: %s of %s vCPUs free, enough for g4dn.xlarge\n", formatQuotaValue(gpu.Available()), formatQuotaValue(gpu.Limit))
+		return nil
+	}
+	fmt.Printf("⚠️  GPU quota: %s of %s vCPUs free; g4dn.xlarge needs %d\n", formatQuotaValue(gpu.Available()), formatQuotaValue(gpu.Limit), ollamaGPUVCPUs)
+	fmt.Printf("   aws service-quotas request-service-quota-increase --service-code %s --quota-code %s --desired-value %s\n",
+		gpu.Service, gpu.Code, formatQuotaValue(gpu.Usage+ollamaGPUVCPUs))
+
+	standard, err := client.QuotaUsage(ctx, aws.StandardVCPUs)
+	if err != nil {
+		return err
+	}
+	if standard.Available() >= ollamaCPUVCPUs {
+		fmt.Printf("   Until it is approved, t3.medium fits the standard quota (%s vCPUs free), at slower inference\n", formatQuotaValue(standard.Available()))
+	} else {
+		fmt.Printf("   The standard quota has no room for t3.medium either (%s vCPUs free)\n", formatQuotaValue(standard.Available()))
+	}
+	return nil
+}
+
+func init() {
+	quotasCmd.Flags().Float64Var(&quotasThreshold, "threshold", 80, "flag quotas at or above this percentage of their limit")
+	quotasCmd.Flags().StringSliceVar(&quotasExtra, "quota", nil, "also check these quotas, as service:code (repeatable)")
+	rootCmd.AddCommand(quotasCmd)
+}
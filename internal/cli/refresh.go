@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/viper"
+)
+
+// minRefreshInterval keeps a misconfigured schedule from hammering the AWS
+// APIs.
+const minRefreshInterval = time.Minute
+
+// refreshSchedule reads how often each live-scanned service is rescanned in
+// the background from the refresh.* config keys:
+//
+//	refresh:
+//	  every: 1h         # all services
+//	  services:         # per-service overrides; "off" disables one
+//	    lambda: 15m
+//	    kms: "@daily"
+//
+// Services without an interval are not refreshed; an empty schedule turns
+// the refresher off.
+func refreshSchedule() (map[string]time.Duration, error) {
+	every, err := parseRefreshInterval(getConfigString("refresh.every"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh.every: %w", err)
+	}
+
+	overrides := viper.GetStringMapString("refresh.services")
+	services := state.LiveServices()
+	schedule := make(map[string]time.Duration)
+	for _, service := range services {
+		schedule[service] = every
+	}
+	for service, value := range overrides {
+		if !slices.Contains(services, service) {
+			return nil, fmt.Errorf("unknown service %q in refresh.services: use %s", service, strings.Join(services, ", "))
+		}
+		interval, err := parseRefreshInterval(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh.services.%s: %w", service, err)
+		}
+		schedule[service] = interval
+	}
+	for service, interval := range schedule {
+		if interval == 0 {
+			delete(schedule, service)
+		}
+	}
+	return schedule, nil
+}
+
+// parseRefreshInterval reads a Go duration ("90m"), "@every <duration>",
+// "@hourly", "@daily" or "@weekly". "" and "off" mean never.
+func parseRefreshInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var interval time.Duration
+	switch value {
+	case "", "off", "never":
+		return 0, nil
+	case "@hourly":
+		interval = time.Hour
+	case "@daily":
+		interval = 24 * time.Hour
+	case "@weekly":
+		interval = 7 * 24 * time.Hour
+	default:
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(value, "@every")))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a duration such as 30m, @every 6h or @daily", value)
+		}
+		interval = d
+	}
+	if interval < minRefreshInterval {
+		return 0, fmt.Errorf("%s is shorter than the minimum of %s", interval, minRefreshInterval)
+	}
+	return interval, nil
+}
+
+// runRefresher rescans services on the schedule until ctx is done. Each
+// time services fall due, the caches of the projects returned by projects
+// are refreshed; projects whose cache is not a live scan are skipped.
+func runRefresher(ctx context.Context, schedule map[string]time.Duration, projects func() []string, lock sync.Locker) {
+	if len(schedule) == 0 {
+		return
+	}
+	next := make(map[string]time.Time, len(schedule))
+	for service, interval := range schedule {
+		next[service] = time.Now().Add(interval)
+	}
+
+	for {
+		wake := time.Time{}
+		for _, at := range next {
+			if wake.IsZero() || at.Before(wake) {
+				wake = at
+			}
+		}
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		var due []string
+		for _, service := range state.LiveServices() {
+			if at, ok := next[service]; ok && !at.After(now) {
+				due = append(due, service)
+				next[service] = now.Add(schedule[service])
+			}
+		}
+		for _, dir := range projects() {
+			refreshed, count, err := refreshProject(ctx, dir, due, lock)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Background refresh of %s failed: %v\n", dir, err)
+			} else if len(refreshed) > 0 {
+				fmt.Printf("🔄 Refreshed %s in %s (%d resources)\n", strings.Join(refreshed, ", "), dir, count)
+			}
+		}
+	}
+}
+
+// refreshProject rescans the due services a project's cached live scan
+// covers and merges the result into its cache, returning the services it
+// rescanned and the number of resources cached. The scan runs without the
+// lock; loading, merging and saving the cache run with it held.
+func refreshProject(ctx context.Context, dir string, due []string, lock sync.Locker) ([]string, int, error) {
+	cacheManager, err := newCacheManager(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !cacheManager.Exists() {
+		return nil, 0, nil
+	}
+	// Nobody is there to notice a cache that fails its integrity check, so
+	// it is skipped rather than signed again with the rescan merged in
+	infraState, err := cacheManager.Load()
+	if state.IsUntrusted(err) {
+		return nil, 0, fmt.Errorf("skipped: %w. Run `cloudai scan` to rebuild it", err)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not load cache: %w", err)
+	}
+	var services []string
+	for _, service := range state.RefreshableServices(infraState) {
+		if slices.Contains(due, service) {
+			services = append(services, service)
+		}
+	}
+	if len(services) == 0 {
+		return nil, 0, nil
+	}
+
+	awsClient, err := newAWSClient(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	live, err := (&state.LiveProvider{Client: awsClient}).ScanServices(ctx, services)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	// a scan or import may have rewritten the cache while this one ran
+	infraState, err = cacheManager.Load()
+	if state.IsUntrusted(err) {
+		return nil, 0, fmt.Errorf("skipped: %w. Run `cloudai scan` to rebuild it", err)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not load cache: %w", err)
+	}
+	if len(state.RefreshableServices(infraState)) == 0 {
+		return nil, 0, nil
+	}
+	state.RefreshServices(infraState, live, services)
+	if err := cacheManager.Save(infraState); err != nil {
+		return nil, 0, fmt.Errorf("could not save cache: %w", err)
+	}
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	return services, len(resources), nil
+}
+
+// describeSchedule lists the refresh intervals for the startup message.
+func describeSchedule(schedule map[string]time.Duration) string {
+	var parts []string
+	for _, service := range state.LiveServices() {
+		if interval, ok := schedule[service]; ok {
+			parts = append(parts, fmt.Sprintf("%s every %s", service, interval))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
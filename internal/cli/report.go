@@ -0,0 +1,532 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxReportItems is how many services, finding groups and drifted
+	// resources a report lists in each section
+	maxReportItems = 10
+	// reportFindingsLimit is the most findings pulled from each service
+	reportFindingsLimit = 200
+)
+
+// reportSections are the sections a report can have, in report order
+var reportSections = []string{"cost", "security", "drift"}
+
+var reportCmd = &cobra.Command{
+	Use:   "report [path]",
+	Short: "Write a cost, security and drift report, once or on a schedule",
+	Long: `Writes a plain-text report of the account behind a project:
+
+  cost      spend of the last --days by service, against the period before
+  security  active GuardDuty and Security Hub findings, ranked as 'cloudai findings' does
+  drift     resources added, removed or changed since the cached live scan
+
+and prints it, or delivers it with --deliver to an SNS topic or through SES:
+
+  cloudai report
+  cloudai report --deliver sns:arn:aws:sns:us-east-1:123456789012:finops
+  cloudai report --schedule weekly --deliver ses:team@example.com --from cloudai@example.com
+
+With --schedule (hourly, daily, weekly, a duration or "@every 12h") the command
+keeps running, sends a report right away and then one every interval. The daemon
+and 'cloudai serve' send reports on the same schedule, starting one interval after
+they start, when it is configured:
+
+  report:
+    schedule: weekly
+    deliver: [sns:arn:aws:sns:...:finops, ses:team@example.com]
+    from: cloudai@example.com   # a verified SES identity
+    dir: /home/me/infra         # the project the daemon reports on
+    days: 7
+    sections: [cost, security]
+
+Delivery is the only write the report makes; it uses sns:Publish or ses:SendEmail.
+
+--comment prints the report as a markdown pull request comment, and
+--post-comment posts it through the GitHub or GitLab API, e.g. as the drift check
+of an Atlantis or Terraform Cloud pipeline:
+
+  cloudai report --sections drift --post-comment`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReport,
+}
+
+// reportOptions are what a report covers and where it goes
+type reportOptions struct {
+	Days     int
+	Sections []string
+	Deliver  []string
+	From     string
+}
+
+// reportCost is the spend section of a report
+type reportCost struct {
+	Currency      string            `json:"currency"`
+	Total         float64           `json:"total"`
+	PreviousTotal float64           `json:"previous_total"`
+	Services      []aws.ServiceCost `json:"services"`
+}
+
+// report is one generated report
+type report struct {
+	Project     string                `json:"project"`
+	Account     string                `json:"account,omitempty"`
+	GeneratedAt time.Time             `json:"generated_at"`
+	Days        int                   `json:"days"`
+	Cost        *reportCost           `json:"cost,omitempty"`
+	Security    *state.FindingsReport `json:"security,omitempty"`
+	Drift       *state.Drift          `json:"drift,omitempty"`
+	// Notes are the sections that could not be written, and why
+	Notes []string `json:"notes,omitempty"`
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("error getting absolute path: %w", err)
+	}
+	opts, err := reportConfig(cmd)
+	if err != nil {
+		return err
+	}
+	schedule := reportSchedule
+	if !cmd.Flags().Changed("schedule") {
+		schedule = getConfigString("report.schedule")
+	}
+	interval, err := parseReportSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	if interval == 0 {
+		r, err := generateReport(context.Background(), absPath, opts)
+		if err != nil {
+			return err
+		}
+		if wantsComment() {
+			return emitComment(context.Background(), r.Comment())
+		}
+		if len(opts.Deliver) == 0 {
+			if jsonOutput {
+				return output.NewFormatter(true).FormatResult(&output.Result{Query: "report", Data: r, Success: true})
+			}
+			fmt.Print(r.Text())
+			return nil
+		}
+		return deliverReport(context.Background(), r, opts)
+	}
+
+	if wantsComment() {
+		return fmt.Errorf("--comment and --post-comment report once; drop --schedule")
+	}
+	if len(opts.Deliver) == 0 {
+		return fmt.Errorf("--schedule needs --deliver (or report.deliver) to send the reports to")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("📬 Reporting on %s every %s to %s (Ctrl-C to stop)\n", absPath, interval, strings.Join(opts.Deliver, ", "))
+	sendScheduledReport(ctx, absPath, opts)
+	runReporter(ctx, interval, absPath, opts)
+	return nil
+}
+
+// reportConfig reads the report options from the flags, falling back to the
+// report.* config keys for flags that were not given.
+func reportConfig(cmd *cobra.Command) (reportOptions, error) {
+	opts := reportOptions{Days: reportDays, Sections: reportSectionList, Deliver: reportDeliver, From: reportFrom}
+	if cmd == nil || !cmd.Flags().Changed("days") {
+		if days := viper.GetInt("report.days"); days > 0 {
+			opts.Days = days
+		}
+	}
+	if cmd == nil || !cmd.Flags().Changed("sections") {
+		if sections := viper.GetStringSlice("report.sections"); len(sections) > 0 {
+			opts.Sections = sections
+		}
+	}
+	if cmd == nil || !cmd.Flags().Changed("deliver") {
+		opts.Deliver = viper.GetStringSlice("report.deliver")
+	}
+	if cmd == nil || !cmd.Flags().Changed("from") {
+		opts.From = getConfigString("report.from")
+	}
+
+	if opts.Days <= 0 {
+		return opts, fmt.Errorf("--days must be positive")
+	}
+	for _, section := range opts.Sections {
+		if !slices.Contains(reportSections, section) {
+			return opts, fmt.Errorf("unknown report section %q: use %s", section, strings.Join(reportSections, ", "))
+		}
+	}
+	for _, target := range opts.Deliver {
+		kind, address, _ := strings.Cut(target, ":")
+		switch {
+		case kind == "sns" && strings.HasPrefix(address, "arn:"):
+		case kind == "ses" && strings.Contains(address, "@"):
+			if opts.From == "" {
+				return opts, fmt.Errorf("delivering through SES needs --from (or report.from), a verified SES identity")
+			}
+		default:
+			return opts, fmt.Errorf("cannot deliver to %q: use sns:<topic ARN> or ses:<email address>", target)
+		}
+	}
+	return opts, nil
+}
+
+// parseReportSchedule reads hourly, daily, weekly or anything
+// parseRefreshInterval accepts. "" means no schedule.
+func parseReportSchedule(value string) (time.Duration, error) {
+	switch strings.TrimSpace(value) {
+	case "hourly", "daily", "weekly":
+		value = "@" + strings.TrimSpace(value)
+	}
+	interval, err := parseRefreshInterval(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid report schedule: %w", err)
+	}
+	return interval, nil
+}
+
+// runReporter sends a report every interval until ctx is done. Failures are
+// logged and the schedule carries on.
+func runReporter(ctx context.Context, interval time.Duration, dir string, opts reportOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sendScheduledReport(ctx, dir, opts)
+		}
+	}
+}
+
+func sendScheduledReport(ctx context.Context, dir string, opts reportOptions) {
+	r, err := generateReport(ctx, dir, opts)
+	if err == nil {
+		err = deliverReport(ctx, r, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Scheduled report of %s failed: %v\n", dir, err)
+	}
+}
+
+// scheduledReport returns the reporter interval and options configured for
+// the daemon and server, or a zero interval when reports are not configured.
+func scheduledReport() (time.Duration, reportOptions, error) {
+	interval, err := parseReportSchedule(getConfigString("report.schedule"))
+	if err != nil || interval == 0 {
+		return 0, reportOptions{}, err
+	}
+	opts, err := reportConfig(nil)
+	if err != nil {
+		return 0, reportOptions{}, err
+	}
+	if len(opts.Deliver) == 0 {
+		return 0, reportOptions{}, fmt.Errorf("report.schedule is set but report.deliver is empty")
+	}
+	return interval, opts, nil
+}
+
+// generateReport collects the report sections for a project. A section that
+// cannot be written is noted in the report rather than failing it.
+func generateReport(ctx context.Context, dir string, opts reportOptions) (*report, error) {
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	now := time.Now()
+	r := &report{Project: dir, GeneratedAt: now, Days: opts.Days}
+	if identity, err := client.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err == nil {
+		r.Account = awssdk.ToString(identity.Account)
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil && !errors.Is(err, state.ErrNoCache) {
+		return nil, err
+	}
+
+	if slices.Contains(opts.Sections, "cost") {
+		end := now.UTC().Truncate(24 * time.Hour)
+		start := end.AddDate(0, 0, -opts.Days)
+		services, currency, err := client.TopServices(ctx, start, end)
+		if err == nil {
+			cost := &reportCost{Currency: currency, Services: services}
+			for _, service := range services {
+				cost.Total += service.Cost
+			}
+			if previous, _, err := client.TopServices(ctx, start.AddDate(0, 0, -opts.Days), start); err == nil {
+				for _, service := range previous {
+					cost.PreviousTotal += service.Cost
+				}
+			}
+			r.Cost = cost
+		} else {
+			r.Notes = append(r.Notes, fmt.Sprintf("cost: %v", err))
+		}
+	}
+
+	if slices.Contains(opts.Sections, "security") {
+		findingsState := infraState
+		if findingsState == nil {
+			findingsState = map[string]interface{}{}
+		}
+		findings, err := state.CollectFindings(ctx, client, findingsState, reportFindingsLimit, now)
+		if err == nil {
+			r.Security = findings
+		} else {
+			r.Notes = append(r.Notes, fmt.Sprintf("security: %v", err))
+		}
+	}
+
+	if slices.Contains(opts.Sections, "drift") {
+		services := state.RefreshableServices(infraState)
+		switch {
+		case infraState == nil:
+			r.Notes = append(r.Notes, "drift: no infrastructure cache; run `cloudai scan --live` to have drift checked")
+		case len(services) == 0:
+			r.Notes = append(r.Notes, "drift: the cache is not a live scan; run `cloudai scan --live` to have drift checked")
+		default:
+			live, err := (&state.LiveProvider{Client: client}).ScanServices(ctx, services)
+			if err == nil {
+				r.Drift, err = state.DiffLive(infraState, live, services)
+			}
+			if err != nil {
+				r.Notes = append(r.Notes, fmt.Sprintf("drift: %v", err))
+			}
+		}
+	}
+	return r, nil
+}
+
+// Subject is the report's email and notification subject.
+func (r *report) Subject() string {
+	account := r.Account
+	if account == "" {
+		account = filepath.Base(r.Project)
+	}
+	return fmt.Sprintf("CloudAI report for %s, %s", account, r.GeneratedAt.Format("2006-01-02"))
+}
+
+// Text renders the report as plain text, for terminals, email and SNS.
+func (r *report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", r.Subject())
+	fmt.Fprintf(&b, "Project: %s\n", r.Project)
+	fmt.Fprintf(&b, "Generated: %s\n", r.GeneratedAt.Format(time.RFC1123))
+
+	if cost := r.Cost; cost != nil {
+		fmt.Fprintf(&b, "\nCOST (last %d days): %.2f %s", r.Days, cost.Total, cost.Currency)
+		if cost.PreviousTotal > 0 {
+			change := (cost.Total - cost.PreviousTotal) / cost.PreviousTotal * 100
+			fmt.Fprintf(&b, ", %+.0f%% against the %d days before (%.2f)", change, r.Days, cost.PreviousTotal)
+		}
+		b.WriteString("\n")
+		for i, service := range cost.Services {
+			if i == maxReportItems {
+				fmt.Fprintf(&b, "  ... and %d more service(s)\n", len(cost.Services)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  %-45s %10.2f\n", truncate(service.Service, 45), service.Cost)
+		}
+	}
+
+	if security := r.Security; security != nil {
+		fmt.Fprintf(&b, "\nSECURITY: %d active finding(s) in %d group(s)\n", security.Total, len(security.Groups))
+		for _, service := range security.Disabled {
+			fmt.Fprintf(&b, "  %s is not enabled in this region\n", service)
+		}
+		for i, group := range security.Groups {
+			if i == maxReportItems {
+				fmt.Fprintf(&b, "  ... and %d more group(s)\n", len(security.Groups)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  [%s] %s: %d resource(s)", group.Severity, group.Title, len(group.Resources))
+			if group.Dependents > 0 {
+				fmt.Fprintf(&b, ", %d dependent(s)", group.Dependents)
+			}
+			b.WriteString("\n")
+			if len(group.NextSteps) > 0 {
+				fmt.Fprintf(&b, "    next: %s\n", group.NextSteps[0])
+			}
+		}
+	}
+
+	if drift := r.Drift; drift != nil {
+		fmt.Fprintf(&b, "\nDRIFT since the cached scan of %s: %d added, %d removed, %d changed\n",
+			strings.Join(drift.Services, ", "), len(drift.Added), len(drift.Removed), len(drift.Changed))
+		for _, list := range []struct {
+			mark string
+			ids  []string
+		}{{"+", drift.Added}, {"-", drift.Removed}, {"~", drift.Changed}} {
+			for i, id := range list.ids {
+				if i == maxReportItems {
+					fmt.Fprintf(&b, "  %s ... and %d more\n", list.mark, len(list.ids)-i)
+					break
+				}
+				fmt.Fprintf(&b, "  %s %s\n", list.mark, id)
+			}
+		}
+	}
+
+	if len(r.Notes) > 0 {
+		b.WriteString("\nNOT INCLUDED\n")
+		for _, note := range r.Notes {
+			fmt.Fprintf(&b, "  %s\n", note)
+		}
+	}
+	return b.String()
+}
+
+// Comment formats the report as a pull request comment, such as the drift
+// check of an Atlantis or Terraform Cloud pipeline. Sections with something
+// to act on are expanded.
+func (r *report) Comment() *output.Comment {
+	comment := &output.Comment{Marker: "cloudai:report", Title: r.Subject()}
+	var summary []string
+
+	if drift := r.Drift; drift != nil {
+		if drift.Count() == 0 {
+			summary = append(summary, fmt.Sprintf("✅ No drift in %s since the cached scan", strings.Join(drift.Services, ", ")))
+		} else {
+			summary = append(summary, fmt.Sprintf("⚠️ **Drift**: %d added, %d removed, %d changed since the cached scan",
+				len(drift.Added), len(drift.Removed), len(drift.Changed)))
+			table := &output.Table{Headers: []string{"", "Resource"}}
+			for _, list := range []struct {
+				change string
+				ids    []string
+			}{{"added", drift.Added}, {"removed", drift.Removed}, {"changed", drift.Changed}} {
+				for _, id := range list.ids {
+					table.Rows = append(table.Rows, []string{list.change, "`" + id + "`"})
+				}
+			}
+			comment.Sections = append(comment.Sections, output.CommentSection{
+				Title: fmt.Sprintf("Drift (%d)", drift.Count()),
+				Body:  table.Markdown(),
+				Open:  true,
+			})
+		}
+	}
+
+	if security := r.Security; security != nil {
+		counts := map[string]int{}
+		for _, group := range security.Groups {
+			counts[group.Severity]++
+		}
+		summary = append(summary, fmt.Sprintf("🛡️ **Security**: %d active finding(s) in %d group(s), %d critical and %d high",
+			security.Total, len(security.Groups), counts["CRITICAL"], counts["HIGH"]))
+		if len(security.Groups) > 0 {
+			table := &output.Table{Headers: []string{"Severity", "Finding", "Resources", "Next step"}}
+			for i, group := range security.Groups {
+				if i == maxReportItems {
+					table.Footer = fmt.Sprintf("_... and %d more group(s)_", len(security.Groups)-i)
+					break
+				}
+				step := ""
+				if len(group.NextSteps) > 0 {
+					step = group.NextSteps[0]
+				}
+				table.Rows = append(table.Rows, []string{group.Severity, group.Title, strconv.Itoa(len(group.Resources)), step})
+			}
+			comment.Sections = append(comment.Sections, output.CommentSection{
+				Title: fmt.Sprintf("Security findings (%d)", len(security.Groups)),
+				Body:  table.Markdown(),
+				Open:  counts["CRITICAL"]+counts["HIGH"] > 0,
+			})
+		}
+	}
+
+	if cost := r.Cost; cost != nil {
+		line := fmt.Sprintf("💰 **Cost** (last %d days): %.2f %s", r.Days, cost.Total, cost.Currency)
+		if cost.PreviousTotal > 0 {
+			line += fmt.Sprintf(", %+.0f%% against the %d days before", (cost.Total-cost.PreviousTotal)/cost.PreviousTotal*100, r.Days)
+		}
+		summary = append(summary, line)
+		table := &output.Table{Headers: []string{"Service", "Cost"}}
+		for i, service := range cost.Services {
+			if i == maxReportItems {
+				break
+			}
+			table.Rows = append(table.Rows, []string{service.Service, fmt.Sprintf("%.2f", service.Cost)})
+		}
+		comment.Sections = append(comment.Sections, output.CommentSection{
+			Title: "Cost by service",
+			Body:  table.Markdown(),
+		})
+	}
+
+	if len(r.Notes) > 0 {
+		comment.Sections = append(comment.Sections, output.CommentSection{
+			Title: "Not included",
+			Body:  "- " + strings.Join(r.Notes, "\n- "),
+		})
+	}
+	comment.Summary = strings.Join(summary, "\n")
+	return comment
+}
+
+// deliverReport sends a report to each target. The client lifts the
+// read-only guard, so it is used for nothing but the delivery calls.
+func deliverReport(ctx context.Context, r *report, opts reportOptions) error {
+	client, err := aws.NewClient(ctx, append(awsClientOptions(), aws.WithWrites())...)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	subject, body := r.Subject(), r.Text()
+	var failed []string
+	for _, target := range opts.Deliver {
+		kind, address, _ := strings.Cut(target, ":")
+		switch kind {
+		case "sns":
+			err = client.PublishMessage(ctx, address, subject, body)
+		case "ses":
+			err = client.SendEmail(ctx, opts.From, []string{address}, subject, body)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+			failed = append(failed, target)
+			continue
+		}
+		fmt.Printf("📬 Report sent to %s\n", target)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not deliver the report to %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportSchedule, "schedule", "", "keep running and send a report hourly, daily, weekly or every duration (config: report.schedule)")
+	reportCmd.Flags().StringSliceVar(&reportDeliver, "deliver", nil, "send the report to sns:<topic ARN> or ses:<email address> (repeatable, config: report.deliver)")
+	reportCmd.Flags().StringVar(&reportFrom, "from", "", "verified SES sender for ses: targets (config: report.from)")
+	reportCmd.Flags().IntVar(&reportDays, "days", 7, "period the cost section covers (config: report.days)")
+	reportCmd.Flags().StringSliceVar(&reportSectionList, "sections", reportSections, "sections to include: cost, security, drift (config: report.sections)")
+	addCommentFlags(reportCmd)
+	rootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var riskCmd = &cobra.Command{
+	Use:   "risk <base-path> [path]",
+	Short: "Score the risk of the infrastructure changes in a pull request",
+	Long: `Compares the scan of a pull request's base with the scan of its head and
+scores each added, modified and removed resource by what it could break:
+the kind of change, whether the resource guards access or holds data, how
+many resources depend on it and which API routes it serves.
+
+Scan both checkouts first, e.g. in CI:
+
+  git worktree add ../base origin/main
+  cloudai scan ../base && cloudai scan .
+  cloudai risk ../base . --json
+
+The score runs from 0 to 100 with a level of none, low, medium or high.
+Unless --no-summary is given, the model writes a short narrative for the
+pull request ("this change touches the auth Lambda used by 3 routes").
+
+--comment prints the result as a markdown pull request comment, and
+--post-comment posts it to the pull request through the GitHub or GitLab API,
+updating the comment of an earlier run, e.g. in an Atlantis workflow:
+
+  cloudai risk ../base . --post-comment --provider github`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runRisk,
+}
+
+func runRisk(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	base, err := loadCachedState(args[0])
+	if err != nil {
+		return fmt.Errorf("base %s: %w", args[0], err)
+	}
+	head, err := loadCachedState(dir)
+	if err != nil {
+		return err
+	}
+	report := state.AssessRisk(base, head)
+
+	summary := ""
+	if !riskNoSummary && len(report.Changes) > 0 {
+		summary, err = riskSummary(context.Background(), dir, report)
+		if err != nil {
+			// the scored changes stand on their own
+			fmt.Fprintf(os.Stderr, "⚠️  Could not write the risk narrative: %v\n", err)
+		}
+	}
+
+	if wantsComment() {
+		return emitComment(context.Background(), riskComment(report, summary))
+	}
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query: "risk",
+			Data: map[string]interface{}{
+				"score":   report.Score,
+				"level":   report.Level,
+				"changes": report.Changes,
+				"summary": summary,
+			},
+			Success: true,
+		})
+	}
+
+	if len(report.Changes) == 0 {
+		fmt.Println("✅ No infrastructure changes between the two scans")
+		return nil
+	}
+	icon := map[string]string{"low": "🟢", "medium": "🟡", "high": "🔴"}[report.Level]
+	fmt.Printf("%s Change risk: %d/100 (%s), %d resource(s) changed\n\n", icon, report.Score, report.Level, len(report.Changes))
+	for _, change := range report.Changes {
+		fmt.Printf("[%2d] %s\n", change.Score, strings.Join(change.Reasons, "; "))
+	}
+	if summary != "" {
+		fmt.Printf("\n🤖 %s\n", summary)
+	}
+	return nil
+}
+
+// riskComment formats the report as a pull request comment, the changes in
+// a table readers expand.
+func riskComment(report state.RiskReport, summary string) *output.Comment {
+	comment := &output.Comment{Marker: "cloudai:risk"}
+	if len(report.Changes) == 0 {
+		comment.Title = "✅ CloudAI change risk: no infrastructure changes"
+		return comment
+	}
+	icon := map[string]string{"low": "🟢", "medium": "🟡", "high": "🔴"}[report.Level]
+	comment.Title = fmt.Sprintf("%s CloudAI change risk: %d/100 (%s)", icon, report.Score, report.Level)
+	comment.Summary = fmt.Sprintf("%d resource(s) changed.", len(report.Changes))
+	if summary != "" {
+		comment.Summary += "\n\n" + summary
+	}
+
+	table := &output.Table{Headers: []string{"Score", "Change", "Resource", "Type", "Dependents", "Why"}}
+	var routes []string
+	for _, change := range report.Changes {
+		table.Rows = append(table.Rows, []string{
+			strconv.Itoa(change.Score), change.Change, "`" + change.ID + "`", change.Type,
+			strconv.Itoa(change.Affected), strings.Join(change.Reasons, "; "),
+		})
+		for _, route := range change.Routes {
+			if !slices.Contains(routes, route) {
+				routes = append(routes, route)
+			}
+		}
+	}
+	comment.Sections = append(comment.Sections, output.CommentSection{
+		Title: fmt.Sprintf("Changes (%d)", len(report.Changes)),
+		Body:  table.Markdown(),
+		Open:  report.Level == "high",
+	})
+	if len(routes) > 0 {
+		comment.Sections = append(comment.Sections, output.CommentSection{
+			Title: fmt.Sprintf("API routes affected (%d)", len(routes)),
+			Body:  "- `" + strings.Join(routes, "`\n- `") + "`",
+		})
+	}
+	return comment
+}
+
+// riskSummary asks the model for a pull request narrative of the report.
+func riskSummary(ctx context.Context, dir string, report state.RiskReport) (string, error) {
+	engine, err := newQueryEngine(false, "")
+	if err != nil {
+		return "", err
+	}
+	models, err := engine.models("")
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": "change_risk",
+		"result": report,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resources []string
+	for _, change := range report.Changes {
+		resources = append(resources, change.ID)
+	}
+	question := "How risky is this infrastructure change? Write a short review comment for the pull request, naming the riskiest changes and what they could break."
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+	start := time.Now()
+	text, err := models.router.Phrase(ctx, question, string(result))
+	recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, resources)
+	return text, err
+}
+
+func init() {
+	riskCmd.Flags().BoolVar(&riskNoSummary, "no-summary", false, "score the changes without asking the model for a narrative")
+	addCommentFlags(riskCmd)
+	rootCmd.AddCommand(riskCmd)
+}
@@ -3,32 +3,128 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	awssdk "github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	bedrockruntimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/ddjura/cloudai/internal/aws"
 	"github.com/ddjura/cloudai/internal/llm"
 	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/processor"
 	"github.com/ddjura/cloudai/internal/state"
 	"github.com/ddjura/cloudai/internal/sysinfo"
+	"github.com/ddjura/cloudai/internal/usage"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cfgFile    string
-	jsonOutput bool
-	planMode   bool
+	cfgFile       string
+	jsonOutput    bool
+	planMode      bool
+	costByAccount bool
+	scanLive      bool
+	rawContext    bool
+	rawAnswer     bool
+	noDaemon      bool
+	serveAddr     string
+	serveLive     bool
+	statsDays     int
+	canaryDays    int
+	estimateOnly  bool
+	readOnly      bool
+	auditWithin   int
+
+	modelsYes         bool
+	modelsUseCaseFile string
+	modelsWait        time.Duration
+	noBrowser         bool
+	setupYes          bool
+	queryTier         string
+	noSuggestions     bool
+	exportFormat      string
+	exportOutput      string
+	importFormat      string
+	importType        string
+	scanAWSConfig     bool
+	scanAggregator    string
+	scanDiscover      bool
+	scanRecursive     bool
+	scanIncluded      []string
+	scanExcluded      []string
+	pruneServices     []string
+	pruneTypes        []string
+	pruneImports      []string
+	useProfile        string
+	useRegion         string
+	savedParams       []string
+	savedDescription  string
+	auditLogDays      int
+	selftestGolden    string
+	versionCheck      bool
+	contextFiles      []string
+	impactNoSummary   bool
+	riskNoSummary     bool
+	incidentSince     time.Duration
+	incidentNoSummary bool
+	drRegion          string
+	drNoSummary       bool
+	auditFramework    string
+	auditCSV          bool
+	tunnelLocalPort   int
+	tunnelRemotePort  int
+	tunnelRemoteHost  string
+	tunnelModel       string
+	trainRoleName     string
+	trainBucket       string
+	trainOutput       string
+	trainUpload       bool
+	trainMinRating    float64
+	trainIntentCap    float64
+	trainMaxChars     int
+	trainMetricsOnly  bool
+	trainPollInterval time.Duration
+	trainEvalScores   map[string]string
+	trainInstanceType string
+	deployEndpoint    string
+	deployVersion     int
+	deployInstance    string
+	deployNoWait      bool
+	nl2querySource    string
+	nl2queryRun       bool
+	nl2querySince     time.Duration
+	nl2queryGroups    []string
+	nl2queryNoSummary bool
+	findingsLimit     int
+	findingsSeverity  string
+	findingsNoSummary bool
+	quotasThreshold   float64
+	quotasExtra       []string
+	reportSchedule    string
+	reportDeliver     []string
+	reportFrom        string
+	reportDays        int
+	reportSectionList []string
+	commentOutput     bool
+	commentPost       bool
+	commentProvider   string
+	commentRepo       string
+	commentPR         int
+	constructListOnly bool
+	costPrometheus    bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -47,8 +143,30 @@ Examples:
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
-func Execute() error {
-	return rootCmd.Execute()
+// A panic or an internal error leaves a diagnostic bundle in
+// .cloudai/diagnostics/.
+func Execute() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+			reportCrash(err, debug.Stack())
+		}
+	}()
+	if handled, err := runPluginIfPresent(os.Args[1:]); handled {
+		return err
+	}
+	// Errors are printed by ReportError, with --json as an envelope
+	rootCmd.SilenceErrors = true
+	rootCmd.PersistentPreRun = startCommand
+	err = rootCmd.Execute()
+	llm.WaitCanary()
+	if err != nil && commandStarted && errors.Is(err, errInternal) {
+		reportCrash(err, nil)
+	}
+	if err == nil {
+		notifyUpdate(context.Background())
+	}
+	return err
 }
 
 var setupCmd = &cobra.Command{
@@ -131,7 +249,7 @@ This command will now verify your credentials by listing your Lambda functions.`
 		fmt.Println("Verifying your AWS credentials by listing Lambda functions...")
 
 		ctx := context.Background()
-		awsClient, err := aws.NewClient(ctx)
+		awsClient, err := newAWSClient(ctx)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "❌ AWS client initialization failed: %v\n", err)
 			return err
@@ -162,7 +280,7 @@ Models marked as "Available" can be enabled for use.`,
 		fmt.Println()
 
 		ctx := context.Background()
-		cfg, err := config.LoadDefaultConfig(ctx)
+		cfg, err := aws.LoadConfig(ctx, awsClientOptions()...)
 		if err != nil {
 			return fmt.Errorf("failed to load AWS config: %w", err)
 		}
@@ -235,6 +353,10 @@ It will:
 5. Guide you through the process step-by-step
 6. Test models continuously until access is granted
 
+Over SSH or on a machine without a display (or with --no-browser) the
+console URL is printed with a QR code to open on another device, and access
+is polled for instead of waiting for Enter.
+
 This completely automates the Bedrock setup process.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println()
@@ -244,7 +366,7 @@ This completely automates the Bedrock setup process.`,
 		// Check AWS credentials
 		fmt.Println("1. Checking AWS credentials...")
 		ctx := context.Background()
-		cfg, err := config.LoadDefaultConfig(ctx)
+		cfg, err := aws.LoadConfig(ctx, awsClientOptions()...)
 		if err != nil {
 			fmt.Printf("❌ AWS credentials issue: %v\n", err)
 			fmt.Println("\n📋 To fix this:")
@@ -302,14 +424,12 @@ This completely automates the Bedrock setup process.`,
 		fmt.Println("   🥉 Anthropic Claude 3 Sonnet (high quality)")
 		fmt.Println()
 
+		fmt.Println("💡 'cloudai models enable' can enable them through the Bedrock API instead.")
+		fmt.Println()
+
 		// Automatically open AWS Console
 		consoleURL := fmt.Sprintf("https://%s.console.aws.amazon.com/bedrock/home?region=%s#/modelaccess", region, region)
-		fmt.Printf("📱 Opening AWS Console: %s\n", consoleURL)
-
-		if err := openBrowser(consoleURL); err != nil {
-			fmt.Printf("⚠️  Could not open browser automatically: %v\n", err)
-			fmt.Printf("🔗 Please open this URL manually: %s\n", consoleURL)
-		}
+		browserOpened := presentURL(consoleURL)
 
 		fmt.Println("\n📋 In the AWS Console (follow these steps):")
 		fmt.Println("   1. ✅ Click 'Enable all models' (orange button)")
@@ -320,9 +440,12 @@ This completely automates the Bedrock setup process.`,
 		fmt.Println("   5. ✅ Wait for 'Access granted' status (10-30 seconds)")
 		fmt.Println()
 
-		// Ask user to confirm they've submitted
-		fmt.Print("Press Enter after you've clicked 'Submit' in the AWS Console...")
-		fmt.Scanln()
+		// Ask user to confirm they've submitted. Without a local browser the
+		// steps happen on another device, so just poll until access shows up.
+		if browserOpened {
+			fmt.Print("Press Enter after you've clicked 'Submit' in the AWS Console...")
+			fmt.Scanln()
+		}
 
 		// Continuously test until access is granted
 		fmt.Println("\n⏳ Waiting for model access to be enabled...")
@@ -354,7 +477,7 @@ Just run this one command and CloudAI-CLI will be ready to use!`,
 		// Step 1: Check AWS credentials
 		fmt.Println("1️⃣  Checking AWS credentials...")
 		ctx := context.Background()
-		cfg, err := config.LoadDefaultConfig(ctx)
+		cfg, err := aws.LoadConfig(ctx, awsClientOptions()...)
 		if err != nil {
 			fmt.Printf("❌ AWS credentials not found: %v\n", err)
 			fmt.Println("\n📋 Quick fix:")
@@ -381,19 +504,17 @@ Just run this one command and CloudAI-CLI will be ready to use!`,
 
 			// Open console automatically
 			consoleURL := fmt.Sprintf("https://%s.console.aws.amazon.com/bedrock/home?region=%s#/modelaccess", region, region)
-			fmt.Printf("📱 Opening AWS Console: %s\n", consoleURL)
-
-			if err := openBrowser(consoleURL); err != nil {
-				fmt.Printf("🔗 Please open manually: %s\n", consoleURL)
-			}
+			browserOpened := presentURL(consoleURL)
 
 			fmt.Println("\n📋 In the AWS Console:")
 			fmt.Println("   ✅ Click 'Enable specific models'")
 			fmt.Println("   ✅ Select 'Anthropic Claude' (recommended)")
 			fmt.Println("   ✅ Click 'Next' → 'Submit'")
 			fmt.Println()
-			fmt.Print("Press Enter when you've submitted the request...")
-			fmt.Scanln()
+			if browserOpened {
+				fmt.Print("Press Enter when you've submitted the request...")
+				fmt.Scanln()
+			}
 
 			fmt.Println("\n⏳ Waiting for model access...")
 			if err := waitForModelAccess(ctx, cfg); err != nil {
@@ -446,20 +567,17 @@ Just run this one command and CloudAI-CLI will be ready to use!`,
 			region = "us-east-1"
 		}
 
-		viper.Set("model.type", "aws")
-		viper.Set("model.aws_type", "bedrock")
-		viper.Set("model.model_id", bestModel.ModelID)
-		viper.Set("model.region", region)
-		viper.Set("cost.daily_limit", dailyBudget)
-		viper.Set("cost.prioritize_speed", prioritizeSpeed)
-
-		home, _ := os.UserHomeDir()
-		configPath := home + "/.cloudai.yaml"
-		if err := viper.WriteConfigAs(configPath); err != nil {
+		if err := saveConfig(map[string]interface{}{
+			"model.type":            "aws",
+			"model.aws_type":        "bedrock",
+			"model.model_id":        bestModel.ModelID,
+			"model.region":          region,
+			"cost.daily_limit":      dailyBudget,
+			"cost.prioritize_speed": prioritizeSpeed,
+		}); err != nil {
 			return fmt.Errorf("failed to save configuration: %w", err)
 		}
-
-		fmt.Printf("✅ Configuration saved to: %s\n", configPath)
+		fmt.Println("✅ Configuration saved")
 
 		// Step 5: Final test
 		fmt.Println("\n5️⃣  Testing complete setup...")
@@ -509,7 +627,36 @@ var scanCmd = &cobra.Command{
 or a live AWS account to create a cache of the infrastructure state.
 
 This cached state is then used to answer general questions about your infrastructure.
-If no path is provided, it scans the current directory.`,
+If no path is provided, it scans the current directory.
+
+With --live, the AWS account behind your current credentials is scanned instead and the
+result is cached in the given directory. Services you lack permission for are skipped and
+reported as warnings together with the IAM actions that would enable them.
+
+Live scans start by listing every tagged resource through the Resource Groups Tagging API,
+so services without a dedicated scanner still appear by ARN and tags. With --discover only
+that listing is stored, which keeps scans of large accounts fast: a question then fetches
+the configuration of just the resources it names, or of the services it asks about, and
+the details are saved to the cache so they are fetched only once.
+
+With --aws-config, the state is read from the inventory AWS Config already records in the
+account, which is faster and covers every recorded resource type. --aggregator reads a
+Config aggregator instead, spanning all of its accounts and regions.
+
+--services keeps only the listed services and --exclude leaves services out, which
+keeps scans fast and the question context small. Services are named after the
+CloudFormation type (lambda for AWS::Lambda::Function); live scans skip the scanners
+of unselected services altogether.
+
+With --recursive, every CDK app (with a synthesized cdk.out) and Terraform configuration
+under the path is scanned into one state cached in the path, so a monorepo's services can
+be asked about together. Resources are named <project>/<logical ID> and record the project
+they come from; projects that fail to scan are reported and left out.
+
+Examples:
+  cloudai scan --recursive ~/src/platform
+  cloudai scan --live --services lambda,apigateway,dynamodb
+  cloudai scan --exclude iam,logs`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		scanPath := "."
@@ -520,11 +667,64 @@ If no path is provided, it scans the current directory.`,
 		if err != nil {
 			return fmt.Errorf("error getting absolute path: %w", err)
 		}
+		selectEnvironment(absPath)
+		if err := selectPolicy(absPath); err != nil {
+			return err
+		}
+		for _, list := range [][]string{scanIncluded, scanExcluded} {
+			for i, service := range list {
+				list[i] = strings.ToLower(strings.TrimSpace(service))
+			}
+		}
 
-		fmt.Printf("Scanning for infrastructure in: %s\n", absPath)
+		ctx := context.Background()
+		var provider state.Provider
+		if scanAWSConfig || scanAggregator != "" {
+			if scanAggregator != "" {
+				fmt.Printf("Reading the AWS Config inventory of aggregator %s...\n", scanAggregator)
+			} else {
+				fmt.Println("Reading the AWS Config inventory...")
+			}
+			awsClient, err := newAWSClient(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to initialize AWS client: %w", err)
+			}
+			provider = &state.ConfigProvider{Client: awsClient, Aggregator: scanAggregator}
+		} else if scanLive || scanDiscover {
+			fmt.Println("Scanning live AWS account...")
+			awsClient, err := newAWSClient(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to initialize AWS client: %w", err)
+			}
+			provider = &state.LiveProvider{Client: awsClient, Services: scanIncluded, Exclude: scanExcluded}
+			if scanDiscover {
+				provider = &state.DiscoveryProvider{Client: awsClient}
+			}
+		} else if scanRecursive {
+			projects := state.FindIaCProjects(absPath)
+			fmt.Printf("Scanning %d projects under: %s\n", len(projects), absPath)
+			provider = &state.FederatedProvider{}
+		} else {
+			fmt.Printf("Scanning for infrastructure in: %s\n", absPath)
+			provider = &state.IaCProvider{}
+		}
 
-		iacProvider := &state.IaCProvider{}
-		infraState, err := iacProvider.Scan(context.Background(), absPath)
+		noteOperation("scanning with %T", provider)
+		infraState, err := provider.Scan(ctx, absPath)
+		if err == nil {
+			if dropped := state.FilterServices(infraState, scanIncluded, scanExcluded); dropped > 0 {
+				fmt.Printf("Left out %d resources of unselected services\n", dropped)
+			}
+			if projects, ok := infraState["Projects"].([]state.ProjectScan); ok && !jsonOutput {
+				for _, project := range projects {
+					if project.Error != "" {
+						fmt.Fprintf(os.Stderr, "⚠️  %s: %s\n", project.Path, project.Error)
+					} else {
+						fmt.Printf("   📦 %s: %d resources\n", project.Path, project.Resources)
+					}
+				}
+			}
+		}
 
 		formatter := output.NewFormatter(jsonOutput)
 		var result *output.Result
@@ -537,11 +737,24 @@ If no path is provided, it scans the current directory.`,
 			}
 		} else {
 			// Save the successful scan to cache
-			cacheManager := state.NewCacheManager(absPath)
-			if err := cacheManager.Save(infraState); err != nil {
+			cacheManager, err := newCacheManager(absPath)
+			if err == nil {
+				keepImports(cacheManager, infraState)
+				err = cacheManager.Save(infraState)
+			}
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: could not save cache: %v\n", err)
 			} else {
-				fmt.Println("Successfully saved infrastructure state to .cloudai/cache.json")
+				savedTo := cacheManager.Path()
+				if rel, err := filepath.Rel(absPath, savedTo); err == nil {
+					savedTo = rel
+				}
+				fmt.Printf("Successfully saved infrastructure state to %s\n", savedTo)
+			}
+			if prompt, err := promptContext(infraState); err == nil {
+				for _, warning := range contextBudgetWarnings(prompt) {
+					fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+				}
 			}
 
 			result = &output.Result{
@@ -593,7 +806,7 @@ This command will:
 			ollamaURL = "http://localhost:11434"
 		}
 
-		if isOllamaAvailable(ollamaURL) {
+		if llm.OllamaAvailable(ollamaURL) {
 			// Get current model
 			currentModel := os.Getenv("OLLAMA_MODEL")
 			if currentModel == "" {
@@ -610,7 +823,7 @@ This command will:
 
 			// List available models
 			fmt.Println("\n📋 Available models in Ollama:")
-			availableModels, err := getAvailableModels(ollamaURL)
+			availableModels, err := llm.OllamaModels(ollamaURL)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "❌ Failed to get available models: %v\n", err)
 				return err
@@ -658,7 +871,7 @@ This command will:
 			fmt.Println("      export AWS_MODEL_ID=anthropic.claude-3-haiku-20240307-v1:0")
 			fmt.Println("      export AWS_REGION=us-east-1")
 		}
-		if isOllamaAvailable(ollamaURL) {
+		if llm.OllamaAvailable(ollamaURL) {
 			fmt.Println("   • Set OLLAMA_MODEL to override auto-selection")
 			fmt.Println("   • Install more models: ollama pull llama3.2:3b")
 			fmt.Println("   • Smaller models are faster but less accurate")
@@ -673,38 +886,50 @@ This command will:
 var costCmd = &cobra.Command{
 	Use:   "cost",
 	Short: "Show current cost usage and budget information",
-	Long: `Shows current daily cost usage and remaining budget for AWS models.
+	Long: `Shows current daily cost usage and remaining budget across all model backends.
 
 This command displays:
 - Current daily spending
 - Remaining budget
 - Number of requests made today
-- Cost per request statistics`,
+- Cost per request statistics
+- Per-backend requests, tokens, cost, latency and throughput (Bedrock,
+  SageMaker, OpenAI and local Ollama models)
+- Per-project requests, tokens and cost, keyed by each project's cache
+  path
+
+With --by-account, it instead reports AWS spend per linked account for the last
+two full months using Cost Explorer (requires management account credentials
+to see every account in the organization).
+
+With --prometheus, it prints today's usage and budget and this month's
+per-project spend as Prometheus gauges for node_exporter's textfile collector,
+so spend can be alerted on without running the server. Write to a temporary
+file and rename it so the collector never reads a partial file:
+
+  cloudai cost --prometheus > /var/lib/node_exporter/cloudai.prom.$$ &&
+    mv /var/lib/node_exporter/cloudai.prom.$$ /var/lib/node_exporter/cloudai.prom`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("💰 CloudAI-CLI Cost Information")
-
-		// Check if using AWS models
-		modelType := getConfigString("model.type")
-		if modelType != "aws" {
-			fmt.Println("ℹ️  Cost tracking is only available for AWS models.")
-			fmt.Println("   Local Ollama models are free to use.")
-			fmt.Println("   Run 'cloudai setup-interactive' to configure AWS models.")
-			return nil
+		if costByAccount {
+			return runCostByAccount(context.Background())
+		}
+		if costPrometheus {
+			return printCostMetrics()
 		}
 
+		fmt.Println("💰 CloudAI-CLI Cost Information")
+
 		// Load cost manager
-		dailyLimit := getConfigFloat("cost.daily_limit")
-		if dailyLimit == 0 {
-			dailyLimit = 5.0 // Default
+		costManager := llm.NewCostManager(llm.DailyLimit())
+		if err := costManager.LoadUsage(); err != nil {
+			return err
 		}
-
-		costManager := llm.NewCostManager(dailyLimit)
 		usage := costManager.GetUsageStats()
 		remaining := costManager.GetRemainingBudget()
 
 		// Display current usage
 		fmt.Printf("📊 Daily Usage (today: %s)\n", usage.Date)
-		fmt.Printf("   Spent: $%.4f / $%.2f\n", usage.TotalCost, dailyLimit)
+		fmt.Printf("   Spent: $%.4f / $%.2f\n", usage.TotalCost, costManager.DailyLimit)
 		fmt.Printf("   Remaining: $%.4f\n", remaining)
 		fmt.Printf("   Requests: %d\n", usage.RequestCount)
 		fmt.Printf("   Tokens used: %d\n", usage.TokensUsed)
@@ -715,7 +940,7 @@ This command displays:
 		}
 
 		// Show progress bar
-		percentage := (usage.TotalCost / dailyLimit) * 100
+		percentage := (usage.TotalCost / costManager.DailyLimit) * 100
 		fmt.Printf("\n📈 Budget Usage: %.1f%%\n", percentage)
 
 		barWidth := 30
@@ -723,8 +948,14 @@ This command displays:
 		bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
 		fmt.Printf("   [%s]\n", bar)
 
+		printBackendUsage(usage)
+		printProjectUsage(usage)
+
 		// Show model information
 		modelID := getConfigString("model.model_id")
+		if getConfigString("model.type") == "openai" {
+			modelID = getConfigString("openai.model")
+		}
 		if modelCost := llm.GetModelCost(modelID); modelCost != nil {
 			fmt.Printf("\n🤖 Current Model: %s\n", modelID)
 			fmt.Printf("   Input cost: $%.4f per 1K tokens\n", modelCost.InputTokenCost)
@@ -745,35 +976,201 @@ This command displays:
 	},
 }
 
-// Helper functions for the model command
-func isOllamaAvailable(url string) bool {
-	resp, err := http.Get(url + "/api/tags")
-	if err != nil {
-		return false
+// printBackendUsage prints today's usage per backend and model. Local models
+// cost nothing, so their latency and tokens per second are shown instead.
+func printBackendUsage(usage llm.CostTracker) {
+	if len(usage.Backends) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(usage.Backends))
+	for key := range usage.Backends {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	table := &output.Table{
+		Title:   "By Backend",
+		Headers: []string{"Backend", "Model", "Requests", "Tokens in/out", "Cost", "Avg latency", "Tokens/s"},
 	}
-	defer resp.Body.Close()
-	return resp.StatusCode == 200
+	for _, key := range keys {
+		b := usage.Backends[key]
+		cost := fmt.Sprintf("$%.4f", b.Cost)
+		switch b.Backend {
+		case "ollama":
+			cost = "free (local)"
+		case "tgi":
+			cost = "free (self-hosted)"
+		}
+		table.Rows = append(table.Rows, []string{
+			b.Backend, b.Model, fmt.Sprint(b.Requests),
+			fmt.Sprintf("%d/%d", b.InputTokens, b.OutputTokens), cost,
+			b.AvgLatency().Round(time.Millisecond).String(), fmt.Sprintf("%.1f", b.TokensPerSecond()),
+		})
+	}
+	fmt.Println()
+	table.Print()
+}
+
+// printProjectUsage prints today's usage per project, so work for different
+// repositories can be told apart. Projects are keyed by cache path, shown
+// relative to the home directory; the rows add up to the daily total.
+func printProjectUsage(usage llm.CostTracker) {
+	if len(usage.Projects) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(usage.Projects))
+	for key := range usage.Projects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	home, _ := os.UserHomeDir()
+	table := &output.Table{
+		Title:   "By Project",
+		Headers: []string{"Project cache", "Requests", "Tokens in/out", "Cost"},
+	}
+	requests, cost := 0, 0.0
+	for _, key := range keys {
+		p := usage.Projects[key]
+		project := p.Project
+		switch {
+		case project == "":
+			project = "(no project)"
+		case home != "" && strings.HasPrefix(project, home+string(filepath.Separator)):
+			project = "~" + strings.TrimPrefix(project, home)
+		}
+		table.Rows = append(table.Rows, []string{
+			project, fmt.Sprint(p.Requests), fmt.Sprintf("%d/%d", p.InputTokens, p.OutputTokens), fmt.Sprintf("$%.4f", p.Cost),
+		})
+		requests += p.Requests
+		cost += p.Cost
+	}
+	// Usage tracked before projects were recorded
+	if rest := usage.RequestCount - requests; rest > 0 {
+		table.Rows = append(table.Rows, []string{"(not attributed)", fmt.Sprint(rest), "-", fmt.Sprintf("$%.4f", usage.TotalCost-cost)})
+	}
+	fmt.Println()
+	table.Print()
 }
 
-func getAvailableModels(ollamaURL string) ([]llm.AvailableModel, error) {
-	resp, err := http.Get(ollamaURL + "/api/tags")
+// runCostByAccount prints the per-account AWS cost rollup for the last two months
+func runCostByAccount(ctx context.Context) error {
+	awsClient, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+
+	isManagement, caller, management, err := awsClient.IsManagementAccount(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not determine organization membership: %v\n", err)
+	}
+
+	report, err := awsClient.CostByAccount(ctx, time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ollama: %w", err)
+		return err
+	}
+	report.IsManagement = isManagement
+	report.CallerAccountID = caller
+	report.ManagementAccount = management
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "cost --by-account",
+			Data:    report,
+			Success: true,
+		})
+	}
+
+	fmt.Println("💰 AWS Cost by Account")
+	if !isManagement {
+		fmt.Println("⚠️  These credentials do not belong to the organization's management account.")
+		fmt.Println("   Only costs visible to this account are shown.")
+	}
+	fmt.Printf("\n%-14s %-28s %12s %12s %12s %8s\n", "ACCOUNT", "NAME", report.PreviousMonth, report.CurrentMonth, "CHANGE", "%")
+	for _, acct := range report.Accounts {
+		name := acct.AccountName
+		if runes := []rune(name); len(runes) > 28 {
+			name = string(runes[:25]) + "..."
+		}
+		fmt.Printf("%-14s %-28s %12.2f %12.2f %+12.2f %+7.1f%%\n",
+			acct.AccountID, name, acct.PreviousCost, acct.CurrentCost, acct.Change, acct.ChangePct)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Ollama API returned status %d", resp.StatusCode)
+	if len(report.Accounts) > 0 && report.Accounts[0].Change > 0 {
+		top := report.Accounts[0]
+		label := top.AccountID
+		if top.AccountName != "" {
+			label = fmt.Sprintf("%s (%s)", top.AccountName, top.AccountID)
+		}
+		fmt.Printf("\n📈 Largest growth: %s, +%.2f %s month over month\n", label, top.Change, report.Currency)
 	}
 
-	var result struct {
-		Models []llm.AvailableModel `json:"models"`
+	return nil
+}
+
+// refuseReadOnly returns an error when --read-only was passed explicitly to
+// a command that lifts the read-only guard. change says what the command
+// does to the account, e.g. "creates a bucket and a role".
+func refuseReadOnly(cmd *cobra.Command, change string) error {
+	if cmd.Flags().Changed("read-only") && readOnly {
+		return fmt.Errorf("%s %s, which --read-only forbids", cmd.Name(), change)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
+	return nil
+}
+
+// newAWSClient creates the AWS client for commands. It is read-only unless
+// --read-only=false is passed.
+func newAWSClient(ctx context.Context) (*aws.Client, error) {
+	opts := awsClientOptions()
+	if readOnly {
+		return aws.NewClient(ctx, opts...)
 	}
+	fmt.Fprintln(os.Stderr, "⚠️  Read-only guard disabled (--read-only=false): AWS calls are not restricted to reads")
+	return aws.NewClient(ctx, append(opts, aws.WithWrites())...)
+}
 
-	return result.Models, nil
+// awsClientOptions returns the profile, region and endpoint of the active
+// environment as client options.
+func awsClientOptions() []aws.Option {
+	var opts []aws.Option
+	if activeEnvironment.Profile != "" {
+		opts = append(opts, aws.WithProfile(activeEnvironment.Profile))
+	}
+	if activeEnvironment.Region != "" {
+		opts = append(opts, aws.WithRegion(activeEnvironment.Region))
+	}
+	if endpoint := getConfigString("aws.endpoint_url"); endpoint != "" {
+		opts = append(opts, aws.WithEndpoint(endpoint))
+	}
+	return opts
+}
+
+// newCacheManager returns the cache manager for dir, encrypting the cache at
+// rest when cache.encrypt is set. The key comes from the OS keyring by
+// default, or from KMS with cache.key_source: kms and cache.kms_key_id.
+func newCacheManager(dir string) (*state.CacheManager, error) {
+	cacheManager := state.NewCacheManager(dir)
+	if !viper.GetBool("cache.encrypt") {
+		return cacheManager, nil
+	}
+
+	switch source := getConfigString("cache.key_source"); source {
+	case "", "keyring":
+		cacheManager.SetCipher(state.KeyringCipher{})
+	case "kms":
+		keyID := getConfigString("cache.kms_key_id")
+		if keyID == "" {
+			return nil, fmt.Errorf("cache.key_source is kms but cache.kms_key_id is not set")
+		}
+		awsClient, err := newAWSClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
+		}
+		cacheManager.SetCipher(state.KMSCipher{Client: awsClient, KeyID: keyID})
+	default:
+		return nil, fmt.Errorf("unknown cache.key_source %q (use keyring or kms)", source)
+	}
+	return cacheManager, nil
 }
 
 func getConfigString(key string) string {
@@ -791,6 +1188,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cloudai.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "output in JSON format for automation")
 	rootCmd.PersistentFlags().BoolVar(&planMode, "plan", false, "print remediation scripts (never executed)")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", true, "reject any AWS API call that could modify infrastructure")
 
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(bedrockSetupCmd)
@@ -799,6 +1197,26 @@ func init() {
 	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(modelCmd)
 	rootCmd.AddCommand(costCmd)
+
+	rootCmd.Flags().BoolVar(&noSuggestions, "no-suggestions", false, "do not suggest follow-up questions after the answer (also answer.suggestions: false)")
+	rootCmd.Flags().BoolVar(&rawContext, "raw-context", false, "send the full cached state to the model without summarizing or deduplicating it")
+	rootCmd.Flags().BoolVar(&noDaemon, "no-daemon", false, "answer in this process even if 'cloudai daemon' is running")
+	rootCmd.Flags().BoolVar(&rawAnswer, "raw-answer", false, "print the model output without post-processing (same as answer.clean: false)")
+	rootCmd.Flags().StringVar(&queryTier, "tier", "", "model tier to answer with: fast or smart (models.fast / models.smart in the config)")
+	rootCmd.Flags().StringArrayVar(&contextFiles, "context-file", nil, "add a document, e.g. a runbook or OpenAPI spec, to the context (repeatable; credentials are redacted)")
+	rootCmd.Flags().BoolVar(&estimateOnly, "estimate-only", false, "print the estimated prompt size and cost per model, then exit without asking")
+	scanCmd.Flags().BoolVar(&scanLive, "live", false, "scan the live AWS account instead of IaC files")
+	scanCmd.Flags().BoolVar(&scanRecursive, "recursive", false, "scan every CDK and Terraform project under the path into one state")
+	scanCmd.Flags().BoolVar(&scanDiscover, "discover", false, "only list resources through the Tagging API; details are fetched per question")
+	scanCmd.Flags().BoolVar(&scanAWSConfig, "aws-config", false, "read the live state from the AWS Config inventory")
+	scanCmd.Flags().StringVar(&scanAggregator, "aggregator", "", "read the live state from this AWS Config aggregator")
+	scanCmd.Flags().StringSliceVar(&scanIncluded, "services", nil, "only keep these services, e.g. lambda,apigateway,dynamodb")
+	scanCmd.Flags().StringSliceVar(&scanExcluded, "exclude", nil, "leave these services out of the scan")
+	costCmd.Flags().BoolVar(&costByAccount, "by-account", false, "show AWS spend per linked account (Organizations management account)")
+	costCmd.Flags().BoolVar(&costPrometheus, "prometheus", false, "print usage and budget as Prometheus text for the node_exporter textfile collector")
+	bedrockSetupCmd.Flags().BoolVar(&noBrowser, "no-browser", false, "print the console URL and a QR code instead of opening a browser")
+	autoSetupCmd.Flags().BoolVarP(&setupYes, "yes", "y", false, "write the configuration without asking for confirmation")
+	autoSetupCmd.Flags().BoolVar(&noBrowser, "no-browser", false, "print the console URL and a QR code instead of opening a browser")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -823,112 +1241,779 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		selectEnvironment(cwd)
+		policyErr = selectPolicy(cwd)
+	}
 }
 
 func runQuery(cmd *cobra.Command, args []string) error {
 	userQuery := args[0]
 	ctx := context.Background()
 
-	// 1. Find and load the infrastructure context from cache
 	// We assume the user is running the command from a path that contains the cache
 	// A more robust solution would search parent directories
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("could not get current working directory: %w", err)
 	}
-	cacheManager := state.NewCacheManager(cwd)
-	if !cacheManager.Exists() {
-		return fmt.Errorf("no infrastructure cache found in this directory. Please run `cloudai scan` first")
+
+	if estimateOnly {
+		return runEstimate(cwd, userQuery)
+	}
+	if rawAnswer {
+		viper.Set("answer.clean", false)
 	}
 
-	infraState, err := cacheManager.Load()
+	fmt.Println("Asking AI to reason about your infrastructure (multi-model)...")
+
+	// A running daemon answers with warm clients; flags that change how the
+	// answer is built are only honoured in-process.
+	suggest := !noSuggestions && (!viper.IsSet("answer.suggestions") || viper.GetBool("answer.suggestions"))
+	start := time.Now()
+	var result queryResult
+	viaDaemon := false
+	// The daemon's AWS clients use its own credentials, not the profile of
+	// this project's environment, and it does not read the project's policy,
+	// a --config file or --read-only=false.
+	if !noDaemon && !rawAnswer && !rawContext && !planMode && len(contextFiles) == 0 && cfgFile == "" && readOnly && activeEnvironment == (state.Environment{}) && llm.ActivePolicy() == nil && policyErr == nil {
+		result, viaDaemon, err = askDaemon(ctx, cwd, userQuery, queryTier, suggest)
+		if err != nil {
+			return err
+		}
+	}
+	if !viaDaemon {
+		result, err = answerQuestion(ctx, cwd, userQuery, queryTier, suggest)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Print the answer in a cleaner format
+	fmt.Println("\n🤖 AI Answer:")
+	fmt.Println("─" + strings.Repeat("─", 50))
+	fmt.Println(strings.TrimSpace(result.Answer))
+	fmt.Println("─" + strings.Repeat("─", 50))
+	if viper.GetBool("answer.footer") {
+		printUsageFooter(result.Usage, time.Since(start))
+	}
+	printFollowUps(result.FollowUps)
+
+	return nil
+}
+
+// printFollowUps lists suggested follow-up questions under the answer,
+// dimmed on a terminal unless NO_COLOR is set.
+func printFollowUps(followUps []string) {
+	if len(followUps) == 0 {
+		return
+	}
+	dim, reset := dimCodes()
+	fmt.Printf("%s💡 You could also ask:\n", dim)
+	for _, q := range followUps {
+		fmt.Printf("   cloudai %q\n", q)
+	}
+	fmt.Print(reset)
+}
+
+// dimCodes returns the escape codes that dim terminal output, or nothing
+// when stdout is not a terminal or NO_COLOR is set.
+func dimCodes() (dim, reset string) {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		return "\033[2m", "\033[0m"
+	}
+	return "", ""
+}
+
+// printUsageFooter prints what the answer cost on one line, such as
+// "claude-haiku, 2.3s, 1.8k in / 240 out tokens, $0.0021, budget 42% used",
+// dimmed like the follow-ups. The time is the whole answer's, context
+// loading included; budget is today's share of cost.daily_limit.
+func printUsageFooter(u llm.Usage, elapsed time.Duration) {
+	model := u.Model
+	if model == "" {
+		model = u.Backend
+	}
+	parts := []string{
+		model,
+		fmt.Sprintf("%.1fs", elapsed.Seconds()),
+		fmt.Sprintf("%s in / %s out tokens", shortCount(u.InputTokens), shortCount(u.OutputTokens)),
+	}
+	switch u.Backend {
+	case "ollama", "mock":
+		parts = append(parts, "free (local)")
+	case "tgi":
+		parts = append(parts, "free (self-hosted)")
+	default:
+		parts = append(parts, fmt.Sprintf("$%.4f", u.Cost))
+		if spent, limit := llm.BudgetUsed(); limit > 0 {
+			parts = append(parts, fmt.Sprintf("budget %.0f%% used", 100*spent/limit))
+		}
+	}
+
+	dim, reset := dimCodes()
+	fmt.Printf("%s%s%s\n", dim, strings.Join(parts, ", "), reset)
+}
+
+// shortCount writes token counts of a thousand or more as 1.8k
+func shortCount(n int) string {
+	if n < 1000 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
+
+// answerQuestion answers a question from the infrastructure cache in dir
+// using the given model tier ("" for the default). It is shared by the CLI
+// and 'cloudai serve'.
+func answerQuestion(ctx context.Context, dir, userQuery, tier string, suggest bool) (queryResult, error) {
+	engine, err := newQueryEngine(false, tier)
+	if err != nil {
+		return queryResult{}, err
+	}
+	return engine.answer(ctx, dir, userQuery, tier, suggest)
+}
+
+// queryEngine answers questions with one set of model clients. The CLI
+// builds one per question; 'cloudai daemon' keeps one warm, together with
+// each project's prompt context until its cache file changes.
+type queryEngine struct {
+	archClient *llm.Client
+
+	// tiers holds the general client and router per model tier, created on
+	// first use; "" is the default general model
+	tiers map[string]*tierModels
+
+	// mu serializes questions: the clients track usage per call
+	mu       sync.Mutex
+	keepWarm bool
+	warm     map[string]*warmContext
+}
+
+// projects returns the directories the engine has answered questions about.
+func (e *queryEngine) projects() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	dirs := make([]string, 0, len(e.warm))
+	for dir := range e.warm {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// warmContext is a project's loaded cache and serialized prompt context
+type warmContext struct {
+	path    string
+	modTime time.Time
+	state   map[string]interface{}
+	prompt  string
+}
+
+// tierModels is the general client of a tier and the router built on it
+type tierModels struct {
+	client *llm.Client
+	router *llm.Router
+}
+
+// newQueryEngine initializes the LLM clients (general + architecture-aware)
+// for tier and the router. With keepWarm, loaded caches are kept in memory.
+func newQueryEngine(keepWarm bool, tier string) (*queryEngine, error) {
+	archClient, err := llm.NewArchClientFromConfig() // may return nil if not configured
+	if err != nil {
+		return nil, fmt.Errorf("failed to create architecture model client: %w", err)
+	}
+	if err := checkPolicy(archClient); err != nil {
+		return nil, err
+	}
+
+	e := &queryEngine{
+		archClient: archClient,
+		tiers:      make(map[string]*tierModels),
+		keepWarm:   keepWarm,
+		warm:       make(map[string]*warmContext),
+	}
+	if _, err := e.models(tier); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// models returns the clients for tier, creating them on first use. A
+// fast-tier router escalates refusals to the smart tier when one is
+// configured, unless models.escalate is false.
+func (e *queryEngine) models(tier string) (*tierModels, error) {
+	if tier == "" {
+		tier = getConfigString("models.default_tier")
+	}
+	if m, ok := e.tiers[tier]; ok {
+		return m, nil
+	}
+
+	var client *llm.Client
+	var err error
+	switch tier {
+	case "":
+		client, err = llm.NewClient()
+	case llm.TierFast, llm.TierSmart:
+		client, err = llm.NewTierClient(tier)
+		if err == nil && client == nil {
+			return nil, fmt.Errorf("no %s tier configured; set models.%s in %s", tier, tier, viper.ConfigFileUsed())
+		}
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "🎚️  %s tier: %s (%s)\n", tier, client.Model(), client.Backend())
+		}
+	default:
+		return nil, fmt.Errorf("unknown tier %q: use fast or smart", tier)
+	}
 	if err != nil {
-		return fmt.Errorf("could not load infrastructure cache: %w", err)
+		return nil, fmt.Errorf("could not initialize general LLM client: %w: %w", llm.ErrModelUnavailable, err)
+	}
+	if err := checkPolicy(client); err != nil {
+		return nil, err
+	}
+
+	m := &tierModels{client: client, router: llm.NewRouter(e.archClient, client)}
+	if tier == llm.TierFast && (!viper.IsSet("models.escalate") || viper.GetBool("models.escalate")) {
+		if getConfigString("models."+llm.TierSmart) != "" {
+			smart, err := e.models(llm.TierSmart)
+			if err != nil {
+				return nil, err
+			}
+			m.router.EscalateTo(smart.client)
+		}
 	}
+	e.tiers[tier] = m
+	return m, nil
+}
+
+// queryResult is an answer together with the follow-up questions suggested
+// for it.
+type queryResult struct {
+	Answer    string
+	FollowUps []string
+	Usage     llm.Usage
+}
 
-	// 2. Serialize the context for the LLM prompt
+// answer answers a question from the project in dir. With suggest, the model
+// also proposes follow-up questions grounded in the same context.
+func (e *queryEngine) answer(ctx context.Context, dir, userQuery, tier string, suggest bool) (queryResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ctx = llm.WithProject(ctx, projectCache(dir))
+
+	models, err := e.models(tier)
+	if err != nil {
+		return queryResult{}, err
+	}
+
+	// 0. Requests to change infrastructure are never answered as questions,
+	// which invites the model to reply as if it had acted; they get a plan
+	// to review under a notice that nothing was changed
+	if llm.IsChangeRequest(userQuery) {
+		return e.planChange(ctx, dir, userQuery, models)
+	}
+
+	// 1. Questions a deterministic handler recognises are answered from its
+	// result, with the LLM only phrasing it; everything else falls back to
+	// RAG over the cached infrastructure state.
+	var mentioned []string
+	var names map[string]string
+	answer := models.router.Answer
+	noteOperation("running the deterministic handlers")
+	contextString, err := deterministicContext(ctx, dir, userQuery, models.client)
+	if err == nil {
+		answer = models.router.Phrase
+	} else {
+		if !errors.Is(err, processor.ErrNotHandled) {
+			fmt.Fprintf(os.Stderr, "⚠️  %v; answering from the cache instead\n", err)
+		}
+		noteOperation("loading the infrastructure context")
+		contextString, mentioned, names, err = e.queryContext(ctx, dir, userQuery)
+		if err != nil {
+			return queryResult{}, err
+		}
+	}
+	if contextString, err = withContextFiles(contextString); err != nil {
+		return queryResult{}, err
+	}
+	noteOperation("asking %s (%s)", models.client.Model(), models.client.Backend())
+
+	// 2. Ask the router to answer the question using the selected context
+	start := time.Now()
+	text, err := answer(ctx, userQuery, contextString)
+	usage := models.router.LastUsage()
+	recordUsage(dir, usage, time.Since(start), err == nil, mentioned)
+	if err != nil {
+		return queryResult{Usage: usage}, fmt.Errorf("AI failed to answer the question: %w", err)
+	}
+
+	// 3. A refusal usually means the context lacked the detail asked about;
+	// retry once with the full cache and a live scan of the services the
+	// question names, unless query.retry_refusals is false
+	if llm.IsRefusal(text) && (!viper.IsSet("query.retry_refusals") || viper.GetBool("query.retry_refusals")) {
+		broader, err := broaderContext(ctx, dir, userQuery)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not widen the context: %v\n", err)
+		} else if broader != "" && broader != contextString {
+			fmt.Fprintln(os.Stderr, "🔁 The model could not answer; retrying with the full infrastructure state")
+			noteOperation("retrying with the full infrastructure state")
+			start = time.Now()
+			retried, err := models.router.Answer(ctx, userQuery, broader)
+			retryUsage := models.router.LastUsage()
+			recordUsage(dir, retryUsage, time.Since(start), err == nil, mentioned)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Retry failed: %v\n", err)
+			} else {
+				text, usage, contextString = retried, retryUsage, broader
+			}
+		}
+	}
+	result := queryResult{Answer: llm.LoadPostProcessConfig().UseNames(text, names), Usage: usage}
+
+	// 4. Suggest where to go next; a failure here never costs the answer
+	if suggest {
+		noteOperation("suggesting follow-up questions")
+		start = time.Now()
+		followUps, err := models.router.FollowUps(ctx, userQuery, text, contextString)
+		recordUsage(dir, models.router.LastUsage(), time.Since(start), err == nil, mentioned)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+		result.FollowUps = followUps
+	}
+	return result, nil
+}
+
+// broaderContext is the context for retrying a refused question: the cached
+// state without summarizing or deduplicating, plus a live scan of the
+// services the question mentions when AWS credentials are available. It
+// returns "" when there is nothing to add.
+func broaderContext(ctx context.Context, dir, userQuery string) (string, error) {
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		infraState = map[string]interface{}{}
+	}
+
+	if services := state.MentionedServices(userQuery); len(services) > 0 {
+		fmt.Fprintf(os.Stderr, "🔍 Scanning %s in the live account...\n", strings.Join(services, ", "))
+		live, err := scanServices(ctx, services)
+		if err != nil {
+			// the full cache alone may still hold the answer
+			fmt.Fprintf(os.Stderr, "⚠️  Live scan failed: %v\n", err)
+		} else {
+			infraState["Live"] = live
+		}
+	}
+
+	if len(infraState) == 0 {
+		return "", nil
+	}
+	includes, err := contextIncludes()
+	if err != nil {
+		return "", err
+	}
+	infraState = state.ApplyIncludes(state.StripSecrets(infraState), includes)
+	if live, ok := infraState["Live"].(map[string]interface{}); ok {
+		infraState["Live"] = state.ApplyIncludes(live, includes)
+	}
 	contextBytes, err := json.Marshal(infraState)
 	if err != nil {
-		return fmt.Errorf("could not serialize infrastructure state for LLM: %w", err)
+		return "", internalError(fmt.Errorf("could not serialize infrastructure state for LLM: %w", err))
 	}
-	contextString := string(contextBytes)
+	return withContextFiles(string(contextBytes))
+}
 
-	// 3. Initialize LLM clients (general + architecture-aware) and router
-	generalClient, err := llm.NewClient()
+// scanServices runs a live scan of the given services.
+func scanServices(ctx context.Context, services []string) (map[string]interface{}, error) {
+	awsClient, err := newAWSClient(ctx)
 	if err != nil {
-		return fmt.Errorf("could not initialize general LLM client: %w", err)
+		return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	return (&state.LiveProvider{Client: awsClient}).ScanServices(ctx, services)
+}
+
+// queryContext is loadQueryContext, reusing the warm copy of the project's
+// cache while the cache file is unchanged. A shallow inventory from
+// 'scan --discover' is first enriched with what the question needs. It also
+// returns the friendly names of the cached resources.
+func (e *queryEngine) queryContext(ctx context.Context, dir, userQuery string) (string, []string, map[string]string, error) {
+	var infraState map[string]interface{}
+	cachePath := state.NewCacheManager(dir).Path()
+	info, statErr := os.Stat(cachePath)
+	if w, ok := e.warm[dir]; e.keepWarm && ok && statErr == nil && w.path == cachePath && w.modTime.Equal(info.ModTime()) {
+		if !state.IsShallow(w.state) {
+			return w.prompt, state.MentionedResources(w.state, userQuery), state.FriendlyNames(w.state), nil
+		}
+		infraState = w.state
+	} else {
+		loaded, err := loadCachedState(dir)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		infraState = loaded
+	}
+
+	mentioned := state.MentionedResources(infraState, userQuery)
+	if !state.IsShallow(infraState) {
+		prompt, err := promptContext(infraState)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if e.keepWarm && statErr == nil {
+			e.warm[dir] = &warmContext{path: cachePath, modTime: info.ModTime(), state: infraState, prompt: prompt}
+		}
+		return prompt, mentioned, state.FriendlyNames(infraState), nil
 	}
 
-	archClient, err := llm.NewArchClientFromEnv() // may return nil if not configured
+	if e.keepWarm && statErr == nil {
+		e.warm[dir] = &warmContext{path: cachePath, modTime: info.ModTime(), state: infraState}
+	}
+	infraState = enrichInventory(ctx, dir, infraState, userQuery, mentioned)
+	prompt, err := promptContext(infraState)
 	if err != nil {
-		return fmt.Errorf("failed to create architecture model client: %w", err)
+		return "", nil, nil, err
 	}
+	return prompt, mentioned, state.FriendlyNames(infraState), nil
+}
 
-	router := llm.NewRouter(archClient, generalClient)
+// enrichInventory fetches the details a question needs from a shallow
+// inventory: the configuration of each shallow resource it names, or a live
+// scan of the services it mentions when it names none. What was fetched is
+// saved to the cache, so each resource and service is fetched once. Failures
+// are warned about and leave the inventory as it was.
+func enrichInventory(ctx context.Context, dir string, infraState map[string]interface{}, userQuery string, mentioned []string) map[string]interface{} {
+	shallow := state.ShallowResources(infraState, mentioned)
+	services := state.PendingServices(infraState, state.MentionedServices(userQuery))
+	if len(shallow) == 0 && (len(mentioned) > 0 || len(services) == 0) {
+		return infraState
+	}
 
-	// 4. Ask the router to answer the question using the provided context
-	fmt.Println("Asking AI to reason about your infrastructure (multi-model)...")
-	answer, err := router.Answer(ctx, userQuery, contextString)
+	awsClient, err := newAWSClient(ctx)
 	if err != nil {
-		return fmt.Errorf("AI failed to answer the question: %w", err)
+		fmt.Fprintf(os.Stderr, "⚠️  Could not fetch details: %v; answering from the inventory\n", err)
+		return infraState
 	}
 
-	// 5. Print the answer in a cleaner format
-	fmt.Println("\n🤖 AI Answer:")
-	fmt.Println("─" + strings.Repeat("─", 50))
-	fmt.Println(strings.TrimSpace(answer))
-	fmt.Println("─" + strings.Repeat("─", 50))
+	// details holds what was fetched in the shape of a live scan, so it can
+	// be added to the cache as saved now rather than as loaded
+	var details map[string]interface{}
+	if len(shallow) > 0 {
+		fmt.Fprintf(os.Stderr, "🔍 Fetching details of %s from the live account...\n", strings.Join(shallow, ", "))
+		// the cached copy may be shared with the warm context; enrich a copy
+		infraState = state.WithDetails(infraState, nil, nil)
+		enriched, err := state.Enrich(ctx, awsClient, infraState, shallow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+		if len(enriched) > 0 {
+			resources := infraState["Resources"].(map[string]interface{})
+			fetched := make(map[string]interface{}, len(enriched))
+			for _, logicalID := range enriched {
+				fetched[logicalID] = resources[logicalID]
+			}
+			details = map[string]interface{}{"Resources": fetched}
+		}
+		services = nil
+	} else {
+		fmt.Fprintf(os.Stderr, "🔍 Fetching %s details from the live account...\n", strings.Join(services, ", "))
+		live, err := (&state.LiveProvider{Client: awsClient}).ScanServices(ctx, services)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Live scan failed: %v; answering from the inventory\n", err)
+			return infraState
+		}
+		infraState = state.WithDetails(infraState, live, services)
+		details = live
+	}
 
-	return nil
+	if details != nil {
+		if err := saveDetails(dir, details, services); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not save the fetched details: %v\n", err)
+		}
+	}
+	return infraState
 }
 
-// findAvailableBedrockModel tests common models to find one that works
+// enrichMu serializes the cache writes of the answer path. Questions
+// answered at the same time, as by 'cloudai serve', each add their details
+// to the cache as it is then, so none drops another's.
+var enrichMu sync.Mutex
+
+// saveDetails adds fetched details to the project's cache, as
+// state.WithDetails adds them to a loaded state. A cache that fails its
+// integrity check is left alone, so saving does not sign it again.
+func saveDetails(dir string, details map[string]interface{}, services []string) error {
+	enrichMu.Lock()
+	defer enrichMu.Unlock()
+	cacheManager, err := newCacheManager(dir)
+	if err != nil {
+		return err
+	}
+	cached, err := cacheManager.Load()
+	if state.IsUntrusted(err) {
+		return fmt.Errorf("the cache is not updated: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	return cacheManager.Save(state.WithDetails(cached, details, services))
+}
+
+// loadHandlerPlugins registers the intent handler plugins in plugins.dir once
+// per process.
+var loadHandlerPlugins = sync.OnceFunc(func() {
+	if dir := getConfigString("plugins.dir"); dir != "" {
+		if err := processor.LoadPlugins(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not load plugins from %s: %v\n", dir, err)
+		}
+	}
+})
+
+// deterministicContext runs the processor handler that recognises the
+// question and returns its result serialized for the prompt. With
+// query.llm_intents set, the LLM also classifies questions that no keyword
+// matcher recognises, at the cost of an extra model call unless the parse is
+// already cached in .cloudai/parse-cache.json.
+func deterministicContext(ctx context.Context, dir, userQuery string, llmClient *llm.Client) (string, error) {
+	loadHandlerPlugins()
+
+	if viper.GetBool("query.llm_intents") {
+		// repeated questions reuse the parse instead of another model call
+		llmClient.SetParseCache(llm.NewParseCache(filepath.Join(dir, ".cloudai")))
+	} else {
+		llmClient = nil
+	}
+
+	// The AWS configuration is only loaded once a handler recognises the question
+	p := processor.NewProcessor(llmClient, nil, nil)
+	p.LoadAWSOnDemand(func() (*aws.Client, error) { return newAWSClient(ctx) })
+	p.QueryFlowLogsWith(flowLogsTable())
+	p.ReadCacheWith(func() (map[string]interface{}, error) { return loadCachedState(dir) })
+	query, data, err := p.Resolve(ctx, userQuery)
+	if err != nil {
+		return "", err
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"intent": query.Intent,
+		"params": query.Params,
+		"result": data,
+	})
+	if err != nil {
+		return "", internalError(fmt.Errorf("could not serialize %s result: %w", query.Intent, err))
+	}
+	return string(result), nil
+}
+
+// loadQueryContext loads the infrastructure cache in dir and serializes it
+// for the prompt. It also returns the resources the question mentions.
+func loadQueryContext(dir, userQuery string) (string, []string, error) {
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mentioned := state.MentionedResources(infraState, userQuery)
+	contextString, err := promptContext(infraState)
+	if err != nil {
+		return "", nil, err
+	}
+	return contextString, mentioned, nil
+}
+
+// promptContext serializes the infrastructure state for the LLM prompt,
+// collapsing policy/metadata noise and repeated resources first. Hard-coded
+// secrets found by the scan never leave the machine.
+func promptContext(infraState map[string]interface{}) (string, error) {
+	includes, err := contextIncludes()
+	if err != nil {
+		return "", err
+	}
+	infraState = state.ApplyIncludes(state.StripSecrets(infraState), includes)
+	if !rawContext {
+		infraState = state.Deduplicate(state.Summarize(infraState))
+	}
+	contextBytes, err := json.Marshal(infraState)
+	if err != nil {
+		return "", internalError(fmt.Errorf("could not serialize infrastructure state for LLM: %w", err))
+	}
+	return string(contextBytes), nil
+}
+
+// contextIncludes reads the context.include settings, which keep categories
+// of resource properties such as environment variables out of prompts.
+func contextIncludes() (state.ContextIncludes, error) {
+	includes := state.ContextIncludes{
+		EnvVars:     getConfigString("context.include.env_vars"),
+		IAMPolicies: getConfigString("context.include.iam_policies"),
+		UserData:    getConfigString("context.include.user_data"),
+		Tags:        getConfigString("context.include.tags"),
+	}
+	return includes, includes.Validate()
+}
+
+// loadCachedState verifies and loads the infrastructure cache in dir.
+func loadCachedState(dir string) (map[string]interface{}, error) {
+	return loadCache(dir, planMode)
+}
+
+// loadCache loads the infrastructure cache in dir. A cache that fails its
+// integrity check is refused when strict, and warned about otherwise.
+func loadCache(dir string, strict bool) (map[string]interface{}, error) {
+	cacheManager, err := newCacheManager(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !cacheManager.Exists() {
+		return nil, fmt.Errorf("%w in this directory. Please run `cloudai scan` first", state.ErrNoCache)
+	}
+
+	// An edited cache could smuggle instructions into the prompt; plan mode
+	// outputs scripts and commands that rewrite the cache would re-sign it,
+	// so they refuse untrusted content outright.
+	infraState, err := cacheManager.Load()
+	if state.IsUntrusted(err) {
+		if strict {
+			return nil, fmt.Errorf("refusing to use the cache: %w. Run `cloudai scan` to rebuild it", err)
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: %v. Run `cloudai scan` to rebuild it.\n", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("could not load infrastructure cache: %w", err)
+	}
+	return infraState, nil
+}
+
+// recordUsage appends a question to the local usage ledger read by 'cloudai stats'.
+func recordUsage(dir string, u llm.Usage, elapsed time.Duration, success bool, resources []string) {
+	ledger, err := usage.NewLedger()
+	if err == nil {
+		err = ledger.Append(usage.Entry{
+			Time:         time.Now(),
+			Project:      dir,
+			Cache:        projectCache(dir),
+			Backend:      u.Backend,
+			Model:        u.Model,
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			Cost:         u.Cost,
+			DurationMs:   elapsed.Milliseconds(),
+			Success:      success,
+			Resources:    resources,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record usage: %v\n", err)
+	}
+}
+
+// projectCache is the absolute cache path of the project in dir, which
+// spend is attributed to.
+func projectCache(dir string) string {
+	cache := state.NewCacheManager(dir).Path()
+	if abs, err := filepath.Abs(cache); err == nil {
+		cache = abs
+	}
+	return cache
+}
+
+// defaultProbeModels are tried, in order of preference, when
+// bedrock.probe_models is not configured
+var defaultProbeModels = []string{
+	"anthropic.claude-3-haiku-20240307-v1:0",
+	"anthropic.claude-3-sonnet-20240229-v1:0",
+	"amazon.titan-text-express-v1",
+	"meta.llama3.2-70b-instruct-v1:0",
+}
+
+// bedrockProbeTimeout bounds one round of model probes
+const bedrockProbeTimeout = 20 * time.Second
+
+// findAvailableBedrockModel checks the candidate models at once and returns
+// the most preferred one that works. The candidates are bedrock.probe_models:
+//
+//	bedrock:
+//	  probe_models: [anthropic.claude-3-haiku-20240307-v1:0, amazon.titan-text-express-v1]
+//	  poll_interval: 10s     # between rounds while waiting for access (default 5s)
 func findAvailableBedrockModel(ctx context.Context, cfg awssdk.Config) string {
+	bedrockClient := bedrock.NewFromConfig(cfg)
 	bedrockRuntimeClient := bedrockruntime.NewFromConfig(cfg)
 
-	// Test models in order of preference
-	testModels := []string{
-		"anthropic.claude-3-haiku-20240307-v1:0",
-		"anthropic.claude-3-sonnet-20240229-v1:0",
-		"amazon.titan-text-express-v1",
-		"meta.llama3.2-70b-instruct-v1:0",
+	testModels := viper.GetStringSlice("bedrock.probe_models")
+	if len(testModels) == 0 {
+		testModels = defaultProbeModels
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bedrockProbeTimeout)
+	defer cancel()
+	available := make([]bool, len(testModels))
+	var wg sync.WaitGroup
+	for i, modelID := range testModels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			available[i] = modelAccessible(ctx, bedrockClient, bedrockRuntimeClient, modelID)
+		}()
 	}
+	wg.Wait()
 
-	for _, modelID := range testModels {
-		if testModelQuietly(ctx, bedrockRuntimeClient, modelID) {
+	for i, modelID := range testModels {
+		if available[i] {
 			return modelID
 		}
 	}
-
 	return ""
 }
 
-// testModelQuietly tests a model without printing errors
-func testModelQuietly(ctx context.Context, client *bedrockruntime.Client, modelID string) bool {
-	testBody := `{"prompt": "Hi", "max_tokens": 1, "temperature": 0.1, "anthropic_version": "bedrock-2023-05-31"}`
+// bedrockPollInterval is bedrock.poll_interval, or 5 seconds
+func bedrockPollInterval() time.Duration {
+	if value := getConfigString("bedrock.poll_interval"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval > 0 {
+			return interval
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Ignoring bedrock.poll_interval %q: not a positive duration such as 10s\n", value)
+	}
+	return 5 * time.Second
+}
+
+// modelAccessible checks access to a model without paying for a request.
+// GetFoundationModelAvailability answers from the account's agreements and
+// policies. Where it cannot be called, e.g. without
+// bedrock:GetFoundationModelAvailability, the model is invoked with an empty
+// body: Bedrock checks access before it validates the body, so a validation
+// error means access and nothing is generated or billed.
+func modelAccessible(ctx context.Context, control *bedrock.Client, runtime *bedrockruntime.Client, modelID string) bool {
+	availability, err := control.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+		ModelId: awssdk.String(modelID),
+	})
+	if err == nil {
+		return availability.RegionAvailability == bedrocktypes.RegionAvailabilityAvailable &&
+			availability.AuthorizationStatus == bedrocktypes.AuthorizationStatusAuthorized &&
+			availability.EntitlementAvailability == bedrocktypes.EntitlementAvailabilityAvailable &&
+			availability.AgreementAvailability != nil &&
+			availability.AgreementAvailability.Status == bedrocktypes.AgreementStatusAvailable
+	}
 
-	_, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+	_, err = runtime.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
 		ModelId:     awssdk.String(modelID),
 		ContentType: awssdk.String("application/json"),
-		Body:        []byte(testBody),
+		Body:        []byte(`{}`),
 	})
-
-	return err == nil
+	return errors.As(err, new(*bedrockruntimetypes.ValidationException))
 }
 
 // waitForModelAccess continuously tests until a model becomes available
 func waitForModelAccess(ctx context.Context, cfg awssdk.Config) error {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(bedrockPollInterval())
 	defer ticker.Stop()
 
-	attempts := 0
-	maxAttempts := 60 // 5 minutes max
+	started := time.Now()
+	lastReport := started
+	const maxWait = 5 * time.Minute
 
 	for {
 		select {
 		case <-ticker.C:
-			attempts++
-
 			// Test for available models
 			availableModel := findAvailableBedrockModel(ctx, cfg)
 			if availableModel != "" {
@@ -942,14 +2027,15 @@ func waitForModelAccess(ctx context.Context, cfg awssdk.Config) error {
 			}
 
 			// Show progress
-			if attempts%6 == 0 { // Every 30 seconds
-				fmt.Printf("⏳ Still waiting... (%d minutes elapsed)\n", attempts/12)
+			if time.Since(lastReport) >= 30*time.Second {
+				lastReport = time.Now()
+				fmt.Printf("⏳ Still waiting... (%d minutes elapsed)\n", int(time.Since(started).Minutes()))
 				fmt.Println("   💡 Make sure you clicked 'Submit' in the AWS Console")
 			} else {
 				fmt.Print(".")
 			}
 
-			if attempts >= maxAttempts {
+			if time.Since(started) >= maxWait {
 				fmt.Println("\n⏰ Timeout reached. Model access may take longer than expected.")
 				fmt.Println("\n📋 Manual verification:")
 				fmt.Println("   1. Check the AWS Console for any pending requests")
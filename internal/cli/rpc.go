@@ -0,0 +1,415 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// JSON-RPC 2.0 error codes, and the LSP code for a cancelled request
+const (
+	rpcParseError       = -32700
+	rpcInvalidRequest   = -32600
+	rpcMethodNotFound   = -32601
+	rpcInvalidParams    = -32602
+	rpcInternalError    = -32603
+	rpcRequestCancelled = -32800
+)
+
+// maxHoverDependents is how many direct dependents a hover names
+const maxHoverDependents = 5
+
+var rpcCmd = &cobra.Command{
+	Use:   "rpc [path]",
+	Short: "Answer editor requests over JSON-RPC on stdin and stdout",
+	Long: `Speaks JSON-RPC 2.0 over stdio for editor extensions, so infrastructure
+answers can be shown next to CDK or Terraform code. Messages are framed with
+Content-Length headers as in the Language Server Protocol, or one JSON object
+per line; replies use the framing of the request.
+
+Methods:
+  initialize    {}                                   -> {"name", "version", "methods"}
+  query         {"query": "...", "tier"?: "fast"}    -> {"answer", "follow_ups", "usage"}
+  explain       {"resource": "OrdersTable"}          -> {"resource", "answer", "usage"}
+  hover         {"resource": "OrdersTable"}          -> {"resource", "type", "name", "dependents", "affected", "markdown"} or null
+  shutdown, exit, $/cancelRequest {"id": ...}
+
+Every method also takes "dir", the project whose cache answers it (default:
+the given path). hover reads only the cache and never calls a model, so it
+is fast enough to run as the cursor moves. Logs go to stderr.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRPC,
+}
+
+// rpcMessage is a JSON-RPC request, notification or response
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error; Data carries the CLI's error code, as in
+// the --json error envelope
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcParams are the parameters every method understands
+type rpcParams struct {
+	Dir      string          `json:"dir"`
+	Query    string          `json:"query"`
+	Tier     string          `json:"tier"`
+	Resource string          `json:"resource"`
+	ID       json.RawMessage `json:"id"`
+}
+
+// rpcHover is the quick information about a resource shown on hover
+type rpcHover struct {
+	Resource   string   `json:"resource"`
+	Type       string   `json:"type"`
+	Name       string   `json:"name,omitempty"`
+	DefinedIn  string   `json:"defined_in,omitempty"`
+	Dependents []string `json:"dependents"`
+	Affected   int      `json:"affected"`
+	Markdown   string   `json:"markdown"`
+}
+
+// rpcServer answers the requests of one editor session
+type rpcServer struct {
+	dir string
+
+	// engine is created on the first question, so hover works without a
+	// model configured
+	engineMu sync.Mutex
+	engine   *queryEngine
+
+	writeMu sync.Mutex
+	out     io.Writer
+
+	mu       sync.Mutex
+	inFlight map[string]context.CancelFunc
+	// states are the loaded caches hover reads, by cache path; they are
+	// kept apart from the engine's so a hover never waits for a model call
+	states map[string]*warmContext
+}
+
+func runRPC(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("error getting absolute path: %w", err)
+	}
+	// stdout carries the protocol; anything else printed goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	s := &rpcServer{dir: absPath, out: out, inFlight: map[string]context.CancelFunc{}, states: map[string]*warmContext{}}
+	fmt.Fprintf(os.Stderr, "🔌 CloudAI-CLI answering JSON-RPC on stdio for %s\n", absPath)
+	return s.serve(context.Background(), bufio.NewReader(os.Stdin))
+}
+
+// serve reads requests until exit or the end of input. Each request runs
+// in its own goroutine, so a hover is answered while a query is running.
+func (s *rpcServer) serve(ctx context.Context, in *bufio.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		body, framed, err := readRPCMessage(in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read request: %w", err)
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.reply(framed, &rpcMessage{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		switch msg.Method {
+		case "exit":
+			return nil
+		case "$/cancelRequest":
+			var params rpcParams
+			if json.Unmarshal(msg.Params, &params) == nil {
+				s.mu.Lock()
+				if cancelRequest, ok := s.inFlight[string(params.ID)]; ok {
+					cancelRequest()
+				}
+				s.mu.Unlock()
+			}
+			continue
+		}
+		if msg.Method == "" || msg.JSONRPC != "2.0" {
+			if msg.ID != nil {
+				s.reply(framed, &rpcMessage{ID: msg.ID, Error: &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}})
+			}
+			continue
+		}
+
+		reqCtx, cancelRequest := context.WithCancel(ctx)
+		key := string(msg.ID)
+		if msg.ID != nil {
+			s.mu.Lock()
+			s.inFlight[key] = cancelRequest
+			s.mu.Unlock()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancelRequest()
+			result, rpcErr := s.handle(reqCtx, msg.Method, msg.Params)
+			if msg.ID == nil {
+				// a notification gets no reply
+				return
+			}
+			s.mu.Lock()
+			delete(s.inFlight, key)
+			s.mu.Unlock()
+			if rpcErr == nil && reqCtx.Err() != nil {
+				rpcErr = &rpcError{Code: rpcRequestCancelled, Message: "request cancelled"}
+			}
+			reply := &rpcMessage{ID: msg.ID, Result: result, Error: rpcErr}
+			if rpcErr == nil && result == nil {
+				// a JSON-RPC response carries either a result or an error
+				reply.Result = json.RawMessage("null")
+			}
+			s.reply(framed, reply)
+		}()
+	}
+}
+
+// handle runs one method.
+func (s *rpcServer) handle(ctx context.Context, method string, raw json.RawMessage) (interface{}, *rpcError) {
+	var params rpcParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	dir := s.dir
+	if params.Dir != "" {
+		dir = params.Dir
+	}
+
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"name":    "cloudai",
+			"version": readBuildInfo().Version,
+			"methods": []string{"query", "explain", "hover", "shutdown", "exit", "$/cancelRequest"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+
+	case "query":
+		if strings.TrimSpace(params.Query) == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "query is required"}
+		}
+		engine, err := s.queryEngine()
+		if err != nil {
+			return nil, rpcFailure(err)
+		}
+		result, err := engine.answer(ctx, dir, params.Query, params.Tier, true)
+		if err != nil {
+			return nil, rpcFailure(err)
+		}
+		return map[string]interface{}{"answer": result.Answer, "follow_ups": result.FollowUps, "usage": result.Usage}, nil
+
+	case "explain":
+		infraState, err := s.cachedState(dir)
+		if err != nil {
+			return nil, rpcFailure(err)
+		}
+		if params.Resource == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "resource is required"}
+		}
+		id, err := state.ResolveResource(infraState, params.Resource)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		impact := state.Impact(infraState, id)
+		question := fmt.Sprintf("Explain the resource %s (%s): what it is for, how it is configured and what depends on it.", id, impact.Type)
+		engine, err := s.queryEngine()
+		if err != nil {
+			return nil, rpcFailure(err)
+		}
+		result, err := engine.answer(ctx, dir, question, params.Tier, false)
+		if err != nil {
+			return nil, rpcFailure(err)
+		}
+		return map[string]interface{}{"resource": id, "answer": result.Answer, "usage": result.Usage}, nil
+
+	case "hover":
+		infraState, err := s.cachedState(dir)
+		if err != nil {
+			return nil, rpcFailure(err)
+		}
+		if params.Resource == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "resource is required"}
+		}
+		id, err := state.ResolveResource(infraState, params.Resource)
+		if err != nil {
+			// nothing to show is not an error to an editor
+			return nil, nil
+		}
+		return hoverInfo(state.Impact(infraState, id)), nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+// hoverInfo describes a resource and what depends on it.
+func hoverInfo(impact *state.ImpactNode) *rpcHover {
+	hover := &rpcHover{Resource: impact.ID, Type: impact.Type, Name: impact.Name, DefinedIn: impact.DefinedIn, Affected: impact.Count(), Dependents: []string{}}
+	for _, dependent := range impact.Dependents {
+		hover.Dependents = append(hover.Dependents, dependent.ID)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** `%s`", impact.ID, impact.Type)
+	if impact.Name != "" && impact.Name != impact.ID {
+		fmt.Fprintf(&b, "\n\nName: `%s`", impact.Name)
+	}
+	if impact.DefinedIn != "" {
+		fmt.Fprintf(&b, "\n\nDefined in `%s`", impact.DefinedIn)
+	}
+	switch {
+	case hover.Affected == 0:
+		b.WriteString("\n\nNothing in the cache depends on it.")
+	default:
+		shown := hover.Dependents
+		more := ""
+		if len(shown) > maxHoverDependents {
+			more = fmt.Sprintf(" and %d more", len(shown)-maxHoverDependents)
+			shown = shown[:maxHoverDependents]
+		}
+		fmt.Fprintf(&b, "\n\n%d resource(s) depend on it, directly: %s%s", hover.Affected, strings.Join(shown, ", "), more)
+	}
+	hover.Markdown = b.String()
+	return hover
+}
+
+// queryEngine returns the session's engine, creating it on first use.
+func (s *rpcServer) queryEngine() (*queryEngine, error) {
+	s.engineMu.Lock()
+	defer s.engineMu.Unlock()
+	if s.engine == nil {
+		engine, err := newQueryEngine(true, "")
+		if err != nil {
+			return nil, err
+		}
+		s.engine = engine
+	}
+	return s.engine, nil
+}
+
+// cachedState returns the project's cache, reusing the loaded copy while the
+// cache file is unchanged.
+func (s *rpcServer) cachedState(dir string) (map[string]interface{}, error) {
+	cachePath := state.NewCacheManager(dir).Path()
+	info, statErr := os.Stat(cachePath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.states[cachePath]; ok && statErr == nil && w.modTime.Equal(info.ModTime()) {
+		return w.state, nil
+	}
+	infraState, err := loadCachedState(dir)
+	if err != nil {
+		return nil, err
+	}
+	if statErr == nil {
+		s.states[cachePath] = &warmContext{path: cachePath, modTime: info.ModTime(), state: infraState}
+	}
+	return infraState, nil
+}
+
+// rpcFailure wraps a command error with the CLI's error code.
+func rpcFailure(err error) *rpcError {
+	if errors.Is(err, context.Canceled) {
+		return &rpcError{Code: rpcRequestCancelled, Message: "request cancelled"}
+	}
+	return &rpcError{Code: rpcInternalError, Message: err.Error(), Data: map[string]string{"code": classify(err).code}}
+}
+
+// reply writes a response in the framing of its request.
+func (s *rpcServer) reply(framed bool, msg *rpcMessage) {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		body, _ = json.Marshal(&rpcMessage{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{Code: rpcInternalError, Message: err.Error()}})
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if framed {
+		fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	} else {
+		fmt.Fprintf(s.out, "%s\n", body)
+	}
+}
+
+// readRPCMessage reads one message framed with LSP headers, or a line of
+// JSON, and reports which it was. Blank lines between messages are skipped.
+func readRPCMessage(in *bufio.Reader) ([]byte, bool, error) {
+	for {
+		peek, err := in.Peek(1)
+		if err != nil {
+			return nil, false, err
+		}
+		switch peek[0] {
+		case '\r', '\n', ' ', '\t':
+			in.ReadByte()
+			continue
+		case '{', '[':
+			line, err := in.ReadBytes('\n')
+			if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+				return nil, false, err
+			}
+			return line, false, nil
+		}
+
+		headers, err := textproto.NewReader(in).ReadMIMEHeader()
+		if err != nil {
+			return nil, true, err
+		}
+		length, err := strconv.Atoi(headers.Get("Content-Length"))
+		if err != nil || length < 0 {
+			return nil, true, fmt.Errorf("missing or invalid Content-Length header")
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(in, body); err != nil {
+			return nil, true, err
+		}
+		return body, true, nil
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(rpcCmd)
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// defaultArchEndpoint is the endpoint 'cloudai sagemaker deploy' creates
+// unless arch.endpoint names another.
+const defaultArchEndpoint = "cloudai-arch"
+
+// endpointWaitTimeout bounds the wait for a deployment; TGI loading an 8B
+// model takes about ten minutes
+const endpointWaitTimeout = 30 * time.Minute
+
+var sagemakerCmd = &cobra.Command{
+	Use:   "sagemaker",
+	Short: "Deploy and roll back registered versions of the architecture model",
+}
+
+var sagemakerVersionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "List the registered versions of the architecture model",
+	Long: `Lists the versions in the model registry group (training.model_package_group,
+default cloudai-arch-models) with their approval status, training job, dataset
+hash and eval scores, and marks the one the endpoint serves.
+
+Requires sagemaker:ListModelPackages, sagemaker:DescribeModelPackage,
+sagemaker:DescribeEndpoint, sagemaker:DescribeEndpointConfig and
+sagemaker:DescribeModel.`,
+	Args: cobra.NoArgs,
+	RunE: runSageMakerVersions,
+}
+
+var sagemakerDeployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Deploy a registered version of the architecture model to an endpoint",
+	Long: `Serves a version from the model registry on a SageMaker real-time endpoint
+with the TGI container, creating the endpoint or switching it over without
+downtime, and routes architecture questions to it: the endpoint is written to
+the arch section of the config, which enables the router's architecture
+model in place of the CLOUDAI_ARCH_* variables. Other questions keep going to
+the configured model.
+
+  cloudai sagemaker deploy               # the newest version that is not rejected
+  cloudai sagemaker deploy --version 3   # roll back to version 3
+
+The endpoint is arch.endpoint, or cloudai-arch; the model runs with the
+training.role_arn of 'cloudai train bootstrap-role'. A version pending
+approval is approved by deploying it. This changes your account, so the
+read-only guard is lifted for it, unless --read-only is passed explicitly,
+and you are asked to confirm unless --yes is given. Requires sagemaker:CreateModel, sagemaker:CreateEndpointConfig,
+sagemaker:CreateEndpoint, sagemaker:UpdateEndpoint,
+sagemaker:UpdateModelPackage and iam:PassRole on the role.`,
+	Args: cobra.NoArgs,
+	RunE: runSageMakerDeploy,
+}
+
+func runSageMakerVersions(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	group, endpoint := modelPackageGroup(), archEndpoint()
+	versions, err := client.ListModelVersions(ctx, group, endpoint)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "sagemaker versions",
+			Data:    versions,
+			Success: true,
+		})
+	}
+	if len(versions) == 0 {
+		fmt.Printf("No versions registered in %s yet: register one with 'cloudai train register <job>'\n", group)
+		return nil
+	}
+	table := &output.Table{
+		Title:   fmt.Sprintf("%s (endpoint %s)", group, endpoint),
+		Headers: []string{"Version", "Status", "Registered", "Training job", "Dataset", "Eval scores"},
+	}
+	for _, version := range versions {
+		number := fmt.Sprint(version.Version)
+		if version.Deployed {
+			number += " 🚀"
+		}
+		dataset := version.DatasetHash
+		if len(dataset) > 12 {
+			dataset = dataset[:12]
+		}
+		table.Rows = append(table.Rows, []string{
+			number, version.Approval, version.Created.Format("2006-01-02 15:04"),
+			version.TrainingJob, dataset, formatEvalScores(version.EvalScores),
+		})
+	}
+	table.Print()
+	return nil
+}
+
+func runSageMakerDeploy(cmd *cobra.Command, args []string) error {
+	if err := refuseReadOnly(cmd, "creates or updates an endpoint"); err != nil {
+		return err
+	}
+	roleARN := getConfigString("training.role_arn")
+	if roleARN == "" {
+		return fmt.Errorf("training.role_arn is not set: run 'cloudai train bootstrap-role' first")
+	}
+	ctx := context.Background()
+	client, err := aws.NewClient(ctx, append(awsClientOptions(), aws.WithWrites())...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	group, endpoint := modelPackageGroup(), archEndpoint()
+	versions, err := client.ListModelVersions(ctx, group, endpoint)
+	if err != nil {
+		return err
+	}
+	version, err := pickModelVersion(versions, deployVersion)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, group)
+	}
+
+	fmt.Printf("🚀 Deploying version %d of %s to endpoint %s\n", version.Version, group, endpoint)
+	if version.TrainingJob != "" {
+		fmt.Printf("   • trained by %s", version.TrainingJob)
+		if version.BaseModel != "" {
+			fmt.Printf(" from %s", version.BaseModel)
+		}
+		fmt.Println()
+	}
+	if scores := formatEvalScores(version.EvalScores); scores != "" {
+		fmt.Printf("   • eval scores: %s\n", scores)
+	}
+	for _, other := range versions {
+		if other.Deployed {
+			fmt.Printf("   • replaces version %d, which keeps serving until the new one is in service\n", other.Version)
+		}
+	}
+	if version.Deployed {
+		fmt.Println("   • the endpoint already serves this version; it is redeployed")
+	}
+	if !setupYes && !confirm("Continue? (y/N): ") {
+		return fmt.Errorf("cancelled, nothing was deployed")
+	}
+
+	deployment, err := client.DeployModelVersion(ctx, version, endpoint, roleARN, deployInstance)
+	if err != nil {
+		return err
+	}
+	verb := "Updating"
+	if deployment.Created {
+		verb = "Creating"
+	}
+	fmt.Printf("\n⏳ %s endpoint %s on %s\n", verb, endpoint, deployment.InstanceType)
+	if !deployNoWait {
+		if err := client.WaitForEndpoint(ctx, endpoint, endpointWaitTimeout); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Endpoint %s serves version %d\n", endpoint, version.Version)
+	}
+
+	if err := saveConfig(map[string]interface{}{
+		"arch.endpoint": endpoint,
+		"arch.region":   deployment.Region,
+		"arch.model_id": fmt.Sprintf("%s/%d", group, version.Version),
+		"sagemaker.endpoints." + endpoint + ".format": "tgi",
+	}); err != nil {
+		return err
+	}
+	if os.Getenv("CLOUDAI_ARCH_ENDPOINT") != "" {
+		fmt.Println("⚠️  CLOUDAI_ARCH_ENDPOINT is set and overrides arch.endpoint; unset it to use this deployment")
+	}
+	fmt.Println("🧭 Architecture questions are now routed to the endpoint")
+	return nil
+}
+
+// pickModelVersion returns the requested version, or with 0 the newest
+// that is not rejected. Versions are newest first.
+func pickModelVersion(versions []aws.ModelVersion, number int) (*aws.ModelVersion, error) {
+	for i, version := range versions {
+		switch {
+		case number > 0 && int(version.Version) == number:
+			return &versions[i], nil
+		case number == 0 && version.Approval != "Rejected":
+			return &versions[i], nil
+		}
+	}
+	if number > 0 {
+		return nil, fmt.Errorf("no version %d", number)
+	}
+	return nil, fmt.Errorf("no deployable version: register one with 'cloudai train register <job>'")
+}
+
+// formatEvalScores renders scores as name=value pairs in name order.
+func formatEvalScores(scores map[string]float64) string {
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%.4g", name, scores[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+func modelPackageGroup() string {
+	if group := getConfigString("training.model_package_group"); group != "" {
+		return group
+	}
+	return aws.DefaultModelPackageGroup
+}
+
+func archEndpoint() string {
+	if deployEndpoint != "" {
+		return deployEndpoint
+	}
+	if endpoint := llm.ArchEndpoint(); endpoint != "" {
+		return endpoint
+	}
+	return defaultArchEndpoint
+}
+
+func init() {
+	sagemakerCmd.PersistentFlags().StringVar(&deployEndpoint, "endpoint", "", "SageMaker endpoint (default: arch.endpoint, or cloudai-arch)")
+	sagemakerDeployCmd.Flags().IntVar(&deployVersion, "version", 0, "registered version to deploy (default: the newest that is not rejected)")
+	sagemakerDeployCmd.Flags().StringVar(&deployInstance, "instance-type", "", "instance type (default: the version's, or "+aws.DefaultInferenceInstanceType+")")
+	sagemakerDeployCmd.Flags().BoolVar(&deployNoWait, "no-wait", false, "return once the deployment has started instead of when it is in service")
+	sagemakerDeployCmd.Flags().BoolVarP(&setupYes, "yes", "y", false, "deploy and write the config without asking")
+	sagemakerCmd.AddCommand(sagemakerVersionsCmd, sagemakerDeployCmd)
+	rootCmd.AddCommand(sagemakerCmd)
+}
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// savedQuery is a question kept under a name. {{param}} placeholders in the
+// question are filled in when it runs, from --param or the defaults.
+type savedQuery struct {
+	Question    string            `yaml:"question"`
+	Description string            `yaml:"description,omitempty"`
+	Params      map[string]string `yaml:"params,omitempty"`
+}
+
+// queryParam matches a {{param}} placeholder.
+var queryParam = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+var savedCmd = &cobra.Command{
+	Use:   "saved",
+	Short: "Save recurring questions under a name and run them",
+	Long: `Keeps recurring questions in .cloudai/queries.yaml in the project, so a team can
+commit them and ask them the same way everywhere, including CI. Questions may contain
+{{param}} placeholders, filled in from --param when the question runs or from the
+defaults given when it was saved.
+
+Examples:
+  cloudai saved add untagged "List resources missing a {{tag}} tag" --param tag=cost-center
+  cloudai saved run untagged
+  cloudai saved run untagged --param tag=owner`,
+}
+
+var savedAddCmd = &cobra.Command{
+	Use:   "add <name> <question>",
+	Short: "Save a question under a name",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, question := args[0], args[1]
+		params, err := parseQueryParams(savedParams)
+		if err != nil {
+			return err
+		}
+		placeholders := make(map[string]bool)
+		for _, match := range queryParam.FindAllStringSubmatch(question, -1) {
+			placeholders[match[1]] = true
+		}
+		for _, param := range sortedKeys(params) {
+			if !placeholders[param] {
+				return fmt.Errorf("--param %s: the question has no {{%s}} placeholder", param, param)
+			}
+		}
+
+		dir, queries, err := loadSavedQueries()
+		if err != nil {
+			return err
+		}
+		_, replaced := queries[name]
+		queries[name] = savedQuery{Question: question, Description: savedDescription, Params: params}
+		if err := saveSavedQueries(dir, queries); err != nil {
+			return err
+		}
+		verb := "Saved"
+		if replaced {
+			verb = "Updated"
+		}
+		fmt.Printf("✅ %s %q; run it with: cloudai saved run %s\n", verb, name, name)
+		return nil
+	},
+}
+
+var savedListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved questions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, queries, err := loadSavedQueries()
+		if err != nil {
+			return err
+		}
+		if len(queries) == 0 {
+			fmt.Println(`No saved questions. Add one with: cloudai saved add <name> "<question>"`)
+			return nil
+		}
+		table := &output.Table{Title: "Saved questions", Headers: []string{"Name", "Question", "Parameters"}}
+		for _, name := range sortedKeys(queries) {
+			query := queries[name]
+			question := query.Question
+			if query.Description != "" {
+				question = query.Description + ": " + question
+			}
+			table.Rows = append(table.Rows, []string{name, question, describeParams(query)})
+		}
+		table.Print()
+		return nil
+	},
+}
+
+var savedRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Ask a saved question",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, queries, err := loadSavedQueries()
+		if err != nil {
+			return err
+		}
+		query, ok := queries[args[0]]
+		if !ok {
+			return fmt.Errorf("no saved question named %q; see 'cloudai saved list'", args[0])
+		}
+		params, err := parseQueryParams(savedParams)
+		if err != nil {
+			return err
+		}
+		question, err := fillQuery(query, params)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("▶️  %s: %s\n", args[0], question)
+		return runQuery(cmd, []string{question})
+	},
+}
+
+var savedRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a saved question",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, queries, err := loadSavedQueries()
+		if err != nil {
+			return err
+		}
+		if _, ok := queries[args[0]]; !ok {
+			return fmt.Errorf("no saved question named %q", args[0])
+		}
+		delete(queries, args[0])
+		if err := saveSavedQueries(dir, queries); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Removed %q\n", args[0])
+		return nil
+	},
+}
+
+// loadSavedQueries reads the saved questions of the project in the current
+// directory.
+func loadSavedQueries() (string, map[string]savedQuery, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", nil, fmt.Errorf("could not get current working directory: %w", err)
+	}
+	queries := make(map[string]savedQuery)
+	data, err := os.ReadFile(savedQueriesFile(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return dir, queries, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if err := yaml.Unmarshal(data, &queries); err != nil {
+		return "", nil, fmt.Errorf("failed to parse %s: %w", savedQueriesFile(dir), err)
+	}
+	if queries == nil {
+		queries = make(map[string]savedQuery)
+	}
+	return dir, queries, nil
+}
+
+func saveSavedQueries(dir string, queries map[string]savedQuery) error {
+	path := savedQueriesFile(dir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(queries)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func savedQueriesFile(dir string) string {
+	return filepath.Join(dir, ".cloudai", "queries.yaml")
+}
+
+// parseQueryParams reads name=value pairs.
+func parseQueryParams(pairs []string) (map[string]string, error) {
+	params := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --param %q: use name=value", pair)
+		}
+		params[strings.TrimSpace(name)] = value
+	}
+	return params, nil
+}
+
+// fillQuery replaces the placeholders of a saved question with the given
+// parameters, falling back to the saved defaults.
+func fillQuery(query savedQuery, params map[string]string) (string, error) {
+	var missing []string
+	question := queryParam.ReplaceAllStringFunc(query.Question, func(placeholder string) string {
+		name := queryParam.FindStringSubmatch(placeholder)[1]
+		if value, ok := params[name]; ok {
+			return value
+		}
+		if value, ok := query.Params[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return placeholder
+	})
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("missing parameters: %s; pass them with --param name=value", strings.Join(missing, ", "))
+	}
+	return question, nil
+}
+
+// describeParams lists a saved question's placeholders with their defaults.
+func describeParams(query savedQuery) string {
+	var params []string
+	seen := make(map[string]bool)
+	for _, match := range queryParam.FindAllStringSubmatch(query.Question, -1) {
+		name := match[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if value, ok := query.Params[name]; ok {
+			name += "=" + value
+		}
+		params = append(params, name)
+	}
+	if len(params) == 0 {
+		return "-"
+	}
+	return strings.Join(params, ", ")
+}
+
+func init() {
+	savedAddCmd.Flags().StringArrayVarP(&savedParams, "param", "p", nil, "default for a {{name}} placeholder, as name=value (repeatable)")
+	savedAddCmd.Flags().StringVar(&savedDescription, "description", "", "what the question is for")
+	savedRunCmd.Flags().StringArrayVarP(&savedParams, "param", "p", nil, "value for a {{name}} placeholder, as name=value (repeatable)")
+	savedRunCmd.Flags().StringVar(&queryTier, "tier", "", "model tier to answer with: fast or smart")
+	savedRunCmd.Flags().BoolVar(&noSuggestions, "no-suggestions", false, "do not suggest follow-up questions after the answer")
+	savedCmd.AddCommand(savedAddCmd, savedListCmd, savedRunCmd, savedRemoveCmd)
+	rootCmd.AddCommand(savedCmd)
+}
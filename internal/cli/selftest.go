@@ -0,0 +1,333 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/processor"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// selftestFiles holds the synthesized demo-cdk stack and the expected
+// output of every check.
+//
+//go:embed selftest
+var selftestFiles embed.FS
+
+// selftestQuestions are asked of the scanned demo stack. The route question
+// is answered by the API Gateway handler, the inventory question from the
+// cache.
+var selftestQuestions = []struct {
+	name, question string
+}{
+	{"route", "Which Lambda handles GET /hello on cloudai-demo-api?"},
+	{"inventory", "Which functions and REST APIs are defined?"},
+}
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check the installation against the bundled demo stack",
+	Long: `Runs the demo-cdk stack bundled with the binary through the same pipeline as a
+question: scan, cache, query parsing, the deterministic handlers, the formatter and
+the answer. The mock model stands in for the LLM and a local stand-in for the API
+Gateway API answers the handlers, so nothing leaves the machine and no credentials
+or models are needed. Every output is compared with the expected one.
+
+After an intended change of output, contributors regenerate the expected outputs
+with:
+
+  cloudai selftest --update-golden internal/cli/selftest/golden`,
+	Args: cobra.NoArgs,
+	RunE: runSelftest,
+}
+
+// selftestCheck is the outcome of one step of the self-test
+type selftestCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Problem string `json:"problem,omitempty"`
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	// The self-test must give the same answers everywhere: use the mock
+	// model without canned answers or clean-up, and keep its requests out of
+	// the audit log. Nothing is sent anywhere, so no policy applies.
+	viper.Set("model.type", "mock")
+	viper.Set("mock.answers", map[string]string{})
+	viper.Set("answer.clean", false)
+	viper.Set("audit_log.enabled", false)
+	viper.Set("privacy.pii.mode", llm.PIIOff)
+	llm.SetPolicy(nil)
+
+	dir, err := os.MkdirTemp("", "cloudai-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create a directory for the demo stack: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := extractSelftestStack(dir); err != nil {
+		return fmt.Errorf("failed to extract the demo stack: %w", err)
+	}
+
+	api := httptest.NewServer(demoAPIGateway())
+	defer api.Close()
+	awsClient, err := aws.NewClient(ctx, aws.WithEndpoint(api.URL), aws.WithRegion("us-east-1"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	client, err := llm.NewClient()
+	if err != nil {
+		return fmt.Errorf("failed to initialize the mock model: %w", err)
+	}
+	router := llm.NewRouter(nil, client)
+
+	var checks []selftestCheck
+	outputs := make(map[string]string)
+	record := func(name string, out string, err error) {
+		if err == nil {
+			outputs[name] = out
+		}
+		checks = append(checks, compareGolden(name, out, err))
+	}
+
+	// 1. Scan the stack and keep it in a signed cache
+	infraState, err := (&state.IaCProvider{}).Scan(ctx, dir)
+	record("scan", describeResources(infraState), err)
+	if err != nil {
+		return reportSelftest(checks, outputs)
+	}
+	checks = append(checks, checkSelftestCache(dir, infraState))
+	prompt, err := promptContext(infraState)
+	if err != nil {
+		return err
+	}
+
+	// 2. Answer each question the way answerQuestion does
+	for _, q := range selftestQuestions {
+		query, data, err := processor.NewProcessor(nil, awsClient, nil).Resolve(ctx, q.question)
+		answer, context := "", prompt
+		switch {
+		case err == nil:
+			var lookup bytes.Buffer
+			err = output.NewFormatterTo(&lookup, true).FormatResult(&output.Result{Query: q.question, Data: data, Success: true})
+			record(q.name+".lookup", lookup.String(), err)
+
+			var result []byte
+			result, err = json.Marshal(map[string]interface{}{"intent": query.Intent, "params": query.Params, "result": data})
+			if err == nil {
+				context = string(result)
+				answer, err = router.Phrase(ctx, q.question, context)
+			}
+		case errors.Is(err, processor.ErrNotHandled):
+			answer, err = router.Answer(ctx, q.question, context)
+		}
+		if err == nil {
+			var followUps []string
+			followUps, err = router.FollowUps(ctx, q.question, answer, context)
+			answer = strings.TrimSpace(answer) + "\n\nFollow-ups:\n" + strings.Join(followUps, "\n")
+		}
+		record(q.name+".answer", answer, err)
+	}
+	return reportSelftest(checks, outputs)
+}
+
+// reportSelftest prints the checks, or writes the outputs as the new
+// expected ones with --update-golden.
+func reportSelftest(checks []selftestCheck, outputs map[string]string) error {
+	if selftestGolden != "" {
+		if err := os.MkdirAll(selftestGolden, 0755); err != nil {
+			return err
+		}
+		for _, name := range sortedKeys(outputs) {
+			if err := os.WriteFile(filepath.Join(selftestGolden, name+".golden"), []byte(normalizeGolden(outputs[name])), 0644); err != nil {
+				return fmt.Errorf("failed to write golden file: %w", err)
+			}
+		}
+		fmt.Printf("📝 Wrote %d golden files to %s\n", len(outputs), selftestGolden)
+		return nil
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if !check.OK {
+			failed++
+		}
+	}
+	if jsonOutput {
+		if err := output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "selftest",
+			Data:    checks,
+			Success: failed == 0,
+		}); err != nil {
+			return err
+		}
+	} else {
+		for _, check := range checks {
+			if check.OK {
+				fmt.Printf("✅ %s\n", check.Name)
+			} else {
+				fmt.Printf("❌ %s: %s\n", check.Name, check.Problem)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("self-test failed: %d of %d checks", failed, len(checks))
+	}
+	if !jsonOutput {
+		fmt.Printf("\n🎉 Self-test passed: %d checks\n", len(checks))
+	}
+	return nil
+}
+
+// compareGolden checks out against the embedded golden file of name.
+func compareGolden(name, out string, err error) selftestCheck {
+	check := selftestCheck{Name: name}
+	if err != nil {
+		check.Problem = err.Error()
+		return check
+	}
+	want, err := selftestFiles.ReadFile(path.Join("selftest", "golden", name+".golden"))
+	if err != nil {
+		check.Problem = "no golden file"
+		return check
+	}
+
+	gotLines := strings.Split(normalizeGolden(out), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var got, expected string
+		if i < len(gotLines) {
+			got = gotLines[i]
+		}
+		if i < len(wantLines) {
+			expected = wantLines[i]
+		}
+		if got != expected {
+			check.Problem = fmt.Sprintf("line %d is %q, expected %q", i+1, got, expected)
+			return check
+		}
+	}
+	check.OK = true
+	return check
+}
+
+// normalizeGolden drops trailing whitespace, which editors do not keep.
+func normalizeGolden(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// checkSelftestCache saves the scanned state to the project's cache and
+// checks that it verifies and loads unchanged.
+func checkSelftestCache(dir string, infraState map[string]interface{}) selftestCheck {
+	check := selftestCheck{Name: "cache"}
+	cacheManager := state.NewCacheManager(dir)
+	err := cacheManager.Save(infraState)
+	var loaded map[string]interface{}
+	if err == nil {
+		loaded, err = cacheManager.Load()
+	}
+	if err != nil {
+		check.Problem = err.Error()
+		return check
+	}
+	// The cache is saved with the friendly name table added
+	if !reflect.DeepEqual(state.FriendlyNames(loaded), state.FriendlyNames(infraState)) {
+		check.Problem = "the loaded friendly names differ from the scanned state's"
+		return check
+	}
+	delete(loaded, state.FriendlyNamesKey)
+	if !reflect.DeepEqual(loaded, infraState) {
+		check.Problem = "the loaded cache differs from the scanned state"
+		return check
+	}
+	check.OK = true
+	return check
+}
+
+// describeResources lists the logical IDs and types of the scanned resources.
+func describeResources(infraState map[string]interface{}) string {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	lines := make([]string, 0, len(resources))
+	for id, resource := range resources {
+		resourceType := ""
+		if r, ok := resource.(map[string]interface{}); ok {
+			resourceType, _ = r["Type"].(string)
+		}
+		lines = append(lines, id+" "+resourceType)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// extractSelftestStack writes the synthesized demo stack into dir.
+func extractSelftestStack(dir string) error {
+	root := path.Join("selftest", "demo-cdk")
+	return fs.WalkDir(selftestFiles, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, root)))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := selftestFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// demoAPIGateway answers the API Gateway calls of the api_gateway_lambda
+// handler as the deployed demo stack would.
+func demoAPIGateway() http.Handler {
+	responses := map[string]string{
+		"/restapis": `{"item": [{"id": "a1b2c3d4e5", "name": "cloudai-demo-api"}]}`,
+		"/restapis/a1b2c3d4e5/resources": `{"item": [
+			{"id": "r00tid", "path": "/"},
+			{"id": "h3ll0x", "parentId": "r00tid", "pathPart": "hello", "path": "/hello", "resourceMethods": {"GET": {}}}
+		]}`,
+		"/restapis/a1b2c3d4e5/resources/h3ll0x/methods/GET": `{"httpMethod": "GET", "authorizationType": "NONE", "methodIntegration": {
+			"type": "AWS_PROXY", "httpMethod": "POST",
+			"uri": "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:cloudai-demo-hello/invocations"
+		}}`,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.Header().Set("X-Amzn-Errortype", "NotFoundException")
+			w.WriteHeader(http.StatusNotFound)
+			body = `{"message": "Invalid resource identifier specified"}`
+		}
+		fmt.Fprint(w, body)
+	})
+}
+
+func init() {
+	selftestCmd.Flags().StringVar(&selftestGolden, "update-golden", "", "write the outputs to this directory as the new expected outputs")
+	rootCmd.AddCommand(selftestCmd)
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/server"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [path]",
+	Short: "Serve questions about a project over HTTP",
+	Long: `Runs CloudAI-CLI as an HTTP service for a shared deployment. Questions are answered
+from the infrastructure cache in the given directory (default: current directory).
+
+Endpoints:
+  POST /query    {"query": "..."} – answer a question
+  POST /scan     rescan the project (or the live account with --live) and refresh the cache
+  GET  /metrics  Prometheus metrics: query count, latency, tokens, cost, backend errors
+  GET  /healthz  liveness check
+
+Set OTEL_EXPORTER_OTLP_ENDPOINT to export OpenTelemetry traces of scans and model calls.
+
+Callers are limited per API key (or per IP when no keys are configured), and all of them
+share the team budget in cost.daily_limit. Configure in ~/.cloudai.yaml:
+
+  serve:
+    api_keys:               # caller name -> key, sent as "Authorization: Bearer <key>"
+      alice: <random key>
+    rate_limit: 10          # requests per minute per caller
+    daily_queries: 200      # requests per caller per day
+    caller_daily_budget: 1  # USD per caller per day
+
+When the cache is a live scan, refresh.every and refresh.services rescan the account in
+the background on a schedule (see 'cloudai daemon --help'), so answers stay current
+without calls to /scan. With report.schedule and report.deliver configured, the server
+also sends the report of 'cloudai report' on that schedule.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runServe,
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	absPath, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("error getting absolute path: %w", err)
+	}
+
+	selectEnvironment(absPath)
+	if err := selectPolicy(absPath); err != nil {
+		return err
+	}
+
+	addr := serveAddr
+	if !cmd.Flags().Changed("addr") {
+		if configured := getConfigString("serve.addr"); configured != "" {
+			addr = configured
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := server.SetupTracing(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not flush traces: %v\n", err)
+		}
+	}()
+
+	schedule, err := refreshSchedule()
+	if err != nil {
+		return err
+	}
+	reportEvery, reportOpts, err := scheduledReport()
+	if err != nil {
+		return err
+	}
+	// cacheMu keeps POST /scan and the background refresh from writing the
+	// cache at the same time, and questions, which read the cache and save
+	// the details they fetch to it, from running while they do
+	var cacheMu sync.RWMutex
+
+	answer := func(ctx context.Context, question string) (string, llm.Usage, error) {
+		cacheMu.RLock()
+		defer cacheMu.RUnlock()
+		result, err := answerQuestion(ctx, absPath, question, "", false)
+		return result.Answer, result.Usage, err
+	}
+	scan := func(ctx context.Context) (int, error) {
+		cacheMu.Lock()
+		defer cacheMu.Unlock()
+		return scanToCache(ctx, absPath, serveLive)
+	}
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server.New(answer, scan, serverQuotas()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	fmt.Printf("🚀 Serving %s on http://%s (metrics at /metrics)\n", absPath, addr)
+	if len(schedule) > 0 {
+		go runRefresher(ctx, schedule, func() []string { return []string{absPath} }, &cacheMu)
+		fmt.Printf("🔄 Refreshing the live scan in the background: %s\n", describeSchedule(schedule))
+	}
+	if reportEvery > 0 {
+		go runReporter(ctx, reportEvery, absPath, reportOpts)
+		fmt.Printf("📬 Reporting every %s to %s\n", reportEvery, strings.Join(reportOpts.Deliver, ", "))
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	fmt.Println("\nShutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
+
+// serverQuotas builds per-caller limits from the serve.* config keys. The
+// team budget is cost.daily_limit, shared with the model clients.
+func serverQuotas() *server.Quotas {
+	limits := server.Limits{
+		RequestsPerMinute: viper.GetInt("serve.rate_limit"),
+		DailyQueries:      viper.GetInt("serve.daily_queries"),
+		CallerDailyBudget: getConfigFloat("serve.caller_daily_budget"),
+	}
+
+	keys := viper.GetStringMapString("serve.api_keys")
+	if len(keys) == 0 {
+		fmt.Fprintln(os.Stderr, "⚠️  No serve.api_keys configured: the server is open and callers are limited by IP address")
+	}
+	return server.NewQuotas(keys, limits, llm.NewCostManager(llm.DailyLimit()))
+}
+
+// scanToCache scans a project (or the live account) and saves the result to
+// the project's cache, returning the number of resources found.
+func scanToCache(ctx context.Context, dir string, live bool) (int, error) {
+	var provider state.Provider = &state.IaCProvider{}
+	if live {
+		awsClient, err := newAWSClient(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to initialize AWS client: %w", err)
+		}
+		provider = &state.LiveProvider{Client: awsClient}
+	}
+
+	infraState, err := provider.Scan(ctx, dir)
+	if err != nil {
+		return 0, err
+	}
+	cacheManager, err := newCacheManager(dir)
+	if err != nil {
+		return 0, err
+	}
+	keepImports(cacheManager, infraState)
+	if err := cacheManager.Save(infraState); err != nil {
+		return 0, fmt.Errorf("could not save cache: %w", err)
+	}
+
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	return len(resources), nil
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "address to listen on (config: serve.addr)")
+	serveCmd.Flags().BoolVar(&serveLive, "live", false, "rescan the live AWS account on POST /scan instead of IaC files")
+	rootCmd.AddCommand(serveCmd)
+}
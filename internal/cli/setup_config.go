@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// errConfigDeclined is returned by saveConfig when the user rejects the changes.
+var errConfigDeclined = errors.New("cancelled, nothing was written")
+
+// configFilePath returns the config file setup writes: --config, or
+// ~/.cloudai.yaml.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cloudai.yaml"), nil
+}
+
+// saveConfig merges settings (dotted keys such as "model.type") into the
+// config file. Every other setting in the file is left as it is. The changes
+// are shown as a diff and only written after confirmation (or with --yes);
+// running the same setup twice writes nothing.
+func saveConfig(settings map[string]interface{}) error {
+	configPath, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]interface{}{}
+	if data, err := os.ReadFile(configPath); err == nil {
+		if err := yaml.Unmarshal(data, &existing); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+		if existing == nil {
+			existing = map[string]interface{}{}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var diff []string
+	for _, key := range keys {
+		value := settings[key]
+		old, found := lookupConfigKey(existing, key)
+		switch {
+		case !found:
+			diff = append(diff, fmt.Sprintf("   + %s: %s", key, displayConfigValue(key, value)))
+		case fmt.Sprint(old) != fmt.Sprint(value):
+			diff = append(diff, fmt.Sprintf("   ~ %s: %s → %s", key, displayConfigValue(key, old), displayConfigValue(key, value)))
+		default:
+			continue
+		}
+		setConfigKey(existing, key, value)
+	}
+
+	// Keep this process in sync, e.g. for the test that follows auto-setup
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+
+	if len(diff) == 0 {
+		fmt.Printf("✅ %s is already up to date\n", configPath)
+		return nil
+	}
+	fmt.Printf("\n📝 Changes to %s (other settings are kept):\n", configPath)
+	fmt.Println(strings.Join(diff, "\n"))
+	if !setupYes && !confirm("Write these changes? (y/N): ") {
+		return errConfigDeclined
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(existing); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	// The file may hold API keys; write through a temp file so a failed write
+	// never truncates it
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, configPath)
+}
+
+// lookupConfigKey finds a dotted key in a decoded YAML document.
+func lookupConfigKey(config map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := config[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		config = next
+	}
+	value, ok := config[parts[len(parts)-1]]
+	return value, ok
+}
+
+// setConfigKey sets a dotted key, creating intermediate sections as needed.
+// A scalar in the way of a section is replaced.
+func setConfigKey(config map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := config[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			config[part] = next
+		}
+		config = next
+	}
+	config[parts[len(parts)-1]] = value
+}
+
+// displayConfigValue masks secrets in the diff.
+func displayConfigValue(key string, value interface{}) string {
+	s := fmt.Sprint(value)
+	for _, secret := range []string{"api_key", "token", "secret", "password"} {
+		if strings.Contains(key, secret) {
+			if len(s) > 4 {
+				return "****" + s[len(s)-4:]
+			}
+			return "****"
+		}
+	}
+	return s
+}
@@ -7,13 +7,11 @@ import (
 	"os"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrock"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/ddjura/cloudai/internal/aws"
 	"github.com/ddjura/cloudai/internal/llm"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var interactiveSetupCmd = &cobra.Command{
@@ -169,7 +167,7 @@ func setupLocalOllama(reader *bufio.Reader) error {
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
 	// Check if Ollama is installed
-	if !isOllamaAvailable("http://localhost:11434") {
+	if !llm.OllamaAvailable("http://localhost:11434") {
 		fmt.Println("\n❌ Ollama is not running on your machine.")
 		fmt.Println("\n📋 To install Ollama:")
 		fmt.Println("   1. Visit: https://ollama.com/")
@@ -184,7 +182,7 @@ func setupLocalOllama(reader *bufio.Reader) error {
 		fmt.Print("Press Enter after installing Ollama...")
 		reader.ReadString('\n')
 
-		if !isOllamaAvailable("http://localhost:11434") {
+		if !llm.OllamaAvailable("http://localhost:11434") {
 			return fmt.Errorf("Ollama is still not available. Please ensure it's running.")
 		}
 	}
@@ -225,11 +223,11 @@ func setupLocalOllama(reader *bufio.Reader) error {
 	}
 
 	// Save configuration
-	viper.Set("model.type", "ollama")
-	viper.Set("model.name", bestModel)
-	viper.Set("model.url", "http://localhost:11434")
-
-	if err := saveConfig(); err != nil {
+	if err := saveConfig(map[string]interface{}{
+		"model.type": "ollama",
+		"model.name": bestModel,
+		"model.url":  "http://localhost:11434",
+	}); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -272,6 +270,11 @@ func setupEC2Ollama(reader *bufio.Reader) error {
 	}
 	fmt.Println("✅ AWS credentials found!")
 
+	fmt.Println("\n🔍 Checking EC2 vCPU quotas...")
+	if err := checkOllamaQuotas(context.Background()); err != nil {
+		fmt.Printf("⚠️  Could not check quotas: %v\n", err)
+	}
+
 	fmt.Println("\n🚀 To deploy Ollama on EC2:")
 	fmt.Println("   ./deploy-ollama-ec2.sh")
 	fmt.Println("\nThis script will:")
@@ -305,11 +308,11 @@ func setupSageMaker(reader *bufio.Reader) error {
 	endpoint = strings.TrimSpace(endpoint)
 
 	// Save configuration
-	viper.Set("model.type", "sagemaker")
-	viper.Set("model.endpoint", endpoint)
-	viper.Set("model.region", "us-east-1")
-
-	if err := saveConfig(); err != nil {
+	if err := saveConfig(map[string]interface{}{
+		"model.type":     "sagemaker",
+		"model.endpoint": endpoint,
+		"model.region":   "us-east-1",
+	}); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -337,12 +340,12 @@ func setupBedrock(reader *bufio.Reader) error {
 	fmt.Println("✅ AWS credentials found!")
 
 	// Save configuration
-	viper.Set("model.type", "aws")
-	viper.Set("model.aws_type", "bedrock")
-	viper.Set("model.model_id", "anthropic.claude-3-haiku-20240307-v1:0")
-	viper.Set("model.region", "us-east-1")
-
-	if err := saveConfig(); err != nil {
+	if err := saveConfig(map[string]interface{}{
+		"model.type":     "aws",
+		"model.aws_type": "bedrock",
+		"model.model_id": "anthropic.claude-3-haiku-20240307-v1:0",
+		"model.region":   "us-east-1",
+	}); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -368,7 +371,7 @@ func setupPrivacyRemoteAPI(reader *bufio.Reader) error {
 	fmt.Println("   • OpenAI or Anthropic API key")
 
 	// First ensure local Ollama is set up
-	if !isOllamaAvailable("http://localhost:11434") {
+	if !llm.OllamaAvailable("http://localhost:11434") {
 		fmt.Println("\n❌ Local Ollama required for privacy protection")
 		fmt.Println("💡 Please set up Option 1 first, then return here")
 		return nil
@@ -401,15 +404,15 @@ func setupPrivacyRemoteAPI(reader *bufio.Reader) error {
 	apiKey = strings.TrimSpace(apiKey)
 
 	// Save configuration
-	viper.Set("model.type", "privacy-remote")
-	viper.Set("model.local_sanitizer", "ollama")
-	viper.Set("model.remote_provider", provider)
-	viper.Set("model.api_key", apiKey)
-	viper.Set("privacy.enabled", true)
-	viper.Set("privacy.redact_account_ids", true)
-	viper.Set("privacy.redact_arns", true)
-
-	if err := saveConfig(); err != nil {
+	if err := saveConfig(map[string]interface{}{
+		"model.type":                 "privacy-remote",
+		"model.local_sanitizer":      "ollama",
+		"model.remote_provider":      provider,
+		"model.api_key":              apiKey,
+		"privacy.enabled":            true,
+		"privacy.redact_account_ids": true,
+		"privacy.redact_arns":        true,
+	}); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -436,7 +439,7 @@ func setupPrivacyCLI(reader *bufio.Reader) error {
 	fmt.Println("   • Google Gemini CLI or similar tool")
 
 	// First ensure local Ollama is set up
-	if !isOllamaAvailable("http://localhost:11434") {
+	if !llm.OllamaAvailable("http://localhost:11434") {
 		fmt.Println("\n❌ Local Ollama required for privacy protection")
 		fmt.Println("💡 Please set up Option 1 first, then return here")
 		return nil
@@ -481,15 +484,15 @@ func setupPrivacyCLI(reader *bufio.Reader) error {
 	reader.ReadString('\n')
 
 	// Save configuration
-	viper.Set("model.type", "privacy-cli")
-	viper.Set("model.local_sanitizer", "ollama")
-	viper.Set("model.cli_tool", cliTool)
-	viper.Set("model.cli_command", cliCommand)
-	viper.Set("privacy.enabled", true)
-	viper.Set("privacy.redact_account_ids", true)
-	viper.Set("privacy.redact_resource_names", true)
-
-	if err := saveConfig(); err != nil {
+	if err := saveConfig(map[string]interface{}{
+		"model.type":                    "privacy-cli",
+		"model.local_sanitizer":         "ollama",
+		"model.cli_tool":                cliTool,
+		"model.cli_command":             cliCommand,
+		"privacy.enabled":               true,
+		"privacy.redact_account_ids":    true,
+		"privacy.redact_resource_names": true,
+	}); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
@@ -506,7 +509,7 @@ func testLocalSetup(model string) error {
 
 	// Here you would make a simple API call to Ollama
 	// For now, just check if it's available
-	if isOllamaAvailable("http://localhost:11434") {
+	if llm.OllamaAvailable("http://localhost:11434") {
 		fmt.Println("✓")
 		return nil
 	}
@@ -551,7 +554,7 @@ func checkForModels(url string) bool {
 // checkAWSCredentials verifies that AWS credentials are configured
 func checkAWSCredentials() error {
 	ctx := context.Background()
-	_, err := config.LoadDefaultConfig(ctx)
+	_, err := aws.LoadConfig(ctx, awsClientOptions()...)
 	if err != nil {
 		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -561,7 +564,7 @@ func checkAWSCredentials() error {
 // checkBedrockAccess verifies that Bedrock is accessible and models are enabled
 func checkBedrockAccess() error {
 	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+	cfg, err := aws.LoadConfig(ctx, awsClientOptions()...)
 	if err != nil {
 		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -576,43 +579,22 @@ func checkBedrockAccess() error {
 	return nil
 }
 
-// testModelAccess tests if a specific model can be invoked
+// testModelAccess checks that a specific model can be invoked, without
+// sending a paid request
 func testModelAccess(modelID string) error {
 	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+	cfg, err := aws.LoadConfig(ctx, awsClientOptions()...)
 	if err != nil {
 		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	bedrockClient := bedrockruntime.NewFromConfig(cfg)
-
-	// Try a minimal test request
-	testPrompt := "Hello"
-	body := `{"prompt": "` + testPrompt + `", "max_tokens": 1, "temperature": 0.1, "anthropic_version": "bedrock-2023-05-31"}`
-
-	_, err = bedrockClient.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
-		ModelId:     aws.String(modelID),
-		ContentType: aws.String("application/json"),
-		Body:        []byte(body),
-	})
-
-	if err != nil {
-		return fmt.Errorf("model %s not accessible: %w", modelID, err)
+	if !modelAccessible(ctx, bedrock.NewFromConfig(cfg), bedrockruntime.NewFromConfig(cfg), modelID) {
+		return fmt.Errorf("model %s not accessible: access is not enabled in %s", modelID, cfg.Region)
 	}
 
 	return nil
 }
 
-func saveConfig() error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	configPath := home + "/.cloudai.yaml"
-	return viper.WriteConfigAs(configPath)
-}
-
 func init() {
 	rootCmd.AddCommand(interactiveSetupCmd)
 }
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+// maxStatsRows caps the model and resource lists in the text output
+const maxStatsRows = 5
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize your local CloudAI-CLI usage",
+	Long: `Shows queries per day, models used, average latency, cost trend and the resources
+you ask about most. Everything is computed from the local usage ledger
+(~/.cloudai/usage.jsonl); nothing is sent anywhere.`,
+	RunE: runStats,
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsDays < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	ledger, err := usage.NewLedger()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -(statsDays - 1))
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	entries, err := ledger.Load(since)
+	if err != nil {
+		return fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	stats := usage.Summarize(entries, since, now)
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "stats",
+			Data:    stats,
+			Success: true,
+		})
+	}
+
+	fmt.Printf("📈 CloudAI-CLI usage – last %d days\n", statsDays)
+	if stats.Queries == 0 {
+		fmt.Println("\nNo questions recorded yet. Ask one with: cloudai \"<question>\"")
+		return nil
+	}
+
+	fmt.Printf("\n   Queries:      %d (%d failed)\n", stats.Queries, stats.Failed)
+	fmt.Printf("   Avg latency:  %.1fs\n", float64(stats.AvgLatencyMs)/1000)
+	fmt.Printf("   Tokens:       ~%d\n", stats.TotalTokens)
+	fmt.Printf("   Cost:         $%.4f\n", stats.TotalCost)
+	if trend := costTrend(stats.Days); trend != "" {
+		fmt.Printf("   Cost trend:   %s\n", trend)
+	}
+
+	fmt.Println("\n📅 Queries per day")
+	peak := 0
+	for _, day := range stats.Days {
+		peak = max(peak, day.Queries)
+	}
+	for _, day := range stats.Days {
+		if day.Queries == 0 && statsDays > 14 {
+			continue
+		}
+		bar := strings.Repeat("█", day.Queries*30/max(peak, 1))
+		fmt.Printf("   %s %-30s %3d  $%.4f\n", day.Date, bar, day.Queries, day.Cost)
+	}
+
+	fmt.Println("\n🤖 Models used")
+	for _, m := range limitCounts(stats.Models) {
+		fmt.Printf("   %-55s %d\n", m.Name, m.Count)
+	}
+
+	if len(stats.Resources) > 0 {
+		fmt.Println("\n🔎 Most-asked-about resources")
+		for _, r := range limitCounts(stats.Resources) {
+			fmt.Printf("   %-55s %d\n", r.Name, r.Count)
+		}
+	}
+	return nil
+}
+
+// costTrend compares spend over the last 7 days with the 7 days before.
+func costTrend(days []usage.DayStats) string {
+	if len(days) < 14 {
+		return ""
+	}
+	var recent, previous float64
+	for _, day := range days[len(days)-7:] {
+		recent += day.Cost
+	}
+	for _, day := range days[len(days)-14 : len(days)-7] {
+		previous += day.Cost
+	}
+	if previous == 0 {
+		return fmt.Sprintf("$%.4f this week (none the week before)", recent)
+	}
+	return fmt.Sprintf("$%.4f this week vs $%.4f the week before (%+.0f%%)", recent, previous, (recent-previous)/previous*100)
+}
+
+func limitCounts(counts []usage.Count) []usage.Count {
+	if len(counts) > maxStatsRows {
+		return counts[:maxStatsRows]
+	}
+	return counts
+}
+
+func init() {
+	statsCmd.Flags().IntVar(&statsDays, "days", 30, "number of days to summarize")
+	rootCmd.AddCommand(statsCmd)
+}
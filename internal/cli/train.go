@@ -0,0 +1,467 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/training"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var trainCmd = &cobra.Command{
+	Use:   "train",
+	Short: "Prepare and follow fine-tuning of the architecture model",
+}
+
+var trainBootstrapRoleCmd = &cobra.Command{
+	Use:   "bootstrap-role",
+	Short: "Create or locate the SageMaker execution role and training bucket",
+	Long: `Creates the S3 bucket and SageMaker execution role that training jobs for
+the architecture model run with, or reuses them if they exist, and writes
+them to the config as training.role_arn, training.bucket and training.region.
+
+The role trusts only SageMaker and is scoped by an inline policy to the
+training bucket, the deep learning container images, SageMaker logs and
+metrics, and Hugging Face tokens stored as cloudai-* secrets. Running the
+command again re-applies that policy.
+
+  cloudai train bootstrap-role
+  cloudai train bootstrap-role --role-name ml-training --bucket my-training-bucket
+
+This is one of the few commands that changes your account, so the read-only
+guard is lifted for it, unless --read-only is passed explicitly, and you are
+asked to confirm unless --yes is given.
+Requires sts:GetCallerIdentity, s3:ListBucket, s3:CreateBucket, iam:GetRole,
+iam:CreateRole, iam:TagRole and iam:PutRolePolicy.`,
+	Args: cobra.NoArgs,
+	RunE: runTrainBootstrapRole,
+}
+
+func runTrainBootstrapRole(cmd *cobra.Command, args []string) error {
+	// The guard is lifted for this command, unless asked for explicitly
+	if err := refuseReadOnly(cmd, "creates a bucket and a role"); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := aws.NewClient(ctx, append(awsClientOptions(), aws.WithWrites())...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+
+	bucket := trainBucket
+	if bucket == "" {
+		bucket = "cloudai-training-<account>-<region>"
+	}
+	fmt.Println("🏗️  Training resources:")
+	fmt.Printf("   • S3 bucket %s\n", bucket)
+	fmt.Printf("   • IAM role %s, assumable by SageMaker and scoped to that bucket\n", trainRoleName)
+	fmt.Println("   Existing ones are reused; missing ones are created in your account.")
+	if !setupYes && !confirm("Continue? (y/N): ") {
+		return fmt.Errorf("cancelled, nothing was created")
+	}
+
+	resources, err := client.BootstrapTraining(ctx, trainRoleName, trainBucket)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%s bucket %s (%s)\n", createdOrFound(resources.BucketCreated), resources.Bucket, resources.Region)
+	fmt.Printf("%s role %s\n", createdOrFound(resources.RoleCreated), resources.RoleARN)
+	if resources.RoleCreated {
+		fmt.Println("   New IAM roles can take a few seconds before SageMaker is able to assume them.")
+	}
+
+	return saveConfig(map[string]interface{}{
+		"training.role_arn": resources.RoleARN,
+		"training.bucket":   resources.Bucket,
+		"training.region":   resources.Region,
+	})
+}
+
+var trainPrepareCmd = &cobra.Command{
+	Use:   "prepare <file.jsonl>",
+	Short: "Validate, deduplicate and balance training data",
+	Long: `Checks a JSONL file of training examples and writes the ones worth training
+on, reporting what was dropped and why. Each line is an object with a prompt
+and a completion, and optionally an intent and a satisfaction rating (1-5):
+
+  {"prompt": "Which Lambda handles GET /users?", "completion": "...", "intent": "api_gateway_lambda", "satisfaction": 5}
+
+Malformed lines, empty or over-long examples, duplicates (ignoring case and
+whitespace) and examples rated below --min-satisfaction are dropped. No
+intent keeps more than --intent-cap times the examples of the median intent;
+the best rated are kept.
+
+  cloudai train prepare examples.jsonl                 # writes examples.clean.jsonl
+  cloudai train prepare examples.jsonl --upload        # and uploads it to training.bucket
+
+--upload puts the result under train/ in the bucket of 'cloudai train
+bootstrap-role', where training jobs read it; it needs s3:PutObject and is
+refused when --read-only is passed explicitly.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTrainPrepare,
+}
+
+func runTrainPrepare(cmd *cobra.Command, args []string) error {
+	if trainUpload {
+		if err := refuseReadOnly(cmd, "--upload writes to the training bucket"); err != nil {
+			return err
+		}
+	}
+	dataset, err := prepareTrainingData(args[0])
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if err := output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "train prepare " + args[0],
+			Data:    dataset,
+			Success: true,
+		}); err != nil {
+			return err
+		}
+	} else {
+		printTrainingStats(dataset)
+	}
+	if dataset.Stats.Kept == 0 {
+		return fmt.Errorf("no usable examples in %s", args[0])
+	}
+
+	var buf bytes.Buffer
+	if err := dataset.WriteJSONL(&buf); err != nil {
+		return err
+	}
+	path := trainOutput
+	if path == "" {
+		path = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".clean.jsonl"
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if !jsonOutput {
+		fmt.Printf("\n💾 %d examples written to %s\n", dataset.Stats.Kept, path)
+	}
+	if !trainUpload {
+		return nil
+	}
+
+	bucket := getConfigString("training.bucket")
+	if bucket == "" {
+		return fmt.Errorf("training.bucket is not set: run 'cloudai train bootstrap-role' first")
+	}
+	ctx := context.Background()
+	client, err := aws.NewClient(ctx, append(awsClientOptions(), aws.WithWrites())...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	name := time.Now().UTC().Format("20060102T150405Z") + ".jsonl"
+	uri, err := client.UploadTrainingData(ctx, bucket, name, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if !jsonOutput {
+		fmt.Printf("☁️  Uploaded to %s\n", uri)
+	}
+	return nil
+}
+
+// prepareTrainingData validates a JSONL file of examples with the limits
+// from the flags, falling back to the training section of the config.
+func prepareTrainingData(path string) (*training.Dataset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	opts := training.DefaultOptions()
+	if viper.IsSet("training.min_satisfaction") {
+		opts.MinSatisfaction = viper.GetFloat64("training.min_satisfaction")
+	}
+	if viper.IsSet("training.intent_cap") {
+		opts.IntentCap = viper.GetFloat64("training.intent_cap")
+	}
+	if viper.IsSet("training.max_chars") {
+		opts.MaxChars = viper.GetInt("training.max_chars")
+	}
+	if trainMinRating >= 0 {
+		opts.MinSatisfaction = trainMinRating
+	}
+	if trainIntentCap >= 0 {
+		opts.IntentCap = trainIntentCap
+	}
+	if trainMaxChars >= 0 {
+		opts.MaxChars = trainMaxChars
+	}
+	return training.Prepare(file, opts)
+}
+
+// printTrainingStats reports what preparation kept and dropped.
+func printTrainingStats(dataset *training.Dataset) {
+	stats := dataset.Stats
+	fmt.Printf("📚 %d examples read, %d kept\n", stats.Lines, stats.Kept)
+	for _, dropped := range []struct {
+		count  int
+		reason string
+	}{
+		{stats.Invalid, "invalid"},
+		{stats.Duplicates, "duplicates"},
+		{stats.LowSatisfaction, "rated below the minimum satisfaction"},
+		{stats.TooLong, "too long"},
+		{stats.Rebalanced, "over their intent's cap"},
+	} {
+		if dropped.count > 0 {
+			fmt.Printf("   • %d %s\n", dropped.count, dropped.reason)
+		}
+	}
+
+	const maxProblems = 10
+	if len(dataset.Problems) > 0 {
+		fmt.Println("\n⚠️  Dropped lines:")
+		for i, problem := range dataset.Problems {
+			if i == maxProblems {
+				fmt.Printf("   ... and %d more (--json lists them all)\n", len(dataset.Problems)-maxProblems)
+				break
+			}
+			fmt.Printf("   line %d: %s\n", problem.Line, problem.Reason)
+		}
+	}
+	if stats.Kept == 0 {
+		return
+	}
+
+	intents := make([]string, 0, len(stats.Intents))
+	for intent := range stats.Intents {
+		intents = append(intents, intent)
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		if stats.Intents[intents[i]] != stats.Intents[intents[j]] {
+			return stats.Intents[intents[i]] > stats.Intents[intents[j]]
+		}
+		return intents[i] < intents[j]
+	})
+	table := &output.Table{Title: "Intents", Headers: []string{"Intent", "Examples", "Share"}}
+	for _, intent := range intents {
+		count := stats.Intents[intent]
+		table.Rows = append(table.Rows, []string{intent, fmt.Sprint(count), fmt.Sprintf("%.0f%%", 100*float64(count)/float64(stats.Kept))})
+	}
+	fmt.Println()
+	table.Print()
+	fmt.Printf("\n📏 Average prompt %d characters, completion %d; about %d tokens per epoch\n",
+		stats.AvgPromptChars, stats.AvgCompletionChars, stats.EstimatedTokens)
+}
+
+var trainLogsCmd = &cobra.Command{
+	Use:   "logs <training-job>",
+	Short: "Follow a SageMaker training job's logs and metrics",
+	Long: `Streams the CloudWatch Logs of a training job to the terminal until it
+completes, fails or is stopped, and reports the loss, accuracy and similar
+values the training script logs as they change. Status changes, such as
+downloading the data or a spot interruption, are shown as they happen.
+
+  cloudai train logs cloudai-arch-20250101T120000Z
+  cloudai train logs cloudai-arch-20250101T120000Z --metrics-only
+
+A job that has already finished prints its logs and final state. Requires
+sagemaker:DescribeTrainingJob and logs:FilterLogEvents.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTrainLogs,
+}
+
+func runTrainLogs(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	client, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	job, err := monitorTrainingJob(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "train logs " + args[0],
+			Data:    job,
+			Success: job.Status == "Completed",
+		})
+	}
+	if job.Status != "Completed" {
+		return fmt.Errorf("training job %s %s", job.Name, strings.ToLower(job.Status))
+	}
+	return nil
+}
+
+// monitorTrainingJob follows a training job until it is done, printing its
+// log lines, or with --metrics-only just the metrics found in them, and
+// returns its final state.
+func monitorTrainingJob(ctx context.Context, client *aws.Client, name string) (*aws.TrainingJob, error) {
+	latest := map[string]float64{}
+	var names []string
+	onStatus := func(job *aws.TrainingJob) {
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "⏳ %s: %s (%s)\n", job.Name, job.Status, job.SecondaryStatus)
+		}
+	}
+	onLine := func(line aws.LogLine) {
+		metrics := training.ParseMetrics(line.Message)
+		for _, metric := range metrics {
+			if _, ok := latest[metric.Name]; !ok {
+				names = append(names, metric.Name)
+			}
+			latest[metric.Name] = metric.Value
+		}
+		switch {
+		case jsonOutput:
+		case !trainMetricsOnly:
+			fmt.Printf("%s %s\n", line.Time.Format("15:04:05"), strings.TrimRight(line.Message, "\n"))
+		case len(metrics) > 0:
+			parts := make([]string, len(metrics))
+			for i, metric := range metrics {
+				parts[i] = fmt.Sprintf("%s=%.4g", metric.Name, metric.Value)
+			}
+			fmt.Printf("📈 %s %s\n", line.Time.Format("15:04:05"), strings.Join(parts, " "))
+		}
+	}
+
+	job, err := client.FollowTrainingJob(ctx, name, trainPollInterval, onStatus, onLine)
+	if err != nil {
+		return nil, err
+	}
+	// metrics SageMaker extracted itself take precedence over the logs
+	if job.FinalMetrics == nil && len(latest) > 0 {
+		job.FinalMetrics = latest
+	}
+	if jsonOutput {
+		return job, nil
+	}
+
+	fmt.Println()
+	switch job.Status {
+	case "Completed":
+		fmt.Printf("✅ %s completed", job.Name)
+	case "Failed":
+		fmt.Printf("❌ %s failed: %s", job.Name, job.FailureReason)
+	default:
+		fmt.Printf("⏹️  %s %s", job.Name, strings.ToLower(job.Status))
+	}
+	if job.BillableSeconds > 0 {
+		fmt.Printf(", %s billable", (time.Duration(job.BillableSeconds) * time.Second).String())
+		if job.Spot {
+			fmt.Print(" on spot capacity")
+		}
+	}
+	fmt.Println()
+	if len(names) > 0 {
+		parts := make([]string, len(names))
+		for i, metric := range names {
+			parts[i] = fmt.Sprintf("%s=%.4g", metric, latest[metric])
+		}
+		fmt.Printf("📈 Last logged: %s\n", strings.Join(parts, " "))
+	}
+	if job.ModelArtifacts != "" {
+		fmt.Printf("📦 Model: %s\n", job.ModelArtifacts)
+	}
+	if job.Status == "Completed" {
+		fmt.Printf("\n💡 Serve it for architecture questions: cloudai train register %s && cloudai sagemaker deploy\n", job.Name)
+	}
+	return job, nil
+}
+
+var trainRegisterCmd = &cobra.Command{
+	Use:   "register <training-job>",
+	Short: "Register a completed training job's model in the model registry",
+	Long: `Registers the model a completed training job produced as a new, approved
+version in the SageMaker Model Registry (training.model_package_group,
+default cloudai-arch-models), ready for 'cloudai sagemaker deploy'.
+
+The version records the training job, its base model, a SHA-256 of its
+training data and eval scores: the metrics SageMaker extracted from the job,
+plus any given with --eval.
+
+  cloudai train register cloudai-arch-20250101T120000Z
+  cloudai train register cloudai-arch-20250101T120000Z --eval exact_match=0.82 --eval judge_score=4.1
+
+This changes your account, so the read-only guard is lifted for it, unless
+--read-only is passed explicitly.
+Requires sagemaker:DescribeTrainingJob, sagemaker:DescribeModelPackageGroup,
+sagemaker:CreateModelPackageGroup, sagemaker:CreateModelPackage,
+sagemaker:DescribeModelPackage and s3:GetObject on the training data.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTrainRegister,
+}
+
+func runTrainRegister(cmd *cobra.Command, args []string) error {
+	if err := refuseReadOnly(cmd, "creates a model package"); err != nil {
+		return err
+	}
+	scores := map[string]float64{}
+	for name, value := range trainEvalScores {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("eval score %s=%s is not a number", name, value)
+		}
+		scores[name] = score
+	}
+
+	ctx := context.Background()
+	client, err := aws.NewClient(ctx, append(awsClientOptions(), aws.WithWrites())...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	group := modelPackageGroup()
+	version, err := client.RegisterTrainedModel(ctx, group, args[0], trainInstanceType, scores)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "train register " + args[0],
+			Data:    version,
+			Success: true,
+		})
+	}
+	fmt.Printf("✅ Registered %s as version %d of %s\n", args[0], version.Version, group)
+	if version.DatasetHash != "" {
+		fmt.Printf("   • dataset %s (sha256 %s)\n", version.Dataset, version.DatasetHash)
+	}
+	if scores := formatEvalScores(version.EvalScores); scores != "" {
+		fmt.Printf("   • eval scores: %s\n", scores)
+	}
+	fmt.Printf("\n💡 Deploy it with: cloudai sagemaker deploy --version %d\n", version.Version)
+	return nil
+}
+
+func createdOrFound(created bool) string {
+	if created {
+		return "✅ Created"
+	}
+	return "✅ Found"
+}
+
+func init() {
+	trainBootstrapRoleCmd.Flags().StringVar(&trainRoleName, "role-name", aws.DefaultTrainingRoleName, "name of the SageMaker execution role")
+	trainBootstrapRoleCmd.Flags().StringVar(&trainBucket, "bucket", "", "training bucket (default: cloudai-training-<account>-<region>)")
+	trainBootstrapRoleCmd.Flags().BoolVarP(&setupYes, "yes", "y", false, "create the resources and write the config without asking")
+	trainPrepareCmd.Flags().StringVarP(&trainOutput, "output", "o", "", "where to write the prepared JSONL (default: <file>.clean.jsonl)")
+	trainPrepareCmd.Flags().BoolVar(&trainUpload, "upload", false, "upload the prepared JSONL to train/ in training.bucket")
+	trainPrepareCmd.Flags().Float64Var(&trainMinRating, "min-satisfaction", -1, "drop examples rated below this, 1-5 (default 3, or training.min_satisfaction)")
+	trainPrepareCmd.Flags().Float64Var(&trainIntentCap, "intent-cap", -1, "keep at most this many times the median intent's examples per intent, 0 to not balance (default 3, or training.intent_cap)")
+	trainPrepareCmd.Flags().IntVar(&trainMaxChars, "max-chars", -1, "drop examples longer than this, 0 for no limit (default 8000, or training.max_chars)")
+	trainLogsCmd.Flags().BoolVar(&trainMetricsOnly, "metrics-only", false, "print only the metrics found in the logs, not every line")
+	trainLogsCmd.Flags().DurationVar(&trainPollInterval, "interval", 10*time.Second, "how often to check for new log lines and status changes")
+	trainRegisterCmd.Flags().StringToStringVar(&trainEvalScores, "eval", nil, "eval score to record, as name=value (repeatable)")
+	trainRegisterCmd.Flags().StringVar(&trainInstanceType, "instance-type", aws.DefaultInferenceInstanceType, "instance type the model is registered to be served on")
+	trainCmd.AddCommand(trainBootstrapRoleCmd, trainPrepareCmd, trainLogsCmd, trainRegisterCmd)
+	rootCmd.AddCommand(trainCmd)
+}
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+// defaultTunnelTarget is the Name tag ec2-ollama-stack.yaml gives its
+// instance.
+const defaultTunnelTarget = "cloudai-ollama-server"
+
+// tunnelOpenTimeout bounds how long the session may take to start listening
+const tunnelOpenTimeout = 30 * time.Second
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel [instance]",
+	Short: "Forward a local port to a private Ollama instance or SageMaker endpoint over SSM",
+	Long: `Opens an SSM Session Manager port-forward to an EC2 instance, given by ID or
+Name tag (default: cloudai-ollama-server), so a model in a private subnet is
+reachable without opening a port to the internet:
+
+  cloudai tunnel                      # Ollama on the instance's port 11434
+  cloudai tunnel i-0abc123 --local-port 21434
+  cloudai tunnel bastion --remote-host vpce-0abc.runtime.sagemaker.us-east-1.vpce.amazonaws.com
+
+While the tunnel is open, questions in other terminals send Ollama requests
+to its local port instead of model.url; with --remote-host, SageMaker
+requests go through the instance to that VPC endpoint. The model config is
+left untouched and everything returns to normal when you press Ctrl-C.
+
+The session is run by the AWS CLI, so 'aws' and the Session Manager plugin
+must be installed, and the instance must run the SSM agent.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTunnel,
+}
+
+func runTunnel(cmd *cobra.Command, args []string) error {
+	target := defaultTunnelTarget
+	if len(args) > 0 {
+		target = args[0]
+	}
+	model := tunnelModel
+	if model == "" {
+		model = llm.TunnelOllama
+		if strings.Contains(tunnelRemoteHost, "sagemaker") {
+			model = llm.TunnelSageMaker
+		}
+	}
+	if model != llm.TunnelOllama && model != llm.TunnelSageMaker {
+		return fmt.Errorf("unknown --model %q: use %s or %s", model, llm.TunnelOllama, llm.TunnelSageMaker)
+	}
+	if model == llm.TunnelSageMaker && tunnelRemoteHost == "" {
+		return fmt.Errorf("a SageMaker tunnel needs --remote-host, the DNS name of the SageMaker runtime VPC endpoint")
+	}
+	remotePort := tunnelRemotePort
+	if remotePort == 0 {
+		remotePort = 11434
+		if model == llm.TunnelSageMaker {
+			remotePort = 443
+		}
+	}
+
+	for _, tool := range []string{"aws", "session-manager-plugin"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("%s is not installed: the tunnel runs 'aws ssm start-session', which needs the AWS CLI and the Session Manager plugin", tool)
+		}
+	}
+	if existing := llm.ActiveTunnel(); existing != nil {
+		return fmt.Errorf("a tunnel to %s is already open on localhost:%d", existing.Target, existing.LocalPort)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	awsClient, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	instance, err := awsClient.FindInstance(ctx, target)
+	if err != nil {
+		return err
+	}
+	localPort := tunnelLocalPort
+	if localPort == 0 {
+		if localPort, err = freeLocalPort(); err != nil {
+			return err
+		}
+	}
+
+	session := exec.CommandContext(ctx, "aws", tunnelSessionArgs(instance.ID, remotePort, localPort)...)
+	session.Stdout = os.Stderr
+	session.Stderr = os.Stderr
+	if err := session.Start(); err != nil {
+		return fmt.Errorf("could not start the SSM session: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- session.Wait() }()
+
+	if err := waitForPort(ctx, localPort, exited); err != nil {
+		return err
+	}
+	tunnel := &llm.Tunnel{
+		Model:      model,
+		LocalPort:  localPort,
+		Target:     instance.ID,
+		RemoteHost: tunnelRemoteHost,
+		Started:    time.Now(),
+	}
+	if err := llm.SaveTunnel(tunnel); err != nil {
+		return fmt.Errorf("could not record the tunnel: %w", err)
+	}
+	defer llm.RemoveTunnel()
+
+	name := instance.ID
+	if instance.Name != "" {
+		name = fmt.Sprintf("%s (%s)", instance.Name, instance.ID)
+	}
+	destination := fmt.Sprintf("port %d on %s", remotePort, name)
+	if tunnelRemoteHost != "" {
+		destination = fmt.Sprintf("%s:%d through %s", tunnelRemoteHost, remotePort, name)
+	}
+	fmt.Printf("🔌 Tunnel open: localhost:%d → %s\n", localPort, destination)
+	fmt.Printf("   %s requests from other terminals use it until you press Ctrl-C\n", tunnelModelName(model))
+
+	err = <-exited
+	if ctx.Err() != nil {
+		fmt.Println("\n🔌 Tunnel closed")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("the SSM session ended: %w", err)
+	}
+	return nil
+}
+
+// tunnelSessionArgs builds the 'aws ssm start-session' arguments for a
+// port-forward to the instance itself or, with --remote-host, through it.
+func tunnelSessionArgs(instanceID string, remotePort, localPort int) []string {
+	document := "AWS-StartPortForwardingSession"
+	parameters := map[string][]string{
+		"portNumber":      {strconv.Itoa(remotePort)},
+		"localPortNumber": {strconv.Itoa(localPort)},
+	}
+	if tunnelRemoteHost != "" {
+		document = "AWS-StartPortForwardingSessionToRemoteHost"
+		parameters["host"] = []string{tunnelRemoteHost}
+	}
+	encoded, _ := json.Marshal(parameters)
+	args := []string{"ssm", "start-session", "--target", instanceID, "--document-name", document, "--parameters", string(encoded)}
+	if activeEnvironment.Profile != "" {
+		args = append(args, "--profile", activeEnvironment.Profile)
+	}
+	if activeEnvironment.Region != "" {
+		args = append(args, "--region", activeEnvironment.Region)
+	}
+	return args
+}
+
+// freeLocalPort asks the OS for a port nothing listens on.
+func freeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("could not find a free local port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForPort waits until the session listens on the local port, failing
+// early if the session exits first.
+func waitForPort(ctx context.Context, port int, exited <-chan error) error {
+	deadline := time.Now().Add(tunnelOpenTimeout)
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return fmt.Errorf("the SSM session did not start: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+		if conn, err := net.DialTimeout("tcp", address, time.Second); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return fmt.Errorf("the SSM session did not listen on localhost:%d within %s", port, tunnelOpenTimeout)
+}
+
+func tunnelModelName(model string) string {
+	if model == llm.TunnelSageMaker {
+		return "SageMaker"
+	}
+	return "Ollama"
+}
+
+func init() {
+	tunnelCmd.Flags().IntVar(&tunnelLocalPort, "local-port", 0, "local port to listen on (default: a free port)")
+	tunnelCmd.Flags().IntVar(&tunnelRemotePort, "remote-port", 0, "port to forward to (default: 11434 for Ollama, 443 for SageMaker)")
+	tunnelCmd.Flags().StringVar(&tunnelRemoteHost, "remote-host", "", "forward through the instance to this host, e.g. a SageMaker runtime VPC endpoint")
+	tunnelCmd.Flags().StringVar(&tunnelModel, "model", "", "model the tunnel carries: ollama or sagemaker (default: sagemaker for a SageMaker --remote-host)")
+	rootCmd.AddCommand(tunnelCmd)
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/ddjura/cloudai/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// defaultEnvironment names the project's unnamed cache in 'cloudai use'.
+const defaultEnvironment = "default"
+
+// activeEnvironment is the environment of the project being worked on; its
+// profile and region apply to every AWS client the command creates.
+var activeEnvironment state.Environment
+
+var useCmd = &cobra.Command{
+	Use:   "use [environment]",
+	Short: "Switch between named caches of a project, e.g. dev, staging and prod",
+	Long: `Keeps several infrastructure caches per project, one per environment, and selects
+the one that scans, imports and questions in this directory use. Each environment can
+name the AWS profile and region its live scans and lookups run with, so dev, staging
+and prod can be built from different accounts.
+
+Without arguments, lists the project's environments. 'cloudai use default' goes back
+to the unnamed cache in .cloudai/cache.json.
+
+Examples:
+  cloudai use prod --profile prod-readonly --region eu-west-1
+  cloudai scan --live
+  cloudai "Which Lambdas still run python3.8?"
+  cloudai use staging`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runUse,
+}
+
+func runUse(cmd *cobra.Command, args []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("could not get current working directory: %w", err)
+	}
+	envs, err := state.LoadEnvironments(dir)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		listEnvironments(dir, envs)
+		return nil
+	}
+
+	name := args[0]
+	if name == defaultEnvironment {
+		if useProfile != "" || useRegion != "" {
+			return fmt.Errorf("the default environment uses the standard AWS credential chain; name an environment to give it a profile or region")
+		}
+		envs.Current = ""
+	} else {
+		if err := state.ValidateEnvironmentName(name); err != nil {
+			return err
+		}
+		env := envs.Environments[name]
+		if cmd.Flags().Changed("profile") {
+			env.Profile = useProfile
+		}
+		if cmd.Flags().Changed("region") {
+			env.Region = useRegion
+		}
+		envs.Environments[name] = env
+		envs.Current = name
+	}
+	if err := envs.Save(dir); err != nil {
+		return fmt.Errorf("failed to save environments: %w", err)
+	}
+
+	env := envs.Environments[envs.Current]
+	fmt.Printf("✅ Using %s%s\n", name, describeEnvironment(env))
+	if !state.NewCacheManager(dir).Exists() {
+		fmt.Println("   No cache yet: run 'cloudai scan' (or 'cloudai scan --live') to build it")
+	}
+	return nil
+}
+
+func listEnvironments(dir string, envs *state.Environments) {
+	names := []string{defaultEnvironment}
+	for name := range envs.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+
+	current := envs.Current
+	if current == "" {
+		current = defaultEnvironment
+	}
+	table := &output.Table{Title: "Environments", Headers: []string{"", "Environment", "Profile", "Region"}}
+	for _, name := range names {
+		marker := ""
+		if name == current {
+			marker = "*"
+		}
+		env := envs.Environments[name]
+		table.Rows = append(table.Rows, []string{marker, name, orDash(env.Profile), orDash(env.Region)})
+	}
+	table.Print()
+}
+
+// selectEnvironment makes the current environment of the project in dir
+// the one AWS clients use.
+func selectEnvironment(dir string) {
+	_, activeEnvironment = state.CurrentEnvironment(dir)
+}
+
+// describeEnvironment summarises an environment's AWS settings.
+func describeEnvironment(env state.Environment) string {
+	switch {
+	case env.Profile != "" && env.Region != "":
+		return fmt.Sprintf(" (profile %s, region %s)", env.Profile, env.Region)
+	case env.Profile != "":
+		return fmt.Sprintf(" (profile %s)", env.Profile)
+	case env.Region != "":
+		return fmt.Sprintf(" (region %s)", env.Region)
+	}
+	return ""
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	useCmd.Flags().StringVar(&useProfile, "profile", "", "AWS profile for the environment's scans and lookups")
+	useCmd.Flags().StringVar(&useRegion, "region", "", "AWS region for the environment")
+	rootCmd.AddCommand(useCmd)
+}
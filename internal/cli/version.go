@@ -0,0 +1,435 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// version is set at release time with
+// -ldflags "-X github.com/ddjura/cloudai/internal/cli.version=v1.2.3".
+var version string
+
+// defaultReleaseRepo is where releases are published, overridable with
+// update.repo.
+const defaultReleaseRepo = "marko-durasic/CloudAI-CLI"
+
+// updateCheckInterval is how often update.check looks for a new release.
+const updateCheckInterval = 24 * time.Hour
+
+// pseudoVersion matches the timestamp and commit of a Go pseudo-version,
+// which 'go install' of an untagged commit reports.
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}`)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show the version of CloudAI-CLI and check for a newer release",
+	Long: `Shows the version, commit and build date of this binary. With --check, the latest
+release on GitHub is looked up and compared with it.
+
+To be told about new releases automatically, at most once a day, opt in with:
+
+  update:
+    check: true`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Replace this binary with the latest release",
+	Long: `Downloads the latest release for this platform from GitHub, verifies it against
+the release's checksums.txt and replaces the running binary with it.
+
+Binaries installed with 'go install' can also be upgraded with
+'go install github.com/ddjura/cloudai/cmd/cloudai@latest'.`,
+	Args: cobra.NoArgs,
+	RunE: runUpgrade,
+}
+
+// buildInfo describes the running binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// release is the part of a GitHub release we use
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				info.BuildDate = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	info := readBuildInfo()
+	var latest *release
+	if versionCheck {
+		var err error
+		if latest, err = latestRelease(cmd.Context()); err != nil {
+			return err
+		}
+		saveUpdateCheck(latest.TagName)
+	}
+
+	if jsonOutput {
+		data := map[string]interface{}{"build": info}
+		if latest != nil {
+			data["latest"] = latest.TagName
+			data["update_available"] = isNewerVersion(latest.TagName, info.Version)
+		}
+		return output.NewFormatter(true).FormatResult(&output.Result{Query: "version", Data: data, Success: true})
+	}
+
+	fmt.Printf("cloudai %s\n", info.Version)
+	if info.Commit != "" {
+		commit := info.Commit
+		if info.Modified {
+			commit += " (modified)"
+		}
+		fmt.Printf("   commit:  %s\n", commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("   built:   %s\n", info.BuildDate)
+	}
+	fmt.Printf("   go:      %s %s\n", info.GoVersion, info.Platform)
+
+	if latest != nil {
+		switch {
+		case isNewerVersion(latest.TagName, info.Version):
+			fmt.Printf("\n⬆️  %s is available (%s)\n   Run 'cloudai upgrade' to install it.\n", latest.TagName, latest.HTMLURL)
+		case !isReleaseVersion(info.Version):
+			fmt.Printf("\nℹ️  This is a development build; the latest release is %s\n", latest.TagName)
+		default:
+			fmt.Println("\n✅ This is the latest release")
+		}
+	}
+	return nil
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	info := readBuildInfo()
+	latest, err := latestRelease(ctx)
+	if err != nil {
+		return err
+	}
+	if isReleaseVersion(info.Version) && !isNewerVersion(latest.TagName, info.Version) {
+		fmt.Printf("✅ cloudai %s is the latest release\n", info.Version)
+		return nil
+	}
+
+	asset := releaseAssetName()
+	var binaryURL, checksumsURL string
+	for _, a := range latest.Assets {
+		switch a.Name {
+		case asset:
+			binaryURL = a.URL
+		case "checksums.txt":
+			checksumsURL = a.URL
+		}
+	}
+	if binaryURL == "" {
+		return fmt.Errorf("release %s has no binary for %s (expected %s)", latest.TagName, info.Platform, asset)
+	}
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no checksums.txt; refusing to install an unverified binary", latest.TagName)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+
+	fmt.Printf("⬇️  Downloading cloudai %s for %s...\n", latest.TagName, info.Platform)
+	want, err := releaseChecksum(ctx, checksumsURL, asset)
+	if err != nil {
+		return err
+	}
+	// Download next to the binary, so the final rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".cloudai-upgrade-*")
+	if err != nil {
+		return fmt.Errorf("failed to write next to %s: %w", executable, err)
+	}
+	defer os.Remove(tmp.Name())
+	got, err := download(ctx, binaryURL, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", asset, err)
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset, want, got)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	// Windows cannot replace a running executable, but it can rename it
+	old := executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", executable, err)
+	}
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		os.Rename(old, executable)
+		return fmt.Errorf("failed to replace %s: %w", executable, err)
+	}
+	os.Remove(old)
+	saveUpdateCheck(latest.TagName)
+	fmt.Printf("✅ Upgraded %s from %s to %s\n", executable, info.Version, latest.TagName)
+	return nil
+}
+
+// releaseAssetName is the name of the release binary for this platform,
+// e.g. cloudai_linux_amd64 or cloudai_windows_amd64.exe.
+func releaseAssetName() string {
+	name := "cloudai_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// latestRelease looks up the latest release of update.repo on GitHub.
+func latestRelease(ctx context.Context) (*release, error) {
+	repo := getConfigString("update.repo")
+	if repo == "" {
+		repo = defaultReleaseRepo
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/"+repo+"/releases/latest", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := llm.HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for a new release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for a new release: GitHub returned %s", resp.Status)
+	}
+	var latest release
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil {
+		return nil, fmt.Errorf("failed to parse the release: %w", err)
+	}
+	return &latest, nil
+}
+
+// releaseChecksum returns the SHA-256 of asset listed in a checksums.txt.
+func releaseChecksum(ctx context.Context, url, asset string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := llm.HTTPClient(0).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums.txt: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download checksums.txt: %s", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == asset {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksums.txt: %w", err)
+	}
+	return "", fmt.Errorf("checksums.txt has no entry for %s", asset)
+}
+
+// download writes url to w and returns the SHA-256 of what was written.
+func download(ctx context.Context, url string, w io.Writer) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := llm.HTTPClient(0).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned %s", resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// updateCheck records the last automatic release check
+type updateCheck struct {
+	Time   time.Time `json:"time"`
+	Latest string    `json:"latest"`
+}
+
+func updateCheckPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cloudai", "update-check.json"), nil
+}
+
+func saveUpdateCheck(latest string) {
+	path, err := updateCheckPath()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(updateCheck{Time: time.Now(), Latest: latest})
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
+
+// notifyUpdate tells the user on stderr when a newer release exists. It
+// only runs with update.check, asks GitHub at most once a day and never
+// fails the command.
+func notifyUpdate(ctx context.Context) {
+	if !viper.GetBool("update.check") {
+		return
+	}
+	info := readBuildInfo()
+	if !isReleaseVersion(info.Version) {
+		return
+	}
+
+	var last updateCheck
+	if path, err := updateCheckPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &last)
+		}
+	}
+	if time.Since(last.Time) > updateCheckInterval {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		latest, err := latestRelease(ctx)
+		if err != nil {
+			return
+		}
+		last.Latest = latest.TagName
+		saveUpdateCheck(latest.TagName)
+	}
+	if isNewerVersion(last.Latest, info.Version) {
+		fmt.Fprintf(os.Stderr, "⬆️  cloudai %s is available (you have %s); run 'cloudai upgrade'\n", last.Latest, info.Version)
+	}
+}
+
+// isReleaseVersion reports whether v is a release version such as v1.2.3,
+// rather than a development build or a Go pseudo-version.
+func isReleaseVersion(v string) bool {
+	_, ok := parseVersion(v)
+	return ok && !pseudoVersion.MatchString(v) && !strings.Contains(v, "+")
+}
+
+// isNewerVersion reports whether release v is newer than current.
+func isNewerVersion(v, current string) bool {
+	a, ok := parseVersion(v)
+	if !ok {
+		return false
+	}
+	b, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	// v1.2.3 is newer than its pre-releases, e.g. v1.2.3-rc.1
+	return !strings.Contains(v, "-") && strings.Contains(current, "-")
+}
+
+// parseVersion parses the major, minor and patch numbers of vX.Y.Z.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v, ok := strings.CutPrefix(v, "v")
+	if !ok {
+		return parts, false
+	}
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "look up the latest release on GitHub")
+	rootCmd.AddCommand(versionCmd, upgradeCmd)
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the AWS identity in use and which commands its permissions allow",
+	Long: `Calls STS GetCallerIdentity to show which AWS principal CloudAI-CLI is running as,
+then uses IAM policy simulation to check every permission needed by the enabled features.
+
+For each missing permission you get the exact IAM action and the commands that will fail
+without it. Simulation itself requires iam:SimulatePrincipalPolicy (and iam:GetRole for
+assumed roles).`,
+	RunE: runWhoami,
+}
+
+// featureCheck is the simulated permission state of a single feature
+type featureCheck struct {
+	aws.Feature
+	Missing []string `json:"missing,omitempty"`
+	OK      bool     `json:"ok"`
+}
+
+type whoamiReport struct {
+	Identity *aws.Identity  `json:"identity"`
+	Features []featureCheck `json:"features,omitempty"`
+	Warning  string         `json:"warning,omitempty"`
+}
+
+func runWhoami(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	awsClient, err := newAWSClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+
+	identity, err := awsClient.WhoAmI(ctx)
+	if err != nil {
+		return err
+	}
+
+	report := &whoamiReport{Identity: identity}
+
+	switch identity.PrincipalType {
+	case "root":
+		report.Warning = "running as the account root user: every permission is granted, but using root credentials is strongly discouraged"
+	case "federated":
+		report.Warning = "federated principals cannot be simulated; permissions were not checked"
+	default:
+		for _, feature := range enabledFeatures() {
+			decisions, err := awsClient.SimulateActions(ctx, identity.PrincipalARN, feature.Actions)
+			if err != nil {
+				report.Warning = fmt.Sprintf("could not simulate policies: %v", err)
+				report.Features = nil
+				break
+			}
+
+			check := featureCheck{Feature: feature, OK: true}
+			for _, action := range feature.Actions {
+				if decisions[action] != "allowed" {
+					check.Missing = append(check.Missing, action)
+					check.OK = false
+				}
+			}
+			report.Features = append(report.Features, check)
+		}
+	}
+
+	if jsonOutput {
+		return output.NewFormatter(true).FormatResult(&output.Result{
+			Query:   "whoami",
+			Data:    report,
+			Success: true,
+		})
+	}
+
+	fmt.Println("🪪 AWS Identity")
+	fmt.Printf("   Account: %s\n", identity.Account)
+	fmt.Printf("   ARN:     %s\n", identity.ARN)
+	if identity.PrincipalARN != "" && identity.PrincipalARN != identity.ARN {
+		fmt.Printf("   Policies evaluated for: %s\n", identity.PrincipalARN)
+	}
+	if endpoint := getConfigString("aws.endpoint_url"); endpoint != "" {
+		fmt.Printf("   Endpoint: %s (aws.endpoint_url)\n", endpoint)
+	}
+
+	if report.Warning != "" {
+		fmt.Printf("\n⚠️  %s\n", report.Warning)
+	}
+	if len(report.Features) == 0 {
+		return nil
+	}
+
+	fmt.Println("\n🔐 Permission check")
+	missingAny := false
+	for _, check := range report.Features {
+		if check.OK {
+			fmt.Printf("   ✅ %s\n", check.Name)
+			continue
+		}
+		missingAny = true
+		fmt.Printf("   ❌ %s — missing: %s\n", check.Name, strings.Join(check.Missing, ", "))
+		for _, command := range check.Commands {
+			fmt.Printf("      will fail: %s\n", command)
+		}
+	}
+
+	if missingAny {
+		fmt.Println("\n💡 Grant the missing actions to the principal above, or run 'cloudai setup' for a full policy.")
+	} else {
+		fmt.Println("\n🎉 All enabled features have the permissions they need.")
+	}
+
+	return nil
+}
+
+// enabledFeatures returns the features relevant to the current configuration.
+// Model backends are only checked when they are actually configured.
+func enabledFeatures() []aws.Feature {
+	modelType := getConfigString("model.type")
+	awsType := getConfigString("model.aws_type")
+	if envType := os.Getenv("AWS_MODEL_TYPE"); envType != "" {
+		awsType = envType
+	}
+
+	var features []aws.Feature
+	for _, feature := range aws.Features {
+		switch feature.Name {
+		case "Bedrock models":
+			if awsType != "bedrock" {
+				continue
+			}
+		case "Training bootstrap", "Training data upload", "Training job logs",
+			"Model registry", "Model deployment":
+			// only check the training features once training is set up
+			if getConfigString("training.role_arn") == "" {
+				continue
+			}
+		case "Athena queries":
+			if getConfigString("athena.database") == "" {
+				continue
+			}
+		case "SageMaker models":
+			if modelType != "sagemaker" && awsType != "sagemaker" && llm.ArchEndpoint() == "" {
+				continue
+			}
+		}
+		features = append(features, feature)
+	}
+	return features
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
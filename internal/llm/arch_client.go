@@ -5,29 +5,27 @@ import (
     "os"
 )
 
-// NewArchClientFromEnv attempts to construct a specialised architecture model
-// client (usually a SageMaker endpoint fine-tuned on your infra docs) based on
-// three environment variables:
-//   CLOUDAI_ARCH_ENDPOINT   – SageMaker endpoint name
-//   CLOUDAI_ARCH_REGION     – AWS region (default us-east-1)
-//   CLOUDAI_ARCH_MODEL_ID   – Optional metadata only, used for cost calc & logs
+// NewArchClientFromConfig attempts to construct a specialised architecture
+// model client (usually a SageMaker endpoint fine-tuned on your infra docs)
+// from the arch config section, which 'cloudai sagemaker deploy' writes, or
+// the environment variables that override it:
+//   arch.endpoint / CLOUDAI_ARCH_ENDPOINT   – SageMaker endpoint name
+//   arch.region   / CLOUDAI_ARCH_REGION     – AWS region (default us-east-1)
+//   arch.model_id / CLOUDAI_ARCH_MODEL_ID   – Optional metadata only, used for cost calc & logs
 //
-// If CLOUDAI_ARCH_ENDPOINT is not set the function returns (nil, nil) so callers
-// can treat the absence of a specialised model as non-fatal.
-func NewArchClientFromEnv() (*Client, error) {
-    endpoint := os.Getenv("CLOUDAI_ARCH_ENDPOINT")
+// If no endpoint is set the function returns (nil, nil) so callers can treat
+// the absence of a specialised model as non-fatal.
+func NewArchClientFromConfig() (*Client, error) {
+    endpoint := ArchEndpoint()
     if endpoint == "" {
         return nil, nil
     }
 
-    region := os.Getenv("CLOUDAI_ARCH_REGION")
+    region := archSetting("CLOUDAI_ARCH_REGION", "arch.region")
     if region == "" {
         region = "us-east-1"
     }
-    modelID := os.Getenv("CLOUDAI_ARCH_MODEL_ID")
-    if modelID == "" {
-        modelID = "arch-bot"
-    }
+    modelID := archModelID()
 
     cfg := &AWSModelConfig{
         Type:         AWSModelSageMaker,
@@ -43,12 +41,31 @@ func NewArchClientFromEnv() (*Client, error) {
         return nil, fmt.Errorf("failed to create architecture SageMaker client: %w", err)
     }
 
-    // Use a relaxed daily budget – specialised model, likely infrequent
-    cm := NewCostManager(2.0) // $2/day default
-
+    // Usage counts towards the shared daily budget enforced by the Router
     return &Client{
         useAWS:      true,
         awsClient:   awsClient,
-        costManager: cm,
+        costManager: newCostManagerFromConfig(),
     }, nil
-}
\ No newline at end of file
+}
+
+// ArchEndpoint returns the SageMaker endpoint of the architecture model, or
+// "" when none is configured.
+func ArchEndpoint() string {
+    return archSetting("CLOUDAI_ARCH_ENDPOINT", "arch.endpoint")
+}
+
+func archModelID() string {
+    if modelID := archSetting("CLOUDAI_ARCH_MODEL_ID", "arch.model_id"); modelID != "" {
+        return modelID
+    }
+    return "arch-bot"
+}
+
+// archSetting prefers the environment variable over the config key.
+func archSetting(env, key string) string {
+    if value := os.Getenv(env); value != "" {
+        return value
+    }
+    return getConfigString(key)
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/usage"
+	"github.com/spf13/viper"
+)
+
+// redactionPlaceholder matches the placeholders DataProtector puts in place
+// of sensitive values, capturing the category: [[ARN_3]] is an ARN.
+var redactionPlaceholder = regexp.MustCompile(`\[\[([A-Z][A-Z_]*)_\d+\]\]`)
+
+// RedactionCounts counts the redacted values in a prompt by category.
+func RedactionCounts(prompt string) map[string]int {
+	var counts map[string]int
+	for _, match := range redactionPlaceholder.FindAllStringSubmatch(prompt, -1) {
+		if counts == nil {
+			counts = make(map[string]int)
+		}
+		counts[match[1]]++
+	}
+	return counts
+}
+
+// NewAuditLogFromConfig opens the audit log configured under audit_log:
+//
+//	audit_log:
+//	  enabled: true      # default; false stops recording model calls
+//	  hash_chain: true   # chain entries so edits and deletions are detected
+//	  path: /var/log/cloudai/audit.jsonl   # default ~/.cloudai/audit.jsonl
+func NewAuditLogFromConfig() (*usage.AuditLog, error) {
+	return usage.NewAuditLog(getConfigString("audit_log.path"), viper.GetBool("audit_log.hash_chain"))
+}
+
+// audit records a request that was sent to the model in the audit log. A
+// failure to record it is reported but does not fail the request.
+func (c *Client) audit(operation, prompt string, u Usage, success bool) {
+	if viper.IsSet("audit_log.enabled") && !viper.GetBool("audit_log.enabled") {
+		return
+	}
+	log, err := NewAuditLogFromConfig()
+	if err == nil {
+		err = log.Append(usage.AuditEntry{
+			Time:         time.Now(),
+			Provider:     c.Backend(),
+			Model:        c.Model(),
+			Operation:    operation,
+			Redactions:   RedactionCounts(prompt),
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			Success:      success,
+
+			TokensEstimated: u.estimated,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not write the audit log: %v\n", err)
+	}
+}
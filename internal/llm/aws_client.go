@@ -1,16 +1,22 @@
 package llm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/aws/aws-sdk-go-v2/service/sagemakerruntime"
+	sagemakertypes "github.com/aws/aws-sdk-go-v2/service/sagemakerruntime/types"
+	"github.com/aws/smithy-go"
+	cloudaws "github.com/ddjura/cloudai/internal/aws"
 )
 
 // AWSModelType represents different types of AWS-hosted models
@@ -37,14 +43,18 @@ type AWSClient struct {
 	config          *AWSModelConfig
 	bedrockClient   *bedrockruntime.Client
 	sagemakerClient *sagemakerruntime.Client
+	sagemakerFormat *sageMakerFormat
 	region          string
 }
 
 // NewAWSClient creates a new AWS model client
 func NewAWSClient(modelConfig *AWSModelConfig) (*AWSClient, error) {
-	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(modelConfig.Region))
+	// Load AWS config, pointed at aws.endpoint_url like the scanning clients
+	opts := []cloudaws.Option{cloudaws.WithRegion(modelConfig.Region)}
+	if endpoint := getConfigString("aws.endpoint_url"); endpoint != "" {
+		opts = append(opts, cloudaws.WithEndpoint(endpoint))
+	}
+	cfg, err := cloudaws.LoadConfig(context.Background(), opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -59,7 +69,20 @@ func NewAWSClient(modelConfig *AWSModelConfig) (*AWSClient, error) {
 	case AWSModelBedrock:
 		client.bedrockClient = bedrockruntime.NewFromConfig(cfg)
 	case AWSModelSageMaker:
-		client.sagemakerClient = sagemakerruntime.NewFromConfig(cfg)
+		var options []func(*sagemakerruntime.Options)
+		// a private endpoint is reached through an open SSM tunnel to its
+		// VPC endpoint
+		if t := ActiveTunnel(); t != nil && t.Model == TunnelSageMaker {
+			fmt.Fprintf(os.Stderr, "🔌 Using SageMaker through the SSM tunnel on localhost:%d\n", t.LocalPort)
+			options = append(options, func(o *sagemakerruntime.Options) {
+				o.BaseEndpoint = aws.String("https://" + t.RemoteHost)
+				o.HTTPClient = tunnelHTTPClient(t)
+			})
+		}
+		client.sagemakerClient = sagemakerruntime.NewFromConfig(cfg, options...)
+		if client.sagemakerFormat, err = loadSageMakerFormat(modelConfig.EndpointName); err != nil {
+			return nil, err
+		}
 	case AWSModelOpenAI:
 		// OpenAI through AWS (if configured)
 		client.bedrockClient = bedrockruntime.NewFromConfig(cfg)
@@ -84,6 +107,73 @@ func (c *AWSClient) Generate(ctx context.Context, prompt string) (string, error)
 	}
 }
 
+// GenerateJSON asks the model for JSON matching schema. Bedrock models are
+// forced to call a tool whose input schema is the requested schema; models
+// without tool use, and SageMaker endpoints, fall back to a plain prompt.
+// The token counts Bedrock reports are returned with the reply.
+func (c *AWSClient) GenerateJSON(ctx context.Context, name, prompt string, schema Schema) ([]byte, Usage, error) {
+	if c.bedrockClient == nil {
+		response, err := c.Generate(ctx, prompt)
+		return []byte(response), Usage{}, err
+	}
+
+	resp, err := c.bedrockClient.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId: aws.String(c.config.ModelID),
+		Messages: []bedrocktypes.Message{{
+			Role:    bedrocktypes.ConversationRoleUser,
+			Content: []bedrocktypes.ContentBlock{&bedrocktypes.ContentBlockMemberText{Value: prompt}},
+		}},
+		InferenceConfig: &bedrocktypes.InferenceConfiguration{
+			MaxTokens:   aws.Int32(int32(c.config.MaxTokens)),
+			Temperature: aws.Float32(float32(c.config.Temperature)),
+		},
+		ToolConfig: &bedrocktypes.ToolConfiguration{
+			Tools: []bedrocktypes.Tool{&bedrocktypes.ToolMemberToolSpec{Value: bedrocktypes.ToolSpecification{
+				Name:        aws.String(name),
+				Description: aws.String("Record the " + name + " as structured data."),
+				InputSchema: &bedrocktypes.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(map[string]interface{}(schema))},
+			}}},
+			ToolChoice: &bedrocktypes.ToolChoiceMemberTool{Value: bedrocktypes.SpecificToolChoice{Name: aws.String(name)}},
+		},
+	})
+	if err != nil {
+		// Models without (forced) tool use reject the request up front
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException" {
+			response, err := c.Generate(ctx, prompt)
+			return []byte(response), Usage{}, err
+		}
+		return nil, Usage{}, fmt.Errorf("bedrock converse request failed: %w", err)
+	}
+
+	var reported Usage
+	if resp.Usage != nil {
+		reported.InputTokens = int(aws.ToInt32(resp.Usage.InputTokens))
+		reported.OutputTokens = int(aws.ToInt32(resp.Usage.OutputTokens))
+	}
+	message, ok := resp.Output.(*bedrocktypes.ConverseOutputMemberMessage)
+	if !ok {
+		return nil, Usage{}, fmt.Errorf("bedrock converse returned no message")
+	}
+	var text strings.Builder
+	for _, block := range message.Value.Content {
+		switch b := block.(type) {
+		case *bedrocktypes.ContentBlockMemberToolUse:
+			if b.Value.Input == nil {
+				continue
+			}
+			input, err := b.Value.Input.MarshalSmithyDocument()
+			if err != nil {
+				return nil, Usage{}, fmt.Errorf("failed to read tool input: %w", err)
+			}
+			return input, reported, nil
+		case *bedrocktypes.ContentBlockMemberText:
+			text.WriteString(b.Value)
+		}
+	}
+	return []byte(strings.TrimSpace(text.String())), reported, nil
+}
+
 // generateWithBedrock sends request to AWS Bedrock
 func (c *AWSClient) generateWithBedrock(ctx context.Context, prompt string) (string, error) {
 	// Prepare the request body based on the model
@@ -169,51 +259,70 @@ func (c *AWSClient) generateWithBedrock(ctx context.Context, prompt string) (str
 	return strings.TrimSpace(responseText), nil
 }
 
-// generateWithSageMaker sends request to SageMaker endpoint
+// generateWithSageMaker sends request to SageMaker endpoint, in the format
+// configured for its container
 func (c *AWSClient) generateWithSageMaker(ctx context.Context, prompt string) (string, error) {
-	// Prepare the request body (assuming a standard format)
-	body := map[string]interface{}{
-		"prompt":      prompt,
-		"max_tokens":  c.config.MaxTokens,
-		"temperature": c.config.Temperature,
-	}
-
-	bodyBytes, err := json.Marshal(body)
+	bodyBytes, err := c.sagemakerFormat.body(sageMakerRequest{
+		Prompt:      prompt,
+		MaxTokens:   c.config.MaxTokens,
+		Temperature: c.config.Temperature,
+		Endpoint:    c.config.EndpointName,
+		Model:       c.config.ModelID,
+		Stream:      c.sagemakerFormat.stream,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request body: %w", err)
+		return "", err
+	}
+	var customAttributes *string
+	if c.sagemakerFormat.customAttributes != "" {
+		customAttributes = aws.String(c.sagemakerFormat.customAttributes)
+	}
+	if c.sagemakerFormat.stream {
+		return c.streamFromSageMaker(ctx, bodyBytes, customAttributes)
 	}
 
 	// Send request to SageMaker endpoint
 	resp, err := c.sagemakerClient.InvokeEndpoint(ctx, &sagemakerruntime.InvokeEndpointInput{
-		EndpointName: aws.String(c.config.EndpointName),
-		ContentType:  aws.String("application/json"),
-		Body:         bodyBytes,
+		EndpointName:     aws.String(c.config.EndpointName),
+		ContentType:      aws.String(c.sagemakerFormat.contentType),
+		CustomAttributes: customAttributes,
+		Body:             bodyBytes,
 	})
 	if err != nil {
 		return "", fmt.Errorf("sagemaker request failed: %w", err)
 	}
+	return c.sagemakerFormat.text(resp.Body)
+}
 
-	// Parse response (assuming standard format)
-	var result struct {
-		Response string `json:"response"`
-		Output   string `json:"output"`
-		Text     string `json:"text"`
+// streamFromSageMaker reads the TGI token stream of an endpoint. Payload
+// parts split events arbitrarily, so they are joined before parsing.
+func (c *AWSClient) streamFromSageMaker(ctx context.Context, body []byte, customAttributes *string) (string, error) {
+	resp, err := c.sagemakerClient.InvokeEndpointWithResponseStream(ctx, &sagemakerruntime.InvokeEndpointWithResponseStreamInput{
+		EndpointName:     aws.String(c.config.EndpointName),
+		ContentType:      aws.String(c.sagemakerFormat.contentType),
+		CustomAttributes: customAttributes,
+		Body:             body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("sagemaker request failed: %w", err)
 	}
+	stream := resp.GetStream()
+	defer stream.Close()
 
-	if err := json.Unmarshal(resp.Body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse sagemaker response: %w", err)
+	var events bytes.Buffer
+	for event := range stream.Events() {
+		if part, ok := event.(*sagemakertypes.ResponseStreamMemberPayloadPart); ok {
+			events.Write(part.Value.Bytes)
+		}
 	}
-
-	// Try different response fields
-	responseText := result.Response
-	if responseText == "" {
-		responseText = result.Output
+	if err := stream.Err(); err != nil {
+		return "", fmt.Errorf("sagemaker stream failed: %w", err)
 	}
-	if responseText == "" {
-		responseText = result.Text
+	text, _, err := readTGIGenerateStream(&events)
+	if err != nil {
+		return "", err
 	}
-
-	return strings.TrimSpace(responseText), nil
+	return strings.TrimSpace(text), nil
 }
 
 // generateWithBedrockOpenAI sends request to OpenAI through AWS Bedrock
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBudgetExceeded is returned when a request to a paid backend would take
+// the day's spend past cost.daily_limit.
+var ErrBudgetExceeded = errors.New("daily budget exceeded")
+
+// BudgetGuard enforces cost.daily_limit in front of every remote backend
+// (Bedrock, SageMaker, OpenAI). The limit applies to the combined spend of all
+// backends recorded in ~/.cloudai-cost.json, so switching providers does not
+// reset it. Local Ollama models are never blocked.
+type BudgetGuard struct {
+	costs *CostManager
+}
+
+// NewBudgetGuard creates a guard for the configured daily limit.
+func NewBudgetGuard() *BudgetGuard {
+	return &BudgetGuard{costs: newCostManagerFromConfig()}
+}
+
+// Allow returns an ErrBudgetExceeded error when sending prompt to c is
+// estimated to exceed the remaining budget.
+func (g *BudgetGuard) Allow(c *Client, prompt string) error {
+	if g == nil || !c.IsRemote() {
+		return nil
+	}
+	// Other processes (or the daemon) may have spent since we last looked
+	if err := g.costs.LoadUsage(); err != nil {
+		return fmt.Errorf("could not check the daily budget: %w", err)
+	}
+
+	estimatedCost := c.estimateRequestCost(prompt)
+	if !g.costs.CanMakeRequest(estimatedCost) {
+		return fmt.Errorf("%w (limit $%.2f, remaining $%.2f, estimated cost $%.4f for %s); raise cost.daily_limit or try again tomorrow",
+			ErrBudgetExceeded, g.costs.DailyLimit, g.costs.GetRemainingBudget(), estimatedCost, c.Model())
+	}
+	return nil
+}
+
+// IsRemote reports whether requests leave the machine and cost money.
+func (c *Client) IsRemote() bool {
+	return !c.useOllama && !c.useTGI && !c.useMock
+}
+
+// BudgetUsed returns today's spend across remote backends, as recorded in
+// ~/.cloudai-cost.json, and the cost.daily_limit it counts against; both are
+// 0 when the file cannot be read.
+func BudgetUsed() (spent, limit float64) {
+	costs := newCostManagerFromConfig()
+	if err := costs.LoadUsage(); err != nil {
+		return 0, 0
+	}
+	return costs.CurrentUsage.TotalCost, costs.DailyLimit
+}
@@ -0,0 +1,266 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/usage"
+	"github.com/spf13/viper"
+)
+
+// canaryDefaultReportEvery is how many comparisons pass between the summaries
+// printed while answering
+const canaryDefaultReportEvery = 25
+
+// canaryJudgeContextChars bounds the context the judge sees
+const canaryJudgeContextChars = 12000
+
+// canaryPending counts the comparisons still being judged and logged
+var canaryPending sync.WaitGroup
+
+// WaitCanary blocks until the comparisons started by answered questions are
+// logged; the process should not exit before.
+func WaitCanary() {
+	canaryPending.Wait()
+}
+
+// Canary answers a sample of the questions routed to the architecture model
+// with the general model as well, so the two can be compared before the
+// fine-tune is trusted with every question:
+//
+//	arch:
+//	  canary:
+//	    sample_rate: 0.1     # share of architecture questions to compare; 0 (default) turns it off
+//	    judge: true          # default; a model picks the better answer of each pair
+//	    judge_model: bedrock:anthropic.claude-3-5-sonnet-20240620-v1:0   # default: the general model
+//	    report_every: 25     # print a summary every this many comparisons; 0 never
+//
+// Comparisons are logged to ~/.cloudai/canary.jsonl; 'cloudai canary'
+// tells whether the fine-tune is better. The answer shown is always the
+// one of the model the question was routed to.
+type Canary struct {
+	sampleRate  float64
+	judge       bool
+	judgeModel  string
+	reportEvery int
+}
+
+// NewCanaryFromConfig returns the canary configured under arch.canary, or
+// nil when it is off.
+func NewCanaryFromConfig() *Canary {
+	rate := viper.GetFloat64("arch.canary.sample_rate")
+	if rate <= 0 {
+		return nil
+	}
+	c := &Canary{
+		sampleRate:  rate,
+		judge:       !viper.IsSet("arch.canary.judge") || viper.GetBool("arch.canary.judge"),
+		judgeModel:  getConfigString("arch.canary.judge_model"),
+		reportEvery: canaryDefaultReportEvery,
+	}
+	if viper.IsSet("arch.canary.report_every") {
+		c.reportEvery = viper.GetInt("arch.canary.report_every")
+	}
+	return c
+}
+
+// sample decides whether a question is compared.
+func (c *Canary) sample() bool {
+	return c != nil && rand.Float64() < c.sampleRate
+}
+
+// canaryResult is one model's answer to a compared question.
+type canaryResult struct {
+	answer   string
+	err      error
+	duration time.Duration
+	usage    Usage
+}
+
+// ask answers with client, recording how long it took.
+func ask(ctx context.Context, client *Client, question, context string, call func(*Client, context.Context, string, string) (string, error)) canaryResult {
+	start := time.Now()
+	answer, err := call(client, ctx, question, context)
+	return canaryResult{answer: answer, err: err, duration: time.Since(start), usage: client.LastUsage()}
+}
+
+// recordLater records a comparison in the background, so judging does not
+// delay the answer; see WaitCanary.
+func (c *Canary) recordLater(ctx context.Context, general, arch *Client, pii *PIIDetector, question, data string, archResult, generalResult canaryResult) {
+	ctx = context.WithoutCancel(ctx)
+	canaryPending.Add(1)
+	go func() {
+		defer canaryPending.Done()
+		c.record(ctx, general, arch, pii, question, data, archResult, generalResult)
+	}()
+}
+
+// record judges a comparison, if configured, and appends it to the canary
+// log. Question, context and answers are the scrubbed texts the models saw,
+// protected from personal data as pii does for arch. Failures are reported
+// but never fail the question.
+func (c *Canary) record(ctx context.Context, general, arch *Client, pii *PIIDetector, question, context string, archResult, generalResult canaryResult) {
+	entry := usage.CanaryEntry{
+		Time:       time.Now(),
+		Question:   question,
+		Arch:       canaryAnswer(arch, archResult),
+		General:    canaryAnswer(general, generalResult),
+		Similarity: wordOverlap(archResult.answer, generalResult.answer),
+	}
+	if c.judge && archResult.err == nil && generalResult.err == nil {
+		winner, reason, err := c.judgeAnswers(ctx, general, arch, pii, question, context, archResult.answer, generalResult.answer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Canary: could not judge the answers: %v\n", err)
+		}
+		entry.Winner, entry.Reason = winner, reason
+	}
+
+	log, err := usage.NewCanaryLog()
+	if err == nil {
+		err = log.Append(entry)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Canary: could not write the comparison: %v\n", err)
+		return
+	}
+	if c.reportEvery <= 0 {
+		return
+	}
+	entries, err := log.Load(time.Time{})
+	if err != nil || len(entries)%c.reportEvery != 0 {
+		return
+	}
+	report := usage.SummarizeCanary(entries)
+	fmt.Fprintf(os.Stderr, "📊 Canary: %s won %d, %s won %d, %d ties of %d judged comparisons (cloudai canary)\n",
+		report.Arch.Model, report.Arch.Wins, report.General.Model, report.General.Wins, report.Ties, report.Judged)
+}
+
+func canaryAnswer(client *Client, result canaryResult) usage.CanaryAnswer {
+	answer := usage.CanaryAnswer{
+		Model:      client.Model(),
+		Answer:     result.answer,
+		Refused:    result.err == nil && IsRefusal(result.answer),
+		DurationMs: result.duration.Milliseconds(),
+		Cost:       result.usage.Cost,
+	}
+	if result.err != nil {
+		answer.Error = result.err.Error()
+	}
+	return answer
+}
+
+// judgeAnswers asks the judge model which answer is better. The answers are
+// shown in random order so the judge cannot favour a position or, by
+// recognising its own style, reliably favour itself.
+func (c *Canary) judgeAnswers(ctx context.Context, general, arch *Client, pii *PIIDetector, question, context, archAnswer, generalAnswer string) (string, string, error) {
+	var judge *Client
+	if c.judgeModel != "" {
+		var err error
+		if judge, err = NewClientForModel(c.judgeModel); err != nil {
+			return "", "", err
+		}
+	} else {
+		// The answer path may still be using the general client, so the
+		// judge keeps its own usage
+		copied := *general
+		copied.lastUsage = Usage{}
+		if copied.costManager != nil {
+			copied.costManager = newCostManagerFromConfig()
+		}
+		judge = &copied
+	}
+	// The texts were only checked for personal data if arch needed it
+	if pii.Enabled(judge) && !pii.Enabled(arch) {
+		return "", "", fmt.Errorf("%s would see text not checked for personal data (privacy.pii)", judge.Model())
+	}
+	if err := NewBudgetGuard().Allow(judge, question+context+archAnswer+generalAnswer); err != nil {
+		return "", "", err
+	}
+
+	first, second := archAnswer, generalAnswer
+	archFirst := rand.Intn(2) == 0
+	if !archFirst {
+		first, second = second, first
+	}
+	if runes := []rune(context); len(runes) > canaryJudgeContextChars {
+		context = string(runes[:canaryJudgeContextChars]) + "\n[...]"
+	}
+	raw, err := judge.GenerateStructured(ctx, "canary_judge", buildJudgePrompt(question, context, first, second), judgeSchema())
+	if err != nil {
+		return "", "", err
+	}
+	var verdict struct {
+		Better string `json:"better"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(raw, &verdict); err != nil {
+		return "", "", err
+	}
+	switch {
+	case verdict.Better == "tie":
+		return usage.CanaryTie, verdict.Reason, nil
+	case (verdict.Better == "A") == archFirst:
+		return usage.CanaryArch, verdict.Reason, nil
+	default:
+		return usage.CanaryGeneral, verdict.Reason, nil
+	}
+}
+
+func judgeSchema() Schema {
+	return Schema{
+		"type":     "object",
+		"required": []interface{}{"better", "reason"},
+		"properties": map[string]interface{}{
+			"better": map[string]interface{}{"type": "string", "enum": []interface{}{"A", "B", "tie"}},
+			"reason": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+func buildJudgePrompt(question, context, first, second string) string {
+	return fmt.Sprintf(`You compare two answers to a question about a cloud infrastructure, given the infrastructure data the answers were based on.
+Decide which answer is better: correct according to the data, complete, and specific about the resources involved.
+Placeholders such as [[ARN_1]] stand for redacted values; treat them as the values they replace.
+Answer "tie" when neither is clearly better. Give the reason in one sentence that says what the better answer
+got right or the other got wrong, without calling the answers A or B.
+
+Infrastructure data:
+%s
+
+Question: %s
+
+Answer A:
+%s
+
+Answer B:
+%s`, context, question, first, second)
+}
+
+// wordOverlap is the Jaccard similarity of the answers' lower-cased words.
+func wordOverlap(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := map[string]bool{}
+		for _, word := range strings.Fields(strings.ToLower(s)) {
+			set[strings.Trim(word, ".,;:!?()[]\"'`*")] = true
+		}
+		delete(set, "")
+		return set
+	}
+	left, right := words(a), words(b)
+	if len(left) == 0 && len(right) == 0 {
+		return 1
+	}
+	shared := 0
+	for word := range left {
+		if right[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(left)+len(right)-shared)
+}
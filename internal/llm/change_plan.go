@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ChangeNotice heads every reply to a request to change infrastructure, so
+// no wording of the plan can be read as the change having been made.
+const ChangeNotice = "⛔ cloudai never executes changes: nothing in your infrastructure was created, modified or deleted. Below is a plan for you to review and run yourself."
+
+// ChangePlan is a script the model wrote for a request to change
+// infrastructure, for the user to review and run.
+type ChangePlan struct {
+	Summary string `json:"summary"`
+	// Steps describe the script in order, including what to check before
+	// each change
+	Steps    []string `json:"steps"`
+	Script   string   `json:"script"`
+	Warnings []string `json:"warnings"`
+}
+
+// changeRequestPrefixes are the polite openings of an instruction, removed
+// before looking at its verb
+var changeRequestPrefixes = regexp.MustCompile(`^(?:(?:please|kindly|go ahead and|now|just|cloudai)[,\s]+|(?:can|could|would|will) you(?: please)?\s+|i (?:want|need) you to\s+|let'?s\s+)+`)
+
+// changeVerbs open instructions that change infrastructure. Questions such
+// as "what happens if I delete the bucket" or "can I delete it" open with
+// other words and are left alone.
+var changeVerbs = regexp.MustCompile(`^(?:delete|remove|destroy|terminate|drop|purge|empty|wipe|create|add|provision|launch|deploy|update|change|modify|set|enable|disable|turn (?:on|off)|stop|start|restart|reboot|scale|resize|rotate|attach|detach|rename|tag|untag|grant|revoke|upgrade|downgrade|increase|decrease|apply|roll ?back|migrate|move|encrypt|fix|clean ?up|shut ?down)\b`)
+
+// notChangeOpenings open with a change verb but ask for an explanation,
+// like "start from the API and walk through the flow"
+var notChangeOpenings = regexp.MustCompile(`^start (?:from|with|at|by)\b`)
+
+// IsChangeRequest reports whether a question asks the tool to change
+// infrastructure, like "delete the old buckets" or "could you scale the
+// cluster to 5 nodes", rather than asking about it.
+func IsChangeRequest(question string) bool {
+	lower := strings.ToLower(strings.TrimSpace(question))
+	lower = changeRequestPrefixes.ReplaceAllString(lower, "")
+	return changeVerbs.MatchString(lower) && !notChangeOpenings.MatchString(lower)
+}
+
+// PlanChange asks the general model for a script that makes the change
+// request asks for, using the infrastructure context it would answer from.
+// The request goes through the same redaction, PII and budget checks as
+// Answer. Nothing is run: callers show the plan under ChangeNotice.
+func (r *Router) PlanChange(ctx context.Context, request, context string) (*ChangePlan, error) {
+	raw, err := r.generateProtected(ctx, "change_plan", changePlanSchema(), func(texts []string) string {
+		return buildChangePlanPrompt(texts[0], texts[1])
+	}, request, context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan the change: %w", err)
+	}
+
+	var plan ChangePlan
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return nil, fmt.Errorf("failed to read the change plan: %w", err)
+	}
+	return &plan, nil
+}
+
+func changePlanSchema() Schema {
+	stringList := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	return Schema{
+		"type":     "object",
+		"required": []interface{}{"summary", "steps", "script", "warnings"},
+		"properties": map[string]interface{}{
+			"summary":  map[string]interface{}{"type": "string"},
+			"steps":    stringList,
+			"script":   map[string]interface{}{"type": "string"},
+			"warnings": stringList,
+		},
+	}
+}
+
+func buildChangePlanPrompt(request, context string) string {
+	return fmt.Sprintf(`You are an expert cloud infrastructure assistant that never makes changes itself.
+The user asked for a change to their infrastructure. Write a plan they can review and run themselves.
+
+RULES:
+1. Never say or imply that anything was changed, run or applied. The plan has not been run.
+2. Use only resources from the infrastructure context, named exactly as there. If the request names something
+   the context does not have, or is ambiguous, say so in warnings rather than guessing.
+3. For a resource defined in a CDK or Terraform project (it has DefinedIn or a TerraformType), the change belongs
+   in that code: say which file to edit in the steps and end the script with the deploy or apply command.
+   Otherwise use AWS CLI commands.
+4. Start the script with read-only commands that show the current state of what will change, and use --dry-run
+   or a similar preview where the command supports it. Put a short comment above every command.
+5. The script is bash without a shebang or set options; they are added for it.
+6. Put anything destructive or irreversible, such as deleting data, in warnings along with how to back it up.
+
+--- INFRASTRUCTURE CONTEXT ---
+%s
+--- END CONTEXT ---
+
+Request: %s`, context, request)
+}
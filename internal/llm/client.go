@@ -4,15 +4,31 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer emits spans around model calls. It is a no-op unless a tracer
+// provider has been installed (see cloudai serve).
+var tracer = otel.Tracer("github.com/ddjura/cloudai/internal/llm")
+
+// ErrModelUnavailable wraps every failed request to a model backend, whether
+// the backend could not be reached or rejected the request.
+var ErrModelUnavailable = errors.New("model unavailable")
+
 // Query represents a parsed query with intent and parameters
 type Query struct {
 	Intent   string            `json:"intent"`
@@ -26,11 +42,25 @@ type Query struct {
 type Client struct {
 	useOllama   bool
 	useAWS      bool
+	useMock     bool
 	ollamaModel string
 	ollamaURL   string
-	openai      *openai.Client
-	awsClient   *AWSClient
+	// tunneled is set when ollamaURL is the local end of an SSM tunnel to
+	// a remote host
+	tunneled  bool
+	openai    *openai.Client
+	awsClient *AWSClient
+
+	useTGI   bool
+	tgiURL   string
+	tgiModel string
+
+	openaiModel     string
+	openaiMaxTokens int
+
 	costManager *CostManager
+	lastUsage   Usage
+	parseCache  *ParseCache
 }
 
 // NewClient creates a new LLM client, preferring config file settings, then env vars, then auto-detection
@@ -40,8 +70,16 @@ func NewClient() (*Client, error) {
 		switch modelType {
 		case "aws":
 			return newAWSClientFromConfig()
+		case "sagemaker":
+			return newSageMakerClientFromConfig()
+		case "tgi":
+			return newTGIClientFromConfig()
 		case "ollama":
 			return newOllamaClientFromConfig()
+		case "openai":
+			return newOpenAIClientFromConfig()
+		case "mock":
+			return newMockClient()
 		}
 	}
 
@@ -65,15 +103,11 @@ func newAWSClientFromConfig() (*Client, error) {
 	}
 
 	// Initialize cost manager
-	dailyLimit := getConfigFloat("cost.daily_limit")
-	if dailyLimit == 0 {
-		dailyLimit = 5.0 // Default $5/day
-	}
-	costManager := NewCostManager(dailyLimit)
+	costManager := newCostManagerFromConfig()
 
 	fmt.Fprintf(os.Stderr, "🚀 Using AWS model from config: %s (%s)\n", awsConfig.ModelID, awsConfig.Type)
 	fmt.Fprintf(os.Stderr, "💰 Daily budget: $%.2f (remaining: $%.2f)\n",
-		dailyLimit, costManager.GetRemainingBudget())
+		costManager.DailyLimit, costManager.GetRemainingBudget())
 
 	return &Client{
 		useAWS:      true,
@@ -82,6 +116,42 @@ func newAWSClientFromConfig() (*Client, error) {
 	}, nil
 }
 
+// newSageMakerClientFromConfig creates a client for the SageMaker endpoint
+// in model.endpoint, as 'cloudai setup-interactive' saves it
+func newSageMakerClientFromConfig() (*Client, error) {
+	endpoint := getConfigString("model.endpoint")
+	if endpoint == "" {
+		return nil, fmt.Errorf("no SageMaker endpoint specified in config (model.endpoint)")
+	}
+	region := getConfigString("model.region")
+	if region == "" {
+		region = "us-east-1"
+	}
+	modelID := getConfigString("model.model_id")
+	if modelID == "" {
+		modelID = endpoint
+	}
+
+	awsClient, err := NewAWSClient(&AWSModelConfig{
+		Type:         AWSModelSageMaker,
+		ModelID:      modelID,
+		EndpointName: endpoint,
+		Region:       region,
+		MaxTokens:    4096,
+		Temperature:  0.1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize SageMaker client from config: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "🚀 Using SageMaker endpoint from config: %s\n", endpoint)
+	return &Client{
+		useAWS:      true,
+		awsClient:   awsClient,
+		costManager: newCostManagerFromConfig(),
+	}, nil
+}
+
 // newOllamaClientFromConfig creates Ollama client from configuration
 func newOllamaClientFromConfig() (*Client, error) {
 	ollamaURL := getConfigString("model.url")
@@ -93,16 +163,17 @@ func newOllamaClientFromConfig() (*Client, error) {
 	if ollamaModel == "" {
 		return nil, fmt.Errorf("no Ollama model specified in config")
 	}
+	ollamaURL, tunneled := tunneledOllamaURL(ollamaURL)
 
-	if !isOllamaAvailable(ollamaURL) {
-		return nil, fmt.Errorf("Ollama is not available at %s", ollamaURL)
-	}
-
+	// Ollama is not probed here: a question answered without the model
+	// should not wait for it, and the first request reports it unreachable
 	fmt.Fprintf(os.Stderr, "🖥️  Using local Ollama model from config: %s\n", ollamaModel)
 	return &Client{
 		useOllama:   true,
 		ollamaModel: ollamaModel,
 		ollamaURL:   ollamaURL,
+		tunneled:    tunneled,
+		costManager: newCostManagerFromConfig(),
 	}, nil
 }
 
@@ -115,14 +186,11 @@ func newClientFromEnvAndAutoDetect() (*Client, error) {
 			return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
 		}
 
-		// Use default daily limit for env-configured AWS models
-		costManager := NewCostManager(5.0) // $5/day default
-
 		fmt.Fprintf(os.Stderr, "🚀 Using AWS model: %s (%s)\n", awsConfig.ModelID, awsConfig.Type)
 		return &Client{
 			useAWS:      true,
 			awsClient:   awsClient,
-			costManager: costManager,
+			costManager: newCostManagerFromConfig(),
 		}, nil
 	}
 
@@ -131,10 +199,11 @@ func newClientFromEnvAndAutoDetect() (*Client, error) {
 	if ollamaURL == "" {
 		ollamaURL = "http://localhost:11434"
 	}
+	ollamaURL, tunneled := tunneledOllamaURL(ollamaURL)
 	ollamaModel := os.Getenv("OLLAMA_MODEL")
 
 	// Check if Ollama is running
-	if isOllamaAvailable(ollamaURL) {
+	if OllamaAvailable(ollamaURL) {
 		// If no model is specified, try to load from config or auto-select
 		if ollamaModel == "" {
 			ollamaModel = loadModelFromConfig()
@@ -154,54 +223,95 @@ func newClientFromEnvAndAutoDetect() (*Client, error) {
 			useOllama:   true,
 			ollamaModel: ollamaModel,
 			ollamaURL:   ollamaURL,
+			tunneled:    tunneled,
+			costManager: newCostManagerFromConfig(),
 		}, nil
 	}
 
 	// Fallback to OpenAI
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+	if os.Getenv("OPENAI_API_KEY") == "" {
 		return nil, fmt.Errorf("No model configured. Please run 'cloudai setup-interactive' to configure your AI model")
 	}
-
-	fmt.Fprintf(os.Stderr, "☁️  Using OpenAI model\n")
-	return &Client{
-		useOllama: false,
-		openai:    openai.NewClient(apiKey),
-	}, nil
+	return newOpenAIClientFromConfig()
 }
 
-// isOllamaAvailable checks if Ollama API is reachable
-func isOllamaAvailable(url string) bool {
-	resp, err := http.Get(url + "/api/tags")
+// ParseQuery uses LLM to parse natural language into structured query. The
+// reply is constrained to the query schema; if the model still fails to
+// produce a valid query the intent is "unknown". The question goes through
+// the same redaction and PII checks as Answer, and the parameters of the
+// reply have the redacted values restored.
+func (c *Client) ParseQuery(ctx context.Context, rawQuery string) (*Query, error) {
+	var cacheKey string
+	if c.parseCache != nil {
+		cacheKey = c.parseCacheKey(rawQuery)
+		if q, ok := c.parseCache.Get(cacheKey); ok {
+			q.RawQuery = rawQuery
+			return q, nil
+		}
+	}
+
+	protected := &Router{generalClient: c, protector: NewDataProtector(), pii: NewPIIDetectorFromConfig()}
+	raw, err := protected.generateProtected(ctx, "query", querySchema(), func(texts []string) string {
+		return buildPrompt(texts[0])
+	}, rawQuery)
+	if errors.Is(err, ErrInvalidOutput) {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not parse the question: %v\n", err)
+		return unknownQuery(rawQuery), nil
+	}
 	if err != nil {
-		return false
+		return nil, err
 	}
-	defer resp.Body.Close()
-	return resp.StatusCode == 200
+
+	var q Query
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return unknownQuery(rawQuery), nil
+	}
+	q.RawQuery = rawQuery
+	if q.Params == nil {
+		q.Params = make(map[string]string)
+	}
+	if c.parseCache != nil {
+		c.parseCache.Put(cacheKey, &q)
+	}
+	return &q, nil
 }
 
-// ParseQuery uses LLM to parse natural language into structured query
-func (c *Client) ParseQuery(ctx context.Context, rawQuery string) (*Query, error) {
-	prompt := buildPrompt(rawQuery)
+func unknownQuery(rawQuery string) *Query {
+	return &Query{Intent: "unknown", RawQuery: rawQuery, Params: map[string]string{}}
+}
 
-	if c.useAWS {
-		return c.parseWithAWS(ctx, prompt, rawQuery)
-	} else if c.useOllama {
-		return c.parseWithOllama(ctx, prompt, rawQuery)
-	} else {
-		return c.parseWithOpenAI(ctx, prompt, rawQuery)
-	}
+// intentDescriptions lists the intents the query parser may emit. Intent
+// handlers add themselves through RegisterIntent.
+var (
+	intentMu           sync.RWMutex
+	intentDescriptions = make(map[string]string)
+)
+
+// RegisterIntent makes an intent known to the query parser prompt.
+func RegisterIntent(intent, description string) {
+	intentMu.Lock()
+	defer intentMu.Unlock()
+	intentDescriptions[intent] = description
 }
 
 // buildPrompt creates a system prompt for intent extraction
 func buildPrompt(raw string) string {
+	intentMu.RLock()
+	names := make([]string, 0, len(intentDescriptions))
+	for name := range intentDescriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var intents strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&intents, "- %q for %s\n", name, intentDescriptions[name])
+	}
+	intentMu.RUnlock()
+
 	return `You are an AWS CLI assistant. Parse the following user query into a JSON object with fields: intent, service, action, params (map), and raw_query.
 
 Common intents:
-- "api_gateway_lambda" for queries about which Lambda handles API Gateway requests
-- "lambda_triggers" for queries about what triggers a Lambda function
-- "cost_top" for queries about top cost services
-
+` + intents.String() + `
 Examples:
 Query: "Which Lambda handles GET /users on prod-api?"
 Response: {"intent": "api_gateway_lambda", "service": "apigateway", "action": "get_integration", "params": {"api": "prod-api", "method": "GET", "path": "/users"}, "raw_query": "Which Lambda handles GET /users on prod-api?"}
@@ -215,114 +325,109 @@ Response: {"intent": "cost_top", "service": "costexplorer", "action": "get_cost"
 Now parse this query: ` + raw
 }
 
-// parseWithAWS sends the prompt to the AWS model
-func (c *Client) parseWithAWS(ctx context.Context, prompt, rawQuery string) (*Query, error) {
-	response, err := c.awsClient.Generate(ctx, prompt)
-	if err != nil {
-		return nil, fmt.Errorf("aws model request failed: %w", err)
-	}
-
-	// Try to parse JSON response
-	var q Query
-	if err := json.Unmarshal([]byte(response), &q); err == nil {
-		q.RawQuery = rawQuery
-		return &q, nil
-	}
+// Answer uses the LLM to answer a question based on provided context.
+func (c *Client) Answer(ctx context.Context, question, context string) (string, error) {
+	return c.complete(ctx, "llm.answer", buildRAGPrompt(question, context))
+}
 
-	// Fallback to unknown intent
-	return &Query{Intent: "unknown", RawQuery: rawQuery, Params: map[string]string{}}, nil
+// Phrase uses the LLM only to word the answer to a question that a
+// deterministic lookup has already answered with the given result.
+func (c *Client) Phrase(ctx context.Context, question, result string) (string, error) {
+	return c.complete(ctx, "llm.phrase", buildPhrasingPrompt(question, result))
 }
 
-// parseWithOllama sends the prompt to the local Ollama model
-func (c *Client) parseWithOllama(ctx context.Context, prompt, rawQuery string) (*Query, error) {
-	body := map[string]interface{}{
-		"model":  c.ollamaModel,
-		"prompt": prompt,
-	}
-	b, _ := json.Marshal(body)
-	resp, err := http.Post(c.ollamaURL+"/api/generate", "application/json", bytes.NewReader(b))
-	if err != nil {
-		return nil, fmt.Errorf("ollama request failed: %w", err)
-	}
-	defer resp.Body.Close()
-	var result struct {
-		Response string `json:"response"`
-	}
-	dec := json.NewDecoder(resp.Body)
-	for dec.More() {
-		if err := dec.Decode(&result); err == nil && strings.Contains(result.Response, "intent") {
-			var q Query
-			if err := json.Unmarshal([]byte(result.Response), &q); err == nil {
-				q.RawQuery = rawQuery
-				return &q, nil
-			}
+// complete sends a prompt to the configured backend, enforcing the policy
+// and daily budget and recording usage for LastUsage.
+func (c *Client) complete(ctx context.Context, spanName, prompt string) (answer string, err error) {
+	ctx, span := tracer.Start(ctx, spanName, trace.WithAttributes(
+		attribute.String("llm.backend", c.Backend()),
+		attribute.String("llm.model", c.Model()),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 		}
-	}
-	return &Query{Intent: "unknown", RawQuery: rawQuery, Params: map[string]string{}}, nil
-}
+		span.End()
+	}()
 
-// parseWithOpenAI sends the prompt to OpenAI
-func (c *Client) parseWithOpenAI(ctx context.Context, prompt, rawQuery string) (*Query, error) {
-	req := openai.ChatCompletionRequest{
-		Model:    openai.GPT4o,
-		Messages: []openai.ChatCompletionMessage{{Role: "system", Content: prompt}},
-	}
-	resp, err := c.openai.CreateChatCompletion(ctx, req)
-	if err != nil || len(resp.Choices) == 0 {
-		return &Query{Intent: "unknown", RawQuery: rawQuery, Params: map[string]string{}}, nil
-	}
-	var q Query
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &q); err == nil {
-		q.RawQuery = rawQuery
-		return &q, nil
+	c.lastUsage = Usage{}
+	if err := activePolicy.allow(c, prompt); err != nil {
+		return "", err
 	}
-	return &Query{Intent: "unknown", RawQuery: rawQuery, Params: map[string]string{}}, nil
-}
-
-// Answer uses the LLM to answer a question based on provided context.
-func (c *Client) Answer(ctx context.Context, question, context string) (string, error) {
-	prompt := buildRAGPrompt(question, context)
 
 	var response string
-	var err error
 
+	// Token counts are estimated (~4 chars per token) unless the backend reports them
+	usage := Usage{
+		Backend:     c.Backend(),
+		Model:       c.Model(),
+		InputTokens: len(prompt) / 4,
+	}
+	start := time.Now()
 	if c.useAWS {
-		// Check budget before making request
-		if c.costManager != nil {
-			estimatedCost := c.estimateRequestCost(prompt)
-			if !c.costManager.CanMakeRequest(estimatedCost) {
-				remaining := c.costManager.GetRemainingBudget()
-				return "", fmt.Errorf("daily budget exceeded. Remaining: $%.2f, Estimated cost: $%.2f", remaining, estimatedCost)
-			}
-		}
-
 		response, err = c.awsClient.Generate(ctx, prompt)
-
-		// Track actual usage after successful request
-		if err == nil && c.costManager != nil {
-			// Estimate token usage (rough approximation)
-			inputTokens := len(prompt) / 4 // ~4 chars per token
-			outputTokens := len(response) / 4
-			c.costManager.TrackUsage(inputTokens, outputTokens, c.awsClient.config.ModelID)
-		}
+		usage.OutputTokens = len(response) / 4
+		usage.estimated = true
 	} else if c.useOllama {
-		response, err = c.answerWithOllama(ctx, prompt)
+		var stats ollamaStats
+		response, stats, err = c.answerWithOllama(ctx, prompt)
+		usage.OutputTokens = len(response) / 4
+		if stats.EvalCount > 0 {
+			usage.InputTokens, usage.OutputTokens = stats.PromptEvalCount, stats.EvalCount
+			usage.GenerationTime = time.Duration(stats.EvalDuration)
+		}
+		usage.estimated = stats.EvalCount == 0
+	} else if c.useTGI {
+		var stats tgiStats
+		response, stats, err = c.answerWithTGI(ctx, prompt)
+		usage.OutputTokens = len(response) / 4
+		if stats.PromptTokens > 0 {
+			usage.InputTokens = stats.PromptTokens
+		}
+		if stats.GeneratedTokens > 0 {
+			usage.OutputTokens = stats.GeneratedTokens
+		}
+		usage.estimated = stats.PromptTokens == 0 || stats.GeneratedTokens == 0
+	} else if c.useMock {
+		response = mockComplete(spanName, prompt)
+		usage.OutputTokens = len(response) / 4
+		usage.estimated = true
 	} else {
-		response, err = c.answerWithOpenAI(ctx, prompt)
+		var reported openai.Usage
+		response, reported, err = c.answerWithOpenAI(ctx, prompt)
+		usage.InputTokens, usage.OutputTokens = reported.PromptTokens, reported.CompletionTokens
 	}
-
+	c.audit(strings.TrimPrefix(spanName, "llm."), prompt, usage, err == nil)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %w", ErrModelUnavailable, err)
+	}
+	usage.Latency = time.Since(start)
+	usage.Project = projectOf(ctx)
+	if !c.useOllama && !c.useTGI {
+		usage.Cost = (&CostManager{}).CalculateCost(usage.InputTokens, usage.OutputTokens, usage.Model)
 	}
+	c.lastUsage = usage
 
-	// Post-process the response to make it more user-friendly
-	cleanedResponse := cleanAIResponse(response, context)
-	return cleanedResponse, nil
+	// Track actual usage after successful request; the Router's BudgetGuard
+	// checks it against the daily limit before the next one
+	if c.costManager != nil {
+		if err := c.costManager.TrackUsage(usage); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not record the cost of the request: %v\n", err)
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("llm.input_tokens", usage.InputTokens),
+		attribute.Int("llm.output_tokens", usage.OutputTokens),
+	)
+
+	// Post-process the response as configured in the "answer" section
+	return LoadPostProcessConfig().Apply(response), nil
 }
 
 // estimateRequestCost estimates the cost of a request
 func (c *Client) estimateRequestCost(prompt string) float64 {
-	if c.awsClient == nil {
+	if c.useOllama || c.useTGI || c.useMock {
 		return 0.0
 	}
 
@@ -330,7 +435,7 @@ func (c *Client) estimateRequestCost(prompt string) float64 {
 	inputTokens := len(prompt) / 4
 	outputTokens := 500 // Assume average output length
 
-	modelCost := GetModelCost(c.awsClient.config.ModelID)
+	modelCost := GetModelCost(c.Model())
 	if modelCost == nil {
 		return 0.01 // Default small cost
 	}
@@ -350,13 +455,14 @@ IMPORTANT GUIDELINES:
 1. Always use the most human-friendly, descriptive property available for each resource.
 2. Look for properties that appear to be names, IDs, or descriptions (such as "Name", "ID", "Description", etc.), but do not limit yourself to these—use your best judgment based on the data provided.
 3. If no such property exists, use the most descriptive identifier available.
-4. Never rely on internal logical IDs unless there is no better option.
+4. Refer to a resource by its FriendlyName when it has one. Never rely on internal logical IDs unless there is no better option.
 5. Be specific and actionable in your responses.
 6. If you can't find the answer in the context, say "I cannot answer this based on the provided infrastructure information."
 7. Keep responses concise but informative—aim for 1-3 sentences.
 8. Use bullet points or numbered lists when appropriate for clarity.
 9. Focus on answering the user's question directly—don't over-explain technical details unless specifically asked.
 10. Avoid listing all available resources unless the question specifically asks for them.
+11. When a resource you name has a "DefinedIn" location, say where it is defined, e.g. "defined in lib/api-stack.ts:42".
 
 RESPONSE STYLE:
 - Be direct and to the point
@@ -374,38 +480,73 @@ QUESTION: %s
 Please provide a clear, concise answer using the most human-friendly resource names or descriptions:`, context, question)
 }
 
-func (c *Client) answerWithOllama(ctx context.Context, prompt string) (string, error) {
+// buildPhrasingPrompt creates a prompt that turns a deterministic lookup
+// result into a short answer without letting the model add facts.
+func buildPhrasingPrompt(question, result string) string {
+	return fmt.Sprintf(`You are an expert cloud infrastructure assistant.
+The user's question has already been answered exactly by a lookup against their AWS account.
+Your only task is to present that result as a clear answer.
+
+IMPORTANT GUIDELINES:
+1. Use only the data in the result. Never add resources, numbers or facts that are not in it.
+2. Keep every name, number and amount exactly as given.
+3. If the result is empty, say that nothing matched.
+4. Keep responses concise—aim for 1-3 sentences, using a bullet list when there are several items.
+5. If the result has a verdict, state it first, then the policies and statements it rests on; never change it.
+
+--- LOOKUP RESULT ---
+%s
+--- END RESULT ---
+
+QUESTION: %s
+
+Please provide a clear, concise answer:`, result, question)
+}
+
+// ollamaStats are the timing counters Ollama returns with a non-streamed
+// generation. Durations are in nanoseconds.
+type ollamaStats struct {
+	PromptEvalCount int   `json:"prompt_eval_count"`
+	EvalCount       int   `json:"eval_count"`
+	EvalDuration    int64 `json:"eval_duration"`
+}
+
+func (c *Client) answerWithOllama(ctx context.Context, prompt string) (string, ollamaStats, error) {
 	body := map[string]interface{}{
 		"model":  c.ollamaModel,
 		"prompt": prompt,
 		"stream": false, // We want the full answer at once
 	}
 	b, _ := json.Marshal(body)
-	resp, err := http.Post(c.ollamaURL+"/api/generate", "application/json", bytes.NewReader(b))
+	req, err := newOllamaRequest(ctx, http.MethodPost, c.ollamaURL, "/api/generate", bytes.NewReader(b))
+	if err != nil {
+		return "", ollamaStats{}, err
+	}
+	resp, err := HTTPClient(0).Do(req)
 	if err != nil {
-		return "", fmt.Errorf("ollama request failed: %w", err)
+		return "", ollamaStats{}, fmt.Errorf("Ollama is not available at %s: %w", c.ollamaURL, err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", ollamaStats{}, ollamaStatusError(c.ollamaURL, resp)
+	}
 
 	var result struct {
 		Response string `json:"response"`
+		ollamaStats
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return "", ollamaStats{}, err
 	}
-	return result.Response, nil
+	return result.Response, result.ollamaStats, nil
 }
 
-func (c *Client) answerWithOpenAI(ctx context.Context, prompt string) (string, error) {
-	req := openai.ChatCompletionRequest{
-		Model:    openai.GPT4o,
-		Messages: []openai.ChatCompletionMessage{{Role: "system", Content: prompt}},
-	}
-	resp, err := c.openai.CreateChatCompletion(ctx, req)
+func (c *Client) answerWithOpenAI(ctx context.Context, prompt string) (string, openai.Usage, error) {
+	resp, err := c.openai.CreateChatCompletion(ctx, c.openAIRequest(prompt))
 	if err != nil || len(resp.Choices) == 0 {
-		return "", fmt.Errorf("openai request failed or returned no choices: %w", err)
+		return "", openai.Usage{}, fmt.Errorf("openai request failed or returned no choices: %w", err)
 	}
-	return resp.Choices[0].Message.Content, nil
+	return resp.Choices[0].Message.Content, resp.Usage, nil
 }
 
 // loadModelFromConfig loads the selected model from config file
@@ -473,80 +614,6 @@ func saveModelToConfig(model string) {
 	os.WriteFile(configPath, []byte(strings.Join(lines, "\n")), 0644)
 }
 
-// cleanAIResponse post-processes the AI response to make it more user-friendly
-func cleanAIResponse(response string, context string) string {
-	// Remove common verbose patterns
-	response = strings.TrimSpace(response)
-
-	// Remove overly technical explanations
-	patternsToRemove := []string{
-		"Based on the provided infrastructure context,",
-		"From the context, we know that",
-		"If you have any further questions or if there's anything else I can help you with, please let me know!",
-		"To find out which",
-		"Now, let's analyze",
-		"So, in summary:",
-		"However, since",
-		"It's reasonable to conclude that",
-	}
-
-	for _, pattern := range patternsToRemove {
-		response = strings.ReplaceAll(response, pattern, "")
-	}
-
-	// Clean up multiple newlines
-	response = strings.ReplaceAll(response, "\n\n\n", "\n\n")
-
-	// Remove bullet points that are just technical details
-	lines := strings.Split(response, "\n")
-	var cleanedLines []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Skip overly technical lines
-		if strings.Contains(line, "DemoApi") && strings.Contains(line, "Permission") {
-			continue
-		}
-		if strings.Contains(line, "SourceArn") {
-			continue
-		}
-		if strings.Contains(line, "Action:") && strings.Contains(line, "lambda:InvokeFunction") {
-			continue
-		}
-
-		cleanedLines = append(cleanedLines, line)
-	}
-
-	response = strings.Join(cleanedLines, "\n")
-
-	// If the response is still too long, try to extract the key answer
-	if len(response) > 500 {
-		// Look for the main answer in the first few sentences
-		sentences := strings.Split(response, ".")
-		if len(sentences) > 0 {
-			// Take the first 2-3 sentences that seem to contain the answer
-			var keySentences []string
-			for i, sentence := range sentences {
-				if i >= 3 {
-					break
-				}
-				sentence = strings.TrimSpace(sentence)
-				if sentence != "" && !strings.Contains(sentence, "Based on") && !strings.Contains(sentence, "From the context") {
-					keySentences = append(keySentences, sentence)
-				}
-			}
-			if len(keySentences) > 0 {
-				response = strings.Join(keySentences, ". ") + "."
-			}
-		}
-	}
-
-	return strings.TrimSpace(response)
-}
-
 // Helper functions for configuration
 func getConfigString(key string) string {
 	return viper.GetString(key)
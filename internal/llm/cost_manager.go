@@ -2,9 +2,13 @@ package llm
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
+
+	"github.com/ddjura/cloudai/internal/usage"
 )
 
 // ModelCost represents the cost structure for different AWS models
@@ -18,10 +22,57 @@ type ModelCost struct {
 
 // CostTracker tracks daily usage and costs
 type CostTracker struct {
-	Date         string  `json:"date"`
-	TotalCost    float64 `json:"total_cost"`
-	RequestCount int     `json:"request_count"`
-	TokensUsed   int     `json:"tokens_used"`
+	Date         string                   `json:"date"`
+	TotalCost    float64                  `json:"total_cost"`
+	RequestCount int                      `json:"request_count"`
+	TokensUsed   int                      `json:"tokens_used"`
+	Backends     map[string]*BackendUsage `json:"backends,omitempty"`
+	Projects     map[string]*ProjectUsage `json:"projects,omitempty"`
+}
+
+// ProjectUsage is one project's share of the daily usage, keyed by the
+// project's cache path; "" holds requests made outside a project.
+type ProjectUsage struct {
+	Project      string  `json:"project"`
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// BackendUsage is one backend/model's share of the daily usage. Latency is
+// the wall-clock time of the requests; GenerationTime is the time the model
+// spent producing output, when the backend reports it (Ollama does).
+type BackendUsage struct {
+	Backend        string        `json:"backend"`
+	Model          string        `json:"model"`
+	Requests       int           `json:"requests"`
+	InputTokens    int           `json:"input_tokens"`
+	OutputTokens   int           `json:"output_tokens"`
+	Cost           float64       `json:"cost"`
+	Latency        time.Duration `json:"latency"`
+	GenerationTime time.Duration `json:"generation_time,omitempty"`
+}
+
+// AvgLatency returns the mean request latency.
+func (b *BackendUsage) AvgLatency() time.Duration {
+	if b.Requests == 0 {
+		return 0
+	}
+	return b.Latency / time.Duration(b.Requests)
+}
+
+// TokensPerSecond returns the output throughput, measured over the
+// generation time when known and over the request latency otherwise.
+func (b *BackendUsage) TokensPerSecond() float64 {
+	elapsed := b.GenerationTime
+	if elapsed == 0 {
+		elapsed = b.Latency
+	}
+	if elapsed == 0 {
+		return 0
+	}
+	return float64(b.OutputTokens) / elapsed.Seconds()
 }
 
 // CostManager manages cost tracking and limits
@@ -63,6 +114,18 @@ var ModelCosts = []ModelCost{
 	},
 }
 
+// OpenAI model costs (list prices, approximate). Kept apart from ModelCosts so
+// SelectBestAWSModel only ever picks Bedrock models.
+var OpenAIModelCosts = []ModelCost{
+	{ModelID: "gpt-4o", InputTokenCost: 0.0025, OutputTokenCost: 0.01, Speed: 8, Quality: 9},
+	{ModelID: "gpt-4o-mini", InputTokenCost: 0.00015, OutputTokenCost: 0.0006, Speed: 9, Quality: 7},
+	{ModelID: "gpt-4.1", InputTokenCost: 0.002, OutputTokenCost: 0.008, Speed: 8, Quality: 9},
+	{ModelID: "gpt-4.1-mini", InputTokenCost: 0.0004, OutputTokenCost: 0.0016, Speed: 9, Quality: 8},
+	{ModelID: "gpt-4.1-nano", InputTokenCost: 0.0001, OutputTokenCost: 0.0004, Speed: 10, Quality: 6},
+	{ModelID: "o3-mini", InputTokenCost: 0.0011, OutputTokenCost: 0.0044, Speed: 6, Quality: 9},
+	{ModelID: "o4-mini", InputTokenCost: 0.0011, OutputTokenCost: 0.0044, Speed: 6, Quality: 9},
+}
+
 // NewCostManager creates a new cost manager
 func NewCostManager(dailyLimit float64) *CostManager {
 	home, _ := os.UserHomeDir()
@@ -77,52 +140,68 @@ func NewCostManager(dailyLimit float64) *CostManager {
 	return cm
 }
 
-// LoadUsage loads current usage from disk
-func (cm *CostManager) LoadUsage() {
+// newCostManagerFromConfig creates a cost manager with the DailyLimit budget.
+func newCostManagerFromConfig() *CostManager {
+	return NewCostManager(DailyLimit())
+}
+
+// DailyLimit returns the cost.daily_limit budget, capped by the policy's
+// max_daily_cost.
+func DailyLimit() float64 {
+	dailyLimit := getConfigFloat("cost.daily_limit")
+	if dailyLimit == 0 {
+		dailyLimit = 5.0 // Default $5/day
+	}
+	return activePolicy.capDailyLimit(dailyLimit)
+}
+
+// LoadUsage loads current usage from disk. A file that cannot be read or
+// parsed is an error rather than no spend, which would lift the daily limit.
+func (cm *CostManager) LoadUsage() error {
+	today := time.Now().Format("2006-01-02")
 	data, err := os.ReadFile(cm.configPath)
+	if os.IsNotExist(err) {
+		cm.CurrentUsage = CostTracker{Date: today}
+		return nil
+	}
 	if err != nil {
-		// Initialize with today's date
-		cm.CurrentUsage = CostTracker{
-			Date:         time.Now().Format("2006-01-02"),
-			TotalCost:    0,
-			RequestCount: 0,
-			TokensUsed:   0,
-		}
-		return
+		return fmt.Errorf("could not read %s: %w", cm.configPath, err)
 	}
 
 	var usage CostTracker
 	if err := json.Unmarshal(data, &usage); err != nil {
-		cm.CurrentUsage = CostTracker{
-			Date:         time.Now().Format("2006-01-02"),
-			TotalCost:    0,
-			RequestCount: 0,
-			TokensUsed:   0,
-		}
-		return
+		return fmt.Errorf("could not parse %s: %w", cm.configPath, err)
 	}
 
 	// Reset if it's a new day
-	today := time.Now().Format("2006-01-02")
 	if usage.Date != today {
-		cm.CurrentUsage = CostTracker{
-			Date:         today,
-			TotalCost:    0,
-			RequestCount: 0,
-			TokensUsed:   0,
-		}
+		cm.CurrentUsage = CostTracker{Date: today}
 	} else {
 		cm.CurrentUsage = usage
 	}
+	return nil
 }
 
-// SaveUsage saves current usage to disk
+// SaveUsage saves current usage to disk. The file is written aside and
+// renamed into place, so readers never see it half-written.
 func (cm *CostManager) SaveUsage() error {
 	data, err := json.MarshalIndent(cm.CurrentUsage, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(cm.configPath, data, 0644)
+	tmp, err := os.CreateTemp(filepath.Dir(cm.configPath), filepath.Base(cm.configPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cm.configPath)
 }
 
 // CanMakeRequest checks if a request can be made within budget
@@ -130,27 +209,71 @@ func (cm *CostManager) CanMakeRequest(estimatedCost float64) bool {
 	return cm.CurrentUsage.TotalCost+estimatedCost <= cm.DailyLimit
 }
 
-// TrackUsage records usage after a request
-func (cm *CostManager) TrackUsage(inputTokens, outputTokens int, modelID string) error {
-	cost := cm.CalculateCost(inputTokens, outputTokens, modelID)
+// costMu and the lock file next to the cost file serialize TrackUsage
+// within this process and across processes.
+var costMu sync.Mutex
+
+// TrackUsage records usage after a request. The file is re-read first, with
+// the lock held, so several clients (the router's, the questions 'cloudai
+// serve' answers at once, or other processes) add up instead of overwriting
+// each other.
+func (cm *CostManager) TrackUsage(u Usage) error {
+	costMu.Lock()
+	defer costMu.Unlock()
+	unlock, err := usage.LockFile(cm.configPath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := cm.LoadUsage(); err != nil {
+		return err
+	}
 
-	cm.CurrentUsage.TotalCost += cost
+	cm.CurrentUsage.TotalCost += u.Cost
 	cm.CurrentUsage.RequestCount++
-	cm.CurrentUsage.TokensUsed += inputTokens + outputTokens
+	cm.CurrentUsage.TokensUsed += u.InputTokens + u.OutputTokens
+
+	if cm.CurrentUsage.Backends == nil {
+		cm.CurrentUsage.Backends = make(map[string]*BackendUsage)
+	}
+	key := u.Backend + "/" + u.Model
+	b := cm.CurrentUsage.Backends[key]
+	if b == nil {
+		b = &BackendUsage{Backend: u.Backend, Model: u.Model}
+		cm.CurrentUsage.Backends[key] = b
+	}
+	b.Requests++
+	b.InputTokens += u.InputTokens
+	b.OutputTokens += u.OutputTokens
+	b.Cost += u.Cost
+	b.Latency += u.Latency
+	b.GenerationTime += u.GenerationTime
+
+	if cm.CurrentUsage.Projects == nil {
+		cm.CurrentUsage.Projects = make(map[string]*ProjectUsage)
+	}
+	p := cm.CurrentUsage.Projects[u.Project]
+	if p == nil {
+		p = &ProjectUsage{Project: u.Project}
+		cm.CurrentUsage.Projects[u.Project] = p
+	}
+	p.Requests++
+	p.InputTokens += u.InputTokens
+	p.OutputTokens += u.OutputTokens
+	p.Cost += u.Cost
 
 	return cm.SaveUsage()
 }
 
 // CalculateCost calculates the cost for a request
 func (cm *CostManager) CalculateCost(inputTokens, outputTokens int, modelID string) float64 {
-	for _, model := range ModelCosts {
-		if model.ModelID == modelID {
-			inputCost := float64(inputTokens) / 1000.0 * model.InputTokenCost
-			outputCost := float64(outputTokens) / 1000.0 * model.OutputTokenCost
-			return inputCost + outputCost
-		}
+	model := GetModelCost(modelID)
+	if model == nil {
+		return 0.0 // Unknown model
 	}
-	return 0.0 // Unknown model
+	inputCost := float64(inputTokens) / 1000.0 * model.InputTokenCost
+	outputCost := float64(outputTokens) / 1000.0 * model.OutputTokenCost
+	return inputCost + outputCost
 }
 
 // GetRemainingBudget returns the remaining daily budget
@@ -229,5 +352,10 @@ func GetModelCost(modelID string) *ModelCost {
 			return &model
 		}
 	}
+	for _, model := range OpenAIModelCosts {
+		if model.ModelID == modelID {
+			return &model
+		}
+	}
 	return nil
 }
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"os"
+	"strings"
+)
+
+// EstimatedOutputTokens is the assumed answer length used for cost previews.
+const EstimatedOutputTokens = 500
+
+// ModelRef identifies a backend and model without connecting to it.
+type ModelRef struct {
+	Backend string `json:"backend"`
+	Model   string `json:"model"`
+	Role    string `json:"role"` // "general" or "architecture"
+}
+
+// EstimateTokens approximates the token count of text at ~4 characters per token.
+func EstimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// EstimatePromptTokens returns the approximate input size of the prompt that
+// Answer would send for the given question and context.
+func EstimatePromptTokens(question, context string) int {
+	return EstimateTokens(buildRAGPrompt(question, context))
+}
+
+// contextWindows are approximate context window sizes in tokens by model ID
+// prefix.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"anthropic.claude", 200000},
+	{"amazon.titan-text-express", 8000},
+	{"meta.llama3", 128000},
+	{"gpt-4.1", 1000000},
+	{"gpt-4o", 128000},
+	{"o3", 200000},
+	{"o4", 200000},
+}
+
+// ollamaContextWindow is the context Ollama serves models with unless the
+// model's num_ctx parameter raises it. TGI's default --max-total-tokens is
+// the same.
+const ollamaContextWindow = 4096
+
+// ContextWindow returns the approximate context window of a model in tokens,
+// or 0 when it is not known.
+func ContextWindow(ref ModelRef) int {
+	if ref.Backend == "ollama" || ref.Backend == "tgi" {
+		return ollamaContextWindow
+	}
+	for _, window := range contextWindows {
+		if strings.HasPrefix(ref.Model, window.prefix) {
+			return window.tokens
+		}
+	}
+	return 0
+}
+
+// EstimateCost returns the estimated cost of a request to modelID, and false
+// when no pricing is known for it (local and self-hosted models are free).
+func EstimateCost(ref ModelRef, inputTokens, outputTokens int) (float64, bool) {
+	if ref.Backend == "ollama" || ref.Backend == "tgi" || ref.Backend == "mock" {
+		return 0, true
+	}
+	if GetModelCost(ref.Model) == nil {
+		return 0, false
+	}
+	return (&CostManager{}).CalculateCost(inputTokens, outputTokens, ref.Model), true
+}
+
+// ConfiguredModels resolves which models a query would use, following the
+// same precedence as NewClient and NewArchClientFromConfig, but without
+// creating clients or probing any endpoint.
+func ConfiguredModels() []ModelRef {
+	var refs []ModelRef
+
+	switch getConfigString("model.type") {
+	case "aws":
+		refs = append(refs, ModelRef{Backend: getConfigString("model.aws_type"), Model: getConfigString("model.model_id"), Role: "general"})
+	case "ollama":
+		refs = append(refs, ModelRef{Backend: "ollama", Model: getConfigString("model.name"), Role: "general"})
+	case "openai":
+		refs = append(refs, ModelRef{Backend: "openai", Model: configuredOpenAIModel(), Role: "general"})
+	case "tgi":
+		refs = append(refs, ModelRef{Backend: "tgi", Model: getConfigString("model.name"), Role: "general"})
+	case "mock":
+		refs = append(refs, ModelRef{Backend: "mock", Model: mockModel, Role: "general"})
+	default:
+		if awsConfig := LoadAWSModelFromConfig(); awsConfig != nil {
+			refs = append(refs, ModelRef{Backend: string(awsConfig.Type), Model: awsConfig.ModelID, Role: "general"})
+		} else if model := os.Getenv("OLLAMA_MODEL"); model != "" {
+			refs = append(refs, ModelRef{Backend: "ollama", Model: model, Role: "general"})
+		} else if model := loadModelFromConfig(); model != "" {
+			refs = append(refs, ModelRef{Backend: "ollama", Model: model, Role: "general"})
+		} else if os.Getenv("OPENAI_API_KEY") != "" {
+			refs = append(refs, ModelRef{Backend: "openai", Model: configuredOpenAIModel(), Role: "general"})
+		}
+	}
+
+	if ArchEndpoint() != "" {
+		refs = append(refs, ModelRef{Backend: string(AWSModelSageMaker), Model: archModelID(), Role: "architecture"})
+	}
+	return refs
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrNoJSON is returned by ExtractJSON when a reply contains no JSON object.
+var ErrNoJSON = errors.New("no JSON object found in model reply")
+
+// ExtractJSON finds the JSON object in a free-text model reply. It strips
+// markdown code fences, takes the first balanced {...} object and, when that
+// is not valid JSON, repairs the usual model mistakes: trailing commas,
+// single-quoted or curly-quoted strings, Python literals (True, False, None)
+// and closing braces lost to a truncated reply.
+func ExtractJSON(reply string) (string, error) {
+	text := stripCodeFence(reply)
+
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return "", ErrNoJSON
+	}
+	candidate := balancedObject(text[start:])
+	if json.Valid([]byte(candidate)) {
+		return candidate, nil
+	}
+
+	repaired := repairJSON(candidate)
+	if !json.Valid([]byte(repaired)) {
+		return "", ErrNoJSON
+	}
+	return repaired, nil
+}
+
+// stripCodeFence returns the body of the first ``` fenced block, or the text
+// unchanged when there is none.
+func stripCodeFence(text string) string {
+	open := strings.Index(text, "```")
+	if open < 0 {
+		return text
+	}
+	body := text[open+3:]
+	// drop the language tag, e.g. ```json
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 && !strings.Contains(body[:nl], "{") {
+		body = body[nl+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// balancedObject returns the prefix of s (which starts with '{') up to the
+// brace that closes it. String literals in either quote style are skipped.
+// If the object never closes, the missing braces and brackets are appended.
+func balancedObject(s string) string {
+	var stack []byte
+	var quote rune
+	escaped := false
+	for i, r := range s {
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote || (quote == '“' && r == '”'):
+				quote = 0
+			}
+			continue
+		}
+		switch r {
+		case '"', '\'', '“':
+			quote = r
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				return s[:i+1]
+			}
+		}
+	}
+
+	// Truncated reply: close an open string, then every open container
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(s, " \t\r\n,"))
+	if quote != 0 {
+		b.WriteRune(quote)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteByte(stack[i])
+	}
+	return b.String()
+}
+
+// repairJSON rewrites near-JSON into JSON: strings become double-quoted,
+// Python literals become JSON literals and trailing commas are dropped.
+func repairJSON(s string) string {
+	var out strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"' || r == '\'' || r == '“':
+			closing := r
+			if r == '“' {
+				closing = '”'
+			}
+			out.WriteByte('"')
+			for i++; i < len(runes) && runes[i] != closing; i++ {
+				c := runes[i]
+				switch {
+				case c == '\\' && i+1 < len(runes):
+					i++
+					if runes[i] == '\'' {
+						out.WriteRune('\'')
+					} else {
+						out.WriteRune('\\')
+						out.WriteRune(runes[i])
+					}
+				case c == '"':
+					out.WriteString(`\"`)
+				case c == '\n':
+					out.WriteString(`\n`)
+				default:
+					out.WriteRune(c)
+				}
+			}
+			out.WriteByte('"')
+		case r == ',':
+			// drop the comma if the next non-space character closes a container
+			j := i + 1
+			for j < len(runes) && strings.ContainsRune(" \t\r\n", runes[j]) {
+				j++
+			}
+			if j < len(runes) && (runes[j] == '}' || runes[j] == ']') {
+				continue
+			}
+			out.WriteRune(r)
+		case hasWordAt(runes, i, "True"):
+			out.WriteString("true")
+			i += len("True") - 1
+		case hasWordAt(runes, i, "False"):
+			out.WriteString("false")
+			i += len("False") - 1
+		case hasWordAt(runes, i, "None"):
+			out.WriteString("null")
+			i += len("None") - 1
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// hasWordAt reports whether word starts at runes[i] as a whole word.
+func hasWordAt(runes []rune, i int, word string) bool {
+	w := []rune(word)
+	if i+len(w) > len(runes) || string(runes[i:i+len(w)]) != word {
+		return false
+	}
+	isIdent := func(r rune) bool {
+		return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	}
+	if i > 0 && isIdent(runes[i-1]) {
+		return false
+	}
+	return i+len(w) == len(runes) || !isIdent(runes[i+len(w)])
+}
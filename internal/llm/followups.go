@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxFollowUps is how many suggested follow-up questions are kept
+const maxFollowUps = 3
+
+// maxFollowUpContext caps the characters of context sent with the follow-up
+// request; the suggestions only need the resource names, not every property.
+const maxFollowUpContext = 12000
+
+// FollowUps asks the general model for up to three follow-up questions
+// grounded in the context an answer was given from. The request goes through
+// the same redaction, PII and budget checks as Answer, and LastUsage returns
+// its usage afterwards, so callers record the answer's usage first.
+func (r *Router) FollowUps(ctx context.Context, question, answer, context string) ([]string, error) {
+	// Scrub before cutting, so no value is cut short of its pattern
+	context = r.protector.Scrub(context)
+	if runes := []rune(context); len(runes) > maxFollowUpContext {
+		context = string(runes[:maxFollowUpContext])
+	}
+	client := r.generalClient
+	texts, err := r.pii.Protect(ctx, client, r.protector, r.protector.Scrub(question), r.protector.Scrub(answer), context)
+	if err != nil {
+		return nil, err
+	}
+	prompt := buildFollowUpPrompt(texts[0], texts[1], texts[2])
+
+	r.lastClient = client
+	if err := r.budget.Allow(client, prompt); err != nil {
+		client.lastUsage = Usage{} // nothing was sent
+		return nil, err
+	}
+	text, err := client.complete(ctx, "llm.followups", prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest follow-up questions: %w", err)
+	}
+
+	var questions []string
+	for _, line := range parseFollowUps(text) {
+		questions = append(questions, r.protector.Unscrub(line))
+	}
+	return questions, nil
+}
+
+// parseFollowUps extracts the questions from the model's reply, dropping
+// list markers and anything that is not a question.
+func parseFollowUps(text string) []string {
+	var questions []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "-*•0123456789.) ")
+		line = strings.Trim(line, `"`)
+		if !strings.HasSuffix(line, "?") {
+			continue
+		}
+		questions = append(questions, line)
+		if len(questions) == maxFollowUps {
+			break
+		}
+	}
+	return questions
+}
+
+func buildFollowUpPrompt(question, answer, context string) string {
+	return fmt.Sprintf(`You are an expert cloud infrastructure assistant helping someone explore an unfamiliar AWS account.
+They asked a question and got the answer below. Suggest 2-3 short follow-up questions they are likely to ask next.
+
+IMPORTANT GUIDELINES:
+1. Every question must be answerable from the context: refer to resources, services and settings that appear in it.
+2. Refer to resources by the names used in the context.
+3. Do not repeat the original question.
+4. Write one question per line, with no numbering, introduction or explanation.
+
+--- INFRASTRUCTURE CONTEXT ---
+%s
+--- END CONTEXT ---
+
+QUESTION: %s
+
+ANSWER: %s
+
+Follow-up questions:`, context, question, answer)
+}
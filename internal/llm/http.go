@@ -0,0 +1,205 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	transportOnce sync.Once
+	transport     *http.Transport
+)
+
+// HTTPTransport is shared by every outbound request, so connections are kept
+// alive between the parse, answer and follow-up calls of a question. It is
+// set up from the http.* config keys on first use:
+//
+//	http:
+//	  ca_bundle: /etc/ssl/corp-ca.pem   # extra CAs, e.g. of a TLS-inspecting proxy
+//	  insecure_skip_verify: false       # do not verify certificates at all
+//
+// CLOUDAI_CA_BUNDLE takes precedence over http.ca_bundle. Proxies come from
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func HTTPTransport() *http.Transport {
+	transportOnce.Do(func() {
+		transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       outboundTLSConfig(),
+			MaxIdleConns:          10,
+			MaxIdleConnsPerHost:   4,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+			// A non-streamed generation sends its headers only once the
+			// whole answer is ready, which can take minutes for a large
+			// model on CPU.
+			ResponseHeaderTimeout: 5 * time.Minute,
+		}
+	})
+	return transport
+}
+
+// HTTPClient returns a client on the shared transport. A zero timeout
+// leaves requests to be bounded by their context.
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: HTTPTransport(), Timeout: timeout}
+}
+
+// outboundTLSConfig adds the configured CA bundle to the system roots. A
+// bundle that cannot be used is reported and the system roots are kept.
+func outboundTLSConfig() *tls.Config {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if viper.GetBool("http.insecure_skip_verify") {
+		fmt.Fprintln(os.Stderr, "⚠️  http.insecure_skip_verify is set: TLS certificates are not verified")
+		config.InsecureSkipVerify = true
+		return config
+	}
+
+	bundle := os.Getenv("CLOUDAI_CA_BUNDLE")
+	if bundle == "" {
+		bundle = getConfigString("http.ca_bundle")
+	}
+	if bundle == "" {
+		return config
+	}
+	pem, err := os.ReadFile(bundle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not read CA bundle %s: %v\n", bundle, err)
+		return config
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		fmt.Fprintf(os.Stderr, "⚠️  CA bundle %s has no PEM certificates\n", bundle)
+		return config
+	}
+	config.RootCAs = pool
+	return config
+}
+
+// ollamaProbeTimeout bounds availability checks and model lists, which a
+// running Ollama answers at once.
+const ollamaProbeTimeout = 3 * time.Second
+
+var plainAuthWarning sync.Once
+
+// newModelRequest builds a request to a self-hosted model server, Ollama or
+// TGI, at baseURL. Neither has authentication of its own, but one behind a
+// reverse proxy on EC2 or Kubernetes is reached over HTTPS with the
+// credentials the proxy expects:
+//
+//	model:
+//	  url: https://ollama.example.com
+//	  auth:
+//	    bearer_token: ...   # Authorization: Bearer
+//	    username: ...       # or HTTP basic auth
+//	    password: ...
+//	  headers:              # any other header the proxy checks
+//	    X-Api-Key: ...
+//
+// The tokenEnv environment variable, such as CLOUDAI_OLLAMA_TOKEN, takes
+// precedence over model.auth.bearer_token.
+func newModelRequest(ctx context.Context, method, baseURL, path string, body io.Reader, tokenEnv string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, value := range viper.GetStringMapString("model.headers") {
+		req.Header.Set(name, value)
+	}
+	token := os.Getenv(tokenEnv)
+	if token == "" {
+		token = getConfigString("model.auth.bearer_token")
+	}
+	username := getConfigString("model.auth.username")
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case username != "":
+		req.SetBasicAuth(username, getConfigString("model.auth.password"))
+	}
+
+	if req.Header.Get("Authorization") != "" && req.URL.Scheme == "http" && !isLoopbackURL(baseURL) {
+		plainAuthWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "⚠️  Model credentials are sent unencrypted to %s; use an https:// model.url\n", req.URL.Host)
+		})
+	}
+	return req, nil
+}
+
+// newOllamaRequest builds a request to the Ollama API at baseURL.
+func newOllamaRequest(ctx context.Context, method, baseURL, path string, body io.Reader) (*http.Request, error) {
+	return newModelRequest(ctx, method, baseURL, path, body, "CLOUDAI_OLLAMA_TOKEN")
+}
+
+// ollamaStatusError describes a failed Ollama response, pointing at the
+// credentials when a proxy in front of Ollama rejects them.
+func ollamaStatusError(baseURL string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("Ollama at %s rejected the request (%s): check model.auth and model.headers", baseURL, resp.Status)
+	}
+	return fmt.Errorf("Ollama API returned status %d", resp.StatusCode)
+}
+
+// OllamaAvailable checks if the Ollama API at url is reachable
+func OllamaAvailable(url string) bool {
+	req, err := newOllamaRequest(context.Background(), http.MethodGet, url, "/api/tags", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := HTTPClient(ollamaProbeTimeout).Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// OllamaModels fetches the list of models installed in Ollama
+func OllamaModels(ollamaURL string) ([]AvailableModel, error) {
+	req, err := newOllamaRequest(context.Background(), http.MethodGet, ollamaURL, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := HTTPClient(ollamaProbeTimeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ollamaStatusError(ollamaURL, resp)
+	}
+
+	var result struct {
+		Models []AvailableModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
+	}
+
+	return result.Models, nil
+}
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PolicyDraft is an IAM policy the model wrote for a request in plain
+// language.
+type PolicyDraft struct {
+	// Principal is the resource the policy is for, as named in the
+	// resource list, e.g. the Lambda function whose role it is attached to
+	Principal   string           `json:"principal"`
+	Statements  []PolicyDraftRow `json:"statements"`
+	Assumptions []string         `json:"assumptions"`
+}
+
+// PolicyDraftRow is a statement of a PolicyDraft.
+type PolicyDraftRow struct {
+	Sid       string   `json:"sid"`
+	Effect    string   `json:"effect"`
+	Actions   []string `json:"actions"`
+	Resources []string `json:"resources"`
+	Reason    string   `json:"reason"`
+}
+
+// DraftPolicy asks the general model for a least-privilege IAM policy that
+// does what request asks, using only the resources listed in resources.
+// The request goes through the same redaction, PII and budget checks as
+// Answer; ARNs reach the model as placeholders, which are restored in the
+// policy it returns. The draft is never applied; callers review it.
+func (r *Router) DraftPolicy(ctx context.Context, request, resources string) (*PolicyDraft, error) {
+	raw, err := r.generateProtected(ctx, "nl2policy", policyDraftSchema(), func(texts []string) string {
+		return buildPolicyPrompt(texts[0], texts[1])
+	}, request, resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to draft the policy: %w", err)
+	}
+
+	var draft PolicyDraft
+	if err := json.Unmarshal(raw, &draft); err != nil {
+		return nil, fmt.Errorf("failed to read the drafted policy: %w", err)
+	}
+	return &draft, nil
+}
+
+func policyDraftSchema() Schema {
+	stringList := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	return Schema{
+		"type":     "object",
+		"required": []interface{}{"principal", "statements", "assumptions"},
+		"properties": map[string]interface{}{
+			"principal": map[string]interface{}{"type": "string"},
+			"statements": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"sid", "effect", "actions", "resources", "reason"},
+					"properties": map[string]interface{}{
+						"sid":       map[string]interface{}{"type": "string"},
+						"effect":    map[string]interface{}{"type": "string", "enum": []interface{}{"Allow", "Deny"}},
+						"actions":   stringList,
+						"resources": stringList,
+						"reason":    map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+			"assumptions": stringList,
+		},
+	}
+}
+
+func buildPolicyPrompt(request, resources string) string {
+	return fmt.Sprintf(`You are an AWS IAM expert writing least-privilege identity policies.
+Write the IAM policy statements that grant exactly what the request below asks for.
+
+RULES:
+1. Use only ARNs from the resource list, exactly as written there. Add a suffix only to reach what is inside a
+   resource: /* for the objects of a bucket, /index/* for the indexes of a table, /stream/* for its streams.
+2. List the specific actions needed, e.g. dynamodb:GetItem and dynamodb:Query for reading a table. Never use * or
+   service:* as an action and never use * as a resource unless the action supports no resource-level permissions.
+3. Group actions on the same resources into one statement with a short PascalCase sid and a one-line reason.
+4. principal is the resource from the list that gets the policy, e.g. the Lambda function or role.
+5. Put anything you had to assume, or any part of the request no listed resource matches, in assumptions.
+
+--- RESOURCES (logical ID | type | name | ARN) ---
+%s
+--- END RESOURCES ---
+
+Request: %s`, resources, request)
+}
@@ -0,0 +1,293 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// mockModel is the model name the mock backend reports.
+const mockModel = "mock"
+
+// maxMockItems caps the resources and result items a mock answer lists.
+const maxMockItems = 10
+
+// Markers the prompts put around the context, used to find it again.
+const (
+	contextStart = "--- INFRASTRUCTURE CONTEXT ---"
+	contextEnd   = "--- END CONTEXT ---"
+	resultStart  = "--- LOOKUP RESULT ---"
+	resultEnd    = "--- END RESULT ---"
+)
+
+// mockStopWords are question words that never name a resource.
+var mockStopWords = map[string]bool{
+	"the": true, "and": true, "are": true, "any": true, "all": true, "for": true, "how": true,
+	"many": true, "what": true, "which": true, "who": true, "does": true, "with": true,
+	"have": true, "has": true, "there": true, "list": true, "show": true, "from": true,
+	"that": true, "this": true, "use": true, "can": true, "our": true, "everything": true,
+	"resource": true, "exist": true, "account": true,
+}
+
+// newMockClient creates the mock backend, configured with model.type: mock.
+// It needs no model at all: answers are the canned ones in mock.answers,
+// matched by phrase in the question, or are generated from the resources in
+// the context, so demos, tests and air-gapped evaluations run offline.
+//
+//	model:
+//	  type: mock
+//	mock:
+//	  answers:
+//	    "who owns": "The platform team owns everything in this account."
+func newMockClient() (*Client, error) {
+	fmt.Fprintln(os.Stderr, "🧪 Using the mock model: answers are generated from the scanned state without an LLM")
+	return &Client{useMock: true}, nil
+}
+
+// mockComplete answers a prompt built for operation (answer, phrase or
+// followups).
+func mockComplete(operation, prompt string) string {
+	switch operation {
+	case "llm.phrase":
+		question := promptQuestion(prompt)
+		if answer, ok := cannedAnswer(question); ok {
+			return answer
+		}
+		return mockPhrase(between(prompt, resultStart, resultEnd))
+	case "llm.followups":
+		var questions []string
+		for _, resource := range mockResources(between(prompt, contextStart, contextEnd)) {
+			questions = append(questions, fmt.Sprintf("What is %s connected to?", resource.name))
+			if len(questions) == maxFollowUps {
+				break
+			}
+		}
+		return strings.Join(questions, "\n")
+	default:
+		question := promptQuestion(prompt)
+		if answer, ok := cannedAnswer(question); ok {
+			return answer
+		}
+		return mockAnswer(question, between(prompt, contextStart, contextEnd))
+	}
+}
+
+// cannedAnswer returns the mock.answers entry whose phrase the question
+// contains; the longest phrase wins.
+func cannedAnswer(question string) (string, bool) {
+	answers := viper.GetStringMapString("mock.answers")
+	lower := strings.ToLower(question)
+	best := ""
+	for phrase := range answers {
+		if strings.Contains(lower, strings.ToLower(phrase)) && len(phrase) > len(best) {
+			best = phrase
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return answers[best], true
+}
+
+type mockResource struct {
+	id, name, resourceType, definedIn string
+}
+
+// describe renders the resource's type, and where it is defined when known
+func (r mockResource) describe() string {
+	if r.definedIn != "" {
+		return fmt.Sprintf("%s, defined in %s", r.resourceType, r.definedIn)
+	}
+	return r.resourceType
+}
+
+// mockResources lists the resources of a serialized state.
+func mockResources(context string) []mockResource {
+	var infraState struct {
+		Resources map[string]struct {
+			Type       string                 `json:"Type"`
+			Properties map[string]interface{} `json:"Properties"`
+			DefinedIn  string                 `json:"DefinedIn"`
+			Name       string                 `json:"FriendlyName"`
+		} `json:"Resources"`
+	}
+	if err := json.Unmarshal([]byte(context), &infraState); err != nil {
+		return nil
+	}
+	resources := make([]mockResource, 0, len(infraState.Resources))
+	for id, resource := range infraState.Resources {
+		name := resource.Name
+		for _, key := range []string{"Name", "FunctionName", "BucketName", "TableName", "QueueName", "TopicName"} {
+			if value, ok := resource.Properties[key].(string); ok && value != "" && name == "" {
+				name = value
+				break
+			}
+		}
+		if name == "" {
+			name = id
+		}
+		resources = append(resources, mockResource{id: id, name: name, resourceType: resource.Type, definedIn: resource.DefinedIn})
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].id < resources[j].id })
+	return resources
+}
+
+// mockAnswer lists the resources whose name, ID or type matches a word of
+// the question.
+func mockAnswer(question, context string) string {
+	resources := mockResources(context)
+	if len(resources) == 0 {
+		return "I cannot answer this based on the provided infrastructure information."
+	}
+
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) {
+		word = strings.TrimSuffix(word, "s")
+		if len(word) >= 3 && !mockStopWords[word] {
+			words = append(words, word)
+		}
+	}
+
+	var matched []mockResource
+	for _, resource := range resources {
+		haystack := strings.ToLower(resource.id + " " + resource.name + " " + resource.resourceType)
+		for _, word := range words {
+			if strings.Contains(haystack, word) {
+				matched = append(matched, resource)
+				break
+			}
+		}
+	}
+	if len(words) == 0 {
+		matched = resources
+	}
+	switch len(matched) {
+	case 0:
+		return "No resources in the scanned state match the question."
+	case 1:
+		return fmt.Sprintf("1 resource in the scanned state matches: %s (%s).", matched[0].name, matched[0].describe())
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d resources in the scanned state match:\n", len(matched))
+	for i, resource := range matched {
+		if i == maxMockItems {
+			fmt.Fprintf(&b, "- …and %d more\n", len(matched)-maxMockItems)
+			break
+		}
+		fmt.Fprintf(&b, "- %s (%s)\n", resource.name, resource.describe())
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// mockPhrase presents a deterministic lookup result as a list.
+func mockPhrase(result string) string {
+	var lookup struct {
+		Intent string      `json:"intent"`
+		Result interface{} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(result), &lookup); err != nil {
+		return strings.TrimSpace(result)
+	}
+
+	var items []string
+	switch value := lookup.Result.(type) {
+	case []interface{}:
+		for _, item := range value {
+			items = append(items, compactJSON(item))
+		}
+	case map[string]interface{}:
+		for _, key := range sortedMapKeys(value) {
+			items = append(items, key+": "+compactJSON(value[key]))
+		}
+	case nil:
+	default:
+		items = append(items, compactJSON(value))
+	}
+	if len(items) == 0 {
+		return fmt.Sprintf("Nothing matched (%s).", lookup.Intent)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Result of %s:\n", lookup.Intent)
+	for i, item := range items {
+		if i == maxMockItems {
+			fmt.Fprintf(&b, "- …and %d more\n", len(items)-maxMockItems)
+			break
+		}
+		fmt.Fprintf(&b, "- %s\n", item)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// mockJSON returns the smallest value that satisfies schema: empty arrays
+// and strings, zero numbers and the first allowed enum value.
+func mockJSON(schema map[string]interface{}) interface{} {
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	switch schema["type"] {
+	case "object":
+		obj := make(map[string]interface{})
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			sub, _ := properties[name.(string)].(map[string]interface{})
+			obj[name.(string)] = mockJSON(sub)
+		}
+		return obj
+	case "array":
+		return []interface{}{}
+	case "number", "integer":
+		return 0
+	case "boolean":
+		return false
+	default:
+		return ""
+	}
+}
+
+// promptQuestion returns the QUESTION line of a prompt.
+func promptQuestion(prompt string) string {
+	for _, line := range strings.Split(prompt, "\n") {
+		if question, ok := strings.CutPrefix(line, "QUESTION: "); ok {
+			return question
+		}
+	}
+	return ""
+}
+
+func between(text, start, end string) string {
+	_, after, ok := strings.Cut(text, start)
+	if !ok {
+		return ""
+	}
+	inside, _, _ := strings.Cut(after, end)
+	return strings.TrimSpace(inside)
+}
+
+func compactJSON(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,9 +1,7 @@
 package llm
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"sort"
 
@@ -77,7 +75,7 @@ func SelectBestModel(ollamaURL string) (string, error) {
 	fmt.Fprintf(os.Stderr, "🔍 Detected system: %s\n", specs.String())
 
 	// Get available models from Ollama
-	availableModels, err := getAvailableModels(ollamaURL)
+	availableModels, err := OllamaModels(ollamaURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to get available models: %w", err)
 	}
@@ -96,28 +94,6 @@ func SelectBestModel(ollamaURL string) (string, error) {
 	return bestModel, nil
 }
 
-// getAvailableModels fetches the list of available models from Ollama
-func getAvailableModels(ollamaURL string) ([]AvailableModel, error) {
-	resp, err := http.Get(ollamaURL + "/api/tags")
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ollama: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Ollama API returned status %d", resp.StatusCode)
-	}
-
-	var result struct {
-		Models []AvailableModel `json:"models"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
-	}
-
-	return result.Models, nil
-}
-
 // selectBestAvailableModel finds the best model that fits the system specs and is available
 func selectBestAvailableModel(specs *sysinfo.SystemSpecs, availableModels []AvailableModel) string {
 	// Create a map of available models for quick lookup
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Query languages DraftQuery writes
+const (
+	QueryLanguageInsights = "insights" // CloudWatch Logs Insights
+	QueryLanguageAthena   = "athena"   // Athena SQL (Trino)
+)
+
+// QueryDraft is a log or SQL query the model wrote for a request in plain
+// language.
+type QueryDraft struct {
+	Query string `json:"query"`
+	// LogGroups are the log groups a Logs Insights query runs over
+	LogGroups   []string `json:"log_groups,omitempty"`
+	Explanation string   `json:"explanation"`
+	Assumptions []string `json:"assumptions"`
+}
+
+// DraftQuery asks the general model for a query in language that answers
+// request. schema describes what can be queried: the log groups for Logs
+// Insights, the tables and their columns for Athena. The request goes
+// through the same redaction, PII and budget checks as Answer.
+func (r *Router) DraftQuery(ctx context.Context, request, language, schema string) (*QueryDraft, error) {
+	var build func([]string) string
+	switch language {
+	case QueryLanguageInsights:
+		build = func(texts []string) string { return buildInsightsPrompt(texts[0], texts[1]) }
+	case QueryLanguageAthena:
+		build = func(texts []string) string { return buildAthenaPrompt(texts[0], texts[1]) }
+	default:
+		return nil, fmt.Errorf("unknown query language %q", language)
+	}
+	raw, err := r.generateProtected(ctx, "nl2query", queryDraftSchema(), build, request, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to draft the query: %w", err)
+	}
+
+	var draft QueryDraft
+	if err := json.Unmarshal(raw, &draft); err != nil {
+		return nil, fmt.Errorf("failed to read the drafted query: %w", err)
+	}
+	return &draft, nil
+}
+
+func queryDraftSchema() Schema {
+	stringList := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	return Schema{
+		"type":     "object",
+		"required": []interface{}{"query", "explanation", "assumptions"},
+		"properties": map[string]interface{}{
+			"query":       map[string]interface{}{"type": "string"},
+			"log_groups":  stringList,
+			"explanation": map[string]interface{}{"type": "string"},
+			"assumptions": stringList,
+		},
+	}
+}
+
+func buildInsightsPrompt(request, logGroups string) string {
+	return fmt.Sprintf(`You are an AWS expert writing CloudWatch Logs Insights queries.
+Write one Logs Insights query that answers the request below.
+
+RULES:
+1. Put the log groups the query should run over in log_groups, chosen only from the list below, exactly as written
+   there. Choose as few as answer the request; at most 20.
+2. Use Logs Insights syntax only: fields, filter, parse, stats, sort, limit, dedup, display. Commands are separated by |.
+3. The time range is set when the query runs; do not filter @timestamp for it.
+4. End with "limit 100" or less unless stats already reduces the result to a few rows.
+5. explanation says in one or two sentences what the query returns. Put anything you had to assume, such as the
+   format of the log messages, in assumptions.
+
+--- LOG GROUPS ---
+%s
+--- END LOG GROUPS ---
+
+Request: %s`, logGroups, request)
+}
+
+func buildAthenaPrompt(request, tables string) string {
+	return fmt.Sprintf(`You are an AWS expert writing Amazon Athena queries (Trino SQL, engine version 3).
+Write one SQL query that answers the request below.
+
+RULES:
+1. Write a single SELECT statement, optionally with WITH clauses. Never write statements that change data or
+   metadata, and no trailing semicolon.
+2. Use only the tables and columns listed below, exactly as written there.
+3. Filter on the partition columns where the request allows, to limit the data scanned.
+4. End with LIMIT 100 or less unless the query aggregates to a few rows.
+5. explanation says in one or two sentences what the query returns. Put anything you had to assume in assumptions.
+   Leave log_groups empty.
+
+--- TABLES ---
+%s
+--- END TABLES ---
+
+Request: %s`, tables, request)
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/spf13/viper"
+)
+
+// defaultOpenAIModel is used when openai.model is not configured
+const defaultOpenAIModel = openai.GPT4o
+
+// newOpenAIClientFromConfig creates an OpenAI client from the openai.* config
+// keys:
+//
+//	openai:
+//	  model: gpt-4o-mini              # default gpt-4o
+//	  base_url: https://proxy/v1      # OpenAI-compatible endpoint
+//	  organization: org-...
+//	  max_tokens: 1024                # 0 = model default
+//	  api_key: sk-...                 # OPENAI_API_KEY takes precedence
+//
+// An API key is required unless base_url points at a proxy.
+func newOpenAIClientFromConfig() (*Client, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		apiKey = getConfigString("openai.api_key")
+	}
+	baseURL := getConfigString("openai.base_url")
+	if apiKey == "" && baseURL == "" {
+		return nil, fmt.Errorf("no OpenAI API key: set OPENAI_API_KEY or openai.api_key")
+	}
+
+	cfg := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		cfg.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if org := getConfigString("openai.organization"); org != "" {
+		cfg.OrgID = org
+	}
+	cfg.HTTPClient = HTTPClient(0)
+
+	model := configuredOpenAIModel()
+	if baseURL != "" {
+		fmt.Fprintf(os.Stderr, "☁️  Using OpenAI-compatible model %s at %s\n", model, baseURL)
+	} else {
+		fmt.Fprintf(os.Stderr, "☁️  Using OpenAI model %s\n", model)
+	}
+	return &Client{
+		openai:          openai.NewClientWithConfig(cfg),
+		openaiModel:     model,
+		openaiMaxTokens: viper.GetInt("openai.max_tokens"),
+		costManager:     newCostManagerFromConfig(),
+	}, nil
+}
+
+// openAIRequest builds a chat completion request for the configured model.
+// Reasoning models (o1, o3, ...) only accept max_completion_tokens.
+func (c *Client) openAIRequest(prompt string) openai.ChatCompletionRequest {
+	req := openai.ChatCompletionRequest{
+		Model:    c.openaiModel,
+		Messages: []openai.ChatCompletionMessage{{Role: "system", Content: prompt}},
+	}
+	if c.openaiMaxTokens > 0 {
+		if strings.HasPrefix(c.openaiModel, "o") {
+			req.MaxCompletionTokens = c.openaiMaxTokens
+		} else {
+			req.MaxTokens = c.openaiMaxTokens
+		}
+	}
+	return req
+}
+
+// configuredOpenAIModel returns openai.model, or the default model.
+func configuredOpenAIModel() string {
+	if model := getConfigString("openai.model"); model != "" {
+		return model
+	}
+	return defaultOpenAIModel
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxParseCacheEntries caps the parse cache; the oldest entries are evicted.
+const maxParseCacheEntries = 500
+
+// ParseCache stores ParseQuery results for identical questions in
+// .cloudai/parse-cache.json. Parsing runs at a low temperature, so asking the
+// model again for the same question only costs a round trip. Entries are keyed
+// by backend, model and the registered intents as well as the question, so
+// switching models or adding handlers never returns a stale parse.
+type ParseCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]parseCacheEntry
+	loaded  bool
+}
+
+type parseCacheEntry struct {
+	Query   Query     `json:"query"`
+	Created time.Time `json:"created"`
+}
+
+// NewParseCache returns the parse cache stored in dir (normally the project's
+// .cloudai directory). Nothing is read until the first lookup.
+func NewParseCache(dir string) *ParseCache {
+	return &ParseCache{path: filepath.Join(dir, "parse-cache.json")}
+}
+
+// SetParseCache makes ParseQuery consult and fill the given cache.
+func (c *Client) SetParseCache(cache *ParseCache) {
+	c.parseCache = cache
+}
+
+// parseCacheKey identifies a question for a given backend, model and intent set.
+func (c *Client) parseCacheKey(rawQuery string) string {
+	intentMu.RLock()
+	intents := make([]string, 0, len(intentDescriptions))
+	for name, description := range intentDescriptions {
+		intents = append(intents, name+"="+description)
+	}
+	intentMu.RUnlock()
+	sort.Strings(intents)
+
+	h := sha256.New()
+	for _, part := range append([]string{c.Backend(), c.Model(), normalizeQuery(rawQuery)}, intents...) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeQuery folds case and whitespace so trivially different spellings
+// of a question share an entry.
+func normalizeQuery(rawQuery string) string {
+	return strings.Join(strings.Fields(strings.ToLower(rawQuery)), " ")
+}
+
+// Get returns the cached query for key.
+func (pc *ParseCache) Get(key string) (*Query, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load()
+
+	entry, ok := pc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	q := entry.Query
+	return &q, true
+}
+
+// Put stores a parsed query and writes the cache file. Write errors are
+// ignored: the cache is only an optimisation.
+func (pc *ParseCache) Put(key string, q *Query) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load()
+
+	pc.entries[key] = parseCacheEntry{Query: *q, Created: time.Now()}
+	if len(pc.entries) > maxParseCacheEntries {
+		pc.evictOldest(len(pc.entries) - maxParseCacheEntries)
+	}
+
+	data, err := json.Marshal(pc.entries)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(pc.path), 0755); err != nil {
+		return
+	}
+	// write through a temp file so concurrent readers never see a partial file
+	tmp := pc.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		os.Rename(tmp, pc.path)
+	}
+}
+
+func (pc *ParseCache) load() {
+	if pc.loaded {
+		return
+	}
+	pc.loaded = true
+	pc.entries = make(map[string]parseCacheEntry)
+	if data, err := os.ReadFile(pc.path); err == nil {
+		json.Unmarshal(data, &pc.entries)
+	}
+}
+
+func (pc *ParseCache) evictOldest(n int) {
+	keys := make([]string, 0, len(pc.entries))
+	for key := range pc.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return pc.entries[keys[i]].Created.Before(pc.entries[keys[j]].Created) })
+	for _, key := range keys[:n] {
+		delete(pc.entries, key)
+	}
+}
@@ -0,0 +1,315 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrPIIDetected is returned when the PII pass finds personal data in a
+// prompt and is configured to block rather than scrub it.
+var ErrPIIDetected = errors.New("personal data detected")
+
+// PII pass modes
+const (
+	PIIOff   = "off"
+	PIIScrub = "scrub"
+	PIIBlock = "block"
+)
+
+// maxPIIChunk bounds the text sent to the local model in one request, so
+// large contexts fit its context window.
+const maxPIIChunk = 6000
+
+// emailPattern catches addresses the model misses.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// PIIFinding is a piece of personal or customer data found in a prompt.
+type PIIFinding struct {
+	Text     string `json:"text"`
+	Category string `json:"category"`
+}
+
+// PIIDetector runs a local model over prompts bound for a remote backend
+// and scrubs or blocks the personal data it finds: names, email addresses
+// and customer data in resource descriptions and tags, which the regular
+// expressions of DataProtector do not recognise. It is configured under
+// privacy.pii, or by the pii section of the policy, which takes precedence:
+//
+//	privacy:
+//	  pii:
+//	    mode: scrub          # off (default), scrub or block
+//	    model: llama3.1:8b   # local Ollama model; defaults to the general model
+type PIIDetector struct {
+	Mode  string
+	model string
+
+	once   sync.Once
+	client *Client
+	err    error
+
+	// findings of chunks already checked, so the context of an answer and
+	// of its follow-up questions is only checked once
+	mu     sync.Mutex
+	cached map[string][]PIIFinding
+}
+
+// NewPIIDetectorFromConfig returns the detector for the configuration and
+// policy in effect. The local model is only connected to on first use.
+func NewPIIDetectorFromConfig() *PIIDetector {
+	mode, model := getConfigString("privacy.pii.mode"), getConfigString("privacy.pii.model")
+	if p := activePolicy; p != nil && p.PII != nil {
+		if p.PII.Mode != "" {
+			mode = p.PII.Mode
+		}
+		if p.PII.Model != "" {
+			model = p.PII.Model
+		}
+	}
+	if mode == "" {
+		mode = PIIOff
+	}
+	return &PIIDetector{Mode: mode, model: model}
+}
+
+// Enabled reports whether prompts to c are checked: the pass only runs for
+// backends that send prompts off this machine.
+func (d *PIIDetector) Enabled(c *Client) bool {
+	return d != nil && d.Mode != PIIOff && !c.isLocal()
+}
+
+// Protect checks texts that are about to be sent to c. In scrub mode the
+// personal data is replaced with placeholders that p restores in the answer;
+// in block mode an ErrPIIDetected error names what was found.
+func (d *PIIDetector) Protect(ctx context.Context, c *Client, p *DataProtector, texts ...string) ([]string, error) {
+	if !d.Enabled(c) {
+		return texts, nil
+	}
+	switch d.Mode {
+	case PIIScrub, PIIBlock:
+	default:
+		return nil, fmt.Errorf("invalid PII mode %q: use off, scrub or block", d.Mode)
+	}
+
+	var findings []PIIFinding
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		found, err := d.Detect(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("PII detection failed, nothing was sent to %s: %w", c.Model(), err)
+		}
+		for _, finding := range found {
+			if !seen[finding.Text] {
+				seen[finding.Text] = true
+				findings = append(findings, finding)
+			}
+		}
+	}
+	if len(findings) == 0 {
+		return texts, nil
+	}
+
+	if d.Mode == PIIBlock {
+		counts := make(map[string]int)
+		for _, finding := range findings {
+			counts[finding.Category]++
+		}
+		parts := make([]string, 0, len(counts))
+		for category, n := range counts {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(category)))
+		}
+		sort.Strings(parts)
+		return nil, fmt.Errorf("%w (%s); nothing was sent to %s. Remove it from the cache or set the PII mode to scrub",
+			ErrPIIDetected, strings.Join(parts, ", "), c.Model())
+	}
+
+	scrubbed := make([]string, len(texts))
+	for i, text := range texts {
+		scrubbed[i] = p.scrubValues(text, findings)
+	}
+	return scrubbed, nil
+}
+
+// Detect asks the local model for the personal data in text.
+func (d *PIIDetector) Detect(ctx context.Context, text string) ([]PIIFinding, error) {
+	d.once.Do(func() {
+		d.client, d.err = d.newClient()
+	})
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var findings []PIIFinding
+	seen := make(map[string]bool)
+	add := func(finding PIIFinding) {
+		finding.Text = strings.TrimSpace(finding.Text)
+		// Ignore placeholders, fragments too short to mean anything and
+		// anything the model made up
+		if len(finding.Text) < 3 || redactionPlaceholder.MatchString(finding.Text) || !strings.Contains(text, finding.Text) || seen[finding.Text] {
+			return
+		}
+		seen[finding.Text] = true
+		findings = append(findings, finding)
+	}
+
+	for _, email := range emailPattern.FindAllString(text, -1) {
+		add(PIIFinding{Text: email, Category: "EMAIL"})
+	}
+	for _, chunk := range splitChunks(text, maxPIIChunk) {
+		found, err := d.detectChunk(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, finding := range found {
+			add(finding)
+		}
+	}
+	return findings, nil
+}
+
+func (d *PIIDetector) detectChunk(ctx context.Context, chunk string) ([]PIIFinding, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if found, ok := d.cached[chunk]; ok {
+		return found, nil
+	}
+
+	raw, err := d.client.GenerateStructured(ctx, "pii", buildPIIPrompt(chunk), piiSchema())
+	if err != nil {
+		return nil, err
+	}
+	var reply struct {
+		Findings []PIIFinding `json:"findings"`
+	}
+	if err := json.Unmarshal(raw, &reply); err != nil {
+		return nil, err
+	}
+	if d.cached == nil {
+		d.cached = make(map[string][]PIIFinding)
+	}
+	d.cached[chunk] = reply.Findings
+	return reply.Findings, nil
+}
+
+// newClient connects to the local model. A model that is not local would
+// send the very data the pass protects, so it is refused.
+func (d *PIIDetector) newClient() (*Client, error) {
+	model := d.model
+	if model == "" {
+		if getConfigString("model.type") != "ollama" || getConfigString("model.name") == "" {
+			return nil, fmt.Errorf("no local model for PII detection; set privacy.pii.model to an Ollama model")
+		}
+		model = getConfigString("model.name")
+	}
+	client, err := NewClientForModel("ollama:" + strings.TrimPrefix(model, "ollama:"))
+	if err != nil {
+		return nil, err
+	}
+	if !client.isLocal() {
+		return nil, fmt.Errorf("the PII detection model must run on this machine, but Ollama is at %s", client.ollamaURL)
+	}
+	// Keep the detector's own calls out of the answer's usage and budget
+	client.costManager = nil
+	return client, nil
+}
+
+// scrubValues replaces each finding with a placeholder of its category,
+// which Unscrub restores.
+func (p *DataProtector) scrubValues(text string, findings []PIIFinding) string {
+	// Longest first, so a name inside an email address is not replaced
+	// before the address
+	sorted := append([]PIIFinding(nil), findings...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i].Text) > len(sorted[j].Text) })
+
+	for _, finding := range sorted {
+		if !strings.Contains(text, finding.Text) {
+			continue
+		}
+		placeholder := ""
+		for existing, original := range p.replacements {
+			if original == finding.Text {
+				placeholder = existing
+				break
+			}
+		}
+		if placeholder == "" {
+			placeholder = p.buildPlaceholder(finding.Category)
+			p.replacements[placeholder] = finding.Text
+		}
+		text = strings.ReplaceAll(text, finding.Text, placeholder)
+	}
+	return text
+}
+
+// splitChunks splits text at line breaks into chunks of at most size bytes;
+// longer lines are split where they exceed it, on a character boundary.
+func splitChunks(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if current.Len() > 0 && current.Len()+len(line) > size {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		for len(line) > size {
+			cut := size
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(line)
+			}
+			chunks = append(chunks, line[:cut])
+			line = line[cut:]
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+func piiSchema() Schema {
+	return Schema{
+		"type":     "object",
+		"required": []interface{}{"findings"},
+		"properties": map[string]interface{}{
+			"findings": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"text", "category"},
+					"properties": map[string]interface{}{
+						"text":     map[string]interface{}{"type": "string"},
+						"category": map[string]interface{}{"type": "string", "enum": []interface{}{"NAME", "EMAIL", "PHONE", "ADDRESS", "CUSTOMER"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func buildPIIPrompt(text string) string {
+	return fmt.Sprintf(`You review text from a cloud infrastructure inventory before it is sent to an external service.
+List every piece of personal or customer data in it, copied exactly as it appears:
+
+- NAME: names of people
+- EMAIL: email addresses
+- PHONE: phone numbers
+- ADDRESS: postal addresses
+- CUSTOMER: names, account numbers or other identifiers of customers, e.g. in tags or descriptions
+
+Do not list AWS resource names, service names, regions, runtimes, team or project names, or
+placeholders such as [[ARN_1]]. Reply with {"findings": []} when there is nothing to list.
+
+--- TEXT ---
+%s
+--- END TEXT ---`, text)
+}
@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPolicyViolation is returned when a model request or configuration is
+// not allowed by the organisation's policy file.
+var ErrPolicyViolation = errors.New("blocked by policy")
+
+// Policy restricts which models CloudAI-CLI may use, read from a
+// .cloudai/policy.yaml that an organisation commits to its repositories:
+//
+//	providers: [bedrock, ollama]          # ollama, bedrock, sagemaker, openai, mock
+//	models: ["anthropic.claude-3-haiku-*", "llama3*"]
+//	allow_egress: false                   # prompts must stay on this machine
+//	max_daily_cost: 2.00                  # caps cost.daily_limit (USD)
+//	max_request_cost: 0.05                # USD, estimated before sending
+//	pii:                                  # see PIIDetector
+//	  mode: block
+//	  model: llama3.1:8b
+//
+// Empty lists allow everything. Unknown keys are an error, so a misspelt
+// restriction is never silently ignored.
+type Policy struct {
+	Providers      []string `yaml:"providers,omitempty"`
+	Models         []string `yaml:"models,omitempty"`
+	AllowEgress    *bool    `yaml:"allow_egress,omitempty"`
+	MaxDailyCost   float64  `yaml:"max_daily_cost,omitempty"`
+	MaxRequestCost float64  `yaml:"max_request_cost,omitempty"`
+	PII            *struct {
+		Mode  string `yaml:"mode"`
+		Model string `yaml:"model,omitempty"`
+	} `yaml:"pii,omitempty"`
+
+	// Path is the file the policy was read from
+	Path string `yaml:"-"`
+}
+
+// activePolicy applies to every client of the process; nil allows everything.
+var activePolicy *Policy
+
+// SetPolicy makes p the policy every model request is checked against.
+func SetPolicy(p *Policy) {
+	activePolicy = p
+}
+
+// ActivePolicy returns the policy in effect, or nil.
+func ActivePolicy() *Policy {
+	return activePolicy
+}
+
+// LoadPolicy reads a policy file. It returns nil, nil when the file does not
+// exist.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", file, err)
+	}
+
+	p := &Policy{Path: file}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy %s: %w", file, err)
+	}
+	for i, provider := range p.Providers {
+		p.Providers[i] = strings.ToLower(strings.TrimSpace(provider))
+		switch p.Providers[i] {
+		case "ollama", "tgi", "mock", string(AWSModelBedrock), string(AWSModelSageMaker), string(AWSModelOpenAI):
+		default:
+			return nil, fmt.Errorf("invalid policy %s: unknown provider %q (use ollama, tgi, bedrock, sagemaker, openai or mock)", file, provider)
+		}
+	}
+	for _, pattern := range p.Models {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: bad model pattern %q: %w", file, pattern, err)
+		}
+	}
+	if p.PII != nil {
+		switch p.PII.Mode {
+		case PIIOff, PIIScrub, PIIBlock:
+		default:
+			return nil, fmt.Errorf("invalid policy %s: unknown pii mode %q (use off, scrub or block)", file, p.PII.Mode)
+		}
+	}
+	if p.MaxDailyCost < 0 || p.MaxRequestCost < 0 {
+		return nil, fmt.Errorf("invalid policy %s: costs cannot be negative", file)
+	}
+	return p, nil
+}
+
+// EgressAllowed reports whether prompts may be sent off this machine.
+func (p *Policy) EgressAllowed() bool {
+	return p == nil || p.AllowEgress == nil || *p.AllowEgress
+}
+
+// check validates a backend and model; local is whether requests to it stay
+// on this machine.
+func (p *Policy) check(backend, model string, local bool) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Providers) > 0 && !slices.Contains(p.Providers, backend) {
+		return fmt.Errorf("%w: provider %s is not allowed by %s (allowed: %s)", ErrPolicyViolation, backend, p.Path, strings.Join(p.Providers, ", "))
+	}
+	if len(p.Models) > 0 && !slices.ContainsFunc(p.Models, func(pattern string) bool {
+		matched, _ := path.Match(pattern, model)
+		return matched
+	}) {
+		return fmt.Errorf("%w: model %s is not allowed by %s (allowed: %s)", ErrPolicyViolation, model, p.Path, strings.Join(p.Models, ", "))
+	}
+	if !local && !p.EgressAllowed() {
+		return fmt.Errorf("%w: %s (%s) would send prompts off this machine, which %s does not allow; use a local Ollama model", ErrPolicyViolation, model, backend, p.Path)
+	}
+	return nil
+}
+
+// CheckClient validates the backend and model of a client.
+func (p *Policy) CheckClient(c *Client) error {
+	if c == nil {
+		return nil
+	}
+	return p.check(c.Backend(), c.Model(), c.isLocal())
+}
+
+// CheckModel validates a configured model without connecting to it.
+func (p *Policy) CheckModel(ref ModelRef) error {
+	local := ref.Backend == "mock"
+	if ref.Backend == "ollama" {
+		ollamaURL := getConfigString("model.url")
+		if ollamaURL == "" {
+			ollamaURL = os.Getenv("OLLAMA_URL")
+		}
+		local = (ollamaURL == "" || isLoopbackURL(ollamaURL)) && ActiveTunnel() == nil
+	}
+	if ref.Backend == "tgi" {
+		tgiURL := getConfigString("model.url")
+		local = tgiURL == "" || isLoopbackURL(tgiURL)
+	}
+	return p.check(ref.Backend, ref.Model, local)
+}
+
+// allow validates a request before it is sent to c.
+func (p *Policy) allow(c *Client, prompt string) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.CheckClient(c); err != nil {
+		return err
+	}
+	if p.MaxRequestCost > 0 {
+		if cost := c.estimateRequestCost(prompt); cost > p.MaxRequestCost {
+			return fmt.Errorf("%w: the request to %s is estimated at $%.4f, more than the $%.4f per request allowed by %s",
+				ErrPolicyViolation, c.Model(), cost, p.MaxRequestCost, p.Path)
+		}
+	}
+	return nil
+}
+
+// capDailyLimit lowers a daily limit to the policy's maximum.
+func (p *Policy) capDailyLimit(limit float64) float64 {
+	if p != nil && p.MaxDailyCost > 0 && limit > p.MaxDailyCost {
+		return p.MaxDailyCost
+	}
+	return limit
+}
+
+// isLocal reports whether requests to c stay on this machine: Ollama served
+// on a loopback address that is not an SSM tunnel, TGI on a loopback
+// address, or the mock model.
+func (c *Client) isLocal() bool {
+	return c.useMock || c.useOllama && isLoopbackURL(c.ollamaURL) && !c.tunneled || c.useTGI && isLoopbackURL(c.tgiURL)
+}
+
+func isLoopbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
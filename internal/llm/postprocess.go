@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// defaultStripPhrases are filler openers and closers that models add around
+// an answer. Only whole phrases are removed, never the sentence they start.
+var defaultStripPhrases = []string{
+	"Based on the provided infrastructure context,",
+	"Based on the provided context,",
+	"If you have any further questions or if there's anything else I can help you with, please let me know!",
+}
+
+// PostProcessConfig controls how model answers are cleaned up before they
+// are shown. It is read from the "answer" config section:
+//
+//	answer:
+//	  clean: true                 # false returns the model output untouched
+//	  max_length: 0               # truncate at a sentence boundary; 0 = no limit
+//	  strip_phrases: [...]        # exact phrases to remove
+//	  drop_lines: ["SourceArn"]   # regexps; matching lines are removed
+//	  collapse_blank_lines: true  # squeeze runs of blank lines into one
+//	  friendly_names: true        # replace logical IDs with resource names
+type PostProcessConfig struct {
+	Enabled            bool
+	MaxLength          int
+	StripPhrases       []string
+	DropLines          []*regexp.Regexp
+	CollapseBlankLines bool
+	FriendlyNames      bool
+}
+
+// LoadPostProcessConfig reads the answer post-processing settings. Invalid
+// drop_lines patterns are skipped with a warning.
+func LoadPostProcessConfig() PostProcessConfig {
+	cfg := PostProcessConfig{
+		Enabled:            true,
+		MaxLength:          viper.GetInt("answer.max_length"),
+		StripPhrases:       defaultStripPhrases,
+		CollapseBlankLines: true,
+		FriendlyNames:      true,
+	}
+	if viper.IsSet("answer.clean") {
+		cfg.Enabled = viper.GetBool("answer.clean")
+	}
+	if viper.IsSet("answer.strip_phrases") {
+		cfg.StripPhrases = viper.GetStringSlice("answer.strip_phrases")
+	}
+	if viper.IsSet("answer.collapse_blank_lines") {
+		cfg.CollapseBlankLines = viper.GetBool("answer.collapse_blank_lines")
+	}
+	if viper.IsSet("answer.friendly_names") {
+		cfg.FriendlyNames = viper.GetBool("answer.friendly_names")
+	}
+	for _, pattern := range viper.GetStringSlice("answer.drop_lines") {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Ignoring invalid answer.drop_lines pattern %q: %v\n", pattern, err)
+			continue
+		}
+		cfg.DropLines = append(cfg.DropLines, re)
+	}
+	return cfg
+}
+
+// Apply runs the configured post-processing steps on a model answer.
+func (cfg PostProcessConfig) Apply(response string) string {
+	if !cfg.Enabled {
+		return response
+	}
+
+	for _, phrase := range cfg.StripPhrases {
+		if phrase != "" {
+			response = strings.ReplaceAll(response, phrase, "")
+		}
+	}
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(strings.TrimSpace(response), "\n") {
+		// Keep leading indentation: it carries nested lists and code blocks
+		line = strings.TrimRight(line, " \t\r")
+		if cfg.dropLine(line) {
+			continue
+		}
+		if line == "" {
+			if cfg.CollapseBlankLines && blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	response = strings.TrimSpace(strings.Join(lines, "\n"))
+
+	if cfg.MaxLength > 0 {
+		response = truncateAtSentence(response, cfg.MaxLength)
+	}
+	return response
+}
+
+// UseNames replaces the logical IDs left in an answer with the friendly
+// names of the resources, given by logical ID. An ID the answer already
+// pairs with its name, as in "orders (OrdersTable1A2B)", is dropped.
+func (cfg PostProcessConfig) UseNames(response string, names map[string]string) string {
+	if !cfg.Enabled || !cfg.FriendlyNames || len(names) == 0 {
+		return response
+	}
+	ids := make([]string, 0, len(names))
+	for id, name := range names {
+		if name != "" && name != id && strings.Contains(response, id) {
+			ids = append(ids, id)
+		}
+	}
+	// longer IDs first, so one that contains another is replaced whole
+	sort.Slice(ids, func(i, j int) bool { return len(ids[i]) > len(ids[j]) })
+	for _, id := range ids {
+		name := names[id]
+		response = strings.ReplaceAll(response, name+" ("+id+")", name)
+		response = strings.ReplaceAll(response, id+" ("+name+")", name)
+		response = replaceWord(response, id, name)
+	}
+	return response
+}
+
+// replaceWord replaces the occurrences of word that are not part of a longer
+// identifier, ARN or path.
+func replaceWord(s, word, replacement string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, word)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(word)
+		before, after := rune(0), rune(0)
+		if i > 0 {
+			before, _ = utf8.DecodeLastRuneInString(s[:i])
+		}
+		if end < len(s) {
+			after, _ = utf8.DecodeRuneInString(s[end:])
+		}
+		b.WriteString(s[:i])
+		if isWordRune(before) || strings.ContainsRune(".:/-", before) || isWordRune(after) || after == '-' {
+			b.WriteString(word)
+		} else {
+			b.WriteString(replacement)
+		}
+		s = s[end:]
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (cfg PostProcessConfig) dropLine(line string) bool {
+	for _, re := range cfg.DropLines {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateAtSentence shortens s to at most max runes, cutting after the last
+// complete sentence when there is one and marking the cut with an ellipsis.
+func truncateAtSentence(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	cut := string(runes[:max])
+	if i := strings.LastIndexAny(cut, ".!?\n"); i > len(cut)/2 {
+		return strings.TrimSpace(cut[:i+1]) + " …"
+	}
+	return strings.TrimSpace(cut) + "…"
+}
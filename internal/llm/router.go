@@ -2,6 +2,8 @@ package llm
 
 import (
     "context"
+    "fmt"
+    "os"
     "strings"
 )
 
@@ -21,8 +23,24 @@ type Router struct {
 
     protector *DataProtector
 
+    // pii checks prompts for personal data before they leave the machine
+    pii *PIIDetector
+
+    // budget enforces cost.daily_limit for every remote backend
+    budget *BudgetGuard
+
     // naive keyword trigger list for the architecture brain
     archKeywords []string
+
+    // escalation, when set, re-asks questions the general client refused
+    escalation *Client
+
+    // canary, when configured, compares a sample of the arch client's
+    // answers with the general client's
+    canary *Canary
+
+    // lastClient is the backend that served the most recent answer
+    lastClient *Client
 }
 
 // NewRouter constructs a router.
@@ -34,13 +52,26 @@ func NewRouter(archClient, generalClient *Client) *Router {
         archClient:    archClient,
         generalClient: generalClient,
         protector:     NewDataProtector(),
+        pii:           NewPIIDetectorFromConfig(),
+        budget:        NewBudgetGuard(),
         archKeywords:  kw,
+        canary:        NewCanaryFromConfig(),
     }
 }
 
 // Answer selects the backend, scrubs the prompt + context, forwards the request
 // and returns the de-scrubbed answer.
 func (r *Router) Answer(ctx context.Context, question, context string) (string, error) {
+    return r.forward(ctx, question, context, (*Client).Answer)
+}
+
+// Phrase is like Answer, but the context is the result of a deterministic
+// lookup that the model only has to put into words.
+func (r *Router) Phrase(ctx context.Context, question, result string) (string, error) {
+    return r.forward(ctx, question, result, (*Client).Phrase)
+}
+
+func (r *Router) forward(ctx context.Context, question, context string, call func(*Client, context.Context, string, string) (string, error)) (string, error) {
     // 1. Scrub potentially sensitive data.
     scrubbedQuestion := r.protector.Scrub(question)
     scrubbedContext := r.protector.Scrub(context)
@@ -48,16 +79,84 @@ func (r *Router) Answer(ctx context.Context, question, context string) (string,
     // 2. Choose backend.
     client := r.chooseClient(strings.ToLower(question))
 
-    // 3. Forward.
-    answer, err := client.Answer(ctx, scrubbedQuestion, scrubbedContext)
+    // 2b. Scrub or block personal data the patterns miss before it leaves
+    // the machine, for the chosen client or the one a refusal escalates to.
+    target := client
+    if !r.pii.Enabled(target) && client == r.generalClient && r.escalation != nil {
+        target = r.escalation
+    }
+    protected, err := r.pii.Protect(ctx, target, r.protector, scrubbedQuestion, scrubbedContext)
     if err != nil {
         return "", err
     }
+    scrubbedQuestion, scrubbedContext = protected[0], protected[1]
 
-    // 4. De-scrub.
+    // 3. Enforce the daily budget, whichever backend was chosen.
+    r.lastClient = client
+    if err := r.budget.Allow(client, scrubbedQuestion+scrubbedContext); err != nil {
+        client.lastUsage = Usage{} // nothing was sent
+        return "", err
+    }
+
+    // 4. Forward. A sample of architecture questions is answered by the
+    // general client too, at the same time, for the canary; only where the
+    // text protected for the arch client is fit to send to the general one.
+    // The comparison is judged and logged in the background.
+    var canary chan canaryResult
+    if client == r.archClient && r.canary.sample() &&
+        (r.pii.Enabled(client) || !r.pii.Enabled(r.generalClient)) &&
+        r.budget.Allow(r.generalClient, scrubbedQuestion+scrubbedContext) == nil {
+        canary = make(chan canaryResult, 1)
+        go func() {
+            canary <- ask(ctx, r.generalClient, scrubbedQuestion, scrubbedContext, call)
+        }()
+    }
+    result := ask(ctx, client, scrubbedQuestion, scrubbedContext, call)
+    if canary != nil {
+        r.canary.recordLater(ctx, r.generalClient, client, r.pii, scrubbedQuestion, scrubbedContext, result, <-canary)
+    }
+    answer, err := result.answer, result.err
+    if err != nil {
+        return "", err
+    }
+
+    // 4b. Escalate a refusal from the general (fast tier) model once.
+    if client == r.generalClient && r.escalation != nil && IsRefusal(answer) {
+        fmt.Fprintf(os.Stderr, "⬆️  %s could not answer; asking %s\n", client.Model(), r.escalation.Model())
+        if err := r.budget.Allow(r.escalation, scrubbedQuestion+scrubbedContext); err != nil {
+            return r.protector.Unscrub(answer), nil
+        }
+        escalated, err := call(r.escalation, ctx, scrubbedQuestion, scrubbedContext)
+        if err == nil {
+            r.lastClient = r.escalation
+            answer = escalated
+        }
+    }
+
+    // 5. De-scrub.
     return r.protector.Unscrub(answer), nil
 }
 
+// EscalateTo makes the router re-ask questions the general client refuses
+// to answer from the given context, once, using c (the smart tier).
+func (r *Router) EscalateTo(c *Client) {
+    r.escalation = c
+}
+
+// LastUsage reports the backend and token usage of the most recent answer.
+func (r *Router) LastUsage() Usage {
+    if r.lastClient == nil {
+        return Usage{}
+    }
+    usage := r.lastClient.LastUsage()
+    if usage.Backend == "" {
+        // the call failed before usage was recorded
+        usage.Backend = r.lastClient.Backend()
+        usage.Model = r.lastClient.Model()
+    }
+    return usage
+}
+
 func (r *Router) chooseClient(lowerQ string) *Client {
     if r.archClient == nil {
         return r.generalClient
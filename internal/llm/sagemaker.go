@@ -0,0 +1,225 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// sageMakerFormat is the request body a SageMaker endpoint's container
+// expects and where its response keeps the generated text.
+type sageMakerFormat struct {
+	contentType string
+	request     *template.Template
+	// response is a JMESPath-style path, such as "[0].generated_text" or
+	// "choices[0].message.content || choices[0].text"; responseTemplate is
+	// used instead when the setting is a Go template
+	response         string
+	responseTemplate *template.Template
+	// customAttributes is passed to the container, e.g. accept_eula=true
+	// for JumpStart Llama endpoints
+	customAttributes string
+	// stream requests a TGI token stream, so long answers are not cut off
+	// by the 60 second InvokeEndpoint timeout
+	stream bool
+}
+
+// sageMakerRequest is the data request templates are executed with.
+type sageMakerRequest struct {
+	Prompt      string
+	MaxTokens   int
+	Temperature float64
+	Endpoint    string
+	Model       string
+	Stream      bool
+}
+
+// sageMakerFormats are the built-in formats of common serving containers.
+var sageMakerFormats = map[string]struct {
+	request, response string
+	// streams is set for containers that stream TGI events
+	streams bool
+}{
+	"default": {
+		request:  `{"prompt": {{json .Prompt}}, "max_tokens": {{.MaxTokens}}, "temperature": {{.Temperature}}}`,
+		response: "response || output || text",
+	},
+	// Hugging Face Text Generation Inference, which JumpStart LLMs run on
+	"tgi": {
+		request:  `{"inputs": {{json .Prompt}}, "parameters": {"max_new_tokens": {{.MaxTokens}}, "temperature": {{.Temperature}}, "return_full_text": false}, "stream": {{.Stream}}}`,
+		response: "[0].generated_text || generated_text",
+		streams:  true,
+	},
+	// Deep Java Library (LMI) containers
+	"djl": {
+		request:  `{"inputs": {{json .Prompt}}, "parameters": {"max_new_tokens": {{.MaxTokens}}, "temperature": {{.Temperature}}}}`,
+		response: "generated_text || [0].generated_text",
+	},
+	// vLLM and other OpenAI-compatible servers
+	"vllm": {
+		request:  `{"model": {{json .Model}}, "messages": [{"role": "user", "content": {{json .Prompt}}}], "max_tokens": {{.MaxTokens}}, "temperature": {{.Temperature}}}`,
+		response: "choices[0].message.content || choices[0].text",
+	},
+}
+
+var sageMakerFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// loadSageMakerFormat reads the format of an endpoint from the sagemaker
+// config section. Settings of the endpoint override those of the section,
+// and request or response override the format's:
+//
+//	sagemaker:
+//	  format: tgi                      # default, tgi, djl or vllm
+//	  endpoints:
+//	    my-endpoint:
+//	      format: vllm
+//	      request: '{"inputs": {{json .Prompt}}}'   # Go template
+//	      response: 'outputs[0]'                    # JMESPath-style path, or a Go template
+//	      content_type: application/json
+//	    jumpstart-llama:
+//	      format: tgi
+//	      custom_attributes: accept_eula=true       # required by JumpStart Llama 2 endpoints
+//	      stream: false                             # tgi streams unless turned off
+//
+// Request templates see .Prompt, .MaxTokens, .Temperature, .Endpoint,
+// .Model and .Stream; json renders a value as JSON. Response templates see
+// the decoded response body. Streaming needs the format's own response
+// handling, so a custom response turns it off.
+func loadSageMakerFormat(endpoint string) (*sageMakerFormat, error) {
+	setting := func(key string) string {
+		if value := viper.GetString("sagemaker.endpoints." + endpoint + "." + key); value != "" {
+			return value
+		}
+		return viper.GetString("sagemaker." + key)
+	}
+
+	name := setting("format")
+	if name == "" {
+		name = "default"
+	}
+	builtin, ok := sageMakerFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown SageMaker format %q for endpoint %s: use default, tgi, djl or vllm", name, endpoint)
+	}
+	request, response := builtin.request, builtin.response
+	if custom := setting("request"); custom != "" {
+		request = custom
+	}
+	if custom := setting("response"); custom != "" {
+		response = custom
+	}
+
+	format := &sageMakerFormat{
+		contentType:      setting("content_type"),
+		response:         response,
+		customAttributes: setting("custom_attributes"),
+		stream:           builtin.streams && setting("response") == "" && setting("stream") != "false",
+	}
+	if format.contentType == "" {
+		format.contentType = "application/json"
+	}
+	var err error
+	if format.request, err = template.New("request").Funcs(sageMakerFuncs).Parse(request); err != nil {
+		return nil, fmt.Errorf("invalid SageMaker request template for endpoint %s: %w", endpoint, err)
+	}
+	if strings.Contains(response, "{{") {
+		if format.responseTemplate, err = template.New("response").Funcs(sageMakerFuncs).Parse(response); err != nil {
+			return nil, fmt.Errorf("invalid SageMaker response template for endpoint %s: %w", endpoint, err)
+		}
+	}
+	return format, nil
+}
+
+// body renders the request body for a prompt.
+func (f *sageMakerFormat) body(data sageMakerRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.request.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render the SageMaker request template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// text extracts the generated text from a response body.
+func (f *sageMakerFormat) text(body []byte) (string, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return "", fmt.Errorf("failed to parse sagemaker response: %w", err)
+	}
+	if f.responseTemplate != nil {
+		var buf bytes.Buffer
+		if err := f.responseTemplate.Execute(&buf, decoded); err != nil {
+			return "", fmt.Errorf("failed to render the SageMaker response template: %w", err)
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+	for _, alternative := range strings.Split(f.response, "||") {
+		if text, ok := lookupPath(decoded, strings.TrimSpace(alternative)); ok {
+			return strings.TrimSpace(text), nil
+		}
+	}
+	preview := string(body)
+	if runes := []rune(preview); len(runes) > 200 {
+		preview = string(runes[:200]) + "..."
+	}
+	return "", fmt.Errorf("sagemaker response has no text at %q (set sagemaker.endpoints.<name>.response): %s", f.response, preview)
+}
+
+// lookupPath follows a path of field names and [n] indexes, the subset of
+// JMESPath that response bodies need. Text found is returned as is, other
+// non-empty values as JSON.
+func lookupPath(value interface{}, path string) (string, bool) {
+	rest := path
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", false
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			list, ok := value.([]interface{})
+			if err != nil || !ok {
+				return "", false
+			}
+			if index < 0 {
+				index += len(list)
+			}
+			if index < 0 || index >= len(list) {
+				return "", false
+			}
+			value, rest = list[index], rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			value, rest = object[rest[:end]], rest[end:]
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, v != ""
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err == nil
+	}
+}
@@ -0,0 +1,322 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxStructuredAttempts bounds the retry-on-invalid loop of GenerateStructured
+const maxStructuredAttempts = 3
+
+// ErrInvalidOutput is returned when the model keeps replying with output that
+// does not match the requested schema.
+var ErrInvalidOutput = errors.New("model output does not match the schema")
+
+// Schema is a JSON Schema document. Validate supports the subset used for
+// model output: type, properties, required, additionalProperties, items and
+// enum.
+type Schema map[string]interface{}
+
+// MarshalJSON lets a Schema be passed where the OpenAI client expects a
+// json.Marshaler.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}(s))
+}
+
+// Validate checks a decoded JSON value against the schema.
+func (s Schema) Validate(v interface{}) error {
+	return validateValue(v, s, "$")
+}
+
+func validateValue(v interface{}, schema map[string]interface{}, path string) error {
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if v == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		extra, _ := schema["additionalProperties"].(map[string]interface{})
+		for name, value := range obj {
+			sub, ok := properties[name].(map[string]interface{})
+			if !ok {
+				sub = extra
+			}
+			if sub == nil {
+				continue
+			}
+			if err := validateValue(value, sub, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		if sub, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				if err := validateValue(item, sub, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+	case "number", "integer":
+		n, ok := v.(float64)
+		if !ok || (schema["type"] == "integer" && n != float64(int64(n))) {
+			return fmt.Errorf("%s: expected a %s", path, schema["type"])
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+	}
+	return nil
+}
+
+// querySchema describes the Query the parser must return. The intent is
+// restricted to the registered intents.
+func querySchema() Schema {
+	intentMu.RLock()
+	intents := []interface{}{"unknown"}
+	names := make([]string, 0, len(intentDescriptions))
+	for name := range intentDescriptions {
+		names = append(names, name)
+	}
+	intentMu.RUnlock()
+	sort.Strings(names)
+	for _, name := range names {
+		intents = append(intents, name)
+	}
+
+	return Schema{
+		"type":     "object",
+		"required": []interface{}{"intent", "service", "action", "params"},
+		"properties": map[string]interface{}{
+			"intent":  map[string]interface{}{"type": "string", "enum": intents},
+			"service": map[string]interface{}{"type": "string"},
+			"action":  map[string]interface{}{"type": "string"},
+			"params": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"raw_query": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+// GenerateStructured asks the model for a JSON value that matches schema,
+// using the backend's native structured output: Bedrock tool use, OpenAI
+// response_format json_schema or Ollama format. Replies that fail validation
+// are retried with the validation error appended to the prompt, up to
+// maxStructuredAttempts times, before ErrInvalidOutput is returned. Every
+// attempt is checked against cost.daily_limit and tracked like Answer's
+// requests; LastUsage returns the usage of all attempts together.
+func (c *Client) GenerateStructured(ctx context.Context, name, prompt string, schema Schema) (json.RawMessage, error) {
+	c.lastUsage = Usage{}
+	total := Usage{Backend: c.Backend(), Model: c.Model()}
+	defer func() { c.lastUsage = total }()
+
+	var lastErr error
+	for attempt := 1; attempt <= maxStructuredAttempts; attempt++ {
+		attemptPrompt := prompt
+		if lastErr != nil {
+			attemptPrompt += fmt.Sprintf("\n\nYour previous reply was rejected (%v). Reply again with only a JSON object that matches the schema.", lastErr)
+		}
+
+		raw, usage, err := c.generateJSON(ctx, name, attemptPrompt, schema)
+		total.InputTokens += usage.InputTokens
+		total.OutputTokens += usage.OutputTokens
+		total.Cost += usage.Cost
+		total.Latency += usage.Latency
+		if err != nil {
+			return nil, err
+		}
+		// Fallback paths return free text that may wrap or mangle the JSON
+		if extracted, err := ExtractJSON(string(raw)); err == nil {
+			raw = []byte(extracted)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			lastErr = fmt.Errorf("not valid JSON: %v", err)
+			continue
+		}
+		if err := schema.Validate(v); err != nil {
+			lastErr = err
+			continue
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("%w: %s after %d attempts: %v", ErrInvalidOutput, name, maxStructuredAttempts, lastErr)
+}
+
+// generateProtected is GenerateStructured with the general client behind
+// the same redaction, PII and budget checks as Answer. build makes the
+// prompt from the protected texts; the reply has its placeholders restored.
+func (r *Router) generateProtected(ctx context.Context, name string, schema Schema, build func([]string) string, texts ...string) ([]byte, error) {
+	client := r.generalClient
+	scrubbed := make([]string, len(texts))
+	for i, text := range texts {
+		scrubbed[i] = r.protector.Scrub(text)
+	}
+	protected, err := r.pii.Protect(ctx, client, r.protector, scrubbed...)
+	if err != nil {
+		return nil, err
+	}
+	prompt := build(protected)
+
+	r.lastClient = client
+	raw, err := client.GenerateStructured(ctx, name, prompt, schema)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(r.protector.Unscrub(string(raw))), nil
+}
+
+// generateJSON sends one structured-output request to the active backend,
+// after the same budget check the Router makes for answers, and tracks its
+// cost like complete.
+func (c *Client) generateJSON(ctx context.Context, name, prompt string, schema Schema) ([]byte, Usage, error) {
+	if c.costManager != nil {
+		if err := (&BudgetGuard{costs: c.costManager}).Allow(c, prompt); err != nil {
+			return nil, Usage{}, err
+		}
+	}
+	if err := activePolicy.allow(c, prompt); err != nil {
+		return nil, Usage{}, err
+	}
+
+	start := time.Now()
+	var raw []byte
+	var reported Usage // the token counts the backend reports, if any
+	var err error
+	switch {
+	case c.useAWS:
+		raw, reported, err = c.awsClient.GenerateJSON(ctx, name, prompt, schema)
+	case c.useOllama:
+		raw, reported, err = c.generateJSONWithOllama(ctx, prompt, schema)
+	case c.useTGI:
+		raw, reported, err = c.generateJSONWithTGI(ctx, prompt, schema)
+	case c.useMock:
+		raw, err = json.Marshal(mockJSON(schema))
+	default:
+		raw, reported, err = c.generateJSONWithOpenAI(ctx, name, prompt, schema)
+	}
+
+	// Token counts the backend does not report are estimated (~4 chars per token)
+	usage := Usage{Backend: c.Backend(), Model: c.Model(), InputTokens: reported.InputTokens, OutputTokens: reported.OutputTokens}
+	if usage.InputTokens == 0 {
+		usage.InputTokens, usage.estimated = len(prompt)/4, true
+	}
+	if usage.OutputTokens == 0 {
+		usage.OutputTokens, usage.estimated = len(raw)/4, true
+	}
+	c.audit(name, prompt, usage, err == nil)
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("%w: %w", ErrModelUnavailable, err)
+	}
+	usage.Latency = time.Since(start)
+	usage.Project = projectOf(ctx)
+	if !c.useOllama && !c.useTGI {
+		usage.Cost = (&CostManager{}).CalculateCost(usage.InputTokens, usage.OutputTokens, usage.Model)
+	}
+	if c.costManager != nil {
+		if err := c.costManager.TrackUsage(usage); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not record the cost of the request: %v\n", err)
+		}
+	}
+	return raw, usage, nil
+}
+
+// generateJSONWithOllama uses Ollama's format parameter, which constrains
+// decoding to the given JSON schema.
+func (c *Client) generateJSONWithOllama(ctx context.Context, prompt string, schema Schema) ([]byte, Usage, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":  c.ollamaModel,
+		"prompt": prompt,
+		"format": schema,
+		"stream": false,
+	})
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	req, err := newOllamaRequest(ctx, http.MethodPost, c.ollamaURL, "/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return nil, Usage{}, err
+	}
+
+	resp, err := HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("Ollama is not available at %s: %w", c.ollamaURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, Usage{}, ollamaStatusError(c.ollamaURL, resp)
+	}
+
+	var result struct {
+		Response string `json:"response"`
+		ollamaStats
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, Usage{}, fmt.Errorf("failed to parse ollama response: %w", err)
+	}
+	reported := Usage{InputTokens: result.PromptEvalCount, OutputTokens: result.EvalCount}
+	return []byte(strings.TrimSpace(result.Response)), reported, nil
+}
+
+// generateJSONWithOpenAI uses response_format json_schema. Strict mode is off
+// because it forbids open maps such as Query.Params.
+func (c *Client) generateJSONWithOpenAI(ctx context.Context, name, prompt string, schema Schema) ([]byte, Usage, error) {
+	req := c.openAIRequest(prompt)
+	req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: schema,
+		},
+	}
+	resp, err := c.openai.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("openai request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, Usage{}, fmt.Errorf("openai request returned no choices")
+	}
+	reported := Usage{InputTokens: resp.Usage.PromptTokens, OutputTokens: resp.Usage.CompletionTokens}
+	return []byte(resp.Choices[0].Message.Content), reported, nil
+}
@@ -0,0 +1,321 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// APIs of a Text Generation Inference server
+const (
+	tgiAPIChat     = "chat"
+	tgiAPIGenerate = "generate"
+)
+
+// tgiDefaultMaxTokens leaves room for the prompt in the 4096 total tokens
+// TGI allows by default
+const tgiDefaultMaxTokens = 1024
+
+// errTGINoChat is returned by servers older than TGI 1.4, which have no
+// Messages API
+var errTGINoChat = errors.New("TGI server has no /v1/chat/completions")
+
+// tgiStats are the token counts TGI reports
+type tgiStats struct {
+	PromptTokens    int
+	GeneratedTokens int
+}
+
+// tgiDetails is the part of a /generate response's details CloudAI uses
+type tgiDetails struct {
+	GeneratedTokens int `json:"generated_tokens"`
+}
+
+// newTGIClientFromConfig creates a client for a self-hosted Hugging Face
+// Text Generation Inference server. SageMaker JumpStart endpoints run the
+// same container; reach those with model.type sagemaker and the tgi format.
+//
+//	model:
+//	  type: tgi
+//	  url: http://localhost:8080
+//	  name: meta-llama/Llama-3.1-8B-Instruct   # sent as the chat model; TGI serves one
+//	tgi:
+//	  api: chat          # chat (/v1/chat/completions) or generate (/generate)
+//	  stream: true       # stream tokens, so long answers are not cut off by timeouts
+//	  max_tokens: 1024
+//
+// The chat API applies the model's chat template; servers without it fall
+// back to /generate. HF_TOKEN takes precedence over model.auth.bearer_token.
+func newTGIClientFromConfig() (*Client, error) {
+	tgiURL := getConfigString("model.url")
+	if tgiURL == "" {
+		tgiURL = "http://localhost:8080"
+	}
+	model := getConfigString("model.name")
+	if model == "" {
+		model = "tgi"
+	}
+	fmt.Fprintf(os.Stderr, "🤗 Using TGI server from config: %s\n", tgiURL)
+	return &Client{
+		useTGI:      true,
+		tgiURL:      tgiURL,
+		tgiModel:    model,
+		costManager: newCostManagerFromConfig(),
+	}, nil
+}
+
+func (c *Client) answerWithTGI(ctx context.Context, prompt string) (string, tgiStats, error) {
+	stream := !viper.IsSet("tgi.stream") || viper.GetBool("tgi.stream")
+	switch api := getConfigString("tgi.api"); api {
+	case "":
+		text, stats, err := c.tgiChat(ctx, prompt, stream)
+		if errors.Is(err, errTGINoChat) {
+			return c.tgiGenerate(ctx, prompt, stream)
+		}
+		return text, stats, err
+	case tgiAPIChat:
+		return c.tgiChat(ctx, prompt, stream)
+	case tgiAPIGenerate:
+		return c.tgiGenerate(ctx, prompt, stream)
+	default:
+		return "", tgiStats{}, fmt.Errorf("unknown tgi.api %q: use chat or generate", api)
+	}
+}
+
+func tgiMaxTokens() int {
+	if n := viper.GetInt("tgi.max_tokens"); n > 0 {
+		return n
+	}
+	return tgiDefaultMaxTokens
+}
+
+// tgiChat uses the OpenAI-compatible Messages API.
+func (c *Client) tgiChat(ctx context.Context, prompt string, stream bool) (string, tgiStats, error) {
+	resp, err := c.postTGI(ctx, "/v1/chat/completions", map[string]interface{}{
+		"model":       c.tgiModel,
+		"messages":    []map[string]string{{"role": "user", "content": prompt}},
+		"max_tokens":  tgiMaxTokens(),
+		"temperature": 0.1,
+		"stream":      stream,
+	})
+	if err != nil {
+		return "", tgiStats{}, err
+	}
+	defer resp.Body.Close()
+
+	type chatUsage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	}
+	var stats tgiStats
+	if !stream {
+		var result struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+			Usage chatUsage `json:"usage"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return "", stats, fmt.Errorf("failed to decode TGI response: %w", err)
+		}
+		if len(result.Choices) == 0 {
+			return "", stats, fmt.Errorf("TGI returned no choices")
+		}
+		stats = tgiStats{PromptTokens: result.Usage.PromptTokens, GeneratedTokens: result.Usage.CompletionTokens}
+		return result.Choices[0].Message.Content, stats, nil
+	}
+
+	var text strings.Builder
+	err = readServerSentEvents(resp.Body, func(data []byte) error {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *chatUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode TGI stream: %w", err)
+		}
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.Content)
+		}
+		if chunk.Usage != nil {
+			stats = tgiStats{PromptTokens: chunk.Usage.PromptTokens, GeneratedTokens: chunk.Usage.CompletionTokens}
+		}
+		return nil
+	})
+	return text.String(), stats, err
+}
+
+// tgiGenerate uses the native /generate and /generate_stream endpoints,
+// which every TGI version has.
+func (c *Client) tgiGenerate(ctx context.Context, prompt string, stream bool) (string, tgiStats, error) {
+	path := "/generate"
+	if stream {
+		path = "/generate_stream"
+	}
+	resp, err := c.postTGI(ctx, path, tgiGenerateBody(prompt, nil))
+	if err != nil {
+		return "", tgiStats{}, err
+	}
+	defer resp.Body.Close()
+
+	if stream {
+		return readTGIGenerateStream(resp.Body)
+	}
+	var result struct {
+		GeneratedText string     `json:"generated_text"`
+		Details       tgiDetails `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", tgiStats{}, fmt.Errorf("failed to decode TGI response: %w", err)
+	}
+	return result.GeneratedText, tgiStats{GeneratedTokens: result.Details.GeneratedTokens}, nil
+}
+
+// readTGIGenerateStream collects the tokens of a /generate_stream response.
+func readTGIGenerateStream(r io.Reader) (string, tgiStats, error) {
+	var text strings.Builder
+	var stats tgiStats
+	final := ""
+	err := readServerSentEvents(r, func(data []byte) error {
+		var event struct {
+			Token struct {
+				Text    string `json:"text"`
+				Special bool   `json:"special"`
+			} `json:"token"`
+			GeneratedText *string     `json:"generated_text"`
+			Details       *tgiDetails `json:"details"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to decode TGI stream: %w", err)
+		}
+		if !event.Token.Special {
+			text.WriteString(event.Token.Text)
+		}
+		// the last event carries the whole text and the token count
+		if event.GeneratedText != nil {
+			final = *event.GeneratedText
+		}
+		if event.Details != nil {
+			stats.GeneratedTokens = event.Details.GeneratedTokens
+		}
+		return nil
+	})
+	if final != "" {
+		return final, stats, err
+	}
+	return text.String(), stats, err
+}
+
+// generateJSONWithTGI constrains /generate to the schema with TGI's
+// grammar parameter.
+func (c *Client) generateJSONWithTGI(ctx context.Context, prompt string, schema Schema) ([]byte, Usage, error) {
+	resp, err := c.postTGI(ctx, "/generate", tgiGenerateBody(prompt, map[string]interface{}{"type": "json", "value": schema}))
+	if err != nil {
+		return nil, Usage{}, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		GeneratedText string      `json:"generated_text"`
+		Details       *tgiDetails `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, Usage{}, fmt.Errorf("failed to decode TGI response: %w", err)
+	}
+	var reported Usage
+	if result.Details != nil {
+		reported.OutputTokens = result.Details.GeneratedTokens
+	}
+	return []byte(result.GeneratedText), reported, nil
+}
+
+func tgiGenerateBody(prompt string, grammar map[string]interface{}) map[string]interface{} {
+	parameters := map[string]interface{}{
+		"max_new_tokens":   tgiMaxTokens(),
+		"temperature":      0.1,
+		"return_full_text": false,
+		"details":          true,
+	}
+	if grammar != nil {
+		parameters["grammar"] = grammar
+	}
+	return map[string]interface{}{"inputs": prompt, "parameters": parameters}
+}
+
+// postTGI sends a request to the TGI server and checks its status.
+func (c *Client) postTGI(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	req, err := newModelRequest(ctx, http.MethodPost, c.tgiURL, path, bytes.NewReader(b), "HF_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("TGI is not available at %s: %w", c.tgiURL, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr)
+	switch {
+	case resp.StatusCode == http.StatusNotFound && path == "/v1/chat/completions":
+		return nil, errTGINoChat
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("TGI at %s rejected the request (%s): check model.auth and model.headers", c.tgiURL, resp.Status)
+	case apiErr.Error != "":
+		return nil, fmt.Errorf("TGI request failed (%s): %s", resp.Status, apiErr.Error)
+	}
+	return nil, fmt.Errorf("TGI request failed: %s", resp.Status)
+}
+
+// readServerSentEvents calls fn with the data of each event of a TGI or
+// OpenAI-style stream until [DONE]. An event carrying an error ends it.
+func readServerSentEvents(r io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		if string(data) == "[DONE]" {
+			return nil
+		}
+		var streamErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &streamErr) == nil && streamErr.Error != "" {
+			return fmt.Errorf("stream failed: %s", streamErr.Error)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Tiers name the models configured under models.fast and models.smart:
+//
+//	models:
+//	  fast: claude-haiku
+//	  smart: claude-3.5-sonnet
+//	  default_tier: fast        # used when --tier is not given
+//	  escalate: true            # retry fast-tier refusals on the smart tier
+//	  aliases:
+//	    team-llama: ollama:llama3.1:70b
+const (
+	TierFast  = "fast"
+	TierSmart = "smart"
+)
+
+// builtinModelAliases maps short names to backend:model specs. Entries in
+// models.aliases take precedence.
+var builtinModelAliases = map[string]string{
+	"claude-haiku":      "bedrock:anthropic.claude-3-haiku-20240307-v1:0",
+	"claude-3-haiku":    "bedrock:anthropic.claude-3-haiku-20240307-v1:0",
+	"claude-sonnet":     "bedrock:anthropic.claude-3-sonnet-20240229-v1:0",
+	"claude-3-sonnet":   "bedrock:anthropic.claude-3-sonnet-20240229-v1:0",
+	"claude-3.5-sonnet": "bedrock:anthropic.claude-3-5-sonnet-20240620-v1:0",
+	"titan-express":     "bedrock:amazon.titan-text-express-v1",
+	"gpt-4o":            "openai:gpt-4o",
+	"gpt-4o-mini":       "openai:gpt-4o-mini",
+	"gpt-4.1":           "openai:gpt-4.1",
+	"gpt-4.1-mini":      "openai:gpt-4.1-mini",
+}
+
+// ResolveModelAlias turns an alias or model spec into a backend (bedrock,
+// ollama, openai, tgi or mock) and model ID. Specs are "backend:model"; a bare model ID
+// uses the backend of the configured general model.
+func ResolveModelAlias(name string) (backend, model string) {
+	spec := name
+	if alias := viper.GetString("models.aliases." + name); alias != "" {
+		spec = alias
+	} else if alias, ok := builtinModelAliases[name]; ok {
+		spec = alias
+	}
+
+	// Bedrock model IDs contain colons too, so only split on a known backend
+	if prefix, rest, ok := strings.Cut(spec, ":"); ok {
+		switch prefix {
+		case "bedrock", "ollama", "openai", "tgi", "mock":
+			return prefix, rest
+		}
+	}
+
+	switch getConfigString("model.type") {
+	case "aws":
+		return "bedrock", spec
+	case "ollama", "openai", "tgi", "mock":
+		return getConfigString("model.type"), spec
+	}
+	// Bedrock IDs are provider-qualified, e.g. anthropic.claude-...
+	if strings.Contains(spec, ".") && !strings.Contains(spec, ":") {
+		return "bedrock", spec
+	}
+	return "ollama", spec
+}
+
+// NewTierClient creates the client for models.<tier>. It returns nil, nil
+// when the tier is not configured.
+func NewTierClient(tier string) (*Client, error) {
+	name := viper.GetString("models." + tier)
+	if name == "" {
+		return nil, nil
+	}
+	client, err := NewClientForModel(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s tier model %q: %w", tier, name, err)
+	}
+	return client, nil
+}
+
+// NewClientForModel creates a client for an alias or model spec, reusing the
+// connection settings of the general model configuration.
+func NewClientForModel(name string) (*Client, error) {
+	backend, model := ResolveModelAlias(name)
+	switch backend {
+	case "bedrock":
+		region := getConfigString("model.region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = "us-east-1"
+		}
+		awsClient, err := NewAWSClient(&AWSModelConfig{
+			Type:        AWSModelBedrock,
+			ModelID:     model,
+			Region:      region,
+			MaxTokens:   4096,
+			Temperature: 0.1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Client{useAWS: true, awsClient: awsClient, costManager: newCostManagerFromConfig()}, nil
+	case "openai":
+		client, err := newOpenAIClientFromConfig()
+		if err != nil {
+			return nil, err
+		}
+		client.openaiModel = model
+		return client, nil
+	case "tgi":
+		client, err := newTGIClientFromConfig()
+		if err != nil {
+			return nil, err
+		}
+		client.tgiModel = model
+		return client, nil
+	case "mock":
+		return newMockClient()
+	default:
+		ollamaURL := getConfigString("model.url")
+		if ollamaURL == "" {
+			ollamaURL = os.Getenv("OLLAMA_URL")
+		}
+		if ollamaURL == "" {
+			ollamaURL = "http://localhost:11434"
+		}
+		ollamaURL, tunneled := tunneledOllamaURL(ollamaURL)
+		return &Client{useOllama: true, ollamaModel: model, ollamaURL: ollamaURL, tunneled: tunneled, costManager: newCostManagerFromConfig()}, nil
+	}
+}
+
+// refusalPhrases mark answers where the model says the context does not
+// contain what was asked. The RAG prompt asks for the first one verbatim.
+var refusalPhrases = []string{
+	"cannot answer this based on the provided",
+	"can't answer this based on the provided",
+	"cannot answer based on the provided",
+	"does not contain information about",
+	"doesn't contain information about",
+	"not enough information to answer",
+}
+
+// IsRefusal reports whether an answer is the model declining to answer from
+// the given context.
+func IsRefusal(answer string) bool {
+	lower := strings.ToLower(answer)
+	for _, phrase := range refusalPhrases {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	return false
+}
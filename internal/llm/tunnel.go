@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Models a tunnel can carry
+const (
+	TunnelOllama    = "ollama"
+	TunnelSageMaker = "sagemaker"
+)
+
+// tunnelDialTimeout bounds the check that a recorded tunnel still listens.
+const tunnelDialTimeout = 200 * time.Millisecond
+
+// Tunnel is an SSM port-forwarding session opened by 'cloudai tunnel'. While
+// it listens, Ollama requests go to its local port instead of model.url,
+// and SageMaker requests reach a VPC endpoint through it.
+type Tunnel struct {
+	Model     string `json:"model"`
+	LocalPort int    `json:"local_port"`
+	Target    string `json:"target"`
+	// RemoteHost is the host forwarded to through the target, such as a
+	// SageMaker runtime VPC endpoint; empty for a port on the target itself
+	RemoteHost string    `json:"remote_host,omitempty"`
+	Started    time.Time `json:"started"`
+}
+
+// URL is the local address of an Ollama tunnel.
+func (t *Tunnel) URL() string {
+	return fmt.Sprintf("http://localhost:%d", t.LocalPort)
+}
+
+// TunnelPath returns the file an open tunnel is recorded in.
+func TunnelPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "cloudai-tunnel.json")
+	}
+	return filepath.Join(home, ".cloudai", "tunnel.json")
+}
+
+// SaveTunnel records an open tunnel for the model clients of other
+// processes.
+func SaveTunnel(t *Tunnel) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := TunnelPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// RemoveTunnel forgets the recorded tunnel.
+func RemoveTunnel() error {
+	if err := os.Remove(TunnelPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ActiveTunnel returns the recorded tunnel if its local port still accepts
+// connections. A tunnel whose session ended without cleaning up is ignored.
+func ActiveTunnel() *Tunnel {
+	data, err := os.ReadFile(TunnelPath())
+	if err != nil {
+		return nil
+	}
+	var t Tunnel
+	if json.Unmarshal(data, &t) != nil || t.LocalPort == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(t.LocalPort)), tunnelDialTimeout)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return &t
+}
+
+// tunneledOllamaURL returns the local URL of an open Ollama tunnel, or url
+// when there is none.
+func tunneledOllamaURL(url string) (string, bool) {
+	t := ActiveTunnel()
+	if t == nil || t.Model != TunnelOllama {
+		return url, false
+	}
+	fmt.Fprintf(os.Stderr, "🔌 Using Ollama on %s through the SSM tunnel on localhost:%d\n", t.Target, t.LocalPort)
+	return t.URL(), true
+}
+
+// tunnelHTTPClient sends requests for the tunnel's remote host to its local
+// port. Requests keep the remote host name, so TLS verification and SigV4
+// signing see the endpoint they expect.
+func tunnelHTTPClient(t *Tunnel) *http.Client {
+	local := net.JoinHostPort("127.0.0.1", strconv.Itoa(t.LocalPort))
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	transport := HTTPTransport().Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, local)
+	}
+	return &http.Client{Transport: transport}
+}
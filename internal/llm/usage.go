@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// Usage describes the backend that served a request and its token
+// consumption. Token counts come from the backend when it reports them
+// (OpenAI, Ollama) and are estimated at ~4 characters per token otherwise.
+type Usage struct {
+	Backend        string        `json:"backend"`
+	Model          string        `json:"model"`
+	InputTokens    int           `json:"input_tokens"`
+	OutputTokens   int           `json:"output_tokens"`
+	Cost           float64       `json:"cost"`
+	Latency        time.Duration `json:"latency,omitempty"`
+	GenerationTime time.Duration `json:"generation_time,omitempty"`
+	// Project is the project the request was made for, see WithProject
+	Project string `json:"project,omitempty"`
+
+	// estimated is set when the backend did not report both token counts
+	estimated bool
+}
+
+// projectKey is the context key of the project spend is attributed to
+type projectKey struct{}
+
+// defaultProject is the project of requests whose context names none
+var defaultProject string
+
+// SetProject sets the project, e.g. the cache path of the directory a
+// command runs in, that spend is attributed to when the request's context
+// does not name one.
+func SetProject(project string) {
+	defaultProject = project
+}
+
+// WithProject attributes the spend of requests made with ctx to project, for
+// processes such as the daemon that answer for several projects.
+func WithProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, projectKey{}, project)
+}
+
+func projectOf(ctx context.Context) string {
+	if project, ok := ctx.Value(projectKey{}).(string); ok {
+		return project
+	}
+	return defaultProject
+}
+
+// Backend returns the name of the backend this client talks to:
+// bedrock, sagemaker, openai (direct or via AWS) or ollama.
+func (c *Client) Backend() string {
+	switch {
+	case c.useAWS && c.awsClient != nil:
+		return string(c.awsClient.config.Type)
+	case c.useOllama:
+		return "ollama"
+	case c.useTGI:
+		return "tgi"
+	case c.useMock:
+		return "mock"
+	default:
+		return "openai"
+	}
+}
+
+// Model returns the model identifier used for answers.
+func (c *Client) Model() string {
+	switch {
+	case c.useAWS && c.awsClient != nil:
+		if c.awsClient.config.Type == AWSModelSageMaker && c.awsClient.config.EndpointName != "" {
+			return c.awsClient.config.EndpointName
+		}
+		return c.awsClient.config.ModelID
+	case c.useOllama:
+		return c.ollamaModel
+	case c.useTGI:
+		return c.tgiModel
+	case c.useMock:
+		return mockModel
+	case c.openaiModel != "":
+		return c.openaiModel
+	default:
+		return defaultOpenAIModel
+	}
+}
+
+// LastUsage returns the usage of the most recent successful Answer call.
+func (c *Client) LastUsage() Usage {
+	return c.lastUsage
+}
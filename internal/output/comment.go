@@ -0,0 +1,86 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCommentLength keeps a comment under GitHub's 65536-character limit,
+// the lower of the platforms it is posted to
+const maxCommentLength = 65000
+
+// Comment is a pull request comment: a heading and summary that are always
+// shown, and sections readers expand.
+type Comment struct {
+	// Marker identifies the comment among the others on a pull request, so
+	// a later run updates it instead of adding another
+	Marker   string
+	Title    string
+	Summary  string
+	Sections []CommentSection
+}
+
+// CommentSection is a collapsible part of a Comment. Body is markdown.
+type CommentSection struct {
+	Title string
+	Body  string
+	// Open shows the section expanded
+	Open bool
+}
+
+// Markdown renders the comment. Sections that would take it over the length
+// a comment may have are left out and counted at the end.
+func (c *Comment) Markdown() string {
+	var b strings.Builder
+	if c.Marker != "" {
+		fmt.Fprintf(&b, "<!-- %s -->\n", c.Marker)
+	}
+	fmt.Fprintf(&b, "### %s\n\n", c.Title)
+	if c.Summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(c.Summary))
+	}
+	for i, section := range c.Sections {
+		open := ""
+		if section.Open {
+			open = " open"
+		}
+		rendered := fmt.Sprintf("<details%s><summary>%s</summary>\n\n%s\n\n</details>\n\n", open, escapeMarkdown(section.Title), strings.TrimSpace(section.Body))
+		if b.Len()+len(rendered) > maxCommentLength {
+			fmt.Fprintf(&b, "_%d more section(s) left out: the comment would be too long. Run the command locally with --json for everything._\n", len(c.Sections)-i)
+			break
+		}
+		b.WriteString(rendered)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// Markdown renders the table as a markdown table.
+func (t *Table) Markdown() string {
+	if len(t.Rows) == 0 {
+		return "_No matching rows._"
+	}
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(escapeCells(t.Headers), " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(t.Headers)) + "\n")
+	for _, row := range t.Rows {
+		b.WriteString("| " + strings.Join(escapeCells(row), " | ") + " |\n")
+	}
+	if t.Footer != "" {
+		b.WriteString("\n" + t.Footer + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func escapeCells(cells []string) []string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(escapeMarkdown(cell), "\n", " ")
+	}
+	return escaped
+}
+
+// escapeMarkdown keeps resource names and model text from breaking out of a
+// table cell or summary tag
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;").Replace(s)
+}
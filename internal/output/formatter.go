@@ -3,17 +3,25 @@ package output
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
 // Formatter handles output formatting
 type Formatter struct {
 	jsonOutput bool
+	out        io.Writer
 }
 
-// NewFormatter creates a new formatter
+// NewFormatter creates a new formatter that writes to stdout
 func NewFormatter(jsonOutput bool) *Formatter {
-	return &Formatter{jsonOutput: jsonOutput}
+	return NewFormatterTo(os.Stdout, jsonOutput)
+}
+
+// NewFormatterTo creates a new formatter that writes to w
+func NewFormatterTo(w io.Writer, jsonOutput bool) *Formatter {
+	return &Formatter{jsonOutput: jsonOutput, out: w}
 }
 
 // Result represents a query result
@@ -34,7 +42,7 @@ func (f *Formatter) FormatResult(result *Result) error {
 
 // formatJSON outputs result in JSON format
 func (f *Formatter) formatJSON(result *Result) error {
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(f.out)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(result)
 }
@@ -42,31 +50,41 @@ func (f *Formatter) formatJSON(result *Result) error {
 // formatTable outputs result in table format
 func (f *Formatter) formatTable(result *Result) error {
 	if !result.Success {
-		fmt.Printf("❌ Error: %s\n", result.Error)
+		fmt.Fprintf(f.out, "❌ Error: %s\n", result.Error)
 		return nil
 	}
 
-	fmt.Printf("✅ Query: %s\n", result.Query)
+	fmt.Fprintf(f.out, "✅ Query: %s\n", result.Query)
 
-	// Special handling for scan results
-	if result.Query == "scan ." || result.Query == "scan" {
+	// Special handling for scan results and tables
+	if table, ok := result.Data.(*Table); ok {
+		printTable(f.out, table)
+	} else if result.Query == "scan ." || result.Query == "scan" {
 		f.formatScanSummary(result.Data)
 	} else {
 		// For other queries, show a summary of the data
-		fmt.Printf("📊 Data: %+v\n", result.Data)
+		fmt.Fprintf(f.out, "📊 Data: %+v\n", result.Data)
 	}
 	return nil
 }
 
+// keyResourceLabels are the resource types a scan summary lists by name
+var keyResourceLabels = map[string]string{
+	"AWS::Lambda::Function":    "Lambda",
+	"AWS::ApiGateway::RestApi": "API Gateway",
+	"AWS::S3::Bucket":          "S3 Bucket",
+	"AWS::DynamoDB::Table":     "DynamoDB Table",
+}
+
 // formatScanSummary creates a user-friendly summary of scan results
 func (f *Formatter) formatScanSummary(data interface{}) {
 	if infraData, ok := data.(map[string]interface{}); ok {
-		fmt.Println("📋 Infrastructure Summary:")
+		fmt.Fprintln(f.out, "📋 Infrastructure Summary:")
 
 		// Extract and display key resources
 		if resources, ok := infraData["Resources"].(map[string]interface{}); ok {
 			resourceCount := len(resources)
-			fmt.Printf("   • Total Resources: %d\n", resourceCount)
+			fmt.Fprintf(f.out, "   • Total Resources: %d\n", resourceCount)
 
 			// Count by resource type
 			resourceTypes := make(map[string]int)
@@ -80,77 +98,129 @@ func (f *Formatter) formatScanSummary(data interface{}) {
 
 			// Display resource types
 			for resourceType, count := range resourceTypes {
-				fmt.Printf("   • %s: %d\n", resourceType, count)
+				fmt.Fprintf(f.out, "   • %s: %d\n", resourceType, count)
 			}
 
-			// Show some key resources
-			fmt.Println("\n🔍 Key Resources Found:")
+			// Show some key resources by their friendly names
+			names := friendlyNames(infraData["FriendlyNames"])
+			fmt.Fprintln(f.out, "\n🔍 Key Resources Found:")
 			for resourceName, resource := range resources {
-				if resourceMap, ok := resource.(map[string]interface{}); ok {
-					if resourceType, ok := resourceMap["Type"].(string); ok {
-						// Show user-friendly names for common resources
-						switch resourceType {
-						case "AWS::Lambda::Function":
-							// Try to get the actual function name
-							if properties, ok := resourceMap["Properties"].(map[string]interface{}); ok {
-								if functionName, ok := properties["FunctionName"].(string); ok {
-									fmt.Printf("   • Lambda: %s (%s)\n", functionName, resourceName)
-								} else {
-									fmt.Printf("   • Lambda: %s\n", resourceName)
-								}
-							} else {
-								fmt.Printf("   • Lambda: %s\n", resourceName)
-							}
-						case "AWS::ApiGateway::RestApi":
-							// Try to get the actual API name
-							if properties, ok := resourceMap["Properties"].(map[string]interface{}); ok {
-								if apiName, ok := properties["Name"].(string); ok {
-									fmt.Printf("   • API Gateway: %s (%s)\n", apiName, resourceName)
-								} else {
-									fmt.Printf("   • API Gateway: %s\n", resourceName)
-								}
-							} else {
-								fmt.Printf("   • API Gateway: %s\n", resourceName)
-							}
-						case "AWS::S3::Bucket":
-							// Try to get the actual bucket name
-							if properties, ok := resourceMap["Properties"].(map[string]interface{}); ok {
-								if bucketName, ok := properties["BucketName"].(string); ok {
-									fmt.Printf("   • S3 Bucket: %s (%s)\n", bucketName, resourceName)
-								} else {
-									fmt.Printf("   • S3 Bucket: %s\n", resourceName)
-								}
-							} else {
-								fmt.Printf("   • S3 Bucket: %s\n", resourceName)
-							}
-						case "AWS::DynamoDB::Table":
-							// Try to get the actual table name
-							if properties, ok := resourceMap["Properties"].(map[string]interface{}); ok {
-								if tableName, ok := properties["TableName"].(string); ok {
-									fmt.Printf("   • DynamoDB Table: %s (%s)\n", tableName, resourceName)
-								} else {
-									fmt.Printf("   • DynamoDB Table: %s\n", resourceName)
-								}
-							} else {
-								fmt.Printf("   • DynamoDB Table: %s\n", resourceName)
-							}
-						}
-					}
+				resourceMap, _ := resource.(map[string]interface{})
+				resourceType, _ := resourceMap["Type"].(string)
+				label, ok := keyResourceLabels[resourceType]
+				if !ok {
+					continue
+				}
+				if name, ok := names[resourceName]; ok {
+					fmt.Fprintf(f.out, "   • %s: %s (%s)\n", label, name, resourceName)
+				} else {
+					fmt.Fprintf(f.out, "   • %s: %s\n", label, resourceName)
 				}
 			}
 		}
 
 		// Show outputs if available
 		if outputs, ok := infraData["Outputs"].(map[string]interface{}); ok && len(outputs) > 0 {
-			fmt.Printf("\n📤 Outputs: %d\n", len(outputs))
+			fmt.Fprintf(f.out, "\n📤 Outputs: %d\n", len(outputs))
 			for outputName := range outputs {
-				fmt.Printf("   • %s\n", outputName)
+				fmt.Fprintf(f.out, "   • %s\n", outputName)
+			}
+		}
+
+		// Show services skipped because of missing permissions
+		if warnings := scanWarnings(infraData["Warnings"]); len(warnings) > 0 {
+			fmt.Fprintln(f.out, "\n⚠️  Partial scan – some services were skipped (access denied):")
+			for _, w := range warnings {
+				fmt.Fprintf(f.out, "   • %s: grant %s\n", w.service, strings.Join(w.grant, ", "))
 			}
+			fmt.Fprintln(f.out, "   Grant these actions and re-run the scan to improve coverage.")
 		}
 
-		fmt.Println("\n💡 You can now ask questions about your infrastructure!")
-		fmt.Println("   Example: cloudai \"Which Lambda handles GET /hello?\"")
+		// Show hard-coded secrets; they are redacted from the state and never sent to a model
+		if secrets := secretFindings(infraData["SecretFindings"]); len(secrets) > 0 {
+			fmt.Fprintf(f.out, "\n🔑 Hard-coded secrets: %d (redacted, kept out of prompts)\n", len(secrets))
+			for _, s := range secrets {
+				fmt.Fprintf(f.out, "   • %s %s: %s (%s)\n", s.Resource, s.Location, s.Kind, s.Preview)
+			}
+			fmt.Fprintln(f.out, "   Move them to Secrets Manager or SSM Parameter Store. List them again with 'cloudai audit secrets'.")
+		}
+
+		fmt.Fprintln(f.out, "\n💡 You can now ask questions about your infrastructure!")
+		fmt.Fprintln(f.out, "   Example: cloudai \"Which Lambda handles GET /hello?\"")
 	} else {
-		fmt.Printf("📊 Data: %+v\n", data)
+		fmt.Fprintf(f.out, "📊 Data: %+v\n", data)
+	}
+}
+
+type scanWarning struct {
+	service string
+	grant   []string
+}
+
+// scanWarnings extracts permission warnings from scan data. Warnings arrive as
+// typed values straight from a scan and as generic maps once loaded from cache,
+// so both are normalised through JSON.
+func scanWarnings(raw interface{}) []scanWarning {
+	if raw == nil {
+		return nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var decoded []struct {
+		Service string   `json:"service"`
+		Grant   []string `json:"grant"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil
+	}
+	warnings := make([]scanWarning, 0, len(decoded))
+	for _, d := range decoded {
+		warnings = append(warnings, scanWarning{service: d.Service, grant: d.Grant})
+	}
+	return warnings
+}
+
+type secretFinding struct {
+	Resource string `json:"resource"`
+	Location string `json:"location"`
+	Kind     string `json:"kind"`
+	Preview  string `json:"preview"`
+}
+
+// secretFindings extracts the hard-coded secrets found by a scan, normalised
+// through JSON like scanWarnings.
+func secretFindings(raw interface{}) []secretFinding {
+	if raw == nil {
+		return nil
 	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var decoded []secretFinding
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// friendlyNames extracts the logical ID → friendly name table a scan saves
+// with the state, typed straight from a scan and generic once loaded from
+// cache.
+func friendlyNames(raw interface{}) map[string]string {
+	switch names := raw.(type) {
+	case map[string]string:
+		return names
+	case map[string]interface{}:
+		out := make(map[string]string, len(names))
+		for id, name := range names {
+			if s, ok := name.(string); ok {
+				out[id] = s
+			}
+		}
+		return out
+	}
+	return nil
 }
@@ -0,0 +1,49 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Table is tabular result data. Handlers that return a *Table get aligned
+// columns in text mode and plain JSON with --json, with no LLM involved.
+type Table struct {
+	Title   string     `json:"title,omitempty"`
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+	Footer  string     `json:"footer,omitempty"`
+}
+
+// Print writes the table as aligned columns, for commands that show a table
+// as one section of a larger report.
+func (t *Table) Print() {
+	printTable(os.Stdout, t)
+}
+
+// printTable writes t to w as aligned columns
+func printTable(w io.Writer, t *Table) {
+	if t.Title != "" {
+		fmt.Fprintf(w, "📊 %s\n\n", t.Title)
+	}
+	if len(t.Rows) == 0 {
+		fmt.Fprintln(w, "   (no matching rows)")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "   "+strings.Join(t.Headers, "\t"))
+		underline := make([]string, len(t.Headers))
+		for i, h := range t.Headers {
+			underline[i] = strings.Repeat("─", len([]rune(h)))
+		}
+		fmt.Fprintln(tw, "   "+strings.Join(underline, "\t"))
+		for _, row := range t.Rows {
+			fmt.Fprintln(tw, "   "+strings.Join(row, "\t"))
+		}
+		tw.Flush()
+	}
+	if t.Footer != "" {
+		fmt.Fprintf(w, "\n%s\n", t.Footer)
+	}
+}
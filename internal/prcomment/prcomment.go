@@ -0,0 +1,224 @@
+// Package prcomment posts comments to GitHub pull requests and GitLab merge
+// requests, for CI pipelines such as Atlantis and Terraform Cloud run tasks.
+package prcomment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+)
+
+// Providers
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+)
+
+// requestTimeout bounds each API call
+const requestTimeout = 30 * time.Second
+
+// githubPullRef finds the pull request number in GITHUB_REF
+var githubPullRef = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+// Target is the pull or merge request a comment goes to.
+type Target struct {
+	Provider string
+	// APIURL is the API root, such as https://api.github.com or
+	// https://gitlab.example.com/api/v4
+	APIURL string
+	// Repo is owner/name on GitHub, and the project ID or path on GitLab
+	Repo   string
+	Number int
+	Token  string
+}
+
+// TargetFromEnv fills in what provider, repo and number leave empty from the
+// environment of GitHub Actions, GitLab CI or Atlantis. The token is read
+// from GITHUB_TOKEN (or GH_TOKEN) or GITLAB_TOKEN.
+func TargetFromEnv(provider, repo string, number int) (*Target, error) {
+	if provider == "" {
+		switch {
+		case os.Getenv("GITHUB_ACTIONS") == "true":
+			provider = GitHub
+		case os.Getenv("GITLAB_CI") == "true":
+			provider = GitLab
+		case os.Getenv("GITLAB_TOKEN") != "" && githubToken() == "":
+			provider = GitLab
+		case githubToken() != "":
+			provider = GitHub
+		default:
+			return nil, fmt.Errorf("cannot tell whether to post to GitHub or GitLab: pass --provider")
+		}
+	}
+
+	// Atlantis names the repo and pull request the same way for both
+	if repo == "" && os.Getenv("BASE_REPO_OWNER") != "" && os.Getenv("BASE_REPO_NAME") != "" {
+		repo = os.Getenv("BASE_REPO_OWNER") + "/" + os.Getenv("BASE_REPO_NAME")
+	}
+	if number == 0 {
+		number, _ = strconv.Atoi(os.Getenv("PULL_NUM"))
+	}
+
+	target := &Target{Provider: provider, Repo: repo, Number: number}
+	switch provider {
+	case GitHub:
+		target.Token = githubToken()
+		target.APIURL = envOr("GITHUB_API_URL", "https://api.github.com")
+		if target.Repo == "" {
+			target.Repo = os.Getenv("GITHUB_REPOSITORY")
+		}
+		if target.Number == 0 {
+			if match := githubPullRef.FindStringSubmatch(os.Getenv("GITHUB_REF")); match != nil {
+				target.Number, _ = strconv.Atoi(match[1])
+			}
+		}
+	case GitLab:
+		target.Token = os.Getenv("GITLAB_TOKEN")
+		target.APIURL = envOr("CI_API_V4_URL", "https://gitlab.com/api/v4")
+		if target.Repo == "" {
+			target.Repo = os.Getenv("CI_PROJECT_ID")
+		}
+		if target.Number == 0 {
+			target.Number, _ = strconv.Atoi(os.Getenv("CI_MERGE_REQUEST_IID"))
+		}
+	default:
+		return nil, fmt.Errorf("unknown provider %q: use %s or %s", provider, GitHub, GitLab)
+	}
+
+	switch {
+	case target.Token == "":
+		return nil, fmt.Errorf("no %s token: set %s", provider, map[string]string{GitHub: "GITHUB_TOKEN", GitLab: "GITLAB_TOKEN"}[provider])
+	case target.Repo == "":
+		return nil, fmt.Errorf("no repository to post to: pass --repo")
+	case target.Number <= 0:
+		return nil, fmt.Errorf("no pull request to post to: pass --pr")
+	}
+	return target, nil
+}
+
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return strings.TrimRight(value, "/")
+	}
+	return fallback
+}
+
+// comment is a GitHub issue comment or GitLab note
+type comment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url,omitempty"`
+}
+
+// Post adds body as a comment, or updates the comment that carries marker
+// when an earlier run left one, so a pull request keeps a single comment per
+// report. It returns a link to the comment when the API gives one.
+func Post(ctx context.Context, target *Target, marker, body string) (string, error) {
+	existing, err := findComment(ctx, target, marker)
+	if err != nil {
+		return "", err
+	}
+
+	var method, path string
+	switch {
+	case target.Provider == GitHub && existing != nil:
+		method, path = http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", target.Repo, existing.ID)
+	case target.Provider == GitHub:
+		method, path = http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", target.Repo, target.Number)
+	case existing != nil:
+		method, path = http.MethodPut, fmt.Sprintf("/projects/%s/merge_requests/%d/notes/%d", url.PathEscape(target.Repo), target.Number, existing.ID)
+	default:
+		method, path = http.MethodPost, fmt.Sprintf("/projects/%s/merge_requests/%d/notes", url.PathEscape(target.Repo), target.Number)
+	}
+
+	var posted comment
+	if err := call(ctx, target, method, path, map[string]string{"body": body}, &posted); err != nil {
+		return "", err
+	}
+	return posted.HTMLURL, nil
+}
+
+// findComment returns the first comment that carries marker, or nil.
+func findComment(ctx context.Context, target *Target, marker string) (*comment, error) {
+	if marker == "" {
+		return nil, nil
+	}
+	tag := "<!-- " + marker + " -->"
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", target.Repo, target.Number, page)
+		if target.Provider == GitLab {
+			path = fmt.Sprintf("/projects/%s/merge_requests/%d/notes?per_page=100&page=%d", url.PathEscape(target.Repo), target.Number, page)
+		}
+		var comments []comment
+		if err := call(ctx, target, http.MethodGet, path, nil, &comments); err != nil {
+			return nil, err
+		}
+		for i := range comments {
+			if strings.HasPrefix(comments[i].Body, tag) {
+				return &comments[i], nil
+			}
+		}
+		if len(comments) < 100 {
+			return nil, nil
+		}
+	}
+}
+
+func call(ctx context.Context, target *Target, method, path string, payload, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.APIURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if target.Provider == GitHub {
+		req.Header.Set("Authorization", "Bearer "+target.Token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	} else {
+		req.Header.Set("PRIVATE-TOKEN", target.Token)
+	}
+
+	// The shared client honours the proxy and http.ca_bundle of CI runners
+	// behind a TLS-inspecting proxy
+	resp, err := llm.HTTPClient(0).Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", target.Provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
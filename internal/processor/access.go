@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// accessHandler answers whether one resource can access another by
+// evaluating the IAM policies, resource policies, permissions boundaries and
+// service control policies in the cached state. The LLM only words the
+// verdict.
+type accessHandler struct{}
+
+func init() {
+	Register(accessHandler{})
+}
+
+func (accessHandler) Intent() string { return "access" }
+
+func (accessHandler) Description() string {
+	return `permission questions such as "can the orders Lambda write to the audit bucket?" (params.principal = who acts, params.access = a verb such as read, write, delete, list or invoke, or an IAM action like s3:PutObject, params.resource = what is acted on)`
+}
+
+// Local marks the handler as answering from the cached state alone
+func (accessHandler) Local() {}
+
+// accessVerbs are the verbs permission questions use
+const accessVerbs = `read|get|download|fetch|consume|receive|poll|decrypt|subscribe|write|put|upload|store|save|send|publish|push|encrypt|update|delete|remove|list|scan|invoke|call|trigger|execute|start|run|access`
+
+// accessQuestions match "can X write to Y", "does X have (write) access to
+// Y" and "is X allowed to write to Y", capturing the principal, the verb and
+// the resource
+var accessQuestions = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\s*(?:can|could|may)\s+(.+?)\s+(` + accessVerbs + `)\b(?:\s+(?:to|from|into|in|on|with|using|objects\s+in|items\s+in|messages\s+(?:to|from)))?\s+(.+?)[\s?.!]*$`),
+	regexp.MustCompile(`(?i)^\s*(?:does|do)\s+(.+?)\s+have\s+(?:(` + accessVerbs + `)\s+)?(?:access|permissions?)\s+(?:to|on|for)\s+(.+?)[\s?.!]*$`),
+	regexp.MustCompile(`(?i)^\s*(?:is|are)\s+(.+?)\s+(?:allowed|able|permitted)\s+to\s+(` + accessVerbs + `)\b(?:\s+(?:to|from|into|in|on|with|using))?\s+(.+?)[\s?.!]*$`),
+}
+
+// accessPronouns ask what the user can do, which the scan does not know
+var accessPronouns = map[string]bool{"i": true, "we": true, "you": true, "they": true, "it": true}
+
+// Match recognises "can X read Y", "does X have access to Y" and "is X
+// allowed to write to Y" about a principal other than the user.
+func (h accessHandler) Match(rawQuery string) (*llm.Query, int) {
+	for _, pattern := range accessQuestions {
+		match := pattern.FindStringSubmatch(rawQuery)
+		if match == nil || accessPronouns[strings.ToLower(match[1])] {
+			continue
+		}
+		access := strings.ToLower(match[2])
+		if access == "" {
+			access = "access"
+		}
+		return &llm.Query{
+			Intent:   h.Intent(),
+			Action:   "check_access",
+			RawQuery: rawQuery,
+			Params: map[string]string{
+				"principal": match[1],
+				"access":    access,
+				"resource":  match[3],
+			},
+		}, patternScore
+	}
+	return nil, 0
+}
+
+// Handle evaluates the policies in the cached state for the question. When
+// the principal or resource is not in the cache, or not one resource, the
+// question is left to the cache context.
+func (accessHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	principal, resource := query.Params["principal"], query.Params["resource"]
+	if env.CachedState == nil || principal == "" || resource == "" {
+		return nil, ErrNotHandled
+	}
+	infraState, err := env.CachedState()
+	if err != nil {
+		return nil, ErrNotHandled
+	}
+	access := query.Params["access"]
+	if access == "" {
+		access = "access"
+	}
+	report, err := state.Access(infraState, principal, access, resource)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotHandled, err)
+	}
+	return report, nil
+}
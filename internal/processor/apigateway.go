@@ -0,0 +1,227 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway/types"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// apiGatewayLambdaHandler answers which Lambda handles an API Gateway route
+type apiGatewayLambdaHandler struct{}
+
+func init() {
+	Register(apiGatewayLambdaHandler{})
+}
+
+func (apiGatewayLambdaHandler) Intent() string { return "api_gateway_lambda" }
+
+func (apiGatewayLambdaHandler) Description() string {
+	return "queries about which Lambda handles API Gateway requests or implements an OpenAPI operation (params: method, path, api, operation)"
+}
+
+// routePattern extracts METHOD /path on api-name
+var routePattern = regexp.MustCompile(`(?i)(GET|POST|PUT|DELETE|PATCH)\s+([/\w-]+)\s+(?:on|in)\s+([\w-]+)`)
+
+// operationPattern extracts the operation ID of "the createOrder operation"
+// or "operation createOrder"
+var operationPattern = regexp.MustCompile(`(?i)(?:\b([A-Za-z][\w-]*)\s+operation\b|\boperation\s+([A-Za-z][\w-]*))`)
+
+// operationFillers are words operationPattern can catch instead of an ID
+var operationFillers = map[string]bool{"the": true, "an": true, "this": true, "that": true, "which": true, "api": true}
+
+// Match recognises "lambda" together with "api", "gateway", a route or an
+// operation ID, e.g. "which lambda handles GET /orders in the shop api?".
+func (h apiGatewayLambdaHandler) Match(rawQuery string) (*llm.Query, int) {
+	w := words(rawQuery)
+	operation := operationID(rawQuery)
+	matches := routePattern.FindStringSubmatch(rawQuery)
+	if !w.hasPrefix("lambda") {
+		return nil, 0
+	}
+	score := 1
+	for _, found := range []bool{w.has("api", "apis"), w.hasPrefix("gateway"), len(matches) == 4, operation != ""} {
+		if found {
+			score++
+		}
+	}
+	if score == 1 {
+		return nil, 0
+	}
+
+	query := &llm.Query{
+		Intent:   h.Intent(),
+		Service:  "apigateway",
+		Action:   "get_integration",
+		RawQuery: rawQuery,
+		Params:   make(map[string]string),
+	}
+	if len(matches) == 4 {
+		query.Params["method"] = strings.ToUpper(matches[1])
+		query.Params["path"] = matches[2]
+		query.Params["api"] = matches[3]
+	}
+	if operation != "" {
+		query.Params["operation"] = operation
+	}
+	return query, score
+}
+
+// operationID returns the OpenAPI operation a question names, or "".
+func operationID(rawQuery string) string {
+	for _, m := range operationPattern.FindAllStringSubmatch(rawQuery, -1) {
+		for _, id := range m[1:] {
+			if id != "" && !operationFillers[strings.ToLower(id)] {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// Handle handles API Gateway to Lambda queries
+func (apiGatewayLambdaHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	// Extract parameters from query
+	apiName := query.Params["api"]
+	httpMethod := query.Params["method"]
+	path := query.Params["path"]
+
+	// List all REST APIs
+	apis, err := env.AWS.APIGateway.GetRestApis(ctx, &apigateway.GetRestApisInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API Gateways: %w", err)
+	}
+
+	if operation := query.Params["operation"]; operation != "" && path == "" {
+		return findOperation(ctx, env, apis.Items, apiName, operation)
+	}
+
+	// Find the target API
+	var targetAPI *types.RestApi
+	for _, api := range apis.Items {
+		if apiName == "" || *api.Name == apiName {
+			targetAPI = &api
+			break
+		}
+	}
+
+	if targetAPI == nil {
+		// Return available APIs
+		apiNames := make([]string, len(apis.Items))
+		for i, api := range apis.Items {
+			apiNames[i] = *api.Name
+		}
+		return map[string]interface{}{
+			"message":        fmt.Sprintf("API Gateway '%s' not found", apiName),
+			"available_apis": apiNames,
+		}, nil
+	}
+
+	// Get resources for the API
+	resources, err := env.AWS.APIGateway.GetResources(ctx, &apigateway.GetResourcesInput{
+		RestApiId: targetAPI.Id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API resources: %w", err)
+	}
+
+	// Find the resource matching the path
+	var targetResource *types.Resource
+	for _, resource := range resources.Items {
+		if resource.ResourceMethods != nil {
+			if _, ok := resource.ResourceMethods[httpMethod]; ok && *resource.Path == path {
+				targetResource = &resource
+				break
+			}
+		}
+	}
+
+	if targetResource == nil {
+		return map[string]interface{}{
+			"message":  fmt.Sprintf("Path '%s' with method '%s' not found in API '%s'", path, httpMethod, *targetAPI.Name),
+			"api_name": *targetAPI.Name,
+			"api_id":   *targetAPI.Id,
+		}, nil
+	}
+
+	// Get the method integration
+	method, err := env.AWS.APIGateway.GetMethod(ctx, &apigateway.GetMethodInput{
+		RestApiId:  targetAPI.Id,
+		ResourceId: targetResource.Id,
+		HttpMethod: awssdk.String(httpMethod),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get method: %w", err)
+	}
+
+	// Extract Lambda function name from integration URI
+	var lambdaName string
+	if method.MethodIntegration != nil && method.MethodIntegration.Uri != nil {
+		lambdaName = state.LambdaFromIntegrationURI(*method.MethodIntegration.Uri)
+	}
+
+	return map[string]interface{}{
+		"api_name":    *targetAPI.Name,
+		"api_id":      *targetAPI.Id,
+		"path":        *targetResource.Path,
+		"method":      httpMethod,
+		"lambda_name": lambdaName,
+	}, nil
+}
+
+// findOperation looks an operation ID up in the OpenAPI export of each
+// REST API, or of the named one, with its integrations.
+func findOperation(ctx context.Context, env *Env, apis []types.RestApi, apiName, operation string) (interface{}, error) {
+	searched := []string{}
+	for _, api := range apis {
+		if apiName != "" && awssdk.ToString(api.Name) != apiName {
+			continue
+		}
+		// an export is per stage; any stage has the operation IDs
+		stages, err := env.AWS.APIGateway.GetStages(ctx, &apigateway.GetStagesInput{RestApiId: api.Id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stages of %s: %w", awssdk.ToString(api.Name), err)
+		}
+		if len(stages.Item) == 0 {
+			continue
+		}
+		export, err := env.AWS.APIGateway.GetExport(ctx, &apigateway.GetExportInput{
+			RestApiId:  api.Id,
+			StageName:  stages.Item[0].StageName,
+			ExportType: awssdk.String("oas30"),
+			Accepts:    awssdk.String("application/json"),
+			Parameters: map[string]string{"extensions": "integrations"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to export the OpenAPI definition of %s: %w", awssdk.ToString(api.Name), err)
+		}
+		searched = append(searched, awssdk.ToString(api.Name))
+
+		ops, err := state.ParseOpenAPI(export.Body)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range ops {
+			if strings.EqualFold(op.OperationID, operation) {
+				return map[string]interface{}{
+					"api_name":    awssdk.ToString(api.Name),
+					"api_id":      awssdk.ToString(api.Id),
+					"operation":   op.OperationID,
+					"path":        op.Path,
+					"method":      op.Method,
+					"lambda_name": op.Lambda,
+				}, nil
+			}
+		}
+	}
+	return map[string]interface{}{
+		"message":       fmt.Sprintf("Operation '%s' not found", operation),
+		"searched_apis": searched,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+)
+
+// costTopHandler answers which services cost the most
+type costTopHandler struct{}
+
+func init() {
+	Register(costTopHandler{})
+}
+
+func (costTopHandler) Intent() string { return "cost_top" }
+
+func (costTopHandler) Description() string {
+	return "queries about top cost services"
+}
+
+const (
+	defaultCostLimit = 5
+	defaultCostDays  = 30
+)
+
+var (
+	topNPattern   = regexp.MustCompile(`(?i)\btop\s+(\d+)`)
+	periodPattern = regexp.MustCompile(`(?i)(\d+)\s*(day|week|month)s?`)
+)
+
+// Match recognises cost, spend or expensive together with top, most or
+// service, e.g. "top 5 services by cost in the last 30 days".
+func (h costTopHandler) Match(rawQuery string) (*llm.Query, int) {
+	w := words(rawQuery)
+	if !w.hasPrefix("cost", "spend") && !w.has("expensive") {
+		return nil, 0
+	}
+	if !w.has("top", "most") && !w.hasPrefix("service") {
+		return nil, 0
+	}
+
+	params := make(map[string]string)
+	if m := topNPattern.FindStringSubmatch(rawQuery); m != nil {
+		params["limit"] = m[1]
+	}
+	if m := periodPattern.FindStringSubmatch(rawQuery); m != nil {
+		params["period"] = m[1] + " " + strings.ToLower(m[2]) + "s"
+	} else if w.hasPrefix("week") {
+		params["period"] = "7 days"
+	}
+	return &llm.Query{Intent: h.Intent(), Service: "costexplorer", Action: "get_cost", RawQuery: rawQuery, Params: params}, 2
+}
+
+// periodDays converts a period such as "7 days", "2 weeks" or "1 month" to
+// a number of days
+func periodDays(period string) int {
+	m := periodPattern.FindStringSubmatch(period)
+	if m == nil {
+		return defaultCostDays
+	}
+	n, _ := strconv.Atoi(m[1])
+	switch strings.ToLower(m[2]) {
+	case "week":
+		n *= 7
+	case "month":
+		n *= 30
+	}
+	if n < 1 {
+		return defaultCostDays
+	}
+	return n
+}
+
+// Handle handles cost top queries
+func (costTopHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	limit, err := strconv.Atoi(query.Params["limit"])
+	if err != nil || limit < 1 {
+		limit = defaultCostLimit
+	}
+	days := periodDays(query.Params["period"])
+
+	// Cost Explorer end dates are exclusive, so the window ends today
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -days)
+
+	services, currency, err := env.AWS.TopServices(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var total float64
+	for _, s := range services {
+		total += s.Cost
+	}
+	if len(services) > limit {
+		services = services[:limit]
+	}
+
+	table := &output.Table{
+		Title:   fmt.Sprintf("Top %d services by cost, %s to %s", len(services), start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")),
+		Headers: []string{"Service", "Cost (" + currency + ")", "Share"},
+		Footer:  fmt.Sprintf("Total for all services: %.2f %s", total, currency),
+	}
+	for _, s := range services {
+		share := 0.0
+		if total > 0 {
+			share = s.Cost / total * 100
+		}
+		table.Rows = append(table.Rows, []string{s.Service, fmt.Sprintf("%.2f", s.Cost), fmt.Sprintf("%.1f%%", share)})
+	}
+	return table, nil
+}
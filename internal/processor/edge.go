@@ -0,0 +1,522 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// maxEdgeDepth stops alias chains that loop back on themselves
+const maxEdgeDepth = 6
+
+// edgeRoutingHandler answers "what does api.example.com point to?" by
+// following Route53 records through CloudFront, load balancers and API
+// Gateway down to the Lambda functions that serve the traffic.
+type edgeRoutingHandler struct{}
+
+func init() {
+	Register(edgeRoutingHandler{})
+}
+
+func (edgeRoutingHandler) Intent() string { return "edge_routing" }
+
+func (edgeRoutingHandler) Description() string {
+	return "queries about what a domain name points to (Route53, CloudFront, load balancers, API Gateway); put the hostname in params.domain"
+}
+
+// edgeHop is one step from a hostname towards whatever serves it
+type edgeHop struct {
+	Service  string    `json:"service"`
+	Resource string    `json:"resource"`
+	Detail   string    `json:"detail,omitempty"`
+	Next     []edgeHop `json:"next,omitempty"`
+}
+
+var (
+	hostnamePattern = regexp.MustCompile(`(?i)\b((?:[a-z0-9-]+\.)+[a-z]{2,})\b`)
+	edgeKeywords    = []string{"point", "route", "resolve", "serve", "behind", "front door", "go to", "goes to"}
+)
+
+// Match recognises a hostname with one of edgeKeywords, such as "point",
+// "route" or "behind", e.g. "what does api.example.com point to?".
+func (h edgeRoutingHandler) Match(rawQuery string) (*llm.Query, int) {
+	host := hostnamePattern.FindString(rawQuery)
+	if host == "" {
+		return nil, 0
+	}
+	w := words(rawQuery)
+	for _, kw := range edgeKeywords {
+		if w.hasPrefix(kw) {
+			return &llm.Query{
+				Intent:   h.Intent(),
+				Service:  "route53",
+				Action:   "resolve",
+				RawQuery: rawQuery,
+				Params:   map[string]string{"domain": strings.ToLower(host)},
+			}, 2
+		}
+	}
+	return nil, 0
+}
+
+// Handle resolves the domain and returns the routing tree plus one readable
+// line per path through it
+func (edgeRoutingHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	domain := strings.ToLower(strings.TrimSuffix(query.Params["domain"], "."))
+	if domain == "" {
+		domain = strings.ToLower(hostnamePattern.FindString(query.RawQuery))
+	}
+	if domain == "" {
+		return map[string]string{"message": "No domain name found in the question"}, nil
+	}
+
+	r := &edgeResolver{env: env}
+	hops, err := r.resolveName(ctx, domain, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, hop := range hops {
+		paths = append(paths, flattenHops(domain, hop)...)
+	}
+	return map[string]interface{}{
+		"domain": domain,
+		"routes": hops,
+		"paths":  paths,
+	}, nil
+}
+
+// edgeResolver caches the account-wide listings shared by every hop
+type edgeResolver struct {
+	env   *Env
+	zones map[string]string // zone name -> hosted zone ID
+}
+
+// resolveName looks the name up in Route53 first and falls back to
+// recognising AWS service hostnames directly
+func (r *edgeResolver) resolveName(ctx context.Context, name string, depth int) ([]edgeHop, error) {
+	if depth > maxEdgeDepth {
+		return []edgeHop{{Service: "unresolved", Resource: name, Detail: "alias chain too deep"}}, nil
+	}
+
+	zoneID, err := r.zoneFor(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if zoneID == "" {
+		hop, err := r.resolveTarget(ctx, name, depth)
+		if err != nil {
+			return nil, err
+		}
+		return []edgeHop{hop}, nil
+	}
+
+	records, err := r.recordsNamed(ctx, zoneID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var hops []edgeHop
+	for _, record := range records {
+		recordType := string(record.Type)
+		switch {
+		case record.AliasTarget != nil:
+			target := strings.TrimSuffix(awssdk.ToString(record.AliasTarget.DNSName), ".")
+			next, err := r.resolveTarget(ctx, target, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			hops = append(hops, edgeHop{Service: "route53", Resource: name, Detail: recordType + " alias to " + target, Next: []edgeHop{next}})
+		case recordType == "CNAME":
+			for _, value := range record.ResourceRecords {
+				target := strings.TrimSuffix(awssdk.ToString(value.Value), ".")
+				next, err := r.resolveName(ctx, target, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				hops = append(hops, edgeHop{Service: "route53", Resource: name, Detail: "CNAME to " + target, Next: next})
+			}
+		case recordType == "A" || recordType == "AAAA":
+			var ips []string
+			for _, value := range record.ResourceRecords {
+				ips = append(ips, awssdk.ToString(value.Value))
+			}
+			hops = append(hops, edgeHop{Service: "route53", Resource: name, Detail: recordType + " " + strings.Join(ips, ", ")})
+		}
+	}
+
+	if len(hops) == 0 {
+		hops = append(hops, edgeHop{Service: "route53", Resource: name, Detail: "no A, AAAA or CNAME record in hosted zone " + zoneID})
+	}
+	return hops, nil
+}
+
+// recordsNamed lists the records of the hosted zone named name. Records are
+// sorted by name, so listing stops at the first one with another name.
+func (r *edgeResolver) recordsNamed(ctx context.Context, zoneID, name string) ([]route53types.ResourceRecordSet, error) {
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    awssdk.String(zoneID),
+		StartRecordName: awssdk.String(name),
+	}
+	var records []route53types.ResourceRecordSet
+	for {
+		out, err := r.env.AWS.Route53.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Route53 records: %w", err)
+		}
+		for _, record := range out.ResourceRecordSets {
+			if strings.TrimSuffix(awssdk.ToString(record.Name), ".") != name {
+				return records, nil
+			}
+			records = append(records, record)
+		}
+		// The SDK has no paginator for record sets
+		if !out.IsTruncated {
+			return records, nil
+		}
+		input.StartRecordName = out.NextRecordName
+		input.StartRecordType = out.NextRecordType
+		input.StartRecordIdentifier = out.NextRecordIdentifier
+	}
+}
+
+// zoneFor returns the most specific hosted zone containing name, or ""
+func (r *edgeResolver) zoneFor(ctx context.Context, name string) (string, error) {
+	if r.zones == nil {
+		r.zones = make(map[string]string)
+		paginator := route53.NewListHostedZonesPaginator(r.env.AWS.Route53, &route53.ListHostedZonesInput{})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to list Route53 hosted zones: %w", err)
+			}
+			for _, zone := range page.HostedZones {
+				zoneName := strings.TrimSuffix(awssdk.ToString(zone.Name), ".")
+				r.zones[zoneName] = strings.TrimPrefix(awssdk.ToString(zone.Id), "/hostedzone/")
+			}
+		}
+	}
+
+	best := ""
+	for zoneName := range r.zones {
+		if (name == zoneName || strings.HasSuffix(name, "."+zoneName)) && len(zoneName) > len(best) {
+			best = zoneName
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	return r.zones[best], nil
+}
+
+// resolveTarget identifies the AWS resource behind a service hostname
+func (r *edgeResolver) resolveTarget(ctx context.Context, host string, depth int) (edgeHop, error) {
+	host = strings.TrimPrefix(strings.ToLower(host), "dualstack.")
+
+	switch {
+	case strings.HasSuffix(host, ".cloudfront.net"):
+		return r.resolveCloudFront(ctx, host, depth)
+	case strings.Contains(host, ".execute-api."):
+		return r.resolveExecuteAPI(ctx, host)
+	case strings.HasSuffix(host, ".elb.amazonaws.com"):
+		return r.resolveLoadBalancer(ctx, host)
+	case strings.Contains(host, ".s3") && strings.HasSuffix(host, ".amazonaws.com"):
+		bucket := host[:strings.Index(host, ".s3")]
+		return edgeHop{Service: "s3", Resource: bucket}, nil
+	}
+
+	// An origin may itself be a name in one of our hosted zones
+	if depth < maxEdgeDepth {
+		if zoneID, err := r.zoneFor(ctx, host); err == nil && zoneID != "" {
+			next, err := r.resolveName(ctx, host, depth+1)
+			if err != nil {
+				return edgeHop{}, err
+			}
+			return edgeHop{Service: "dns", Resource: host, Next: next}, nil
+		}
+	}
+	return edgeHop{Service: "external", Resource: host}, nil
+}
+
+func (r *edgeResolver) resolveCloudFront(ctx context.Context, host string, depth int) (edgeHop, error) {
+	paginator := cloudfront.NewListDistributionsPaginator(r.env.AWS.CloudFront, &cloudfront.ListDistributionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return edgeHop{}, fmt.Errorf("failed to list CloudFront distributions: %w", err)
+		}
+		if page.DistributionList == nil {
+			break
+		}
+		for _, dist := range page.DistributionList.Items {
+			if strings.ToLower(awssdk.ToString(dist.DomainName)) != host {
+				continue
+			}
+
+			hop := edgeHop{Service: "cloudfront", Resource: awssdk.ToString(dist.Id)}
+			if dist.Aliases != nil && len(dist.Aliases.Items) > 0 {
+				hop.Detail = "aliases " + strings.Join(dist.Aliases.Items, ", ")
+			}
+			defaultOrigin := ""
+			if dist.DefaultCacheBehavior != nil {
+				defaultOrigin = awssdk.ToString(dist.DefaultCacheBehavior.TargetOriginId)
+			}
+			if dist.Origins != nil {
+				for _, origin := range dist.Origins.Items {
+					next, err := r.resolveTarget(ctx, awssdk.ToString(origin.DomainName), depth+1)
+					if err != nil {
+						return edgeHop{}, err
+					}
+					role := "origin " + awssdk.ToString(origin.Id)
+					if awssdk.ToString(origin.Id) == defaultOrigin {
+						role += " (default)"
+					}
+					if path := awssdk.ToString(origin.OriginPath); path != "" {
+						role += " path " + path
+					}
+					if next.Detail != "" {
+						role += "; " + next.Detail
+					}
+					next.Detail = role
+					hop.Next = append(hop.Next, next)
+				}
+			}
+			return hop, nil
+		}
+	}
+
+	// Edge-optimized API Gateway custom domains use AWS-managed distributions
+	// that don't show up in the account's distribution list.
+	return r.resolveAPIDomain(ctx, host)
+}
+
+func (r *edgeResolver) resolveExecuteAPI(ctx context.Context, host string) (edgeHop, error) {
+	label := strings.SplitN(host, ".", 2)[0]
+	if strings.HasPrefix(label, "d-") {
+		return r.resolveAPIDomain(ctx, host)
+	}
+	return r.restAPIHop(ctx, label, "")
+}
+
+// resolveAPIDomain finds the API Gateway custom domain whose regional or
+// edge hostname is host and follows its base path mappings
+func (r *edgeResolver) resolveAPIDomain(ctx context.Context, host string) (edgeHop, error) {
+	domains := apigateway.NewGetDomainNamesPaginator(r.env.AWS.APIGateway, &apigateway.GetDomainNamesInput{})
+	for domains.HasMorePages() {
+		page, err := domains.NextPage(ctx)
+		if err != nil {
+			return edgeHop{}, fmt.Errorf("failed to list API Gateway domain names: %w", err)
+		}
+		for _, domain := range page.Items {
+			if strings.ToLower(awssdk.ToString(domain.RegionalDomainName)) != host &&
+				strings.ToLower(awssdk.ToString(domain.DistributionDomainName)) != host {
+				continue
+			}
+
+			hop := edgeHop{Service: "apigateway-domain", Resource: awssdk.ToString(domain.DomainName)}
+			mappings, err := r.env.AWS.APIGateway.GetBasePathMappings(ctx, &apigateway.GetBasePathMappingsInput{
+				DomainName: domain.DomainName,
+			})
+			if err != nil {
+				return edgeHop{}, fmt.Errorf("failed to get base path mappings: %w", err)
+			}
+			for _, mapping := range mappings.Items {
+				basePath := awssdk.ToString(mapping.BasePath)
+				if basePath == "(none)" {
+					basePath = ""
+				}
+				next, err := r.restAPIHop(ctx, awssdk.ToString(mapping.RestApiId), "/"+basePath)
+				if err != nil {
+					return edgeHop{}, err
+				}
+				hop.Next = append(hop.Next, next)
+			}
+			return hop, nil
+		}
+	}
+	return edgeHop{Service: "external", Resource: host}, nil
+}
+
+// restAPIHop describes a REST API and the Lambda functions behind its routes
+func (r *edgeResolver) restAPIHop(ctx context.Context, apiID, basePath string) (edgeHop, error) {
+	api, err := r.env.AWS.APIGateway.GetRestApi(ctx, &apigateway.GetRestApiInput{RestApiId: awssdk.String(apiID)})
+	if err != nil {
+		return edgeHop{}, fmt.Errorf("failed to get REST API %s: %w", apiID, err)
+	}
+	hop := edgeHop{Service: "apigateway", Resource: fmt.Sprintf("%s (%s)", awssdk.ToString(api.Name), apiID)}
+	if basePath != "" {
+		hop.Detail = "base path " + basePath
+	}
+
+	routes := make(map[string][]string) // lambda name -> routes
+	pages := apigateway.NewGetResourcesPaginator(r.env.AWS.APIGateway, &apigateway.GetResourcesInput{
+		RestApiId: api.Id,
+		Embed:     []string{"methods"},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return edgeHop{}, fmt.Errorf("failed to get API resources: %w", err)
+		}
+		for _, res := range page.Items {
+			for httpMethod, method := range res.ResourceMethods {
+				if method.MethodIntegration == nil {
+					continue
+				}
+				if name := state.LambdaFromIntegrationURI(awssdk.ToString(method.MethodIntegration.Uri)); name != "" {
+					routes[name] = append(routes[name], httpMethod+" "+awssdk.ToString(res.Path))
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sort.Strings(routes[name])
+		hop.Next = append(hop.Next, edgeHop{Service: "lambda", Resource: name, Detail: strings.Join(routes[name], ", ")})
+	}
+	return hop, nil
+}
+
+func (r *edgeResolver) resolveLoadBalancer(ctx context.Context, host string) (edgeHop, error) {
+	paginator := elbv2.NewDescribeLoadBalancersPaginator(r.env.AWS.ELBv2, &elbv2.DescribeLoadBalancersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return edgeHop{}, fmt.Errorf("failed to describe load balancers: %w", err)
+		}
+		for _, lb := range page.LoadBalancers {
+			if strings.ToLower(awssdk.ToString(lb.DNSName)) != host {
+				continue
+			}
+			hop := edgeHop{
+				Service:  "elb",
+				Resource: awssdk.ToString(lb.LoadBalancerName),
+				Detail:   string(lb.Type) + ", " + string(lb.Scheme),
+			}
+			targets, err := r.loadBalancerTargets(ctx, awssdk.ToString(lb.LoadBalancerArn))
+			if err != nil {
+				return edgeHop{}, err
+			}
+			hop.Next = targets
+			return hop, nil
+		}
+	}
+	return edgeHop{Service: "external", Resource: host}, nil
+}
+
+// loadBalancerTargets follows listener rules to target groups, expanding
+// Lambda targets to their functions
+func (r *edgeResolver) loadBalancerTargets(ctx context.Context, lbARN string) ([]edgeHop, error) {
+	var listeners []elbv2types.Listener
+	listenerPages := elbv2.NewDescribeListenersPaginator(r.env.AWS.ELBv2, &elbv2.DescribeListenersInput{LoadBalancerArn: awssdk.String(lbARN)})
+	for listenerPages.HasMorePages() {
+		page, err := listenerPages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe listeners: %w", err)
+		}
+		listeners = append(listeners, page.Listeners...)
+	}
+
+	seen := make(map[string]bool)
+	var hops []edgeHop
+	for _, listener := range listeners {
+		var rules []elbv2types.Rule
+		rulePages := elbv2.NewDescribeRulesPaginator(r.env.AWS.ELBv2, &elbv2.DescribeRulesInput{ListenerArn: listener.ListenerArn})
+		for rulePages.HasMorePages() {
+			page, err := rulePages.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe listener rules: %w", err)
+			}
+			rules = append(rules, page.Rules...)
+		}
+		for _, rule := range rules {
+			for _, action := range rule.Actions {
+				var groups []string
+				if action.TargetGroupArn != nil {
+					groups = append(groups, awssdk.ToString(action.TargetGroupArn))
+				}
+				if action.ForwardConfig != nil {
+					for _, tg := range action.ForwardConfig.TargetGroups {
+						groups = append(groups, awssdk.ToString(tg.TargetGroupArn))
+					}
+				}
+				for _, tgARN := range groups {
+					if seen[tgARN] {
+						continue
+					}
+					seen[tgARN] = true
+					hop, err := r.targetGroupHop(ctx, tgARN, fmt.Sprintf("port %d", awssdk.ToInt32(listener.Port)))
+					if err != nil {
+						return nil, err
+					}
+					hops = append(hops, hop)
+				}
+			}
+		}
+	}
+	return hops, nil
+}
+
+func (r *edgeResolver) targetGroupHop(ctx context.Context, tgARN, listener string) (edgeHop, error) {
+	name := tgARN
+	if parts := strings.Split(tgARN, "/"); len(parts) >= 2 {
+		name = parts[len(parts)-2]
+	}
+	hop := edgeHop{Service: "target-group", Resource: name, Detail: listener}
+
+	health, err := r.env.AWS.ELBv2.DescribeTargetHealth(ctx, &elbv2.DescribeTargetHealthInput{TargetGroupArn: awssdk.String(tgARN)})
+	if err != nil {
+		return edgeHop{}, fmt.Errorf("failed to describe target health: %w", err)
+	}
+	others := 0
+	for _, target := range health.TargetHealthDescriptions {
+		if target.Target == nil {
+			continue
+		}
+		id := awssdk.ToString(target.Target.Id)
+		if strings.Contains(id, ":function:") {
+			hop.Next = append(hop.Next, edgeHop{Service: "lambda", Resource: strings.Split(strings.SplitN(id, ":function:", 2)[1], ":")[0]})
+			continue
+		}
+		others++
+	}
+	if others > 0 {
+		hop.Detail += fmt.Sprintf(", %d instance/IP targets", others)
+	}
+	return hop, nil
+}
+
+// flattenHops renders every root-to-leaf path as "a → b → c"
+func flattenHops(prefix string, hop edgeHop) []string {
+	label := hop.Service + " " + hop.Resource
+	if hop.Service == "route53" {
+		label = "route53 " + hop.Detail
+	}
+	line := prefix + " → " + label
+	if len(hop.Next) == 0 {
+		return []string{line}
+	}
+	var lines []string
+	for _, next := range hop.Next {
+		lines = append(lines, flattenHops(line, next)...)
+	}
+	return lines
+}
@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+)
+
+// Env carries the dependencies available to intent handlers.
+type Env struct {
+	AWS *aws.Client
+	LLM *llm.Client
+	// FlowLogs is the Athena table of flow logs delivered to S3, if one is
+	// configured
+	FlowLogs *aws.FlowLogsTable
+	// CachedState loads the project's cached infrastructure state, if the
+	// caller has one
+	CachedState func() (map[string]interface{}, error)
+}
+
+// Handler answers queries for a single intent. Implementations register
+// themselves with Register from an init function in their own file, so new
+// intents never need to touch ProcessQuery.
+type Handler interface {
+	// Intent is the intent name the query parser emits for this handler.
+	Intent() string
+	// Description tells the query parser when to choose this intent.
+	Description() string
+	// Handle executes the query and returns data for the formatter.
+	Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error)
+}
+
+// ErrNotHandled is returned when no handler can answer a query
+// deterministically, either because none recognises it or because the
+// handler for its intent cannot answer it yet.
+var ErrNotHandled = errors.New("no deterministic handler for this query")
+
+// Matcher is optionally implemented by handlers that can recognise their
+// intent from keywords when the LLM parser returns "unknown".
+type Matcher interface {
+	// Match is the keyword fallback for when the LLM cannot determine the
+	// intent. It returns the query for this handler's intent and a score,
+	// the number of keywords rawQuery matched, or 0 when rawQuery does not
+	// look like one. The handler with the highest score answers.
+	Match(rawQuery string) (*llm.Query, int)
+}
+
+// patternScore is the score of a match on a pattern that recognises the
+// whole question, which is more telling than a few keywords.
+const patternScore = 3
+
+// queryWords holds the lower-cased words of a query between single spaces
+// for keyword matching on word boundaries. Dots, hyphens, underscores and
+// slashes stay inside a word, so "api" matches neither api.example.com
+// nor orders-api.
+type queryWords string
+
+// words splits rawQuery into queryWords.
+func words(rawQuery string) queryWords {
+	fields := strings.FieldsFunc(strings.ToLower(rawQuery), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-/", r)
+	})
+	for i, field := range fields {
+		fields[i] = strings.Trim(field, ".")
+	}
+	return queryWords(" " + strings.Join(fields, " ") + " ")
+}
+
+// has reports whether the query contains any of phrases as whole words.
+func (w queryWords) has(phrases ...string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(string(w), " "+phrase+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefix reports whether a word of the query starts with any of stems,
+// e.g. "encrypt" for encrypted and encryption.
+func (w queryWords) hasPrefix(stems ...string) bool {
+	for _, stem := range stems {
+		if strings.Contains(string(w), " "+stem) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalHandler is optionally implemented by handlers that answer from the
+// cached infrastructure state alone, so the AWS configuration is not loaded
+// for them.
+type LocalHandler interface {
+	Local()
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Handler)
+)
+
+// Register adds a handler to the registry. It panics if another handler is
+// already registered for the same intent, mirroring database/sql drivers.
+func Register(h Handler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	intent := h.Intent()
+	if _, dup := registry[intent]; dup {
+		panic("processor: Register called twice for intent " + intent)
+	}
+	registry[intent] = h
+	llm.RegisterIntent(intent, h.Description())
+}
+
+// lookup returns the handler for an intent, or nil.
+func lookup(intent string) Handler {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[intent]
+}
+
+// handlers returns all registered handlers sorted by intent name, so keyword
+// matches with the same score are resolved the same way every time.
+func handlers() []Handler {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	list := make([]Handler, 0, len(registry))
+	for _, h := range registry {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Intent() < list[j].Intent() })
+	return list
+}
+
+// LoadPlugins opens every Go plugin (*.so) in dir and registers the handlers
+// it exports through a `Handlers() []processor.Handler` function. Plugins must
+// be built with the same Go toolchain and module versions as the binary.
+// A missing directory is not an error.
+func LoadPlugins(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping plugin %s: %v\n", path, err)
+			continue
+		}
+		sym, err := p.Lookup("Handlers")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping plugin %s: no Handlers function\n", path)
+			continue
+		}
+		factory, ok := sym.(func() []Handler)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping plugin %s: Handlers has the wrong signature\n", path)
+			continue
+		}
+		for _, h := range factory() {
+			if lookup(h.Intent()) != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Plugin %s: intent %q is already registered\n", path, h.Intent())
+				continue
+			}
+			Register(h)
+		}
+	}
+	return nil
+}
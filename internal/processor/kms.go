@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// kmsKeysHandler answers what a KMS key encrypts and which keys are pending
+// deletion, using a live scan so references across services are known
+type kmsKeysHandler struct{}
+
+func init() {
+	Register(kmsKeysHandler{})
+}
+
+func (kmsKeysHandler) Intent() string { return "kms_keys" }
+
+func (kmsKeysHandler) Description() string {
+	return `queries about KMS keys: what a key encrypts (params.key = alias, ID or description word) or which keys are scheduled for deletion (params.filter = "pending_deletion")`
+}
+
+// kmsKeyReport summarizes one key and the resources referencing it
+type kmsKeyReport struct {
+	KeyID        string   `json:"key_id"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	State        string   `json:"state"`
+	Manager      string   `json:"manager"`
+	DeletionDate string   `json:"deletion_date,omitempty"`
+	ReferencedBy []string `json:"referenced_by,omitempty"`
+	Grantees     []string `json:"grantees,omitempty"`
+}
+
+// Match recognises KMS keys pending deletion, or what is "encrypted with"
+// a key.
+func (h kmsKeysHandler) Match(rawQuery string) (*llm.Query, int) {
+	lowerQuery := strings.ToLower(rawQuery)
+	w := words(rawQuery)
+	if !w.has("kms", "key", "keys") {
+		return nil, 0
+	}
+
+	query := &llm.Query{Intent: h.Intent(), Service: "kms", RawQuery: rawQuery, Params: make(map[string]string)}
+	switch {
+	case w.has("deletion", "deleted"):
+		query.Action = "list_pending_deletion"
+		query.Params["filter"] = "pending_deletion"
+	case w.has("encrypted with"):
+		query.Action = "key_usage"
+		rest := lowerQuery[strings.Index(lowerQuery, "encrypted with")+len("encrypted with"):]
+		rest = strings.NewReplacer("the ", "", " kms", "", " key", "", "?", "").Replace(rest)
+		query.Params["key"] = strings.TrimSpace(rest)
+	default:
+		return nil, 0
+	}
+	if w.has("kms") && w.has("key", "keys") {
+		return query, 3
+	}
+	return query, 2
+}
+
+// Handle scans the account and reports the matching keys
+func (kmsKeysHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	infraState, err := (&state.LiveProvider{Client: env.AWS}).Scan(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan account: %w", err)
+	}
+	resources, _ := infraState["Resources"].(map[string]interface{})
+
+	pendingOnly := query.Params["filter"] == "pending_deletion"
+	needle := strings.ToLower(strings.TrimPrefix(query.Params["key"], "alias/"))
+
+	var keys []kmsKeyReport
+	for _, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource["Type"] != "AWS::KMS::Key" {
+			continue
+		}
+		props, _ := resource["Properties"].(map[string]interface{})
+		report := kmsReport(props)
+
+		if pendingOnly && report.State != "PendingDeletion" {
+			continue
+		}
+		if needle != "" && !kmsKeyMatches(report, needle) {
+			continue
+		}
+		keys = append(keys, report)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KeyID < keys[j].KeyID })
+
+	result := map[string]interface{}{"keys": keys, "count": len(keys)}
+	if warnings, ok := infraState["Warnings"]; ok {
+		result["warnings"] = warnings
+	}
+	return result, nil
+}
+
+func kmsReport(props map[string]interface{}) kmsKeyReport {
+	str := func(key string) string {
+		s, _ := props[key].(string)
+		return s
+	}
+	report := kmsKeyReport{
+		KeyID:        str("KeyId"),
+		Description:  str("Description"),
+		State:        str("KeyState"),
+		Manager:      str("KeyManager"),
+		DeletionDate: str("DeletionDate"),
+	}
+	report.Aliases, _ = props["Aliases"].([]string)
+	report.ReferencedBy, _ = props["ReferencedBy"].([]string)
+	if grants, ok := props["Grants"].([]map[string]interface{}); ok {
+		for _, grant := range grants {
+			if principal, _ := grant["GranteePrincipal"].(string); principal != "" {
+				report.Grantees = append(report.Grantees, principal)
+			}
+		}
+	}
+	return report
+}
+
+func kmsKeyMatches(report kmsKeyReport, needle string) bool {
+	if strings.Contains(strings.ToLower(report.KeyID), needle) || strings.Contains(strings.ToLower(report.Description), needle) {
+		return true
+	}
+	for _, alias := range report.Aliases {
+		if strings.Contains(strings.ToLower(alias), needle) {
+			return true
+		}
+	}
+	return false
+}
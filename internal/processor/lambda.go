@@ -0,0 +1,26 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/ddjura/cloudai/internal/llm"
+)
+
+// lambdaTriggersHandler answers what triggers a Lambda function
+type lambdaTriggersHandler struct{}
+
+func init() {
+	Register(lambdaTriggersHandler{})
+}
+
+func (lambdaTriggersHandler) Intent() string { return "lambda_triggers" }
+
+func (lambdaTriggersHandler) Description() string {
+	return "queries about what triggers a Lambda function"
+}
+
+// Handle handles Lambda trigger queries
+func (lambdaTriggersHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	// TODO: Implement Lambda trigger lookup
+	return nil, ErrNotHandled
+}
@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/output"
+)
+
+// lambdaConfigHandler answers configuration questions across every function
+// (runtime, memory, timeout, environment variables, reserved concurrency)
+// deterministically, returning a table instead of an LLM answer
+type lambdaConfigHandler struct{}
+
+func init() {
+	Register(lambdaConfigHandler{})
+}
+
+func (lambdaConfigHandler) Intent() string { return "lambda_config" }
+
+func (lambdaConfigHandler) Description() string {
+	return `queries about Lambda configuration across functions; params may include field (runtime, memory, timeout, env, concurrency), runtime (e.g. "python3.8"), min_memory, max_memory, min_timeout, max_timeout, env_var and reserved_concurrency ("set" or "unset")`
+}
+
+var (
+	runtimePattern   = regexp.MustCompile(`(?i)\b(python\d[\d.]*|nodejs\d+(?:\.x)?|java\d+(?:\.al2)?|dotnet\d+|dotnetcore\d[\d.]*|ruby\d[\d.]*|go1\.x|provided(?:\.al2(?:023)?)?)\b`)
+	comparePattern   = regexp.MustCompile(`(?i)(more than|over|above|greater than|at least|less than|under|below|at most)\s*(\d+)`)
+	envVarPattern    = regexp.MustCompile(`\b([A-Z][A-Z0-9_]{2,})\b`)
+	lambdaConfigKeys = []struct{ keyword, field string }{
+		{"reserved concurrency", "concurrency"},
+		{"concurrency", "concurrency"},
+		{"environment variable", "env"},
+		{"env var", "env"},
+		{"memory", "memory"},
+		{"timeout", "timeout"},
+		{"runtime", "runtime"},
+	}
+)
+
+// Match recognises "lambda" or "function" together with one of
+// lambdaConfigKeys, such as memory or timeout, or a runtime like python3.12.
+func (h lambdaConfigHandler) Match(rawQuery string) (*llm.Query, int) {
+	lowerQuery := strings.ToLower(rawQuery)
+	w := words(rawQuery)
+	if !w.hasPrefix("lambda", "function") {
+		return nil, 0
+	}
+
+	score := 1
+	params := make(map[string]string)
+	for _, k := range lambdaConfigKeys {
+		if w.hasPrefix(k.keyword) {
+			params["field"] = k.field
+			score++
+			break
+		}
+	}
+	if runtime := runtimePattern.FindString(rawQuery); runtime != "" {
+		params["runtime"] = strings.ToLower(runtime)
+		if params["field"] == "" {
+			params["field"] = "runtime"
+		}
+		score++
+	}
+	if params["field"] == "" {
+		return nil, 0
+	}
+
+	if m := comparePattern.FindStringSubmatch(lowerQuery); m != nil && (params["field"] == "memory" || params["field"] == "timeout") {
+		n, _ := strconv.Atoi(m[2])
+		switch m[1] {
+		case "more than", "over", "above", "greater than":
+			params["min_"+params["field"]] = strconv.Itoa(n + 1)
+		case "at least":
+			params["min_"+params["field"]] = strconv.Itoa(n)
+		case "less than", "under", "below":
+			params["max_"+params["field"]] = strconv.Itoa(n - 1)
+		case "at most":
+			params["max_"+params["field"]] = strconv.Itoa(n)
+		}
+	}
+	if params["field"] == "env" {
+		if name := envVarPattern.FindString(rawQuery); name != "" {
+			params["env_var"] = name
+		}
+	}
+	if params["field"] == "concurrency" {
+		switch {
+		case w.has("without", "no reserved") || w.hasPrefix("lack"):
+			params["reserved_concurrency"] = "unset"
+		case w.has("reserved"):
+			params["reserved_concurrency"] = "set"
+		}
+	}
+
+	return &llm.Query{Intent: h.Intent(), Service: "lambda", Action: "describe_config", RawQuery: rawQuery, Params: params}, score
+}
+
+// lambdaFilter holds the parsed query parameters
+type lambdaFilter struct {
+	field                string
+	runtime              string
+	minMemory, maxMemory int
+	minTimeout           int
+	maxTimeout           int
+	envVar               string
+	reservedConcurrency  string
+}
+
+func parseLambdaFilter(params map[string]string) lambdaFilter {
+	atoi := func(key string) int {
+		n, _ := strconv.Atoi(params[key])
+		return n
+	}
+	field := strings.ToLower(params["field"])
+	if field == "" {
+		field = "all"
+	}
+	return lambdaFilter{
+		field:               field,
+		runtime:             strings.ToLower(params["runtime"]),
+		minMemory:           atoi("min_memory"),
+		maxMemory:           atoi("max_memory"),
+		minTimeout:          atoi("min_timeout"),
+		maxTimeout:          atoi("max_timeout"),
+		envVar:              params["env_var"],
+		reservedConcurrency: strings.ToLower(params["reserved_concurrency"]),
+	}
+}
+
+// Handle lists every function (ListFunctions returns the same configuration
+// as GetFunctionConfiguration) and renders the matching ones as a table
+func (lambdaConfigHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	filter := parseLambdaFilter(query.Params)
+	withConcurrency := filter.field == "concurrency" || filter.field == "all" || filter.reservedConcurrency != ""
+
+	table := &output.Table{Headers: []string{"Function", "Runtime", "Memory (MB)", "Timeout (s)"}}
+	if filter.field == "env" || filter.field == "all" {
+		table.Headers = append(table.Headers, "Env vars")
+	}
+	if withConcurrency {
+		table.Headers = append(table.Headers, "Reserved concurrency")
+	}
+
+	total := 0
+	paginator := lambda.NewListFunctionsPaginator(env.AWS.Lambda, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Lambda functions: %w", err)
+		}
+		for _, fn := range page.Functions {
+			total++
+			runtime := string(fn.Runtime)
+			if runtime == "" {
+				runtime = "image (" + string(fn.PackageType) + ")"
+			}
+			memory := int(awssdk.ToInt32(fn.MemorySize))
+			timeout := int(awssdk.ToInt32(fn.Timeout))
+
+			var envNames []string
+			if fn.Environment != nil {
+				for name := range fn.Environment.Variables {
+					envNames = append(envNames, name) // names only, never values
+				}
+				sort.Strings(envNames)
+			}
+
+			if filter.runtime != "" && !strings.HasPrefix(runtime, filter.runtime) {
+				continue
+			}
+			if (filter.minMemory > 0 && memory < filter.minMemory) || (filter.maxMemory > 0 && memory > filter.maxMemory) {
+				continue
+			}
+			if (filter.minTimeout > 0 && timeout < filter.minTimeout) || (filter.maxTimeout > 0 && timeout > filter.maxTimeout) {
+				continue
+			}
+			if filter.envVar != "" && !containsString(envNames, filter.envVar) {
+				continue
+			}
+
+			row := []string{awssdk.ToString(fn.FunctionName), runtime, strconv.Itoa(memory), strconv.Itoa(timeout)}
+			if filter.field == "env" || filter.field == "all" {
+				row = append(row, strings.Join(envNames, ", "))
+			}
+			if withConcurrency {
+				reserved, err := reservedConcurrency(ctx, env, fn.FunctionName)
+				if err != nil {
+					return nil, err
+				}
+				if (filter.reservedConcurrency == "set" && reserved == "") || (filter.reservedConcurrency == "unset" && reserved != "") {
+					continue
+				}
+				if reserved == "" {
+					reserved = "-"
+				}
+				row = append(row, reserved)
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	}
+
+	sort.Slice(table.Rows, func(i, j int) bool { return table.Rows[i][0] < table.Rows[j][0] })
+	table.Title = fmt.Sprintf("Lambda functions: %d of %d match", len(table.Rows), total)
+	return table, nil
+}
+
+// reservedConcurrency returns the reserved concurrency of a function, or ""
+// when none is reserved
+func reservedConcurrency(ctx context.Context, env *Env, name *string) (string, error) {
+	out, err := env.AWS.Lambda.GetFunctionConcurrency(ctx, &lambda.GetFunctionConcurrencyInput{FunctionName: name})
+	if err != nil {
+		return "", fmt.Errorf("failed to get concurrency for %s: %w", awssdk.ToString(name), err)
+	}
+	if out.ReservedConcurrentExecutions == nil {
+		return "", nil
+	}
+	return strconv.Itoa(int(*out.ReservedConcurrentExecutions)), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,13 +2,9 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
-	"strings"
 
-	awssdk "github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/apigateway"
-	"github.com/aws/aws-sdk-go-v2/service/apigateway/types"
 	"github.com/ddjura/cloudai/internal/aws"
 	"github.com/ddjura/cloudai/internal/llm"
 	"github.com/ddjura/cloudai/internal/output"
@@ -18,6 +14,9 @@ import (
 type Processor struct {
 	llmClient *llm.Client
 	awsClient *aws.Client
+	loadAWS   func() (*aws.Client, error)
+	flowLogs  *aws.FlowLogsTable
+	loadState func() (map[string]interface{}, error)
 	formatter *output.Formatter
 }
 
@@ -43,16 +42,12 @@ func (p *Processor) ProcessQuery(ctx context.Context, rawQuery string) error {
 		query = p.fallbackParse(rawQuery)
 	}
 
-	// Execute the query based on intent
+	// Execute the query with the handler registered for its intent
 	var data interface{}
-	switch query.Intent {
-	case "lambda_triggers":
-		data, err = p.handleLambdaTriggers(ctx, query)
-	case "api_gateway_lambda":
-		data, err = p.handleAPIGatewayLambda(ctx, query)
-	case "cost_top":
-		data, err = p.handleCostTop(ctx, query)
-	default:
+	if handler := lookup(query.Intent); handler != nil {
+		env := &Env{AWS: p.awsClient, LLM: p.llmClient, FlowLogs: p.flowLogs, CachedState: p.loadState}
+		data, err = handler.Handle(ctx, env, query)
+	} else {
 		data = map[string]string{
 			"message": "Query intent not yet implemented",
 			"intent":  query.Intent,
@@ -77,140 +72,80 @@ func (p *Processor) ProcessQuery(ctx context.Context, rawQuery string) error {
 	return p.formatter.FormatResult(result)
 }
 
-// handleLambdaTriggers handles Lambda trigger queries
-func (p *Processor) handleLambdaTriggers(ctx context.Context, query *llm.Query) (interface{}, error) {
-	// TODO: Implement Lambda trigger lookup
-	return map[string]string{
-		"message": "Lambda trigger lookup not yet implemented",
-		"lambda":  query.Params["lambda"],
-	}, nil
+// LoadAWSOnDemand makes the processor create its AWS client with load when
+// a handler first needs it, so that questions no handler recognises never
+// load the AWS configuration.
+func (p *Processor) LoadAWSOnDemand(load func() (*aws.Client, error)) {
+	p.loadAWS = load
 }
 
-// handleAPIGatewayLambda handles API Gateway to Lambda queries
-func (p *Processor) handleAPIGatewayLambda(ctx context.Context, query *llm.Query) (interface{}, error) {
-	// Extract parameters from query
-	apiName := query.Params["api"]
-	httpMethod := query.Params["method"]
-	path := query.Params["path"]
-
-	// List all REST APIs
-	apis, err := p.awsClient.APIGateway.GetRestApis(ctx, &apigateway.GetRestApisInput{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list API Gateways: %w", err)
-	}
+// QueryFlowLogsWith lets handlers query flow logs delivered to S3 through
+// the given Athena table.
+func (p *Processor) QueryFlowLogsWith(table *aws.FlowLogsTable) {
+	p.flowLogs = table
+}
 
-	// Find the target API
-	var targetAPI *types.RestApi
-	for _, api := range apis.Items {
-		if apiName == "" || *api.Name == apiName {
-			targetAPI = &api
-			break
-		}
-	}
+// ReadCacheWith lets handlers answer from the project's cached
+// infrastructure state, loaded with load.
+func (p *Processor) ReadCacheWith(load func() (map[string]interface{}, error)) {
+	p.loadState = load
+}
 
-	if targetAPI == nil {
-		// Return available APIs
-		apiNames := make([]string, len(apis.Items))
-		for i, api := range apis.Items {
-			apiNames[i] = *api.Name
+// Resolve answers a query with the handler that recognises it and returns
+// the parsed query with the handler's data. Keyword matchers are tried first
+// since they cost nothing; the LLM parser is only consulted when the
+// processor was given an LLM client. It returns ErrNotHandled when no handler
+// can answer, so callers can fall back to answering from the cache.
+func (p *Processor) Resolve(ctx context.Context, rawQuery string) (*llm.Query, interface{}, error) {
+	query := p.fallbackParse(rawQuery)
+	if query.Intent == "unknown" && p.llmClient != nil {
+		parsed, err := p.llmClient.ParseQuery(ctx, rawQuery)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse query: %w", err)
 		}
-		return map[string]interface{}{
-			"message":        fmt.Sprintf("API Gateway '%s' not found", apiName),
-			"available_apis": apiNames,
-		}, nil
+		query = parsed
 	}
 
-	// Get resources for the API
-	resources, err := p.awsClient.APIGateway.GetResources(ctx, &apigateway.GetResourcesInput{
-		RestApiId: targetAPI.Id,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get API resources: %w", err)
+	handler := lookup(query.Intent)
+	if handler == nil {
+		return query, nil, ErrNotHandled
 	}
-
-	// Find the resource matching the path
-	var targetResource *types.Resource
-	for _, resource := range resources.Items {
-		if resource.ResourceMethods != nil {
-			if _, ok := resource.ResourceMethods[httpMethod]; ok && *resource.Path == path {
-				targetResource = &resource
-				break
-			}
+	if _, local := handler.(LocalHandler); !local && p.awsClient == nil && p.loadAWS != nil {
+		awsClient, err := p.loadAWS()
+		if err != nil {
+			return query, nil, fmt.Errorf("failed to initialize AWS client: %w", err)
 		}
+		p.awsClient = awsClient
 	}
-
-	if targetResource == nil {
-		return map[string]interface{}{
-			"message":  fmt.Sprintf("Path '%s' with method '%s' not found in API '%s'", path, httpMethod, *targetAPI.Name),
-			"api_name": *targetAPI.Name,
-			"api_id":   *targetAPI.Id,
-		}, nil
-	}
-
-	// Get the method integration
-	method, err := p.awsClient.APIGateway.GetMethod(ctx, &apigateway.GetMethodInput{
-		RestApiId:  targetAPI.Id,
-		ResourceId: targetResource.Id,
-		HttpMethod: awssdk.String(httpMethod),
-	})
+	data, err := handler.Handle(ctx, &Env{AWS: p.awsClient, LLM: p.llmClient, FlowLogs: p.flowLogs, CachedState: p.loadState}, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get method: %w", err)
-	}
-
-	// Extract Lambda function name from integration URI
-	var lambdaName string
-	if method.MethodIntegration != nil && method.MethodIntegration.Uri != nil {
-		uri := *method.MethodIntegration.Uri
-		if strings.Contains(uri, ":lambda:path") {
-			parts := strings.Split(uri, ":function:")
-			if len(parts) > 1 {
-				lambdaName = strings.Split(parts[1], "/")[0]
-			}
+		if errors.Is(err, ErrNotHandled) {
+			return query, nil, err
 		}
+		return query, nil, fmt.Errorf("%s lookup failed: %w", query.Intent, err)
 	}
-
-	return map[string]interface{}{
-		"api_name":    *targetAPI.Name,
-		"api_id":      *targetAPI.Id,
-		"path":        *targetResource.Path,
-		"method":      httpMethod,
-		"lambda_name": lambdaName,
-	}, nil
+	return query, data, nil
 }
 
-// handleCostTop handles cost top queries
-func (p *Processor) handleCostTop(ctx context.Context, query *llm.Query) (interface{}, error) {
-	// TODO: Implement cost top lookup
-	return map[string]string{
-		"message": "Cost top lookup not yet implemented",
-		"period":  query.Params["period"],
-		"limit":   query.Params["limit"],
-	}, nil
-}
-
-// fallbackParse is a simple keyword-based parser
+// fallbackParse asks each handler that supports keyword matching to
+// recognise the query and keeps the best match; on a tie the first intent
+// in name order wins
 func (p *Processor) fallbackParse(rawQuery string) *llm.Query {
-	lowerQuery := strings.ToLower(rawQuery)
-	query := &llm.Query{RawQuery: rawQuery, Params: make(map[string]string)}
-
-	// API Gateway -> Lambda intent
-	if strings.Contains(lowerQuery, "lambda") && (strings.Contains(lowerQuery, "api") || strings.Contains(lowerQuery, "gateway")) {
-		query.Intent = "api_gateway_lambda"
-		query.Service = "apigateway"
-		query.Action = "get_integration"
-
-		// Regex to extract METHOD /path on api-name
-		r := regexp.MustCompile(`(?i)(GET|POST|PUT|DELETE|PATCH)\s+([/\w-]+)\s+(?:on|in)\s+([\w-]+)`)
-		matches := r.FindStringSubmatch(rawQuery)
-		if len(matches) == 4 {
-			query.Params["method"] = strings.ToUpper(matches[1])
-			query.Params["path"] = matches[2]
-			query.Params["api"] = matches[3]
+	var best *llm.Query
+	bestScore := 0
+	for _, handler := range handlers() {
+		matcher, ok := handler.(Matcher)
+		if !ok {
+			continue
 		}
-		return query
+		if query, score := matcher.Match(rawQuery); score > bestScore {
+			best, bestScore = query, score
+		}
+	}
+	if best != nil {
+		return best
 	}
 
 	// Default to unknown
-	query.Intent = "unknown"
-	return query
+	return &llm.Query{Intent: "unknown", RawQuery: rawQuery, Params: make(map[string]string)}
 }
@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+)
+
+// bucketWorkers bounds concurrent per-bucket lookups; each bucket needs four calls
+const bucketWorkers = 8
+
+// s3BucketConfigHandler answers which buckets are public, unversioned or
+// unencrypted
+type s3BucketConfigHandler struct{}
+
+func init() {
+	Register(s3BucketConfigHandler{})
+}
+
+func (s3BucketConfigHandler) Intent() string { return "s3_bucket_config" }
+
+func (s3BucketConfigHandler) Description() string {
+	return `queries about S3 bucket settings such as public access, versioning or encryption; set params.check to "public", "versioning", "encryption" or "all"`
+}
+
+// bucketReport is the configuration of a single bucket
+type bucketReport struct {
+	Name              string   `json:"name"`
+	Region            string   `json:"region"`
+	Public            bool     `json:"public"`
+	PublicAccessBlock string   `json:"public_access_block"` // full, partial, none
+	Encryption        string   `json:"encryption"`          // algorithm or "none"
+	Versioning        string   `json:"versioning"`          // Enabled, Suspended, Disabled
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// Match recognises "bucket" together with "public", "version" or
+// "encrypt".
+func (h s3BucketConfigHandler) Match(rawQuery string) (*llm.Query, int) {
+	w := words(rawQuery)
+	if !w.hasPrefix("bucket") {
+		return nil, 0
+	}
+	check := ""
+	switch {
+	case w.hasPrefix("public"):
+		check = "public"
+	case w.hasPrefix("version", "unversion"):
+		check = "versioning"
+	case w.hasPrefix("encrypt", "unencrypt"):
+		check = "encryption"
+	default:
+		return nil, 0
+	}
+	return &llm.Query{
+		Intent:   h.Intent(),
+		Service:  "s3",
+		Action:   "analyze_buckets",
+		RawQuery: rawQuery,
+		Params:   map[string]string{"check": check},
+	}, 2
+}
+
+// Handle inspects every bucket and returns those failing the requested check
+func (s3BucketConfigHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	check := strings.ToLower(query.Params["check"])
+	if check == "" {
+		check = "all"
+	}
+
+	var buckets []bucketReport
+	paginator := s3.NewListBucketsPaginator(env.AWS.S3, &s3.ListBucketsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list buckets: %w", err)
+		}
+		for _, bucket := range page.Buckets {
+			buckets = append(buckets, bucketReport{
+				Name:   awssdk.ToString(bucket.Name),
+				Region: awssdk.ToString(bucket.BucketRegion),
+			})
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, bucketWorkers)
+	for i := range buckets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(b *bucketReport) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			inspectBucket(ctx, env.AWS.S3, b)
+		}(&buckets[i])
+	}
+	wg.Wait()
+
+	var matching []bucketReport
+	for _, b := range buckets {
+		if bucketFails(b, check) {
+			matching = append(matching, b)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].Name < matching[j].Name })
+
+	return map[string]interface{}{
+		"check":          check,
+		"total_buckets":  len(buckets),
+		"matching_count": len(matching),
+		"buckets":        matching,
+	}, nil
+}
+
+// bucketFails reports whether a bucket should be listed for check. Buckets
+// whose setting could not be read are listed too, with the error attached.
+func bucketFails(b bucketReport, check string) bool {
+	switch check {
+	case "public":
+		return b.Public
+	case "versioning":
+		return b.Versioning != "Enabled"
+	case "encryption":
+		return b.Encryption == "none" || b.Encryption == "unknown"
+	default:
+		return true
+	}
+}
+
+// inspectBucket fills in the configuration of b. Calls go to the bucket's own
+// region; per-call failures are recorded on the report instead of aborting.
+func inspectBucket(ctx context.Context, client *s3.Client, b *bucketReport) {
+	inRegion := func(o *s3.Options) {
+		if b.Region != "" {
+			o.Region = b.Region
+		}
+	}
+	bucket := awssdk.String(b.Name)
+
+	status, err := client.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{Bucket: bucket}, inRegion)
+	switch {
+	case err == nil && status.PolicyStatus != nil:
+		b.Public = awssdk.ToBool(status.PolicyStatus.IsPublic)
+	case err != nil && !aws.HasErrorCode(err, "NoSuchBucketPolicy"):
+		b.Errors = append(b.Errors, "policy status: "+err.Error())
+	}
+
+	b.PublicAccessBlock = "none"
+	pab, err := client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: bucket}, inRegion)
+	switch {
+	case err == nil && pab.PublicAccessBlockConfiguration != nil:
+		cfg := pab.PublicAccessBlockConfiguration
+		blocked := 0
+		for _, v := range []*bool{cfg.BlockPublicAcls, cfg.IgnorePublicAcls, cfg.BlockPublicPolicy, cfg.RestrictPublicBuckets} {
+			if awssdk.ToBool(v) {
+				blocked++
+			}
+		}
+		switch blocked {
+		case 4:
+			b.PublicAccessBlock = "full"
+		case 0:
+			b.PublicAccessBlock = "none"
+		default:
+			b.PublicAccessBlock = "partial"
+		}
+	case err != nil && !aws.HasErrorCode(err, "NoSuchPublicAccessBlockConfiguration"):
+		b.PublicAccessBlock = "unknown"
+		b.Errors = append(b.Errors, "public access block: "+err.Error())
+	}
+
+	b.Encryption = "none"
+	enc, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket}, inRegion)
+	switch {
+	case err == nil && enc.ServerSideEncryptionConfiguration != nil:
+		for _, rule := range enc.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault != nil {
+				b.Encryption = string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+				break
+			}
+		}
+	case err != nil && !aws.HasErrorCode(err, "ServerSideEncryptionConfigurationNotFoundError"):
+		b.Encryption = "unknown"
+		b.Errors = append(b.Errors, "encryption: "+err.Error())
+	}
+
+	versioning, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: bucket}, inRegion)
+	switch {
+	case err != nil:
+		b.Versioning = "unknown"
+		b.Errors = append(b.Errors, "versioning: "+err.Error())
+	case versioning.Status == "":
+		b.Versioning = "Disabled"
+	default:
+		b.Versioning = string(versioning.Status)
+	}
+}
@@ -0,0 +1,291 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/ddjura/cloudai/internal/state"
+)
+
+// streamingHandler answers what consumes a Kinesis stream or MSK cluster,
+// and lists streams, clusters and ElastiCache caches, from a live scan of
+// those services and the Lambda event source mappings that poll them
+type streamingHandler struct{}
+
+func init() {
+	Register(streamingHandler{})
+}
+
+func (streamingHandler) Intent() string { return "streaming" }
+
+func (streamingHandler) Description() string {
+	return `queries about Kinesis streams, MSK (Kafka) clusters and ElastiCache (Redis, Memcached, Valkey) caches: what consumes a stream or cluster (params.stream = its name), or which exist (params.service = "kinesis", "msk" or "elasticache"; params.filter = "unencrypted")`
+}
+
+var (
+	// streamBefore finds the name in "the clickstream Kinesis stream" and
+	// streamAfter the one in "stream named clickstream"
+	streamBefore = regexp.MustCompile(`(?i)\b([\w.-]+)\s+(?:(?:kinesis|kafka|msk)\s+)?(?:data\s+)?(?:stream|cluster|topic)\b`)
+	streamAfter  = regexp.MustCompile(`(?i)\b(?:stream|cluster)\s+(?:named\s+|called\s+)?["'` + "`" + `]?([\w.-]+)`)
+	// streamNameStopWords are words the patterns catch that are not names
+	streamNameStopWords = map[string]bool{
+		"the": true, "a": true, "an": true, "this": true, "that": true, "which": true, "what": true,
+		"kinesis": true, "kafka": true, "msk": true, "data": true, "my": true, "our": true,
+		"consumes": true, "reads": true, "from": true, "of": true, "each": true, "every": true,
+	}
+	streamingServiceWords = []struct{ keyword, service string }{
+		{"kinesis", "kinesis"},
+		{"kafka", "msk"},
+		{"msk", "msk"},
+		{"elasticache", "elasticache"},
+		{"redis", "elasticache"},
+		{"memcached", "elasticache"},
+		{"valkey", "elasticache"},
+	}
+)
+
+// streamConsumer is a function or enhanced fan-out consumer reading a stream
+type streamConsumer struct {
+	Kind             string   `json:"kind"` // lambda or enhanced_fan_out
+	Name             string   `json:"name"`
+	State            string   `json:"state,omitempty"`
+	BatchSize        int      `json:"batch_size,omitempty"`
+	StartingPosition string   `json:"starting_position,omitempty"`
+	Topics           []string `json:"topics,omitempty"`
+}
+
+// streamReport is a stream, cluster or cache and what reads from it
+type streamReport struct {
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	Arn       string           `json:"arn,omitempty"`
+	Details   map[string]any   `json:"details,omitempty"`
+	Consumers []streamConsumer `json:"consumers,omitempty"`
+}
+
+// Match recognises Kinesis, Kafka/MSK and ElastiCache (Redis, Memcached,
+// Valkey), or consumers of a named stream, e.g. "what reads from the orders
+// stream?".
+func (h streamingHandler) Match(rawQuery string) (*llm.Query, int) {
+	w := words(rawQuery)
+	service := ""
+	for _, word := range streamingServiceWords {
+		if w.has(word.keyword) {
+			service = word.service
+			break
+		}
+	}
+	consumers := w.hasPrefix("consum", "subscribe", "poll") || w.has("reads from", "read from")
+	stream := w.hasPrefix("stream")
+	if service == "" && !(consumers && stream) {
+		return nil, 0
+	}
+
+	query := &llm.Query{Intent: h.Intent(), Service: service, RawQuery: rawQuery, Params: make(map[string]string)}
+	if consumers && service != "elasticache" {
+		name := streamName(rawQuery)
+		if name == "" {
+			return nil, 0
+		}
+		query.Action = "stream_consumers"
+		query.Params["stream"] = name
+		if service != "" {
+			return query, 3
+		}
+		return query, 2
+	}
+	if service == "" {
+		return nil, 0
+	}
+	query.Action = "list"
+	query.Params["service"] = service
+	if w.has("unencrypted", "not encrypted", "without encryption") {
+		query.Params["filter"] = "unencrypted"
+		return query, 2
+	}
+	if stream {
+		return query, 2
+	}
+	return query, 1
+}
+
+// streamName returns the stream or cluster a question names, or "".
+func streamName(rawQuery string) string {
+	for _, pattern := range []*regexp.Regexp{streamBefore, streamAfter} {
+		for _, match := range pattern.FindAllStringSubmatch(rawQuery, -1) {
+			if name := strings.Trim(match[1], `"'.?`+"`"); name != "" && !streamNameStopWords[strings.ToLower(name)] {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Handle scans the services the question is about and reports the matching
+// streams, clusters or caches. A stream that is not in the account is left
+// to the cached project state, which may define it.
+func (streamingHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	needle := strings.ToLower(query.Params["stream"])
+	service := strings.ToLower(query.Params["service"])
+	var services []string
+	switch {
+	case needle != "":
+		services = []string{"lambda", "kinesis", "msk"}
+	case service == "kinesis" || service == "msk" || service == "elasticache":
+		services = []string{service}
+	default:
+		return nil, ErrNotHandled
+	}
+
+	infraState, err := (&state.LiveProvider{Client: env.AWS}).ScanServices(ctx, services)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", strings.Join(services, ", "), err)
+	}
+	resources, _ := infraState["Resources"].(map[string]interface{})
+
+	var reports []streamReport
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		if !streamingTypes[resourceType] || (needle == "" && state.ResourceService(resource) != service) {
+			continue
+		}
+		report := streamReport{Name: streamResourceName(id, props), Type: resourceType, Arn: str(props, "Arn"), Details: streamDetails(props)}
+		if needle != "" {
+			if resourceType != "AWS::Kinesis::Stream" && resourceType != "AWS::MSK::Cluster" && resourceType != "AWS::MSK::ServerlessCluster" {
+				continue
+			}
+			if !strings.Contains(strings.ToLower(report.Name), needle) {
+				continue
+			}
+			report.Consumers = streamConsumers(resources, report.Arn)
+		}
+		if query.Params["filter"] == "unencrypted" && encrypted(resourceType, props) {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	if needle != "" && len(reports) == 0 {
+		return nil, ErrNotHandled
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+
+	key := map[string]string{"kinesis": "streams", "msk": "clusters", "elasticache": "caches"}[service]
+	if needle != "" || key == "" {
+		key = "streams"
+	}
+	result := map[string]interface{}{key: reports, "count": len(reports)}
+	if warnings, ok := infraState["Warnings"]; ok {
+		result["warnings"] = warnings
+	}
+	return result, nil
+}
+
+// streamingTypes are the resource types the handler reports
+var streamingTypes = map[string]bool{
+	"AWS::Kinesis::Stream":               true,
+	"AWS::MSK::Cluster":                  true,
+	"AWS::MSK::ServerlessCluster":        true,
+	"AWS::ElastiCache::ReplicationGroup": true,
+	"AWS::ElastiCache::CacheCluster":     true,
+	"AWS::ElastiCache::ServerlessCache":  true,
+}
+
+// streamConsumers returns the Lambda functions mapped to the stream or
+// cluster and the enhanced fan-out consumers registered on a stream.
+func streamConsumers(resources map[string]interface{}, arn string) []streamConsumer {
+	var consumers []streamConsumer
+	for _, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		switch resource["Type"] {
+		case "AWS::Lambda::EventSourceMapping":
+			if str(props, "EventSourceArn") != arn {
+				continue
+			}
+			consumer := streamConsumer{
+				Kind:             "lambda",
+				Name:             functionName(str(props, "FunctionArn")),
+				State:            str(props, "State"),
+				StartingPosition: str(props, "StartingPosition"),
+			}
+			if size, ok := props["BatchSize"].(int32); ok {
+				consumer.BatchSize = int(size)
+			}
+			topics, _ := props["Topics"].([]interface{})
+			for _, topic := range topics {
+				if s, ok := topic.(string); ok {
+					consumer.Topics = append(consumer.Topics, s)
+				}
+			}
+			consumers = append(consumers, consumer)
+		case "AWS::Kinesis::StreamConsumer":
+			if str(props, "StreamARN") == arn {
+				consumers = append(consumers, streamConsumer{Kind: "enhanced_fan_out", Name: str(props, "ConsumerName"), State: str(props, "ConsumerStatus")})
+			}
+		}
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+	return consumers
+}
+
+// streamDetails keeps the properties worth reporting for each type
+func streamDetails(props map[string]interface{}) map[string]any {
+	details := make(map[string]any)
+	for _, key := range []string{
+		"StreamStatus", "ShardCount", "RetentionPeriodHours", "EncryptionType",
+		"State", "KafkaVersion", "InstanceType", "NumberOfBrokerNodes",
+		"Engine", "EngineVersion", "CacheNodeType", "Status", "NumCacheClusters", "NumCacheNodes",
+		"AtRestEncryptionEnabled", "TransitEncryptionEnabled", "Endpoint",
+	} {
+		if value, ok := props[key]; ok && value != "" {
+			details[key] = value
+		}
+	}
+	if mode, ok := props["StreamModeDetails"].(map[string]interface{}); ok {
+		details["StreamMode"] = mode["StreamMode"]
+	}
+	return details
+}
+
+// encrypted reports whether a stream, cluster or cache encrypts its data at
+// rest. MSK always does, with an AWS managed key by default.
+func encrypted(resourceType string, props map[string]interface{}) bool {
+	switch resourceType {
+	case "AWS::Kinesis::Stream":
+		return str(props, "EncryptionType") == "KMS"
+	case "AWS::ElastiCache::ReplicationGroup", "AWS::ElastiCache::CacheCluster":
+		enabled, _ := props["AtRestEncryptionEnabled"].(bool)
+		return enabled
+	}
+	return true
+}
+
+func streamResourceName(id string, props map[string]interface{}) string {
+	for _, key := range []string{"Name", "ClusterName", "ReplicationGroupId", "ServerlessCacheName"} {
+		if name := str(props, key); name != "" {
+			return name
+		}
+	}
+	return id
+}
+
+// functionName returns the name in a function ARN, without a version or
+// alias qualifier
+func functionName(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) >= 7 && parts[5] == "function" {
+		return parts[6]
+	}
+	return arn
+}
+
+func str(props map[string]interface{}, key string) string {
+	s, _ := props[key].(string)
+	return s
+}
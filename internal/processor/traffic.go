@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/ddjura/cloudai/internal/llm"
+)
+
+const (
+	// defaultTrafficDays is the window looked at when the question names none
+	defaultTrafficDays = 7
+	// maxTrafficDays bounds the window, and with it the data a query scans
+	maxTrafficDays = 90
+)
+
+// networkTrafficHandler answers whether anything talks to a resource from
+// the traffic its VPC flow logs observed, rather than from the security
+// groups and routes that would allow it
+type networkTrafficHandler struct{}
+
+func init() {
+	Register(networkTrafficHandler{})
+}
+
+func (networkTrafficHandler) Intent() string { return "network_traffic" }
+
+func (networkTrafficHandler) Description() string {
+	return `questions about observed network traffic to a resource, e.g. whether anything still connects to a database or instance; params.resource = RDS instance, Lambda function, load balancer, EC2 instance, ENI or IP (name or part of it), params.days = window in days`
+}
+
+// trafficReport is what the flow logs show about the traffic to a resource
+type trafficReport struct {
+	Target           *aws.TrafficTarget `json:"target"`
+	FlowLog          *aws.FlowLog       `json:"flow_log,omitempty"`
+	Days             int                `json:"days"`
+	Peers            []aws.TrafficPeer  `json:"peers,omitempty"`
+	AcceptedSources  int                `json:"accepted_sources"`
+	AcceptedFlows    int64              `json:"accepted_flows"`
+	AcceptedBytes    int64              `json:"accepted_bytes"`
+	RejectedFlows    int64              `json:"rejected_flows"`
+	LastAccepted     *time.Time         `json:"last_accepted,omitempty"`
+	Verdict          string             `json:"verdict"`
+	Notes            []string           `json:"notes,omitempty"`
+	ObservedTraffic  bool               `json:"observed_traffic"`
+	FlowLogAvailable bool               `json:"flow_log_available"`
+}
+
+var (
+	// trafficPattern finds the resource in "is anything talking to X?"
+	trafficPattern = regexp.MustCompile(`(?i)\b(?:talking|talks|talk|connecting|connects|connect|connections|connected|traffic|sending|sends)\s+(?:to|with|into)\s+(.+?)(?:\s+(?:in|over|during|for)\s+the\s+(?:last|past)\b.*)?[?.!]*$`)
+	// trafficDays finds the window in "in the last 30 days"
+	trafficDays = regexp.MustCompile(`(?i)\b(?:last|past)\s+(\d+)\s+days?\b`)
+)
+
+// trafficNoise are words around a resource name that are not part of it
+var trafficNoise = map[string]bool{
+	"the": true, "our": true, "my": true, "a": true, "an": true,
+	"rds": true, "aurora": true, "database": true, "db": true, "instance": true,
+	"lambda": true, "function": true, "load": true, "balancer": true, "alb": true, "nlb": true,
+	"ec2": true, "server": true, "host": true,
+}
+
+// Match recognises talking, connecting or sending traffic to a resource,
+// e.g. "what is talking to orders-db in the last 7 days?".
+func (h networkTrafficHandler) Match(rawQuery string) (*llm.Query, int) {
+	match := trafficPattern.FindStringSubmatch(strings.TrimSpace(rawQuery))
+	if match == nil {
+		return nil, 0
+	}
+	var words []string
+	for _, word := range strings.Fields(match[1]) {
+		if !trafficNoise[strings.ToLower(word)] {
+			words = append(words, word)
+		}
+	}
+	resource := strings.Join(words, " ")
+	if resource == "" {
+		return nil, 0
+	}
+	params := map[string]string{"resource": resource}
+	if days := trafficDays.FindStringSubmatch(rawQuery); days != nil {
+		params["days"] = days[1]
+	}
+	return &llm.Query{Intent: h.Intent(), Service: "ec2", Action: "flow_logs", RawQuery: rawQuery, Params: params}, patternScore
+}
+
+// Handle finds the resource's network interfaces and a flow log recording
+// them, and queries it for the traffic they received
+func (networkTrafficHandler) Handle(ctx context.Context, env *Env, query *llm.Query) (interface{}, error) {
+	ref := query.Params["resource"]
+	if ref == "" {
+		return nil, ErrNotHandled
+	}
+	days := defaultTrafficDays
+	if n, err := strconv.Atoi(query.Params["days"]); err == nil && n > 0 {
+		days = n
+	}
+	if days > maxTrafficDays {
+		days = maxTrafficDays
+	}
+
+	target, err := env.AWS.FindTrafficTarget(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	report := &trafficReport{Target: target, Days: days}
+	if target.Shared {
+		report.Notes = append(report.Notes, "the interfaces were found by security group and may include those of other resources in the same groups")
+	}
+	logs, err := env.AWS.FlowLogsFor(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	flowLog := pickFlowLog(logs, env.FlowLogs != nil)
+	if flowLog == nil {
+		report.Verdict = fmt.Sprintf("no active flow log that can be queried records the traffic of %s; enable VPC flow logs on %s to CloudWatch Logs, or to S3 with athena.database set, to answer from observed traffic", target.Resource, target.VpcID)
+		return report, nil
+	}
+	report.FlowLog, report.FlowLogAvailable = flowLog, true
+	if flowLog.CustomFormat {
+		report.Notes = append(report.Notes, "the flow log uses a custom format; fields it leaves out cannot be queried")
+	}
+
+	until := time.Now()
+	peers, err := env.AWS.ObservedTraffic(ctx, target, *flowLog, env.FlowLogs, until.AddDate(0, 0, -days), until)
+	if err != nil {
+		return nil, err
+	}
+	report.Peers = peers
+	sources := map[string]bool{}
+	for _, peer := range peers {
+		if peer.Action != "ACCEPT" {
+			report.RejectedFlows += peer.Flows
+			continue
+		}
+		sources[peer.Address] = true
+		report.AcceptedFlows += peer.Flows
+		report.AcceptedBytes += peer.Bytes
+		if lastSeen := peer.LastSeen; report.LastAccepted == nil || lastSeen.After(*report.LastAccepted) {
+			report.LastAccepted = &lastSeen
+		}
+	}
+	report.AcceptedSources = len(sources)
+	report.ObservedTraffic = report.AcceptedFlows > 0
+
+	switch {
+	case report.ObservedTraffic:
+		report.Verdict = fmt.Sprintf("%d source(s) sent accepted traffic to %s in the last %d days, most recently at %s",
+			report.AcceptedSources, target.Resource, days, report.LastAccepted.Format(time.RFC3339))
+	case flowLog.TrafficType == "REJECT":
+		report.Verdict = fmt.Sprintf("flow log %s records only rejected traffic, so whether anything connects to %s cannot be seen", flowLog.ID, target.Resource)
+	default:
+		report.Verdict = fmt.Sprintf("no accepted traffic reached %s in the last %d days", target.Resource, days)
+	}
+	return report, nil
+}
+
+// pickFlowLog prefers logs that record all traffic, and Logs Insights over
+// Athena, which needs the table configured.
+func pickFlowLog(logs []aws.FlowLog, athena bool) *aws.FlowLog {
+	var best *aws.FlowLog
+	bestScore := 0
+	for i, log := range logs {
+		score := 0
+		switch log.Destination {
+		case "cloud-watch-logs":
+			score = 2
+		case "s3":
+			if athena {
+				score = 1
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		if log.TrafficType == "ALL" {
+			score += 4
+		} else if log.TrafficType == "ACCEPT" {
+			score += 2
+		}
+		if score > bestScore {
+			best, bestScore = &logs[i], score
+		}
+	}
+	return best
+}
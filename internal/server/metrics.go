@@ -0,0 +1,97 @@
+package server
+
+import (
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Metrics holds the Prometheus collectors exposed on /metrics.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	queries       *prometheus.CounterVec
+	queryLatency  prometheus.Histogram
+	tokens        *prometheus.CounterVec
+	cost          *prometheus.CounterVec
+	backendErrors *prometheus.CounterVec
+	scans         *prometheus.CounterVec
+	scanLatency   prometheus.Histogram
+	rejected      *prometheus.CounterVec
+}
+
+// NewMetrics registers the server metrics on a fresh registry, together with
+// the standard Go runtime and process collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudai_queries_total",
+			Help: "Questions answered, by outcome.",
+		}, []string{"status"}),
+		queryLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "cloudai_query_duration_seconds",
+			Help:    "End-to-end latency of answering a question.",
+			Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+		}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudai_llm_tokens_total",
+			Help: "Estimated tokens sent to and received from model backends.",
+		}, []string{"backend", "model", "direction"}),
+		cost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudai_llm_cost_dollars_total",
+			Help: "Estimated model spend in USD.",
+		}, []string{"backend", "model"}),
+		backendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudai_backend_errors_total",
+			Help: "Failed requests to model backends.",
+		}, []string{"backend"}),
+		scans: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudai_scans_total",
+			Help: "Infrastructure scans, by outcome.",
+		}, []string{"status"}),
+		scanLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "cloudai_scan_duration_seconds",
+			Help:    "Latency of infrastructure scans.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudai_rejected_requests_total",
+			Help: "Requests rejected by authentication, rate limits or quotas, by reason.",
+		}, []string{"reason"}),
+	}
+
+	m.Registry.MustRegister(
+		m.queries, m.queryLatency, m.tokens, m.cost, m.backendErrors, m.scans, m.scanLatency, m.rejected,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observeQuery records the outcome of a single question.
+func (m *Metrics) observeQuery(seconds float64, usage llm.Usage, err error) {
+	m.queryLatency.Observe(seconds)
+	if err != nil {
+		m.queries.WithLabelValues("error").Inc()
+		if usage.Backend != "" {
+			m.backendErrors.WithLabelValues(usage.Backend).Inc()
+		}
+		return
+	}
+
+	m.queries.WithLabelValues("ok").Inc()
+	m.tokens.WithLabelValues(usage.Backend, usage.Model, "input").Add(float64(usage.InputTokens))
+	m.tokens.WithLabelValues(usage.Backend, usage.Model, "output").Add(float64(usage.OutputTokens))
+	m.cost.WithLabelValues(usage.Backend, usage.Model).Add(usage.Cost)
+}
+
+// observeScan records the outcome of a single scan.
+func (m *Metrics) observeScan(seconds float64, err error) {
+	m.scanLatency.Observe(seconds)
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.scans.WithLabelValues(status).Inc()
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+)
+
+// Limits are applied to every caller independently.
+type Limits struct {
+	RequestsPerMinute int     // 0 disables rate limiting
+	DailyQueries      int     // requests per day; 0 disables the quota
+	CallerDailyBudget float64 // USD per caller per day; 0 disables it
+}
+
+// Quotas authenticates callers and enforces per-caller rate limits and daily
+// quotas. The team-wide budget is enforced through a CostManager shared with
+// the model clients (same usage file), so no single caller can exhaust it.
+type Quotas struct {
+	limits Limits
+	keys   map[string]string // API key -> caller name
+	team   *llm.CostManager
+
+	mu      sync.Mutex
+	callers map[string]*callerUsage
+	now     func() time.Time
+}
+
+type callerUsage struct {
+	day         string
+	queries     int
+	cost        float64
+	windowStart time.Time
+	windowCount int
+}
+
+// QuotaError is returned when a request is rejected.
+type QuotaError struct {
+	Status     int
+	Reason     string // metric label: unauthorized, rate_limit, daily_queries, caller_budget, team_budget
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return e.Message
+}
+
+// NewQuotas creates quota enforcement. keys maps caller names to API keys; if
+// it is empty the server is open and callers are identified by remote IP.
+// team may be nil to skip the shared budget check.
+func NewQuotas(keys map[string]string, limits Limits, team *llm.CostManager) *Quotas {
+	byKey := make(map[string]string, len(keys))
+	for name, key := range keys {
+		if key != "" {
+			byKey[key] = name
+		}
+	}
+	return &Quotas{
+		limits:  limits,
+		keys:    byKey,
+		team:    team,
+		callers: make(map[string]*callerUsage),
+		now:     time.Now,
+	}
+}
+
+// Authenticate returns the caller for a request. The API key is read from
+// "Authorization: Bearer <key>" or "X-API-Key".
+func (q *Quotas) Authenticate(r *http.Request) (string, error) {
+	if len(q.keys) == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return host, nil
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	for candidate, name := range q.keys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return name, nil
+		}
+	}
+	return "", &QuotaError{Status: http.StatusUnauthorized, Reason: "unauthorized", Message: "missing or invalid API key"}
+}
+
+// Allow reserves a request for caller, or explains why it is rejected.
+func (q *Quotas) Allow(caller string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	usage := q.usage(caller, now)
+
+	if limit := q.limits.RequestsPerMinute; limit > 0 {
+		if now.Sub(usage.windowStart) >= time.Minute {
+			usage.windowStart = now
+			usage.windowCount = 0
+		}
+		if usage.windowCount >= limit {
+			return &QuotaError{
+				Status:     http.StatusTooManyRequests,
+				Reason:     "rate_limit",
+				Message:    fmt.Sprintf("rate limit of %d requests per minute exceeded", limit),
+				RetryAfter: usage.windowStart.Add(time.Minute).Sub(now),
+			}
+		}
+	}
+	if limit := q.limits.DailyQueries; limit > 0 && usage.queries >= limit {
+		return &QuotaError{
+			Status:     http.StatusTooManyRequests,
+			Reason:     "daily_queries",
+			Message:    fmt.Sprintf("daily quota of %d queries used up", limit),
+			RetryAfter: untilMidnight(now),
+		}
+	}
+	if limit := q.limits.CallerDailyBudget; limit > 0 && usage.cost >= limit {
+		return &QuotaError{
+			Status:     http.StatusTooManyRequests,
+			Reason:     "caller_budget",
+			Message:    fmt.Sprintf("daily budget of $%.2f for %s used up", limit, caller),
+			RetryAfter: untilMidnight(now),
+		}
+	}
+	if q.team != nil {
+		// Model clients record spend in the same usage file; reload to see it.
+		if err := q.team.LoadUsage(); err != nil {
+			return &QuotaError{
+				Status:  http.StatusServiceUnavailable,
+				Reason:  "team_budget",
+				Message: fmt.Sprintf("team daily budget cannot be checked: %v", err),
+			}
+		}
+		if q.team.GetRemainingBudget() <= 0 {
+			return &QuotaError{
+				Status:     http.StatusTooManyRequests,
+				Reason:     "team_budget",
+				Message:    fmt.Sprintf("team daily budget of $%.2f used up", q.team.DailyLimit),
+				RetryAfter: untilMidnight(now),
+			}
+		}
+	}
+
+	usage.windowCount++
+	usage.queries++
+	return nil
+}
+
+// Record adds the cost of a completed request to the caller's daily total.
+func (q *Quotas) Record(caller string, cost float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usage(caller, q.now()).cost += cost
+}
+
+// usage returns the caller's counters, resetting them on a new day.
+func (q *Quotas) usage(caller string, now time.Time) *callerUsage {
+	day := now.Format("2006-01-02")
+	usage, ok := q.callers[caller]
+	if !ok || usage.day != day {
+		usage = &callerUsage{day: day, windowStart: now}
+		q.callers[caller] = usage
+	}
+	return usage
+}
+
+func untilMidnight(now time.Time) time.Duration {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
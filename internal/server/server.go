@@ -0,0 +1,162 @@
+// Package server exposes CloudAI-CLI over HTTP for shared deployments, with
+// Prometheus metrics and OpenTelemetry tracing for operators.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/llm"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AnswerFunc answers a question about the served project's infrastructure.
+type AnswerFunc func(ctx context.Context, question string) (string, llm.Usage, error)
+
+// ScanFunc rescans the served project and refreshes its cache, returning the
+// number of resources found.
+type ScanFunc func(ctx context.Context) (int, error)
+
+// Server handles HTTP requests for a single project directory.
+type Server struct {
+	answer  AnswerFunc
+	scan    ScanFunc
+	quotas  *Quotas
+	metrics *Metrics
+	mux     *http.ServeMux
+}
+
+// QueryRequest is the body of POST /query.
+type QueryRequest struct {
+	Query string `json:"query"`
+}
+
+// QueryResponse is returned by POST /query.
+type QueryResponse struct {
+	Answer     string    `json:"answer,omitempty"`
+	Usage      llm.Usage `json:"usage"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// New creates a server. Routes:
+//
+//	POST /query    answer a question: {"query": "..."}
+//	POST /scan     rescan the project and refresh the cache
+//	GET  /metrics  Prometheus metrics
+//	GET  /healthz  liveness check
+//
+// /query and /scan are subject to quotas; nil quotas leaves them unlimited.
+func New(answer AnswerFunc, scan ScanFunc, quotas *Quotas) *Server {
+	s := &Server{
+		answer:  answer,
+		scan:    scan,
+		quotas:  quotas,
+		metrics: NewMetrics(),
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /query", s.limited(s.handleQuery))
+	s.mux.HandleFunc("POST /scan", s.limited(s.handleScan))
+	s.mux.Handle("GET /metrics", promhttp.HandlerFor(s.metrics.Registry, promhttp.HandlerOpts{}))
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// limited authenticates the caller and applies quotas before calling next.
+func (s *Server) limited(next func(w http.ResponseWriter, r *http.Request, caller string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.quotas == nil {
+			next(w, r, "")
+			return
+		}
+
+		caller, err := s.quotas.Authenticate(r)
+		if err == nil {
+			err = s.quotas.Allow(caller)
+		}
+		var quotaErr *QuotaError
+		if errors.As(err, &quotaErr) {
+			s.metrics.rejected.WithLabelValues(quotaErr.Reason).Inc()
+			if quotaErr.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(quotaErr.RetryAfter.Seconds())+1))
+			}
+			writeJSON(w, quotaErr.Status, map[string]interface{}{"error": quotaErr.Message})
+			return
+		}
+		next(w, r, caller)
+	}
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request, caller string) {
+	var req QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Query) == "" {
+		writeJSON(w, http.StatusBadRequest, QueryResponse{Error: `body must be {"query": "<question>"}`})
+		return
+	}
+
+	ctx, span := tracer.Start(r.Context(), "cloudai.query", trace.WithAttributes(
+		attribute.Int("cloudai.query.length", len(req.Query)),
+	))
+	defer span.End()
+
+	start := time.Now()
+	answer, usage, err := s.answer(ctx, req.Query)
+	elapsed := time.Since(start)
+	s.metrics.observeQuery(elapsed.Seconds(), usage, err)
+	if s.quotas != nil {
+		s.quotas.Record(caller, usage.Cost)
+	}
+
+	resp := QueryResponse{Answer: answer, Usage: usage, DurationMs: elapsed.Milliseconds()}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		resp.Error = err.Error()
+		writeJSON(w, http.StatusInternalServerError, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request, caller string) {
+	ctx, span := tracer.Start(r.Context(), "cloudai.scan")
+	defer span.End()
+
+	start := time.Now()
+	count, err := s.scan(ctx)
+	elapsed := time.Since(start)
+	s.metrics.observeScan(elapsed.Seconds(), err)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.Int("cloudai.scan.resources", count))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources":   count,
+		"duration_ms": elapsed.Milliseconds(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,921 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Decisions for one action, named as in IAM's policy evaluation logic
+const (
+	decisionAllow        = "allow"
+	decisionExplicitDeny = "explicit deny"
+	decisionImplicitDeny = "implicit deny"
+)
+
+// PolicyPath is a policy statement that takes part in an access decision.
+type PolicyPath struct {
+	// Kind is identity, resource, boundary (a permissions boundary) or scp
+	// (a service control policy)
+	Kind      string `json:"kind"`
+	Policy    string `json:"policy"`
+	Statement string `json:"statement"`
+	// Conditional is set for a statement with conditions, which are not
+	// evaluated
+	Conditional bool   `json:"conditional,omitempty"`
+	DefinedIn   string `json:"defined_in,omitempty"`
+}
+
+// ActionDecision is whether the principal may call one action on the
+// resource, with the statements that decide it.
+type ActionDecision struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	Decision string `json:"decision"`
+	// Reason explains an implicit deny
+	Reason    string       `json:"reason,omitempty"`
+	GrantedBy []PolicyPath `json:"granted_by,omitempty"`
+	DeniedBy  []PolicyPath `json:"denied_by,omitempty"`
+	// Conditional is set when conditions on the statements could change
+	// the decision
+	Conditional bool `json:"conditional,omitempty"`
+}
+
+// AccessReport is the verdict of whether a principal may access a resource.
+type AccessReport struct {
+	Principal     string `json:"principal"`
+	PrincipalType string `json:"principal_type"`
+	// Identity is the role or user whose policies apply: the principal
+	// itself, or the role a function or other resource runs as
+	Identity     string `json:"identity"`
+	Resource     string `json:"resource"`
+	ResourceType string `json:"resource_type"`
+	Access       string `json:"access"`
+	// Verdict is allowed, allowed under conditions, partially allowed,
+	// denied or not allowed
+	Verdict string           `json:"verdict"`
+	Actions []ActionDecision `json:"actions"`
+	// Unevaluated are policies that apply but are not in the scan, such as
+	// AWS managed policies
+	Unevaluated []string `json:"unevaluated,omitempty"`
+}
+
+// accessActions are the actions checked for each kind of access, by the
+// IAM service prefix of the resource
+var accessActions = map[string]map[string][]string{
+	"s3":             {"read": {"s3:GetObject"}, "write": {"s3:PutObject"}, "delete": {"s3:DeleteObject"}, "list": {"s3:ListBucket"}},
+	"dynamodb":       {"read": {"dynamodb:GetItem", "dynamodb:Query"}, "write": {"dynamodb:PutItem", "dynamodb:UpdateItem"}, "delete": {"dynamodb:DeleteItem"}, "list": {"dynamodb:Scan"}},
+	"sqs":            {"read": {"sqs:ReceiveMessage"}, "write": {"sqs:SendMessage"}, "delete": {"sqs:DeleteMessage"}},
+	"sns":            {"read": {"sns:Subscribe"}, "write": {"sns:Publish"}},
+	"lambda":         {"invoke": {"lambda:InvokeFunction"}, "read": {"lambda:GetFunction"}, "write": {"lambda:UpdateFunctionCode"}},
+	"kinesis":        {"read": {"kinesis:GetRecords"}, "write": {"kinesis:PutRecord", "kinesis:PutRecords"}},
+	"secretsmanager": {"read": {"secretsmanager:GetSecretValue"}, "write": {"secretsmanager:PutSecretValue"}},
+	"kms":            {"read": {"kms:Decrypt"}, "write": {"kms:Encrypt", "kms:GenerateDataKey"}},
+	"states":         {"invoke": {"states:StartExecution"}, "read": {"states:DescribeExecution"}},
+	"events":         {"write": {"events:PutEvents"}},
+	"ssm":            {"read": {"ssm:GetParameter"}, "write": {"ssm:PutParameter"}},
+	"logs":           {"read": {"logs:FilterLogEvents"}, "write": {"logs:PutLogEvents"}},
+}
+
+// accessKinds maps the verbs of a question to the kinds of accessActions
+var accessKinds = map[string]string{
+	"read": "read", "get": "read", "download": "read", "fetch": "read", "consume": "read",
+	"receive": "read", "poll": "read", "decrypt": "read", "subscribe": "read",
+	"write": "write", "put": "write", "upload": "write", "store": "write", "save": "write",
+	"send": "write", "publish": "write", "push": "write", "encrypt": "write", "update": "write",
+	"delete": "delete", "remove": "delete",
+	"list": "list", "scan": "list",
+	"invoke": "invoke", "call": "invoke", "trigger": "invoke", "execute": "invoke", "start": "invoke", "run": "invoke",
+}
+
+// objectActions act on the objects in a bucket rather than the bucket
+var objectActions = map[string]bool{"s3:GetObject": true, "s3:PutObject": true, "s3:DeleteObject": true}
+
+// iamServices are the IAM prefixes of services whose type names differ
+var iamServices = map[string]string{"stepfunctions": "states"}
+
+// accessTypeWords name resource types in questions, e.g. "the orders
+// Lambda" or "the audit bucket"
+var accessTypeWords = map[string]string{
+	"lambda": "AWS::Lambda::Function", "function": "AWS::Lambda::Function",
+	"role": "AWS::IAM::Role", "user": "AWS::IAM::User",
+	"bucket": "AWS::S3::Bucket", "table": "AWS::DynamoDB::Table",
+	"queue": "AWS::SQS::Queue", "topic": "AWS::SNS::Topic",
+	"stream": "AWS::Kinesis::Stream", "secret": "AWS::SecretsManager::Secret",
+	"key": "AWS::KMS::Key", "workflow": "AWS::StepFunctions::StateMachine",
+	"parameter": "AWS::SSM::Parameter", "bus": "AWS::Events::EventBus",
+}
+
+// roleProperties hold the role a resource runs as, in order of preference:
+// an ECS task's own role comes before the one ECS pulls images with.
+var roleProperties = []string{"Role", "RoleArn", "TaskRoleArn", "ServiceRole", "ExecutionRoleArn"}
+
+// Access evaluates whether principal may perform access on resource from the
+// policies in the state: identity policies of the principal's role or user,
+// resource policies, permissions boundaries and service control policies.
+// As in IAM, an explicit deny wins, then a boundary or SCP that allows
+// nothing, then an allow by either kind of policy. KMS keys also need their
+// key policy to allow the principal or the account. Conditions are not
+// evaluated; statements with them are marked. principal and resource are
+// logical IDs or names, optionally with a type word, like "orders lambda";
+// access is a verb such as read, write or invoke, or an IAM action.
+func Access(infraState map[string]interface{}, principal, access, resource string) (*AccessReport, error) {
+	principalID, err := resolveAccessRef(infraState, principal)
+	if err != nil {
+		return nil, err
+	}
+	resourceID, err := resolveAccessRef(infraState, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newPolicyResolver(infraState)
+	identityID, err := r.identity(principalID)
+	if err != nil {
+		return nil, err
+	}
+	target, _ := r.resources[resourceID].(map[string]interface{})
+	targetType, _ := target["Type"].(string)
+	service := ResourceService(target)
+	if prefix, ok := iamServices[service]; ok {
+		service = prefix
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(access))
+	var actions []string
+	if strings.Contains(kind, ":") {
+		actions = []string{access}
+	} else {
+		if k, ok := accessKinds[kind]; ok {
+			kind = k
+		}
+		if kind == "invoke" && accessActions[service]["invoke"] == nil {
+			kind = "write"
+		}
+		if kind == "access" {
+			actions = slices.Concat(accessActions[service]["read"], accessActions[service]["write"])
+		} else {
+			actions = accessActions[service][kind]
+		}
+		if len(actions) == 0 {
+			return nil, fmt.Errorf("no %s actions known for %s; name an action such as %s:Get*", kind, targetType, service)
+		}
+	}
+
+	report := &AccessReport{
+		Principal:     principalID,
+		PrincipalType: typeOf(r.resources[principalID]),
+		Identity:      identityID,
+		Resource:      resourceID,
+		ResourceType:  targetType,
+		Access:        kind,
+	}
+	identity, boundary, unevaluated := r.identityStatements(identityID)
+	report.Unevaluated = unevaluated
+	resourcePolicies, keyPolicy := r.resourceStatements(resourceID)
+	scps := r.scpStatements()
+	principalARN := r.arn(identityID)
+
+	for _, action := range actions {
+		arn := r.arn(resourceID)
+		if objectActions[action] {
+			arn += "/*"
+		}
+		decision := ActionDecision{Action: action, Resource: arn}
+		var identityGrants, resourceGrants, accountGrants, boundaryGrants, scpGrants []policyStatement
+		check := func(statements []policyStatement, grants *[]policyStatement, accountLevel *[]policyStatement) {
+			for _, s := range statements {
+				if !s.matches(action, arn) {
+					continue
+				}
+				account := false
+				if s.path.Kind == "resource" {
+					var ok bool
+					if ok, account = s.matchesPrincipal(principalARN); !ok {
+						continue
+					}
+				}
+				switch {
+				case s.effect == "Deny":
+					decision.DeniedBy = append(decision.DeniedBy, s.path)
+				case account && accountLevel != nil:
+					*accountLevel = append(*accountLevel, s)
+				case !account:
+					*grants = append(*grants, s)
+				}
+			}
+		}
+		check(identity, &identityGrants, nil)
+		check(resourcePolicies, &resourceGrants, &accountGrants)
+		check(boundary, &boundaryGrants, nil)
+		check(scps, &scpGrants, nil)
+
+		var grants []policyStatement
+		switch {
+		case len(scps) > 0 && len(scpGrants) == 0:
+			decision.Reason = "no service control policy allows it"
+		case len(boundary) > 0 && len(boundaryGrants) == 0:
+			decision.Reason = "the permissions boundary does not allow it"
+		case keyPolicy && len(resourceGrants) > 0:
+			grants = resourceGrants
+		case keyPolicy && len(accountGrants) == 0:
+			decision.Reason = "the key policy does not allow the principal or the account"
+		case keyPolicy && len(identityGrants) == 0:
+			decision.Reason = "the key policy defers to IAM policies, and none allows it"
+		case keyPolicy:
+			grants = append(accountGrants, identityGrants...)
+		case len(identityGrants) > 0 || len(resourceGrants) > 0:
+			grants = append(identityGrants, resourceGrants...)
+		default:
+			decision.Reason = "no policy in the scan allows it"
+			if len(unevaluated) > 0 {
+				decision.Reason += "; the policies it has outside the scan are not evaluated"
+			}
+		}
+		if len(grants) > 0 {
+			grants = append(grants, append(boundaryGrants, scpGrants...)...)
+		}
+
+		decision.Decision = decisionImplicitDeny
+		if len(grants) > 0 {
+			decision.Decision = decisionAllow
+			conditional := true
+			for _, g := range grants {
+				decision.GrantedBy = append(decision.GrantedBy, g.path)
+				if g.path.Kind == "identity" || g.path.Kind == "resource" {
+					conditional = conditional && g.path.Conditional
+				}
+			}
+			decision.Conditional = conditional
+		}
+		for _, denied := range decision.DeniedBy {
+			if !denied.Conditional {
+				decision.Decision, decision.Reason, decision.GrantedBy = decisionExplicitDeny, "", nil
+				decision.Conditional = false
+				break
+			}
+			decision.Conditional = decision.Decision == decisionAllow
+		}
+		report.Actions = append(report.Actions, decision)
+	}
+	report.Verdict = accessVerdict(report.Actions)
+	return report, nil
+}
+
+// accessVerdict sums up the decisions for the actions checked
+func accessVerdict(decisions []ActionDecision) string {
+	allowed, denied, conditional := 0, 0, false
+	for _, d := range decisions {
+		switch d.Decision {
+		case decisionAllow:
+			allowed++
+			conditional = conditional || d.Conditional
+		case decisionExplicitDeny:
+			denied++
+		}
+	}
+	switch {
+	case allowed == len(decisions) && conditional:
+		return "allowed under conditions"
+	case allowed == len(decisions):
+		return "allowed"
+	case allowed > 0:
+		return "partially allowed"
+	case denied > 0:
+		return "denied"
+	}
+	return "not allowed"
+}
+
+// resolveAccessRef returns the logical ID of the resource a question refers
+// to. Type words such as "lambda" or "bucket" narrow the match, and the
+// rest is matched against logical IDs, names, ARNs and friendly names:
+// exactly if possible, otherwise as a part of them.
+func resolveAccessRef(infraState map[string]interface{}, ref string) (string, error) {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	if _, ok := resources[ref]; ok {
+		return ref, nil
+	}
+
+	types := make(map[string]bool)
+	var words []string
+	for i, word := range strings.Fields(strings.ToLower(strings.Trim(ref, `"'?.`))) {
+		cfnType, ok := accessTypeWords[word]
+		if !ok {
+			cfnType, ok = accessTypeWords[strings.TrimSuffix(word, "s")]
+		}
+		if ok {
+			types[cfnType] = true
+			continue
+		}
+		if i == 0 && word == "the" {
+			continue
+		}
+		words = append(words, word)
+	}
+	name := strings.Join(words, " ")
+
+	names := FriendlyNames(infraState)
+	var exact, partial []string
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		if resourceType == "AWS::CDK::Metadata" || (len(types) > 0 && !types[resourceType]) {
+			continue
+		}
+		if name == "" {
+			partial = append(partial, logicalID)
+			continue
+		}
+		candidates := []string{logicalID, names[logicalID]}
+		props, _ := resource["Properties"].(map[string]interface{})
+		for key, value := range props {
+			if s, ok := value.(string); ok && (key == "Arn" || strings.HasSuffix(key, "Name")) {
+				candidates = append(candidates, s)
+			}
+		}
+		for _, candidate := range candidates {
+			lower := strings.ToLower(candidate)
+			if lower == "" {
+				continue
+			}
+			if lower == name || lower == strings.ReplaceAll(name, " ", "-") {
+				exact = append(exact, logicalID)
+				break
+			}
+			if containsWords(lower, words) {
+				partial = append(partial, logicalID)
+				break
+			}
+		}
+	}
+	matches := exact
+	if len(matches) == 0 {
+		matches = partial
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no resource matching %q in the cache", ref)
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("%q matches %d resources: %s", ref, len(matches), strings.Join(matches, ", "))
+}
+
+// containsWords reports whether s contains every word
+func containsWords(s string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(s, word) {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// policyStatement is a statement with its references resolved to ARNs
+type policyStatement struct {
+	path         PolicyPath
+	effect       string
+	actions      []string
+	notActions   []string
+	resources    []string
+	notResources []string
+	// principals are the AWS principals of a resource policy statement,
+	// "*" for anyone
+	principals []string
+}
+
+// matches reports whether the statement applies to action on arn
+func (s policyStatement) matches(action, arn string) bool {
+	if len(s.notActions) > 0 {
+		if matchesAny(s.notActions, action, actionMatch) {
+			return false
+		}
+	} else if !matchesAny(s.actions, action, actionMatch) {
+		return false
+	}
+	if len(s.notResources) > 0 {
+		return !matchesAny(s.notResources, arn, arnMatch)
+	}
+	// a Lambda permission has no resource; it applies to its function
+	return len(s.resources) == 0 || matchesAny(s.resources, arn, arnMatch)
+}
+
+// matchesPrincipal reports whether a resource policy statement names the
+// principal or anyone, and whether it names the principal's account, which
+// for same-account access leaves the decision to IAM policies.
+func (s policyStatement) matchesPrincipal(arn string) (matched, account bool) {
+	for _, principal := range s.principals {
+		switch {
+		case principal == "*" || arnMatch(principal, arn):
+			return true, false
+		case accountPrincipal.MatchString(principal):
+			matched, account = true, true
+		}
+	}
+	return matched, account
+}
+
+// accountPrincipal is an account ID, its root ARN or the scan's placeholder
+var accountPrincipal = regexp.MustCompile(`^(\d{12}|<account>|arn:aws[\w-]*:iam::(\d{12}|<account>|\*):root)$`)
+
+func matchesAny(patterns []string, value string, match func(pattern, value string) bool) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionMatch matches an IAM action against a pattern, ignoring case
+func actionMatch(pattern, action string) bool {
+	return globMatch(strings.ToLower(pattern), strings.ToLower(action))
+}
+
+// arnMatch matches an ARN against a pattern. The region and account the
+// scan does not know match any.
+func arnMatch(pattern, arn string) bool {
+	if globMatch(pattern, arn) {
+		return true
+	}
+	patternParts, arnParts := strings.SplitN(pattern, ":", 6), strings.SplitN(arn, ":", 6)
+	if len(patternParts) != 6 || len(arnParts) != 6 {
+		return false
+	}
+	for i := range patternParts {
+		p, a := patternParts[i], arnParts[i]
+		if (i == 3 || i == 4) && (p == unknownRegion || p == unknownAccount || a == unknownRegion || a == unknownAccount) {
+			continue
+		}
+		if !globMatch(p, a) {
+			return false
+		}
+	}
+	return true
+}
+
+// globMatch matches s against a pattern where * matches any run of
+// characters and ? any one, as in IAM policies.
+func globMatch(pattern, s string) bool {
+	star, next := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			next++
+			i = next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// policyResolver resolves the policies of a state and the references in
+// them
+type policyResolver struct {
+	resources map[string]interface{}
+	arns      map[string]string
+}
+
+func newPolicyResolver(infraState map[string]interface{}) *policyResolver {
+	r := &policyResolver{arns: make(map[string]string)}
+	r.resources, _ = infraState["Resources"].(map[string]interface{})
+	for _, target := range PolicyTargets(infraState) {
+		r.arns[target.ID] = target.ARN
+	}
+	return r
+}
+
+// arn returns the ARN of a resource: recorded, derived from its name or,
+// for a resource with a generated name, built around {logical ID}, which
+// references to it resolve to as well.
+func (r *policyResolver) arn(logicalID string) string {
+	if arn, ok := r.arns[logicalID]; ok {
+		return arn
+	}
+	resource, _ := r.resources[logicalID].(map[string]interface{})
+	service := ResourceService(resource)
+	if prefix, ok := iamServices[service]; ok {
+		service = prefix
+	}
+	arn := fmt.Sprintf("arn:aws:%s:%s:%s:{%s}", service, unknownRegion, unknownAccount, logicalID)
+	if spec, ok := arnTemplates[typeOf(resource)]; ok {
+		arn = fmt.Sprintf(spec.template, r.name(logicalID), unknownRegion, unknownAccount)
+	}
+	r.arns[logicalID] = arn
+	return arn
+}
+
+// name returns what names a resource in its ARN: its name property or, for
+// a generated name, {logical ID}
+func (r *policyResolver) name(logicalID string) string {
+	resource, _ := r.resources[logicalID].(map[string]interface{})
+	props, _ := resource["Properties"].(map[string]interface{})
+	if spec, ok := arnTemplates[typeOf(resource)]; ok {
+		if name, ok := props[spec.nameProperty].(string); ok && name != "" {
+			return name
+		}
+	}
+	return "{" + logicalID + "}"
+}
+
+// refIsARN are the types whose Ref returns their ARN rather than their name
+var refIsARN = map[string]bool{
+	"AWS::SNS::Topic":                  true,
+	"AWS::StepFunctions::StateMachine": true,
+	"AWS::SecretsManager::Secret":      true,
+	"AWS::IAM::ManagedPolicy":          true,
+}
+
+// pseudoParameters resolve to what the scan knows of them
+var pseudoParameters = map[string]string{
+	"AWS::Partition": "aws",
+	"AWS::Region":    unknownRegion,
+	"AWS::AccountId": unknownAccount,
+	"AWS::URLSuffix": "amazonaws.com",
+}
+
+// subVariable is a ${...} reference in Fn::Sub
+var subVariable = regexp.MustCompile(`\$\{([^}!]+)\}`)
+
+// resolve turns a policy value into a string: references become the ARN or
+// name of the resource, joins and substitutions are expanded, and anything
+// else the scan cannot know becomes *.
+func (r *policyResolver) resolve(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if ref, ok := v["Ref"].(string); ok {
+			return r.ref(ref)
+		}
+		if getAtt, ok := v["Fn::GetAtt"]; ok {
+			var parts []string
+			switch g := getAtt.(type) {
+			case string:
+				parts = strings.SplitN(g, ".", 2)
+			case []interface{}:
+				parts = stringList(g)
+			}
+			if len(parts) == 2 {
+				return r.getAtt(parts[0], parts[1])
+			}
+		}
+		if join, ok := v["Fn::Join"].([]interface{}); ok && len(join) == 2 {
+			separator, _ := join[0].(string)
+			items, _ := join[1].([]interface{})
+			parts := make([]string, len(items))
+			for i, item := range items {
+				parts[i] = r.resolve(item)
+			}
+			return strings.Join(parts, separator)
+		}
+		if sub, ok := v["Fn::Sub"]; ok {
+			template, _ := sub.(string)
+			variables := map[string]interface{}{}
+			if list, ok := sub.([]interface{}); ok && len(list) == 2 {
+				template, _ = list[0].(string)
+				variables, _ = list[1].(map[string]interface{})
+			}
+			return subVariable.ReplaceAllStringFunc(template, func(match string) string {
+				name := match[2 : len(match)-1]
+				if value, ok := variables[name]; ok {
+					return r.resolve(value)
+				}
+				if id, attr, ok := strings.Cut(name, "."); ok {
+					return r.getAtt(id, attr)
+				}
+				return r.ref(name)
+			})
+		}
+	}
+	return "*"
+}
+
+func (r *policyResolver) ref(name string) string {
+	if value, ok := pseudoParameters[name]; ok {
+		return value
+	}
+	resource, ok := r.resources[name].(map[string]interface{})
+	if !ok {
+		return "*"
+	}
+	if refIsARN[typeOf(resource)] {
+		return r.arn(name)
+	}
+	return r.name(name)
+}
+
+func (r *policyResolver) getAtt(logicalID, attribute string) string {
+	if _, ok := r.resources[logicalID]; ok && strings.HasSuffix(attribute, "Arn") {
+		return r.arn(logicalID)
+	}
+	return "*"
+}
+
+func (r *policyResolver) resolveList(value interface{}) []string {
+	var out []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			out = append(out, r.resolve(item))
+		}
+	case []string:
+		out = v
+	case nil:
+	default:
+		out = []string{r.resolve(v)}
+	}
+	return out
+}
+
+// statements resolves the statements of a policy document, which Terraform
+// holds as a JSON string
+func (r *policyResolver) statements(doc interface{}, path PolicyPath) []policyStatement {
+	if s, ok := doc.(string); ok {
+		var decoded interface{}
+		if json.Unmarshal([]byte(s), &decoded) != nil {
+			return nil
+		}
+		doc = decoded
+	}
+	policy, _ := doc.(map[string]interface{})
+	var raw []interface{}
+	switch s := policy["Statement"].(type) {
+	case []interface{}:
+		raw = s
+	case map[string]interface{}:
+		raw = []interface{}{s}
+	}
+
+	var statements []policyStatement
+	for i, item := range raw {
+		stmt, _ := item.(map[string]interface{})
+		if stmt == nil {
+			continue
+		}
+		s := policyStatement{
+			path:         path,
+			effect:       "Allow",
+			actions:      r.resolveList(stmt["Action"]),
+			notActions:   r.resolveList(stmt["NotAction"]),
+			resources:    r.resolveList(stmt["Resource"]),
+			notResources: r.resolveList(stmt["NotResource"]),
+		}
+		if effect, ok := stmt["Effect"].(string); ok {
+			s.effect = effect
+		}
+		s.path.Statement = fmt.Sprintf("#%d", i+1)
+		if sid, ok := stmt["Sid"].(string); ok && sid != "" {
+			s.path.Statement = sid
+		}
+		_, s.path.Conditional = stmt["Condition"]
+		switch principal := stmt["Principal"].(type) {
+		case string:
+			s.principals = []string{principal}
+		case map[string]interface{}:
+			s.principals = r.resolveList(principal["AWS"])
+		}
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// identity returns the role or user whose policies apply to a principal
+func (r *policyResolver) identity(logicalID string) (string, error) {
+	resource, _ := r.resources[logicalID].(map[string]interface{})
+	switch typeOf(resource) {
+	case "AWS::IAM::Role", "AWS::IAM::User":
+		return logicalID, nil
+	}
+	props, _ := resource["Properties"].(map[string]interface{})
+	for _, key := range roleProperties {
+		value, ok := props[key]
+		if !ok {
+			continue
+		}
+		if m, ok := value.(map[string]interface{}); ok {
+			if id := refName(m); r.resources[id] != nil {
+				return id, nil
+			}
+		}
+		if arn, ok := value.(string); ok {
+			for id, raw := range r.resources {
+				role, _ := raw.(map[string]interface{})
+				if typeOf(role) == "AWS::IAM::Role" && r.refersTo(arn, id) {
+					return id, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("%s runs as %s, which is not in the cache", logicalID, r.resolve(value))
+	}
+	return "", fmt.Errorf("%s has no IAM role or user whose policies could be evaluated", logicalID)
+}
+
+// refersTo reports whether value refers to a resource: a Ref or Fn::GetAtt
+// to its logical ID, or its name, ARN, ID or URL. A list refers to it if an
+// item does.
+func (r *policyResolver) refersTo(value interface{}, logicalID string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return refName(v) == logicalID
+	case []interface{}:
+		for _, item := range v {
+			if r.refersTo(item, logicalID) {
+				return true
+			}
+		}
+	case string:
+		if v == logicalID || v == r.arn(logicalID) {
+			return true
+		}
+		resource, _ := r.resources[logicalID].(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		for key, prop := range props {
+			if s, ok := prop.(string); ok && s == v && (key == "Arn" || key == "Id" || key == "Url" || strings.HasSuffix(key, "Name")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// identityStatements returns the statements of the identity policies of a
+// role or user and of its permissions boundary, with the policies attached
+// to it that the scan does not hold.
+func (r *policyResolver) identityStatements(identityID string) (identity, boundary []policyStatement, unevaluated []string) {
+	identityResource, _ := r.resources[identityID].(map[string]interface{})
+	props, _ := identityResource["Properties"].(map[string]interface{})
+
+	for _, key := range []string{"Policies", "InlinePolicy"} {
+		inline, _ := props[key].([]interface{})
+		for _, raw := range inline {
+			policy, _ := raw.(map[string]interface{})
+			name, doc := firstString(policy, "PolicyName", "name"), policy["PolicyDocument"]
+			if doc == nil {
+				doc = policy["policy"]
+			}
+			identity = append(identity, r.statements(doc, r.policyPath("identity", identityID, identityID+" inline policy "+name))...)
+		}
+	}
+
+	managed := func(value interface{}) []policyStatement {
+		for id, raw := range r.resources {
+			resource, _ := raw.(map[string]interface{})
+			if typeOf(resource) == "AWS::IAM::ManagedPolicy" && r.refersTo(value, id) {
+				policyProps, _ := resource["Properties"].(map[string]interface{})
+				return r.statements(policyProps["PolicyDocument"], r.policyPath("identity", id, ""))
+			}
+		}
+		unevaluated = append(unevaluated, r.resolve(value))
+		return nil
+	}
+	for _, value := range r.resolveAny(props["ManagedPolicyArns"]) {
+		identity = append(identity, managed(value)...)
+	}
+	if value, ok := props["PermissionsBoundary"]; ok {
+		boundary = managed(value)
+		for i := range boundary {
+			boundary[i].path.Kind = "boundary"
+		}
+	}
+
+	attachedTo := func(policyProps map[string]interface{}) bool {
+		for _, key := range []string{"Roles", "Role", "Users", "User"} {
+			if r.refersTo(policyProps[key], identityID) {
+				return true
+			}
+		}
+		return false
+	}
+	for id, raw := range r.resources {
+		resource, _ := raw.(map[string]interface{})
+		policyProps, _ := resource["Properties"].(map[string]interface{})
+		switch {
+		case typeOf(resource) == "AWS::IAM::Policy" || typeOf(resource) == "AWS::IAM::ManagedPolicy":
+			if attachedTo(policyProps) {
+				identity = append(identity, r.statements(policyProps["PolicyDocument"], r.policyPath("identity", id, firstString(policyProps, "PolicyName", "Name")))...)
+			}
+		case strings.HasSuffix(typeOf(resource), "_policy_attachment"):
+			if attachedTo(policyProps) {
+				identity = append(identity, managed(policyProps["PolicyArn"])...)
+			}
+		}
+	}
+	sort.Strings(unevaluated)
+	return identity, boundary, unevaluated
+}
+
+// resourceStatements returns the statements of the resource policies of a
+// resource, and whether it is a KMS key with a key policy.
+func (r *policyResolver) resourceStatements(resourceID string) (statements []policyStatement, keyPolicy bool) {
+	target, _ := r.resources[resourceID].(map[string]interface{})
+	targetProps, _ := target["Properties"].(map[string]interface{})
+	for _, key := range []string{"KeyPolicy", "PolicyDocument", "ResourcePolicy"} {
+		if doc, ok := targetProps[key]; ok && !strings.HasPrefix(typeOf(target), "AWS::IAM::") {
+			statements = append(statements, r.statements(doc, r.policyPath("resource", resourceID, resourceID+" "+key))...)
+			keyPolicy = keyPolicy || typeOf(target) == "AWS::KMS::Key"
+		}
+	}
+
+	// resource policies defined apart from the resource, with the
+	// properties that name the resource
+	policyTargets := map[string][]string{
+		"AWS::S3::BucketPolicy":               {"Bucket"},
+		"AWS::SQS::QueuePolicy":               {"Queues", "QueueUrl"},
+		"AWS::SNS::TopicPolicy":               {"Topics", "Arn"},
+		"AWS::SecretsManager::ResourcePolicy": {"SecretId", "SecretArn"},
+	}
+	for id, raw := range r.resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		resourceType := typeOf(resource)
+		if resourceType == "AWS::Lambda::Permission" && r.refersTo(props["FunctionName"], resourceID) {
+			_, sourceARN := props["SourceArn"]
+			_, sourceAccount := props["SourceAccount"]
+			statements = append(statements, policyStatement{
+				path:       PolicyPath{Kind: "resource", Policy: id, Statement: "#1", Conditional: sourceARN || sourceAccount, DefinedIn: DefinedIn(resource)},
+				effect:     "Allow",
+				actions:    r.resolveList(props["Action"]),
+				principals: r.resolveList(props["Principal"]),
+			})
+			continue
+		}
+		for _, key := range policyTargets[resourceType] {
+			if r.refersTo(props[key], resourceID) {
+				doc := props["PolicyDocument"]
+				if doc == nil {
+					doc = props["ResourcePolicy"]
+				}
+				statements = append(statements, r.statements(doc, r.policyPath("resource", id, ""))...)
+				break
+			}
+		}
+	}
+	return statements, keyPolicy
+}
+
+// scpStatements returns the statements of the service control policies in
+// the state. Where they are attached is not checked; each is taken to apply.
+func (r *policyResolver) scpStatements() []policyStatement {
+	var statements []policyStatement
+	for id, raw := range r.resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		if typeOf(resource) == "AWS::Organizations::Policy" && props["Type"] == "SERVICE_CONTROL_POLICY" {
+			statements = append(statements, r.statements(props["Content"], r.policyPath("scp", id, firstString(props, "Name")))...)
+		}
+	}
+	return statements
+}
+
+// policyPath names a policy by its name or, without one, its logical ID
+func (r *policyResolver) policyPath(kind, logicalID, name string) PolicyPath {
+	if name == "" {
+		name = logicalID
+	}
+	resource, _ := r.resources[logicalID].(map[string]interface{})
+	return PolicyPath{Kind: kind, Policy: name, DefinedIn: DefinedIn(resource)}
+}
+
+// resolveAny returns a list property as its items, or a single value alone
+func (r *policyResolver) resolveAny(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case nil:
+		return nil
+	}
+	return []interface{}{value}
+}
+
+func firstString(props map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := props[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func typeOf(raw interface{}) string {
+	resource, _ := raw.(map[string]interface{})
+	resourceType, _ := resource["Type"].(string)
+	return resourceType
+}
@@ -0,0 +1,159 @@
+package state
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/backup"
+	backuptypes "github.com/aws/aws-sdk-go-v2/service/backup/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// scanBackup records AWS Backup plans and their resource selections in the
+// shape of AWS::Backup::BackupPlan and AWS::Backup::BackupSelection, so
+// templates and live scans are audited alike.
+func scanBackup(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	plans := backup.NewListBackupPlansPaginator(client.Backup, &backup.ListBackupPlansInput{})
+	for plans.HasMorePages() {
+		page, err := plans.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, member := range page.BackupPlansList {
+			out, err := client.Backup.GetBackupPlan(ctx, &backup.GetBackupPlanInput{BackupPlanId: member.BackupPlanId})
+			if err != nil {
+				return err
+			}
+			planID := awssdk.ToString(member.BackupPlanId)
+			resources["backup/"+planID] = backupPlanResource(planID, awssdk.ToString(member.BackupPlanArn), out.BackupPlan)
+
+			selections := backup.NewListBackupSelectionsPaginator(client.Backup, &backup.ListBackupSelectionsInput{BackupPlanId: member.BackupPlanId})
+			for selections.HasMorePages() {
+				selectionPage, err := selections.NextPage(ctx)
+				if err != nil {
+					return err
+				}
+				for _, entry := range selectionPage.BackupSelectionsList {
+					selection, err := client.Backup.GetBackupSelection(ctx, &backup.GetBackupSelectionInput{
+						BackupPlanId: member.BackupPlanId,
+						SelectionId:  entry.SelectionId,
+					})
+					if err != nil {
+						return err
+					}
+					resources["backup/"+awssdk.ToString(entry.SelectionId)] = backupSelectionResource(planID, selection.BackupSelection)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func backupPlanResource(planID, arn string, plan *backuptypes.BackupPlan) map[string]interface{} {
+	var rules []interface{}
+	name := ""
+	if plan != nil {
+		name = awssdk.ToString(plan.BackupPlanName)
+		for _, rule := range plan.Rules {
+			var copies []interface{}
+			for _, copyAction := range rule.CopyActions {
+				copies = append(copies, map[string]interface{}{
+					"DestinationBackupVaultArn": awssdk.ToString(copyAction.DestinationBackupVaultArn),
+				})
+			}
+			entry := map[string]interface{}{
+				"RuleName":               awssdk.ToString(rule.RuleName),
+				"ScheduleExpression":     awssdk.ToString(rule.ScheduleExpression),
+				"TargetBackupVault":      awssdk.ToString(rule.TargetBackupVaultName),
+				"EnableContinuousBackup": awssdk.ToBool(rule.EnableContinuousBackup),
+				"CopyActions":            copies,
+			}
+			if rule.Lifecycle != nil && rule.Lifecycle.DeleteAfterDays != nil {
+				entry["Lifecycle"] = map[string]interface{}{"DeleteAfterDays": *rule.Lifecycle.DeleteAfterDays}
+			}
+			rules = append(rules, entry)
+		}
+	}
+	return map[string]interface{}{
+		"Type": "AWS::Backup::BackupPlan",
+		"Properties": map[string]interface{}{
+			"BackupPlanId": planID,
+			"Arn":          arn,
+			"BackupPlan": map[string]interface{}{
+				"BackupPlanName": name,
+				"BackupPlanRule": rules,
+			},
+		},
+	}
+}
+
+func backupSelectionResource(planID string, selection *backuptypes.BackupSelection) map[string]interface{} {
+	props := map[string]interface{}{"BackupPlanId": planID}
+	if selection != nil {
+		var tags []interface{}
+		for _, condition := range selection.ListOfTags {
+			tags = append(tags, map[string]interface{}{
+				"ConditionType":  string(condition.ConditionType),
+				"ConditionKey":   awssdk.ToString(condition.ConditionKey),
+				"ConditionValue": awssdk.ToString(condition.ConditionValue),
+			})
+		}
+		props["BackupSelection"] = map[string]interface{}{
+			"SelectionName": awssdk.ToString(selection.SelectionName),
+			"Resources":     selection.Resources,
+			"NotResources":  selection.NotResources,
+			"ListOfTags":    tags,
+		}
+	}
+	return map[string]interface{}{
+		"Type":       "AWS::Backup::BackupSelection",
+		"Properties": props,
+	}
+}
+
+// scanDynamoDB lists tables with their point-in-time recovery setting and
+// the regions they are replicated to as global tables.
+func scanDynamoDB(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := dynamodb.NewListTablesPaginator(client.DynamoDB, &dynamodb.ListTablesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, name := range page.TableNames {
+			table, err := client.DynamoDB.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: awssdk.String(name)})
+			if err != nil {
+				return err
+			}
+			backups, err := client.DynamoDB.DescribeContinuousBackups(ctx, &dynamodb.DescribeContinuousBackupsInput{TableName: awssdk.String(name)})
+			if err != nil {
+				return err
+			}
+			resources["dynamodb/"+name] = dynamoDBResource(name, table.Table, backups.ContinuousBackupsDescription)
+		}
+	}
+	return nil
+}
+
+func dynamoDBResource(name string, table *dynamodbtypes.TableDescription, backups *dynamodbtypes.ContinuousBackupsDescription) map[string]interface{} {
+	props := map[string]interface{}{"TableName": name}
+	if table != nil {
+		props["Arn"] = awssdk.ToString(table.TableArn)
+		var replicas []interface{}
+		for _, replica := range table.Replicas {
+			replicas = append(replicas, map[string]interface{}{"Region": awssdk.ToString(replica.RegionName)})
+		}
+		if len(replicas) > 0 {
+			props["Replicas"] = replicas
+		}
+	}
+	pitr := backups != nil && backups.PointInTimeRecoveryDescription != nil &&
+		backups.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus == dynamodbtypes.PointInTimeRecoveryStatusEnabled
+	props["PointInTimeRecoverySpecification"] = map[string]interface{}{"PointInTimeRecoveryEnabled": pitr}
+	return map[string]interface{}{
+		"Type":       "AWS::DynamoDB::Table",
+		"Properties": props,
+	}
+}
@@ -1,49 +1,202 @@
 package state
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 )
 
+// ErrNoCache means the project has not been scanned yet.
+var ErrNoCache = errors.New("no infrastructure cache found")
+
 // CacheManager handles saving and loading the infrastructure state.
 type CacheManager struct {
 	cacheDir  string
 	cacheFile string
+	sigFile   string
+	cipher    Cipher
 }
 
-// NewCacheManager creates a new cache manager for a given project path.
+// NewCacheManager creates a new cache manager for a given project path,
+// using the cache of the project's current environment (see 'cloudai use').
 func NewCacheManager(projectPath string) *CacheManager {
+	name, _ := CurrentEnvironment(projectPath)
+	cacheDir := environmentCacheDir(projectPath, name)
 	return &CacheManager{
-		cacheDir:  filepath.Join(projectPath, ".cloudai"),
-		cacheFile: filepath.Join(projectPath, ".cloudai", "cache.json"),
+		cacheDir:  cacheDir,
+		cacheFile: filepath.Join(cacheDir, "cache.json"),
+		sigFile:   filepath.Join(cacheDir, "cache.json.sig"),
 	}
 }
 
-// Save writes the given state to the cache file.
+// Path returns the cache file.
+func (m *CacheManager) Path() string {
+	return m.cacheFile
+}
+
+// SetCipher enables encryption of the cache at rest.
+func (m *CacheManager) SetCipher(c Cipher) {
+	m.cipher = c
+}
+
+// signedCache is the cache file: the state, or its encrypted envelope,
+// together with the signature of its compact JSON, so a reader never pairs
+// one save's state with another's signature.
+type signedCache struct {
+	Signature string          `json:"cloudai_signature"`
+	Cache     json.RawMessage `json:"cache"`
+}
+
+// Save writes the given state to the cache file, signed with an HMAC made
+// with the local signing key. The state is saved with its friendly name
+// table worked out afresh; the caller's map is left as it is.
 func (m *CacheManager) Save(state map[string]interface{}) error {
 	if err := os.MkdirAll(m.cacheDir, 0755); err != nil {
 		return err
 	}
+	saved := make(map[string]interface{}, len(state)+1)
+	for key, value := range state {
+		saved[key] = value
+	}
+	// The table is stored the way it loads back, as plain JSON values
+	names := make(map[string]interface{})
+	for id, name := range friendlyNames(state) {
+		names[id] = name
+	}
+	saved[FriendlyNamesKey] = names
 
-	bytes, err := json.MarshalIndent(state, "", "  ")
+	payload, err := json.Marshal(saved)
 	if err != nil {
 		return err
 	}
+	if m.cipher != nil {
+		if payload, err = m.cipher.Encrypt(context.Background(), payload); err != nil {
+			return err
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, payload); err != nil {
+			return err
+		}
+		payload = compact.Bytes()
+	}
 
-	return os.WriteFile(m.cacheFile, bytes, 0644)
+	signature, err := sign(payload)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(signedCache{Signature: signature, Cache: payload}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Readers such as 'cloudai serve' must never see a half-written file,
+	// so it is written aside and renamed into place
+	if err := writeFileAtomic(m.cacheFile, data); err != nil {
+		return err
+	}
+	// The signature of caches written by older versions is not needed
+	// any more
+	if err := os.Remove(m.sigFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
-// Load reads the state from the cache file.
+// writeFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// IsUntrusted reports whether err means the cache does not match its
+// signature or has none.
+func IsUntrusted(err error) bool {
+	return errors.Is(err, ErrCacheUnsigned) || errors.Is(err, ErrCacheModified)
+}
+
+// read reads the cache file once and checks the payload it returns against
+// its signature; trustErr is ErrCacheUnsigned or ErrCacheModified when the
+// payload cannot be trusted.
+func (m *CacheManager) read() (payload []byte, trustErr, err error) {
+	data, err := os.ReadFile(m.cacheFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var signed signedCache
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, nil, err
+	}
+	if signed.Signature == "" {
+		// Written by an older version, with the signature in cache.json.sig
+		signature, err := os.ReadFile(m.sigFile)
+		if os.IsNotExist(err) {
+			return data, ErrCacheUnsigned, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, verify(data, string(signature)), nil
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, signed.Cache); err != nil {
+		return nil, nil, err
+	}
+	payload = compact.Bytes()
+	return payload, verify(payload, signed.Signature), nil
+}
+
+// Verify checks the cache file against its signature. It returns
+// ErrCacheUnsigned or ErrCacheModified when the cache cannot be trusted.
+func (m *CacheManager) Verify() error {
+	_, trustErr, err := m.read()
+	if err != nil {
+		return err
+	}
+	return trustErr
+}
+
+// Load reads the state from the cache file and checks it against its
+// signature in the same read. A cache that cannot be trusted is returned
+// with ErrCacheUnsigned or ErrCacheModified, for callers that only warn
+// about it; see IsUntrusted.
 func (m *CacheManager) Load() (map[string]interface{}, error) {
-	bytes, err := os.ReadFile(m.cacheFile)
+	payload, trustErr, err := m.read()
 	if err != nil {
 		return nil, err
 	}
+	if isEncrypted(payload) {
+		if m.cipher == nil {
+			return nil, ErrCacheEncrypted
+		}
+		if payload, err = m.cipher.Decrypt(context.Background(), payload); err != nil {
+			return nil, err
+		}
+	}
 
 	var state map[string]interface{}
-	err = json.Unmarshal(bytes, &state)
-	return state, err
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, err
+	}
+	return state, trustErr
 }
 
 // Exists checks if a cache file already exists.
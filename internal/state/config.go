@@ -0,0 +1,96 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// configQuery selects every recorded resource with its configuration.
+// Deleted resources stay in the inventory with status ResourceDeleted and are
+// dropped after the query.
+const configQuery = "SELECT resourceId, resourceName, resourceType, arn, awsRegion, accountId, " +
+	"configuration, tags, relationships, configurationItemStatus"
+
+// ConfigProvider builds the live state from the resource inventory AWS Config
+// already records: one paginated query instead of list calls per service,
+// covering every resource type the recorder tracks. With Aggregator set, the
+// query runs against that aggregator and spans its accounts and regions.
+type ConfigProvider struct {
+	Client     *aws.Client
+	Aggregator string
+}
+
+func (p *ConfigProvider) Scan(ctx context.Context, path string) (map[string]interface{}, error) {
+	results, err := p.query(ctx)
+	if err != nil {
+		if aws.IsAccessDenied(err) {
+			return nil, fmt.Errorf("access denied querying AWS Config; grant config:SelectResourceConfig (or config:SelectAggregateResourceConfig for --aggregator): %w", err)
+		}
+		return nil, fmt.Errorf("failed to query AWS Config: %w", err)
+	}
+
+	var items []map[string]interface{}
+	for _, result := range results {
+		var item map[string]interface{}
+		if err := json.Unmarshal([]byte(result), &item); err != nil {
+			return nil, fmt.Errorf("failed to parse AWS Config result: %w", err)
+		}
+		switch item["configurationItemStatus"] {
+		case "ResourceDeleted", "ResourceDeletedNotRecorded", "ResourceNotRecorded":
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("AWS Config returned no resources; is a configuration recorder running in this region? Use 'cloudai scan --live' otherwise")
+	}
+
+	resources := itemResources(configItems(items))
+	LinkKMSKeys(resources)
+
+	infraState := map[string]interface{}{
+		"Source":    "config",
+		"Resources": resources,
+	}
+	if p.Aggregator != "" {
+		infraState["Aggregator"] = p.Aggregator
+	}
+	return infraState, nil
+}
+
+// query runs configQuery against the account's recorder or the aggregator
+// and returns every page of results.
+func (p *ConfigProvider) query(ctx context.Context) ([]string, error) {
+	var results []string
+	if p.Aggregator != "" {
+		paginator := configservice.NewSelectAggregateResourceConfigPaginator(p.Client.Config, &configservice.SelectAggregateResourceConfigInput{
+			ConfigurationAggregatorName: awssdk.String(p.Aggregator),
+			Expression:                  awssdk.String(configQuery),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, page.Results...)
+		}
+		return results, nil
+	}
+
+	paginator := configservice.NewSelectResourceConfigPaginator(p.Client.Config, &configservice.SelectResourceConfigInput{
+		Expression: awssdk.String(configQuery),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, page.Results...)
+	}
+	return results, nil
+}
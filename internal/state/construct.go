@@ -0,0 +1,182 @@
+package state
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxConstructHints caps the constructs an unknown path error suggests
+const maxConstructHints = 10
+
+// ConstructResource is a resource a CDK construct generated.
+type ConstructResource struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	// Path is the resource's construct path below the construct, such as
+	// ServiceRole/DefaultPolicy, or the construct's own ID for the resource
+	// the construct wraps
+	Path      string `json:"path"`
+	DefinedIn string `json:"defined_in,omitempty"`
+}
+
+// ConstructLink is a reference between two resources. From and To are
+// construct-relative paths for the construct's resources, and construct
+// paths, or logical IDs, for the others.
+type ConstructLink struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// ConstructReport is what a CDK construct creates and how it is wired.
+type ConstructReport struct {
+	Path      string              `json:"path"`
+	Resources []ConstructResource `json:"resources"`
+	// Internal are references among the construct's resources
+	Internal []ConstructLink `json:"internal,omitempty"`
+	// Outgoing are references from its resources to others, Incoming those
+	// from other resources to its own
+	Outgoing []ConstructLink `json:"outgoing,omitempty"`
+	Incoming []ConstructLink `json:"incoming,omitempty"`
+}
+
+// Construct collects the resources synthesized under a CDK construct path,
+// read from their aws:cdk:path metadata, and the references among them and
+// to the rest of the state. The path may leave out the stack and leading
+// constructs, e.g. OrdersTable for ApiStack/OrdersTable, as long as it names
+// one construct.
+func Construct(infraState map[string]interface{}, path string) (*ConstructReport, error) {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	want := strings.Split(strings.Trim(path, "/"), "/")
+	if path = strings.Join(want, "/"); path == "" {
+		return nil, fmt.Errorf("no construct path given")
+	}
+
+	paths := make(map[string]string)
+	roots := make(map[string]bool)
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		full := ConstructPath(resource)
+		if full == "" || resource["Type"] == "AWS::CDK::Metadata" {
+			continue
+		}
+		paths[logicalID] = full
+		if root := constructRoot(strings.Split(full, "/"), want); root != "" {
+			roots[root] = true
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("the cache has no CDK construct paths: scan a CDK project after `cdk synth`")
+	}
+
+	switch len(roots) {
+	case 0:
+		return nil, fmt.Errorf("no construct %q in the cache; constructs include %s", path, strings.Join(constructHints(paths), ", "))
+	case 1:
+	default:
+		matches := slices.Sorted(maps.Keys(roots))
+		return nil, fmt.Errorf("%q matches %d constructs: %s", path, len(matches), strings.Join(matches, ", "))
+	}
+	var root string
+	for r := range roots {
+		root = r
+	}
+	ownID := root[strings.LastIndex(root, "/")+1:]
+
+	report := &ConstructReport{Path: root}
+	members := make(map[string]string)
+	for logicalID, full := range paths {
+		if full != root && !strings.HasPrefix(full, root+"/") {
+			continue
+		}
+		relative := strings.TrimPrefix(strings.TrimPrefix(full, root), "/")
+		if relative == "" {
+			relative = ownID
+		}
+		members[logicalID] = relative
+		resource, _ := resources[logicalID].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+		report.Resources = append(report.Resources, ConstructResource{
+			ID:        logicalID,
+			Type:      resourceType,
+			Name:      resourceName(props),
+			Path:      relative,
+			DefinedIn: DefinedIn(resource),
+		})
+	}
+	sort.Slice(report.Resources, func(i, j int) bool { return report.Resources[i].Path < report.Resources[j].Path })
+
+	outside := func(logicalID string) string {
+		if full, ok := paths[logicalID]; ok {
+			return full
+		}
+		return logicalID
+	}
+	for _, edge := range Edges(infraState) {
+		from, fromInside := members[edge.From]
+		to, toInside := members[edge.To]
+		switch {
+		case fromInside && toInside:
+			report.Internal = append(report.Internal, ConstructLink{From: from, To: to, Relation: edge.Relation})
+		case fromInside:
+			report.Outgoing = append(report.Outgoing, ConstructLink{From: from, To: outside(edge.To), Relation: edge.Relation})
+		case toInside:
+			report.Incoming = append(report.Incoming, ConstructLink{From: outside(edge.From), To: to, Relation: edge.Relation})
+		}
+	}
+	for _, links := range [][]ConstructLink{report.Internal, report.Outgoing, report.Incoming} {
+		sort.Slice(links, func(i, j int) bool {
+			if links[i].From != links[j].From {
+				return links[i].From < links[j].From
+			}
+			return links[i].To < links[j].To
+		})
+	}
+	return report, nil
+}
+
+// constructRoot returns the construct path of segments up to where want
+// occurs in it, or "" when it does not. The CDK's Resource and Default
+// children are not constructs of their own, so want never ends on them.
+func constructRoot(segments, want []string) string {
+	for start := 0; start+len(want) <= len(segments); start++ {
+		end := start + len(want)
+		if last := segments[end-1]; last == "Resource" || last == "Default" {
+			continue
+		}
+		matched := true
+		for i, id := range want {
+			if segments[start+i] != id {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return strings.Join(segments[:end], "/")
+		}
+	}
+	return ""
+}
+
+// constructHints lists the top-level constructs of the stacks, those with
+// the most resources first.
+func constructHints(paths map[string]string) []string {
+	counts := make(map[string]int)
+	for _, full := range paths {
+		segments := strings.Split(full, "/")
+		if len(segments) >= 2 {
+			counts[segments[0]+"/"+segments[1]]++
+		}
+	}
+	hints := slices.Sorted(maps.Keys(counts))
+	sort.SliceStable(hints, func(i, j int) bool { return counts[hints[i]] > counts[hints[j]] })
+	if len(hints) > maxConstructHints {
+		hints = hints[:maxConstructHints]
+	}
+	return hints
+}
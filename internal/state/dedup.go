@@ -0,0 +1,188 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// minDuplicateGroup is the smallest number of similar resources worth collapsing.
+	minDuplicateGroup = 3
+	// maxVaryingFields is how many fields may differ for resources to still
+	// count as "nearly identical".
+	maxVaryingFields = 4
+)
+
+// Deduplicate returns a copy of the state where groups of nearly identical
+// resources (same type, same fields, at most a few differing values) are
+// replaced by a single entry holding the shared fields once, the count, and
+// the per-resource values of the fields that differ.
+//
+// Real accounts are full of such repetition – dozens of log groups, one subnet
+// per AZ, a permission per API route – and collapsing it shrinks prompts
+// dramatically without losing the information needed to answer questions.
+func Deduplicate(infraState map[string]interface{}) map[string]interface{} {
+	resources, ok := infraState["Resources"].(map[string]interface{})
+	if !ok {
+		return infraState
+	}
+
+	// Bucket candidates by type and field set; only resources with the same
+	// shape can be nearly identical.
+	buckets := make(map[string][]string)
+	flattened := make(map[string]map[string]interface{})
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceType, _ := resource["Type"].(string)
+		fields := flattenResource(resource)
+		flattened[logicalID] = fields
+
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		shape := resourceType + "|" + strings.Join(keys, ",")
+		buckets[shape] = append(buckets[shape], logicalID)
+	}
+
+	out := make(map[string]interface{}, len(resources))
+	for k, v := range resources {
+		out[k] = v
+	}
+
+	shapes := make([]string, 0, len(buckets))
+	for shape := range buckets {
+		shapes = append(shapes, shape)
+	}
+	sort.Strings(shapes)
+
+	for _, shape := range shapes {
+		ids := buckets[shape]
+		if len(ids) < minDuplicateGroup {
+			continue
+		}
+		sort.Strings(ids)
+
+		common, varying := splitFields(ids, flattened)
+		if len(varying) > maxVaryingFields || len(common) == 0 {
+			continue
+		}
+
+		resourceType := strings.SplitN(shape, "|", 2)[0]
+		group := map[string]interface{}{
+			"Type":       resourceType,
+			"Count":      len(ids),
+			"LogicalIds": ids,
+		}
+		for field, value := range common {
+			setField(group, field, value)
+		}
+		if len(varying) > 0 {
+			group["Varying"] = varying
+		}
+
+		for _, id := range ids {
+			delete(out, id)
+		}
+		out[fmt.Sprintf("%s (+%d similar)", ids[0], len(ids)-1)] = group
+	}
+
+	result := make(map[string]interface{}, len(infraState))
+	for k, v := range infraState {
+		result[k] = v
+	}
+	result["Resources"] = out
+	return result
+}
+
+// flattenResource maps a resource to comparable fields: top-level keys as-is
+// and each property as "Properties.<name>". Type is excluded.
+func flattenResource(resource map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for key, value := range resource {
+		switch key {
+		case "Type":
+		case "FriendlyName":
+			// a name taken from a property repeats it, and would keep
+			// otherwise similar resources apart
+			if !hasPropertyValue(resource, value) {
+				fields[key] = value
+			}
+		case "Properties":
+			if props, ok := value.(map[string]interface{}); ok {
+				for name, v := range props {
+					fields["Properties."+name] = v
+				}
+				continue
+			}
+			fields[key] = value
+		default:
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// hasPropertyValue reports whether a top-level property of the resource is
+// the string value.
+func hasPropertyValue(resource map[string]interface{}, value interface{}) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	props, _ := resource["Properties"].(map[string]interface{})
+	for _, v := range props {
+		if prop, ok := v.(string); ok && prop == s {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFields separates fields that are identical across all resources from
+// those that differ, returning the differing values in logical ID order.
+func splitFields(ids []string, flattened map[string]map[string]interface{}) (map[string]interface{}, map[string][]interface{}) {
+	common := make(map[string]interface{})
+	varying := make(map[string][]interface{})
+
+	for field, first := range flattened[ids[0]] {
+		firstJSON, _ := json.Marshal(first)
+		same := true
+		for _, id := range ids[1:] {
+			other, _ := json.Marshal(flattened[id][field])
+			if string(other) != string(firstJSON) {
+				same = false
+				break
+			}
+		}
+		if same {
+			common[field] = first
+			continue
+		}
+		values := make([]interface{}, len(ids))
+		for i, id := range ids {
+			values[i] = flattened[id][field]
+		}
+		varying[field] = values
+	}
+	return common, varying
+}
+
+func setField(group map[string]interface{}, field string, value interface{}) {
+	if name, ok := strings.CutPrefix(field, "Properties."); ok {
+		props, _ := group["Properties"].(map[string]interface{})
+		if props == nil {
+			props = make(map[string]interface{})
+			group["Properties"] = props
+		}
+		props[name] = value
+		return
+	}
+	group[field] = value
+}
@@ -0,0 +1,148 @@
+package state
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// shallowKey marks resources known only by ARN and tags from discovery.
+const shallowKey = "Shallow"
+
+// DiscoveryProvider builds a shallow inventory: every ARN the Resource Groups
+// Tagging API knows about, with its tags but no configuration. It is one
+// paginated call for the whole region instead of list calls per service, so
+// it stays fast on large accounts. Details are fetched when a question first
+// needs them (see Enrich and WithDetails) and saved back to the cache. The Tagging API only returns resources that are or were
+// tagged.
+type DiscoveryProvider struct {
+	Client *aws.Client
+}
+
+func (p *DiscoveryProvider) Scan(ctx context.Context, path string) (map[string]interface{}, error) {
+	infraState, err := (&LiveProvider{Client: p.Client}).ScanServices(ctx, []string{"tagging"})
+	if err != nil {
+		return nil, err
+	}
+	infraState["Source"] = "discovery"
+	return infraState, nil
+}
+
+// IsShallow reports whether a state is a discovery-only inventory.
+func IsShallow(infraState map[string]interface{}) bool {
+	return infraState["Source"] == "discovery"
+}
+
+// detailedServicesKey lists the services of a shallow inventory that a live
+// scan has already filled in.
+const detailedServicesKey = "DetailedServices"
+
+// WithDetails returns a copy of a state in which the resources of a live
+// scan of services replace their shallow entries, and records the services
+// as detailed. The input is not modified.
+func WithDetails(infraState, live map[string]interface{}, services []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(infraState))
+	for key, value := range infraState {
+		out[key] = value
+	}
+	merged := make(map[string]interface{})
+	if resources, ok := infraState["Resources"].(map[string]interface{}); ok {
+		for logicalID, resource := range resources {
+			merged[logicalID] = resource
+		}
+	}
+	if resources, ok := live["Resources"].(map[string]interface{}); ok {
+		for logicalID, resource := range resources {
+			merged[logicalID] = resource
+		}
+	}
+	out["Resources"] = merged
+	mergeScanTimes(out, live)
+
+	detailed := stringList(infraState[detailedServicesKey])
+	for _, service := range services {
+		if !slices.Contains(detailed, service) {
+			detailed = append(detailed, service)
+		}
+	}
+	if len(detailed) > 0 {
+		sort.Strings(detailed)
+		out[detailedServicesKey] = detailed
+	}
+	return out
+}
+
+// PendingServices returns the services that no live scan has filled in yet.
+func PendingServices(infraState map[string]interface{}, services []string) []string {
+	detailed := stringList(infraState[detailedServicesKey])
+	var pending []string
+	for _, service := range services {
+		if !slices.Contains(detailed, service) {
+			pending = append(pending, service)
+		}
+	}
+	return pending
+}
+
+// scanTagged records every tagged resource as a shallow entry, keyed like the
+// per-service scanners key theirs so their detailed entries replace it.
+func scanTagged(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(client.Tagging, &resourcegroupstaggingapi.GetResourcesInput{
+		ResourcesPerPage: awssdk.Int32(100),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mapping := range page.ResourceTagMappingList {
+			arn := awssdk.ToString(mapping.ResourceARN)
+			logicalID, name := arnID(arn)
+			if logicalID == "" {
+				continue
+			}
+			if _, scanned := resources[logicalID]; scanned {
+				continue
+			}
+
+			props := map[string]interface{}{"Arn": arn, "Name": name}
+			if len(mapping.Tags) > 0 {
+				tags := make(map[string]interface{}, len(mapping.Tags))
+				for _, tag := range mapping.Tags {
+					tags[awssdk.ToString(tag.Key)] = awssdk.ToString(tag.Value)
+				}
+				props["Tags"] = tags
+			}
+			resourceType := arnResourceType(arn)
+			if resourceType == "" {
+				resourceType = "AWS::" + strings.SplitN(arn, ":", 4)[2]
+			}
+			resources[logicalID] = map[string]interface{}{
+				"Type":       resourceType,
+				"Properties": props,
+				shallowKey:   true,
+			}
+		}
+	}
+	return nil
+}
+
+// arnID returns the "service/name" logical ID the live scanners use for the
+// resource an ARN names, and the name.
+func arnID(arn string) (string, string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[5] == "" {
+		return "", ""
+	}
+	resource := parts[5]
+	name := resource[strings.LastIndexAny(resource, ":/")+1:]
+	if name == "" {
+		return "", ""
+	}
+	return parts[2] + "/" + name, name
+}
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Drift is how the live account differs from a cached live scan.
+type Drift struct {
+	Services []string `json:"services"`
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	// Changed are resources in both whose properties differ
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Count is the number of resources that drifted.
+func (d *Drift) Count() int {
+	return len(d.Added) + len(d.Removed) + len(d.Changed)
+}
+
+// DiffLive compares a cached state with a fresh live scan of some of its
+// services, merged the way a background refresh would merge it, so denied
+// services and resources outside the state's selection are not reported as
+// removed or added. The cached state is not modified.
+func DiffLive(cached, live map[string]interface{}, services []string) (*Drift, error) {
+	before, err := normalizeState(cached)
+	if err != nil {
+		return nil, err
+	}
+	after, err := normalizeState(cached)
+	if err != nil {
+		return nil, err
+	}
+	fresh, err := normalizeState(live)
+	if err != nil {
+		return nil, err
+	}
+	RefreshServices(after, fresh, services)
+
+	drift := &Drift{Services: services}
+	oldResources, _ := before["Resources"].(map[string]interface{})
+	newResources, _ := after["Resources"].(map[string]interface{})
+	for id, raw := range newResources {
+		old, ok := oldResources[id]
+		if !ok {
+			drift.Added = append(drift.Added, id)
+			continue
+		}
+		oldProps, _ := old.(map[string]interface{})
+		newProps, _ := raw.(map[string]interface{})
+		if !reflect.DeepEqual(oldProps["Properties"], newProps["Properties"]) {
+			drift.Changed = append(drift.Changed, id)
+		}
+	}
+	for id := range oldResources {
+		if _, ok := newResources[id]; !ok {
+			drift.Removed = append(drift.Removed, id)
+		}
+	}
+	sort.Strings(drift.Added)
+	sort.Strings(drift.Removed)
+	sort.Strings(drift.Changed)
+	return drift, nil
+}
+
+// normalizeState deep-copies a state through JSON, so a fresh scan compares
+// equal to the same scan loaded back from the cache.
+func normalizeState(infraState map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(infraState)
+	if err != nil {
+		return nil, err
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
@@ -0,0 +1,182 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ddjura/cloudai/internal/aws"
+	"github.com/zalando/go-keyring"
+)
+
+// ErrCacheEncrypted is returned when an encrypted cache is loaded without
+// encryption configured.
+var ErrCacheEncrypted = errors.New("infrastructure cache is encrypted; set cache.encrypt: true to read it")
+
+const (
+	keyringService = "cloudai"
+	keyringUser    = "cache-key"
+)
+
+// Cipher encrypts the on-disk cache. Implementations return a self-describing
+// envelope so the cache can be decrypted without extra configuration.
+type Cipher interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// envelope is the on-disk format of an encrypted cache.
+type envelope struct {
+	Encrypted  int    `json:"cloudai_encrypted"`
+	KeySource  string `json:"key_source"`
+	WrappedKey string `json:"wrapped_key,omitempty"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// isEncrypted reports whether data is an encrypted cache envelope.
+func isEncrypted(data []byte) bool {
+	return bytes.Contains(data[:min(len(data), 64)], []byte(`"cloudai_encrypted"`))
+}
+
+// KeyringCipher encrypts with an AES-256 key kept in the OS keyring (macOS
+// Keychain, Windows Credential Manager, Secret Service on Linux). The key is
+// created on first use.
+type KeyringCipher struct{}
+
+func (KeyringCipher) key() ([]byte, error) {
+	stored, err := keyring.Get(keyringService, keyringUser)
+	if err == nil {
+		return base64.StdEncoding.DecodeString(stored)
+	}
+	if !errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read cache key from OS keyring: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := keyring.Set(keyringService, keyringUser, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store cache key in OS keyring: %w", err)
+	}
+	return key, nil
+}
+
+func (c KeyringCipher) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	key, err := c.key()
+	if err != nil {
+		return nil, err
+	}
+	return seal(envelope{KeySource: "keyring"}, key, plaintext)
+}
+
+func (c KeyringCipher) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	env, err := parseEnvelope(data, "keyring")
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.key()
+	if err != nil {
+		return nil, err
+	}
+	return open(env, key)
+}
+
+// KMSCipher uses envelope encryption: every save asks KMS for a fresh data
+// key, encrypts locally with it and stores only the KMS-wrapped copy.
+type KMSCipher struct {
+	Client *aws.Client
+	KeyID  string
+}
+
+func (c KMSCipher) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	out, err := c.Client.KMS.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   awssdk.String(c.KeyID),
+		KeySpec: kmstypes.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate KMS data key: %w", err)
+	}
+	env := envelope{KeySource: "kms", WrappedKey: base64.StdEncoding.EncodeToString(out.CiphertextBlob)}
+	return seal(env, out.Plaintext, plaintext)
+}
+
+func (c KMSCipher) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	env, err := parseEnvelope(data, "kms")
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key in cache: %w", err)
+	}
+	out, err := c.Client.KMS.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cache data key with KMS: %w", err)
+	}
+	return open(env, out.Plaintext)
+}
+
+func parseEnvelope(data []byte, keySource string) (envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, fmt.Errorf("could not parse encrypted cache: %w", err)
+	}
+	if env.KeySource != keySource {
+		return env, fmt.Errorf("cache was encrypted with %s but cache.key_source is %s", env.KeySource, keySource)
+	}
+	return env, nil
+}
+
+func seal(env envelope, key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	env.Encrypted = 1
+	env.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	env.Ciphertext = base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil))
+	return json.Marshal(env)
+}
+
+func open(env envelope, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce in cache: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext in cache: %w", err)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt cache (wrong key?): %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
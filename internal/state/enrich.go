@@ -0,0 +1,160 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// detailFetchers fetch the full configuration of a single resource, keyed by
+// the service part of its "service/name" logical ID. They build the same
+// entries the service scanners do.
+var detailFetchers = map[string]func(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error){
+	"lambda":     fetchFunction,
+	"apigateway": fetchRestAPI,
+	"s3":         fetchBucket,
+	"kms":        fetchKey,
+	"rds":        fetchDatabase,
+	"eks":        fetchEKSCluster,
+}
+
+// ShallowResources returns the logical IDs among ids that are still shallow
+// discovery entries, sorted.
+func ShallowResources(infraState map[string]interface{}, ids []string) []string {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	var shallow []string
+	for _, logicalID := range ids {
+		if resource, ok := resources[logicalID].(map[string]interface{}); ok && resource[shallowKey] == true {
+			shallow = append(shallow, logicalID)
+		}
+	}
+	sort.Strings(shallow)
+	return shallow
+}
+
+// Enrich replaces the shallow entries of the given resources with their full
+// configuration, one API call or two per resource, and returns the logical
+// IDs it enriched. Tags from discovery are kept. Resources of services
+// without a fetcher, and resources the credentials may not read, stay
+// shallow. Other failures leave the resource shallow too; the first is
+// returned once the rest are enriched.
+func Enrich(ctx context.Context, client *aws.Client, infraState map[string]interface{}, logicalIDs []string) ([]string, error) {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	var enriched []string
+	var firstErr error
+	for _, logicalID := range ShallowResources(infraState, logicalIDs) {
+		service, name, _ := strings.Cut(logicalID, "/")
+		fetch, ok := detailFetchers[service]
+		if !ok {
+			continue
+		}
+		shallow := resources[logicalID].(map[string]interface{})
+		shallowProps, _ := shallow["Properties"].(map[string]interface{})
+		arn, _ := shallowProps["Arn"].(string)
+
+		resource, err := fetch(ctx, client, name, arn)
+		if err != nil {
+			if !aws.IsAccessDenied(err) && firstErr == nil {
+				firstErr = fmt.Errorf("failed to fetch %s: %w", logicalID, err)
+			}
+			continue
+		}
+		if props, ok := resource["Properties"].(map[string]interface{}); ok && props["Tags"] == nil && shallowProps["Tags"] != nil {
+			props["Tags"] = shallowProps["Tags"]
+		}
+		resources[logicalID] = resource
+		enriched = append(enriched, logicalID)
+	}
+	return enriched, firstErr
+}
+
+func fetchFunction(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error) {
+	out, err := client.Lambda.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: awssdk.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	if out.Configuration == nil {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+	return lambdaResource(*out.Configuration), nil
+}
+
+func fetchRestAPI(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error) {
+	api, err := client.APIGateway.GetRestApi(ctx, &apigateway.GetRestApiInput{RestApiId: awssdk.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	routes, err := apiRoutes(ctx, client, api.Id)
+	if err != nil {
+		return nil, err
+	}
+	return apiResource(api.Name, api.Id, api.Description, routes), nil
+}
+
+func fetchBucket(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error) {
+	out, err := client.S3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: awssdk.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	return bucketResource(awssdk.String(name), out.BucketRegion), nil
+}
+
+func fetchKey(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error) {
+	aliases := make(map[string][]string)
+	pages := kms.NewListAliasesPaginator(client.KMS, &kms.ListAliasesInput{KeyId: awssdk.String(name)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range page.Aliases {
+			aliases[name] = append(aliases[name], awssdk.ToString(alias.AliasName))
+		}
+	}
+	return kmsKeyResource(ctx, client, awssdk.String(name), aliases)
+}
+
+// fetchDatabase tells clusters from instances by the ARN, which names them
+// "cluster:" and "db:".
+func fetchDatabase(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error) {
+	if strings.Contains(arn, ":cluster:") {
+		out, err := client.RDS.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{DBClusterIdentifier: awssdk.String(name)})
+		if err != nil {
+			return nil, err
+		}
+		if len(out.DBClusters) == 0 {
+			return nil, fmt.Errorf("DB cluster %s not found", name)
+		}
+		return rdsClusterResource(out.DBClusters[0]), nil
+	}
+
+	out, err := client.RDS.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: awssdk.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.DBInstances) == 0 {
+		return nil, fmt.Errorf("DB instance %s not found", name)
+	}
+	return rdsInstanceResource(out.DBInstances[0]), nil
+}
+
+func fetchEKSCluster(ctx context.Context, client *aws.Client, name, arn string) (map[string]interface{}, error) {
+	out, err := client.EKS.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: awssdk.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	if out.Cluster == nil {
+		return nil, fmt.Errorf("EKS cluster %s not found", name)
+	}
+	return eksResource(name, out.Cluster), nil
+}
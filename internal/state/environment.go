@@ -0,0 +1,92 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Environment is a named cache of a project, such as dev or prod, with the
+// AWS profile and region its live scans and lookups use.
+type Environment struct {
+	Profile string `json:"profile,omitempty"`
+	Region  string `json:"region,omitempty"`
+}
+
+// Environments are the named caches of a project and the one in use, kept in
+// .cloudai/environments.json. Without a current environment the project uses
+// the unnamed cache in .cloudai/cache.json.
+type Environments struct {
+	Current      string                 `json:"current,omitempty"`
+	Environments map[string]Environment `json:"environments,omitempty"`
+}
+
+// environmentName keeps names usable as directory names.
+var environmentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateEnvironmentName rejects names that cannot name a cache directory.
+func ValidateEnvironmentName(name string) error {
+	if !environmentName.MatchString(name) {
+		return fmt.Errorf("invalid environment name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+func environmentsFile(projectPath string) string {
+	return filepath.Join(projectPath, ".cloudai", "environments.json")
+}
+
+// LoadEnvironments reads a project's environments; a project without any has
+// an empty set.
+func LoadEnvironments(projectPath string) (*Environments, error) {
+	envs := &Environments{Environments: make(map[string]Environment)}
+	data, err := os.ReadFile(environmentsFile(projectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return envs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, envs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", environmentsFile(projectPath), err)
+	}
+	if envs.Environments == nil {
+		envs.Environments = make(map[string]Environment)
+	}
+	return envs, nil
+}
+
+// Save writes the environments back to the project.
+func (e *Environments) Save(projectPath string) error {
+	if err := os.MkdirAll(filepath.Join(projectPath, ".cloudai"), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(environmentsFile(projectPath), append(data, '\n'), 0644)
+}
+
+// CurrentEnvironment returns the name and settings of the environment a
+// project uses, or "" when it uses the unnamed cache. Unreadable settings
+// fall back to the unnamed cache.
+func CurrentEnvironment(projectPath string) (string, Environment) {
+	envs, err := LoadEnvironments(projectPath)
+	if err != nil || envs.Current == "" {
+		return "", Environment{}
+	}
+	return envs.Current, envs.Environments[envs.Current]
+}
+
+// environmentCacheDir is where an environment's cache lives: .cloudai for
+// the unnamed one, .cloudai/envs/<name> otherwise.
+func environmentCacheDir(projectPath, name string) string {
+	if name == "" {
+		return filepath.Join(projectPath, ".cloudai")
+	}
+	return filepath.Join(projectPath, ".cloudai", "envs", name)
+}
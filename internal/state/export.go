@@ -0,0 +1,190 @@
+package state
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// InventoryRow is a resource of the state in flat form, for CSV exports.
+type InventoryRow struct {
+	ID         string
+	Type       string
+	Name       string
+	ARN        string
+	Properties string // JSON
+}
+
+// Edge is a reference from one resource to another. Relation is the
+// property the reference was found in, or "DependsOn".
+type Edge struct {
+	From     string
+	To       string
+	Relation string
+}
+
+// Inventory lists the resources of a state sorted by logical ID.
+func Inventory(infraState map[string]interface{}) []InventoryRow {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	rows := make([]InventoryRow, 0, len(resources))
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+		row := InventoryRow{ID: logicalID, Type: resourceType, Name: resourceName(props)}
+		if arn, ok := props["Arn"].(string); ok {
+			row.ARN = arn
+		}
+		if len(props) > 0 {
+			if data, err := json.Marshal(props); err == nil {
+				row.Properties = string(data)
+			}
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows
+}
+
+// resourceName picks the friendliest name property: the first "*Name" or
+// "*Identifier" property in alphabetical order.
+func resourceName(props map[string]interface{}) string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, suffix := range []string{"Name", "Identifier"} {
+		for _, key := range keys {
+			if name, ok := props[key].(string); ok && name != "" && strings.HasSuffix(key, suffix) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Edges lists the references between resources: Ref, Fn::GetAtt and Fn::Sub
+// to a logical ID and DependsOn in templates, and property values containing
+// another resource's ARN or key ID in live scans.
+func Edges(infraState map[string]interface{}) []Edge {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+
+	// ARNs and key IDs are how live resources refer to each other
+	identifiers := make(map[string]string)
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		for _, field := range []string{"Arn", "KeyId"} {
+			if id, ok := props[field].(string); ok && len(id) >= minMentionLength {
+				identifiers[id] = logicalID
+			}
+		}
+	}
+
+	seen := make(map[Edge]bool)
+	var edges []Edge
+	add := func(edge Edge) {
+		if edge.From == edge.To || seen[edge] {
+			return
+		}
+		if _, ok := resources[edge.To]; !ok {
+			return
+		}
+		seen[edge] = true
+		edges = append(edges, edge)
+	}
+
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		for _, dep := range stringList(resource["DependsOn"]) {
+			add(Edge{From: logicalID, To: dep, Relation: "DependsOn"})
+		}
+		props, _ := resource["Properties"].(map[string]interface{})
+		for key, value := range props {
+			for _, target := range referencedIDs(value, identifiers) {
+				add(Edge{From: logicalID, To: target, Relation: key})
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Relation < edges[j].Relation
+	})
+	return edges
+}
+
+// referencedIDs returns the logical IDs a property value points at.
+func referencedIDs(value interface{}, identifiers map[string]string) []string {
+	var ids []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			for id, logicalID := range identifiers {
+				if strings.Contains(val, id) {
+					ids = append(ids, logicalID)
+				}
+			}
+		case []interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		case map[string]interface{}:
+			if ref, ok := val["Ref"].(string); ok {
+				ids = append(ids, ref)
+			}
+			if getAtt, ok := val["Fn::GetAtt"].([]interface{}); ok && len(getAtt) > 0 {
+				if id, ok := getAtt[0].(string); ok {
+					ids = append(ids, id)
+				}
+			}
+			if sub, ok := val["Fn::Sub"]; ok {
+				ids = append(ids, subReferences(sub)...)
+			}
+			for key, child := range val {
+				if key != "Ref" && key != "Fn::GetAtt" {
+					walk(child)
+				}
+			}
+		}
+	}
+	walk(value)
+	return ids
+}
+
+// subReferences extracts the logical IDs in ${Name} and ${Name.Attr}
+// placeholders of an Fn::Sub string.
+func subReferences(sub interface{}) []string {
+	var template string
+	switch v := sub.(type) {
+	case string:
+		template = v
+	case []interface{}:
+		if len(v) > 0 {
+			template, _ = v[0].(string)
+		}
+	}
+	var ids []string
+	for {
+		start := strings.Index(template, "${")
+		if start < 0 {
+			return ids
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return ids
+		}
+		name := template[start+2 : start+end]
+		if id, _, _ := strings.Cut(name, "."); !strings.HasPrefix(id, "AWS::") && !strings.HasPrefix(id, "!") {
+			ids = append(ids, id)
+		}
+		template = template[start+end+1:]
+	}
+}
@@ -0,0 +1,189 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectKey is the resource key naming the project a resource of a
+// federated state was scanned from
+const projectKey = "Project"
+
+// ProjectScan is the outcome of scanning one project of a monorepo
+type ProjectScan struct {
+	Path      string `json:"path"`
+	Resources int    `json:"resources"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FederatedProvider scans every IaC project under a directory, e.g. the
+// services of a monorepo, into one state. Resources are keyed
+// "<project>/<logical ID>" and name their project, so identically named
+// resources of different services stay apart.
+type FederatedProvider struct{}
+
+func (p *FederatedProvider) Scan(ctx context.Context, path string) (map[string]interface{}, error) {
+	projects := FindIaCProjects(path)
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("no IaC projects found under %s", path)
+	}
+
+	resources := make(map[string]interface{})
+	outputs := make(map[string]interface{})
+	var operations []interface{}
+	var secrets []SecretFinding
+	scans := make([]ProjectScan, 0, len(projects))
+	failed := 0
+	for _, dir := range projects {
+		project, err := filepath.Rel(path, dir)
+		if err != nil {
+			project = dir
+		}
+		project = filepath.ToSlash(project)
+		scan := ProjectScan{Path: project}
+
+		projectState, err := (&IaCProvider{}).Scan(ctx, dir)
+		if err != nil {
+			// one broken service should not hide the others
+			scan.Error = err.Error()
+			scans = append(scans, scan)
+			failed++
+			continue
+		}
+		projectResources, _ := projectState["Resources"].(map[string]interface{})
+		for logicalID, raw := range projectResources {
+			resource, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			resource = prefixReferences(resource, project, projectResources).(map[string]interface{})
+			resource[projectKey] = project
+			resources[project+"/"+logicalID] = resource
+		}
+		projectOutputs, _ := projectState["Outputs"].(map[string]interface{})
+		for name, output := range projectOutputs {
+			outputs[project+"/"+name] = prefixReferences(output, project, projectResources)
+		}
+		if ops, ok := projectState["APIOperations"].([]interface{}); ok {
+			for _, raw := range ops {
+				if op, ok := raw.(map[string]interface{}); ok {
+					op[projectKey] = project
+					operations = append(operations, op)
+				}
+			}
+		}
+		for _, finding := range SecretFindings(projectState) {
+			finding.Resource = project + "/" + finding.Resource
+			secrets = append(secrets, finding)
+		}
+		scan.Resources = len(projectResources)
+		scans = append(scans, scan)
+	}
+	if failed == len(projects) {
+		return nil, fmt.Errorf("every project under %s failed to scan; the first: %s: %s", path, scans[0].Path, scans[0].Error)
+	}
+
+	LinkKMSKeys(resources)
+	infraState := map[string]interface{}{
+		"Resources": resources,
+		"Projects":  scans,
+	}
+	if len(outputs) > 0 {
+		infraState["Outputs"] = outputs
+	}
+	if len(operations) > 0 {
+		infraState["APIOperations"] = operations
+	}
+	if len(secrets) > 0 {
+		infraState[SecretsKey] = secrets
+	}
+	return infraState, nil
+}
+
+// FindIaCProjects returns the directories under root that IaCProvider can
+// scan: CDK apps with a synthesized cdk.out and Terraform configurations.
+// Projects are not searched for nested ones, so the modules of a Terraform
+// configuration are not taken for projects of their own.
+func FindIaCProjects(root string) []string {
+	var projects []string
+	filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if path != root && (skippedScanDirs[entry.Name()] || strings.HasPrefix(entry.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if isIaCProject(path) {
+			projects = append(projects, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return projects
+}
+
+func isIaCProject(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "cdk.out", "manifest.json")); err == nil {
+		return true
+	}
+	for _, name := range terraformJSONFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	tfFiles, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	return len(tfFiles) > 0
+}
+
+// prefixReferences copies a template value with the Ref, Fn::GetAtt and
+// DependsOn references to the project's resources renamed like the
+// resources themselves.
+func prefixReferences(value interface{}, project string, resources map[string]interface{}) interface{} {
+	rename := func(id string) string {
+		if _, ok := resources[id]; ok {
+			return project + "/" + id
+		}
+		return id
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			switch key {
+			case "Ref":
+				if id, ok := child.(string); ok {
+					out[key] = rename(id)
+					continue
+				}
+			case "Fn::GetAtt":
+				if args, ok := child.([]interface{}); ok && len(args) > 0 {
+					if id, ok := args[0].(string); ok {
+						out[key] = append([]interface{}{rename(id)}, args[1:]...)
+						continue
+					}
+				}
+			case "DependsOn":
+				deps := stringList(child)
+				renamed := make([]interface{}, len(deps))
+				for i, dep := range deps {
+					renamed[i] = rename(dep)
+				}
+				out[key] = renamed
+				continue
+			}
+			out[key] = prefixReferences(child, project, resources)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = prefixReferences(child, project, resources)
+		}
+		return out
+	default:
+		return value
+	}
+}
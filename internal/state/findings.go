@@ -0,0 +1,278 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// severityWeights rank finding severities in a group's score, far enough
+// apart that the other factors never outrank severity
+var severityWeights = map[string]int{"CRITICAL": 120, "HIGH": 90, "MEDIUM": 60, "LOW": 30}
+
+// SeverityRank orders severity labels, INFORMATIONAL and unknown labels
+// lowest.
+func SeverityRank(severity string) int {
+	return severityWeights[strings.ToUpper(severity)]
+}
+
+// threatSteps are the next steps for a GuardDuty finding, by the threat
+// purpose its type starts with
+var threatSteps = map[string]string{
+	"Backdoor":            "Isolate the instance by replacing its security groups, snapshot its volumes for forensics and rebuild it from a known-good image",
+	"CredentialAccess":    "Rotate the credentials involved and review what they were used for in CloudTrail",
+	"CryptoCurrency":      "Isolate the instance by replacing its security groups, snapshot its volumes for forensics and rebuild it from a known-good image",
+	"DefenseEvasion":      "Re-enable the logging or protection that was turned off and find the principal that did it in CloudTrail",
+	"Discovery":           "Check in CloudTrail whether the principal's API calls were expected and restrict its policy if not",
+	"Execution":           "Check in CloudTrail whether the principal's API calls were expected and restrict its policy if not",
+	"Exfiltration":        "Block the principal, rotate its credentials and review the data it accessed",
+	"Impact":              "Block the principal, rotate its credentials and review the resources it changed",
+	"InitialAccess":       "Rotate the credentials involved and restrict where they can be used from",
+	"PenTest":             "Confirm the penetration test was authorized; otherwise block the principal and rotate its credentials",
+	"Persistence":         "Remove the users, keys or roles the principal created and rotate its credentials",
+	"Policy":              "Review the configuration change the finding describes and revert it if it was not intended",
+	"PrivilegeEscalation": "Revoke the permissions the principal gained and rotate its credentials",
+	"Recon":               "Restrict the ports and sources the resource's security groups allow",
+	"Stealth":             "Re-enable the logging that was turned off and find the principal that did it in CloudTrail",
+	"Trojan":              "Isolate the instance by replacing its security groups, snapshot its volumes for forensics and rebuild it from a known-good image",
+	"UnauthorizedAccess":  "Check whether the access was expected; if not, rotate the credentials involved and tighten the security group or policy that allowed it",
+}
+
+// FindingGroup is the findings of one kind, merged across resources and
+// sources: a GuardDuty finding type or a Security Hub control.
+type FindingGroup struct {
+	Title    string `json:"title"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	// Score orders groups: severity first, then how many resources depend
+	// on the affected ones, recent activity and active threats
+	Score   int      `json:"score"`
+	Sources []string `json:"sources"`
+	// Findings is how many findings were merged, Occurrences how often
+	// their activity was observed
+	Findings    int      `json:"findings"`
+	Occurrences int      `json:"occurrences"`
+	Resources   []string `json:"resources"`
+	// InScan are the logical IDs of the affected resources in the cache,
+	// and Dependents how many resources depend on them
+	InScan     []string  `json:"in_scan,omitempty"`
+	Dependents int       `json:"dependents"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	NextSteps  []string  `json:"next_steps"`
+	IDs        []string  `json:"ids"`
+}
+
+// FindingsReport is the prioritized summary of an account's findings.
+type FindingsReport struct {
+	Total  int            `json:"total"`
+	Groups []FindingGroup `json:"groups"`
+	// Disabled are the services that are not enabled in the region
+	Disabled []string      `json:"disabled,omitempty"`
+	Warnings []ScanWarning `json:"warnings,omitempty"`
+}
+
+// CollectFindings pulls up to limit active findings from GuardDuty and
+// Security Hub each and summarizes them against the cached state. A service
+// that is not enabled, or not readable, is skipped and reported.
+func CollectFindings(ctx context.Context, client *aws.Client, infraState map[string]interface{}, limit int, now time.Time) (*FindingsReport, error) {
+	report := &FindingsReport{}
+	skip := func(service string, err error, grant ...string) error {
+		switch {
+		case errors.Is(err, aws.ErrNotEnabled):
+			report.Disabled = append(report.Disabled, service)
+		case aws.IsAccessDenied(err):
+			report.Warnings = append(report.Warnings, ScanWarning{Service: strings.ToLower(strings.ReplaceAll(service, " ", "")), Error: err.Error(), Grant: grant})
+		default:
+			return err
+		}
+		return nil
+	}
+
+	guardDuty, err := client.GuardDutyFindings(ctx, limit)
+	if err = skip(aws.SourceGuardDuty, err, "guardduty:ListDetectors", "guardduty:ListFindings", "guardduty:GetFindings"); err != nil {
+		return nil, err
+	}
+	// Security Hub repeats GuardDuty's findings; they are only needed from
+	// it when GuardDuty could not be read directly
+	securityHub, err := client.SecurityHubFindings(ctx, limit, guardDuty != nil)
+	if err = skip(aws.SourceSecurityHub, err, "securityhub:GetFindings"); err != nil {
+		return nil, err
+	}
+	if len(report.Disabled) == 2 {
+		return nil, fmt.Errorf("neither GuardDuty nor Security Hub is enabled in this region")
+	}
+
+	findings := append(guardDuty, securityHub...)
+	report.Total = len(findings)
+	report.Groups = SummarizeFindings(infraState, findings, now)
+	return report, nil
+}
+
+// SummarizeFindings merges findings of the same kind, correlates their
+// resources with the cached state and orders the groups by score, highest
+// first.
+func SummarizeFindings(infraState map[string]interface{}, findings []aws.SecurityFinding, now time.Time) []FindingGroup {
+	resolve := findingResolver(infraState)
+	groups := map[string]*FindingGroup{}
+	var order []string
+	for _, finding := range findings {
+		kind := finding.Type
+		if finding.Source == aws.SourceSecurityHub || kind == "" {
+			// controls of different standards share a generator, not a title
+			kind = finding.Title
+		}
+		key := strings.ToLower(kind)
+		if finding.Source == aws.SourceGuardDuty {
+			// GuardDuty titles name the resource; the type is the kind
+			key = "guardduty|" + key
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &FindingGroup{Title: finding.Title, Type: finding.Type, Severity: finding.Severity, FirstSeen: finding.FirstSeen}
+			if finding.Source == aws.SourceGuardDuty {
+				group.Title = guardDutyTitle(finding.Type)
+			}
+			groups[key] = group
+			order = append(order, key)
+		}
+
+		group.Findings++
+		group.Occurrences += finding.Count
+		group.IDs = append(group.IDs, finding.ID)
+		group.Resources = appendUnique(group.Resources, finding.Resources...)
+		group.Sources = appendUnique(group.Sources, finding.Source)
+		if SeverityRank(finding.Severity) > SeverityRank(group.Severity) {
+			group.Severity = finding.Severity
+		}
+		if !finding.FirstSeen.IsZero() && (group.FirstSeen.IsZero() || finding.FirstSeen.Before(group.FirstSeen)) {
+			group.FirstSeen = finding.FirstSeen
+		}
+		if finding.LastSeen.After(group.LastSeen) {
+			group.LastSeen = finding.LastSeen
+		}
+		if finding.Recommendation != "" {
+			step := finding.Recommendation
+			if finding.RecommendationURL != "" {
+				step += " (" + finding.RecommendationURL + ")"
+			}
+			group.NextSteps = appendUnique(group.NextSteps, step)
+		} else if finding.Source == aws.SourceGuardDuty {
+			purpose, _, _ := strings.Cut(finding.Type, ":")
+			if step, ok := threatSteps[purpose]; ok {
+				group.NextSteps = appendUnique(group.NextSteps, step)
+			}
+		}
+	}
+
+	result := make([]FindingGroup, 0, len(groups))
+	for _, key := range order {
+		group := groups[key]
+		sort.Strings(group.Resources)
+		mostDependents, mostDependentsID := 0, ""
+		for _, resource := range group.Resources {
+			id := resolve(resource)
+			if id == "" {
+				continue
+			}
+			group.InScan = appendUnique(group.InScan, id)
+			if count := Impact(infraState, id).Count(); count > 0 {
+				group.Dependents += count
+				if count > mostDependents {
+					mostDependents, mostDependentsID = count, id
+				}
+			}
+		}
+		if mostDependentsID != "" {
+			group.NextSteps = append(group.NextSteps, fmt.Sprintf("%d resource(s) depend on %s; see what a change to it affects with 'cloudai impact %s'", mostDependents, mostDependentsID, mostDependentsID))
+		}
+		if len(group.NextSteps) == 0 {
+			group.NextSteps = []string{fmt.Sprintf("Review the finding in the %s console", group.Sources[0])}
+		}
+
+		group.Score = SeverityRank(group.Severity) + min(group.Dependents, 10) + min(len(group.Resources), 5)
+		if now.Sub(group.LastSeen) < 24*time.Hour {
+			group.Score += 5
+		}
+		for _, source := range group.Sources {
+			if source == aws.SourceGuardDuty {
+				// an active threat rather than a configuration gap
+				group.Score += 5
+			}
+		}
+		result = append(result, *group)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	return result
+}
+
+// guardDutyTitle names a GuardDuty finding type without the resource the
+// title of each finding names: "UnauthorizedAccess:EC2/SSHBruteForce"
+// becomes "EC2 SSHBruteForce (UnauthorizedAccess)".
+func guardDutyTitle(findingType string) string {
+	purpose, rest, ok := strings.Cut(findingType, ":")
+	if !ok {
+		return findingType
+	}
+	rest, _, _ = strings.Cut(rest, "!")
+	return strings.ReplaceAll(rest, "/", " ") + " (" + purpose + ")"
+}
+
+// findingResolver returns a function that finds the logical ID of the
+// resource a finding names by ARN or ID, or "" when it is not in the cache.
+func findingResolver(infraState map[string]interface{}) func(string) string {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	names := map[string][]string{}
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		names[id] = resourceNames(id, props)
+		for _, key := range []string{"InstanceId", "KeyId", "DBInstanceIdentifier", "FunctionName", "BucketName"} {
+			if value, ok := props[key].(string); ok && value != "" {
+				names[id] = append(names[id], value)
+			}
+		}
+	}
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return func(ref string) string {
+		for _, id := range ids {
+			for _, name := range names[id] {
+				if strings.EqualFold(ref, name) || strings.HasSuffix(ref, ":"+name) || strings.HasSuffix(ref, "/"+name) {
+					return id
+				}
+			}
+		}
+		return ""
+	}
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := value == ""
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,216 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholders for the parts of a derived ARN the scan does not know
+const (
+	unknownRegion  = "<region>"
+	unknownAccount = "<account>"
+)
+
+// PolicyTarget is a resource of the state an IAM policy can refer to.
+type PolicyTarget struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	ARN  string `json:"arn"`
+	// Derived is set when the ARN was built from the type and name rather
+	// than recorded by a live scan
+	Derived bool `json:"derived,omitempty"`
+	// Role is the execution role of a function or state machine, the
+	// principal a policy for it is attached to
+	Role string `json:"role,omitempty"`
+}
+
+// IAMPolicy is an identity-based IAM policy document.
+type IAMPolicy struct {
+	Version   string         `json:"Version"`
+	Statement []IAMStatement `json:"Statement"`
+}
+
+// IAMStatement is a statement of an IAM policy.
+type IAMStatement struct {
+	Sid      string   `json:"Sid,omitempty"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// PolicyFinding is a problem ReviewPolicy found. Severity is "error" for a
+// statement that must be fixed, "warning" for one to look at.
+type PolicyFinding struct {
+	Severity  string `json:"severity"`
+	Statement string `json:"statement,omitempty"`
+	Message   string `json:"message"`
+}
+
+// arnTemplates build the ARN of resources a template scan records only by
+// name: %[1]s is the name, %[2]s the region and %[3]s the account.
+var arnTemplates = map[string]struct {
+	nameProperty string
+	template     string
+}{
+	"AWS::S3::Bucket":                  {"BucketName", "arn:aws:s3:::%[1]s"},
+	"AWS::DynamoDB::Table":             {"TableName", "arn:aws:dynamodb:%[2]s:%[3]s:table/%[1]s"},
+	"AWS::Lambda::Function":            {"FunctionName", "arn:aws:lambda:%[2]s:%[3]s:function:%[1]s"},
+	"AWS::SQS::Queue":                  {"QueueName", "arn:aws:sqs:%[2]s:%[3]s:%[1]s"},
+	"AWS::SNS::Topic":                  {"TopicName", "arn:aws:sns:%[2]s:%[3]s:%[1]s"},
+	"AWS::IAM::Role":                   {"RoleName", "arn:aws:iam::%[3]s:role/%[1]s"},
+	"AWS::StepFunctions::StateMachine": {"StateMachineName", "arn:aws:states:%[2]s:%[3]s:stateMachine:%[1]s"},
+	"AWS::Events::EventBus":            {"Name", "arn:aws:events:%[2]s:%[3]s:event-bus/%[1]s"},
+	"AWS::Kinesis::Stream":             {"Name", "arn:aws:kinesis:%[2]s:%[3]s:stream/%[1]s"},
+	"AWS::Logs::LogGroup":              {"LogGroupName", "arn:aws:logs:%[2]s:%[3]s:log-group:%[1]s"},
+	// Secrets Manager appends a random suffix to secret ARNs
+	"AWS::SecretsManager::Secret": {"Name", "arn:aws:secretsmanager:%[2]s:%[3]s:secret:%[1]s-*"},
+}
+
+// PolicyTargets lists the resources of a state that have an ARN, recorded
+// or derived from their name, sorted by logical ID. Parts of a derived ARN
+// the state does not know are left as <region> and <account>.
+func PolicyTargets(infraState map[string]interface{}) []PolicyTarget {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	var targets []PolicyTarget
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+		target := PolicyTarget{ID: logicalID, Type: resourceType, Name: resourceName(props)}
+		if role, ok := props["Role"].(string); ok && strings.HasPrefix(role, "arn:") {
+			target.Role = role
+		} else if role, ok := props["RoleArn"].(string); ok && strings.HasPrefix(role, "arn:") {
+			target.Role = role
+		}
+
+		if arn, ok := props["Arn"].(string); ok && strings.HasPrefix(arn, "arn:") {
+			target.ARN = arn
+		} else if spec, ok := arnTemplates[resourceType]; ok {
+			name, _ := props[spec.nameProperty].(string)
+			if name == "" {
+				continue
+			}
+			region, _ := props["Region"].(string)
+			if region == "" {
+				region = unknownRegion
+			}
+			account, _ := props["AccountId"].(string)
+			if account == "" {
+				account = unknownAccount
+			}
+			target.ARN, target.Derived = fmt.Sprintf(spec.template, name, region, account), true
+		} else {
+			continue
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+// actionPattern is the service:Action form of an IAM action
+var actionPattern = regexp.MustCompile(`^[a-z0-9-]+:[A-Za-z0-9*]+$`)
+
+// sensitiveActions let a principal widen its own access or reach other
+// accounts' resources
+var sensitiveActions = []string{"iam:PassRole", "iam:Create", "iam:Put", "iam:Attach", "iam:Update", "sts:AssumeRole", "kms:CreateGrant", "lambda:AddPermission"}
+
+// ReviewPolicy checks a generated policy against the scanned resources:
+// every resource must be one of targets, or an object, index or stream
+// under one; actions must be well formed and specific. Findings are sorted
+// with errors first.
+func ReviewPolicy(policy IAMPolicy, targets []PolicyTarget) []PolicyFinding {
+	var findings []PolicyFinding
+	add := func(severity, sid, format string, args ...interface{}) {
+		findings = append(findings, PolicyFinding{Severity: severity, Statement: sid, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(policy.Statement) == 0 {
+		add("error", "", "the policy has no statements")
+	}
+	for i, statement := range policy.Statement {
+		sid := statement.Sid
+		if sid == "" {
+			sid = fmt.Sprintf("#%d", i+1)
+		}
+		if statement.Effect != "Allow" && statement.Effect != "Deny" {
+			add("error", sid, "effect %q must be Allow or Deny", statement.Effect)
+		}
+		if len(statement.Action) == 0 || len(statement.Resource) == 0 {
+			add("error", sid, "a statement needs at least one action and one resource")
+		}
+
+		services := map[string]bool{}
+		for _, action := range statement.Action {
+			switch {
+			case statement.Effect == "Allow" && (action == "*" || strings.HasSuffix(action, ":*")):
+				add("error", sid, "%s allows every action of the service; list the actions needed", action)
+			case action != "*" && !actionPattern.MatchString(action):
+				add("error", sid, "%q is not an IAM action (service:Action)", action)
+			case strings.Contains(action, "*") && statement.Effect == "Allow":
+				add("warning", sid, "%s is a wildcard; check every action it matches is intended", action)
+			}
+			if statement.Effect == "Allow" {
+				for _, sensitive := range sensitiveActions {
+					if strings.HasPrefix(action, sensitive) {
+						add("warning", sid, "%s can be used to escalate privileges", action)
+						break
+					}
+				}
+				if strings.Contains(action, ":Delete") {
+					add("warning", sid, "%s is destructive", action)
+				}
+			}
+			if service, _, ok := strings.Cut(action, ":"); ok {
+				services[service] = true
+			}
+		}
+
+		matchedService := false
+		for _, resource := range statement.Resource {
+			if resource == "*" {
+				matchedService = true
+				if statement.Effect == "Allow" {
+					add("warning", sid, "resource * applies the statement to every resource in the account")
+				}
+				continue
+			}
+			target, ok := matchTarget(resource, targets)
+			if !ok {
+				add("error", sid, "%s is not a resource in the scan", resource)
+				continue
+			}
+			if strings.Contains(resource, unknownRegion) || strings.Contains(resource, unknownAccount) {
+				add("warning", sid, "fill in the region and account of %s (%s), which the scan does not record", target.ID, resource)
+			}
+			if parts := strings.SplitN(resource, ":", 4); len(parts) == 4 && services[parts[2]] {
+				matchedService = true
+			}
+		}
+		if !matchedService && len(statement.Resource) > 0 && len(services) > 0 {
+			add("warning", sid, "none of the resources belongs to the actions' service, so the statement grants nothing")
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity == "error" && findings[j].Severity != "error"
+	})
+	return findings
+}
+
+// matchTarget finds the scanned resource an ARN in a policy refers to: the
+// resource itself or something under it, like the objects of a bucket or the
+// indexes of a table.
+func matchTarget(resource string, targets []PolicyTarget) (PolicyTarget, bool) {
+	for _, target := range targets {
+		arn := strings.TrimSuffix(target.ARN, "*")
+		if resource == target.ARN || resource == arn ||
+			strings.HasPrefix(resource, arn+"/") || strings.HasPrefix(resource, arn+":") {
+			return target, true
+		}
+	}
+	return PolicyTarget{}, false
+}
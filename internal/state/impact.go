@@ -0,0 +1,111 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImpactNode is a resource in the blast radius of another: it references
+// its parent, so it breaks when the parent is deleted or throttled.
+type ImpactNode struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	// DefinedIn is the file:line of the code that defines it, when known
+	DefinedIn string `json:"defined_in,omitempty"`
+	// Relation is the property that references the parent
+	Relation   string        `json:"relation,omitempty"`
+	Dependents []*ImpactNode `json:"dependents,omitempty"`
+}
+
+// Count returns the number of resources below the node.
+func (n *ImpactNode) Count() int {
+	count := 0
+	for _, dependent := range n.Dependents {
+		count += 1 + dependent.Count()
+	}
+	return count
+}
+
+// Impact returns the tree of resources that depend on the resource id,
+// directly or through others. Each resource appears once, under the
+// shortest chain of references that reaches it.
+func Impact(infraState map[string]interface{}, id string) *ImpactNode {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	dependents := make(map[string][]Edge)
+	for _, edge := range Edges(infraState) {
+		dependents[edge.To] = append(dependents[edge.To], edge)
+	}
+	// a property names the dependency better than DependsOn does
+	for _, edges := range dependents {
+		sort.SliceStable(edges, func(i, j int) bool {
+			return edges[i].From == edges[j].From && edges[i].Relation != "DependsOn" && edges[j].Relation == "DependsOn"
+		})
+	}
+
+	node := func(id, relation string) *ImpactNode {
+		resource, _ := resources[id].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+		return &ImpactNode{ID: id, Type: resourceType, Name: resourceName(props), DefinedIn: DefinedIn(resource), Relation: relation}
+	}
+
+	root := node(id, "")
+	seen := map[string]bool{id: true}
+	queue := []*ImpactNode{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range dependents[current.ID] {
+			if seen[edge.From] {
+				continue
+			}
+			seen[edge.From] = true
+			child := node(edge.From, edge.Relation)
+			current.Dependents = append(current.Dependents, child)
+			queue = append(queue, child)
+		}
+	}
+	return root
+}
+
+// ResolveResource returns the logical ID of the resource ref names: its
+// logical ID, a name property or its ARN, compared case-insensitively.
+func ResolveResource(infraState map[string]interface{}, ref string) (string, error) {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	if _, ok := resources[ref]; ok {
+		return ref, nil
+	}
+
+	var matches []string
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		names := []string{logicalID}
+		for key, value := range props {
+			if s, ok := value.(string); ok && (key == "Arn" || strings.HasSuffix(key, "Name")) {
+				names = append(names, s)
+			}
+		}
+		for _, name := range names {
+			if strings.EqualFold(name, ref) {
+				matches = append(matches, logicalID)
+				break
+			}
+		}
+	}
+	if len(matches) == 0 {
+		matches = MentionedResources(infraState, ref)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no resource named %q in the cache", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Strings(matches)
+		return "", fmt.Errorf("%q matches %d resources: %s", ref, len(matches), strings.Join(matches, ", "))
+	}
+}
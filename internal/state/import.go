@@ -0,0 +1,401 @@
+package state
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ImportFormats are the external inventory formats Import understands.
+var ImportFormats = []string{"config-snapshot", "steampipe", "csv"}
+
+// importedFromKey marks resources that came from an import rather than a
+// scan, with the source they came from.
+const importedFromKey = "ImportedFrom"
+
+// arnResourceTypes maps "service:resource" from an ARN to the CloudFormation
+// type, for inventories that only carry ARNs.
+var arnResourceTypes = map[string]string{
+	"lambda:function":         "AWS::Lambda::Function",
+	"s3:":                     "AWS::S3::Bucket",
+	"dynamodb:table":          "AWS::DynamoDB::Table",
+	"sqs:":                    "AWS::SQS::Queue",
+	"sns:":                    "AWS::SNS::Topic",
+	"iam:role":                "AWS::IAM::Role",
+	"iam:user":                "AWS::IAM::User",
+	"iam:policy":              "AWS::IAM::ManagedPolicy",
+	"kms:key":                 "AWS::KMS::Key",
+	"rds:db":                  "AWS::RDS::DBInstance",
+	"rds:cluster":             "AWS::RDS::DBCluster",
+	"eks:cluster":             "AWS::EKS::Cluster",
+	"ec2:instance":            "AWS::EC2::Instance",
+	"ec2:vpc":                 "AWS::EC2::VPC",
+	"ec2:subnet":              "AWS::EC2::Subnet",
+	"ec2:security-group":      "AWS::EC2::SecurityGroup",
+	"apigateway:":             "AWS::ApiGateway::RestApi",
+	"states:stateMachine":     "AWS::StepFunctions::StateMachine",
+	"events:rule":             "AWS::Events::Rule",
+	"logs:log-group":          "AWS::Logs::LogGroup",
+	"cloudfront:distribution": "AWS::CloudFront::Distribution",
+}
+
+// DetectImportFormat guesses the format of an inventory file from its
+// content: CSV unless it is JSON, and a Config snapshot when the JSON has
+// configurationItems or aggregator query Results.
+func DetectImportFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return "csv"
+	}
+	var probe map[string]json.RawMessage
+	if json.Unmarshal(trimmed, &probe) == nil {
+		if _, ok := probe["configurationItems"]; ok {
+			return "config-snapshot"
+		}
+		if _, ok := probe["Results"]; ok {
+			return "config-snapshot"
+		}
+	}
+	return "steampipe"
+}
+
+// Import parses an external inventory into resources in the shape of the
+// scanned state, keyed by a logical ID derived from the resource name.
+// defaultType is used for rows that carry neither a type nor an ARN.
+func Import(format string, data []byte, defaultType string) (map[string]interface{}, error) {
+	var items []map[string]interface{}
+	var err error
+	switch format {
+	case "config-snapshot":
+		items, err = parseConfigSnapshot(data)
+	case "steampipe":
+		items, err = parseSteampipe(data, defaultType)
+	case "csv":
+		items, err = parseInventoryCSV(data, defaultType)
+	default:
+		return nil, fmt.Errorf("unknown import format %q: use %s", format, strings.Join(ImportFormats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return itemResources(items), nil
+}
+
+// itemResources keys parsed inventory items by a unique logical ID.
+func itemResources(items []map[string]interface{}) map[string]interface{} {
+	resources := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		resources[uniqueID(resources, item["id"].(string))] = map[string]interface{}{
+			"Type":       item["type"],
+			"Properties": item["properties"],
+		}
+	}
+	return resources
+}
+
+// MergeImport adds imported resources to the state, replacing everything a
+// previous import from the same source added. It returns the number of
+// resources added.
+func MergeImport(infraState, imported map[string]interface{}, source string) int {
+	resources, ok := infraState["Resources"].(map[string]interface{})
+	if !ok {
+		resources = make(map[string]interface{})
+		infraState["Resources"] = resources
+	}
+	for logicalID, raw := range resources {
+		if resource, ok := raw.(map[string]interface{}); ok && resource[importedFromKey] == source {
+			delete(resources, logicalID)
+		}
+	}
+	for logicalID, raw := range imported {
+		resource := raw.(map[string]interface{})
+		resource[importedFromKey] = source
+		resources[uniqueID(resources, logicalID)] = resource
+	}
+	return len(imported)
+}
+
+// ImportSource returns the import a resource came from, as "format:file", or
+// "" for scanned resources.
+func ImportSource(resource map[string]interface{}) string {
+	source, _ := resource[importedFromKey].(string)
+	return source
+}
+
+// KeepImports copies the imported resources of a previous state into a fresh
+// scan, so rescanning does not drop them.
+func KeepImports(previous, next map[string]interface{}) {
+	old, _ := previous["Resources"].(map[string]interface{})
+	for logicalID, raw := range old {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource[importedFromKey] == nil {
+			continue
+		}
+		resources, ok := next["Resources"].(map[string]interface{})
+		if !ok {
+			resources = make(map[string]interface{})
+			next["Resources"] = resources
+		}
+		resources[uniqueID(resources, logicalID)] = resource
+	}
+}
+
+// parseConfigSnapshot reads an AWS Config snapshot (configurationItems) or
+// the output of an aggregator advanced query (Results, one JSON document per
+// entry).
+func parseConfigSnapshot(data []byte) ([]map[string]interface{}, error) {
+	var doc struct {
+		ConfigurationItems []map[string]interface{} `json:"configurationItems"`
+		Results            []string                 `json:"Results"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse Config snapshot: %w", err)
+	}
+	raw := doc.ConfigurationItems
+	for _, result := range doc.Results {
+		var item map[string]interface{}
+		if err := json.Unmarshal([]byte(result), &item); err != nil {
+			return nil, fmt.Errorf("failed to parse Config query result: %w", err)
+		}
+		raw = append(raw, item)
+	}
+	return configItems(raw), nil
+}
+
+// configItems converts AWS Config configuration items into inventory items.
+// The configuration becomes the properties, with its keys in PascalCase.
+func configItems(raw []map[string]interface{}) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(raw))
+	for _, ci := range raw {
+		resourceType, _ := ci["resourceType"].(string)
+		resourceID, _ := ci["resourceId"].(string)
+		name, _ := ci["resourceName"].(string)
+		if resourceType == "" || resourceID == "" {
+			continue
+		}
+
+		props := make(map[string]interface{})
+		configuration := ci["configuration"]
+		if s, ok := configuration.(string); ok {
+			// snapshots delivered by Config embed the configuration as a JSON string
+			var decoded interface{}
+			if json.Unmarshal([]byte(s), &decoded) == nil {
+				configuration = decoded
+			}
+		}
+		if cfg, ok := configuration.(map[string]interface{}); ok {
+			for key, value := range cfg {
+				props[exportedKey(key)] = value
+			}
+		}
+		if name == "" {
+			name = resourceID
+		}
+		props["Name"] = name
+		props["ResourceId"] = resourceID
+		for key, field := range map[string]string{"ARN": "Arn", "arn": "Arn", "awsRegion": "Region", "awsAccountId": "AccountId", "accountId": "AccountId"} {
+			if v, ok := ci[key].(string); ok && v != "" {
+				props[field] = v
+			}
+		}
+		if tags := configTags(ci["tags"]); len(tags) > 0 {
+			props["Tags"] = tags
+		}
+		if rels, ok := ci["relationships"].([]interface{}); ok && len(rels) > 0 {
+			props["Relationships"] = rels
+		}
+		items = append(items, map[string]interface{}{"id": name, "type": resourceType, "properties": props})
+	}
+	return items
+}
+
+// parseSteampipe reads 'steampipe query --output json': either a list of
+// rows or an object with "rows".
+func parseSteampipe(data []byte, defaultType string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var doc struct {
+			Rows []map[string]interface{} `json:"rows"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse Steampipe output: %w", err)
+		}
+		rows = doc.Rows
+	}
+
+	items := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		props := make(map[string]interface{}, len(row))
+		for key, value := range row {
+			if value != nil {
+				props[exportedKey(key)] = value
+			}
+		}
+		item, err := inventoryItem(props, defaultType)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseInventoryCSV reads a CSV with a header row. id, type, name, arn and
+// properties (a JSON object, as written by 'cloudai export') are recognised;
+// every other column becomes a property.
+func parseInventoryCSV(data []byte, defaultType string) ([]map[string]interface{}, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Contains(header, []byte("\t")) {
+		// TSV as written by 'cloudai export --format tsv' is not quoted
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, nil
+	}
+
+	columns := records[0]
+	items := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		props := make(map[string]interface{})
+		for i, column := range columns {
+			if i >= len(record) || record[i] == "" {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(column)) {
+			case "properties":
+				var extra map[string]interface{}
+				if err := json.Unmarshal([]byte(record[i]), &extra); err != nil {
+					return nil, fmt.Errorf("failed to parse properties of row %d: %w", len(items)+1, err)
+				}
+				for key, value := range extra {
+					props[key] = value
+				}
+			default:
+				props[exportedKey(strings.TrimSpace(column))] = record[i]
+			}
+		}
+		item, err := inventoryItem(props, defaultType)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// inventoryItem picks the ID and type of a flat inventory row. Id and Type
+// columns are removed from the properties.
+func inventoryItem(props map[string]interface{}, defaultType string) (map[string]interface{}, error) {
+	resourceType, _ := props["Type"].(string)
+	delete(props, "Type")
+	arn, _ := props["Arn"].(string)
+	if resourceType == "" {
+		resourceType = arnResourceType(arn)
+	}
+	if resourceType == "" {
+		resourceType = defaultType
+	}
+	if resourceType == "" {
+		return nil, fmt.Errorf("cannot tell the type of a resource without a type or arn column; pass --type")
+	}
+
+	id, _ := props["Id"].(string)
+	delete(props, "Id")
+	if name := resourceName(props); id == "" && name != "" {
+		id = name
+	}
+	if title, ok := props["Title"].(string); id == "" && ok {
+		id = title
+	}
+	if id == "" {
+		id = arn
+	}
+	return map[string]interface{}{"id": id, "type": resourceType, "properties": props}, nil
+}
+
+// arnResourceType maps an ARN to a CloudFormation type, or "" when unknown.
+func arnResourceType(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	service, resource := parts[2], parts[5]
+	kind := ""
+	if i := strings.IndexAny(resource, ":/"); i > 0 {
+		kind = resource[:i]
+	}
+	if t, ok := arnResourceTypes[service+":"+kind]; ok {
+		return t
+	}
+	return arnResourceTypes[service+":"]
+}
+
+// configTags turns Config tags, a map or a list of key/value pairs, into a map.
+func configTags(raw interface{}) map[string]interface{} {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		return v
+	case []interface{}:
+		tags := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			if tag, ok := item.(map[string]interface{}); ok {
+				if key, ok := tag["key"].(string); ok {
+					tags[key] = tag["value"]
+				}
+			}
+		}
+		return tags
+	}
+	return nil
+}
+
+// exportedKey turns snake_case and camelCase keys into the PascalCase used
+// by CloudFormation properties, so "*Name" matching works on imports too.
+func exportedKey(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if r == '_' || r == '-' || r == ' ' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// uniqueID turns a name into a logical ID that is not yet in resources.
+func uniqueID(resources map[string]interface{}, name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			b.WriteRune(r)
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "Imported"
+	}
+	candidate := id
+	for i := 2; ; i++ {
+		if _, taken := resources[candidate]; !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", id, i)
+	}
+}
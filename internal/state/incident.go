@@ -0,0 +1,381 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// incidentWords describe how something fails rather than what, so they
+// never pick the resources of an incident
+var incidentWords = map[string]bool{
+	"failing": true, "failed": true, "fails": true, "error": true, "errors": true,
+	"broken": true, "down": true, "slow": true, "timeout": true, "timeouts": true,
+	"timing": true, "returning": true, "throwing": true, "since": true, "after": true,
+	"users": true, "customers": true, "requests": true, "again": true, "with": true,
+}
+
+// errorMetric is a CloudWatch metric that counts failures of a resource
+// type, addressed by the dimension holding the resource's name.
+type errorMetric struct {
+	namespace string
+	metric    string
+	dimension string
+}
+
+var errorMetrics = map[string][]errorMetric{
+	"AWS::Lambda::Function": {
+		{"AWS/Lambda", "Errors", "FunctionName"},
+		{"AWS/Lambda", "Throttles", "FunctionName"},
+	},
+	"AWS::Serverless::Function": {
+		{"AWS/Lambda", "Errors", "FunctionName"},
+		{"AWS/Lambda", "Throttles", "FunctionName"},
+	},
+	"AWS::ApiGateway::RestApi": {
+		{"AWS/ApiGateway", "5XXError", "ApiName"},
+	},
+	"AWS::DynamoDB::Table": {
+		{"AWS/DynamoDB", "ReadThrottleEvents", "TableName"},
+		{"AWS/DynamoDB", "WriteThrottleEvents", "TableName"},
+	},
+}
+
+// IncidentCause is a resource on the failing path with the signals that
+// make it a suspect.
+type IncidentCause struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Score int    `json:"score"`
+	// Errors are the sums of the resource's error metrics over the window
+	Errors   map[string]float64 `json:"errors,omitempty"`
+	Alarms   []aws.Alarm        `json:"alarms,omitempty"`
+	Changes  []aws.TrailEvent   `json:"changes,omitempty"`
+	Evidence []string           `json:"evidence"`
+}
+
+// Incident is the triage of a described failure: the resources on its path
+// and those among them with alarms, errors or recent changes, most likely
+// cause first.
+type Incident struct {
+	Description string          `json:"description"`
+	Since       time.Time       `json:"since"`
+	Path        []string        `json:"path"`
+	Causes      []IncidentCause `json:"causes"`
+	Warnings    []ScanWarning   `json:"warnings,omitempty"`
+}
+
+// IncidentSignals are what CloudWatch and CloudTrail report for a window.
+type IncidentSignals struct {
+	Alarms  []aws.Alarm
+	Changes []aws.TrailEvent
+	// Errors holds metric sums by resource, then metric name
+	Errors map[string]map[string]float64
+}
+
+// IncidentPath returns the resources an incident description is about: the
+// resources it names, everything they depend on and the resources directly
+// in front of them, such as the API that routes to a function.
+func IncidentPath(infraState map[string]interface{}, description string) []string {
+	seeds := MentionedResources(infraState, description)
+	if len(seeds) == 0 {
+		seeds = describedResources(infraState, description)
+	}
+	if len(seeds) == 0 {
+		return nil
+	}
+
+	dependencies := make(map[string][]string)
+	dependents := make(map[string][]string)
+	for _, edge := range Edges(infraState) {
+		dependencies[edge.From] = append(dependencies[edge.From], edge.To)
+		dependents[edge.To] = append(dependents[edge.To], edge.From)
+	}
+
+	onPath := make(map[string]bool)
+	queue := append([]string(nil), seeds...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if onPath[id] {
+			continue
+		}
+		onPath[id] = true
+		queue = append(queue, dependencies[id]...)
+	}
+	for _, seed := range seeds {
+		for _, dependent := range dependents[seed] {
+			onPath[dependent] = true
+		}
+	}
+
+	path := make([]string, 0, len(onPath))
+	for id := range onPath {
+		path = append(path, id)
+	}
+	sort.Strings(path)
+	return path
+}
+
+// describedResources matches the words of a description against logical IDs
+// and names, for descriptions like "checkout is failing" that name a
+// feature rather than a resource.
+func describedResources(infraState map[string]interface{}, description string) []string {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) >= minMentionLength && !incidentWords[word] {
+			words = append(words, word)
+		}
+	}
+
+	var ids []string
+	for id, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		label := strings.ToLower(id + " " + resourceName(props))
+		for _, word := range words {
+			if strings.Contains(label, word) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Triage gathers the alarms, error metrics and CloudTrail changes since the
+// given time for the resources on an incident's path and ranks them. Without
+// permission for CloudWatch or CloudTrail the triage continues on the other
+// and records a warning.
+func Triage(ctx context.Context, client *aws.Client, infraState map[string]interface{}, description string, since, now time.Time) (*Incident, error) {
+	path := IncidentPath(infraState, description)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("no resource in the cache matches %q; name a function, API or table on the failing path", description)
+	}
+
+	var signals IncidentSignals
+	var warnings []ScanWarning
+	warn := func(service string, err error, grant ...string) error {
+		if !aws.IsAccessDenied(err) {
+			return err
+		}
+		warnings = append(warnings, ScanWarning{Service: service, Error: err.Error(), Grant: grant})
+		return nil
+	}
+
+	alarms, err := client.Alarms(ctx)
+	if err = warn("cloudwatch", err, "cloudwatch:DescribeAlarms"); err != nil {
+		return nil, err
+	}
+	signals.Alarms = alarms
+
+	queries, owners := incidentQueries(infraState, path)
+	if len(queries) > 0 {
+		sums, err := client.MetricSums(ctx, queries, since, now)
+		if err = warn("cloudwatch", err, "cloudwatch:GetMetricData"); err != nil {
+			return nil, err
+		}
+		signals.Errors = make(map[string]map[string]float64)
+		for queryID, sum := range sums {
+			owner := owners[queryID]
+			if signals.Errors[owner.resource] == nil {
+				signals.Errors[owner.resource] = make(map[string]float64)
+			}
+			signals.Errors[owner.resource][owner.metric] = sum
+		}
+	}
+
+	changes, err := client.RecentChanges(ctx, since)
+	if err = warn("cloudtrail", err, "cloudtrail:LookupEvents"); err != nil {
+		return nil, err
+	}
+	signals.Changes = changes
+
+	incident := RankCauses(infraState, path, signals, now)
+	incident.Description = description
+	incident.Since = since
+	incident.Warnings = warnings
+	return incident, nil
+}
+
+type queryOwner struct {
+	resource string
+	metric   string
+}
+
+// incidentQueries returns the error metrics of the resources on path whose
+// name is known, with the resource and metric each query ID belongs to.
+func incidentQueries(infraState map[string]interface{}, path []string) ([]aws.MetricQuery, map[string]queryOwner) {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	var queries []aws.MetricQuery
+	owners := make(map[string]queryOwner)
+	for _, id := range path {
+		resource, _ := resources[id].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+		// templates often leave the physical name to CloudFormation
+		name, _ := props[physicalNameKey(resourceType)].(string)
+		if name == "" {
+			continue
+		}
+		for _, metric := range errorMetrics[resourceType] {
+			queryID := fmt.Sprintf("m%d", len(queries))
+			queries = append(queries, aws.MetricQuery{
+				ID:         queryID,
+				Namespace:  metric.namespace,
+				Metric:     metric.metric,
+				Dimensions: map[string]string{metric.dimension: name},
+			})
+			owners[queryID] = queryOwner{resource: id, metric: metric.metric}
+		}
+	}
+	return queries, owners
+}
+
+// physicalNameKey is the property holding the name CloudWatch dimensions
+// use for a resource type.
+func physicalNameKey(resourceType string) string {
+	switch resourceType {
+	case "AWS::Lambda::Function", "AWS::Serverless::Function":
+		return "FunctionName"
+	case "AWS::ApiGateway::RestApi":
+		return "Name"
+	case "AWS::DynamoDB::Table":
+		return "TableName"
+	}
+	return ""
+}
+
+// RankCauses scores each resource on path by its signals: alarms firing,
+// errors counted and changes made during the window. Resources without any
+// signal are left out.
+func RankCauses(infraState map[string]interface{}, path []string, signals IncidentSignals, now time.Time) *Incident {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	incident := &Incident{Path: path, Causes: []IncidentCause{}}
+
+	for _, id := range path {
+		resource, _ := resources[id].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		props, _ := resource["Properties"].(map[string]interface{})
+		cause := IncidentCause{ID: id, Type: resourceType, Name: resourceName(props)}
+		names := resourceNames(id, props)
+
+		for _, alarm := range signals.Alarms {
+			if alarm.State != "ALARM" || !alarmWatches(alarm, names) {
+				continue
+			}
+			cause.Alarms = append(cause.Alarms, alarm)
+			cause.Score += 10
+			cause.Evidence = append(cause.Evidence, fmt.Sprintf("alarm %s went into ALARM %s: %s", alarm.Name, ago(alarm.UpdatedAt, now), alarm.Reason))
+		}
+
+		if errors := signals.Errors[id]; len(errors) > 0 {
+			metrics := make([]string, 0, len(errors))
+			for metric := range errors {
+				metrics = append(metrics, metric)
+			}
+			sort.Strings(metrics)
+			for _, metric := range metrics {
+				if errors[metric] == 0 {
+					continue
+				}
+				cause.Score += 5
+				if errors[metric] >= 100 {
+					cause.Score += 3
+				}
+				cause.Evidence = append(cause.Evidence, fmt.Sprintf("%.0f %s in the window", errors[metric], metric))
+			}
+			cause.Errors = errors
+		}
+
+		for _, event := range signals.Changes {
+			if !touches(event, names) {
+				continue
+			}
+			if len(cause.Changes) == 0 {
+				cause.Score += 8
+			} else if len(cause.Changes) < 3 {
+				cause.Score += 2
+			}
+			cause.Changes = append(cause.Changes, event)
+			by := ""
+			if event.User != "" {
+				by = " by " + event.User
+			}
+			cause.Evidence = append(cause.Evidence, fmt.Sprintf("%s%s %s", event.Name, by, ago(event.Time, now)))
+		}
+
+		if cause.Score > 0 {
+			incident.Causes = append(incident.Causes, cause)
+		}
+	}
+
+	sort.SliceStable(incident.Causes, func(i, j int) bool { return incident.Causes[i].Score > incident.Causes[j].Score })
+	return incident
+}
+
+// resourceNames returns the identifiers a resource appears under in
+// CloudWatch and CloudTrail: its logical ID, name properties and ARN.
+func resourceNames(id string, props map[string]interface{}) []string {
+	names := []string{id}
+	for key, value := range props {
+		if s, ok := value.(string); ok && len(s) >= minMentionLength && (key == "Arn" || strings.HasSuffix(key, "Name")) {
+			names = append(names, s)
+		}
+	}
+	return names
+}
+
+// alarmWatches reports whether an alarm's dimensions name the resource.
+// CloudFormation embeds the logical ID in generated names, so a dimension
+// containing it matches too.
+func alarmWatches(alarm aws.Alarm, names []string) bool {
+	for _, value := range alarm.Dimensions {
+		if matchesName(value, names) {
+			return true
+		}
+	}
+	return false
+}
+
+func touches(event aws.TrailEvent, names []string) bool {
+	for _, resource := range event.Resources {
+		if matchesName(resource, names) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesName(value string, names []string) bool {
+	for i, name := range names {
+		if strings.EqualFold(value, name) || strings.HasSuffix(value, ":"+name) {
+			return true
+		}
+		if i == 0 && len(name) >= minMentionLength && strings.Contains(value, "-"+name+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func ago(t, now time.Time) string {
+	if t.IsZero() {
+		return "an unknown time"
+	}
+	elapsed := now.Sub(t).Round(time.Minute)
+	if elapsed < time.Minute {
+		return "just now"
+	}
+	return strings.TrimSuffix(elapsed.String(), "0s") + " ago"
+}
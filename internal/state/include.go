@@ -0,0 +1,183 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Modes of a data category in ContextIncludes
+const (
+	IncludeFull       = "true"
+	IncludeNone       = "false"
+	IncludeSummarized = "summarized"
+)
+
+// ContextIncludes says which categories of resource properties are
+// serialized into prompts, for teams that would rather not send some of
+// them at all. It is read from the "context.include" config section:
+//
+//	context:
+//	  include:
+//	    env_vars: false             # or summarized: names without values
+//	    iam_policies: summarized    # one-line summaries, even with --raw-context
+//	    user_data: false
+//	    tags: true
+//
+// An empty mode is IncludeFull.
+type ContextIncludes struct {
+	// EnvVars covers Lambda, ECS and CodeBuild environment variables;
+	// summarized keeps their names only
+	EnvVars string
+	// IAMPolicies covers policy documents; summarized collapses them to
+	// one-line summaries even in a raw context
+	IAMPolicies string
+	// UserData covers EC2 instance and launch template user data
+	UserData string
+	// Tags covers resource tags
+	Tags string
+}
+
+// Validate rejects modes a category does not support.
+func (c ContextIncludes) Validate() error {
+	categories := []struct {
+		key, mode  string
+		summarized bool
+	}{
+		{"env_vars", c.EnvVars, true},
+		{"iam_policies", c.IAMPolicies, true},
+		{"user_data", c.UserData, false},
+		{"tags", c.Tags, false},
+	}
+	for _, category := range categories {
+		switch category.mode {
+		case "", IncludeFull, IncludeNone:
+		case IncludeSummarized:
+			if !category.summarized {
+				return fmt.Errorf("context.include.%s cannot be %q: use true or false", category.key, category.mode)
+			}
+		default:
+			return fmt.Errorf("context.include.%s: unknown mode %q", category.key, category.mode)
+		}
+	}
+	return nil
+}
+
+// full reports whether every category is sent as is.
+func (c ContextIncludes) full() bool {
+	for _, mode := range []string{c.EnvVars, c.IAMPolicies, c.UserData, c.Tags} {
+		if mode != "" && mode != IncludeFull {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyIncludes returns a copy of the state with the resource properties
+// of each category dropped or summarized as includes says. The input is
+// not modified.
+func ApplyIncludes(infraState map[string]interface{}, includes ContextIncludes) map[string]interface{} {
+	if includes.full() {
+		return infraState
+	}
+	out := make(map[string]interface{}, len(infraState))
+	for key, value := range infraState {
+		resources, ok := value.(map[string]interface{})
+		if key != "Resources" || !ok {
+			out[key] = value
+			continue
+		}
+		filtered := make(map[string]interface{}, len(resources))
+		for logicalID, raw := range resources {
+			resource, ok := raw.(map[string]interface{})
+			if !ok {
+				filtered[logicalID] = raw
+				continue
+			}
+			copied := make(map[string]interface{}, len(resource))
+			for field, child := range resource {
+				switch {
+				case field == "Properties":
+					copied[field] = includeValue(child, includes)
+				case field == "Tags" && includes.Tags == IncludeNone:
+				default:
+					copied[field] = child
+				}
+			}
+			filtered[logicalID] = copied
+		}
+		out[key] = filtered
+	}
+	return out
+}
+
+// includeValue walks a property tree dropping or summarizing the
+// categories includes leaves out.
+func includeValue(value interface{}, includes ContextIncludes) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			switch {
+			case isEnvVars(key, child) && includes.EnvVars == IncludeNone:
+			case isEnvVars(key, child) && includes.EnvVars == IncludeSummarized:
+				out[key] = envVarNames(child)
+			case policyKeys[key] && includes.IAMPolicies == IncludeNone:
+			case policyKeys[key] && includes.IAMPolicies == IncludeSummarized:
+				out[key] = SummarizePolicy(child)
+			case key == "UserData" && includes.UserData == IncludeNone:
+			case key == "Tags" && includes.Tags == IncludeNone:
+			default:
+				out[key] = includeValue(child, includes)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = includeValue(child, includes)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// isEnvVars reports whether a property holds environment variables: the
+// Variables map of a Lambda function, as templates and Terraform name it,
+// CodeBuild's EnvironmentVariables or the Name/Value list of an ECS
+// container.
+func isEnvVars(key string, value interface{}) bool {
+	switch key {
+	case "Variables", "variables", "EnvironmentVariables":
+		return true
+	case "Environment":
+		list, ok := value.([]interface{})
+		if !ok || len(list) == 0 {
+			return false
+		}
+		entry, ok := list[0].(map[string]interface{})
+		return ok && entry["Name"] != nil
+	}
+	return false
+}
+
+// envVarNames keeps the names of environment variables, sorted.
+func envVarNames(value interface{}) []string {
+	var names []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name := range v {
+			names = append(names, name)
+		}
+	case []interface{}:
+		for _, raw := range v {
+			if entry, ok := raw.(map[string]interface{}); ok {
+				if name, ok := entry["Name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
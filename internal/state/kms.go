@@ -0,0 +1,201 @@
+package state
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+func scanKMS(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	aliases := make(map[string][]string) // key ID -> alias names
+	aliasPages := kms.NewListAliasesPaginator(client.KMS, &kms.ListAliasesInput{})
+	for aliasPages.HasMorePages() {
+		page, err := aliasPages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, alias := range page.Aliases {
+			if alias.TargetKeyId != nil {
+				aliases[*alias.TargetKeyId] = append(aliases[*alias.TargetKeyId], awssdk.ToString(alias.AliasName))
+			}
+		}
+	}
+
+	// Key policies can deny the scanning credentials a single key; skip
+	// those keys rather than the whole service
+	var skipped []string
+	var denied error
+	keyPages := kms.NewListKeysPaginator(client.KMS, &kms.ListKeysInput{})
+	for keyPages.HasMorePages() {
+		page, err := keyPages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, key := range page.Keys {
+			resource, err := kmsKeyResource(ctx, client, key.KeyId, aliases)
+			if aws.IsAccessDenied(err) {
+				skipped = append(skipped, awssdk.ToString(key.KeyId))
+				denied = err
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			resources["kms/"+awssdk.ToString(key.KeyId)] = resource
+		}
+	}
+	if len(skipped) > 0 {
+		return &partialScanError{kind: "keys", skipped: skipped, err: denied}
+	}
+	return nil
+}
+
+// kmsKeyResource describes a key, with its aliases from the key ID -> alias
+// names map and the grants on customer managed keys.
+func kmsKeyResource(ctx context.Context, client *aws.Client, keyID *string, aliases map[string][]string) (map[string]interface{}, error) {
+	desc, err := client.KMS.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: keyID})
+	if err != nil {
+		return nil, err
+	}
+	meta := desc.KeyMetadata
+
+	props := map[string]interface{}{
+		"KeyId":       awssdk.ToString(meta.KeyId),
+		"Arn":         awssdk.ToString(meta.Arn),
+		"Description": awssdk.ToString(meta.Description),
+		"KeyState":    string(meta.KeyState),
+		"KeyManager":  string(meta.KeyManager),
+		"KeyUsage":    string(meta.KeyUsage),
+	}
+	if names := aliases[awssdk.ToString(meta.KeyId)]; len(names) > 0 {
+		sort.Strings(names)
+		props["Aliases"] = names
+	}
+	if meta.DeletionDate != nil {
+		props["DeletionDate"] = meta.DeletionDate.Format("2006-01-02")
+	}
+
+	// AWS-managed keys only carry service grants; list grants on keys
+	// the account controls.
+	if meta.KeyManager == kmstypes.KeyManagerTypeCustomer {
+		grants, err := listGrants(ctx, client, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if len(grants) > 0 {
+			props["Grants"] = grants
+		}
+	}
+
+	return map[string]interface{}{
+		"Type":       "AWS::KMS::Key",
+		"Properties": props,
+	}, nil
+}
+
+func listGrants(ctx context.Context, client *aws.Client, keyID *string) ([]map[string]interface{}, error) {
+	var grants []map[string]interface{}
+	pages := kms.NewListGrantsPaginator(client.KMS, &kms.ListGrantsInput{KeyId: keyID})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, grant := range page.Grants {
+			operations := make([]string, len(grant.Operations))
+			for i, op := range grant.Operations {
+				operations[i] = string(op)
+			}
+			grants = append(grants, map[string]interface{}{
+				"Name":             awssdk.ToString(grant.Name),
+				"GranteePrincipal": awssdk.ToString(grant.GranteePrincipal),
+				"Operations":       operations,
+			})
+		}
+	}
+	return grants, nil
+}
+
+// LinkKMSKeys records on every AWS::KMS::Key resource which other resources
+// reference it, under Properties.ReferencedBy. A reference is a Ref or
+// Fn::GetAtt to the key's logical ID, or any property value containing the
+// key ID, ARN or one of its aliases.
+func LinkKMSKeys(resources map[string]interface{}) {
+	type keyInfo struct {
+		props       map[string]interface{}
+		identifiers []string
+	}
+	keys := make(map[string]keyInfo)
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource["Type"] != "AWS::KMS::Key" {
+			continue
+		}
+		props, _ := resource["Properties"].(map[string]interface{})
+		if props == nil {
+			props = make(map[string]interface{})
+			resource["Properties"] = props
+		}
+		var identifiers []string
+		for _, field := range []string{"KeyId", "Arn"} {
+			if v, ok := props[field].(string); ok && v != "" {
+				identifiers = append(identifiers, v)
+			}
+		}
+		identifiers = append(identifiers, stringList(props["Aliases"])...)
+		keys[logicalID] = keyInfo{props: props, identifiers: identifiers}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource["Type"] == "AWS::KMS::Key" {
+			continue
+		}
+		for keyID, key := range keys {
+			if referencesKey(resource["Properties"], keyID, key.identifiers) {
+				refs, _ := key.props["ReferencedBy"].([]string)
+				key.props["ReferencedBy"] = append(refs, logicalID)
+			}
+		}
+	}
+	for _, key := range keys {
+		if refs, ok := key.props["ReferencedBy"].([]string); ok {
+			sort.Strings(refs)
+		}
+	}
+}
+
+func referencesKey(value interface{}, logicalID string, identifiers []string) bool {
+	switch v := value.(type) {
+	case string:
+		for _, id := range identifiers {
+			if strings.Contains(v, id) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		if refName(v) == logicalID {
+			return true
+		}
+		for _, child := range v {
+			if referencesKey(child, logicalID, identifiers) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if referencesKey(child, logicalID, identifiers) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,536 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	ekstypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// scanTimesKey holds when each service was last scanned, by service.
+const scanTimesKey = "ScanTimes"
+
+// ScanWarning records a service that could not be scanned because the
+// credentials lack permission, together with the actions that would fix it.
+type ScanWarning struct {
+	Service string   `json:"service"`
+	Error   string   `json:"error"`
+	Grant   []string `json:"grant"`
+}
+
+// partialScanError is returned by a scanner that listed its service but was
+// denied some of the resources, which it skipped. What it did scan is kept.
+type partialScanError struct {
+	kind    string
+	skipped []string
+	err     error
+}
+
+func (e *partialScanError) Error() string {
+	return fmt.Sprintf("skipped %d %s (%s): %v", len(e.skipped), e.kind, strings.Join(e.skipped, ", "), e.err)
+}
+
+func (e *partialScanError) Unwrap() error { return e.err }
+
+// LiveProvider scans a live AWS account through the SDK. The resulting state
+// uses the same shape as a CloudFormation template ("Resources" keyed by a
+// logical ID, each with "Type" and "Properties") so the rest of the tool can
+// treat IaC and live scans identically.
+type LiveProvider struct {
+	Client *aws.Client
+
+	// Services limits Scan to these services and Exclude skips services,
+	// by the names ResourceService returns
+	Services []string
+	Exclude  []string
+}
+
+// serviceScanner lists the resources of a single AWS service.
+type serviceScanner struct {
+	service string
+	actions []string
+	scan    func(ctx context.Context, client *aws.Client, resources map[string]interface{}) error
+}
+
+// liveScanners run in order; tagging comes first so the service scanners
+// replace its shallow entries with detailed ones.
+var liveScanners = []serviceScanner{
+	{service: "tagging", actions: []string{"tag:GetResources"}, scan: scanTagged},
+	{service: "lambda", actions: []string{"lambda:ListFunctions", "lambda:ListEventSourceMappings"}, scan: scanLambda},
+	{service: "apigateway", actions: []string{"apigateway:GET"}, scan: scanAPIGateway},
+	{service: "s3", actions: []string{"s3:ListAllMyBuckets", "s3:GetEncryptionConfiguration", "s3:GetReplicationConfiguration"}, scan: scanS3},
+	{service: "kms", actions: []string{"kms:ListKeys", "kms:DescribeKey", "kms:ListAliases", "kms:ListGrants"}, scan: scanKMS},
+	{service: "rds", actions: []string{"rds:DescribeDBInstances", "rds:DescribeDBClusters"}, scan: scanRDS},
+	{service: "eks", actions: []string{"eks:ListClusters", "eks:DescribeCluster"}, scan: scanEKS},
+	{service: "dynamodb", actions: []string{"dynamodb:ListTables", "dynamodb:DescribeTable", "dynamodb:DescribeContinuousBackups"}, scan: scanDynamoDB},
+	{service: "backup", actions: []string{"backup:ListBackupPlans", "backup:GetBackupPlan", "backup:ListBackupSelections", "backup:GetBackupSelection"}, scan: scanBackup},
+	{service: "cloudwatch", actions: []string{"cloudwatch:DescribeAlarms", "cloudwatch:ListDashboards", "cloudwatch:GetDashboard"}, scan: scanCloudWatch},
+	{service: "kinesis", actions: []string{"kinesis:ListStreams", "kinesis:DescribeStreamSummary", "kinesis:ListStreamConsumers"}, scan: scanKinesis},
+	{service: "msk", actions: []string{"kafka:ListClustersV2"}, scan: scanMSK},
+	{service: "elasticache", actions: []string{"elasticache:DescribeReplicationGroups", "elasticache:DescribeCacheClusters", "elasticache:DescribeServerlessCaches"}, scan: scanElastiCache},
+}
+
+// serviceKeywords are words in a question that point at a scanned service.
+var serviceKeywords = map[string][]string{
+	"lambda":      {"lambda", "function"},
+	"apigateway":  {"api", "apigateway", "gateway", "endpoint", "route"},
+	"s3":          {"s3", "bucket"},
+	"kms":         {"kms", "key", "encryption", "encrypted"},
+	"rds":         {"rds", "database", "db", "aurora", "postgres", "postgresql", "mysql"},
+	"eks":         {"eks", "kubernetes", "k8s", "cluster"},
+	"dynamodb":    {"dynamodb", "dynamo", "table"},
+	"backup":      {"backup", "restore", "snapshot", "recovery", "dr", "disaster"},
+	"cloudwatch":  {"cloudwatch", "alarm", "dashboard", "monitoring", "monitored"},
+	"kinesis":     {"kinesis", "stream", "shard", "consumer", "consume", "consumes"},
+	"msk":         {"msk", "kafka", "broker"},
+	"elasticache": {"elasticache", "redis", "memcached", "valkey", "cache"},
+}
+
+// MentionedServices returns the live-scanned services a question is about,
+// in scan order.
+func MentionedServices(question string) []string {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+		words[strings.TrimSuffix(word, "s")] = true
+	}
+
+	var services []string
+	for _, scanner := range liveScanners {
+		for _, keyword := range serviceKeywords[scanner.service] {
+			if words[keyword] {
+				services = append(services, scanner.service)
+				break
+			}
+		}
+	}
+	return services
+}
+
+// Scan lists resources from every supported service. Permission errors on a
+// service are recorded under "Warnings" rather than failing the whole scan,
+// so partially-permitted users still get a usable knowledge base.
+func (p *LiveProvider) Scan(ctx context.Context, path string) (map[string]interface{}, error) {
+	scanners := selectScanners(p.Services, p.Exclude)
+	if len(scanners) == 0 {
+		return nil, fmt.Errorf("no live scanner left for the selected services")
+	}
+	infraState, err := p.scan(ctx, scanners)
+	if err != nil {
+		return nil, err
+	}
+	FilterServices(infraState, p.Services, p.Exclude)
+	return infraState, nil
+}
+
+// ScanServices is Scan limited to the named services.
+func (p *LiveProvider) ScanServices(ctx context.Context, services []string) (map[string]interface{}, error) {
+	var scanners []serviceScanner
+	for _, scanner := range liveScanners {
+		if slices.Contains(services, scanner.service) {
+			scanners = append(scanners, scanner)
+		}
+	}
+	if len(scanners) == 0 {
+		return nil, fmt.Errorf("no live scanner for %s", strings.Join(services, ", "))
+	}
+	return p.scan(ctx, scanners)
+}
+
+func (p *LiveProvider) scan(ctx context.Context, scanners []serviceScanner) (map[string]interface{}, error) {
+	resources := make(map[string]interface{})
+	scanTimes := make(map[string]interface{})
+	var warnings []ScanWarning
+	denied := 0
+
+	for _, scanner := range scanners {
+		if err := scanner.scan(ctx, p.Client, resources); err != nil {
+			var partial *partialScanError
+			if !errors.As(err, &partial) && !aws.IsAccessDenied(err) {
+				return nil, fmt.Errorf("failed to scan %s: %w", scanner.service, err)
+			}
+			warnings = append(warnings, ScanWarning{
+				Service: scanner.service,
+				Error:   err.Error(),
+				Grant:   scanner.actions,
+			})
+			if partial == nil {
+				denied++
+				continue
+			}
+		}
+		scanTimes[scanner.service] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	if denied == len(scanners) {
+		return nil, fmt.Errorf("access denied for every service; run 'cloudai whoami' to check your permissions")
+	}
+
+	LinkKMSKeys(resources)
+
+	infraState := map[string]interface{}{
+		"Source":     "live",
+		"Resources":  resources,
+		scanTimesKey: scanTimes,
+	}
+	if len(warnings) > 0 {
+		sort.Slice(warnings, func(i, j int) bool { return warnings[i].Service < warnings[j].Service })
+		infraState["Warnings"] = warnings
+	}
+	return infraState, nil
+}
+
+func scanLambda(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := lambda.NewListFunctionsPaginator(client.Lambda, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, fn := range page.Functions {
+			resources["lambda/"+awssdk.ToString(fn.FunctionName)] = lambdaResource(fn)
+		}
+	}
+	scanEventSourceMappings(ctx, client, resources)
+	return nil
+}
+
+func lambdaResource(fn lambdatypes.FunctionConfiguration) map[string]interface{} {
+	props := map[string]interface{}{
+		"FunctionName": awssdk.ToString(fn.FunctionName),
+		"Arn":          awssdk.ToString(fn.FunctionArn),
+		"Runtime":      string(fn.Runtime),
+		"Handler":      awssdk.ToString(fn.Handler),
+		"Role":         awssdk.ToString(fn.Role),
+		"MemorySize":   awssdk.ToInt32(fn.MemorySize),
+		"Timeout":      awssdk.ToInt32(fn.Timeout),
+	}
+	if fn.Environment != nil && len(fn.Environment.Variables) > 0 {
+		props["Environment"] = map[string]interface{}{"Variables": fn.Environment.Variables}
+	}
+	if fn.KMSKeyArn != nil {
+		props["KmsKeyArn"] = awssdk.ToString(fn.KMSKeyArn)
+	}
+	return map[string]interface{}{
+		"Type":       "AWS::Lambda::Function",
+		"Properties": props,
+	}
+}
+
+func scanAPIGateway(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	apis := apigateway.NewGetRestApisPaginator(client.APIGateway, &apigateway.GetRestApisInput{})
+	for apis.HasMorePages() {
+		page, err := apis.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, api := range page.Items {
+			routes, err := apiRoutes(ctx, client, api.Id)
+			if err != nil {
+				return err
+			}
+			resources["apigateway/"+awssdk.ToString(api.Id)] = apiResource(api.Name, api.Id, api.Description, routes)
+		}
+	}
+	return nil
+}
+
+// apiRoutes lists the methods of a REST API. Embedding methods returns
+// integrations in the same call, which is what links routes to their Lambda
+// functions.
+func apiRoutes(ctx context.Context, client *aws.Client, apiID *string) ([]map[string]interface{}, error) {
+	var routes []map[string]interface{}
+	pages := apigateway.NewGetResourcesPaginator(client.APIGateway, &apigateway.GetResourcesInput{
+		RestApiId: apiID,
+		Embed:     []string{"methods"},
+	})
+	for pages.HasMorePages() {
+		resPage, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range resPage.Items {
+			for httpMethod, method := range res.ResourceMethods {
+				route := map[string]interface{}{
+					"Path":   awssdk.ToString(res.Path),
+					"Method": httpMethod,
+				}
+				if method.MethodIntegration != nil {
+					route["IntegrationType"] = string(method.MethodIntegration.Type)
+					route["IntegrationUri"] = awssdk.ToString(method.MethodIntegration.Uri)
+				}
+				routes = append(routes, route)
+			}
+		}
+	}
+	return routes, nil
+}
+
+func apiResource(name, id, description *string, routes []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"Type": "AWS::ApiGateway::RestApi",
+		"Properties": map[string]interface{}{
+			"Name":        awssdk.ToString(name),
+			"Id":          awssdk.ToString(id),
+			"Description": awssdk.ToString(description),
+			"Routes":      routes,
+		},
+	}
+}
+
+func scanS3(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := s3.NewListBucketsPaginator(client.S3, &s3.ListBucketsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, bucket := range page.Buckets {
+			resource := bucketResource(bucket.Name, bucket.BucketRegion)
+			if key := bucketKMSKey(ctx, client, bucket.Name, bucket.BucketRegion); key != "" {
+				props := resource["Properties"].(map[string]interface{})
+				props["BucketEncryption"] = map[string]interface{}{
+					"ServerSideEncryptionConfiguration": []interface{}{map[string]interface{}{
+						"ServerSideEncryptionByDefault": map[string]interface{}{"SSEAlgorithm": "aws:kms", "KMSMasterKeyID": key},
+					}},
+				}
+			}
+			if rules := bucketReplication(ctx, client, bucket.Name, bucket.BucketRegion); len(rules) > 0 {
+				props := resource["Properties"].(map[string]interface{})
+				props["ReplicationConfiguration"] = map[string]interface{}{"Rules": rules}
+			}
+			resources["s3/"+awssdk.ToString(bucket.Name)] = resource
+		}
+	}
+	return nil
+}
+
+func bucketResource(name, region *string) map[string]interface{} {
+	props := map[string]interface{}{
+		"BucketName": awssdk.ToString(name),
+	}
+	if region != nil {
+		props["Region"] = awssdk.ToString(region)
+	}
+	return map[string]interface{}{
+		"Type":       "AWS::S3::Bucket",
+		"Properties": props,
+	}
+}
+
+func scanRDS(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	clusters := rds.NewDescribeDBClustersPaginator(client.RDS, &rds.DescribeDBClustersInput{})
+	for clusters.HasMorePages() {
+		page, err := clusters.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range page.DBClusters {
+			resources["rds/"+awssdk.ToString(cluster.DBClusterIdentifier)] = rdsClusterResource(cluster)
+		}
+	}
+
+	instances := rds.NewDescribeDBInstancesPaginator(client.RDS, &rds.DescribeDBInstancesInput{})
+	for instances.HasMorePages() {
+		page, err := instances.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, db := range page.DBInstances {
+			// Cluster members share the cluster's engine version
+			if db.DBClusterIdentifier != nil {
+				continue
+			}
+			resources["rds/"+awssdk.ToString(db.DBInstanceIdentifier)] = rdsInstanceResource(db)
+		}
+	}
+	return nil
+}
+
+func rdsClusterResource(cluster rdstypes.DBCluster) map[string]interface{} {
+	return map[string]interface{}{
+		"Type": "AWS::RDS::DBCluster",
+		"Properties": map[string]interface{}{
+			"DBClusterIdentifier": awssdk.ToString(cluster.DBClusterIdentifier),
+			"Arn":                 awssdk.ToString(cluster.DBClusterArn),
+			"Engine":              awssdk.ToString(cluster.Engine),
+			"EngineVersion":       awssdk.ToString(cluster.EngineVersion),
+			"KmsKeyId":            awssdk.ToString(cluster.KmsKeyId),
+			// read replicas and global databases are named by ARN, which
+			// carries their region
+			"StorageEncrypted":        awssdk.ToBool(cluster.StorageEncrypted),
+			"BackupRetentionPeriod":   awssdk.ToInt32(cluster.BackupRetentionPeriod),
+			"ReadReplicaIdentifiers":  cluster.ReadReplicaIdentifiers,
+			"GlobalClusterIdentifier": awssdk.ToString(cluster.GlobalClusterIdentifier),
+		},
+	}
+}
+
+func rdsInstanceResource(db rdstypes.DBInstance) map[string]interface{} {
+	return map[string]interface{}{
+		"Type": "AWS::RDS::DBInstance",
+		"Properties": map[string]interface{}{
+			"DBInstanceIdentifier": awssdk.ToString(db.DBInstanceIdentifier),
+			"Arn":                  awssdk.ToString(db.DBInstanceArn),
+			"DBInstanceClass":      awssdk.ToString(db.DBInstanceClass),
+			"Engine":               awssdk.ToString(db.Engine),
+			"EngineVersion":        awssdk.ToString(db.EngineVersion),
+			"KmsKeyId":             awssdk.ToString(db.KmsKeyId),
+			// cross-region read replicas are named by ARN
+			"StorageEncrypted":                 awssdk.ToBool(db.StorageEncrypted),
+			"BackupRetentionPeriod":            awssdk.ToInt32(db.BackupRetentionPeriod),
+			"ReadReplicaDBInstanceIdentifiers": db.ReadReplicaDBInstanceIdentifiers,
+		},
+	}
+}
+
+func scanEKS(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := eks.NewListClustersPaginator(client.EKS, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, name := range page.Clusters {
+			out, err := client.EKS.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: awssdk.String(name)})
+			if err != nil {
+				return err
+			}
+			resources["eks/"+name] = eksResource(name, out.Cluster)
+		}
+	}
+	return nil
+}
+
+func eksResource(name string, cluster *ekstypes.Cluster) map[string]interface{} {
+	return map[string]interface{}{
+		"Type": "AWS::EKS::Cluster",
+		"Properties": map[string]interface{}{
+			"Name":    name,
+			"Arn":     awssdk.ToString(cluster.Arn),
+			"Version": awssdk.ToString(cluster.Version),
+			"Status":  string(cluster.Status),
+		},
+	}
+}
+
+func scanCloudWatch(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	alarms, err := client.Alarms(ctx)
+	if err != nil {
+		return err
+	}
+	for _, alarm := range alarms {
+		resources["cloudwatch/"+alarm.Name] = alarmResource(alarm)
+	}
+
+	paginator := cloudwatch.NewListDashboardsPaginator(client.CloudWatch, &cloudwatch.ListDashboardsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.DashboardEntries {
+			// the body is what names the resources a dashboard shows
+			out, err := client.CloudWatch.GetDashboard(ctx, &cloudwatch.GetDashboardInput{DashboardName: entry.DashboardName})
+			if err != nil {
+				return err
+			}
+			resources["cloudwatch/"+awssdk.ToString(entry.DashboardName)] = map[string]interface{}{
+				"Type": "AWS::CloudWatch::Dashboard",
+				"Properties": map[string]interface{}{
+					"DashboardName": awssdk.ToString(entry.DashboardName),
+					"Arn":           awssdk.ToString(entry.DashboardArn),
+					"DashboardBody": awssdk.ToString(out.DashboardBody),
+				},
+			}
+		}
+	}
+	return nil
+}
+
+// alarmResource records an alarm's dimensions the way templates do, as a
+// list of Name and Value pairs.
+func alarmResource(alarm aws.Alarm) map[string]interface{} {
+	names := make([]string, 0, len(alarm.Dimensions))
+	for name := range alarm.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dimensions := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		dimensions = append(dimensions, map[string]interface{}{"Name": name, "Value": alarm.Dimensions[name]})
+	}
+	return map[string]interface{}{
+		"Type": "AWS::CloudWatch::Alarm",
+		"Properties": map[string]interface{}{
+			"AlarmName":  alarm.Name,
+			"Arn":        alarm.ARN,
+			"Namespace":  alarm.Namespace,
+			"MetricName": alarm.Metric,
+			"Dimensions": dimensions,
+			"StateValue": alarm.State,
+		},
+	}
+}
+
+// bucketReplication returns the replication rules of a bucket in the shape
+// of a template's ReplicationConfiguration, or nil for buckets without
+// replication and buckets whose configuration cannot be read.
+func bucketReplication(ctx context.Context, client *aws.Client, bucket, region *string) []interface{} {
+	out, err := client.S3.GetBucketReplication(ctx, &s3.GetBucketReplicationInput{Bucket: bucket}, func(o *s3.Options) {
+		if region != nil {
+			o.Region = *region
+		}
+	})
+	if err != nil || out.ReplicationConfiguration == nil {
+		return nil
+	}
+	var rules []interface{}
+	for _, rule := range out.ReplicationConfiguration.Rules {
+		if rule.Destination == nil {
+			continue
+		}
+		rules = append(rules, map[string]interface{}{
+			"Status":      string(rule.Status),
+			"Destination": map[string]interface{}{"Bucket": awssdk.ToString(rule.Destination.Bucket)},
+		})
+	}
+	return rules
+}
+
+// bucketKMSKey returns the KMS key a bucket encrypts with by default, or ""
+// for SSE-S3 buckets and buckets whose encryption cannot be read.
+func bucketKMSKey(ctx context.Context, client *aws.Client, bucket, region *string) string {
+	out, err := client.S3.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket}, func(o *s3.Options) {
+		if region != nil {
+			o.Region = *region
+		}
+	})
+	if err != nil || out.ServerSideEncryptionConfiguration == nil {
+		return ""
+	}
+	for _, rule := range out.ServerSideEncryptionConfiguration.Rules {
+		if rule.ApplyServerSideEncryptionByDefault != nil && rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID != nil {
+			return *rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID
+		}
+	}
+	return ""
+}
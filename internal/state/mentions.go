@@ -0,0 +1,42 @@
+package state
+
+import (
+	"sort"
+	"strings"
+)
+
+// minMentionLength keeps short names like "Api" from matching everything.
+const minMentionLength = 4
+
+// MentionedResources returns the logical IDs of resources a question refers
+// to, matching either the logical ID or any "*Name" property (FunctionName,
+// BucketName, ...) case-insensitively.
+func MentionedResources(infraState map[string]interface{}, question string) []string {
+	resources, ok := infraState["Resources"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	lowerQ := strings.ToLower(question)
+
+	var ids []string
+	for logicalID, raw := range resources {
+		candidates := []string{logicalID}
+		if resource, ok := raw.(map[string]interface{}); ok {
+			if props, ok := resource["Properties"].(map[string]interface{}); ok {
+				for key, value := range props {
+					if name, ok := value.(string); ok && strings.HasSuffix(key, "Name") {
+						candidates = append(candidates, name)
+					}
+				}
+			}
+		}
+		for _, name := range candidates {
+			if len(name) >= minMentionLength && strings.Contains(lowerQ, strings.ToLower(name)) {
+				ids = append(ids, logicalID)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
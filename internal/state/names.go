@@ -0,0 +1,110 @@
+package state
+
+import (
+	"strings"
+)
+
+// FriendlyNamesKey holds the logical ID → friendly name table the cache is
+// saved with, so answers and output name resources the way people do.
+const FriendlyNamesKey = "FriendlyNames"
+
+// nameProperties are the properties that name a resource of their type,
+// preferred over other names it has
+var nameProperties = []string{
+	"FunctionName", "BucketName", "TableName", "QueueName", "TopicName",
+	"RoleName", "StateMachineName", "ClusterName", "ServiceName",
+	"DBInstanceIdentifier", "DBClusterIdentifier", "LogGroupName", "AlarmName",
+	"RuleName", "ReplicationGroupId", "Name",
+}
+
+// FriendlyNames returns the friendly name of each resource that has one
+// other than its logical ID, from the table saved with the cache or, for a
+// cache saved without one, worked out from the resources.
+func FriendlyNames(infraState map[string]interface{}) map[string]string {
+	switch saved := infraState[FriendlyNamesKey].(type) {
+	case map[string]string:
+		return saved
+	case map[string]interface{}:
+		names := make(map[string]string, len(saved))
+		for id, name := range saved {
+			if s, ok := name.(string); ok {
+				names[id] = s
+			}
+		}
+		return names
+	}
+	return friendlyNames(infraState)
+}
+
+// friendlyNames names resources by, in order, their name property, their
+// Name tag, any other name or identifier property, and the leaf of their
+// CDK construct path. The leaf keeps its parent construct, as in
+// Handler/ServiceRole or orders/GET, since leaves alone repeat.
+func friendlyNames(infraState map[string]interface{}) map[string]string {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	names := make(map[string]string)
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource["Type"] == "AWS::CDK::Metadata" {
+			continue
+		}
+		props, _ := resource["Properties"].(map[string]interface{})
+		name := propertyName(props)
+		if name == "" {
+			name = nameTag(props["Tags"])
+		}
+		if name == "" {
+			name = resourceName(props)
+		}
+		if segments := constructSegments(ConstructPath(resource)); name == "" && len(segments) > 0 {
+			name = strings.Join(segments[max(len(segments)-2, 0):], "/")
+		}
+		if name != "" && name != logicalID {
+			names[logicalID] = name
+		}
+	}
+	return names
+}
+
+// propertyName returns the first of nameProperties set to a plain string;
+// names built with intrinsic functions are not known until deployment.
+func propertyName(props map[string]interface{}) string {
+	for _, key := range nameProperties {
+		if name, ok := props[key].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// nameTag returns the Name tag of a CloudFormation tag list or a Terraform
+// or live tag map.
+func nameTag(tags interface{}) string {
+	switch t := tags.(type) {
+	case []interface{}:
+		for _, raw := range t {
+			tag, _ := raw.(map[string]interface{})
+			if key, _ := tag["Key"].(string); key == "Name" {
+				name, _ := tag["Value"].(string)
+				return name
+			}
+		}
+	case map[string]interface{}:
+		name, _ := t["Name"].(string)
+		return name
+	}
+	return ""
+}
+
+// constructSegments splits a construct path below its stack, leaving out
+// the CDK's Resource and Default children.
+func constructSegments(path string) []string {
+	var segments []string
+	for i, id := range strings.Split(path, "/") {
+		if i == 0 || id == "" || id == "Resource" || id == "Default" {
+			continue
+		}
+		segments = append(segments, id)
+	}
+	return segments
+}
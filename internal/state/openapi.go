@@ -0,0 +1,244 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIOperation is an operation of an OpenAPI or Swagger definition, linked
+// to the Lambda function that implements it.
+type APIOperation struct {
+	OperationID string `json:"operationId,omitempty"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Summary     string `json:"summary,omitempty"`
+	API         string `json:"api,omitempty"`
+	// Lambda is a function name, or a logical ID in templates
+	Lambda string `json:"lambda,omitempty"`
+	// Source is the spec file, or the resource that defines the operation
+	Source string `json:"source"`
+}
+
+// openAPIMethods are the operation keys of an OpenAPI path item
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace", "x-amazon-apigateway-any-method"}
+
+// skippedScanDirs are not searched for spec files or projects
+var skippedScanDirs = map[string]bool{".git": true, ".cloudai": true, "node_modules": true, "vendor": true, "cdk.out": true, ".terraform": true}
+
+// ParseOpenAPI reads the operations of an OpenAPI 3 or Swagger 2 document
+// in JSON or YAML.
+func ParseOpenAPI(data []byte) ([]APIOperation, error) {
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("could not parse OpenAPI document: %w", err)
+	}
+	if spec["openapi"] == nil && spec["swagger"] == nil {
+		return nil, fmt.Errorf("not an OpenAPI document: no openapi or swagger version")
+	}
+	return specOperations(spec, ""), nil
+}
+
+// specOperations lists the operations of a parsed spec, sorted by path and
+// method.
+func specOperations(spec map[string]interface{}, source string) []APIOperation {
+	title := ""
+	if info, ok := spec["info"].(map[string]interface{}); ok {
+		title, _ = info["title"].(string)
+	}
+	paths, _ := spec["paths"].(map[string]interface{})
+
+	var ops []APIOperation
+	for path, raw := range paths {
+		item, _ := raw.(map[string]interface{})
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			operation := APIOperation{
+				Method: strings.ToUpper(strings.TrimPrefix(method, "x-amazon-apigateway-")),
+				Path:   path,
+				API:    title,
+				Source: source,
+			}
+			operation.OperationID, _ = op["operationId"].(string)
+			operation.Summary, _ = op["summary"].(string)
+			if integration, ok := op["x-amazon-apigateway-integration"].(map[string]interface{}); ok {
+				operation.Lambda = integrationLambda(integration["uri"])
+			}
+			ops = append(ops, operation)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// integrationLambda returns the function an integration URI invokes: its
+// name in a literal ARN, or the logical ID an intrinsic function refers to.
+func integrationLambda(uri interface{}) string {
+	if s, ok := uri.(string); ok {
+		return LambdaFromIntegrationURI(s)
+	}
+	for _, id := range referencedIDs(uri, nil) {
+		if !strings.HasPrefix(id, "AWS::") {
+			return id
+		}
+	}
+	return ""
+}
+
+// LambdaFromIntegrationURI extracts the function name from a Lambda proxy
+// integration URI, or returns "" for other integration types
+func LambdaFromIntegrationURI(uri string) string {
+	if !strings.Contains(uri, ":lambda:path") {
+		return ""
+	}
+	parts := strings.Split(uri, ":function:")
+	if len(parts) < 2 {
+		return ""
+	}
+	name := strings.Split(parts[1], "/")[0]
+	// an alias or version follows the name
+	name, _, _ = strings.Cut(name, ":")
+	return name
+}
+
+// APIOperations collects the operations of the OpenAPI and Swagger files
+// under dir and of the API definitions and named methods in the template's
+// resources.
+func APIOperations(dir string, resources map[string]interface{}) []APIOperation {
+	var ops []APIOperation
+	for _, path := range findSpecFiles(dir) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		parsed, err := ParseOpenAPI(data)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		for i := range parsed {
+			parsed[i].Source = filepath.ToSlash(rel)
+		}
+		ops = append(ops, parsed...)
+	}
+
+	for _, logicalID := range sortedResourceIDs(resources) {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		switch resource["Type"] {
+		case "AWS::ApiGateway::RestApi", "AWS::Serverless::Api", "AWS::Serverless::HttpApi", "AWS::ApiGatewayV2::Api":
+			for _, key := range []string{"Body", "DefinitionBody"} {
+				if spec, ok := props[key].(map[string]interface{}); ok {
+					ops = append(ops, specOperations(spec, logicalID)...)
+				}
+			}
+		case "AWS::ApiGateway::Method":
+			// CDK's operationName becomes the method's OperationName
+			name, _ := props["OperationName"].(string)
+			if name == "" {
+				continue
+			}
+			operation := APIOperation{OperationID: name, Source: logicalID}
+			operation.Method, _ = props["HttpMethod"].(string)
+			operation.Path = methodPath(resources, props["ResourceId"])
+			if integration, ok := props["Integration"].(map[string]interface{}); ok {
+				operation.Lambda = integrationLambda(integration["Uri"])
+			}
+			if apiIDs := referencedIDs(props["RestApiId"], nil); len(apiIDs) > 0 {
+				operation.API = apiIDs[0]
+			}
+			ops = append(ops, operation)
+		}
+	}
+	return ops
+}
+
+// methodPath rebuilds the path of an API Gateway resource from the
+// PathParts of its ancestors; the root resource is "/".
+func methodPath(resources map[string]interface{}, resourceID interface{}) string {
+	var parts []string
+	// a cycle cannot occur in a valid template, but bound the walk anyway
+	for depth := 0; depth < 64; depth++ {
+		ref, ok := resourceID.(map[string]interface{})
+		if !ok {
+			break
+		}
+		id, ok := ref["Ref"].(string)
+		if !ok {
+			break // Fn::GetAtt [api, RootResourceId]
+		}
+		resource, _ := resources[id].(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		part, _ := props["PathPart"].(string)
+		parts = append([]string{part}, parts...)
+		resourceID = props["ParentId"]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// findSpecFiles returns the OpenAPI and Swagger files under dir: JSON or
+// YAML files named openapi*, swagger* or *.openapi.*.
+func findSpecFiles(dir string) []string {
+	var paths []string
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != dir && skippedScanDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := strings.ToLower(entry.Name())
+		switch filepath.Ext(name) {
+		case ".json", ".yaml", ".yml":
+		default:
+			return nil
+		}
+		if strings.HasPrefix(name, "openapi") || strings.HasPrefix(name, "swagger") || strings.Contains(name, ".openapi.") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+func sortedResourceIDs(resources map[string]interface{}) []string {
+	ids := make([]string, 0, len(resources))
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// operationsState converts operations to the generic form the cache stores,
+// so a scanned state and its reloaded cache compare equal.
+func operationsState(ops []APIOperation) []interface{} {
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return nil
+	}
+	var generic []interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	return generic
+}
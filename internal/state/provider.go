@@ -17,15 +17,36 @@ type Provider interface {
 type IaCProvider struct{}
 
 func (p *IaCProvider) Scan(ctx context.Context, path string) (map[string]interface{}, error) {
-	// Check for CDK output
+	var templateData map[string]interface{}
+	var err error
+
+	// Check for CDK output, then for Terraform
 	cdkOutPath := filepath.Join(path, "cdk.out")
-	if _, err := os.Stat(cdkOutPath); err == nil {
-		return p.scanCdk(cdkOutPath)
+	if _, statErr := os.Stat(cdkOutPath); statErr == nil {
+		templateData, err = p.scanCdk(cdkOutPath)
+	} else {
+		templateData, err = p.scanTerraform(ctx, path)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: Add CloudFormation and Terraform file checks here
+	// TODO: Add CloudFormation template checks here
+	if templateData == nil {
+		return nil, fmt.Errorf("no supported IaC files found in %s\n\nFor CDK projects: run 'cdk synth' first to generate cdk.out/ directory\nFor Terraform projects: run 'terraform init', or save 'terraform show -json' output as tfplan.json\nFor other projects: ensure you have .tf, .yaml, or .json template files", path)
+	}
 
-	return nil, fmt.Errorf("no supported IaC files found in %s\n\nFor CDK projects: run 'cdk synth' first to generate cdk.out/ directory\nFor other projects: ensure you have .tf, .yaml, or .json template files", path)
+	resources, _ := templateData["Resources"].(map[string]interface{})
+	if resources != nil {
+		LinkKMSKeys(resources)
+	}
+	if ops := APIOperations(path, resources); len(ops) > 0 {
+		templateData["APIOperations"] = operationsState(ops)
+	}
+	if secrets := RedactSecrets(templateData); len(secrets) > 0 {
+		templateData[SecretsKey] = secrets
+	}
+	return templateData, nil
 }
 
 func (p *IaCProvider) scanCdk(cdkOutPath string) (map[string]interface{}, error) {
@@ -41,6 +62,7 @@ func (p *IaCProvider) scanCdk(cdkOutPath string) (map[string]interface{}, error)
 			Properties struct {
 				TemplateFile string `json:"templateFile"`
 			} `json:"properties"`
+			Metadata map[string][]cdkMetadataEntry `json:"metadata"`
 		} `json:"artifacts"`
 	}
 	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
@@ -60,6 +82,9 @@ func (p *IaCProvider) scanCdk(cdkOutPath string) (map[string]interface{}, error)
 			if err := json.Unmarshal(templateBytes, &templateData); err != nil {
 				return nil, fmt.Errorf("could not parse template file %s: %w", templatePath, err)
 			}
+			if resources, ok := templateData["Resources"].(map[string]interface{}); ok {
+				addCdkSources(filepath.Dir(cdkOutPath), artifact.Metadata, resources)
+			}
 			return templateData, nil
 		}
 	}
@@ -0,0 +1,150 @@
+package state
+
+import (
+	"strings"
+)
+
+// LiveServices returns the services a live scan covers, in scan order.
+func LiveServices() []string {
+	services := make([]string, len(liveScanners))
+	for i, scanner := range liveScanners {
+		services[i] = scanner.service
+	}
+	return services
+}
+
+// RefreshableServices returns the services of a cached state that a
+// background refresh should rescan: the services a live scan covered, and
+// for a shallow inventory the Tagging API listing plus the services
+// questions have already filled in. Other states are not refreshed.
+func RefreshableServices(infraState map[string]interface{}) []string {
+	switch infraState["Source"] {
+	case "live":
+		include, exclude := serviceFilter(infraState)
+		var services []string
+		for _, scanner := range selectScanners(include, exclude) {
+			services = append(services, scanner.service)
+		}
+		return services
+	case "discovery":
+		return append([]string{"tagging"}, stringList(infraState[detailedServicesKey])...)
+	}
+	return nil
+}
+
+// RefreshServices merges a live scan of some services into a cached state in
+// place. The resources of those services are replaced by what the scan
+// found within the state's service selection, so deleted ones disappear;
+// resources of other services, imports and their warnings are kept, and so
+// are the resources of a service the scan was denied. Shallow entries belong
+// to the tagging service and never replace a detailed entry.
+func RefreshServices(infraState, live map[string]interface{}, services []string) {
+	denied := make(map[string]bool)
+	for _, warning := range scanWarnings(live["Warnings"]) {
+		denied[warning.Service] = true
+	}
+	refreshed := make(map[string]bool, len(services))
+	for _, service := range services {
+		refreshed[service] = true
+	}
+
+	resources, ok := infraState["Resources"].(map[string]interface{})
+	if !ok {
+		resources = make(map[string]interface{})
+		infraState["Resources"] = resources
+	}
+	for logicalID, raw := range resources {
+		resource, _ := raw.(map[string]interface{})
+		if resource == nil || resource[importedFromKey] != nil {
+			continue
+		}
+		service, _, _ := strings.Cut(logicalID, "/")
+		if resource[shallowKey] == true {
+			service = "tagging"
+		}
+		if refreshed[service] && !denied[service] {
+			delete(resources, logicalID)
+		}
+	}
+
+	include, exclude := serviceFilter(infraState)
+	scanned, _ := live["Resources"].(map[string]interface{})
+	for logicalID, raw := range scanned {
+		resource, _ := raw.(map[string]interface{})
+		if (len(include) > 0 || len(exclude) > 0) && !ServiceSelected(ResourceService(resource), include, exclude) {
+			continue
+		}
+		if existing, ok := resources[logicalID].(map[string]interface{}); ok && resource != nil && resource[shallowKey] == true && existing[shallowKey] != true {
+			continue
+		}
+		resources[logicalID] = raw
+	}
+
+	mergeScanTimes(infraState, live)
+
+	var warnings []interface{}
+	for _, warning := range scanWarnings(infraState["Warnings"]) {
+		if !refreshed[warning.Service] {
+			warnings = append(warnings, warning)
+		}
+	}
+	for _, warning := range scanWarnings(live["Warnings"]) {
+		warnings = append(warnings, warning)
+	}
+	if len(warnings) > 0 {
+		infraState["Warnings"] = warnings
+	} else {
+		delete(infraState, "Warnings")
+	}
+}
+
+// scanWarnings reads the warnings of a scan, whether freshly scanned or
+// loaded from the cache.
+func scanWarnings(raw interface{}) []ScanWarning {
+	switch v := raw.(type) {
+	case []ScanWarning:
+		return v
+	case []interface{}:
+		warnings := make([]ScanWarning, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				service, _ := m["service"].(string)
+				message, _ := m["error"].(string)
+				warnings = append(warnings, ScanWarning{Service: service, Error: message, Grant: stringList(m["grant"])})
+			}
+		}
+		return warnings
+	}
+	return nil
+}
+
+// mergeScanTimes records the scan times of a live scan in a state.
+func mergeScanTimes(infraState, live map[string]interface{}) {
+	scanned, _ := live[scanTimesKey].(map[string]interface{})
+	if len(scanned) == 0 {
+		return
+	}
+	merged := make(map[string]interface{})
+	if existing, ok := infraState[scanTimesKey].(map[string]interface{}); ok {
+		for service, at := range existing {
+			merged[service] = at
+		}
+	}
+	for service, at := range scanned {
+		merged[service] = at
+	}
+	infraState[scanTimesKey] = merged
+}
+
+// ScanTimes returns when each service of a state was last scanned, as
+// RFC 3339 timestamps by service.
+func ScanTimes(infraState map[string]interface{}) map[string]string {
+	raw, _ := infraState[scanTimesKey].(map[string]interface{})
+	times := make(map[string]string, len(raw))
+	for service, at := range raw {
+		if s, ok := at.(string); ok {
+			times[service] = s
+		}
+	}
+	return times
+}
@@ -0,0 +1,250 @@
+package state
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kinds of ResourceChange
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeRemoved  = "removed"
+)
+
+// ResourceChange is a resource that differs between two scans.
+type ResourceChange struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	Change string `json:"change"`
+	// Properties lists the changed properties of a modified resource
+	Properties []string `json:"properties,omitempty"`
+}
+
+// Diff compares the resources of two states, e.g. the scans of a pull
+// request's base and head, sorted by logical ID.
+func Diff(base, head map[string]interface{}) []ResourceChange {
+	baseResources, _ := base["Resources"].(map[string]interface{})
+	headResources, _ := head["Resources"].(map[string]interface{})
+
+	var changes []ResourceChange
+	for id, raw := range headResources {
+		resource, _ := raw.(map[string]interface{})
+		change := newResourceChange(id, resource)
+		old, ok := baseResources[id].(map[string]interface{})
+		if !ok {
+			change.Change = ChangeAdded
+			changes = append(changes, change)
+			continue
+		}
+		change.Properties = changedProperties(old, resource)
+		if len(change.Properties) > 0 {
+			change.Change = ChangeModified
+			changes = append(changes, change)
+		}
+	}
+	for id, raw := range baseResources {
+		if _, ok := headResources[id]; ok {
+			continue
+		}
+		resource, _ := raw.(map[string]interface{})
+		change := newResourceChange(id, resource)
+		change.Change = ChangeRemoved
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes
+}
+
+func newResourceChange(id string, resource map[string]interface{}) ResourceChange {
+	resourceType, _ := resource["Type"].(string)
+	props, _ := resource["Properties"].(map[string]interface{})
+	return ResourceChange{ID: id, Type: resourceType, Name: resourceName(props)}
+}
+
+// changedProperties names the properties that differ between two versions
+// of a resource; a changed type or DependsOn counts as a property.
+func changedProperties(old, current map[string]interface{}) []string {
+	var changed []string
+	for _, key := range []string{"Type", "DependsOn"} {
+		if !reflect.DeepEqual(old[key], current[key]) {
+			changed = append(changed, key)
+		}
+	}
+	oldProps, _ := old["Properties"].(map[string]interface{})
+	newProps, _ := current["Properties"].(map[string]interface{})
+	keys := make(map[string]bool)
+	for key := range oldProps {
+		keys[key] = true
+	}
+	for key := range newProps {
+		keys[key] = true
+	}
+	for key := range keys {
+		if !reflect.DeepEqual(oldProps[key], newProps[key]) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// sensitiveTypes guard access or hold data, so changing them is riskier
+// than changing the resources around them
+var sensitiveTypes = map[string]string{
+	"AWS::IAM::Role":              "controls access",
+	"AWS::IAM::Policy":            "controls access",
+	"AWS::IAM::ManagedPolicy":     "controls access",
+	"AWS::KMS::Key":               "encrypts data",
+	"AWS::EC2::SecurityGroup":     "controls network access",
+	"AWS::Cognito::UserPool":      "authenticates users",
+	"AWS::ApiGateway::Authorizer": "authorizes API calls",
+	"AWS::DynamoDB::Table":        "holds data",
+	"AWS::RDS::DBInstance":        "holds data",
+	"AWS::RDS::DBCluster":         "holds data",
+	"AWS::S3::Bucket":             "holds data",
+	"AWS::SQS::Queue":             "holds messages",
+	"AWS::SecretsManager::Secret": "holds credentials",
+}
+
+// ChangeRisk is a changed resource with what it could break.
+type ChangeRisk struct {
+	ResourceChange
+	Score int `json:"score"`
+	// Affected counts the resources that depend on this one
+	Affected int `json:"affected"`
+	// Routes are the API operations served by the resource or its dependents
+	Routes  []string `json:"routes,omitempty"`
+	Reasons []string `json:"reasons"`
+}
+
+// RiskReport scores the changes between two scans from 0 (nothing changed)
+// to 100.
+type RiskReport struct {
+	Score   int          `json:"score"`
+	Level   string       `json:"level"`
+	Changes []ChangeRisk `json:"changes"`
+}
+
+// AssessRisk scores each change by its kind, the type of resource and its
+// blast radius in the state it matters in: the base for removals, the head
+// otherwise.
+func AssessRisk(base, head map[string]interface{}) RiskReport {
+	report := RiskReport{Changes: []ChangeRisk{}}
+	for _, change := range Diff(base, head) {
+		infraState := head
+		risk := ChangeRisk{ResourceChange: change}
+		switch change.Change {
+		case ChangeAdded:
+			risk.Score = 1
+			risk.Reasons = append(risk.Reasons, "adds "+describeChange(change))
+		case ChangeModified:
+			risk.Score = 3
+			risk.Reasons = append(risk.Reasons, fmt.Sprintf("modifies %s (%s)", describeChange(change), strings.Join(change.Properties, ", ")))
+			if slices.Contains(change.Properties, "Type") {
+				risk.Score += 5
+				risk.Reasons = append(risk.Reasons, "changes the resource type, which replaces the resource")
+			}
+		case ChangeRemoved:
+			infraState = base
+			risk.Score = 5
+			risk.Reasons = append(risk.Reasons, "removes "+describeChange(change))
+		}
+
+		if why, ok := sensitiveTypes[change.Type]; ok && change.Change != ChangeAdded {
+			risk.Score += 5
+			if change.Change == ChangeRemoved && strings.HasPrefix(why, "holds") {
+				risk.Score += 10
+				why += " that is lost with it"
+			}
+			risk.Reasons = append(risk.Reasons, "the resource "+why)
+		}
+
+		if change.Change != ChangeAdded {
+			impact := Impact(infraState, change.ID)
+			risk.Affected = impact.Count()
+			risk.Routes = impactRoutes(infraState, impact)
+			if risk.Affected > 0 {
+				risk.Score += min(risk.Affected, 10)
+				risk.Reasons = append(risk.Reasons, fmt.Sprintf("%d resource(s) depend on it", risk.Affected))
+			}
+			if len(risk.Routes) > 0 {
+				risk.Score += min(2*len(risk.Routes), 10)
+				risk.Reasons = append(risk.Reasons, fmt.Sprintf("it serves %d API route(s): %s", len(risk.Routes), strings.Join(risk.Routes, ", ")))
+			}
+		}
+		report.Score += risk.Score
+		report.Changes = append(report.Changes, risk)
+	}
+
+	sort.SliceStable(report.Changes, func(i, j int) bool { return report.Changes[i].Score > report.Changes[j].Score })
+	report.Score = min(report.Score, 100)
+	switch {
+	case report.Score == 0:
+		report.Level = "none"
+	case report.Score < 10:
+		report.Level = "low"
+	case report.Score < 30:
+		report.Level = "medium"
+	default:
+		report.Level = "high"
+	}
+	return report
+}
+
+func describeChange(change ResourceChange) string {
+	label := change.ID + " (" + change.Type + ")"
+	if change.Name != "" && change.Name != change.ID {
+		label = change.Name + " " + label
+	}
+	return label
+}
+
+// impactRoutes returns the API operations whose Lambda integration is the
+// impacted resource or one of its dependents, as "METHOD /path".
+func impactRoutes(infraState map[string]interface{}, impact *ImpactNode) []string {
+	functions := make(map[string]bool)
+	var collect func(*ImpactNode)
+	collect = func(node *ImpactNode) {
+		if node.Type == "AWS::Lambda::Function" || node.Type == "AWS::Serverless::Function" {
+			functions[node.ID] = true
+			if node.Name != "" {
+				functions[node.Name] = true
+			}
+		}
+		for _, dependent := range node.Dependents {
+			collect(dependent)
+		}
+	}
+	collect(impact)
+
+	ops, _ := infraState["APIOperations"].([]interface{})
+	seen := make(map[string]bool)
+	var routes []string
+	for _, raw := range ops {
+		op, _ := raw.(map[string]interface{})
+		lambda, _ := op["lambda"].(string)
+		if lambda == "" {
+			continue
+		}
+		// federated states key resources by project, operations name it
+		if project, ok := op[projectKey].(string); ok && !functions[lambda] {
+			lambda = project + "/" + lambda
+		}
+		if !functions[lambda] {
+			continue
+		}
+		method, _ := op["method"].(string)
+		path, _ := op["path"].(string)
+		if route := method + " " + path; !seen[route] {
+			seen[route] = true
+			routes = append(routes, route)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
@@ -0,0 +1,339 @@
+package state
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SecretsKey is the state key hard-coded secrets found by a scan are kept
+// under. The section stays on this machine: StripSecrets removes it before
+// the state is serialized for a model.
+const SecretsKey = "SecretFindings"
+
+// secretRedacted replaces a hard-coded secret in the scanned state
+const secretRedacted = "[REDACTED]"
+
+// SecretFinding is a hard-coded secret in a template. Only a masked preview
+// of the value is kept; the value itself is redacted from the state.
+type SecretFinding struct {
+	Resource string `json:"resource"`
+	Location string `json:"location"`
+	Kind     string `json:"kind"`
+	Preview  string `json:"preview"`
+}
+
+// secretFormats are the key formats recognised wherever they appear.
+var secretFormats = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[^-]*(-----END [A-Z ]*PRIVATE KEY-----)?`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{16,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"password in connection string", regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^:/@\s]+:([^@/\s\[$]+)@`)},
+}
+
+// secretName matches variable and parameter names that hold secrets.
+// Names ending in one of secretReferenceSuffixes point at a secret instead.
+var secretName = regexp.MustCompile(`(?i)(passw(or)?d|passwd|secret|token|api_?key|private_?key|credential)`)
+
+var secretReferenceSuffixes = []string{
+	"ARN", "NAME", "ID", "PATH", "PARAM", "PARAMETER", "URL", "URI", "REF",
+	"TABLE", "BUCKET", "QUEUE", "TOPIC", "ENDPOINT", "FILE", "TYPE",
+}
+
+// secretAssignment matches NAME=value and name: value lines of scripts such
+// as user data whose name holds a secret. ${VAR} and [REDACTED] values are not literals.
+var secretAssignment = regexp.MustCompile(`(?i)\b[A-Z0-9_]*(passw(or)?d|passwd|secret|token|api_?key)[A-Z0-9_]*\s*[=:]\s*['"]?([^\s'"\[$]{8,})`)
+
+// secretToken splits text into the candidate tokens the entropy check scores.
+var secretToken = regexp.MustCompile(`[A-Za-z0-9+/=_]{32,}`)
+
+// Random keys score about 4.9 bits per character over 40 characters;
+// CamelCase logical IDs and hex hashes stay below the threshold.
+const secretEntropyThreshold = 4.5
+
+// RedactSecrets finds hard-coded secrets in the Lambda environment
+// variables, user data and parameter defaults of a template, replaces them
+// in place with [REDACTED] and returns them sorted by resource.
+func RedactSecrets(infraState map[string]interface{}) []SecretFinding {
+	var findings []SecretFinding
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	for id, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		props, ok := resource["Properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceType, _ := resource["Type"].(string)
+		if resourceType == "AWS::Lambda::Function" || resourceType == "AWS::Serverless::Function" {
+			if env, ok := props["Environment"]; ok {
+				props["Environment"] = redactValue(env, "Environment", "", func(location, kind, value string) {
+					findings = append(findings, SecretFinding{Resource: id, Location: location, Kind: kind, Preview: maskSecret(value)})
+				})
+			}
+		}
+		redactUserData(props, "", func(location, kind, value string) {
+			findings = append(findings, SecretFinding{Resource: id, Location: location, Kind: kind, Preview: maskSecret(value)})
+		})
+	}
+
+	params, _ := infraState["Parameters"].(map[string]interface{})
+	for name, raw := range params {
+		param, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := param["Default"].(string)
+		if !ok || value == "" {
+			continue
+		}
+		report := func(location, kind, secret string) {
+			findings = append(findings, SecretFinding{Resource: "Parameters/" + name, Location: location, Kind: kind, Preview: maskSecret(secret)})
+		}
+		// a NoEcho parameter is a secret, so its default is one too
+		if isNoEcho(param["NoEcho"]) && !isSecretReference(value) {
+			report("Default", "default of a NoEcho parameter", value)
+			param["Default"] = secretRedacted
+			continue
+		}
+		param["Default"] = redactString(value, "Default", name, report)
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Resource != findings[j].Resource {
+			return findings[i].Resource < findings[j].Resource
+		}
+		return findings[i].Location < findings[j].Location
+	})
+	return findings
+}
+
+// StripSecrets returns the state without its secret findings, for
+// serializing into a prompt. The input is not modified.
+func StripSecrets(infraState map[string]interface{}) map[string]interface{} {
+	if _, ok := infraState[SecretsKey]; !ok {
+		return infraState
+	}
+	out := make(map[string]interface{}, len(infraState))
+	for key, value := range infraState {
+		if key != SecretsKey {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// SecretFindings reads the secret findings of a state, which are typed
+// straight from a scan and generic maps once loaded from cache.
+func SecretFindings(infraState map[string]interface{}) []SecretFinding {
+	switch findings := infraState[SecretsKey].(type) {
+	case nil:
+		return nil
+	case []SecretFinding:
+		return findings
+	default:
+		var out []SecretFinding
+		if data, err := json.Marshal(findings); err == nil {
+			_ = json.Unmarshal(data, &out)
+		}
+		return out
+	}
+}
+
+// redactUserData redacts secrets under every UserData property, which
+// launch templates nest in LaunchTemplateData.
+func redactUserData(props map[string]interface{}, path string, report func(location, kind, value string)) {
+	for key, value := range props {
+		location := joinLocation(path, key)
+		if key == "UserData" {
+			props[key] = redactValue(value, location, "", report)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			redactUserData(nested, location, report)
+		}
+	}
+}
+
+// redactValue redacts the strings in value, an intrinsic function or a
+// plain string alike. name is the map key a string is stored under, so
+// environment variables are judged by their names.
+func redactValue(value interface{}, location, name string, report func(location, kind, value string)) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redactString(v, location, name, report)
+	case map[string]interface{}:
+		for key, item := range v {
+			itemLocation := location
+			if !strings.HasPrefix(key, "Fn::") {
+				itemLocation = joinLocation(location, key)
+			}
+			v[key] = redactValue(item, itemLocation, key, report)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item, location, name, report)
+		}
+		return v
+	}
+	return value
+}
+
+// redactString redacts the secrets in s. A base64 script, as user data
+// often is, is decoded first and, if it holds a secret, kept decoded with
+// the secret redacted.
+func redactString(s, location, name string, report func(location, kind, value string)) string {
+	if decoded, ok := decodeScript(s); ok {
+		if redacted := redactText(decoded, location, name, report); redacted != decoded {
+			return redacted
+		}
+		return s
+	}
+	return redactText(s, location, name, report)
+}
+
+func redactText(s, location, name string, report func(location, kind, value string)) string {
+	for _, format := range secretFormats {
+		s = format.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			secret := match
+			if groups := format.pattern.FindStringSubmatch(match); format.kind == "password in connection string" && len(groups) > 1 {
+				secret = groups[1]
+				report(location, format.kind, secret)
+				return strings.Replace(match, secret, secretRedacted, 1)
+			}
+			report(location, format.kind, secret)
+			return secretRedacted
+		})
+	}
+
+	// a colon ends "secretsmanager:" in an ARN, so only scripts are read
+	// for assignments
+	if strings.Contains(s, "\n") {
+		s = secretAssignment.ReplaceAllStringFunc(s, func(match string) string {
+			groups := secretAssignment.FindStringSubmatch(match)
+			secret := groups[len(groups)-1]
+			report(location, "literal secret assignment", secret)
+			return strings.TrimSuffix(match, secret) + secretRedacted
+		})
+	}
+
+	if name != "" && isSecretName(name) && s != secretRedacted && len(s) >= 8 && !strings.Contains(s, secretRedacted) && !isSecretReference(s) {
+		report(location, "literal value of "+name, s)
+		return secretRedacted
+	}
+
+	return secretToken.ReplaceAllStringFunc(s, func(token string) string {
+		if !isRandomLooking(token) {
+			return token
+		}
+		report(location, "high-entropy string", token)
+		return secretRedacted
+	})
+}
+
+// isSecretName reports whether a variable or parameter name holds a secret
+// rather than the name or ARN of one.
+func isSecretName(name string) bool {
+	if !secretName.MatchString(name) {
+		return false
+	}
+	upper := strings.ToUpper(name)
+	for _, suffix := range secretReferenceSuffixes {
+		if strings.HasSuffix(upper, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSecretReference reports whether a value points at a secret kept
+// elsewhere, such as a Secrets Manager dynamic reference or an SSM path.
+func isSecretReference(value string) bool {
+	for _, prefix := range []string{"arn:", "{{resolve:", "${", "/", "http://", "https://"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return value == "true" || value == "false"
+}
+
+func isNoEcho(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// isRandomLooking reports whether a token reads like a generated key:
+// letters and digits mixed with high Shannon entropy. Hex strings are asset
+// hashes and commit IDs far more often than keys.
+func isRandomLooking(token string) bool {
+	hasLetter, hasDigit, hex := false, false, true
+	counts := make(map[rune]int)
+	for _, r := range token {
+		counts[r]++
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+		hex = hex && strings.ContainsRune("0123456789abcdefABCDEF", r)
+	}
+	if !hasLetter || !hasDigit || hex {
+		return false
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(token))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= secretEntropyThreshold
+}
+
+// decodeScript decodes s if it is base64 of readable text.
+func decodeScript(s string) (string, bool) {
+	if len(s) < 16 || strings.ContainsAny(s, " \t") {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(s, "\n", ""))
+	if err != nil || !utf8.Valid(data) {
+		return "", false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return "", false
+		}
+	}
+	return string(data), true
+}
+
+// maskSecret keeps enough of a secret to find it in the template: the first
+// four characters of a long one, which are mostly a format prefix.
+func maskSecret(secret string) string {
+	if len(secret) >= 16 {
+		return secret[:4] + strings.Repeat("*", 8)
+	}
+	return strings.Repeat("*", 8)
+}
+
+func joinLocation(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
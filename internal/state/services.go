@@ -0,0 +1,98 @@
+package state
+
+import (
+	"slices"
+	"strings"
+)
+
+// serviceFilterKey records the --services and --exclude selection a state
+// was scanned with, so background refreshes keep to it.
+const serviceFilterKey = "ServiceFilter"
+
+// ResourceService returns the service a resource belongs to, from the second
+// part of its CloudFormation type: "lambda" for AWS::Lambda::Function.
+func ResourceService(resource map[string]interface{}) string {
+	resourceType, _ := resource["Type"].(string)
+	parts := strings.Split(resourceType, "::")
+	if len(parts) < 2 || parts[0] != "AWS" {
+		return ""
+	}
+	return strings.ToLower(parts[1])
+}
+
+// FilterServices keeps the resources of the included services (all services
+// when include is empty) that are not excluded, and records the selection in
+// the state. It returns the number of resources dropped.
+func FilterServices(infraState map[string]interface{}, include, exclude []string) int {
+	if len(include) == 0 && len(exclude) == 0 {
+		return 0
+	}
+	infraState[serviceFilterKey] = map[string]interface{}{"Services": include, "Exclude": exclude}
+
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	dropped := 0
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource[importedFromKey] != nil {
+			continue
+		}
+		if !ServiceSelected(ResourceService(resource), include, exclude) {
+			delete(resources, logicalID)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// ServiceSelected reports whether a service passes an include and exclude
+// selection.
+func ServiceSelected(service string, include, exclude []string) bool {
+	if slices.Contains(exclude, service) {
+		return false
+	}
+	return len(include) == 0 || slices.Contains(include, service)
+}
+
+// serviceFilter returns the selection recorded by FilterServices.
+func serviceFilter(infraState map[string]interface{}) (include, exclude []string) {
+	filter, _ := infraState[serviceFilterKey].(map[string]interface{})
+	return stringList(filter["Services"]), stringList(filter["Exclude"])
+}
+
+// selectScanners returns the live scanners for a selection. The tagging
+// listing runs unless excluded or every included service has a scanner of
+// its own; it covers the services that do not.
+func selectScanners(include, exclude []string) []serviceScanner {
+	tagging := !slices.Contains(exclude, "tagging") && len(include) == 0
+	for _, service := range include {
+		if !slices.ContainsFunc(liveScanners, func(s serviceScanner) bool { return s.service == service }) {
+			tagging = !slices.Contains(exclude, "tagging")
+		}
+	}
+
+	var scanners []serviceScanner
+	for _, scanner := range liveScanners {
+		if scanner.service == "tagging" {
+			if tagging {
+				scanners = append(scanners, scanner)
+			}
+		} else if ServiceSelected(scanner.service, include, exclude) {
+			scanners = append(scanners, scanner)
+		}
+	}
+	return scanners
+}
+
+// RemoveResources deletes the resources match selects and returns how many
+// it deleted.
+func RemoveResources(infraState map[string]interface{}, match func(resource map[string]interface{}) bool) int {
+	resources, _ := infraState["Resources"].(map[string]interface{})
+	removed := 0
+	for logicalID, raw := range resources {
+		if resource, ok := raw.(map[string]interface{}); ok && match(resource) {
+			delete(resources, logicalID)
+			removed++
+		}
+	}
+	return removed
+}
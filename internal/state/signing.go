@@ -0,0 +1,85 @@
+package state
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrCacheUnsigned means the cache has no signature, e.g. it was written
+	// by an older version or copied from another machine.
+	ErrCacheUnsigned = errors.New("infrastructure cache is not signed")
+	// ErrCacheModified means the cache does not match its signature: it was
+	// edited outside cloudai or signed with another machine's key.
+	ErrCacheModified = errors.New("infrastructure cache was modified outside cloudai")
+)
+
+// signingKeyPath is the per-user HMAC key used to sign caches. It never
+// leaves the machine, so a cache can only be verified where it was written.
+func signingKeyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cloudai", "cache.key"), nil
+}
+
+// signingKey loads the local signing key, creating it on first use.
+func signingKey() ([]byte, error) {
+	path, err := signingKeyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, fmt.Errorf("invalid cache signing key in %s", path)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// sign returns the hex HMAC-SHA256 of data under the local key.
+func sign(data []byte) (string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to load cache signing key: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify checks data against a hex signature in constant time.
+func verify(data []byte, signature string) error {
+	expected, err := sign(data)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+		return ErrCacheModified
+	}
+	return nil
+}
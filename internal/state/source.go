@@ -0,0 +1,284 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// definedInKey marks scanned resources with the file and line that define
+// them, as "lib/api-stack.ts:42", so answers can point at the code.
+const definedInKey = "DefinedIn"
+
+// maxSourceFiles and maxSourceFileSize bound the search of a CDK project's
+// source for construct IDs
+const (
+	maxSourceFiles    = 2000
+	maxSourceFileSize = 1 << 20
+)
+
+// cdkSourceExtensions are the languages a CDK app is written in
+var cdkSourceExtensions = map[string]bool{
+	".ts": true, ".js": true, ".py": true, ".java": true, ".cs": true, ".go": true,
+}
+
+// skippedSourceDirs hold dependencies and build output, not the app
+var skippedSourceDirs = map[string]bool{
+	"node_modules": true, "cdk.out": true, ".git": true, ".terraform": true,
+	"dist": true, "build": true, "target": true, "obj": true,
+	".venv": true, "venv": true, "__pycache__": true,
+}
+
+var (
+	// traceFrame finds the file and line of a stack frame such as
+	// "new ApiStack (/app/lib/api-stack.ts:42:19)"
+	traceFrame = regexp.MustCompile(`\(?([^()\s]+):(\d+):\d+\)?$`)
+	// terraformBlock finds a resource block's type and name
+	terraformBlock = regexp.MustCompile(`^\s*resource\s+"([^"]+)"\s+"([^"]+)"`)
+	// terraformAddressIndex is the count or for_each key of an address
+	terraformAddressIndex = regexp.MustCompile(`\[[^\]]*\]$`)
+)
+
+// DefinedIn returns where a scanned resource is defined, as file:line
+// relative to the project, or "" when it is not known.
+func DefinedIn(resource map[string]interface{}) string {
+	location, _ := resource[definedInKey].(string)
+	return location
+}
+
+// ConstructPath returns the CDK construct path of a resource, such as
+// ApiStack/OrdersTable, or "" for resources not synthesized by the CDK.
+func ConstructPath(resource map[string]interface{}) string {
+	meta, _ := resource["Metadata"].(map[string]interface{})
+	path, _ := meta["aws:cdk:path"].(string)
+	return strings.TrimSuffix(path, "/Resource")
+}
+
+// cdkMetadataEntry is an entry of a stack artifact's metadata in
+// manifest.json. Trace is only recorded by `cdk synth --debug`.
+type cdkMetadataEntry struct {
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+	Trace []string    `json:"trace"`
+}
+
+// addCdkSources records where each resource of a synthesized stack is
+// defined. The stack traces `cdk synth --debug` leaves in the manifest are
+// exact; without them the app's source is searched for the construct IDs of
+// the resource's construct path.
+func addCdkSources(projectDir string, metadata map[string][]cdkMetadataEntry, resources map[string]interface{}) {
+	traced := make(map[string]string)
+	for _, entries := range metadata {
+		for _, entry := range entries {
+			logicalID, ok := entry.Data.(string)
+			if entry.Type != "aws:cdk:logicalId" || !ok {
+				continue
+			}
+			if location := traceLocation(projectDir, entry.Trace); location != "" {
+				traced[logicalID] = location
+			}
+		}
+	}
+
+	var index *sourceIndex
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok || resource["Type"] == "AWS::CDK::Metadata" {
+			continue
+		}
+		if location, ok := traced[logicalID]; ok {
+			resource[definedInKey] = location
+			continue
+		}
+		path := ConstructPath(resource)
+		if path == "" {
+			continue
+		}
+		if index == nil {
+			index = indexSource(projectDir)
+		}
+		if location := index.construct(path); location != "" {
+			resource[definedInKey] = location
+		}
+	}
+}
+
+// traceLocation returns the first frame of a construct's creation trace
+// that is in the app rather than in the CDK library.
+func traceLocation(projectDir string, trace []string) string {
+	for _, frame := range trace {
+		match := traceFrame.FindStringSubmatch(strings.TrimSpace(frame))
+		if match == nil || strings.Contains(match[1], "node_modules") {
+			continue
+		}
+		file := strings.TrimPrefix(match[1], "file://")
+		rel, err := filepath.Rel(projectDir, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		return filepath.ToSlash(rel) + ":" + match[2]
+	}
+	return ""
+}
+
+// sourceIndex is the text of a CDK app's source files
+type sourceIndex struct {
+	files map[string][]string
+	order []string
+}
+
+func indexSource(projectDir string) *sourceIndex {
+	index := &sourceIndex{files: make(map[string][]string)}
+	// compiled JavaScript repeats every construct ID of a TypeScript app
+	_, err := os.Stat(filepath.Join(projectDir, "tsconfig.json"))
+	typescript := err == nil
+
+	filepath.WalkDir(projectDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != projectDir && skippedSourceDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if !cdkSourceExtensions[ext] || strings.HasSuffix(path, ".d.ts") || (typescript && ext == ".js") {
+			return nil
+		}
+		if len(index.order) >= maxSourceFiles {
+			return filepath.SkipAll
+		}
+		if info, err := entry.Info(); err != nil || info.Size() > maxSourceFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(projectDir, path)
+		rel = filepath.ToSlash(rel)
+		index.files[rel] = strings.Split(string(data), "\n")
+		index.order = append(index.order, rel)
+		return nil
+	})
+	return index
+}
+
+// construct returns where a construct path is defined: the one line that
+// names the innermost construct ID, skipping the CDK's Resource and Default
+// children and IDs that appear on several lines. The stack's own ID is not
+// searched, since it only places the resource in the app entry point.
+func (s *sourceIndex) construct(path string) string {
+	ids := strings.Split(path, "/")
+	for i := len(ids) - 1; i >= 1; i-- {
+		id := ids[i]
+		if id == "" || id == "Resource" || id == "Default" {
+			continue
+		}
+		quoted := []string{`"` + id + `"`, `'` + id + `'`, "`" + id + "`"}
+		var found []string
+		for _, file := range s.order {
+			for n, line := range s.files[file] {
+				for _, q := range quoted {
+					if strings.Contains(line, q) {
+						found = append(found, fmt.Sprintf("%s:%d", file, n+1))
+						break
+					}
+				}
+			}
+		}
+		if len(found) == 1 {
+			return found[0]
+		}
+	}
+	return ""
+}
+
+// addTerraformSources records the file and line of each resource block,
+// finding modules' directories in the modules.json `terraform init` writes.
+func addTerraformSources(projectDir string, resources map[string]interface{}) {
+	dirs := map[string]string{"": "."}
+	if data, err := os.ReadFile(filepath.Join(projectDir, ".terraform", "modules", "modules.json")); err == nil {
+		var manifest struct {
+			Modules []struct {
+				Key string `json:"Key"`
+				Dir string `json:"Dir"`
+			} `json:"Modules"`
+		}
+		if json.Unmarshal(data, &manifest) == nil {
+			for _, module := range manifest.Modules {
+				dirs[module.Key] = module.Dir
+			}
+		}
+	}
+
+	blocks := make(map[string]map[string]string)
+	for address, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, block := terraformAddressParts(address)
+		dir, ok := dirs[key]
+		if !ok || block == "" {
+			continue
+		}
+		if blocks[dir] == nil {
+			blocks[dir] = terraformBlocks(projectDir, dir)
+		}
+		if location, ok := blocks[dir][block]; ok {
+			resource[definedInKey] = location
+		}
+	}
+}
+
+// terraformAddressParts splits an address such as
+// module.network.module.subnets.aws_subnet.private[0] into the module key
+// modules.json uses, "network.subnets", and the block, "aws_subnet.private".
+func terraformAddressParts(address string) (string, string) {
+	var modules []string
+	parts := strings.Split(address, ".")
+	for len(parts) >= 2 && parts[0] == "module" {
+		modules = append(modules, terraformAddressIndex.ReplaceAllString(parts[1], ""))
+		parts = parts[2:]
+	}
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.Join(modules, "."), parts[0] + "." + terraformAddressIndex.ReplaceAllString(parts[1], "")
+}
+
+// terraformBlocks maps the resource blocks of a module's .tf files to their
+// file:line, relative to the project.
+func terraformBlocks(projectDir, dir string) map[string]string {
+	blocks := make(map[string]string)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(projectDir, dir)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(projectDir, path)
+		if err != nil {
+			rel = path
+		}
+		scanner := bufio.NewScanner(file)
+		for n := 1; scanner.Scan(); n++ {
+			if match := terraformBlock.FindStringSubmatch(scanner.Text()); match != nil {
+				blocks[match[1]+"."+match[2]] = fmt.Sprintf("%s:%d", filepath.ToSlash(rel), n)
+			}
+		}
+		file.Close()
+	}
+	return blocks
+}
@@ -0,0 +1,273 @@
+package state
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	ectypes "github.com/aws/aws-sdk-go-v2/service/elasticache/types"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	kafkatypes "github.com/aws/aws-sdk-go-v2/service/kafka/types"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/ddjura/cloudai/internal/aws"
+)
+
+// scanEventSourceMappings records what each Lambda function polls: Kinesis
+// and DynamoDB streams, MSK clusters and SQS queues. The mappings name the
+// source and the function by ARN, which is how the relationship graph links
+// a stream to its consumers. Accounts that deny the listing keep the
+// functions without them.
+func scanEventSourceMappings(ctx context.Context, client *aws.Client, resources map[string]interface{}) {
+	paginator := lambda.NewListEventSourceMappingsPaginator(client.Lambda, &lambda.ListEventSourceMappingsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return
+		}
+		for _, mapping := range page.EventSourceMappings {
+			props := map[string]interface{}{
+				"UUID":           awssdk.ToString(mapping.UUID),
+				"EventSourceArn": awssdk.ToString(mapping.EventSourceArn),
+				"FunctionArn":    awssdk.ToString(mapping.FunctionArn),
+				"State":          awssdk.ToString(mapping.State),
+			}
+			if mapping.BatchSize != nil {
+				props["BatchSize"] = awssdk.ToInt32(mapping.BatchSize)
+			}
+			if mapping.StartingPosition != "" {
+				props["StartingPosition"] = string(mapping.StartingPosition)
+			}
+			if len(mapping.Topics) > 0 {
+				props["Topics"] = stringsToInterfaces(mapping.Topics)
+			}
+			resources["lambda/esm/"+awssdk.ToString(mapping.UUID)] = map[string]interface{}{
+				"Type":       "AWS::Lambda::EventSourceMapping",
+				"Properties": props,
+			}
+		}
+	}
+}
+
+func scanKinesis(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := kinesis.NewListStreamsPaginator(client.Kinesis, &kinesis.ListStreamsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, stream := range page.StreamSummaries {
+			out, err := client.Kinesis.DescribeStreamSummary(ctx, &kinesis.DescribeStreamSummaryInput{StreamARN: stream.StreamARN})
+			if err != nil {
+				return err
+			}
+			summary := out.StreamDescriptionSummary
+			props := map[string]interface{}{
+				"Name":                 awssdk.ToString(summary.StreamName),
+				"Arn":                  awssdk.ToString(summary.StreamARN),
+				"StreamStatus":         string(summary.StreamStatus),
+				"RetentionPeriodHours": awssdk.ToInt32(summary.RetentionPeriodHours),
+				"ShardCount":           awssdk.ToInt32(summary.OpenShardCount),
+				"EncryptionType":       string(summary.EncryptionType),
+			}
+			if summary.StreamModeDetails != nil {
+				props["StreamModeDetails"] = map[string]interface{}{"StreamMode": string(summary.StreamModeDetails.StreamMode)}
+			}
+			if summary.KeyId != nil {
+				props["KmsKeyId"] = awssdk.ToString(summary.KeyId)
+			}
+			resources["kinesis/"+awssdk.ToString(summary.StreamName)] = map[string]interface{}{
+				"Type":       "AWS::Kinesis::Stream",
+				"Properties": props,
+			}
+
+			// enhanced fan-out consumers read the stream without a mapping
+			if awssdk.ToInt32(summary.ConsumerCount) == 0 {
+				continue
+			}
+			consumers := kinesis.NewListStreamConsumersPaginator(client.Kinesis, &kinesis.ListStreamConsumersInput{StreamARN: summary.StreamARN})
+			for consumers.HasMorePages() {
+				consumerPage, err := consumers.NextPage(ctx)
+				if err != nil {
+					return err
+				}
+				for _, consumer := range consumerPage.Consumers {
+					resources["kinesis/"+awssdk.ToString(summary.StreamName)+"/"+awssdk.ToString(consumer.ConsumerName)] = map[string]interface{}{
+						"Type": "AWS::Kinesis::StreamConsumer",
+						"Properties": map[string]interface{}{
+							"ConsumerName":   awssdk.ToString(consumer.ConsumerName),
+							"Arn":            awssdk.ToString(consumer.ConsumerARN),
+							"StreamARN":      awssdk.ToString(summary.StreamARN),
+							"ConsumerStatus": string(consumer.ConsumerStatus),
+						},
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func scanMSK(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	paginator := kafka.NewListClustersV2Paginator(client.Kafka, &kafka.ListClustersV2Input{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range page.ClusterInfoList {
+			resources["msk/"+awssdk.ToString(cluster.ClusterName)] = mskResource(cluster)
+		}
+	}
+	return nil
+}
+
+func mskResource(cluster kafkatypes.Cluster) map[string]interface{} {
+	props := map[string]interface{}{
+		"ClusterName": awssdk.ToString(cluster.ClusterName),
+		"Arn":         awssdk.ToString(cluster.ClusterArn),
+		"State":       string(cluster.State),
+	}
+	resourceType := "AWS::MSK::Cluster"
+	if cluster.ClusterType == kafkatypes.ClusterTypeServerless {
+		resourceType = "AWS::MSK::ServerlessCluster"
+	}
+	if p := cluster.Provisioned; p != nil {
+		props["NumberOfBrokerNodes"] = awssdk.ToInt32(p.NumberOfBrokerNodes)
+		if p.BrokerNodeGroupInfo != nil {
+			props["InstanceType"] = awssdk.ToString(p.BrokerNodeGroupInfo.InstanceType)
+		}
+		if p.CurrentBrokerSoftwareInfo != nil {
+			props["KafkaVersion"] = awssdk.ToString(p.CurrentBrokerSoftwareInfo.KafkaVersion)
+		}
+		if info := p.EncryptionInfo; info != nil {
+			encryption := map[string]interface{}{}
+			if info.EncryptionAtRest != nil {
+				encryption["EncryptionAtRest"] = map[string]interface{}{"DataVolumeKMSKeyId": awssdk.ToString(info.EncryptionAtRest.DataVolumeKMSKeyId)}
+			}
+			if info.EncryptionInTransit != nil {
+				encryption["EncryptionInTransit"] = map[string]interface{}{
+					"ClientBroker": string(info.EncryptionInTransit.ClientBroker),
+					"InCluster":    awssdk.ToBool(info.EncryptionInTransit.InCluster),
+				}
+			}
+			props["EncryptionInfo"] = encryption
+		}
+	}
+	return map[string]interface{}{
+		"Type":       resourceType,
+		"Properties": props,
+	}
+}
+
+func scanElastiCache(ctx context.Context, client *aws.Client, resources map[string]interface{}) error {
+	groups := elasticache.NewDescribeReplicationGroupsPaginator(client.ElastiCache, &elasticache.DescribeReplicationGroupsInput{})
+	for groups.HasMorePages() {
+		page, err := groups.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, group := range page.ReplicationGroups {
+			resources["elasticache/"+awssdk.ToString(group.ReplicationGroupId)] = replicationGroupResource(group)
+		}
+	}
+
+	clusters := elasticache.NewDescribeCacheClustersPaginator(client.ElastiCache, &elasticache.DescribeCacheClustersInput{})
+	for clusters.HasMorePages() {
+		page, err := clusters.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range page.CacheClusters {
+			// the nodes of a replication group are described by the group
+			if cluster.ReplicationGroupId != nil {
+				continue
+			}
+			resources["elasticache/"+awssdk.ToString(cluster.CacheClusterId)] = cacheClusterResource(cluster)
+		}
+	}
+
+	serverless := elasticache.NewDescribeServerlessCachesPaginator(client.ElastiCache, &elasticache.DescribeServerlessCachesInput{})
+	for serverless.HasMorePages() {
+		page, err := serverless.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cache := range page.ServerlessCaches {
+			props := map[string]interface{}{
+				"ServerlessCacheName": awssdk.ToString(cache.ServerlessCacheName),
+				"Arn":                 awssdk.ToString(cache.ARN),
+				"Engine":              awssdk.ToString(cache.Engine),
+				"EngineVersion":       awssdk.ToString(cache.FullEngineVersion),
+				"Status":              awssdk.ToString(cache.Status),
+				"KmsKeyId":            awssdk.ToString(cache.KmsKeyId),
+			}
+			if cache.Endpoint != nil {
+				props["Endpoint"] = awssdk.ToString(cache.Endpoint.Address)
+			}
+			resources["elasticache/"+awssdk.ToString(cache.ServerlessCacheName)] = map[string]interface{}{
+				"Type":       "AWS::ElastiCache::ServerlessCache",
+				"Properties": props,
+			}
+		}
+	}
+	return nil
+}
+
+func replicationGroupResource(group ectypes.ReplicationGroup) map[string]interface{} {
+	props := map[string]interface{}{
+		"ReplicationGroupId":       awssdk.ToString(group.ReplicationGroupId),
+		"Arn":                      awssdk.ToString(group.ARN),
+		"Description":              awssdk.ToString(group.Description),
+		"Engine":                   awssdk.ToString(group.Engine),
+		"CacheNodeType":            awssdk.ToString(group.CacheNodeType),
+		"Status":                   awssdk.ToString(group.Status),
+		"NumCacheClusters":         len(group.MemberClusters),
+		"ClusterEnabled":           awssdk.ToBool(group.ClusterEnabled),
+		"AutomaticFailover":        string(group.AutomaticFailover),
+		"MultiAZ":                  string(group.MultiAZ),
+		"AtRestEncryptionEnabled":  awssdk.ToBool(group.AtRestEncryptionEnabled),
+		"TransitEncryptionEnabled": awssdk.ToBool(group.TransitEncryptionEnabled),
+		"KmsKeyId":                 awssdk.ToString(group.KmsKeyId),
+		"SnapshotRetentionLimit":   awssdk.ToInt32(group.SnapshotRetentionLimit),
+	}
+	switch {
+	case group.ConfigurationEndpoint != nil:
+		props["Endpoint"] = awssdk.ToString(group.ConfigurationEndpoint.Address)
+	case len(group.NodeGroups) > 0 && group.NodeGroups[0].PrimaryEndpoint != nil:
+		props["Endpoint"] = awssdk.ToString(group.NodeGroups[0].PrimaryEndpoint.Address)
+	}
+	return map[string]interface{}{
+		"Type":       "AWS::ElastiCache::ReplicationGroup",
+		"Properties": props,
+	}
+}
+
+func cacheClusterResource(cluster ectypes.CacheCluster) map[string]interface{} {
+	props := map[string]interface{}{
+		"ClusterName":              awssdk.ToString(cluster.CacheClusterId),
+		"Arn":                      awssdk.ToString(cluster.ARN),
+		"Engine":                   awssdk.ToString(cluster.Engine),
+		"EngineVersion":            awssdk.ToString(cluster.EngineVersion),
+		"CacheNodeType":            awssdk.ToString(cluster.CacheNodeType),
+		"NumCacheNodes":            awssdk.ToInt32(cluster.NumCacheNodes),
+		"Status":                   awssdk.ToString(cluster.CacheClusterStatus),
+		"AtRestEncryptionEnabled":  awssdk.ToBool(cluster.AtRestEncryptionEnabled),
+		"TransitEncryptionEnabled": awssdk.ToBool(cluster.TransitEncryptionEnabled),
+	}
+	if cluster.ConfigurationEndpoint != nil {
+		props["Endpoint"] = awssdk.ToString(cluster.ConfigurationEndpoint.Address)
+	}
+	return map[string]interface{}{
+		"Type":       "AWS::ElastiCache::CacheCluster",
+		"Properties": props,
+	}
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, value := range values {
+		out[i] = value
+	}
+	return out
+}
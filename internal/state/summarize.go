@@ -0,0 +1,274 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// policyKeys are property names whose values are IAM policy documents.
+var policyKeys = map[string]bool{
+	"PolicyDocument":           true,
+	"AssumeRolePolicyDocument": true,
+	"KeyPolicy":                true,
+}
+
+// maxActionsShown caps how many actions a policy summary lists verbatim.
+const maxActionsShown = 6
+
+// Summarize returns a copy of the state with high-volume, low-signal content
+// collapsed into short summaries: IAM policy documents become one-line
+// "Allow a, b on X" strings, resource Metadata is reduced to its CDK construct
+// path, the friendly name table is moved onto the resources it names, and
+// CDK bootstrap boilerplate is dropped. The input is not modified.
+//
+// Large CDK templates are dominated by this noise, so summarizing keeps the
+// prompt focused on the resources and how they are wired together.
+func Summarize(infraState map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(infraState))
+	for key, value := range infraState {
+		switch key {
+		case "Resources":
+			resources, ok := value.(map[string]interface{})
+			if !ok {
+				out[key] = value
+				continue
+			}
+			out[key] = summarizeResources(resources, FriendlyNames(infraState))
+		case FriendlyNamesKey:
+			// moved onto the resources it names
+		case "Rules":
+			// CDK only emits CheckBootstrapVersion here
+			if rules, ok := value.(map[string]interface{}); ok {
+				if rest := without(rules, "CheckBootstrapVersion"); len(rest) > 0 {
+					out[key] = rest
+				}
+				continue
+			}
+			out[key] = value
+		case "Parameters":
+			if params, ok := value.(map[string]interface{}); ok {
+				if rest := without(params, "BootstrapVersion"); len(rest) > 0 {
+					out[key] = rest
+				}
+				continue
+			}
+			out[key] = value
+		case "Conditions":
+			if conds, ok := value.(map[string]interface{}); ok {
+				if rest := without(conds, "CDKMetadataAvailable"); len(rest) > 0 {
+					out[key] = rest
+				}
+				continue
+			}
+			out[key] = value
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+func summarizeResources(resources map[string]interface{}, names map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(resources))
+	for logicalID, raw := range resources {
+		resource, ok := raw.(map[string]interface{})
+		if !ok {
+			out[logicalID] = raw
+			continue
+		}
+		if resource["Type"] == "AWS::CDK::Metadata" {
+			continue
+		}
+
+		summarized := make(map[string]interface{}, len(resource)+1)
+		if name, ok := names[logicalID]; ok {
+			summarized["FriendlyName"] = name
+		}
+		for key, value := range resource {
+			switch key {
+			case "Metadata":
+				if meta, ok := value.(map[string]interface{}); ok {
+					if path, ok := meta["aws:cdk:path"].(string); ok {
+						summarized["CdkPath"] = path
+					}
+				}
+			case "Properties":
+				summarized[key] = summarizeValue(value)
+			default:
+				summarized[key] = value
+			}
+		}
+		out[logicalID] = summarized
+	}
+	return out
+}
+
+// summarizeValue walks a property tree replacing policy documents in place.
+func summarizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			if policyKeys[key] {
+				out[key] = SummarizePolicy(child)
+				continue
+			}
+			out[key] = summarizeValue(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = summarizeValue(child)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// SummarizePolicy renders an IAM policy document as a compact, human-readable
+// string such as "Allow s3:GetObject, s3:PutObject on DataBucket".
+func SummarizePolicy(doc interface{}) string {
+	policy, ok := doc.(map[string]interface{})
+	if !ok {
+		return "policy"
+	}
+
+	var statements []interface{}
+	switch s := policy["Statement"].(type) {
+	case []interface{}:
+		statements = s
+	case map[string]interface{}:
+		statements = []interface{}{s}
+	}
+
+	var parts []string
+	for _, raw := range statements {
+		stmt, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		effect, _ := stmt["Effect"].(string)
+		if effect == "" {
+			effect = "Allow"
+		}
+
+		actions := stringList(stmt["Action"])
+		sort.Strings(actions)
+		if len(actions) > maxActionsShown {
+			actions = append(actions[:maxActionsShown], fmt.Sprintf("+%d more", len(actions)-maxActionsShown))
+		}
+
+		part := effect + " " + strings.Join(actions, ", ")
+		if principal := principalNames(stmt["Principal"]); len(principal) > 0 {
+			part += " for " + strings.Join(principal, ", ")
+		}
+		if resources := refNames(stmt["Resource"]); len(resources) > 0 {
+			part += " on " + strings.Join(resources, ", ")
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "empty policy"
+	}
+	return strings.Join(parts, "; ")
+}
+
+func principalNames(raw interface{}) []string {
+	switch p := raw.(type) {
+	case string:
+		return []string{p}
+	case map[string]interface{}:
+		var names []string
+		for _, value := range p {
+			names = append(names, refNames(value)...)
+		}
+		sort.Strings(names)
+		return names
+	}
+	return nil
+}
+
+// refNames turns resource values into short names, resolving Ref and
+// Fn::GetAtt to the logical ID they point at.
+func refNames(raw interface{}) []string {
+	seen := make(map[string]bool)
+	var names []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		name := refName(v)
+		if name == "" {
+			if list, ok := v.([]interface{}); ok {
+				for _, item := range list {
+					collect(item)
+				}
+			}
+			return
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	collect(raw)
+	return names
+}
+
+func refName(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case map[string]interface{}:
+		if ref, ok := val["Ref"].(string); ok {
+			return ref
+		}
+		if getAtt, ok := val["Fn::GetAtt"].([]interface{}); ok && len(getAtt) > 0 {
+			if id, ok := getAtt[0].(string); ok {
+				return id
+			}
+		}
+		if join, ok := val["Fn::Join"].([]interface{}); ok && len(join) == 2 {
+			// Joined ARNs usually embed a single reference; surface it.
+			if refs := refNames(join[1]); len(refs) > 0 {
+				for _, r := range refs {
+					if !strings.ContainsAny(r, ":/") && r != "AWS::Partition" && r != "AWS::Region" && r != "AWS::AccountId" {
+						return r
+					}
+				}
+			}
+			return "(joined ARN)"
+		}
+	}
+	return ""
+}
+
+func stringList(raw interface{}) []string {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func without(m map[string]interface{}, key string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,373 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// terraformJSONFiles are looked for, in order, in a Terraform project:
+// `terraform show -json` output of a plan, then a local state file.
+var terraformJSONFiles = []string{"tfplan.json", "plan.json", "terraform.tfstate"}
+
+// terraformShowTimeout bounds `terraform show -json`, which may read a
+// remote backend
+const terraformShowTimeout = 2 * time.Minute
+
+// terraformTypes maps Terraform resource types to the CloudFormation types
+// the rest of the state is keyed by. Other types keep their Terraform name.
+var terraformTypes = map[string]string{
+	"aws_lambda_function":               "AWS::Lambda::Function",
+	"aws_lambda_permission":             "AWS::Lambda::Permission",
+	"aws_lambda_event_source_mapping":   "AWS::Lambda::EventSourceMapping",
+	"aws_s3_bucket":                     "AWS::S3::Bucket",
+	"aws_dynamodb_table":                "AWS::DynamoDB::Table",
+	"aws_sqs_queue":                     "AWS::SQS::Queue",
+	"aws_sns_topic":                     "AWS::SNS::Topic",
+	"aws_sns_topic_subscription":        "AWS::SNS::Subscription",
+	"aws_iam_role":                      "AWS::IAM::Role",
+	"aws_iam_policy":                    "AWS::IAM::ManagedPolicy",
+	"aws_iam_role_policy":               "AWS::IAM::Policy",
+	"aws_iam_user":                      "AWS::IAM::User",
+	"aws_iam_user_policy":               "AWS::IAM::Policy",
+	"aws_s3_bucket_policy":              "AWS::S3::BucketPolicy",
+	"aws_sqs_queue_policy":              "AWS::SQS::QueuePolicy",
+	"aws_sns_topic_policy":              "AWS::SNS::TopicPolicy",
+	"aws_secretsmanager_secret_policy":  "AWS::SecretsManager::ResourcePolicy",
+	"aws_organizations_policy":          "AWS::Organizations::Policy",
+	"aws_kms_key":                       "AWS::KMS::Key",
+	"aws_kms_alias":                     "AWS::KMS::Alias",
+	"aws_api_gateway_rest_api":          "AWS::ApiGateway::RestApi",
+	"aws_api_gateway_resource":          "AWS::ApiGateway::Resource",
+	"aws_api_gateway_method":            "AWS::ApiGateway::Method",
+	"aws_api_gateway_integration":       "AWS::ApiGateway::Integration",
+	"aws_api_gateway_stage":             "AWS::ApiGateway::Stage",
+	"aws_apigatewayv2_api":              "AWS::ApiGatewayV2::Api",
+	"aws_apigatewayv2_route":            "AWS::ApiGatewayV2::Route",
+	"aws_apigatewayv2_integration":      "AWS::ApiGatewayV2::Integration",
+	"aws_cloudwatch_log_group":          "AWS::Logs::LogGroup",
+	"aws_cloudwatch_metric_alarm":       "AWS::CloudWatch::Alarm",
+	"aws_cloudwatch_event_rule":         "AWS::Events::Rule",
+	"aws_cloudwatch_event_target":       "AWS::Events::Target",
+	"aws_sfn_state_machine":             "AWS::StepFunctions::StateMachine",
+	"aws_db_instance":                   "AWS::RDS::DBInstance",
+	"aws_rds_cluster":                   "AWS::RDS::DBCluster",
+	"aws_instance":                      "AWS::EC2::Instance",
+	"aws_vpc":                           "AWS::EC2::VPC",
+	"aws_subnet":                        "AWS::EC2::Subnet",
+	"aws_security_group":                "AWS::EC2::SecurityGroup",
+	"aws_eks_cluster":                   "AWS::EKS::Cluster",
+	"aws_ecs_cluster":                   "AWS::ECS::Cluster",
+	"aws_ecs_service":                   "AWS::ECS::Service",
+	"aws_ecs_task_definition":           "AWS::ECS::TaskDefinition",
+	"aws_lb":                            "AWS::ElasticLoadBalancingV2::LoadBalancer",
+	"aws_lb_target_group":               "AWS::ElasticLoadBalancingV2::TargetGroup",
+	"aws_lb_listener":                   "AWS::ElasticLoadBalancingV2::Listener",
+	"aws_cloudfront_distribution":       "AWS::CloudFront::Distribution",
+	"aws_route53_zone":                  "AWS::Route53::HostedZone",
+	"aws_route53_record":                "AWS::Route53::RecordSet",
+	"aws_secretsmanager_secret":         "AWS::SecretsManager::Secret",
+	"aws_ssm_parameter":                 "AWS::SSM::Parameter",
+	"aws_kinesis_stream":                "AWS::Kinesis::Stream",
+	"aws_kinesis_stream_consumer":       "AWS::Kinesis::StreamConsumer",
+	"aws_msk_cluster":                   "AWS::MSK::Cluster",
+	"aws_msk_serverless_cluster":        "AWS::MSK::ServerlessCluster",
+	"aws_elasticache_replication_group": "AWS::ElastiCache::ReplicationGroup",
+	"aws_elasticache_cluster":           "AWS::ElastiCache::CacheCluster",
+	"aws_elasticache_serverless_cache":  "AWS::ElastiCache::ServerlessCache",
+}
+
+// terraformPolicyAttributes hold IAM policies as JSON strings; they are
+// decoded under the CloudFormation names so they are summarized like
+// template policies.
+var terraformPolicyAttributes = map[string]string{
+	"policy":             "PolicyDocument",
+	"assume_role_policy": "AssumeRolePolicyDocument",
+}
+
+// terraformModule is a module in `terraform show -json` output
+type terraformModule struct {
+	Address   string `json:"address"`
+	Resources []struct {
+		Address         string                 `json:"address"`
+		Mode            string                 `json:"mode"`
+		Type            string                 `json:"type"`
+		Values          map[string]interface{} `json:"values"`
+		SensitiveValues map[string]interface{} `json:"sensitive_values"`
+	} `json:"resources"`
+	ChildModules []terraformModule `json:"child_modules"`
+}
+
+// terraformValues is the values section of a plan or state in
+// `terraform show -json` output
+type terraformValues struct {
+	Outputs map[string]struct {
+		Sensitive bool        `json:"sensitive"`
+		Value     interface{} `json:"value"`
+	} `json:"outputs"`
+	RootModule terraformModule `json:"root_module"`
+}
+
+// scanTerraform reads a Terraform project's resources, including those of
+// child modules with their variables resolved, from the first of
+// terraformJSONFiles in path. Without one, an initialized project is read
+// with `terraform show -json`. Resources are marked with the file and line
+// of their block. It returns nil when path is not a Terraform project.
+func (p *IaCProvider) scanTerraform(ctx context.Context, path string) (map[string]interface{}, error) {
+	for _, name := range terraformJSONFiles {
+		data, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			continue
+		}
+		infraState, err := parseTerraformJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", name, err)
+		}
+		addTerraformSources(path, infraState["Resources"].(map[string]interface{}))
+		return infraState, nil
+	}
+
+	if tfFiles, _ := filepath.Glob(filepath.Join(path, "*.tf")); len(tfFiles) == 0 {
+		return nil, nil
+	}
+	hint := "run `terraform plan -out tfplan && terraform show -json tfplan > tfplan.json` first"
+	if _, err := os.Stat(filepath.Join(path, ".terraform")); err != nil {
+		return nil, fmt.Errorf("Terraform project is not initialized: %s", hint)
+	}
+	if _, err := exec.LookPath("terraform"); err != nil {
+		return nil, fmt.Errorf("terraform is not installed: %s", hint)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, terraformShowTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "terraform", "show", "-json", "-no-color")
+	cmd.Dir = path
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("terraform show failed: %w; %s", err, hint)
+	}
+	infraState, err := parseTerraformJSON(out)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse terraform show output: %w", err)
+	}
+	addTerraformSources(path, infraState["Resources"].(map[string]interface{}))
+	return infraState, nil
+}
+
+// parseTerraformJSON converts `terraform show -json` output of a plan or
+// state, or a terraform.tfstate file, into the scanned state shape.
+func parseTerraformJSON(data []byte) (map[string]interface{}, error) {
+	var doc struct {
+		PlannedValues *terraformValues `json:"planned_values"`
+		Values        *terraformValues `json:"values"`
+		// terraform.tfstate
+		Version   int `json:"version"`
+		Resources []struct {
+			Module    string `json:"module"`
+			Mode      string `json:"mode"`
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Instances []struct {
+				IndexKey            interface{}            `json:"index_key"`
+				Attributes          map[string]interface{} `json:"attributes"`
+				SensitiveAttributes []json.RawMessage      `json:"sensitive_attributes"`
+				Dependencies        []string               `json:"dependencies"`
+			} `json:"instances"`
+		} `json:"resources"`
+		Outputs map[string]struct {
+			Sensitive bool        `json:"sensitive"`
+			Value     interface{} `json:"value"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	resources := make(map[string]interface{})
+	outputs := make(map[string]interface{})
+	values := doc.PlannedValues
+	if values == nil {
+		values = doc.Values
+	}
+	switch {
+	case values != nil:
+		addTerraformModule(resources, values.RootModule)
+		for name, output := range values.Outputs {
+			if !output.Sensitive {
+				outputs[name] = map[string]interface{}{"Value": output.Value}
+			}
+		}
+	case doc.Version > 0:
+		for _, r := range doc.Resources {
+			if r.Mode != "managed" {
+				continue
+			}
+			for _, instance := range r.Instances {
+				address := r.Type + "." + r.Name + terraformIndex(instance.IndexKey)
+				if r.Module != "" {
+					address = r.Module + "." + address
+				}
+				resource := terraformResource(r.Type, r.Module, instance.Attributes, stateSensitiveAttributes(instance.SensitiveAttributes))
+				if len(instance.Dependencies) > 0 {
+					resource["DependsOn"] = instance.Dependencies
+				}
+				resources[address] = resource
+			}
+		}
+		for name, output := range doc.Outputs {
+			if !output.Sensitive {
+				outputs[name] = map[string]interface{}{"Value": output.Value}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("neither a Terraform plan, state nor state file")
+	}
+
+	infraState := map[string]interface{}{"Resources": resources}
+	if len(outputs) > 0 {
+		infraState["Outputs"] = outputs
+	}
+	return infraState, nil
+}
+
+// addTerraformModule adds the managed resources of module and its child
+// modules, keyed by their addresses.
+func addTerraformModule(resources map[string]interface{}, module terraformModule) {
+	for _, r := range module.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		sensitive := make(map[string]bool)
+		for key, marks := range r.SensitiveValues {
+			// an attribute with a sensitive part is dropped as a whole
+			if hasSensitiveMark(marks) {
+				sensitive[key] = true
+			}
+		}
+		resources[r.Address] = terraformResource(r.Type, module.Address, r.Values, sensitive)
+	}
+	for _, child := range module.ChildModules {
+		addTerraformModule(resources, child)
+	}
+}
+
+// terraformResource converts a resource's attributes into properties named
+// like CloudFormation's. Sensitive attributes, which plans and state files
+// hold in plain text, are left out, as are unset attributes and tags_all,
+// which repeats the tags.
+func terraformResource(tfType, module string, attributes map[string]interface{}, sensitive map[string]bool) map[string]interface{} {
+	props := make(map[string]interface{}, len(attributes))
+	for key, value := range attributes {
+		if sensitive[key] || isSecretAttribute(key) || key == "tags_all" || isEmptyValue(value) {
+			continue
+		}
+		if name, ok := terraformPolicyAttributes[key]; ok {
+			if s, ok := value.(string); ok {
+				var doc interface{}
+				if json.Unmarshal([]byte(s), &doc) == nil {
+					props[name] = doc
+					continue
+				}
+			}
+		}
+		props[exportedKey(key)] = value
+	}
+
+	resourceType := tfType
+	if cfnType, ok := terraformTypes[tfType]; ok {
+		resourceType = cfnType
+	}
+	resource := map[string]interface{}{
+		"Type":          resourceType,
+		"TerraformType": tfType,
+		"Properties":    props,
+	}
+	if module != "" {
+		resource["Module"] = module
+	}
+	return resource
+}
+
+// stateSensitiveAttributes returns the top-level attributes a state file
+// marks sensitive. Each mark is a path such as
+// [{"type":"get_attr","value":"password"}].
+func stateSensitiveAttributes(paths []json.RawMessage) map[string]bool {
+	sensitive := make(map[string]bool)
+	for _, raw := range paths {
+		var path []struct {
+			Value interface{} `json:"value"`
+		}
+		if json.Unmarshal(raw, &path) == nil && len(path) > 0 {
+			if name, ok := path[0].Value.(string); ok {
+				sensitive[name] = true
+			}
+		}
+	}
+	return sensitive
+}
+
+// hasSensitiveMark reports whether a sensitive_values entry, which mirrors
+// the attribute with true for each sensitive part, marks anything.
+func hasSensitiveMark(marks interface{}) bool {
+	switch m := marks.(type) {
+	case bool:
+		return m
+	case []interface{}:
+		for _, item := range m {
+			if hasSensitiveMark(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range m {
+			if hasSensitiveMark(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSecretAttribute catches secrets of providers that do not mark them
+// sensitive, e.g. password, master_password or secret_string.
+func isSecretAttribute(key string) bool {
+	for _, word := range []string{"password", "secret_string", "secret_binary", "private_key", "token"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// terraformIndex renders a count or for_each key as in an address
+func terraformIndex(key interface{}) string {
+	switch k := key.(type) {
+	case nil:
+		return ""
+	case string:
+		return fmt.Sprintf("[%q]", k)
+	case float64:
+		return fmt.Sprintf("[%d]", int(k))
+	default:
+		return fmt.Sprintf("[%v]", k)
+	}
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
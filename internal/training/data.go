@@ -0,0 +1,250 @@
+// Package training prepares fine-tuning data for the architecture model.
+package training
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Default limits of Options
+const (
+	DefaultMinSatisfaction = 3
+	DefaultIntentCap       = 3.0
+	// DefaultMaxChars keeps an example, prompt and completion together,
+	// within the 2048 tokens llm_finetune.py trains on
+	DefaultMaxChars = 8000
+)
+
+// unlabeled is the intent examples without one are counted under
+const unlabeled = "(none)"
+
+// Example is one line of training JSONL. Prompt and completion are what
+// the model is trained on; intent and satisfaction only steer preparation.
+type Example struct {
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion"`
+	Intent     string `json:"intent,omitempty"`
+	// Satisfaction is the user's rating of the completion, 1 (bad) to 5
+	Satisfaction *float64 `json:"satisfaction,omitempty"`
+}
+
+// Options controls which examples Prepare keeps.
+type Options struct {
+	// MinSatisfaction drops rated examples below it; unrated ones are kept
+	MinSatisfaction float64
+	// IntentCap limits every intent to this many times the examples of the
+	// median intent, so one frequent question type does not dominate; 0
+	// disables balancing
+	IntentCap float64
+	// MaxChars drops examples longer than this, prompt and completion
+	// together
+	MaxChars int
+}
+
+// DefaultOptions returns the limits used when none are given.
+func DefaultOptions() Options {
+	return Options{MinSatisfaction: DefaultMinSatisfaction, IntentCap: DefaultIntentCap, MaxChars: DefaultMaxChars}
+}
+
+// Problem is a line that was dropped, with why.
+type Problem struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// Stats describe a dataset before and after preparation.
+type Stats struct {
+	Lines           int            `json:"lines"`
+	Invalid         int            `json:"invalid"`
+	Duplicates      int            `json:"duplicates"`
+	LowSatisfaction int            `json:"low_satisfaction"`
+	TooLong         int            `json:"too_long"`
+	Rebalanced      int            `json:"rebalanced"`
+	Kept            int            `json:"kept"`
+	Intents         map[string]int `json:"intents"`
+	// AvgPromptChars and AvgCompletionChars are over the kept examples
+	AvgPromptChars     int `json:"avg_prompt_chars"`
+	AvgCompletionChars int `json:"avg_completion_chars"`
+	// EstimatedTokens approximates the tokens of one epoch at 4 characters
+	// per token
+	EstimatedTokens int `json:"estimated_tokens"`
+}
+
+// Dataset is the result of Prepare.
+type Dataset struct {
+	Examples []Example `json:"-"`
+	Stats    Stats     `json:"stats"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// Prepare reads prompt/completion JSONL and returns the examples worth
+// training on: malformed lines, duplicates, poorly rated and over-long
+// examples are dropped and intents are balanced. The order of the input is
+// kept, so the result is deterministic.
+func Prepare(r io.Reader, opts Options) (*Dataset, error) {
+	type candidate struct {
+		Example
+		line int
+	}
+	dataset := &Dataset{Stats: Stats{Intents: map[string]int{}}}
+	drop := func(line int, reason string) {
+		dataset.Problems = append(dataset.Problems, Problem{Line: line, Reason: reason})
+	}
+
+	var candidates []candidate
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		dataset.Stats.Lines++
+
+		var example Example
+		if err := json.Unmarshal(raw, &example); err != nil {
+			dataset.Stats.Invalid++
+			drop(line, "not a JSON object with prompt and completion: "+err.Error())
+			continue
+		}
+		example.Prompt = strings.TrimSpace(example.Prompt)
+		example.Completion = strings.TrimSpace(example.Completion)
+		example.Intent = strings.TrimSpace(example.Intent)
+		switch {
+		case example.Prompt == "" || example.Completion == "":
+			dataset.Stats.Invalid++
+			drop(line, "empty prompt or completion")
+			continue
+		case example.Satisfaction != nil && (*example.Satisfaction < 1 || *example.Satisfaction > 5):
+			dataset.Stats.Invalid++
+			drop(line, fmt.Sprintf("satisfaction %g is outside 1-5", *example.Satisfaction))
+			continue
+		case example.Satisfaction != nil && *example.Satisfaction < opts.MinSatisfaction:
+			dataset.Stats.LowSatisfaction++
+			drop(line, fmt.Sprintf("satisfaction %g is below %g", *example.Satisfaction, opts.MinSatisfaction))
+			continue
+		case opts.MaxChars > 0 && len(example.Prompt)+len(example.Completion) > opts.MaxChars:
+			dataset.Stats.TooLong++
+			drop(line, fmt.Sprintf("%d characters, more than %d", len(example.Prompt)+len(example.Completion), opts.MaxChars))
+			continue
+		}
+
+		key := normalize(example.Prompt) + "\x00" + normalize(example.Completion)
+		if first, ok := seen[key]; ok {
+			dataset.Stats.Duplicates++
+			drop(line, fmt.Sprintf("duplicate of line %d", first))
+			continue
+		}
+		seen[key] = line
+		candidates = append(candidates, candidate{Example: example, line: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read training data: %w", err)
+	}
+
+	// Balance intents: keep the best rated examples of an intent over its cap
+	byIntent := map[string][]int{}
+	for i, c := range candidates {
+		byIntent[intentOf(c.Example)] = append(byIntent[intentOf(c.Example)], i)
+	}
+	keep := make([]bool, len(candidates))
+	limit := intentLimit(byIntent, opts.IntentCap)
+	for _, indexes := range byIntent {
+		if limit == 0 || len(indexes) <= limit {
+			for _, i := range indexes {
+				keep[i] = true
+			}
+			continue
+		}
+		ranked := append([]int(nil), indexes...)
+		sort.SliceStable(ranked, func(a, b int) bool {
+			return satisfaction(candidates[ranked[a]].Example) > satisfaction(candidates[ranked[b]].Example)
+		})
+		for _, i := range ranked[:limit] {
+			keep[i] = true
+		}
+		for _, i := range ranked[limit:] {
+			dataset.Stats.Rebalanced++
+			drop(candidates[i].line, fmt.Sprintf("intent %s has more than %d examples", intentOf(candidates[i].Example), limit))
+		}
+	}
+
+	var promptChars, completionChars int
+	for i, c := range candidates {
+		if !keep[i] {
+			continue
+		}
+		dataset.Examples = append(dataset.Examples, c.Example)
+		dataset.Stats.Intents[intentOf(c.Example)]++
+		promptChars += len(c.Prompt)
+		completionChars += len(c.Completion)
+	}
+	sort.Slice(dataset.Problems, func(i, j int) bool { return dataset.Problems[i].Line < dataset.Problems[j].Line })
+
+	dataset.Stats.Kept = len(dataset.Examples)
+	if dataset.Stats.Kept > 0 {
+		dataset.Stats.AvgPromptChars = promptChars / dataset.Stats.Kept
+		dataset.Stats.AvgCompletionChars = completionChars / dataset.Stats.Kept
+		dataset.Stats.EstimatedTokens = (promptChars + completionChars) / 4
+	}
+	return dataset, nil
+}
+
+// WriteJSONL writes the examples as the prompt/completion JSONL the
+// training script reads. Intent and satisfaction are left out.
+func (d *Dataset) WriteJSONL(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, example := range d.Examples {
+		if err := encoder.Encode(Example{Prompt: example.Prompt, Completion: example.Completion}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// intentLimit returns how many examples an intent may keep: capFactor times
+// the median count of the intents, or 0 when nothing is limited. With a
+// single intent there is nothing to balance against.
+func intentLimit(byIntent map[string][]int, capFactor float64) int {
+	if capFactor <= 0 || len(byIntent) < 2 {
+		return 0
+	}
+	counts := make([]int, 0, len(byIntent))
+	for _, indexes := range byIntent {
+		counts = append(counts, len(indexes))
+	}
+	sort.Ints(counts)
+	median := float64(counts[len(counts)/2])
+	if len(counts)%2 == 0 {
+		median = float64(counts[len(counts)/2-1]+counts[len(counts)/2]) / 2
+	}
+	return int(math.Ceil(median * capFactor))
+}
+
+func intentOf(example Example) string {
+	if example.Intent == "" {
+		return unlabeled
+	}
+	return example.Intent
+}
+
+// satisfaction ranks unrated examples in the middle of the scale.
+func satisfaction(example Example) float64 {
+	if example.Satisfaction == nil {
+		return 3
+	}
+	return *example.Satisfaction
+}
+
+// normalize makes duplicates that differ only in case or whitespace equal.
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
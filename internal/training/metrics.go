@@ -0,0 +1,34 @@
+package training
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// metricPattern matches "name: value" and "name=value" pairs whose name is a
+// training metric, as Hugging Face Trainer ({'loss': 1.23, 'epoch': 0.5}),
+// XGBoost ([10]#011train-rmse:0.12) and most training scripts print them.
+var metricPattern = regexp.MustCompile(`(?i)['"]?\b((?:[a-z0-9]+[_-])*(?:loss|accuracy|acc|rmse|mae|auc|f1|error|perplexity|epoch|learning_rate|lr))['"]?\s*[:=]\s*([-+]?(?:\d+\.?\d*|\.\d+)(?:e[-+]?\d+)?)`)
+
+// Metric is a value read from a training log line.
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// ParseMetrics returns the loss, accuracy and similar values on a log line,
+// in the order they appear.
+func ParseMetrics(line string) []Metric {
+	// XGBoost separates its metrics with an encoded tab
+	line = strings.ReplaceAll(line, "#011", " ")
+	var metrics []Metric
+	for _, match := range metricPattern.FindAllStringSubmatch(line, -1) {
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		metrics = append(metrics, Metric{Name: strings.ToLower(match[1]), Value: value})
+	}
+	return metrics
+}
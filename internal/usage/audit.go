@@ -0,0 +1,236 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry records one request sent to a model. Prompts and answers are
+// not stored, only what was sent where and how much was redacted from it.
+type AuditEntry struct {
+	Time         time.Time      `json:"time"`
+	Provider     string         `json:"provider"`
+	Model        string         `json:"model"`
+	Operation    string         `json:"operation"`
+	Redactions   map[string]int `json:"redactions,omitempty"`
+	InputTokens  int            `json:"input_tokens"`
+	OutputTokens int            `json:"output_tokens"`
+	Success      bool           `json:"success"`
+
+	// TokensEstimated is set when the backend did not report the token
+	// counts and they were estimated at ~4 characters per token
+	TokensEstimated bool `json:"tokens_estimated,omitempty"`
+
+	// PrevHash and Hash chain entries together when hash chaining is on:
+	// Hash covers the entry including PrevHash, the Hash of the entry before.
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// AuditLog appends entries to a JSON Lines file that is never rewritten.
+type AuditLog struct {
+	path    string
+	chained bool
+}
+
+// auditMu serialises appends within the process; the lock file serialises
+// them across processes when entries are chained.
+var auditMu sync.Mutex
+
+// NewAuditLog returns the audit log at path, or ~/.cloudai/audit.jsonl when
+// path is empty. With chained, each entry carries the hash of the one before
+// so that edits and deletions can be detected by VerifyAudit.
+func NewAuditLog(path string, chained bool) (*AuditLog, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find home directory: %w", err)
+		}
+		path = filepath.Join(home, ".cloudai", "audit.jsonl")
+	}
+	return &AuditLog{path: path, chained: chained}, nil
+}
+
+// Path returns the audit log file location.
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// Append adds an entry to the log.
+func (l *AuditLog) Append(entry AuditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	if l.chained {
+		unlock, err := LockFile(l.path + ".lock")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		entry.PrevHash, err = l.lastHash()
+		if err != nil {
+			return err
+		}
+		entry.Hash = entryHash(entry)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Load returns all entries recorded at or after since. A missing log is not
+// an error; a malformed line is, since the log is meant to be complete.
+func (l *AuditLog) Load(since time.Time) ([]AuditEntry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", l.path, line, err)
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// lastHash returns the hash of the last entry in the log, or "" when the log
+// is empty or its last entry was written without chaining. Only the end of
+// the file is read.
+func (l *AuditLog) lastHash() (string, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	offset := max(info.Size()-1024*1024, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil {
+		return "", err
+	}
+	lines := bytes.Split(bytes.TrimRight(tail, "\n"), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(last, &entry); err != nil {
+		return "", fmt.Errorf("%s: last entry is malformed: %w", l.path, err)
+	}
+	return entry.Hash, nil
+}
+
+// AuditVerification is the result of checking a log's hash chain.
+type AuditVerification struct {
+	Entries   int `json:"entries"`
+	Chained   int `json:"chained"`
+	Unchained int `json:"unchained"`
+	// BrokenAt is the 1-based line of the first entry whose hash does not
+	// match, or 0 when the chain is intact.
+	BrokenAt int    `json:"broken_at,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+// VerifyAudit recomputes the hash chain of entries. Entries written without
+// chaining are counted but cannot be verified; a chain may start after them.
+func VerifyAudit(entries []AuditEntry) AuditVerification {
+	result := AuditVerification{Entries: len(entries)}
+	prev := ""
+	for i, entry := range entries {
+		if entry.Hash == "" {
+			if prev != "" {
+				result.BrokenAt, result.Problem = i+1, "entry has no hash inside a hash chain"
+				return result
+			}
+			result.Unchained++
+			continue
+		}
+		if entry.PrevHash != prev {
+			result.BrokenAt, result.Problem = i+1, "previous hash does not match: an entry was removed, inserted or reordered"
+			return result
+		}
+		if entryHash(entry) != entry.Hash {
+			result.BrokenAt, result.Problem = i+1, "hash does not match the entry: it was modified"
+			return result
+		}
+		result.Chained++
+		prev = entry.Hash
+	}
+	return result
+}
+
+// entryHash is the SHA-256 of the entry's JSON without its own hash.
+func entryHash(entry AuditEntry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// LockFile creates path exclusively, waiting for another process holding it.
+// A lock older than staleLock is left over from a killed process and taken.
+func LockFile(path string) (func(), error) {
+	const staleLock = 10 * time.Second
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,195 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Canary verdicts of a comparison
+const (
+	CanaryArch    = "arch"
+	CanaryGeneral = "general"
+	CanaryTie     = "tie"
+)
+
+// canaryMinJudged is how many judged comparisons a report needs before it
+// calls one model better
+const canaryMinJudged = 20
+
+// CanaryAnswer is one model's side of a comparison.
+type CanaryAnswer struct {
+	Model      string  `json:"model"`
+	Answer     string  `json:"answer,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	Refused    bool    `json:"refused,omitempty"`
+	DurationMs int64   `json:"duration_ms"`
+	Cost       float64 `json:"cost"`
+}
+
+// CanaryEntry is a question answered by both the architecture model and the
+// general model. Question and answers are kept as they were sent, with
+// sensitive values replaced by placeholders.
+type CanaryEntry struct {
+	Time     time.Time    `json:"time"`
+	Question string       `json:"question"`
+	Arch     CanaryAnswer `json:"arch"`
+	General  CanaryAnswer `json:"general"`
+	// Similarity is the word overlap of the two answers, 0 to 1
+	Similarity float64 `json:"similarity"`
+	// Winner is the judge's verdict, CanaryArch, CanaryGeneral or
+	// CanaryTie; empty when the answers were not judged
+	Winner string `json:"winner,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// CanaryLog reads and appends comparisons in a JSON Lines file.
+type CanaryLog struct {
+	path string
+}
+
+// NewCanaryLog returns the log at ~/.cloudai/canary.jsonl.
+func NewCanaryLog() (*CanaryLog, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return &CanaryLog{path: filepath.Join(home, ".cloudai", "canary.jsonl")}, nil
+}
+
+// Path returns the log file location.
+func (l *CanaryLog) Path() string {
+	return l.path
+}
+
+// Append adds a comparison to the log.
+func (l *CanaryLog) Append(entry CanaryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Load returns all comparisons recorded at or after since. A missing log is
+// not an error; malformed lines are skipped.
+func (l *CanaryLog) Load(since time.Time) ([]CanaryEntry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []CanaryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry CanaryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// CanarySide aggregates one model's answers in a report.
+type CanarySide struct {
+	Model        string  `json:"model"`
+	Wins         int     `json:"wins"`
+	Errors       int     `json:"errors"`
+	Refusals     int     `json:"refusals"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+	Cost         float64 `json:"cost"`
+}
+
+// CanaryReport summarizes comparisons.
+type CanaryReport struct {
+	Samples       int        `json:"samples"`
+	Judged        int        `json:"judged"`
+	Ties          int        `json:"ties"`
+	AvgSimilarity float64    `json:"avg_similarity"`
+	Arch          CanarySide `json:"arch"`
+	General       CanarySide `json:"general"`
+	// Verdict is CanaryArch or CanaryGeneral when that model won clearly
+	// more of enough judged comparisons, CanaryTie when neither did, and
+	// empty when there are too few to tell
+	Verdict string `json:"verdict"`
+}
+
+// SummarizeCanary aggregates comparisons into a report. One model is
+// called better when it wins at least 60% of the judged comparisons it
+// did not tie, out of at least 20.
+func SummarizeCanary(entries []CanaryEntry) CanaryReport {
+	report := CanaryReport{Samples: len(entries)}
+	if len(entries) == 0 {
+		return report
+	}
+	var archMs, generalMs int64
+	var similarity float64
+	for _, entry := range entries {
+		report.Arch.Model, report.General.Model = entry.Arch.Model, entry.General.Model
+		similarity += entry.Similarity
+		archMs += entry.Arch.DurationMs
+		generalMs += entry.General.DurationMs
+		for _, side := range []struct {
+			answer CanaryAnswer
+			report *CanarySide
+		}{{entry.Arch, &report.Arch}, {entry.General, &report.General}} {
+			side.report.Cost += side.answer.Cost
+			if side.answer.Error != "" {
+				side.report.Errors++
+			}
+			if side.answer.Refused {
+				side.report.Refusals++
+			}
+		}
+		switch entry.Winner {
+		case CanaryArch:
+			report.Judged++
+			report.Arch.Wins++
+		case CanaryGeneral:
+			report.Judged++
+			report.General.Wins++
+		case CanaryTie:
+			report.Judged++
+			report.Ties++
+		}
+	}
+	report.AvgSimilarity = similarity / float64(len(entries))
+	report.Arch.AvgLatencyMs = archMs / int64(len(entries))
+	report.General.AvgLatencyMs = generalMs / int64(len(entries))
+
+	if report.Judged < canaryMinJudged {
+		return report
+	}
+	decided := report.Arch.Wins + report.General.Wins
+	switch {
+	case decided > 0 && float64(report.Arch.Wins) >= 0.6*float64(decided):
+		report.Verdict = CanaryArch
+	case decided > 0 && float64(report.General.Wins) >= 0.6*float64(decided):
+		report.Verdict = CanaryGeneral
+	default:
+		report.Verdict = CanaryTie
+	}
+	return report
+}
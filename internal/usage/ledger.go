@@ -0,0 +1,96 @@
+// Package usage keeps a local, append-only ledger of answered questions. It
+// never leaves the machine; it exists so users can see their own usage.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is a single answered (or failed) question. The question text itself
+// is not stored.
+type Entry struct {
+	Time         time.Time `json:"time"`
+	Project      string    `json:"project"`
+	Backend      string    `json:"backend"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost"`
+	DurationMs   int64     `json:"duration_ms"`
+	Success      bool      `json:"success"`
+	Resources    []string  `json:"resources,omitempty"`
+	// Cache is the absolute path of the project's cache file, which tells
+	// projects, and environments of one project, apart
+	Cache string `json:"cache,omitempty"`
+}
+
+// Ledger reads and appends entries in a JSON Lines file.
+type Ledger struct {
+	path string
+}
+
+// NewLedger returns the ledger at ~/.cloudai/usage.jsonl.
+func NewLedger() (*Ledger, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return &Ledger{path: filepath.Join(home, ".cloudai", "usage.jsonl")}, nil
+}
+
+// Path returns the ledger file location.
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds an entry to the ledger.
+func (l *Ledger) Append(entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Load returns all entries recorded at or after since. A missing ledger is
+// not an error; malformed lines are skipped.
+func (l *Ledger) Load(since time.Time) ([]Entry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,146 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// DayStats aggregates a single calendar day.
+type DayStats struct {
+	Date    string  `json:"date"`
+	Queries int     `json:"queries"`
+	Cost    float64 `json:"cost"`
+}
+
+// Count is a name with an occurrence count.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Stats summarizes a set of ledger entries.
+type Stats struct {
+	Queries      int        `json:"queries"`
+	Failed       int        `json:"failed"`
+	AvgLatencyMs int64      `json:"avg_latency_ms"`
+	TotalTokens  int        `json:"total_tokens"`
+	TotalCost    float64    `json:"total_cost"`
+	Days         []DayStats `json:"days"`
+	Models       []Count    `json:"models"`
+	Resources    []Count    `json:"resources"`
+}
+
+// Summarize computes statistics over entries, with one row per day from
+// since to now (inclusive) so gaps show up as zero-query days.
+func Summarize(entries []Entry, since, now time.Time) *Stats {
+	stats := &Stats{}
+	days := make(map[string]*DayStats)
+	models := make(map[string]int)
+	resources := make(map[string]int)
+	var totalLatency int64
+
+	start := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	for d := start; !d.After(now); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		days[date] = &DayStats{Date: date}
+	}
+
+	for _, e := range entries {
+		stats.Queries++
+		if !e.Success {
+			stats.Failed++
+		}
+		totalLatency += e.DurationMs
+		stats.TotalTokens += e.InputTokens + e.OutputTokens
+		stats.TotalCost += e.Cost
+
+		date := e.Time.Local().Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &DayStats{Date: date}
+			days[date] = day
+		}
+		day.Queries++
+		day.Cost += e.Cost
+
+		if e.Model != "" {
+			models[e.Backend+"/"+e.Model]++
+		}
+		for _, r := range e.Resources {
+			resources[r]++
+		}
+	}
+
+	if stats.Queries > 0 {
+		stats.AvgLatencyMs = totalLatency / int64(stats.Queries)
+	}
+	for _, day := range days {
+		stats.Days = append(stats.Days, *day)
+	}
+	sort.Slice(stats.Days, func(i, j int) bool { return stats.Days[i].Date < stats.Days[j].Date })
+	stats.Models = sortedCounts(models)
+	stats.Resources = sortedCounts(resources)
+	return stats
+}
+
+// ProjectStats is the spend of one project, keyed by its cache path.
+type ProjectStats struct {
+	Project      string  `json:"project"`
+	Queries      int     `json:"queries"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	// CostToday is the part of Cost spent since the start of today
+	CostToday float64 `json:"cost_today"`
+}
+
+// ByProject totals entries per project, most expensive first. Entries
+// recorded without a cache path are grouped by their project directory.
+func ByProject(entries []Entry, now time.Time) []ProjectStats {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	projects := make(map[string]*ProjectStats)
+	for _, e := range entries {
+		key := e.Cache
+		if key == "" {
+			key = e.Project
+		}
+		project, ok := projects[key]
+		if !ok {
+			project = &ProjectStats{Project: key}
+			projects[key] = project
+		}
+		project.Queries++
+		project.InputTokens += e.InputTokens
+		project.OutputTokens += e.OutputTokens
+		project.Cost += e.Cost
+		if !e.Time.Before(today) {
+			project.CostToday += e.Cost
+		}
+	}
+
+	list := make([]ProjectStats, 0, len(projects))
+	for _, project := range projects {
+		list = append(list, *project)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Cost != list[j].Cost {
+			return list[i].Cost > list[j].Cost
+		}
+		return list[i].Project < list[j].Project
+	})
+	return list
+}
+
+func sortedCounts(m map[string]int) []Count {
+	counts := make([]Count, 0, len(m))
+	for name, n := range m {
+		counts = append(counts, Count{Name: name, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}